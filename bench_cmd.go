@@ -0,0 +1,467 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	asr "gosdk/client"
+)
+
+const benchUsage = `Usage: stt bench [options] <file or directory>...
+
+Streams a corpus of audio files in concurrent sessions of a running server and
+reports how long the transcriptions took, the latency from speech_stopped to the
+transcription result, lost events and the server's CPU and memory. A directory
+stands for the WAV files in it. Each session transcribes the corpus in turn, starting
+at a different file, once or with -duration until the duration elapsed.
+
+Options:
+`
+
+// benchRun is the outcome of transcribing one file in a session of stt bench
+type benchRun struct {
+	file     string
+	elapsed  time.Duration
+	audio    time.Duration
+	segments int
+	err      error
+}
+
+// benchEvents accumulates the event timings of all sessions of stt bench
+type benchEvents struct {
+	mutex     sync.Mutex
+	latencies []time.Duration // speech_stopped to the item's completed or failed event
+	failed    int             // items whose transcription failed
+	missed    uint64          // server events lost, from the sequence gaps
+	lost      int             // committed items that never got a result
+}
+
+// benchTimer times the utterances of one session. Like a realtime client it commits the
+// audio on speech_stopped, and matches each input_audio_buffer.committed with the
+// oldest speech_stopped not yet matched; the commit of the tail of a file, without
+// one, is not timed.
+type benchTimer struct {
+	events    *benchEvents
+	mutex     sync.Mutex
+	stopped   []time.Time
+	committed map[string]time.Time
+}
+
+func newBenchTimer(events *benchEvents) *benchTimer {
+	return &benchTimer{events: events, committed: make(map[string]time.Time)}
+}
+
+// observe subscribes the timer to the events of a recognizer
+func (t *benchTimer) observe(recognizer *asr.Recognizer) {
+	recognizer.On(asr.EventTypeInputAudioBufferSpeechStopped, func(event asr.Event) {
+		t.mutex.Lock()
+		t.stopped = append(t.stopped, time.Now())
+		t.mutex.Unlock()
+		// The server commits utterances it cut at vad.max_utterance_seconds itself
+		if e, ok := event.(*asr.InputAudioBufferSpeechStoppedEvent); ok && e.Reason == asr.SpeechStoppedReasonMaxDuration {
+			return
+		}
+		// Not on the goroutine dispatching the events
+		go recognizer.CommitAudio()
+	})
+	recognizer.On(asr.EventTypeInputAudioBufferCommitted, func(event asr.Event) {
+		e, ok := event.(*asr.InputAudioBufferCommittedEvent)
+		if !ok {
+			return
+		}
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		var at time.Time
+		if len(t.stopped) > 0 {
+			at, t.stopped = t.stopped[0], t.stopped[1:]
+		}
+		if e.ItemID != "" {
+			t.committed[e.ItemID] = at
+		}
+	})
+	recognizer.On(asr.EventTypeConversationItemInputAudioTranscriptionCompleted, func(event asr.Event) {
+		if e, ok := event.(*asr.ConversationItemInputAudioTranscriptionCompletedEvent); ok {
+			t.result(e.Item.ID, false)
+		}
+	})
+	recognizer.On(asr.EventTypeConversationItemInputAudioTranscriptionFailed, func(event asr.Event) {
+		if e, ok := event.(*asr.ConversationItemInputAudioTranscriptionFailedEvent); ok {
+			t.result(e.ItemID, true)
+		}
+	})
+	recognizer.OnEventGap(func(gap asr.EventGap) {
+		t.events.mutex.Lock()
+		defer t.events.mutex.Unlock()
+		t.events.missed += gap.Missed()
+	})
+}
+
+// result records the completed or failed event of an item
+func (t *benchTimer) result(itemID string, failed bool) {
+	now := time.Now()
+	t.mutex.Lock()
+	stopped, ok := t.committed[itemID]
+	delete(t.committed, itemID)
+	t.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	t.events.mutex.Lock()
+	defer t.events.mutex.Unlock()
+	if failed {
+		t.events.failed++
+	}
+	if !stopped.IsZero() {
+		t.events.latencies = append(t.events.latencies, now.Sub(stopped))
+	}
+}
+
+// finish counts the items of the session left without a result
+func (t *benchTimer) finish() {
+	t.mutex.Lock()
+	lost := len(t.committed)
+	t.mutex.Unlock()
+
+	t.events.mutex.Lock()
+	defer t.events.mutex.Unlock()
+	t.events.lost += lost
+}
+
+// serverStats samples /v1/admin/process of the server while stt bench runs
+type serverStats struct {
+	url    string
+	apiKey string
+	client *http.Client
+
+	mutex   sync.Mutex
+	first   *processSample
+	last    *processSample
+	peakRSS uint64
+	peakSys uint64
+	peakGo  int
+	err     error
+}
+
+// processSample is the part of the server's process stats stt bench reports
+type processSample struct {
+	Timestamp  time.Time `json:"timestamp"`
+	CPUSeconds float64   `json:"cpu_seconds"`
+	NumCPU     int       `json:"num_cpu"`
+	RSSBytes   uint64    `json:"rss_bytes"`
+	SysBytes   uint64    `json:"sys_bytes"`
+	Goroutines int       `json:"goroutines"`
+}
+
+// processStatsURL returns the process stats endpoint of the server of a realtime URL
+func processStatsURL(realtimeURL string) (string, error) {
+	u, err := neturl.Parse(realtimeURL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	u.Path, u.RawQuery = "/v1/admin/process", ""
+	return u.String(), nil
+}
+
+// sample fetches the process stats once
+func (s *serverStats) sample(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", s.url, resp.Status)
+	}
+	var sample processSample
+	if err := json.NewDecoder(resp.Body).Decode(&sample); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.first == nil {
+		s.first = &sample
+	}
+	s.last = &sample
+	s.peakRSS = max(s.peakRSS, sample.RSSBytes)
+	s.peakSys = max(s.peakSys, sample.SysBytes)
+	s.peakGo = max(s.peakGo, sample.Goroutines)
+	return nil
+}
+
+// run samples the process stats every interval until ctx is done, and once more then
+func (s *serverStats) run(ctx context.Context, interval time.Duration, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.sample(ctx); err != nil && ctx.Err() == nil {
+				s.mutex.Lock()
+				s.err = err
+				s.mutex.Unlock()
+			}
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			s.sample(final)
+			cancel()
+			return
+		}
+	}
+}
+
+// runBenchCommand implements "stt bench" and returns the exit code
+func runBenchCommand(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	url := fs.String("url", defaultRealtimeURL, "Realtime WebSocket URL of the server")
+	apiKey := fs.String("key", "", "API key sent as the bearer token")
+	language := fs.String("lang", asr.DefaultConfig().TranscriptionLanguage, "Transcription language")
+	sessions := fs.Int("sessions", 4, "Number of concurrent sessions")
+	speed := fs.Float64("speed", 1, "Pacing of the audio as a multiple of real time, negative to send it unpaced")
+	duration := fs.Duration("duration", 0, "Keep the sessions transcribing the corpus this long, a soak test; 0 transcribes it once")
+	timeout := fs.Duration("timeout", 10*time.Minute, "Give up on a file after this long")
+	statsInterval := fs.Duration("stats-interval", time.Second, "Sampling interval of the server's CPU and memory, 0 disables sampling")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, benchUsage)
+		fs.PrintDefaults()
+	}
+	inputs, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if len(inputs) == 0 || *sessions <= 0 {
+		fs.Usage()
+		return 2
+	}
+	corpus, err := benchCorpus(inputs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✘ %v\n", err)
+		return 1
+	}
+
+	config := asr.DefaultConfig()
+	config.URL = *url
+	config.TranscriptionLanguage = *language
+	if *apiKey != "" {
+		config.Headers = map[string]string{"Authorization": "Bearer " + *apiKey}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var stats *serverStats
+	statsDone := make(chan struct{})
+	statsCtx, stopStats := context.WithCancel(ctx)
+	defer stopStats()
+	if *statsInterval > 0 {
+		statsURL, err := processStatsURL(*url)
+		if err == nil {
+			stats = &serverStats{url: statsURL, apiKey: *apiKey, client: &http.Client{Timeout: 5 * time.Second}}
+			err = stats.sample(ctx)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ server CPU and memory not sampled: %v\n", err)
+			stats = nil
+		}
+	}
+	if stats != nil {
+		go stats.run(statsCtx, *statsInterval, statsDone)
+	} else {
+		close(statsDone)
+	}
+
+	fmt.Printf("▶ streaming %d file(s) in %d sessions of %s\n", len(corpus), *sessions, *url)
+	start := time.Now()
+	events := &benchEvents{}
+	var mutex sync.Mutex
+	var runs []benchRun
+	var wg sync.WaitGroup
+	for i := 0; i < *sessions; i++ {
+		wg.Add(1)
+		go func(session int) {
+			defer wg.Done()
+			for n := 0; ctx.Err() == nil; n++ {
+				if *duration > 0 {
+					if time.Since(start) >= *duration {
+						return
+					}
+				} else if n == len(corpus) {
+					return
+				}
+				run := benchFile(ctx, corpus[(session+n)%len(corpus)], config, *speed, *timeout, events)
+				mutex.Lock()
+				runs = append(runs, run)
+				mutex.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	wall := time.Since(start)
+	stopStats()
+	<-statsDone
+
+	return reportBench(runs, events, stats, wall)
+}
+
+// benchCorpus expands the directories among the inputs to the WAV files in them
+func benchCorpus(inputs []string) ([]string, error) {
+	var corpus []string
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			corpus = append(corpus, input)
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(input, "*.wav"))
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no WAV files in %s", input)
+		}
+		sort.Strings(files)
+		corpus = append(corpus, files...)
+	}
+	return corpus, nil
+}
+
+// benchFile transcribes a file in a session of its own, timing its utterances
+func benchFile(ctx context.Context, file string, config *asr.Config, speed float64, timeout time.Duration, events *benchEvents) benchRun {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	timer := newBenchTimer(events)
+	start := time.Now()
+	transcript, err := asr.TranscribeFileContext(ctx, file, asr.TranscribeFileOptions{
+		Config:  config,
+		Speed:   speed,
+		Observe: timer.observe,
+	})
+	timer.finish()
+	run := benchRun{file: file, elapsed: time.Since(start), err: err}
+	if transcript != nil {
+		run.audio, run.segments = transcript.Duration, len(transcript.Segments)
+	}
+	return run
+}
+
+// reportBench prints the timings of the transcriptions of stt bench, the utterance
+// latency, lost events and the server's resource usage, and returns the exit code, 1
+// when a transcription failed or results were lost
+func reportBench(runs []benchRun, events *benchEvents, stats *serverStats, wall time.Duration) int {
+	var elapsed []time.Duration
+	var audio time.Duration
+	failed := 0
+	for _, run := range runs {
+		if run.err != nil {
+			fmt.Fprintf(os.Stderr, "✘ %s: %v\n", run.file, run.err)
+			failed++
+			continue
+		}
+		elapsed = append(elapsed, run.elapsed)
+		audio += run.audio
+	}
+	fmt.Printf("files:    %d ok, %d failed in %v\n", len(elapsed), failed, wall.Round(time.Millisecond))
+	if len(elapsed) == 0 {
+		return 1
+	}
+
+	sort.Slice(elapsed, func(i, j int) bool { return elapsed[i] < elapsed[j] })
+	var total time.Duration
+	for _, d := range elapsed {
+		total += d
+	}
+	mean := total / time.Duration(len(elapsed))
+	fmt.Printf("audio:    %v transcribed\n", audio.Round(time.Millisecond))
+	fmt.Printf("elapsed:  min %v, mean %v, max %v\n",
+		elapsed[0].Round(time.Millisecond), mean.Round(time.Millisecond), elapsed[len(elapsed)-1].Round(time.Millisecond))
+	if audio > 0 {
+		fmt.Printf("rtf:      %.2f (total elapsed / audio)\n", total.Seconds()/audio.Seconds())
+	}
+
+	events.mutex.Lock()
+	latencies := append([]time.Duration(nil), events.latencies...)
+	missed, lost, failedItems := events.missed, events.lost, events.failed
+	events.mutex.Unlock()
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Printf("latency:  p50 %v, p90 %v, p99 %v, max %v (speech_stopped → result, %d utterances)\n",
+			percentile(latencies, 50), percentile(latencies, 90), percentile(latencies, 99),
+			latencies[len(latencies)-1].Round(time.Millisecond), len(latencies))
+	}
+	fmt.Printf("events:   %d missed, %d results lost, %d transcriptions failed\n", missed, lost, failedItems)
+
+	if stats != nil {
+		reportServerStats(stats)
+	}
+
+	if failed > 0 || lost > 0 {
+		return 1
+	}
+	return 0
+}
+
+// reportServerStats prints the CPU and memory of the server over the run
+func reportServerStats(stats *serverStats) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	if stats.err != nil {
+		fmt.Fprintf(os.Stderr, "⚠ server stats: %v\n", stats.err)
+	}
+	if stats.first == nil || stats.last == nil {
+		return
+	}
+	if seconds := stats.last.Timestamp.Sub(stats.first.Timestamp).Seconds(); seconds > 0 {
+		cpu := (stats.last.CPUSeconds - stats.first.CPUSeconds) / seconds * 100
+		fmt.Printf("server:   cpu %.0f%% of a core (%d cores)\n", cpu, stats.last.NumCPU)
+	}
+	fmt.Printf("          rss peak %s, go runtime peak %s, goroutines peak %d\n",
+		formatBytes(stats.peakRSS), formatBytes(stats.peakSys), stats.peakGo)
+}
+
+// percentile returns the nearest-rank percentile p of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(float64(len(sorted))*p/100)) - 1
+	rank = min(max(rank, 0), len(sorted)-1)
+	return sorted[rank].Round(time.Millisecond)
+}
+
+// formatBytes formats a byte count in MiB
+func formatBytes(n uint64) string {
+	if n == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+}
@@ -9,13 +9,171 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// ModelPack describes a local model file managed by `stt models`
+type ModelPack struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	URL     string `yaml:"url"`
+	SHA256  string `yaml:"sha256"`
+	File    string `yaml:"file"` // relative to Models.Dir
+}
+
+// TierLimits are the usage limits of an API key tier, 0 means unlimited
+type TierLimits struct {
+	MaxSessionSeconds int     `yaml:"max_session_seconds"` // wall-clock duration of a single session
+	MaxAudioMinutes   float64 `yaml:"max_audio_minutes"`   // audio per API key within the period
+	PeriodHours       int     `yaml:"period_hours"`        // audio quota window, defaults to 24
+}
+
+// APIKey is a client key accepted by the WebSocket server
+type APIKey struct {
+	Name        string `yaml:"name"` // shown in logs instead of the key, defaults to the tenant
+	Key         string `yaml:"key"`
+	Tenant      string `yaml:"tenant"`
+	MaxSessions int    `yaml:"max_sessions"` // concurrent sessions of the key, 0 means unlimited
+	// What the key may do: "transcribe", "observe", "export" and "admin", which
+	// grants all of them. Empty means transcribe only.
+	Scopes []string `yaml:"scopes"`
+}
+
+// PayloadEncryption encrypts the transcripts sent to a destination as JWE for the
+// recipient's X25519 public key, see pkg/jwe. Empty RecipientKey sends plain JSON.
+type PayloadEncryption struct {
+	RecipientKey string `yaml:"recipient_key"` // base64url X25519 public key, from "stt keygen"
+	KeyID        string `yaml:"key_id"`        // kid header telling the recipient which key to use
+}
+
+// ASRConcurrencyLimit bounds the concurrent recognition calls to a provider. Calls
+// beyond MaxConcurrent wait in a queue of at most MaxQueued calls, 0 meaning unbounded;
+// a call finding the queue full or waiting longer than QueueTimeoutSeconds fails.
+type ASRConcurrencyLimit struct {
+	// "local" for the in-process model, "default" for asr.base_url and its replicas,
+	// or the asr.base_url of a pipeline
+	Provider            string `yaml:"provider"`
+	Model               string `yaml:"model"` // empty applies to every model of the provider
+	MaxConcurrent       int    `yaml:"max_concurrent"`
+	MaxQueued           int    `yaml:"max_queued"`
+	QueueTimeoutSeconds int    `yaml:"queue_timeout_seconds"` // 0 waits as long as the call may
+}
+
+// Pipeline is a named set of overrides for an endpoint persona, e.g. "broadcast" or
+// "callcenter". Zero values keep the global setting.
+type Pipeline struct {
+	Vad struct {
+		Threshold           float32 `yaml:"threshold"`
+		MinSilenceDuration  float32 `yaml:"min_silence_duration"`
+		MinSpeechDuration   float32 `yaml:"min_speech_duration"`
+		MaxSpeechDuration   float32 `yaml:"max_speech_duration"`
+		MaxUtteranceSeconds float32 `yaml:"max_utterance_seconds"`
+	} `yaml:"vad"`
+
+	Denoiser struct {
+		Enable *bool `yaml:"enable"`
+	} `yaml:"denoiser"`
+
+	ASR struct {
+		BaseURL string `yaml:"base_url"`
+		APIKey  string `yaml:"api_key"`
+		Model   string `yaml:"model"`
+	} `yaml:"asr"`
+
+	PostProcessing struct {
+		Profile            string `yaml:"profile"` // transcript format profile
+		MaxLineLength      int    `yaml:"max_line_length"`
+		MaxLines           int    `yaml:"max_lines"`
+		ParagraphSentences int    `yaml:"paragraph_sentences"`
+		Captions           bool   `yaml:"captions"`
+	} `yaml:"post_processing"`
+}
+
+// ExperimentVariant is an arm of the experiment running the named pipeline on Weight
+// percent of the sessions. The control arm leaves Pipeline empty.
+type ExperimentVariant struct {
+	Name     string  `yaml:"name"`
+	Weight   float64 `yaml:"weight"`
+	Pipeline string  `yaml:"pipeline"`
+}
+
+// IngestStream is a live stream the server pulls and transcribes
+type IngestStream struct {
+	Name     string `yaml:"name"`     // names the stream in logs and GET /v1/admin/ingest
+	URL      string `yaml:"url"`      // rtmp://, rtsp:// or any other input ffmpeg reads
+	Language string `yaml:"language"` // transcription language, empty or "auto" detects it
+	Model    string `yaml:"model"`    // transcription model, defaults to asr.model
+	Pipeline string `yaml:"pipeline"` // named pipeline the stream's session runs, optional
+}
+
 type Config struct {
 	ServicePort string `yaml:"service_port"`
 
 	ASR struct {
+		// "http" (default) sends utterances to the engine at base_url; "local" recognizes
+		// them in-process with a sherpa-onnx offline model, no external engine needed
+		Mode    string `yaml:"mode"`
 		BaseURL string `yaml:"base_url"`
 		APIKey  string `yaml:"api_key"`
 		Model   string `yaml:"model"`
+		// Offline model of the local mode
+		Local struct {
+			ModelType  string `yaml:"model_type"` // "sense_voice" (default), "whisper", "paraformer" or "transducer"
+			Model      string `yaml:"model"`      // sense_voice and paraformer model file
+			Encoder    string `yaml:"encoder"`    // whisper and transducer
+			Decoder    string `yaml:"decoder"`    // whisper and transducer
+			Joiner     string `yaml:"joiner"`     // transducer
+			Tokens     string `yaml:"tokens"`
+			Language   string `yaml:"language"` // sense_voice and whisper, empty detects it
+			NumThreads int    `yaml:"num_threads"`
+			Provider   string `yaml:"provider"`  // "cpu" (default), "cuda" or "coreml"
+			Instances  int    `yaml:"instances"` // models loaded for concurrent utterances, 0 means 1
+		} `yaml:"local"`
+		// Headers copied from the WebSocket upgrade request to every ASR backend call of
+		// that session, e.g. "Authorization" to forward a per-user OAuth token
+		ForwardHeaders []string `yaml:"forward_headers"`
+		// Request verbose_json word timestamps to keep per-word confidence for transcript
+		// export; the backend must support the verbose response format
+		WordConfidence bool `yaml:"word_confidence"`
+		// Custom vocabulary of sessions, input_audio_transcription.hints of session.update
+		Hints struct {
+			Field         string `yaml:"field"`           // form field of the request: "prompt" (default) or "hotwords"
+			MaxHints      int    `yaml:"max_hints"`       // per session, 0 means 100
+			MaxHintLength int    `yaml:"max_hint_length"` // characters per hint, 0 means 64
+		} `yaml:"hints"`
+		// Silence added around every utterance before it is sent, for engines that
+		// clip the first or last phonemes without it
+		PadLeadingMs  int `yaml:"pad_leading_ms"`
+		PadTrailingMs int `yaml:"pad_trailing_ms"`
+		// Further replicas of the engine sharing api_key and model. With more than one
+		// backend, calls are balanced over the healthy ones and fail over on 5xx
+		// responses, timeouts and unreachable backends.
+		BaseURLs  []string `yaml:"base_urls"`
+		Balancing struct {
+			Strategy                   string `yaml:"strategy"`                      // "round_robin" (default) or "least_latency"
+			MaxAttempts                int    `yaml:"max_attempts"`                  // backends tried per call, 0 tries each once
+			TimeoutSeconds             int    `yaml:"timeout_seconds"`               // per attempt, 0 waits indefinitely
+			CooldownSeconds            int    `yaml:"cooldown_seconds"`              // a failed backend is skipped this long, 0 means 30
+			HealthCheckIntervalSeconds int    `yaml:"health_check_interval_seconds"` // 0 only takes backends down on failed calls
+		} `yaml:"balancing"`
+		// Stream the speech of an utterance to the engine while it is spoken instead of
+		// sending one WAV per commit; partial hypotheses become transcription deltas
+		Streaming struct {
+			Enable         bool   `yaml:"enable"`
+			URL            string `yaml:"url"`             // ws:// or wss:// endpoint of the engine
+			TimeoutSeconds int    `yaml:"timeout_seconds"` // wait for the final transcript after commit, 0 means 10
+		} `yaml:"streaming"`
+		// Concurrency limits per provider and model, protecting small engines while calls
+		// to others proceed; the first matching limit applies
+		Concurrency []ASRConcurrencyLimit `yaml:"concurrency"`
+		// Split long committed utterances on VAD segment boundaries into chunks that are
+		// recognized in parallel, their transcripts joined in order
+		Chunking struct {
+			Enable           bool    `yaml:"enable"`
+			ThresholdSeconds float64 `yaml:"threshold_seconds"` // longer utterances are split, 0 means 30
+			ChunkSeconds     float64 `yaml:"chunk_seconds"`     // target length of a chunk, 0 means 10
+			MaxParallel      int     `yaml:"max_parallel"`      // chunks of an item in flight, 0 means 4
+			// Send the transcript of each chunk as a transcription delta once all chunks
+			// before it are done
+			Deltas bool `yaml:"deltas"`
+		} `yaml:"chunking"`
 	} `yaml:"asr"`
 
 	LLM struct {
@@ -24,6 +182,12 @@ type Config struct {
 		Model   string `yaml:"model"`
 	} `yaml:"llm"`
 
+	// Limits on conversation state kept in memory per session, 0 means unlimited
+	Conversation struct {
+		MaxItems           int `yaml:"max_items"`
+		MaxTranscriptChars int `yaml:"max_transcript_chars"`
+	} `yaml:"conversation"`
+
 	// Just for testing purposes
 	Audio struct {
 		Enable     bool    `yaml:"enable"`
@@ -33,10 +197,22 @@ type Config struct {
 		Channels   int    `yaml:"channels"`
 		BitDepth   int    `yaml:"bit_depth"`
 		BufferSize int    `yaml:"buffer_size"`
+		// Quality of the sinc resampler for input rates other than 16kHz and 48kHz:
+		// low, medium or high
+		ResampleQuality string `yaml:"resample_quality"`
+		// Removal of saved audio, limits of 0 are off. With none set, the keep_files
+		// newest segments are kept as before.
+		Retention struct {
+			IntervalSeconds int     `yaml:"interval_seconds"` // 0 means 300
+			MaxAgeHours     float64 `yaml:"max_age_hours"`
+			MaxTotalMB      float64 `yaml:"max_total_mb"`   // all recordings
+			MaxSessionMB    float64 `yaml:"max_session_mb"` // each recording, also enforced as segments are saved
+		} `yaml:"retention"`
 	} `yaml:"audio"`
 
 	Vad struct {
 		Enable               bool    `yaml:"enable"`
+		Engine               string  `yaml:"engine"` // "silero" (default) or "energy", which needs no model
 		Model                string  `yaml:"model"`
 		Threshold            float32 `yaml:"threshold"`
 		MinSilenceDuration   float32 `yaml:"min_silence_duration"`
@@ -49,22 +225,308 @@ type Config struct {
 		Debug                int     `yaml:"debug"`
 		BypassForTesting     bool    `yaml:"bypass_for_testing"`
 	ForceASRAfterSeconds  int    `yaml:"force_asr_after_seconds"`
+		PrefixPaddingMs      int     `yaml:"prefix_padding_ms"` // audio before the detected speech prepended to each segment
+		// Speech buffered for one item beyond this is committed without waiting for a
+		// pause, 0 never forces a commit
+		MaxUtteranceSeconds  float32 `yaml:"max_utterance_seconds"`
 	} `yaml:"vad"`
 
 	Denoiser struct {
 		Enable                bool   `yaml:"enable"`
+		Engine                string `yaml:"engine"` // "gtcrn" (default) or "band", which needs no model
 		Model                 string `yaml:"model"`
 		SampleRate            int    `yaml:"sample_rate"`
 		NumThreads            int    `yaml:"num_threads"`
 		Debug                 int    `yaml:"debug"`
 		BypassForTesting      bool   `yaml:"bypass_for_testing"`
 		MaxProcessingTimeMs   int    `yaml:"max_processing_time_ms"`
+		Strength              float64 `yaml:"strength"` // 0-1 blend of denoised into original audio, default 1
 	} `yaml:"denoiser"`
 
+	Models struct {
+		Dir   string      `yaml:"dir"`
+		Packs []ModelPack `yaml:"packs"`
+	} `yaml:"models"`
+
+	// Active-passive failover: the instance holding the leader lock serves the service
+	// port, the standby waits and takes over when the lease expires
+	Cluster struct {
+		Enable          bool   `yaml:"enable"`
+		InstanceID      string `yaml:"instance_id"` // defaults to hostname-pid
+		RedisAddr       string `yaml:"redis_addr"`
+		RedisPassword   string `yaml:"redis_password"`
+		RedisDB         int    `yaml:"redis_db"`
+		KeyPrefix       string `yaml:"key_prefix"`
+		LeaseTTLSeconds int    `yaml:"lease_ttl_seconds"`
+		HealthPort      string `yaml:"health_port"` // always listening, reports the role to load balancers
+	} `yaml:"cluster"`
+
+	// Persistence of session configuration, conversation and heartbeat so a client whose
+	// connection dropped resumes its session, also after a restart or on another
+	// instance behind a load balancer with the redis store
+	SessionStore struct {
+		Type                string `yaml:"type"` // "" keeps sessions in process only, "memory" or "redis"
+		RedisAddr           string `yaml:"redis_addr"`
+		RedisPassword       string `yaml:"redis_password"`
+		RedisDB             int    `yaml:"redis_db"`
+		KeyPrefix           string `yaml:"key_prefix"`            // defaults to "stt"
+		TTLSeconds          int    `yaml:"ttl_seconds"`           // sessions no instance serves can be resumed this long, 0 means 300
+		SyncIntervalSeconds int    `yaml:"sync_interval_seconds"` // 0 means 2
+	} `yaml:"session_store"`
+
+	// Persistence of every completed and failed conversation item, so transcripts are
+	// kept after their session ended. Rows older than retention_days are deleted.
+	TranscriptStore struct {
+		Type                   string `yaml:"type"`                     // "" keeps transcripts in memory only, "sqlite" or "postgres"
+		DSN                    string `yaml:"dsn"`                      // file path for sqlite, connection string for postgres
+		RetentionDays          int    `yaml:"retention_days"`           // 0 keeps transcripts forever
+		CleanupIntervalMinutes int    `yaml:"cleanup_interval_minutes"` // 0 means 60
+		QueueSize              int    `yaml:"queue_size"`               // items waiting to be written, 0 means 1000
+	} `yaml:"transcript_store"`
+
+	// Publication of transcription and session lifecycle events to Kafka or NATS, one
+	// topic per event type named topic_prefix + type unless topics names it
+	EventSink struct {
+		Type        string            `yaml:"type"`         // "" publishes nothing, "kafka" or "nats"
+		Brokers     []string          `yaml:"brokers"`      // host:port of the Kafka brokers or NATS servers
+		ClientID    string            `yaml:"client_id"`    // defaults to "stt"
+		TopicPrefix string            `yaml:"topic_prefix"` // defaults to "stt."
+		Topics      map[string]string `yaml:"topics"`       // topic of an event type, overriding the prefix
+		// Event types published; empty publishes the session lifecycle and the final
+		// transcription results, "*" every server event
+		Events    []string `yaml:"events"`
+		Acks      int      `yaml:"acks"`       // Kafka: 1 (default) or -1 for all in-sync replicas
+		Username  string   `yaml:"username"`   // NATS
+		Password  string   `yaml:"password"`   // NATS
+		Token     string   `yaml:"token"`      // NATS
+		QueueSize int      `yaml:"queue_size"` // events waiting to be published, 0 means 1000
+		// Seals every event as JWE except session.created, session.updated and session.ended
+		Encryption PayloadEncryption `yaml:"encryption"`
+	} `yaml:"event_sink"`
+
+	// Transcribe the speech left in the VAD buffer when a client disconnects without
+	// committing it. The socket is gone, so results go to a webhook and/or a directory.
+	FinalFlush struct {
+		Enable         bool   `yaml:"enable"`
+		WebhookURL        string            `yaml:"webhook_url"` // receives the result as a JSON POST
+		SaveDir           string            `yaml:"save_dir"`    // writes final_<session>.json
+		TimeoutSeconds    int               `yaml:"timeout_seconds"`
+		WebhookEncryption PayloadEncryption `yaml:"webhook_encryption"` // POSTs application/jose instead
+		SaveEncryption    PayloadEncryption `yaml:"save_encryption"`    // writes final_<session>.jwe instead
+	} `yaml:"final_flush"`
+
+	// Where saved audio and final flush transcripts are written: "local" (default) to
+	// audio.save_dir and final_flush.save_dir, "s3" to a bucket of S3-compatible storage
+	Storage struct {
+		Type string `yaml:"type"`
+		S3   struct {
+			Endpoint        string `yaml:"endpoint"` // e.g. https://s3.us-east-1.amazonaws.com or http://minio:9000
+			Region          string `yaml:"region"`
+			Bucket          string `yaml:"bucket"`
+			// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN when empty
+			AccessKeyID     string `yaml:"access_key_id"`
+			SecretAccessKey string `yaml:"secret_access_key"`
+			SessionToken    string `yaml:"session_token"`
+			PathStyle       bool   `yaml:"path_style"` // bucket in the path, as MinIO usually needs
+			// Key prefixes with {session_id} and {date}, the UTC day the session started
+			AudioPrefix      string `yaml:"audio_prefix"`      // must contain {session_id}, "audio/{session_id}/" when empty
+			TranscriptPrefix string `yaml:"transcript_prefix"` // final flush transcripts are saved only when set
+		} `yaml:"s3"`
+	} `yaml:"storage"`
+
+	// Usage limits per API key tier. Sessions are warned as a limit approaches and
+	// terminated with session.limit_exceeded once it is reached.
+	Limits struct {
+		Enable      bool                  `yaml:"enable"`
+		DefaultTier string                `yaml:"default_tier"` // tier of unknown or missing API keys
+		WarnRatio   float64               `yaml:"warn_ratio"`   // fraction of a limit that triggers a warning
+		Tiers       map[string]TierLimits `yaml:"tiers"`
+		APIKeys     map[string]string     `yaml:"api_keys"` // API key -> tier
+	} `yaml:"limits"`
+
+	// Per-client rate limits, a client being its API key or, without one, its IP address.
+	// A session over a limit is refused, and events or audio over one are dropped, with a
+	// rate_limit_exceeded error event. 0 means unlimited.
+	RateLimits struct {
+		Enable                bool    `yaml:"enable"`
+		MaxSessionsPerKey     int     `yaml:"max_sessions_per_key"`
+		MaxSessionsPerIP      int     `yaml:"max_sessions_per_ip"`
+		AudioSecondsPerMinute float64 `yaml:"audio_seconds_per_minute"` // input audio of all the client's sessions
+		EventsPerSecond       float64 `yaml:"events_per_second"`        // client events of a session
+		EventsBurst           int     `yaml:"events_burst"`             // defaults to twice events_per_second
+	} `yaml:"rate_limits"`
+
+	// Authentication of WebSocket clients by API key or HS256 JWT, read from the
+	// Authorization bearer token or the token query parameter of the upgrade request
+	Auth struct {
+		Enable  bool     `yaml:"enable"`
+		APIKeys []APIKey `yaml:"api_keys"`
+		JWT     struct {
+			Secret      string `yaml:"secret"` // HS256 shared secret, empty disables JWT
+			Issuer      string `yaml:"issuer"`
+			Audience    string `yaml:"audience"`
+			TenantClaim string `yaml:"tenant_claim"` // defaults to "tenant"
+			// Concurrent sessions per tenant, overridden by a max_sessions claim; 0 means unlimited
+			MaxSessions int `yaml:"max_sessions"`
+		} `yaml:"jwt"`
+	} `yaml:"auth"`
+
+	// Defaults of every new session, so fleet-wide behavior is changed here rather than
+	// in every client. Fields a session.update sets take precedence; empty ones keep
+	// the default.
+	SessionDefaults struct {
+		InputAudioTranscription struct {
+			Model    string `yaml:"model"`
+			Language string `yaml:"language"`
+		} `yaml:"input_audio_transcription"`
+		TurnDetection struct {
+			Type              string  `yaml:"type"`
+			Threshold         float32 `yaml:"threshold"`
+			PrefixPaddingMs   int     `yaml:"prefix_padding_ms"`
+			SilenceDurationMs int     `yaml:"silence_duration_ms"`
+		} `yaml:"turn_detection"`
+		NoiseSuppression *bool `yaml:"noise_suppression"` // unset follows denoiser.enable
+	} `yaml:"session_defaults"`
+
+	// Spectral noise gate run before the VAD, for deployments without the ONNX denoiser
+	NoiseGate struct {
+		Enable      bool    `yaml:"enable"`
+		ThresholdDB float64 `yaml:"threshold_db"` // band level above the noise floor that opens the gate
+		ReductionDB float64 `yaml:"reduction_db"` // attenuation of gated bands
+		AttackMs    float64 `yaml:"attack_ms"`
+		ReleaseMs   float64 `yaml:"release_ms"`
+	} `yaml:"noise_gate"`
+
+	// Automatic gain control run before the VAD, so quiet far-field microphones reach
+	// the level the VAD expects. Sessions override it with input_audio_gain_control.
+	AGC struct {
+		Enable         bool    `yaml:"enable"`
+		TargetDBFS     float64 `yaml:"target_dbfs"`      // RMS level speech is brought to
+		MaxGainDB      float64 `yaml:"max_gain_db"`      // largest amplification
+		AttackMs       float64 `yaml:"attack_ms"`        // time for the gain to fall
+		ReleaseMs      float64 `yaml:"release_ms"`       // time for the gain to rise
+		NoiseFloorDBFS float64 `yaml:"noise_floor_dbfs"` // quieter input holds the gain
+		PeakDBFS       float64 `yaml:"peak_dbfs"`        // limit of the amplified peaks
+	} `yaml:"agc"`
+
+	// Periodic removal of sessions inactive for longer than the session timeout
+	SessionGC struct {
+		IntervalSeconds int `yaml:"interval_seconds"` // 0 only sweeps on shutdown and on demand
+	} `yaml:"session_gc"`
+
+	// OpenTelemetry tracing of the recognition pipeline, one trace per conversation item
+	Tracing struct {
+		Enable         bool              `yaml:"enable"`
+		ServiceName    string            `yaml:"service_name"`
+		OTLPEndpoint   string            `yaml:"otlp_endpoint"` // OTLP/HTTP collector, spans are POSTed as JSON to /v1/traces
+		Headers        map[string]string `yaml:"headers"`       // sent with every export, e.g. collector credentials
+		SampleRatio    float64           `yaml:"sample_ratio"`  // fraction of items traced, 0 traces all
+		TimeoutSeconds int               `yaml:"timeout_seconds"`
+	} `yaml:"tracing"`
+
+	// Reuse of loaded VAD and denoiser models between sessions
+	ModelPool struct {
+		MaxIdle int `yaml:"max_idle"` // idle instances kept per model configuration, 0 keeps all
+	} `yaml:"model_pool"`
+
+	// Per-session byte accounting. With ReportIntervalSeconds set, sessions receive a
+	// session.bandwidth event at that interval; 0 only reports it in analytics and stats.
+	Bandwidth struct {
+		ReportIntervalSeconds int `yaml:"report_interval_seconds"`
+	} `yaml:"bandwidth"`
+
+	// Per-session recognition counters. With ReportIntervalSeconds set, sessions receive
+	// a session.stats event at that interval; clients can change it with
+	// session.update stats_report_seconds or ask with session.stats.request.
+	SessionStats struct {
+		ReportIntervalSeconds int `yaml:"report_interval_seconds"`
+	} `yaml:"session_stats"`
+
+	// Named pipelines selected per session with session.update {"pipeline": "<name>"}
+	Pipelines map[string]Pipeline `yaml:"pipelines"`
+
+	// A/B experiment assigning sessions to pipeline variants. The first variant is the
+	// control the others are compared to; sessions beyond the summed weights stay out.
+	Experiment struct {
+		Enable   bool                `yaml:"enable"`
+		Name     string              `yaml:"name"` // changing it reshuffles the assignment
+		Variants []ExperimentVariant `yaml:"variants"`
+	} `yaml:"experiment"`
+
+	// HTTP long-poll transport for clients whose network blocks WebSockets. Sessions are
+	// created with POST /v1/realtime/sessions, audio and client events are POSTed and
+	// server events polled from GET /v1/realtime/<session>/events.
+	LongPoll struct {
+		Enable             bool `yaml:"enable"`
+		MaxWaitSeconds     int  `yaml:"max_wait_seconds"`     // longest a poll is held open, defaults to 30
+		IdleTimeoutSeconds int  `yaml:"idle_timeout_seconds"` // sessions not polled for this long are closed, defaults to 60
+		MaxBufferedEvents  int  `yaml:"max_buffered_events"`  // undelivered events kept per session, defaults to 1000
+	} `yaml:"long_poll"`
+
+	// Sessions whose WebSocket dropped without a close stay alive for a grace period,
+	// buffering their events, so a client reconnecting with session.resume receives
+	// the events it missed
+	Resume struct {
+		Enable       bool `yaml:"enable"`
+		GraceSeconds int  `yaml:"grace_seconds"` // how long a dropped session waits for its client, defaults to 30
+		ReplayBuffer int  `yaml:"replay_buffer"` // server events kept per session for replay, defaults to 256
+	} `yaml:"resume"`
+
+	// Server events waiting for a WebSocket client that reads slower than they are
+	// produced. A client falling queue_size events behind loses the oldest ones or its
+	// session, and receives a buffer_overflow error.
+	Outbound struct {
+		QueueSize int    `yaml:"queue_size"` // events per session, defaults to 256
+		Policy    string `yaml:"policy"`     // "drop_oldest" (default) or "close_session"
+	} `yaml:"outbound"`
+
+	// Files written for every session as its transcripts complete
+	Output struct {
+		// Caption files named after the session, e.g. sidecar captions of a restream
+		Captions struct {
+			Dir     string   `yaml:"dir"`     // no files are written when empty
+			Formats []string `yaml:"formats"` // "srt", "vtt" and "jsonl", defaults to vtt
+		} `yaml:"captions"`
+
+		// WebVTT segments of live captions for an HLS subtitle rendition
+		HLS struct {
+			SegmentSeconds  float64 `yaml:"segment_seconds"`   // 0 means 6, match the restream's segments
+			MPEGTS          int64   `yaml:"mpegts"`            // 90kHz timestamp of the session's audio start in the restream
+			WindowSegments  int     `yaml:"window_segments"`   // segments in the playlist, 0 lists all
+			MaxDelaySeconds float64 `yaml:"max_delay_seconds"` // longest a segment waits for its transcripts, 0 means 30
+		} `yaml:"hls"`
+	} `yaml:"output"`
+
+	// Live streams pulled with ffmpeg and transcribed in sessions of their own, whose
+	// results reach the event sink, the transcript history and the caption files
+	Ingest struct {
+		FFmpegPath        string         `yaml:"ffmpeg_path"`         // looked up in PATH when empty
+		RetryDelaySeconds int            `yaml:"retry_delay_seconds"` // before reconnecting a dropped stream, 0 means 5
+		Streams           []IngestStream `yaml:"streams"`
+	} `yaml:"ingest"`
+
+	// SIP user agent a PBX forks the audio of calls to; every RTP stream of a call is
+	// transcribed in a session of its own, like an ingested stream
+	SIP struct {
+		Enable            bool     `yaml:"enable"`
+		Listen            string   `yaml:"listen"`              // UDP address of SIP, ":5060" when empty
+		PublicIP          string   `yaml:"public_ip"`           // address of SDP answers, the interface reaching the PBX when empty
+		RTPPortMin        int      `yaml:"rtp_port_min"`        // 0 means 20000
+		RTPPortMax        int      `yaml:"rtp_port_max"`        // 0 means 20999
+		RTPTimeoutSeconds int      `yaml:"rtp_timeout_seconds"` // a call without RTP for this long ends, 0 means 30
+		AllowedSources    []string `yaml:"allowed_sources"`     // IPs or CIDRs of the PBXs, empty accepts any
+		Language          string   `yaml:"language"`
+		Model             string   `yaml:"model"`
+		Pipeline          string   `yaml:"pipeline"`
+	} `yaml:"sip"`
+
 	Logging struct {
 		Level  string `yaml:"level"`
 		File   string `yaml:"file"`
 		Format string `yaml:"format"`
+		// Per-component levels overriding Level, keyed by the "component" log field.
+		// Reloaded from the config file on SIGHUP.
+		Components map[string]string `yaml:"components"`
 	} `yaml:"logging"`
 }
 
@@ -123,4 +585,68 @@ func LoadConfig(path string) (*Config, error) {
 	}
 
 	return &cfg, nil
-}
\ No newline at end of file
+}
+// WithPipeline returns a copy of the configuration with the VAD, denoiser and ASR
+// overrides of a pipeline applied
+func (c *Config) WithPipeline(p Pipeline) *Config {
+	cfg := *c
+	if p.Vad.Threshold > 0 {
+		cfg.Vad.Threshold = p.Vad.Threshold
+	}
+	if p.Vad.MinSilenceDuration > 0 {
+		cfg.Vad.MinSilenceDuration = p.Vad.MinSilenceDuration
+	}
+	if p.Vad.MinSpeechDuration > 0 {
+		cfg.Vad.MinSpeechDuration = p.Vad.MinSpeechDuration
+	}
+	if p.Vad.MaxSpeechDuration > 0 {
+		cfg.Vad.MaxSpeechDuration = p.Vad.MaxSpeechDuration
+	}
+	if p.Vad.MaxUtteranceSeconds > 0 {
+		cfg.Vad.MaxUtteranceSeconds = p.Vad.MaxUtteranceSeconds
+	}
+	if p.Denoiser.Enable != nil {
+		cfg.Denoiser.Enable = *p.Denoiser.Enable
+	}
+	if p.ASR.BaseURL != "" {
+		cfg.ASR.BaseURL = p.ASR.BaseURL
+	}
+	if p.ASR.APIKey != "" {
+		cfg.ASR.APIKey = p.ASR.APIKey
+	}
+	if p.ASR.Model != "" {
+		cfg.ASR.Model = p.ASR.Model
+	}
+	return &cfg
+}
+
+// WithTurnDetection returns a copy of the configuration with the turn detection
+// settings of a session applied to the VAD, zero values keep the configured ones
+func (c *Config) WithTurnDetection(threshold float32, silenceDurationMs, prefixPaddingMs int) *Config {
+	cfg := *c
+	if threshold > 0 {
+		cfg.Vad.Threshold = threshold
+	}
+	if silenceDurationMs > 0 {
+		cfg.Vad.MinSilenceDuration = float32(silenceDurationMs) / 1000
+	}
+	if prefixPaddingMs > 0 {
+		cfg.Vad.PrefixPaddingMs = prefixPaddingMs
+	}
+	return &cfg
+}
+
+// ASRBackends returns the base URLs of the ASR engine replicas, base_url first
+func (c *Config) ASRBackends() []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, url := range append([]string{c.ASR.BaseURL}, c.ASR.BaseURLs...) {
+		url = strings.TrimRight(url, "/")
+		if url == "" || seen[url] {
+			continue
+		}
+		seen[url] = true
+		urls = append(urls, url)
+	}
+	return urls
+}
@@ -7,8 +7,9 @@ import (
 	"github.com/go-restream/stt/pkg/logger"
 
 	yaml "github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/denoise"
+	"github.com/go-restream/stt/vad"
 
-	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
 	"github.com/sirupsen/logrus"
 )
 
@@ -16,8 +17,15 @@ var (
 	default_sample_rate = 16000
 )
 
+// Engines selectable with denoiser.engine
+const (
+	EngineGTCRN = "gtcrn" // the GTCRN model run by sherpa-onnx, the default
+	EngineBand  = "band"  // the pure-Go band denoiser of pkg/denoise, needing no model
+)
+
 type DenoiserProcessor struct {
-	denoiser             *sherpa.OfflineSpeechDenoiser
+	denoiser             denoise.Denoiser
+	engine              string
 	sampleRate          int
 	config              *yaml.Config
 	mutex               sync.RWMutex
@@ -44,51 +52,80 @@ func NewDenoiserProcessor(cfg *yaml.Config) *DenoiserProcessor {
 		}
 	}
 
-	denoiserCfg := initDenoiserConfig(cfg)
-	denoiser := sherpa.NewOfflineSpeechDenoiser(denoiserCfg)
-	if denoiser == nil {
-		logger.WithFields(logrus.Fields{
-			"component": "eng_denoiser_audio_sys",
-			"action":    "initialization_failed",
-			"model":     cfg.Denoiser.Model,
-		}).Error("Failed to initialize denoiser processor - operating in bypass mode")
-
-		// Return processor without denoiser (will operate in bypass mode)
-		return &DenoiserProcessor{
-			config:     cfg,
-			sampleRate: cfg.Denoiser.SampleRate,
-			stats: DenoiserStats{
-				TotalSegmentsProcessed: 0,
-				TotalProcessingTime:   0,
-				FailedProcessings:     0,
-				AverageLatency:        0,
-			},
-		}
+	sampleRate := cfg.Denoiser.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = default_sample_rate
 	}
+	denoiser, engine := newEngine(cfg, sampleRate)
 
 	logger.WithFields(logrus.Fields{
 		"component": "eng_denoiser_audio_sys",
 		"action":    "initialization_success",
+		"engine":    engine,
 		"model":     cfg.Denoiser.Model,
-		"sampleRate": cfg.Denoiser.SampleRate,
+		"sampleRate": sampleRate,
 	}).Info("Denoiser processor initialized successfully")
 
 	return &DenoiserProcessor{
 		denoiser:   denoiser,
-		sampleRate: cfg.Denoiser.SampleRate,
+		engine:     engine,
+		sampleRate: sampleRate,
 		config:     cfg,
-		stats: DenoiserStats{
-			TotalSegmentsProcessed: 0,
-			TotalProcessingTime:   0,
-			FailedProcessings:     0,
-			AverageLatency:        0,
-		},
 	}
 }
 
+// newEngine creates the engine of denoiser.engine. Without GTCRN compiled in, or when
+// its model cannot be loaded, the band engine takes over so segments are still denoised.
+func newEngine(cfg *yaml.Config, sampleRate int) (denoise.Denoiser, string) {
+	switch cfg.Denoiser.Engine {
+	case EngineBand:
+		return denoise.NewBandDenoiser(sampleRate, denoise.BandOptions{}), EngineBand
+	case "", EngineGTCRN:
+	default:
+		logger.WithFields(logrus.Fields{
+			"component": "eng_denoiser_audio_sys",
+			"action":    "unknown_engine",
+			"engine":    cfg.Denoiser.Engine,
+		}).Warn("Unknown denoiser engine, using gtcrn")
+	}
+
+	if !Available {
+		logger.WithFields(logrus.Fields{
+			"component": "eng_denoiser_audio_sys",
+			"action":    "denoiser_not_compiled",
+			"engine":    EngineBand,
+		}).Warn("GTCRN denoiser not compiled in (nodenoiser build), using the band engine")
+		return denoise.NewBandDenoiser(sampleRate, denoise.BandOptions{}), EngineBand
+	}
+	if engine := newGTCRNEngine(cfg, sampleRate); engine != nil {
+		return engine, EngineGTCRN
+	}
+	logger.WithFields(logrus.Fields{
+		"component": "eng_denoiser_audio_sys",
+		"action":    "initialization_failed",
+		"engine":    EngineGTCRN,
+		"model":     cfg.Denoiser.Model,
+	}).Error("Failed to load the GTCRN denoiser model, using the band engine")
+	return denoise.NewBandDenoiser(sampleRate, denoise.BandOptions{}), EngineBand
+}
+
+// EngineName returns the engine processors created with cfg run, unless the GTCRN
+// model fails to load
+func EngineName(cfg *yaml.Config) string {
+	if cfg.Denoiser.Engine == EngineBand || !Available {
+		return EngineBand
+	}
+	return EngineGTCRN
+}
+
+// Engine returns the name of the engine the processor runs, empty when disabled
+func (d *DenoiserProcessor) Engine() string {
+	return d.engine
+}
+
 func (d *DenoiserProcessor) Close() {
 	if d.denoiser != nil {
-		sherpa.DeleteOfflineSpeechDenoiser(d.denoiser)
+		d.denoiser.Close()
 		logger.WithFields(logrus.Fields{
 			"component": "eng_denoiser_audio_sys",
 			"action":    "cleanup_completed",
@@ -96,7 +133,17 @@ func (d *DenoiserProcessor) Close() {
 	}
 }
 
-func (d *DenoiserProcessor) ProcessSegment(segment *sherpa.SpeechSegment) *sherpa.SpeechSegment {
+// Reset forgets the noise the engine learned, before the processor serves another stream
+func (d *DenoiserProcessor) Reset() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.denoiser != nil {
+		d.denoiser.Reset()
+	}
+}
+
+func (d *DenoiserProcessor) ProcessSegment(segment *vad.SpeechSegment) *vad.SpeechSegment {
 	if segment == nil {
 		logger.WithFields(logrus.Fields{
 			"component": "eng_denoiser_audio_sys",
@@ -136,7 +183,7 @@ func (d *DenoiserProcessor) ProcessSegment(segment *sherpa.SpeechSegment) *sherp
 		"sampleRate": d.sampleRate,
 	}).Debug("Processing audio segment with denoiser")
 
-	enhancedAudio := d.denoiser.Run(segment.Samples, d.sampleRate)
+	enhancedAudio := d.denoiser.Process(segment.Samples)
 
 	processingTime := time.Since(d.processingStartTime)
 	d.updateStats(processingTime, true)
@@ -146,7 +193,7 @@ func (d *DenoiserProcessor) ProcessSegment(segment *sherpa.SpeechSegment) *sherp
 			"component":     "eng_denoiser_audio_sys",
 			"action":        "segment_processed",
 			"originalSamples": len(segment.Samples),
-			"enhancedSamples": len(enhancedAudio.Samples),
+			"enhancedSamples": len(enhancedAudio),
 			"processingTime": processingTime.Milliseconds(),
 			"maxProcessingTime": d.config.Denoiser.MaxProcessingTimeMs,
 		}).Debug("Audio segment enhanced successfully")
@@ -166,8 +213,8 @@ func (d *DenoiserProcessor) ProcessSegment(segment *sherpa.SpeechSegment) *sherp
 		return segment
 	}
 
-	enhancedSegment := sherpa.SpeechSegment{
-		Samples: enhancedAudio.Samples,
+	enhancedSegment := vad.SpeechSegment{
+		Samples: enhancedAudio,
 	}
 
 	return &enhancedSegment
@@ -209,14 +256,3 @@ func (d *DenoiserProcessor) updateStats(processingTime time.Duration, success bo
 		d.stats.AverageLatency = d.stats.TotalProcessingTime / time.Duration(d.stats.TotalSegmentsProcessed)
 	}
 }
-
-func initDenoiserConfig(cfg *yaml.Config) *sherpa.OfflineSpeechDenoiserConfig {
-	config := sherpa.OfflineSpeechDenoiserConfig{}
-
-	config.Model.Gtcrn.Model = cfg.Denoiser.Model
-	config.Model.NumThreads = int32(cfg.Denoiser.NumThreads)
-	config.Model.Debug = int32(cfg.Denoiser.Debug)
-	config.Model.Provider = "cpu"
-
-	return &config
-}
\ No newline at end of file
@@ -5,7 +5,7 @@ import (
 	"time"
 
 	yaml "github.com/go-restream/stt/config"
-	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+	"github.com/go-restream/stt/vad"
 )
 
 func TestNewDenoiserProcessor_Disabled(t *testing.T) {
@@ -18,7 +18,7 @@ func TestNewDenoiserProcessor_Disabled(t *testing.T) {
 	}
 
 	// When disabled, processor should return original segment
-	testSegment := &sherpa.SpeechSegment{
+	testSegment := &vad.SpeechSegment{
 		Samples: []float32{0.1, 0.2, 0.3},
 	}
 
@@ -46,7 +46,7 @@ func TestNewDenoiserProcessor_Enabled(t *testing.T) {
 	}
 
 	// Test that it operates in bypass mode when model fails to load
-	testSegment := &sherpa.SpeechSegment{
+	testSegment := &vad.SpeechSegment{
 		Samples: []float32{0.1, 0.2, 0.3},
 	}
 
@@ -80,7 +80,7 @@ func TestProcessSegment_EmptySegment(t *testing.T) {
 		sampleRate: 16000,
 	}
 
-	emptySegment := &sherpa.SpeechSegment{
+	emptySegment := &vad.SpeechSegment{
 		Samples: []float32{},
 	}
 
@@ -147,31 +147,3 @@ func TestUpdateStats(t *testing.T) {
 		t.Errorf("Expected average latency %v, got %v", expectedAvg, stats.AverageLatency)
 	}
 }
-
-func TestInitDenoiserConfig(t *testing.T) {
-	cfg := &yaml.Config{}
-	cfg.Denoiser.Model = "./test_model.onnx"
-	cfg.Denoiser.NumThreads = 2
-	cfg.Denoiser.Debug = 1
-
-	config := initDenoiserConfig(cfg)
-	if config == nil {
-		t.Fatal("Expected config to be created")
-	}
-
-	if config.Model.Gtcrn.Model != "./test_model.onnx" {
-		t.Errorf("Expected model path './test_model.onnx', got '%s'", config.Model.Gtcrn.Model)
-	}
-
-	if config.Model.NumThreads != 2 {
-		t.Errorf("Expected 2 threads, got %d", config.Model.NumThreads)
-	}
-
-	if config.Model.Debug != 1 {
-		t.Errorf("Expected debug level 1, got %d", config.Model.Debug)
-	}
-
-	if config.Model.Provider != "cpu" {
-		t.Errorf("Expected provider 'cpu', got '%s'", config.Model.Provider)
-	}
-}
\ No newline at end of file
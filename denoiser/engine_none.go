@@ -0,0 +1,14 @@
+//go:build nodenoiser
+
+package denoiser
+
+import (
+	yaml "github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/denoise"
+)
+
+// Available reports whether the GTCRN denoiser is compiled in, false in builds with
+// the nodenoiser tag. Without it every processor runs the band engine.
+const Available = false
+
+func newGTCRNEngine(cfg *yaml.Config, sampleRate int) denoise.Denoiser { return nil }
@@ -0,0 +1,51 @@
+//go:build !nodenoiser
+
+package denoiser
+
+import (
+	yaml "github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/denoise"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// Available reports whether the GTCRN denoiser is compiled in, false in builds with
+// the nodenoiser tag
+const Available = true
+
+// gtcrnEngine runs the GTCRN model with sherpa-onnx. It is stateless, every call is
+// denoised on its own.
+type gtcrnEngine struct {
+	denoiser   *sherpa.OfflineSpeechDenoiser
+	sampleRate int
+}
+
+// newGTCRNEngine loads the model of cfg, nil when it cannot be loaded
+func newGTCRNEngine(cfg *yaml.Config, sampleRate int) denoise.Denoiser {
+	denoiser := sherpa.NewOfflineSpeechDenoiser(initDenoiserConfig(cfg))
+	if denoiser == nil {
+		return nil
+	}
+	return &gtcrnEngine{denoiser: denoiser, sampleRate: sampleRate}
+}
+
+func (e *gtcrnEngine) Process(samples []float32) []float32 {
+	return e.denoiser.Run(samples, e.sampleRate).Samples
+}
+
+func (e *gtcrnEngine) Reset() {}
+
+func (e *gtcrnEngine) Close() {
+	sherpa.DeleteOfflineSpeechDenoiser(e.denoiser)
+}
+
+func initDenoiserConfig(cfg *yaml.Config) *sherpa.OfflineSpeechDenoiserConfig {
+	config := sherpa.OfflineSpeechDenoiserConfig{}
+
+	config.Model.Gtcrn.Model = cfg.Denoiser.Model
+	config.Model.NumThreads = int32(cfg.Denoiser.NumThreads)
+	config.Model.Debug = int32(cfg.Denoiser.Debug)
+	config.Model.Provider = "cpu"
+
+	return &config
+}
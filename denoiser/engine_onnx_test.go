@@ -0,0 +1,37 @@
+//go:build !nodenoiser
+
+package denoiser
+
+import (
+	"testing"
+
+	yaml "github.com/go-restream/stt/config"
+)
+
+func TestInitDenoiserConfig(t *testing.T) {
+	cfg := &yaml.Config{}
+	cfg.Denoiser.Model = "./test_model.onnx"
+	cfg.Denoiser.NumThreads = 2
+	cfg.Denoiser.Debug = 1
+
+	config := initDenoiserConfig(cfg)
+	if config == nil {
+		t.Fatal("Expected config to be created")
+	}
+
+	if config.Model.Gtcrn.Model != "./test_model.onnx" {
+		t.Errorf("Expected model path './test_model.onnx', got '%s'", config.Model.Gtcrn.Model)
+	}
+
+	if config.Model.NumThreads != 2 {
+		t.Errorf("Expected 2 threads, got %d", config.Model.NumThreads)
+	}
+
+	if config.Model.Debug != 1 {
+		t.Errorf("Expected debug level 1, got %d", config.Model.Debug)
+	}
+
+	if config.Model.Provider != "cpu" {
+		t.Errorf("Expected provider 'cpu', got '%s'", config.Model.Provider)
+	}
+}
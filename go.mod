@@ -9,9 +9,12 @@ require (
 	github.com/go-audio/audio v1.0.0
 	github.com/gorilla/websocket v1.5.3
 	github.com/k2-fsa/sherpa-onnx-go v1.12.15
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/sirupsen/logrus v1.9.3
 	github.com/stretchr/testify v1.11.1
 	gopkg.in/yaml.v3 v3.0.1
+	gosdk v0.0.0-00010101000000-000000000000
 )
 
 require (
@@ -26,6 +29,7 @@ require (
 	github.com/go-playground/validator/v10 v10.27.0 // indirect
 	github.com/goccy/go-json v0.10.2 // indirect
 	github.com/goccy/go-yaml v1.18.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/k2-fsa/sherpa-onnx-go-linux v1.12.15 // indirect
 	github.com/k2-fsa/sherpa-onnx-go-macos v1.12.15 // indirect
@@ -54,3 +58,5 @@ require (
 )
 
 replace github.com/streamasr/sdk => ./sdk/golang
+
+replace gosdk => ./sdk/golang
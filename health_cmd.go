@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-restream/stt/config"
+)
+
+const healthUsage = `Usage: stt health [options]
+
+Checks the health endpoint of a running server, or with -asr the ASR engines of the
+configuration. Exits with 0 when healthy, 1 otherwise.
+
+Options:
+`
+
+// runHealthCommand implements "stt health" and returns the exit code
+func runHealthCommand(args []string) int {
+	fs := flag.NewFlagSet("health", flag.ContinueOnError)
+	url := fs.String("url", "http://localhost:8088", "Base URL of the server")
+	checkASR := fs.Bool("asr", false, "Check the ASR engines of the configuration instead of a server")
+	configPath := fs.String("c", "config.yaml", "Path to configuration file, used with -asr")
+	timeout := fs.Duration("timeout", 5*time.Second, "Timeout of the check")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, healthUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if *checkASR {
+		cfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✘ load config failed: %v\n", err)
+			return 1
+		}
+		if cfg.ASR.Mode == "local" {
+			fmt.Println("✔ ASR runs in-process (asr.mode: local), no engine to check")
+			return 0
+		}
+		if err := checkASREngineHealth(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "✘ %v\n", err)
+			return 1
+		}
+		fmt.Println("✔ ASR engine health check passed")
+		return 0
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	endpoint := strings.TrimSuffix(*url, "/") + "/v1/health"
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✘ %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	var health map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		fmt.Fprintf(os.Stderr, "✘ %s returned %s: %v\n", endpoint, resp.Status, err)
+		return 1
+	}
+	if resp.StatusCode != http.StatusOK || health["status"] != "ok" {
+		fmt.Fprintf(os.Stderr, "✘ %s returned %s: %v\n", endpoint, resp.Status, health)
+		return 1
+	}
+	fmt.Printf("✔ %s is healthy: openai_service=%v", *url, health["openai_service"])
+	if role, ok := health["role"]; ok {
+		fmt.Printf(" role=%v", role)
+	}
+	fmt.Println()
+	return 0
+}
@@ -22,45 +22,7 @@ func WsServiceRun(srvPort string, configPath string) {
 
 	openAIService = NewOpenAIService(DefaultOpenAIConfig(), configPath)
 
-	r.Static("/static", "./static")
-	r.GET("/", func(c *gin.Context) {
-		c.File("./static/index.html")
-	})
-	r.GET("/favicon.ico", func(c *gin.Context) {
-		c.File("./static/favicon.ico")
-	})
-
-	
-		r.GET("/v1/realtime", func(c *gin.Context) {
-		openAIService.HandleOpenAIWebSocket(c)
-	})
-
-		r.POST("/v1/chat/completions", handleChatCompletion)
-
-		r.GET("/v1/health", func(c *gin.Context) {
-		health := gin.H{
-			"status":    "ok",
-			"timestamp": time.Now().Unix(),
-			"service":   "streamASR",
-		}
-
-				if openAIService != nil {
-			health["openai_service"] = "available"
-		} else {
-			health["openai_service"] = "unavailable"
-		}
-
-		c.JSON(http.StatusOK, health)
-	})
-
-		r.GET("/v1/sessions/stats", func(c *gin.Context) {
-		if openAIService != nil {
-			stats := openAIService.GetSessionStats()
-			c.JSON(http.StatusOK, stats)
-		} else {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
-		}
-	})
+	registerRoutes(r)
 
 	logger.WithFields(logrus.Fields{
 		"component": "ws_engine_core ",
@@ -74,6 +36,11 @@ func WsServiceRun(srvPort string, configPath string) {
 		"endpoint":  "/v1/realtime",
 	}).Info("✔ OpenAI Realtime API available")
 
+	if cfg := openAIService.appConfig(); cfg != nil && cfg.Cluster.Enable {
+		runClustered(r, srvPort, cfg)
+		return
+	}
+
 	r.Run(":" + srvPort)
 }
 
@@ -131,4 +98,60 @@ func handleChatCompletion(c *gin.Context) {
 	}
 }
 
+// handleSessionDebug toggles scoped debug mode for a single session
+func handleSessionDebug(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
 
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionID := c.Param("id")
+	if err := openAIService.sessionManager.SetSessionDebug(sessionID, req.Enabled); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"session_id": sessionID, "debug": req.Enabled})
+}
+
+// handleSessionJournal returns the event journal recorded while debug mode was on
+func handleSessionJournal(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	sessionID := c.Param("id")
+	journal, err := openAIService.sessionManager.GetEventJournal(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"session_id": sessionID, "events": journal})
+}
+
+// handleSessionAnalytics returns the talk-time analytics of a session
+func handleSessionAnalytics(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	sessionID := c.Param("id")
+	analytics, err := openAIService.sessionManager.GetSessionAnalytics(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"session_id": sessionID, "analytics": analytics})
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/balancer"
+	"github.com/go-restream/stt/pkg/health"
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// asrBackends balances recognition calls over the replicas of the ASR engine and
+// fails over to the next replica when one is down
+type asrBackends struct {
+	pool        *balancer.Pool
+	maxAttempts int
+	timeout     time.Duration
+	apiKey      string
+	model       string
+}
+
+// newASRBackends creates the balancer of the configured engine replicas, nil with a
+// single backend
+func newASRBackends(cfg *config.Config) *asrBackends {
+	urls := cfg.ASRBackends()
+	if len(urls) < 2 {
+		return nil
+	}
+
+	balancing := cfg.ASR.Balancing
+	strategy := balancer.Strategy(balancing.Strategy)
+	if strategy != "" && strategy != balancer.RoundRobin && strategy != balancer.LeastLatency {
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "unknown_balancing_strategy",
+			"strategy":  balancing.Strategy,
+		}).Warn("Unknown ASR balancing strategy, using round_robin")
+		strategy = balancer.RoundRobin
+	}
+
+	return &asrBackends{
+		pool: balancer.New(urls, balancer.Options{
+			Strategy: strategy,
+			Cooldown: time.Duration(balancing.CooldownSeconds) * time.Second,
+		}),
+		maxAttempts: balancing.MaxAttempts,
+		timeout:     time.Duration(balancing.TimeoutSeconds) * time.Second,
+		apiKey:      cfg.ASR.APIKey,
+		model:       cfg.ASR.Model,
+	}
+}
+
+// transcribe sends the audio to the ASR backend. In local mode the in-process model
+// recognizes it. Calls to the global engine try its replicas in balancing order until
+// one answers; a 4xx response is returned at once, another replica would refuse the
+// request too. Pipelines with their own provider are called directly. Calls wait for
+// the concurrency limit of their provider first.
+func (s *OpenAIService) transcribe(ctx context.Context, wavData []byte, headers http.Header, endpoint *llm.Endpoint) (*llm.Transcription, error) {
+	release, err := s.admitRecognition(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if s.localASR != nil && (endpoint == nil || endpoint.BaseURL == "") {
+		return s.transcribeLocal(ctx, wavData)
+	}
+
+	b := s.asrBackends
+	if b == nil || (endpoint != nil && endpoint.BaseURL != "") {
+		return llm.TranscribeWithEndpoint(ctx, wavData, headers, endpoint)
+	}
+
+	span := tracing.SpanFromContext(ctx)
+	urls := b.pool.Order()
+	attempts := len(urls)
+	if b.maxAttempts > 0 && b.maxAttempts < attempts {
+		attempts = b.maxAttempts
+	}
+
+	var lastErr error
+	for i, url := range urls[:attempts] {
+		attempt := llm.Endpoint{BaseURL: url, Timeout: b.timeout}
+		if endpoint != nil {
+			attempt.APIKey = endpoint.APIKey
+			attempt.Model = endpoint.Model
+			attempt.Language = endpoint.Language
+			attempt.DetectLanguage = endpoint.DetectLanguage
+			attempt.Hints = endpoint.Hints
+		}
+
+		start := time.Now()
+		result, err := llm.TranscribeWithEndpoint(ctx, wavData, headers, &attempt)
+		span.SetAttribute("asr.backend", url)
+		span.SetAttribute("asr.attempts", i+1)
+		if err == nil {
+			b.pool.Succeeded(url, time.Since(start))
+			return result, nil
+		}
+		if !llm.Retryable(err) {
+			return nil, err
+		}
+
+		b.pool.Failed(url, err)
+		lastErr = err
+		logger.WithFields(logrus.Fields{
+			"component": "api_asr_core",
+			"action":    "asr_backend_failed",
+			"backend":   url,
+			"attempt":   i + 1,
+			"attempts":  attempts,
+			"error":     err,
+		}).Warn("ASR backend failed, trying the next one")
+	}
+	return nil, fmt.Errorf("all %d ASR backends failed, last error: %w", attempts, lastErr)
+}
+
+// asrHealthLoop checks every ASR backend at the interval, taking failing backends out
+// of the rotation until a check passes again
+func (s *OpenAIService) asrHealthLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, url := range s.asrBackends.pool.URLs() {
+				result := health.NewHealthChecker(url, s.asrBackends.apiKey, s.asrBackends.model).CheckASREngineHealth()
+				s.asrBackends.pool.SetHealth(url, result.Status == "ok", result.Error)
+			}
+		}
+	}
+}
+
+// handleASRBackends returns the health, latency and failure counts of the ASR backends
+func handleASRBackends(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	if openAIService.asrBackends == nil {
+		c.JSON(http.StatusOK, gin.H{"balancing": false, "backends": []balancer.BackendStats{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"balancing": true, "backends": openAIService.asrBackends.pool.Stats()})
+}
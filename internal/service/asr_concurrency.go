@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/admission"
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Providers of asr.concurrency besides the base URL of a pipeline's engine
+const (
+	asrProviderLocal   = "local"   // the in-process model of asr.mode "local"
+	asrProviderDefault = "default" // asr.base_url and its replicas
+)
+
+// errASROverloaded fails recognition calls refused by a concurrency limit
+var errASROverloaded = errors.New("ASR provider overloaded")
+
+// ASRConcurrencyStats are the counters of one concurrency limit
+type ASRConcurrencyStats struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+	admission.Stats
+}
+
+// asrLimit is a limit of asr.concurrency with the limiter enforcing it
+type asrLimit struct {
+	provider string
+	model    string
+	timeout  time.Duration
+	limiter  *admission.Limiter
+}
+
+// asrConcurrency bounds the concurrent recognition calls per provider and model, so
+// a small local engine queues calls instead of being overloaded while calls to other
+// providers proceed
+type asrConcurrency struct {
+	limits []*asrLimit
+}
+
+// newASRConcurrency creates the limits of asr.concurrency, nil without any
+func newASRConcurrency(cfg *config.Config) *asrConcurrency {
+	c := &asrConcurrency{}
+	for _, limit := range cfg.ASR.Concurrency {
+		if limit.Provider == "" || limit.MaxConcurrent <= 0 {
+			logger.WithFields(logrus.Fields{
+				"component": "svc_openai_api ",
+				"action":    "invalid_asr_concurrency_limit",
+				"provider":  limit.Provider,
+				"model":     limit.Model,
+			}).Warn("ASR concurrency limit without provider or max_concurrent, ignoring it")
+			continue
+		}
+		c.limits = append(c.limits, &asrLimit{
+			provider: limit.Provider,
+			model:    limit.Model,
+			timeout:  time.Duration(limit.QueueTimeoutSeconds) * time.Second,
+			limiter:  admission.New(limit.MaxConcurrent, limit.MaxQueued),
+		})
+	}
+	if len(c.limits) == 0 {
+		return nil
+	}
+	return c
+}
+
+// find returns the first limit matching the provider and model, nil when none does
+func (c *asrConcurrency) find(provider, model string) *asrLimit {
+	for _, limit := range c.limits {
+		if limit.provider == provider && (limit.model == "" || limit.model == model) {
+			return limit
+		}
+	}
+	return nil
+}
+
+// asrProvider returns the provider and model a recognition call with endpoint goes to
+func (s *OpenAIService) asrProvider(endpoint *llm.Endpoint) (string, string) {
+	cfg := s.appConfig()
+	if s.localASR != nil && (endpoint == nil || endpoint.BaseURL == "") {
+		return asrProviderLocal, cfg.ASR.Local.ModelType
+	}
+
+	provider, model := asrProviderDefault, cfg.ASR.Model
+	if endpoint != nil {
+		if endpoint.BaseURL != "" {
+			provider = endpoint.BaseURL
+		}
+		if endpoint.Model != "" {
+			model = endpoint.Model
+		}
+	}
+	return provider, model
+}
+
+// admitRecognition waits for the concurrency limit of the call's provider and model
+// and returns the release of its slot. Calls the queue has no room for, or that wait
+// longer than the queue timeout, fail with errASROverloaded.
+func (s *OpenAIService) admitRecognition(ctx context.Context, endpoint *llm.Endpoint) (func(), error) {
+	if s.asrConcurrency == nil {
+		return func() {}, nil
+	}
+	provider, model := s.asrProvider(endpoint)
+	limit := s.asrConcurrency.find(provider, model)
+	if limit == nil {
+		return func() {}, nil
+	}
+
+	if limit.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limit.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	release, err := limit.limiter.Acquire(ctx)
+	waitMs := time.Since(start).Milliseconds()
+	tracing.SpanFromContext(ctx).SetAttribute("asr.queue_wait_ms", waitMs)
+	if err != nil {
+		stats := limit.limiter.Stats()
+		logger.WithFields(logrus.Fields{
+			"component":  "api_asr_core",
+			"action":     "asr_call_refused",
+			"provider":   provider,
+			"model":      model,
+			"active":     stats.Active,
+			"queueDepth": stats.Queued,
+			"waitMs":     waitMs,
+			"error":      err,
+		}).Warn("ASR provider at its concurrency limit, recognition call refused")
+		return nil, fmt.Errorf("%w: %s: %v", errASROverloaded, provider, err)
+	}
+
+	if waitMs > 0 {
+		logger.WithFields(logrus.Fields{
+			"component": "api_asr_core",
+			"action":    "asr_call_queued",
+			"provider":  provider,
+			"model":     model,
+			"waitMs":    waitMs,
+		}).Debug("Recognition call waited for the provider's concurrency limit")
+	}
+	return release, nil
+}
+
+// Stats returns the counters of every limit, in configuration order
+func (c *asrConcurrency) Stats() []ASRConcurrencyStats {
+	stats := make([]ASRConcurrencyStats, 0, len(c.limits))
+	for _, limit := range c.limits {
+		stats = append(stats, ASRConcurrencyStats{
+			Provider: limit.provider,
+			Model:    limit.model,
+			Stats:    limit.limiter.Stats(),
+		})
+	}
+	return stats
+}
+
+// handleASRConcurrency returns the active calls and queue depth of every ASR
+// concurrency limit
+func handleASRConcurrency(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	if openAIService.asrConcurrency == nil {
+		c.JSON(http.StatusOK, gin.H{"limits": []ASRConcurrencyStats{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"limits": openAIService.asrConcurrency.Stats()})
+}
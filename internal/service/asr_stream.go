@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/tracing"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultStreamFinalTimeout = 10 * time.Second
+
+// asrStreaming streams the speech of each utterance to the engine while it is spoken,
+// so its transcript is ready soon after the commit rather than one request later
+type asrStreaming struct {
+	url     string
+	timeout time.Duration
+}
+
+// newASRStreaming creates the streaming recognition of asr.streaming, nil without an
+// engine URL
+func newASRStreaming(cfg *config.Config) *asrStreaming {
+	streaming := cfg.ASR.Streaming
+	if streaming.URL == "" {
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "streaming_asr_disabled",
+		}).Warn("asr.streaming is enabled without url, utterances are sent per commit")
+		return nil
+	}
+
+	timeout := time.Duration(streaming.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultStreamFinalTimeout
+	}
+	return &asrStreaming{url: streaming.URL, timeout: timeout}
+}
+
+// streamsSession reports whether the utterances of the session are streamed. Sessions
+// whose pipeline names its own provider keep sending one request per commit.
+func (s *OpenAIService) streamsSession(session *Session) bool {
+	return s.asrStreaming != nil && (session.ASREndpoint == nil || session.ASREndpoint.BaseURL == "")
+}
+
+// feedStream sends speech of the utterance in progress to its recognition stream,
+// started with the first audio of the utterance
+func (s *OpenAIService) feedStream(session *Session, samples []int16) {
+	t := &session.interim
+	t.mutex.Lock()
+	if t.stream == nil {
+		t.stream = llm.StartStream(s.asrStreaming.url, session.ForwardedHeaders, session.ASREndpoint)
+		if t.itemID == "" && session.InputAudioTranscription.InterimResults {
+			t.itemID = session.NewItemID()
+		}
+		go s.relayPartials(session, t.stream, t.generation)
+	}
+	stream := t.stream
+	t.mutex.Unlock()
+
+	stream.Send(samples)
+}
+
+// takeStream returns the recognition stream of the utterance being committed, nil
+// when the utterance was not streamed
+func (t *interimTranscript) takeStream() *llm.RecognitionStream {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stream := t.stream
+	t.stream = nil
+	return stream
+}
+
+// relayPartials sends the partial hypotheses of a stream as transcription deltas of
+// its utterance until the utterance is committed
+func (s *OpenAIService) relayPartials(session *Session, stream *llm.RecognitionStream, generation int) {
+	for hypothesis := range stream.Partials() {
+		if session.InputAudioTranscription.InterimResults {
+			s.sendInterimDelta(session, generation, hypothesis)
+		}
+	}
+}
+
+// finishStream ends a streamed utterance and waits for its final transcript. A span of
+// ctx becomes the parent of the wait's span.
+func (s *OpenAIService) finishStream(ctx context.Context, session *Session, itemID string, stream *llm.RecognitionStream) (*llm.Transcription, error) {
+	ctx, span := s.tracer.Start(ctx, spanASRTranscribe, tracing.KindClient)
+	defer span.End()
+	span.SetAttribute("asr.backend", s.asrStreaming.url)
+	span.SetAttribute("asr.streaming", true)
+
+	ctx, cancel := context.WithTimeout(ctx, s.asrStreaming.timeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := stream.Finish(ctx)
+	if err != nil {
+		span.RecordError(err)
+		session.Logger().WithFields(logrus.Fields{
+			"component": "audio_recogniz",
+			"action":    "stream_recognition_failed",
+			"itemID":    itemID,
+			"sessionID": session.ID,
+			"error":     err,
+		}).Warn("Streaming recognition failed, sending the utterance per request")
+		return nil, err
+	}
+
+	session.Logger().WithFields(logrus.Fields{
+		"component":   "audio_recogniz",
+		"action":      "stream_recognition_completed",
+		"itemID":      itemID,
+		"sessionID":   session.ID,
+		"finalWaitMs": time.Since(start).Milliseconds(),
+	}).Debug("Streaming recognition completed")
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/go-restream/stt/pkg/audioquality"
+
+	"github.com/sirupsen/logrus"
+)
+
+// QualityStats aggregates the audio quality of a session's utterances
+type QualityStats struct {
+	Utterances   int     `json:"utterances"`
+	LowQuality   int     `json:"low_quality"` // utterances scoring below audioquality.LowScore
+	AverageScore float64 `json:"average_score"`
+	AverageSNRDb float64 `json:"average_snr_db"`
+}
+
+// qualityTracker accumulates per-utterance quality metrics of a session
+type qualityTracker struct {
+	mutex      sync.Mutex
+	utterances int
+	lowQuality int
+	scoreSum   float64
+	snrSum     float64
+}
+
+// Add records the metrics of a recognized utterance
+func (q *qualityTracker) Add(metrics audioquality.Metrics) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.utterances++
+	q.scoreSum += metrics.Score
+	q.snrSum += metrics.SNRDb
+	if metrics.Score < audioquality.LowScore {
+		q.lowQuality++
+	}
+}
+
+// Snapshot returns the aggregated statistics, nil before the first utterance
+func (q *qualityTracker) Snapshot() *QualityStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.utterances == 0 {
+		return nil
+	}
+	return &QualityStats{
+		Utterances:   q.utterances,
+		LowQuality:   q.lowQuality,
+		AverageScore: math.Round(q.scoreSum/float64(q.utterances)*1000) / 1000,
+		AverageSNRDb: math.Round(q.snrSum/float64(q.utterances)*10) / 10,
+	}
+}
+
+// checkAudioQuality feeds appended samples to the session's audio monitor and sends
+// an input_audio_buffer.quality_warning event for each sustained capture problem
+func (s *OpenAIService) checkAudioQuality(session *Session, samples []int16) {
+	if session.audioMonitor == nil {
+		return
+	}
+
+	for _, warning := range session.audioMonitor.Feed(samples, session.inputSampleRate()) {
+		warningEvent := &InputAudioBufferQualityWarningEvent{
+			BaseEvent: BaseEvent{
+				Type:      EventTypeInputAudioBufferQualityWarning,
+				EventID:   session.NewEventID(),
+				SessionID: session.ID,
+			},
+			Warning:       warning.Type,
+			ClippingRatio: warning.ClippingRatio,
+			DCOffset:      warning.DCOffset,
+			AudioStartMs:  warning.StartMs,
+			AudioEndMs:    warning.EndMs,
+		}
+		switch warning.Type {
+		case audioquality.WarningClipping:
+			warningEvent.Message = fmt.Sprintf("%.1f%% of samples are clipped, lower the input gain", warning.ClippingRatio*100)
+		case audioquality.WarningDCOffset:
+			warningEvent.Message = fmt.Sprintf("audio has a DC offset of %.1f%% of full scale, check the capture device", warning.DCOffset*100)
+		}
+
+		session.Logger().WithFields(logrus.Fields{
+			"component":     "proc_audio_main",
+			"action":        "audio_quality_warning",
+			"sessionID":     session.ID,
+			"warning":       warning.Type,
+			"clippingRatio": warning.ClippingRatio,
+			"dcOffset":      warning.DCOffset,
+			"audioStartMs":  warning.StartMs,
+			"audioEndMs":    warning.EndMs,
+		}).Warn("Input audio quality warning")
+
+		if err := s.sessionManager.SendEvent(session, warningEvent); err != nil {
+			session.Logger().WithFields(logrus.Fields{
+				"component": "ws_event_send ",
+				"action":    "send_quality_warning_failed",
+				"sessionID": session.ID,
+				"error":     err,
+			}).Error("Failed to send quality warning event")
+			return
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/retention"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RetentionResult is the outcome of applying the audio retention policy
+type RetentionResult struct {
+	FilesRemoved   int              `json:"files_removed"`
+	BytesReclaimed int64            `json:"bytes_reclaimed"`
+	ByReason       map[string]int64 `json:"bytes_reclaimed_by_reason,omitempty"` // age, quota, count or total
+	DurationMs     float64          `json:"duration_ms"`
+}
+
+// RetentionStats reports the saved audio and what the retention policy removed since
+// the service started
+type RetentionStats struct {
+	Files          int              `json:"files"` // segments on disk after the last run
+	Bytes          int64            `json:"bytes"`
+	Runs           int64            `json:"runs"`
+	FilesRemoved   int64            `json:"files_removed"` // by runs and per-session quotas
+	BytesReclaimed int64            `json:"bytes_reclaimed"`
+	ByReason       map[string]int64 `json:"bytes_reclaimed_by_reason"`
+	LastRunAt      time.Time        `json:"last_run_at,omitempty"`
+	LastRun        RetentionResult  `json:"last_run"`
+	Policy         retentionPolicy  `json:"policy"`
+}
+
+// retentionPolicy is the policy in force, as reported in RetentionStats
+type retentionPolicy struct {
+	MaxAgeHours  float64 `json:"max_age_hours,omitempty"`
+	MaxTotalMB   float64 `json:"max_total_mb,omitempty"`
+	MaxSessionMB float64 `json:"max_session_mb,omitempty"`
+	MaxFiles     int     `json:"max_files,omitempty"` // audio.keep_files, without other limits
+}
+
+// audioRetention accumulates RetentionStats, safe for concurrent use
+type audioRetention struct {
+	mutex          sync.Mutex
+	files          int
+	bytes          int64
+	runs           int64
+	filesRemoved   int64
+	bytesReclaimed int64
+	byReason       map[string]int64
+	lastRunAt      time.Time
+	lastRun        RetentionResult
+}
+
+// record counts removed files
+func (r *audioRetention) record(result RetentionResult) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.filesRemoved += int64(result.FilesRemoved)
+	r.bytesReclaimed += result.BytesReclaimed
+	if r.byReason == nil {
+		r.byReason = make(map[string]int64)
+	}
+	for reason, bytes := range result.ByReason {
+		r.byReason[reason] += bytes
+	}
+}
+
+// recordRun counts a run over all recordings and the saved audio it left
+func (r *audioRetention) recordRun(result RetentionResult, files int, bytes int64) {
+	r.record(result)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.runs++
+	r.lastRunAt = time.Now()
+	r.lastRun = result
+	r.files, r.bytes = files, bytes
+}
+
+// audioRetentionPolicy returns the policy of audio.retention, falling back to the
+// audio.keep_files newest segments when it sets no limit
+func audioRetentionPolicy(cfg *config.Config) (retention.Policy, retentionPolicy) {
+	limits := cfg.Audio.Retention
+	policy := retention.Policy{
+		MaxAge:        time.Duration(limits.MaxAgeHours * float64(time.Hour)),
+		MaxTotalBytes: int64(limits.MaxTotalMB * 1024 * 1024),
+		MaxGroupBytes: int64(limits.MaxSessionMB * 1024 * 1024),
+	}
+	if !policy.Enabled() {
+		policy.MaxFiles = cfg.Audio.KeepFiles
+		if policy.MaxFiles <= 0 {
+			policy.MaxFiles = 10
+		}
+	}
+	return policy, retentionPolicy{
+		MaxAgeHours:  limits.MaxAgeHours,
+		MaxTotalMB:   limits.MaxTotalMB,
+		MaxSessionMB: limits.MaxSessionMB,
+		MaxFiles:     policy.MaxFiles,
+	}
+}
+
+// recordingFiles returns the segments of the recordings whose keys start with prefix
+// and the journals of debug sessions saved next to them, grouped by the key prefix of
+// their session, and the prefixes of the recordings. Other objects, such as final
+// flush transcripts in the same directory, are left out.
+func recordingFiles(ctx context.Context, store ObjectStore, prefix string) ([]retention.File, []string, error) {
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recordings := make(map[string]bool)
+	var prefixes []string
+	for _, object := range objects {
+		if recording, found := strings.CutSuffix(object.Key, recordingMetadataFile); found {
+			recordings[recording] = true
+			prefixes = append(prefixes, recording)
+		}
+	}
+	var files []retention.File
+	for _, object := range objects {
+		if group, found := strings.CutSuffix(object.Key, journalFile); found && strings.HasSuffix(group, "/") {
+			files = append(files, retention.File{
+				Group:   group,
+				Path:    object.Key,
+				Size:    object.Size,
+				ModTime: object.ModTime,
+			})
+			continue
+		}
+		at := strings.LastIndex(object.Key, "segment_")
+		if at < 0 || !strings.HasSuffix(object.Key, ".wav") || !recordings[object.Key[:at]] {
+			continue
+		}
+		files = append(files, retention.File{
+			Group:   object.Key[:at],
+			Path:    object.Key,
+			Size:    object.Size,
+			ModTime: object.ModTime,
+		})
+	}
+	return files, prefixes, nil
+}
+
+// removeRecordingFiles removes the planned files, returning what was reclaimed
+func removeRecordingFiles(ctx context.Context, store ObjectStore, removals []retention.Removal) RetentionResult {
+	result := RetentionResult{ByReason: make(map[string]int64)}
+	for _, removal := range removals {
+		if err := store.Delete(ctx, removal.Path); err != nil {
+			continue
+		}
+		result.FilesRemoved++
+		result.BytesReclaimed += removal.Size
+		result.ByReason[removal.Reason] += removal.Size
+		logger.WithFields(logrus.Fields{
+			"component": "cln_audio_proc",
+			"action":    "file_removed",
+			"key":       removal.Path,
+			"reason":    removal.Reason,
+		}).Debug("Audio file removed by retention policy")
+	}
+	return result
+}
+
+// runAudioRetention applies the retention policy to all saved audio. Recordings left
+// without segments are removed once their session has ended.
+func (s *OpenAIService) runAudioRetention(ctx context.Context) (RetentionResult, error) {
+	start := time.Now()
+	store := s.audioStore()
+	template := s.audioKeyTemplate()
+	files, recordings, err := recordingFiles(ctx, store, template[:strings.Index(template, "{")])
+	if err != nil {
+		return RetentionResult{}, err
+	}
+
+	policy, _ := audioRetentionPolicy(s.appConfig())
+	removals := retention.Plan(files, policy, start)
+	result := removeRecordingFiles(ctx, store, removals)
+
+	removed := make(map[string]bool, len(removals))
+	for _, removal := range removals {
+		removed[removal.Path] = true
+	}
+	var keptFiles int
+	var keptBytes int64
+	kept := make(map[string]bool)
+	for _, file := range files {
+		if !removed[file.Path] {
+			keptFiles++
+			keptBytes += file.Size
+			kept[file.Group] = true
+		}
+	}
+	for _, prefix := range recordings {
+		if kept[prefix] {
+			continue
+		}
+		recording, err := readRecording(ctx, store, prefix)
+		if err != nil {
+			continue
+		}
+		if _, live := s.sessionManager.GetSession(recording.SessionID); live {
+			continue
+		}
+		if err := store.Delete(ctx, prefix+recordingMetadataFile); err == nil {
+			logger.WithFields(logrus.Fields{
+				"component": "cln_audio_proc",
+				"action":    "recording_removed",
+				"sessionID": recording.SessionID,
+			}).Info("Recording without segments removed")
+		}
+	}
+
+	result.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	s.retention.recordRun(result, keptFiles, keptBytes)
+	return result, nil
+}
+
+// enforceRecordingQuota removes the oldest segments of the recording at prefix over
+// audio.retention.max_session_mb, as its segments are saved
+func (s *OpenAIService) enforceRecordingQuota(ctx context.Context, session *Session, store ObjectStore, prefix string) {
+	policy, _ := audioRetentionPolicy(s.appConfig())
+	if policy.MaxGroupBytes <= 0 {
+		return
+	}
+	files, _, err := recordingFiles(ctx, store, prefix)
+	if err != nil {
+		return
+	}
+	removals := retention.Plan(files, retention.Policy{MaxGroupBytes: policy.MaxGroupBytes}, time.Now())
+	if len(removals) == 0 {
+		return
+	}
+	result := removeRecordingFiles(ctx, store, removals)
+	s.retention.record(result)
+	session.Logger().WithFields(logrus.Fields{
+		"component":      "cln_audio_proc",
+		"action":         "session_quota_enforced",
+		"filesRemoved":   result.FilesRemoved,
+		"bytesReclaimed": result.BytesReclaimed,
+	}).Info("Removed oldest audio segments over the session quota")
+}
+
+// RetentionStats returns the audio retention counters
+func (s *OpenAIService) RetentionStats() RetentionStats {
+	_, policy := audioRetentionPolicy(s.appConfig())
+	s.retention.mutex.Lock()
+	defer s.retention.mutex.Unlock()
+
+	byReason := make(map[string]int64, len(s.retention.byReason))
+	for reason, bytes := range s.retention.byReason {
+		byReason[reason] = bytes
+	}
+	return RetentionStats{
+		Files:          s.retention.files,
+		Bytes:          s.retention.bytes,
+		Runs:           s.retention.runs,
+		FilesRemoved:   s.retention.filesRemoved,
+		BytesReclaimed: s.retention.bytesReclaimed,
+		ByReason:       byReason,
+		LastRunAt:      s.retention.lastRunAt,
+		LastRun:        s.retention.lastRun,
+		Policy:         policy,
+	}
+}
+
+// audioRetentionLoop applies the retention policy at audio.retention.interval_seconds
+// until ctx is done
+func (s *OpenAIService) audioRetentionLoop(ctx context.Context) {
+	interval := time.Duration(s.appConfig().Audio.Retention.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			result, err := s.runAudioRetention(ctx)
+			if err != nil {
+				logger.WithFields(logrus.Fields{
+					"component": "cln_audio_proc",
+					"action":    "cleanup_failed",
+					"error":     err,
+				}).Error("Failed to apply audio retention policy")
+				continue
+			}
+			if result.FilesRemoved > 0 {
+				logger.WithFields(logrus.Fields{
+					"component":      "cln_audio_proc",
+					"action":         "retention_applied",
+					"filesRemoved":   result.FilesRemoved,
+					"bytesReclaimed": result.BytesReclaimed,
+					"byReason":       result.ByReason,
+					"durationMs":     result.DurationMs,
+				}).Info("Applied audio retention policy")
+			}
+		case <-ctx.Done():
+			// Context cancelled, exit gracefully
+			logger.WithFields(logrus.Fields{
+				"component": "cln_audio_proc",
+				"action":    "cleanup_stopped",
+			}).Info("Audio cleanup routine stopped")
+			return
+		}
+	}
+}
+
+// handleAudioRetentionStats returns the saved audio and the retention counters
+func handleAudioRetentionStats(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, openAIService.RetentionStats())
+}
+
+// handleAudioRetention applies the retention policy immediately and returns its
+// outcome with the retention counters
+func handleAudioRetention(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	result, err := openAIService.runAudioRetention(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logger.WithFields(logrus.Fields{
+		"component":      "cln_audio_proc",
+		"action":         "forced_retention",
+		"filesRemoved":   result.FilesRemoved,
+		"bytesReclaimed": result.BytesReclaimed,
+	}).Info("Applied audio retention policy via admin API")
+
+	c.JSON(http.StatusOK, gin.H{"run": result, "retention": openAIService.RetentionStats()})
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/wav"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxTranscriptionFileBytes is the upload limit of /v1/audio/transcriptions, the same
+// as the OpenAI API
+const maxTranscriptionFileBytes = 25 << 20
+
+// TranscriptionSegment is a speech segment of a transcribed file, times in seconds
+type TranscriptionSegment struct {
+	ID    int     `json:"id"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// FileTranscription is the verbose_json response of /v1/audio/transcriptions. Word
+// times are relative to the start of the file.
+type FileTranscription struct {
+	Task     string                  `json:"task"`
+	Language string                  `json:"language,omitempty"`
+	Duration float64                 `json:"duration"`
+	Text     string                  `json:"text"`
+	Segments []TranscriptionSegment  `json:"segments"`
+	Words    []llm.TranscriptionWord `json:"words,omitempty"`
+}
+
+// decodeWAVFile reads a 16-bit PCM WAV file, mixing multiple channels down to mono
+func decodeWAVFile(data []byte) ([]int16, int, error) {
+	reader, err := wav.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+	format := reader.GetFormat()
+	channels := int(max(1, format.NumChannels))
+
+	interleaved := make([]int16, reader.GetDataSize()/2)
+	n, err := reader.ReadSamples(interleaved)
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	interleaved = interleaved[:n]
+	if channels == 1 {
+		return interleaved, int(format.SampleRate), nil
+	}
+
+	mono := make([]int16, len(interleaved)/channels)
+	for i := range mono {
+		var sum int
+		for ch := 0; ch < channels; ch++ {
+			sum += int(interleaved[i*channels+ch])
+		}
+		mono[i] = int16(sum / channels)
+	}
+	return mono, int(format.SampleRate), nil
+}
+
+// speechSegments splits 16kHz audio into speech segments with a VAD detector of its
+// own, returning the start sample of each segment. Without VAD, or with the VAD
+// bypassed, the whole file is one segment.
+func (s *OpenAIService) speechSegments(samples []int16) ([][]int16, []int) {
+	cfg := s.appConfig()
+	if cfg == nil || !cfg.Vad.Enable || cfg.Vad.BypassForTesting {
+		return [][]int16{samples}, []int{0}
+	}
+
+	pool := modelPools.vadPool(cfg)
+	detector, err := pool.Get()
+	if err != nil {
+		return [][]int16{samples}, []int{0}
+	}
+	defer pool.Put(detector)
+
+	var segments [][]int16
+	var starts []int
+	collect := func(samples []float32, start int) {
+		segment := make([]int16, len(samples))
+		for i, sample := range samples {
+			segment[i] = int16(sample * 32767)
+		}
+		segments = append(segments, segment)
+		starts = append(starts, start)
+	}
+
+	// The detector restarts its sample count after each segment it returns
+	fed, resetOffset := 0, 0
+	chunk := make([]float32, 0, 160)
+	for i, sample := range samples {
+		chunk = append(chunk, float32(sample)/32768.0)
+		if len(chunk) < 160 && i < len(samples)-1 {
+			continue
+		}
+		fed += len(chunk)
+		if segment := detector.ProcessSamples(chunk); segment != nil && len(segment.Samples) > 0 {
+			collect(segment.Samples, resetOffset+segment.Start)
+			resetOffset = fed
+		}
+		chunk = chunk[:0]
+	}
+	for _, segment := range detector.Flush() {
+		if len(segment.Samples) > 0 {
+			collect(segment.Samples, resetOffset+segment.Start)
+		}
+	}
+	return segments, starts
+}
+
+// TranscribeFile transcribes 16kHz audio segment by segment through the same VAD and
+// ASR path as realtime sessions
+func (s *OpenAIService) TranscribeFile(samples []int16, headers http.Header, endpoint *llm.Endpoint) (*FileTranscription, error) {
+	result := &FileTranscription{
+		Task:     "transcribe",
+		Duration: float64(len(samples)) / 16000,
+		Segments: []TranscriptionSegment{},
+	}
+	if endpoint != nil {
+		result.Language = endpoint.Language
+	}
+
+	segments, starts := s.speechSegments(samples)
+	var texts []string
+	for i, segment := range segments {
+		wavData, err := s.convertToWAV(segment)
+		if err != nil {
+			return nil, err
+		}
+		transcription, err := s.callRecognitionAPI(context.Background(), wavData, headers, endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		text := strings.TrimSpace(transcription.Text)
+		if text == "" {
+			continue
+		}
+		start := float64(starts[i]) / 16000
+		result.Segments = append(result.Segments, TranscriptionSegment{
+			ID:    len(result.Segments),
+			Start: start,
+			End:   start + float64(len(segment))/16000,
+			Text:  text,
+		})
+		for _, word := range transcription.Words {
+			word.Start += start
+			word.End += start
+			result.Words = append(result.Words, word)
+		}
+		texts = append(texts, text)
+	}
+	result.Text = strings.Join(texts, " ")
+	return result, nil
+}
+
+// openAIError writes an error in the OpenAI API format, for clients of the
+// OpenAI-compatible REST endpoints
+func openAIError(c *gin.Context, status int, errorType string, message string, param string) {
+	c.JSON(status, gin.H{"error": gin.H{
+		"message": message,
+		"type":    errorType,
+		"param":   param,
+		"code":    nil,
+	}})
+}
+
+// handleAudioTranscription is an OpenAI-compatible POST /v1/audio/transcriptions. The
+// uploaded WAV file is resampled to 16kHz, split by the VAD and transcribed
+// synchronously; model and language override the configured ASR settings.
+func handleAudioTranscription(c *gin.Context) {
+	if openAIService == nil {
+		openAIError(c, http.StatusServiceUnavailable, "server_error", "OpenAI service not initialized", "")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTranscriptionFileBytes+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "file is required", "file")
+		return
+	}
+	if fileHeader.Size > maxTranscriptionFileBytes {
+		openAIError(c, http.StatusRequestEntityTooLarge, "invalid_request_error",
+			fmt.Sprintf("file exceeds %d bytes", maxTranscriptionFileBytes), "file")
+		return
+	}
+
+	responseFormat := c.DefaultPostForm("response_format", "json")
+	switch responseFormat {
+	case "json", "text", "verbose_json":
+	default:
+		openAIError(c, http.StatusBadRequest, "invalid_request_error",
+			"response_format must be json, text or verbose_json", "response_format")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error(), "file")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error(), "file")
+		return
+	}
+
+	samples, sampleRate, err := decodeWAVFile(data)
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error",
+			fmt.Sprintf("unsupported audio file, expected 16-bit PCM WAV: %v", err), "file")
+		return
+	}
+	if len(samples) == 0 {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "audio file contains no samples", "file")
+		return
+	}
+	if sampleRate != 16000 {
+		samples, err = openAIService.audioUtils.ResampleAudio(samples, sampleRate, 16000)
+		if err != nil {
+			openAIError(c, http.StatusBadRequest, "invalid_request_error",
+				fmt.Sprintf("failed to resample audio: %v", err), "file")
+			return
+		}
+	}
+
+	var headers http.Header
+	if openAIService.credentialPropagator != nil {
+		headers = openAIService.credentialPropagator.Propagate(c.Request)
+	}
+	endpoint := &llm.Endpoint{
+		Model:    c.PostForm("model"),
+		Language: c.PostForm("language"),
+	}
+
+	logger.WithFields(logrus.Fields{
+		"component":      "api_asr_core",
+		"action":         "file_transcription_started",
+		"fileName":       fileHeader.Filename,
+		"sampleRate":     sampleRate,
+		"sampleCount":    len(samples),
+		"responseFormat": responseFormat,
+	}).Info("Transcribing uploaded audio file")
+
+	result, err := openAIService.TranscribeFile(samples, headers, endpoint)
+	if errors.Is(err, errASROverloaded) {
+		openAIError(c, http.StatusServiceUnavailable, "server_error", err.Error(), "")
+		return
+	}
+	if err != nil {
+		openAIError(c, http.StatusBadGateway, "server_error", err.Error(), "")
+		return
+	}
+
+	switch responseFormat {
+	case "text":
+		c.String(http.StatusOK, result.Text)
+	case "verbose_json":
+		c.JSON(http.StatusOK, result)
+	default:
+		c.JSON(http.StatusOK, gin.H{"text": result.Text})
+	}
+}
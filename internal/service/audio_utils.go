@@ -10,13 +10,28 @@ import (
 	"strings"
 	"time"
 
+	"github.com/go-restream/stt/pkg/g711"
 	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/opus"
 	"github.com/go-restream/stt/pkg/resampler"
 	"github.com/go-restream/stt/pkg/wav"
 
 	"github.com/go-audio/audio"
 )
 
+// Input audio formats of input_audio_format.type
+const (
+	InputAudioFormatPCM16 = "pcm16"
+	InputAudioFormatOpus  = "opus"      // one Opus packet per input_audio_buffer.append
+	InputAudioFormatULaw  = "g711_ulaw" // 8kHz telephony audio
+	InputAudioFormatALaw  = "g711_alaw" // 8kHz telephony audio
+)
+
+// isG711Format reports whether an input format is 8kHz G.711 companded audio
+func isG711Format(format string) bool {
+	return format == InputAudioFormatULaw || format == InputAudioFormatALaw
+}
+
 // AudioUtils provides utilities for Base64 audio encoding/decoding and processing
 type AudioUtils struct{}
 
@@ -96,7 +111,11 @@ func (au *AudioUtils) ConvertBase64ToPCM16(base64Audio string) ([]int16, error)
 	if err != nil {
 		return nil, err
 	}
+	return au.ConvertBytesToPCM16(pcmBytes)
+}
 
+// ConvertBytesToPCM16 converts little-endian 16-bit PCM bytes to samples
+func (au *AudioUtils) ConvertBytesToPCM16(pcmBytes []byte) ([]int16, error) {
 	// Convert bytes to int16 samples
 	if len(pcmBytes)%2 != 0 {
 		return nil, fmt.Errorf("audio data length must be even for 16-bit PCM")
@@ -113,6 +132,37 @@ func (au *AudioUtils) ConvertBase64ToPCM16(base64Audio string) ([]int16, error)
 	return samples, nil
 }
 
+// ConvertBase64OpusToPCM16 decodes a Base64 Opus packet to 16-bit PCM samples at the
+// decoder's sample rate
+func (au *AudioUtils) ConvertBase64OpusToPCM16(decoder *opus.Decoder, base64Audio string) ([]int16, error) {
+	packet, err := au.DecodeBase64Audio(base64Audio)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.Decode(packet)
+}
+
+// ConvertBase64G711ToPCM16 expands Base64 G.711 audio of the given input format to
+// 16-bit PCM samples
+func (au *AudioUtils) ConvertBase64G711ToPCM16(format string, base64Audio string) ([]int16, error) {
+	data, err := au.DecodeBase64Audio(base64Audio)
+	if err != nil {
+		return nil, err
+	}
+	return au.ConvertG711ToPCM16(format, data)
+}
+
+// ConvertG711ToPCM16 expands G.711 bytes of the given input format to 16-bit PCM samples
+func (au *AudioUtils) ConvertG711ToPCM16(format string, data []byte) ([]int16, error) {
+	switch format {
+	case InputAudioFormatULaw:
+		return g711.DecodeULaw(data), nil
+	case InputAudioFormatALaw:
+		return g711.DecodeALaw(data), nil
+	}
+	return nil, fmt.Errorf("unsupported G.711 format: %s", format)
+}
+
 // ConvertPCM16ToBase64 converts 16-bit PCM samples to Base64
 func (au *AudioUtils) ConvertPCM16ToBase64(samples []int16) string {
 	pcmBytes := make([]byte, len(samples)*2)
@@ -321,6 +371,19 @@ func (au *AudioUtils) RemoveSilence(samples []int16, silenceThreshold int16) []i
 	return samples[start : end+1]
 }
 
+// PadSilence surrounds audio with leading and trailing silence
+func (au *AudioUtils) PadSilence(samples []int16, sampleRate int, leadingMs int, trailingMs int) []int16 {
+	leading := au.CalculateSampleCount(max(0, leadingMs), sampleRate)
+	trailing := au.CalculateSampleCount(max(0, trailingMs), sampleRate)
+	if leading == 0 && trailing == 0 {
+		return samples
+	}
+
+	padded := make([]int16, leading+len(samples)+trailing)
+	copy(padded[leading:], samples)
+	return padded
+}
+
 // ConvertPCM16ToWAV converts 16-bit PCM samples to WAV format
 func (au *AudioUtils) ConvertPCM16ToWAV(samples []int16, sampleRate int) ([]byte, error) {
 	// Create WAV format configuration
@@ -493,61 +556,6 @@ func (au *AudioUtils) SaveAudioFromBase64(base64Audio string, sampleRate int, fi
 	return au.SaveAudioToFile(samples, sampleRate, filename)
 }
 
-// CleanOldAudioFiles removes old audio files to prevent disk space issues
-func (au *AudioUtils) CleanOldAudioFiles(maxFiles int) error {
-	audioDir := "audio"
-
-	// Check if directory exists
-	if _, err := os.Stat(audioDir); os.IsNotExist(err) {
-		return nil // Directory doesn't exist, nothing to clean
-	}
-
-	// Read directory
-	files, err := os.ReadDir(audioDir)
-	if err != nil {
-		return fmt.Errorf("failed to read audio directory: %v", err)
-	}
-
-	// Filter only .wav files and sort by modification time
-	var wavFiles []os.FileInfo
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".wav") {
-			info, err := file.Info()
-			if err != nil {
-				continue
-			}
-			wavFiles = append(wavFiles, info)
-		}
-	}
-
-	// If we have more files than allowed, remove oldest ones
-	if len(wavFiles) > maxFiles {
-		// Sort by modification time (oldest first)
-		for i := 0; i < len(wavFiles)-1; i++ {
-			for j := i + 1; j < len(wavFiles); j++ {
-				if wavFiles[i].ModTime().After(wavFiles[j].ModTime()) {
-					wavFiles[i], wavFiles[j] = wavFiles[j], wavFiles[i]
-				}
-			}
-		}
-
-		// Remove oldest files
-		filesToRemove := len(wavFiles) - maxFiles
-		for i := 0; i < filesToRemove; i++ {
-			filePath := filepath.Join(audioDir, wavFiles[i].Name())
-			if err := os.Remove(filePath); err == nil {
-				logger.WithFields(map[string]interface{}{
-					"component": "cln_audio_proc",
-					"action":    "file_removed",
-					"filePath":  filePath,
-				}).Info("Old audio file removed")
-			}
-		}
-	}
-
-	return nil
-}
-
 // Helper function for absolute value
 func abs(x int16) int16 {
 	if x < 0 {
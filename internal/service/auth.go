@@ -0,0 +1,314 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/errcode"
+	"github.com/go-restream/stt/pkg/jwt"
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// jwtLeeway is the clock skew tolerated on the exp and nbf claims of client tokens
+const jwtLeeway = 30 * time.Second
+
+var (
+	errMissingCredentials = errors.New("missing credentials, send an Authorization bearer token or the token query parameter")
+	errInvalidCredentials = errors.New("invalid API key or token")
+)
+
+// Scopes of API keys and tokens, a client holding admin has all of them
+const (
+	ScopeTranscribe = "transcribe" // open sessions, transcribe files, chat completions
+	ScopeObserve    = "observe"    // read session statistics, journals and admin reports
+	ScopeExport     = "export"     // download transcripts and captions of sessions
+	ScopeAdmin      = "admin"      // change runtime settings and act on sessions
+)
+
+// Principal is the authenticated client of a request
+type Principal struct {
+	Tenant      string
+	KeyID       string // API key name or JWT subject, never the secret itself
+	QuotaKey    string // sessions counted together against MaxSessions
+	MaxSessions int    // concurrent sessions of QuotaKey, 0 means unlimited
+	Scopes      []string
+}
+
+// HasScope reports whether the principal may perform the operations of scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// principalScopes returns the configured scopes of a key or token, transcribe when
+// none are given so that keys predating scopes keep opening sessions only
+func principalScopes(scopes []string) []string {
+	if len(scopes) == 0 {
+		return []string{ScopeTranscribe}
+	}
+	return scopes
+}
+
+// Authenticator decides whether a request may use the API, and with which scopes
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// ConfigAuthenticator accepts the API keys of the configuration and, with a secret
+// configured, HS256 JWTs carrying a tenant claim. API key quotas count per key, JWT
+// quotas per tenant.
+type ConfigAuthenticator struct {
+	apiKeys        []config.APIKey
+	jwtSecret      []byte
+	jwtExpect      jwt.Expect
+	tenantClaim    string
+	jwtMaxSessions int
+}
+
+// NewConfigAuthenticator creates an authenticator from the auth section of the configuration
+func NewConfigAuthenticator(cfg *config.Config) *ConfigAuthenticator {
+	a := &ConfigAuthenticator{
+		tenantClaim:    cfg.Auth.JWT.TenantClaim,
+		jwtMaxSessions: cfg.Auth.JWT.MaxSessions,
+		jwtExpect: jwt.Expect{
+			Issuer:   cfg.Auth.JWT.Issuer,
+			Audience: cfg.Auth.JWT.Audience,
+			Leeway:   jwtLeeway,
+		},
+	}
+	if a.tenantClaim == "" {
+		a.tenantClaim = "tenant"
+	}
+	if cfg.Auth.JWT.Secret != "" {
+		a.jwtSecret = []byte(cfg.Auth.JWT.Secret)
+	}
+	for _, key := range cfg.Auth.APIKeys {
+		if key.Key == "" {
+			continue
+		}
+		if key.Name == "" {
+			key.Name = key.Tenant
+		}
+		a.apiKeys = append(a.apiKeys, key)
+	}
+	return a
+}
+
+// requestToken reads the Authorization bearer token, or the token query parameter
+// for browsers, which cannot set headers on a WebSocket
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return r.URL.Query().Get("token")
+}
+
+// Authenticate matches the request token against the API keys, then as a JWT
+func (a *ConfigAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := requestToken(r)
+	if token == "" {
+		return nil, errMissingCredentials
+	}
+
+	for _, key := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key.Key)) == 1 {
+			return &Principal{
+				Tenant:      key.Tenant,
+				KeyID:       key.Name,
+				QuotaKey:    "key:" + key.Name,
+				MaxSessions: key.MaxSessions,
+				Scopes:      principalScopes(key.Scopes),
+			}, nil
+		}
+	}
+
+	if a.jwtSecret == nil || strings.Count(token, ".") != 2 {
+		return nil, errInvalidCredentials
+	}
+	claims, err := jwt.Verify(token, a.jwtSecret, a.jwtExpect, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidCredentials, err)
+	}
+	tenant := claims.String(a.tenantClaim)
+	if tenant == "" {
+		return nil, fmt.Errorf("%w: token has no %s claim", errInvalidCredentials, a.tenantClaim)
+	}
+
+	maxSessions := a.jwtMaxSessions
+	if claimed := claims.Int("max_sessions"); claimed > 0 {
+		maxSessions = claimed
+	}
+	return &Principal{
+		Tenant:      tenant,
+		KeyID:       claims.String("sub"),
+		QuotaKey:    "tenant:" + tenant,
+		MaxSessions: maxSessions,
+		Scopes:      principalScopes(strings.Fields(claims.String("scope"))),
+	}, nil
+}
+
+// SetAuthenticator replaces the authenticator of WebSocket upgrade requests, nil
+// accepts every connection
+func (s *OpenAIService) SetAuthenticator(authenticator Authenticator) {
+	s.authenticator = authenticator
+}
+
+// sessionQuota counts the open sessions of each quota key
+type sessionQuota struct {
+	mutex  sync.Mutex
+	active map[string]int
+}
+
+// acquire counts a new session of the principal, false when its quota is full
+func (q *sessionQuota) acquire(principal *Principal) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.active == nil {
+		q.active = make(map[string]int)
+	}
+	if principal.MaxSessions > 0 && q.active[principal.QuotaKey] >= principal.MaxSessions {
+		return false
+	}
+	q.active[principal.QuotaKey]++
+	return true
+}
+
+// release ends a session counted by acquire
+func (q *sessionQuota) release(principal *Principal) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.active[principal.QuotaKey] <= 1 {
+		delete(q.active, principal.QuotaKey)
+		return
+	}
+	q.active[principal.QuotaKey]--
+}
+
+// principalKey is the gin context key holding the principal authenticated by requireScope
+const principalKey = "principal"
+
+// requireScope refuses requests whose client lacks scope when authentication is
+// enabled. The principal is kept in the context for the handler.
+func requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if openAIService == nil || openAIService.authenticator == nil {
+			c.Next()
+			return
+		}
+
+		principal, err := openAIService.authenticator.Authenticate(c.Request)
+		if err != nil {
+			rejectUnauthenticated(c, err)
+			c.Abort()
+			return
+		}
+		if !principal.HasScope(scope) {
+			rejectMissingScope(c, principal, scope)
+			c.Abort()
+			return
+		}
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// rejectMissingScope answers a request whose client lacks the scope of the operation
+// with 403
+func rejectMissingScope(c *gin.Context, principal *Principal, scope string) {
+	logger.WithFields(logrus.Fields{
+		"component": "svc_openai_api ",
+		"action":    "scope_denied",
+		"tenant":    principal.Tenant,
+		"keyID":     principal.KeyID,
+		"scope":     scope,
+		"path":      c.FullPath(),
+	}).Warn("Rejected request lacking the required scope")
+
+	c.JSON(http.StatusForbidden, gin.H{"error": gin.H{
+		"message": fmt.Sprintf("the API key or token lacks the %s scope", scope),
+		"type":    "invalid_request_error",
+		"code":    "insufficient_scope",
+	}})
+}
+
+// rejectUnauthenticated answers a request that failed authentication with 401
+func rejectUnauthenticated(c *gin.Context, err error) {
+	logger.WithFields(logrus.Fields{
+		"component":  "svc_openai_api ",
+		"action":     "websocket_auth_failed",
+		"remoteAddr": c.ClientIP(),
+		"error":      err,
+	}).Warn("Rejected unauthenticated request")
+
+	c.Header("WWW-Authenticate", `Bearer realm="realtime"`)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": gin.H{
+		"message": err.Error(),
+		"type":    "invalid_request_error",
+		"code":    "invalid_api_key",
+	}})
+}
+
+// rejectOverQuota sends an error event to a connection whose key has no session left
+// and closes it with a policy violation. The upgrade succeeded so that clients, browsers
+// in particular, learn why the session was refused.
+func rejectOverQuota(conn *websocket.Conn, principal *Principal) {
+	logger.WithFields(logrus.Fields{
+		"component":   "svc_openai_api ",
+		"action":      "session_quota_exceeded",
+		"tenant":      principal.Tenant,
+		"keyID":       principal.KeyID,
+		"maxSessions": principal.MaxSessions,
+	}).Warn("Rejected session over the concurrent session quota")
+
+	errorEvent := &ErrorEvent{
+		BaseEvent: BaseEvent{
+			Type:    EventTypeError,
+			EventID: GenerateEventID(),
+		},
+	}
+	errorEvent.Error.Type = "invalid_request_error"
+	errorEvent.Error.Code = "session_quota_exceeded"
+	errorEvent.Error.Category = errcode.SessionLimit
+	errorEvent.Error.Message = fmt.Sprintf("concurrent session limit of %d reached", principal.MaxSessions)
+
+	rejectConnection(conn, errorEvent, "session_quota_exceeded")
+}
+
+// rejectOverCapacity refuses a connection when the server holds its maximum number of
+// sessions
+func rejectOverCapacity(conn *websocket.Conn, maxSessions int) {
+	errorEvent := &ErrorEvent{
+		BaseEvent: BaseEvent{
+			Type:    EventTypeError,
+			EventID: GenerateEventID(),
+		},
+		Error: newErrorDetails("server_error", "max_sessions_reached",
+			fmt.Sprintf("server session limit of %d reached", maxSessions), ""),
+	}
+	rejectConnection(conn, errorEvent, "max_sessions_reached")
+}
+
+// rejectConnection sends the event explaining why a connection is refused before any
+// session exists, then closes it with a policy violation
+func rejectConnection(conn *websocket.Conn, event interface{}, reason string) {
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	conn.WriteJSON(event)
+	message := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+}
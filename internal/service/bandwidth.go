@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BandwidthStats counts the bytes a session moved over its WebSocket, frame headers
+// included. Inbound audio is the base64 audio of input_audio_buffer.append and outbound
+// transcript the text of completed transcriptions; the rest is protocol overhead.
+type BandwidthStats struct {
+	DurationMs              int64   `json:"duration_ms"`
+	AudioMs                 int64   `json:"audio_ms"` // input audio received
+	InboundBytes            int64   `json:"inbound_bytes"`
+	InboundAudioBytes       int64   `json:"inbound_audio_bytes"`
+	InboundOverheadBytes    int64   `json:"inbound_overhead_bytes"`
+	InboundMessages         int64   `json:"inbound_messages"`
+	OutboundBytes           int64   `json:"outbound_bytes"`
+	OutboundTranscriptBytes int64   `json:"outbound_transcript_bytes"`
+	OutboundOverheadBytes   int64   `json:"outbound_overhead_bytes"`
+	OutboundMessages        int64   `json:"outbound_messages"`
+	InboundKbps             float64 `json:"inbound_kbps"`           // average over the session duration
+	OutboundKbps            float64 `json:"outbound_kbps"`          // average over the session duration
+	BytesPerAudioSecond     float64 `json:"bytes_per_audio_second"` // both directions per second of input audio
+}
+
+// bandwidthCounter accumulates the byte counters of a session, safe for concurrent use
+type bandwidthCounter struct {
+	inboundBytes            atomic.Int64
+	inboundAudioBytes       atomic.Int64
+	inboundMessages         atomic.Int64
+	outboundBytes           atomic.Int64
+	outboundTranscriptBytes atomic.Int64
+	outboundMessages        atomic.Int64
+	audioMicros             atomic.Int64
+	reportSeconds           atomic.Int64 // interval of session.bandwidth events, 0 disables
+}
+
+// wsFrameOverhead is the size of the WebSocket frame header carrying a payload.
+// Frames sent by clients are masked and carry a 4 byte key.
+func wsFrameOverhead(payload int, masked bool) int64 {
+	overhead := int64(2)
+	switch {
+	case payload > 65535:
+		overhead += 8
+	case payload > 125:
+		overhead += 2
+	}
+	if masked {
+		overhead += 4
+	}
+	return overhead
+}
+
+// addInbound records a frame received from the client
+func (b *bandwidthCounter) addInbound(payload int) {
+	b.inboundBytes.Add(int64(payload) + wsFrameOverhead(payload, true))
+	b.inboundMessages.Add(1)
+}
+
+// addInboundAudio records the base64 audio of an append event and its duration
+func (b *bandwidthCounter) addInboundAudio(encoded int, duration time.Duration) {
+	b.inboundAudioBytes.Add(int64(encoded))
+	b.audioMicros.Add(duration.Microseconds())
+}
+
+// addOutbound records a frame sent to the client
+func (b *bandwidthCounter) addOutbound(payload int) {
+	b.outboundBytes.Add(int64(payload) + wsFrameOverhead(payload, false))
+	b.outboundMessages.Add(1)
+}
+
+// addOutboundTranscript records the transcript text of a completed transcription
+func (b *bandwidthCounter) addOutboundTranscript(text string) {
+	b.outboundTranscriptBytes.Add(int64(len(text)))
+}
+
+// Snapshot returns the counters of a session that has been open for duration
+func (b *bandwidthCounter) Snapshot(duration time.Duration) BandwidthStats {
+	stats := BandwidthStats{
+		DurationMs:              duration.Milliseconds(),
+		AudioMs:                 b.audioMicros.Load() / 1000,
+		InboundBytes:            b.inboundBytes.Load(),
+		InboundAudioBytes:       b.inboundAudioBytes.Load(),
+		InboundMessages:         b.inboundMessages.Load(),
+		OutboundBytes:           b.outboundBytes.Load(),
+		OutboundTranscriptBytes: b.outboundTranscriptBytes.Load(),
+		OutboundMessages:        b.outboundMessages.Load(),
+	}
+	stats.InboundOverheadBytes = max(0, stats.InboundBytes-stats.InboundAudioBytes)
+	stats.OutboundOverheadBytes = max(0, stats.OutboundBytes-stats.OutboundTranscriptBytes)
+
+	if seconds := duration.Seconds(); seconds > 0 {
+		stats.InboundKbps = float64(stats.InboundBytes) * 8 / 1000 / seconds
+		stats.OutboundKbps = float64(stats.OutboundBytes) * 8 / 1000 / seconds
+	}
+	if stats.AudioMs > 0 {
+		stats.BytesPerAudioSecond = float64(stats.InboundBytes+stats.OutboundBytes) * 1000 / float64(stats.AudioMs)
+	}
+	return stats
+}
+
+// Bandwidth returns the byte counters of the session so far
+func (s *Session) Bandwidth() BandwidthStats {
+	return s.bandwidth.Snapshot(time.Since(s.CreatedAt))
+}
+
+// SetBandwidthReportSeconds sets the interval of session.bandwidth events, 0 disables them
+func (s *Session) SetBandwidthReportSeconds(seconds int) {
+	s.bandwidth.reportSeconds.Store(int64(max(0, seconds)))
+}
+
+// reportLoop sends session.bandwidth and session.stats at the session's report
+// intervals until the connection closes. The intervals may change at any time with
+// session.update.
+func (s *OpenAIService) reportLoop(ctx context.Context, session *Session) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	lastReport, lastStats := time.Now(), time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if interval := time.Duration(session.stats.reportSeconds.Load()) * time.Second; interval > 0 && now.Sub(lastStats) >= interval {
+				lastStats = now
+				s.sendSessionStats(session, "")
+			}
+
+			interval := time.Duration(session.bandwidth.reportSeconds.Load()) * time.Second
+			if interval <= 0 || now.Sub(lastReport) < interval {
+				continue
+			}
+			lastReport = now
+
+			bandwidthEvent := &SessionBandwidthEvent{
+				BaseEvent: BaseEvent{
+					Type:      EventTypeSessionBandwidth,
+					EventID:   session.NewEventID(),
+					SessionID: session.ID,
+				},
+				Bandwidth: session.Bandwidth(),
+			}
+			if err := s.sessionManager.SendEvent(session, bandwidthEvent); err != nil {
+				session.Logger().WithFields(logrus.Fields{
+					"component": "ws_event_send",
+					"action":    "send_session_bandwidth_failed",
+					"sessionID": session.ID,
+					"error":     err,
+				}).Warn("Failed to send session.bandwidth event")
+			}
+		}
+	}
+}
+
+// logSessionBandwidth logs the final byte counters of a session for bandwidth cost reporting
+func logSessionBandwidth(session *Session) {
+	stats := session.Bandwidth()
+	session.Logger().WithFields(logrus.Fields{
+		"component":               "mont_srv_status",
+		"action":                  "session_bandwidth",
+		"sessionID":               session.ID,
+		"durationMs":              stats.DurationMs,
+		"audioMs":                 stats.AudioMs,
+		"inboundBytes":            stats.InboundBytes,
+		"inboundAudioBytes":       stats.InboundAudioBytes,
+		"outboundBytes":           stats.OutboundBytes,
+		"outboundTranscriptBytes": stats.OutboundTranscriptBytes,
+		"bytesPerAudioSecond":     stats.BytesPerAudioSecond,
+	}).Info("Session bandwidth metrics")
+}
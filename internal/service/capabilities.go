@@ -0,0 +1,156 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/go-restream/stt/denoiser"
+	"github.com/go-restream/stt/internal/version"
+	"github.com/go-restream/stt/pkg/opus"
+	"github.com/go-restream/stt/vad"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeatureStatus describes whether an optional subsystem is active and which backend serves it
+type FeatureStatus struct {
+	Enabled bool   `json:"enabled"`
+	Backend string `json:"backend,omitempty"`
+}
+
+// Capabilities describes the optional subsystems active in this deployment so that
+// clients can adapt to the deployed feature set
+type Capabilities struct {
+	Version     string        `json:"version"`
+	VAD         FeatureStatus `json:"vad"`
+	Denoiser    FeatureStatus `json:"denoiser"`
+	Diarization FeatureStatus `json:"diarization"`
+	Translation FeatureStatus `json:"translation"`
+	Storage     FeatureStatus `json:"storage"` // transcripts: "memory", "sqlite" or "postgres"
+	// Where session state is shared for resume and cluster takeover: "memory" or "redis"
+	SessionStore FeatureStatus `json:"session_store"`
+	// Where saved audio and transcripts are written: "local" or "s3"
+	ObjectStorage FeatureStatus     `json:"object_storage"`
+	Providers     map[string]string `json:"providers"`
+	Features      []string          `json:"features"`
+	Pipelines     []string          `json:"pipelines,omitempty"`
+}
+
+// Capabilities reports the optional subsystems enabled by the loaded configuration
+func (s *OpenAIService) Capabilities() Capabilities {
+	caps := Capabilities{
+		Version:       version.Short(),
+		Diarization:   FeatureStatus{Enabled: false},
+		Translation:   FeatureStatus{Enabled: false},
+		Storage:       FeatureStatus{Enabled: true, Backend: "memory"},
+		SessionStore:  FeatureStatus{Enabled: false},
+		ObjectStorage: FeatureStatus{Enabled: true, Backend: "local"},
+		Providers:     map[string]string{},
+		Features: []string{
+			"audio_checksum",
+			"session_debug",
+			"credential_forwarding",
+			"audio_transcriptions",
+			"g711",
+			"webvtt_captions",
+			"input_audio_noise_reduction",
+			"input_audio_gain_control",
+			"multi_channel_input",
+			"transcription_hints",
+			"binary_audio",
+			"twilio_media_streams",
+		},
+	}
+
+	if opus.Supported {
+		caps.Features = append(caps.Features, "opus")
+	}
+
+	cfg := s.appConfig()
+	if cfg == nil {
+		return caps
+	}
+
+	// Report the backends actually opened, a misconfigured one falls back
+	if s.transcripts != nil {
+		caps.Storage.Backend = cfg.TranscriptStore.Type
+	}
+	if s.sessionStore != nil {
+		caps.SessionStore = FeatureStatus{Enabled: true, Backend: cfg.SessionStore.Type}
+	}
+	if s.bucket != nil {
+		caps.ObjectStorage.Backend = "s3"
+	}
+
+	if cfg.NoiseGate.Enable {
+		caps.Features = append(caps.Features, "noise_gate")
+	}
+	if cfg.AGC.Enable {
+		caps.Features = append(caps.Features, "agc")
+	}
+
+	if cfg.Vad.Enable {
+		caps.VAD = FeatureStatus{Enabled: true, Backend: vad.EngineName(cfg)}
+		if cfg.Vad.BypassForTesting {
+			caps.VAD.Backend = "bypass"
+		}
+		caps.Features = append(caps.Features, "interim_results")
+	}
+
+	if cfg.Denoiser.Enable {
+		caps.Denoiser = FeatureStatus{Enabled: true, Backend: denoiser.EngineName(cfg)}
+		if cfg.Denoiser.BypassForTesting {
+			caps.Denoiser.Backend = "bypass"
+		}
+	}
+
+	if s.localASR != nil {
+		caps.Providers["asr"] = "local"
+		caps.Features = append(caps.Features, "local_asr")
+	} else if cfg.ASR.BaseURL != "" {
+		caps.Providers["asr"] = cfg.ASR.Model
+	}
+	if cfg.LLM.BaseURL != "" {
+		caps.Providers["llm"] = cfg.LLM.Model
+	}
+
+	if cfg.Conversation.MaxItems > 0 || cfg.Conversation.MaxTranscriptChars > 0 {
+		caps.Features = append(caps.Features, "conversation_limits")
+	}
+	if cfg.Limits.Enable {
+		caps.Features = append(caps.Features, "usage_limits")
+	}
+	if cfg.FinalFlush.Enable {
+		caps.Features = append(caps.Features, "final_flush")
+	}
+	if s.asrStreaming != nil {
+		caps.Features = append(caps.Features, "streaming_asr")
+	}
+	if cfg.ASR.WordConfidence {
+		caps.Features = append(caps.Features, "word_confidence")
+	}
+	if s.polls != nil {
+		caps.Features = append(caps.Features, "long_poll")
+	}
+	if s.resume != nil {
+		caps.Features = append(caps.Features, "session_resume")
+	}
+	if s.sip != nil {
+		caps.Features = append(caps.Features, "sip")
+	}
+	if len(cfg.Pipelines) > 0 {
+		caps.Features = append(caps.Features, "pipelines")
+		caps.Pipelines = pipelineNames(cfg)
+	}
+
+	return caps
+}
+
+// handleCapabilities returns the runtime feature set of the service
+func handleCapabilities(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, openAIService.Capabilities())
+}
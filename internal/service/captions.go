@@ -0,0 +1,269 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-restream/stt/pkg/captions"
+	"github.com/go-restream/stt/pkg/textformat"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// CaptionConfig enables caption.cue events and sets their line-breaking limits
+type CaptionConfig struct {
+	Enabled bool `json:"enabled"`
+	textformat.CueOptions
+}
+
+// takeUtteranceStart returns the start of the speech committed next and resets it
+func (s *Session) takeUtteranceStart() int64 {
+	start := s.utteranceStartMs
+	s.utteranceStartMs = 0
+	s.hasUtteranceStart = false
+	return start
+}
+
+// sendCaptionCues splits a completed transcript into caption cues and sends them
+// as caption.cue events
+func (s *OpenAIService) sendCaptionCues(session *Session, itemID string, text string, durationMs int64, options textformat.CueOptions) {
+	var startMs int64
+	if item, err := s.sessionManager.GetConversationItem(session.ID, itemID); err == nil {
+		startMs = item.AudioStartMs
+	}
+
+	cues := textformat.Cues(text, startMs, startMs+durationMs, options)
+	for i, cue := range cues {
+		cueEvent := &CaptionCueEvent{
+			BaseEvent: BaseEvent{
+				Type:      EventTypeCaptionCue,
+				EventID:   session.NewEventID(),
+				SessionID: session.ID,
+			},
+			ItemID:    itemID,
+			CueIndex:  cue.Index,
+			StartMs:   cue.StartMs,
+			EndMs:     cue.EndMs,
+			Lines:     cue.Lines,
+			Final:     i == len(cues)-1,
+			Direction: string(cue.Direction),
+		}
+
+		if err := s.sessionManager.SendEvent(session, cueEvent); err != nil {
+			session.Logger().WithFields(logrus.Fields{
+				"component": "ws_event_send ",
+				"action":    "send_caption_cue_failed",
+				"sessionID": session.ID,
+				"itemID":    itemID,
+				"error":     err,
+			}).Error("Failed to send caption.cue event")
+			return
+		}
+	}
+
+	session.Logger().WithFields(logrus.Fields{
+		"component": "ws_event_send ",
+		"action":    "caption_cues_sent",
+		"sessionID": session.ID,
+		"itemID":    itemID,
+		"cues":      len(cues),
+	}).Debug("Sent caption cues")
+}
+
+// captionFileSet holds the caption files of a session under output.captions.dir, opened
+// with its first completed transcript and closed when the session ends
+type captionFileSet struct {
+	mutex  sync.Mutex
+	opened bool
+	files  []*captionFile
+}
+
+type captionFile struct {
+	file   *os.File
+	writer *captions.Writer
+}
+
+// open opens the caption files of the session in dir, appending to files left by a
+// previous run or another node of the session
+func (c *captionFileSet) open(session *Session, dir string, formats []string, options textformat.CueOptions) {
+	c.opened = true
+	if len(formats) == 0 {
+		formats = []string{string(captions.FormatVTT)}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "mg_session_ctrl",
+			"action":    "caption_dir_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Failed to create caption directory")
+		return
+	}
+
+	for _, name := range formats {
+		file, err := openCaptionFile(filepath.Join(dir, session.ID), name, options)
+		if err != nil {
+			session.Logger().WithFields(logrus.Fields{
+				"component": "mg_session_ctrl",
+				"action":    "caption_file_failed",
+				"sessionID": session.ID,
+				"format":    name,
+				"error":     err,
+			}).Error("Failed to open caption file")
+			continue
+		}
+		c.files = append(c.files, file)
+	}
+}
+
+// openCaptionFile opens the caption file of format name at base plus its extension,
+// numbering new cues after those already in it
+func openCaptionFile(base string, name string, opts textformat.CueOptions) (*captionFile, error) {
+	format, err := captions.ParseFormat(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(base+format.Ext(), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := captions.NewWriter(file, format, opts)
+	info, err := file.Stat()
+	if err == nil && info.Size() > 0 {
+		var cues int
+		if cues, err = captions.CountCues(file, format); err == nil {
+			writer.Resume(cues)
+		}
+	} else if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &captionFile{file: file, writer: writer}, nil
+}
+
+// close closes the caption files of an ended session
+func (c *captionFileSet) close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, f := range c.files {
+		f.file.Close()
+	}
+	c.files = nil
+}
+
+// writeCaptionFiles appends the cues of a completed item to the caption files of the
+// session, in the order items complete
+func (s *OpenAIService) writeCaptionFiles(session *Session, itemID string) {
+	cfg := s.appConfig()
+	if cfg == nil || cfg.Output.Captions.Dir == "" {
+		return
+	}
+	item, err := s.sessionManager.GetConversationItem(session.ID, itemID)
+	if err != nil {
+		return
+	}
+
+	session.itemsMutex.RLock()
+	segment := captions.Segment{
+		ItemID:   item.ID,
+		StartMs:  item.AudioStartMs,
+		EndMs:    item.AudioEndMs,
+		Text:     conversationItemTranscript(item),
+		Language: item.Language,
+	}
+	session.itemsMutex.RUnlock()
+	if segment.Text == "" || segment.EndMs == 0 {
+		return
+	}
+
+	// Snapshot before the files lock, sessions are removed holding the manager lock
+	options := s.sessionManager.snapshotItemSettings(session).captions.CueOptions
+	c := &session.captionFiles
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.opened {
+		c.open(session, cfg.Output.Captions.Dir, cfg.Output.Captions.Formats, options)
+	}
+	for _, f := range c.files {
+		if err := f.writer.WriteSegment(segment); err != nil {
+			session.Logger().WithFields(logrus.Fields{
+				"component": "mg_session_ctrl",
+				"action":    "caption_write_failed",
+				"sessionID": session.ID,
+				"itemID":    itemID,
+				"file":      f.file.Name(),
+				"error":     err,
+			}).Error("Failed to write caption file")
+		}
+	}
+}
+
+// CaptionCues splits the completed transcripts of a session into caption cues with the
+// session's caption settings, timed against the session audio
+func (sm *SessionManager) CaptionCues(sessionID string) ([]textformat.Cue, error) {
+	session, exists := sm.GetSession(sessionID)
+	if !exists {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	options := sm.snapshotItemSettings(session).captions.CueOptions
+
+	session.itemsMutex.RLock()
+	defer session.itemsMutex.RUnlock()
+
+	var cues []textformat.Cue
+	for _, item := range session.ConversationItems {
+		transcript := conversationItemTranscript(item)
+		if item.Status != "completed" || transcript == "" || item.AudioEndMs == 0 {
+			continue
+		}
+		cues = append(cues, textformat.Cues(transcript, item.AudioStartMs, item.AudioEndMs, options)...)
+	}
+	return cues, nil
+}
+
+// handleSessionWebVTT serves the captions of a live session as a WebVTT file, for
+// the subtitle track of an HLS restream. The file is rebuilt on every request, so
+// players polling it see transcripts as they complete.
+func handleSessionWebVTT(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	cues, err := openAIService.sessionManager.CaptionCues(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/vtt; charset=utf-8", []byte(textformat.WebVTT(cues)))
+}
+
+// handleSessionSRT serves the captions of a live session as a SubRip file, rebuilt on
+// every request like the WebVTT file
+func handleSessionSRT(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	cues, err := openAIService.sessionManager.CaptionCues(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/x-subrip; charset=utf-8", []byte(textformat.SRT(cues)))
+}
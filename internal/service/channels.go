@@ -0,0 +1,227 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/agc"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxInputChannels is the number of interleaved channels input_audio_format accepts,
+// e.g. the agent on the left and the customer on the right of a telephony call
+const maxInputChannels = 2
+
+// A multi-channel session runs every input channel through a stream of its own: a
+// Session the manager does not hold, with its own VAD detector, noise gate, gain
+// control and buffers. Its events go out on the session it belongs to, with the
+// channel field set, and each commit creates an item per channel that has speech.
+
+// owner returns the session a channel stream belongs to, or the session itself
+func (s *Session) owner() *Session {
+	if s.parent != nil {
+		return s.parent
+	}
+	return s
+}
+
+// channelIndex returns the input channel of a channel stream for the channel field of
+// events, nil for a mono session
+func (s *Session) channelIndex() *int {
+	if s.parent == nil {
+		return nil
+	}
+	channel := s.channel
+	return &channel
+}
+
+// speaker returns the talk-time label of the speech of a session or channel stream
+func (s *Session) speaker() string {
+	if s.parent == nil {
+		return DefaultSpeaker
+	}
+	return fmt.Sprintf("channel_%d", s.channel)
+}
+
+// vadStreams returns the streams the session's audio is VAD'd and committed on, its
+// channel streams or the session itself
+func (s *Session) vadStreams() []*Session {
+	if len(s.channels) > 0 {
+		return s.channels
+	}
+	return []*Session{s}
+}
+
+// inputChannels returns the number of interleaved channels of appended audio
+func (s *Session) inputChannels() int {
+	return max(1, s.InputAudioFormat.Channels)
+}
+
+// validateInputChannels checks the channel count of an input_audio_format
+func validateInputChannels(format string, channels int) error {
+	if channels < 0 || channels > maxInputChannels {
+		return fmt.Errorf("unsupported channel count %d, expected 1 to %d", channels, maxInputChannels)
+	}
+	if channels > 1 && format == InputAudioFormatOpus {
+		return fmt.Errorf("opus input must be mono")
+	}
+	return nil
+}
+
+// applyChannels creates a stream per input channel of a multi-channel session, or
+// removes them once the input is mono again, and brings the streams to the session's
+// VAD, denoiser, noise gate and gain control settings. Like applyTurnDetection it is
+// called with the session manager lock held from the connection's read loop.
+func applyChannels(sess *Session, cfg *config.Config) {
+	channels := sess.inputChannels()
+	if channels == 1 {
+		releaseChannels(sess)
+		return
+	}
+
+	if len(sess.channels) != channels {
+		releaseChannels(sess)
+		for channel := 0; channel < channels; channel++ {
+			sess.channels = append(sess.channels, &Session{
+				ID:          sess.ID,
+				CreatedAt:   sess.CreatedAt,
+				AudioBuffer: make([]int16, 0),
+				talkTime:    sess.talkTime,
+				parent:      sess,
+				channel:     channel,
+			})
+		}
+		sess.Logger().WithFields(logrus.Fields{
+			"component": "mg_session_ctrl",
+			"action":    "channels_created",
+			"sessionID": sess.ID,
+			"channels":  channels,
+		}).Info("Created per-channel streams for multi-channel input")
+	}
+
+	for _, stream := range sess.channels {
+		stream.InputAudioFormat = sess.InputAudioFormat
+		stream.TurnDetection = sess.TurnDetection
+		stream.NoiseReduction = sess.NoiseReduction
+		stream.denoiserPool = sess.denoiserPool
+
+		// The session's own detector holds the settings, the streams lease their own
+		switch {
+		case sess.VADDetector == nil:
+			stream.releaseVADDetector()
+			stream.IsSpeaking = false
+		case stream.vadPool != sess.vadPool:
+			stream.acquireVADDetector(sess.vadConfig)
+			stream.IsSpeaking = false
+			stream.vadResetOffset = stream.vadSamplesFed.Load()
+		}
+
+		if sess.noiseGate == nil {
+			stream.noiseGate = nil
+		} else if stream.noiseGate == nil {
+			stream.noiseGate = newNoiseGate(cfg)
+		}
+
+		if sess.gainControl == nil {
+			stream.gainControl = nil
+		} else if stream.gainControl == nil || stream.GainControl != sess.GainControl {
+			stream.gainControl = agc.New(16000, gainControlOptions(cfg, sess.GainControl))
+		}
+		stream.GainControl = sess.GainControl
+	}
+}
+
+// releaseChannels returns the VAD detectors of the session's channel streams and
+// drops the streams with their buffered audio
+func releaseChannels(sess *Session) {
+	for _, stream := range sess.channels {
+		stream.releaseVADDetector()
+	}
+	sess.channels = nil
+}
+
+// appendChannels splits interleaved audio of a multi-channel session into its
+// channels and runs each through its stream like the audio of a mono session.
+// Interim and sliding-window recognition are not available on multi-channel input.
+func (s *OpenAIService) appendChannels(session *Session, samples []int16) error {
+	channels := len(session.channels)
+	if len(samples)%channels != 0 {
+		return fmt.Errorf("audio of %d channels must hold whole frames, got %d samples", channels, len(samples))
+	}
+	split := deinterleave(samples, channels)
+
+	// Saved audio is mono, the channels are mixed down
+	if s.appConfig().Audio.Enable || session.IsDebug() {
+		if err := s.accumulateAudioForSaving(session, mixDown(split)); err != nil {
+			session.Logger().WithFields(logrus.Fields{
+				"component": "proc_audio_main",
+				"action":    "accumulate_audio_failed",
+				"sessionID": session.ID,
+				"error":     err,
+			}).Error("Failed to accumulate audio for saving")
+		}
+	}
+
+	for i, stream := range session.channels {
+		reSamples, err := s.resampleForVAD(stream, split[i])
+		if err != nil {
+			return err
+		}
+		if stream.noiseGate != nil {
+			reSamples = stream.noiseGate.Process(reSamples)
+		}
+		if stream.gainControl != nil {
+			reSamples = stream.gainControl.Process(reSamples)
+		}
+
+		if !s.usesVAD(session) {
+			stream.AudioBufferMutex.Lock()
+			stream.AudioBuffer = append(stream.AudioBuffer, reSamples...)
+			stream.AudioBufferMutex.Unlock()
+			stream.rawSamplesAppended += int64(len(reSamples))
+			continue
+		}
+
+		if err := s.vadIntegration.processSamples(stream, reSamples); err != nil {
+			session.Logger().WithFields(logrus.Fields{
+				"component": "vad",
+				"action":    "processing_error",
+				"sessionID": session.ID,
+				"channel":   i,
+				"error":     err,
+			}).Error("VAD processing error")
+		}
+		s.enforceMaxUtterance(session, stream)
+	}
+
+	session.LastActive = time.Now()
+	return nil
+}
+
+// deinterleave splits interleaved samples into one slice per channel
+func deinterleave(samples []int16, channels int) [][]int16 {
+	frames := len(samples) / channels
+	split := make([][]int16, channels)
+	for channel := range split {
+		split[channel] = make([]int16, frames)
+		for i := range frames {
+			split[channel][i] = samples[i*channels+channel]
+		}
+	}
+	return split
+}
+
+// mixDown averages the channels returned by deinterleave into a mono signal
+func mixDown(split [][]int16) []int16 {
+	mono := make([]int16, len(split[0]))
+	for i := range mono {
+		var sum int
+		for _, channel := range split {
+			sum += int(channel[i])
+		}
+		mono[i] = int16(sum / len(split))
+	}
+	return mono
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/textmerge"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults of asr.chunking
+const (
+	defaultChunkThresholdSeconds = 30
+	defaultChunkSeconds          = 10
+	defaultChunkParallel         = 4
+)
+
+// recognitionChunks returns the end offsets of the chunks the 16kHz audio of an item is
+// recognized in, nil to send it in one request. Audio longer than
+// asr.chunking.threshold_seconds is split at the ends of its speech segments.
+func (s *OpenAIService) recognitionChunks(samples int, segmentEnds []int) []int {
+	cfg := s.appConfig()
+	if cfg == nil || !cfg.ASR.Chunking.Enable || len(segmentEnds) < 2 {
+		return nil
+	}
+	threshold := cfg.ASR.Chunking.ThresholdSeconds
+	if threshold <= 0 {
+		threshold = defaultChunkThresholdSeconds
+	}
+	if float64(samples) <= threshold*16000 {
+		return nil
+	}
+	chunkSeconds := cfg.ASR.Chunking.ChunkSeconds
+	if chunkSeconds <= 0 {
+		chunkSeconds = defaultChunkSeconds
+	}
+	return splitChunks(segmentEnds, samples, int(chunkSeconds*16000))
+}
+
+// splitChunks groups consecutive speech segments into chunks of at least target samples
+// and returns the chunk end offsets, the last being samples. A tail shorter than half
+// the target joins the chunk before it.
+func splitChunks(segmentEnds []int, samples, target int) []int {
+	var chunks []int
+	start := 0
+	for _, end := range segmentEnds {
+		if end >= samples {
+			break
+		}
+		if end-start >= target {
+			chunks = append(chunks, end)
+			start = end
+		}
+	}
+	if len(chunks) > 0 && samples-start < target/2 {
+		chunks = chunks[:len(chunks)-1]
+	}
+	return append(chunks, samples)
+}
+
+// chunkedTranscript collects the transcripts of the chunks of an item as they complete
+// and sends them as deltas in order
+type chunkedTranscript struct {
+	mutex   sync.Mutex
+	results []*llm.Transcription
+	next    int    // first chunk not yet sent as a delta
+	text    string // transcript of the chunks before next
+}
+
+// transcribeChunks recognizes the chunks of audioData ending at chunkEnds in parallel,
+// at most asr.chunking.max_parallel at a time, and joins their transcripts in order.
+// The first failing chunk fails the item and cancels the others.
+func (s *OpenAIService) transcribeChunks(ctx context.Context, session *Session, itemID string, audioData []int16, chunkEnds []int, hints []string) (*llm.Transcription, error) {
+	cfg := s.appConfig()
+	parallel := cfg.ASR.Chunking.MaxParallel
+	if parallel <= 0 {
+		parallel = defaultChunkParallel
+	}
+	deltas := cfg.ASR.Chunking.Deltas
+
+	session.Logger().WithFields(logrus.Fields{
+		"component":   "audio_recogniz",
+		"action":      "chunked_recognition_started",
+		"itemID":      itemID,
+		"sessionID":   session.ID,
+		"sampleCount": len(audioData),
+		"chunks":      len(chunkEnds),
+		"parallel":    parallel,
+	}).Info("Recognizing long utterance in parallel chunks")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	endpoint := session.recognitionEndpoint(hints)
+	transcript := &chunkedTranscript{results: make([]*llm.Transcription, len(chunkEnds))}
+	slots := make(chan struct{}, parallel)
+	errs := make(chan error, len(chunkEnds))
+	var wg sync.WaitGroup
+	start := 0
+	for i, end := range chunkEnds {
+		wg.Add(1)
+		go func(i int, chunk []int16) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				return
+			}
+
+			wavData, err := s.convertToWAV(chunk)
+			if err == nil {
+				callStart := time.Now()
+				var result *llm.Transcription
+				result, err = s.callRecognitionAPI(ctx, wavData, session.ForwardedHeaders, endpoint)
+				session.recordASRCall(time.Since(callStart), err)
+				if err == nil {
+					transcript.complete(s, session, itemID, i, result, deltas)
+					return
+				}
+			}
+			errs <- fmt.Errorf("chunk %d of %d: %w", i+1, len(chunkEnds), err)
+			cancel()
+		}(i, audioData[start:end])
+		start = end
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return joinChunkTranscripts(transcript.results, chunkEnds), nil
+}
+
+// complete records the transcript of chunk i and, with deltas, sends the transcripts of
+// the chunks now complete up to the first one still missing
+func (t *chunkedTranscript) complete(s *OpenAIService, session *Session, itemID string, i int, result *llm.Transcription, deltas bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.results[i] = result
+	for ; t.next < len(t.results) && t.results[t.next] != nil; t.next++ {
+		previous := t.text
+		t.text = textmerge.Concat(previous, t.results[t.next].Text)
+		if deltas && t.text != previous {
+			s.sendChunkDelta(session, itemID, previous, t.text)
+		}
+	}
+}
+
+// sendChunkDelta sends the transcript of an item grown by a chunk from previous as
+// conversation.item.input_audio_transcription.delta
+func (s *OpenAIService) sendChunkDelta(session *Session, itemID string, previous, text string) {
+	stable, rest := textmerge.CommonPrefix(previous, text)
+	deltaEvent := &ConversationItemInputAudioTranscriptionDeltaEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeConversationItemInputAudioTranscriptionDelta,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		ItemID:       itemID,
+		ContentIndex: 0,
+		Delta:        rest,
+		Transcript:   text,
+	}
+	if session.InputAudioTranscription.DeltaMode == DeltaModeCompact {
+		deltaEvent.StablePrefix = &stable
+		deltaEvent.Transcript = ""
+	}
+	if err := s.sessionManager.SendEvent(session, deltaEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "ws_event_send ",
+			"action":    "send_chunk_delta_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Failed to send chunk transcription delta event")
+	}
+}
+
+// joinChunkTranscripts joins the transcripts of the chunks ending at chunkEnds, moving
+// the word timestamps of each chunk to its offset in the item's audio
+func joinChunkTranscripts(results []*llm.Transcription, chunkEnds []int) *llm.Transcription {
+	joined := &llm.Transcription{}
+	texts := make([]string, len(results))
+	start := 0
+	for i, result := range results {
+		texts[i] = result.Text
+		offset := float64(start) / 16000
+		for _, word := range result.Words {
+			word.Start += offset
+			word.End += offset
+			joined.Words = append(joined.Words, word)
+		}
+		if joined.Language == "" {
+			joined.Language = result.Language
+		}
+		start = chunkEnds[i]
+	}
+	joined.Text = textmerge.Concat(texts...)
+	return joined
+}
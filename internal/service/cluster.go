@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/cluster"
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// clusterElector is set when cluster mode is enabled, nil otherwise
+var clusterElector *cluster.Elector
+
+// clusterRunner serves the router only while this instance holds the leader lock
+type clusterRunner struct {
+	cfg     *config.Config
+	router  *gin.Engine
+	port    string
+	redis   *cluster.RedisClient
+	elector *cluster.Elector
+
+	mutex  sync.Mutex
+	server *http.Server
+}
+
+// runClustered blocks until the process is interrupted, serving the service port while
+// active and only the health port while standby. On the way out an active instance
+// steps down and releases the leader lock, so a standby takes over without waiting
+// for the lease to expire.
+func runClustered(r *gin.Engine, srvPort string, cfg *config.Config) {
+	ttl := time.Duration(cfg.Cluster.LeaseTTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	instanceID := cfg.Cluster.InstanceID
+	if instanceID == "" {
+		instanceID = cluster.DefaultInstanceID()
+	}
+	prefix := cfg.Cluster.KeyPrefix
+	if prefix == "" {
+		prefix = "stt"
+	}
+
+	redis := cluster.NewRedisClient(cfg.Cluster.RedisAddr, cfg.Cluster.RedisPassword, cfg.Cluster.RedisDB)
+	lock := cluster.NewRedisLeaderLock(redis, prefix+":leader", instanceID, ttl)
+
+	cr := &clusterRunner{
+		cfg:     cfg,
+		router:  r,
+		port:    srvPort,
+		redis:   redis,
+		elector: cluster.NewElector(lock, ttl),
+	}
+	cr.elector.OnElected = cr.becomeActive
+	cr.elector.OnDemoted = cr.becomeStandby
+	clusterElector = cr.elector
+
+	logger.WithFields(logrus.Fields{
+		"component":  "cluster_leader ",
+		"action":     "cluster_mode_enabled",
+		"instanceID": instanceID,
+		"redisAddr":  cfg.Cluster.RedisAddr,
+		"leaseTTL":   ttl,
+	}).Info("✔ Cluster mode enabled, waiting for leader lock")
+
+	if cfg.Cluster.HealthPort != "" {
+		go cr.serveHealth()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cr.elector.Run(ctx)
+}
+
+// becomeActive starts listening on the service port. The port is bound before
+// becomeActive returns, so a bind failure keeps this instance standby instead of
+// reporting active on the health port while nothing serves. Sessions of the previous
+// active instance are not carried over here: with a redis session_store their clients
+// resume them on this instance, see session_store.go.
+func (cr *clusterRunner) becomeActive() error {
+	if cr.cfg.SessionStore.Type != "redis" {
+		logger.WithFields(logrus.Fields{
+			"component": "cluster_leader ",
+			"action":    "sessions_not_resumable",
+		}).Warn("Without a redis session_store the sessions of the previous active instance cannot be resumed")
+	}
+
+	ln, err := net.Listen("tcp", ":"+cr.port)
+	if err != nil {
+		return fmt.Errorf("listen on service port %s: %w", cr.port, err)
+	}
+
+	server := &http.Server{Handler: cr.router}
+	cr.mutex.Lock()
+	cr.server = server
+	cr.mutex.Unlock()
+
+	logger.WithFields(logrus.Fields{
+		"component": "cluster_leader ",
+		"action":    "active_serving",
+		"port":      cr.port,
+	}).Info("✔ Active instance serving")
+
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithFields(logrus.Fields{
+				"component": "cluster_leader ",
+				"action":    "serve_failed",
+				"error":     err,
+			}).Error("Failed to serve on service port")
+		}
+	}()
+	return nil
+}
+
+// becomeStandby stops serving the service port and ends the live sessions. Shutdown
+// leaves the hijacked WebSocket connections open, without ending their sessions the
+// instance would keep transcribing next to the new active instance.
+func (cr *clusterRunner) becomeStandby() {
+	cr.mutex.Lock()
+	server := cr.server
+	cr.server = nil
+	cr.mutex.Unlock()
+
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+
+	if openAIService != nil {
+		ended := openAIService.evictAllSessions(closeReasonDemoted)
+		logger.WithFields(logrus.Fields{
+			"component": "cluster_leader ",
+			"action":    "sessions_ended_on_demotion",
+			"count":     ended,
+		}).Info("Ended live sessions after stepping down")
+	}
+}
+
+// serveHealth reports the cluster role on the health port: 200 when active and 503 when
+// standby, so load balancers only route to the active instance
+func (cr *clusterRunner) serveHealth() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/health", func(w http.ResponseWriter, _ *http.Request) {
+		role := cr.elector.Role()
+		status := http.StatusOK
+		if role != cluster.RoleActive {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":    http.StatusText(status),
+			"role":      role,
+			"timestamp": time.Now().Unix(),
+		})
+	})
+
+	if err := http.ListenAndServe(":"+cr.cfg.Cluster.HealthPort, mux); err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "cluster_leader ",
+			"action":    "health_listen_failed",
+			"error":     err,
+		}).Error("Failed to listen on cluster health port")
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// configReloadMutex serializes reloads triggered by SIGHUP and the admin API
+var configReloadMutex sync.Mutex
+
+// appConfig returns the configuration in effect. Code reading it at the time of use
+// follows reloads; settings copied when the service or a session starts do not.
+func (s *OpenAIService) appConfig() *config.Config {
+	return s.sessionManager.Config()
+}
+
+// applyASRSettings sets the global backend settings of ASR calls
+func applyASRSettings(cfg *config.Config) {
+	llm.SetAsrBaseURL(cfg.ASR.BaseURL)
+	llm.SetAsrApiKey(cfg.ASR.APIKey)
+	llm.SetAsrModel(cfg.ASR.Model)
+	llm.SetAsrWordConfidence(cfg.ASR.WordConfidence)
+	llm.SetAsrHintsField(cfg.ASR.Hints.Field)
+}
+
+// ReloadConfig re-reads the configuration file and applies it without a restart. VAD
+// and denoiser settings, session defaults, pipelines and noise gate apply to new
+// sessions; ASR backend, audio saving, conversation limits, final flush and log levels
+// to running ones too. Settings only read at startup keep their running value, their
+// names are returned.
+func (s *OpenAIService) ReloadConfig() ([]string, error) {
+	configReloadMutex.Lock()
+	defer configReloadMutex.Unlock()
+
+	cfg, err := config.LoadConfig(s.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	restart := s.keepStartupSettings(cfg, s.appConfig())
+	s.sessionManager.cfg.Store(cfg)
+	applyASRSettings(cfg)
+	if err := logger.SetComponentLevels(cfg.Logging.Level, cfg.Logging.Components); err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "log_levels_invalid",
+			"error":     err,
+		}).Warn("Invalid log levels in the reloaded configuration, keeping the current ones")
+	}
+
+	fields := logrus.Fields{
+		"component": "svc_openai_api ",
+		"action":    "config_reloaded",
+		"path":      s.configPath,
+	}
+	if len(restart) > 0 {
+		fields["restartRequired"] = restart
+	}
+	logger.WithFields(fields).Info("Configuration reloaded")
+	return restart, nil
+}
+
+// keepStartupSettings carries the settings read only when the service starts over from
+// the running configuration, and returns the names of those the file changed
+func (s *OpenAIService) keepStartupSettings(cfg, running *config.Config) []string {
+	var changed []string
+	keepSetting(&changed, "service_port", &cfg.ServicePort, running.ServicePort)
+	keepSetting(&changed, "asr.mode", &cfg.ASR.Mode, running.ASR.Mode)
+	keepSetting(&changed, "asr.local", &cfg.ASR.Local, running.ASR.Local)
+	keepSetting(&changed, "asr.forward_headers", &cfg.ASR.ForwardHeaders, running.ASR.ForwardHeaders)
+	keepSetting(&changed, "asr.base_urls", &cfg.ASR.BaseURLs, running.ASR.BaseURLs)
+	keepSetting(&changed, "asr.balancing", &cfg.ASR.Balancing, running.ASR.Balancing)
+	keepSetting(&changed, "asr.streaming", &cfg.ASR.Streaming, running.ASR.Streaming)
+	keepSetting(&changed, "asr.concurrency", &cfg.ASR.Concurrency, running.ASR.Concurrency)
+	if s.asrBackends != nil {
+		// The replicas are balanced from the list built at startup
+		keepSetting(&changed, "asr.base_url", &cfg.ASR.BaseURL, running.ASR.BaseURL)
+	}
+	keepSetting(&changed, "vad.enable", &cfg.Vad.Enable, running.Vad.Enable)
+	keepSetting(&changed, "cluster", &cfg.Cluster, running.Cluster)
+	keepSetting(&changed, "limits", &cfg.Limits, running.Limits)
+	keepSetting(&changed, "rate_limits", &cfg.RateLimits, running.RateLimits)
+	keepSetting(&changed, "auth", &cfg.Auth, running.Auth)
+	keepSetting(&changed, "session_gc", &cfg.SessionGC, running.SessionGC)
+	keepSetting(&changed, "tracing", &cfg.Tracing, running.Tracing)
+	keepSetting(&changed, "experiment", &cfg.Experiment, running.Experiment)
+	keepSetting(&changed, "long_poll", &cfg.LongPoll, running.LongPoll)
+	keepSetting(&changed, "session_store", &cfg.SessionStore, running.SessionStore)
+	keepSetting(&changed, "resume", &cfg.Resume, running.Resume)
+	keepSetting(&changed, "outbound", &cfg.Outbound, running.Outbound)
+	keepSetting(&changed, "ingest", &cfg.Ingest, running.Ingest)
+	keepSetting(&changed, "sip", &cfg.SIP, running.SIP)
+	keepSetting(&changed, "storage", &cfg.Storage, running.Storage)
+	keepSetting(&changed, "event_sink", &cfg.EventSink, running.EventSink)
+	// The store is opened at startup, its retention is read on every cleanup pass
+	keepSetting(&changed, "transcript_store.type", &cfg.TranscriptStore.Type, running.TranscriptStore.Type)
+	keepSetting(&changed, "transcript_store.dsn", &cfg.TranscriptStore.DSN, running.TranscriptStore.DSN)
+	keepSetting(&changed, "transcript_store.queue_size", &cfg.TranscriptStore.QueueSize, running.TranscriptStore.QueueSize)
+	keepSetting(&changed, "audio.retention.interval_seconds", &cfg.Audio.Retention.IntervalSeconds, running.Audio.Retention.IntervalSeconds)
+	return changed
+}
+
+// keepSetting restores a setting of the reloaded configuration to its running value,
+// recording its name when the two differ
+func keepSetting[T any](changed *[]string, name string, reloaded *T, running T) {
+	if !reflect.DeepEqual(*reloaded, running) {
+		*changed = append(*changed, name)
+		*reloaded = running
+	}
+}
+
+// handleConfigReload reloads the configuration file, like SIGHUP
+func handleConfigReload(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	restart, err := openAIService.ReloadConfig()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if restart == nil {
+		restart = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"reloaded": true, "restart_required": restart})
+}
+
+// ReloadConfig reloads the configuration of the running service from its file. Before
+// the service started only the log levels are read from configPath.
+func ReloadConfig(configPath string) ([]string, error) {
+	if openAIService == nil {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+		return nil, logger.SetComponentLevels(cfg.Logging.Level, cfg.Logging.Components)
+	}
+	return openAIService.ReloadConfig()
+}
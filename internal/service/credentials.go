@@ -0,0 +1,54 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CredentialPropagator selects which credentials of the WebSocket upgrade request are
+// forwarded to the ASR backend for every recognition call of the session
+type CredentialPropagator interface {
+	Propagate(r *http.Request) http.Header
+}
+
+// HeaderAllowlistPropagator forwards the configured request headers verbatim
+type HeaderAllowlistPropagator struct {
+	headers []string
+}
+
+// NewHeaderAllowlistPropagator creates a propagator forwarding the given header names
+func NewHeaderAllowlistPropagator(headers []string) *HeaderAllowlistPropagator {
+	allowed := make([]string, 0, len(headers))
+	for _, header := range headers {
+		header = strings.TrimSpace(header)
+		if header != "" {
+			allowed = append(allowed, http.CanonicalHeaderKey(header))
+		}
+	}
+	return &HeaderAllowlistPropagator{headers: allowed}
+}
+
+// Propagate returns the allowed headers present on the request, or nil if none are set
+func (p *HeaderAllowlistPropagator) Propagate(r *http.Request) http.Header {
+	if r == nil {
+		return nil
+	}
+
+	var forwarded http.Header
+	for _, header := range p.headers {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+		if forwarded == nil {
+			forwarded = make(http.Header)
+		}
+		forwarded[header] = append([]string(nil), values...)
+	}
+	return forwarded
+}
+
+// SetCredentialPropagator replaces the credential propagator used for new sessions
+func (s *OpenAIService) SetCredentialPropagator(propagator CredentialPropagator) {
+	s.credentialPropagator = propagator
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdh"
+	"encoding/json"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/eventsink"
+	"github.com/go-restream/stt/pkg/jwe"
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultEventSinkQueueSize = 1000
+	defaultEventTopicPrefix   = "stt."
+)
+
+// EventTypeSessionEnded is published to the event sink when a session ends; clients
+// never receive it, their connection is gone
+const EventTypeSessionEnded = "session.ended"
+
+// defaultSinkEvents are published when event_sink.events is empty: the session
+// lifecycle and the final transcription results
+var defaultSinkEvents = []string{
+	EventTypeSessionCreated,
+	EventTypeSessionUpdated,
+	EventTypeSessionEnded,
+	EventTypeConversationItemInputAudioTranscriptionCompleted,
+	EventTypeConversationItemInputAudioTranscriptionFailed,
+}
+
+// SessionEndedEvent is the session.ended event of the event sink
+type SessionEndedEvent struct {
+	BaseEvent
+	Reason     string    `json:"reason"` // one of the SessionEnd* reasons
+	Tenant     string    `json:"tenant,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	EndedAt    time.Time `json:"ended_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// lifecycleSinkEvents carry no audio or transcript and stay JSON when
+// event_sink.encryption names a recipient; every other event is sealed
+var lifecycleSinkEvents = []string{
+	EventTypeSessionCreated,
+	EventTypeSessionUpdated,
+	EventTypeSessionEnded,
+}
+
+// EventSinkStats counts the events handed to the broker
+type EventSinkStats struct {
+	Published int64 `json:"published"`
+	Dropped   int64 `json:"dropped"` // queue full
+	Failed    int64 `json:"failed"`
+}
+
+// sinkMessage is an event waiting for publication
+type sinkMessage struct {
+	topic string
+	key   string // session ID, keeps the events of a session on one Kafka partition
+	data  []byte
+}
+
+// eventPublisher publishes server events to Kafka or NATS in the background, so a slow
+// broker never holds up a session. Events arriving while the queue is full are dropped.
+type eventPublisher struct {
+	publisher   eventsink.Publisher
+	topicPrefix string
+	topics      map[string]string
+	events      []string // nil publishes every event
+	queue       chan sinkMessage
+	done        chan struct{}
+	recipient   *ecdh.PublicKey // seals all but the lifecycle events, nil publishes them as JSON
+	keyID       string
+
+	mutex  sync.Mutex
+	closed bool
+
+	published atomic.Int64
+	dropped   atomic.Int64
+	failed    atomic.Int64
+}
+
+// newEventPublisher creates the publisher of event_sink, nil when it is misconfigured
+func newEventPublisher(cfg *config.Config) *eventPublisher {
+	settings := cfg.EventSink
+	clientID := settings.ClientID
+	if clientID == "" {
+		clientID = "stt"
+	}
+	var recipient *ecdh.PublicKey
+	if settings.Encryption.RecipientKey != "" {
+		key, err := jwe.ParsePublicKey(settings.Encryption.RecipientKey)
+		if err != nil {
+			logger.WithFields(logrus.Fields{
+				"component": "svc_openai_api ",
+				"action":    "event_sink_disabled",
+				"type":      settings.Type,
+				"error":     err,
+			}).Error("Invalid event_sink.encryption.recipient_key, events are not published")
+			return nil
+		}
+		recipient = key
+	}
+	publisher, err := eventsink.New(eventsink.Options{
+		Type:     settings.Type,
+		Brokers:  settings.Brokers,
+		ClientID: clientID,
+		Acks:     settings.Acks,
+		Username: settings.Username,
+		Password: settings.Password,
+		Token:    settings.Token,
+	})
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "event_sink_disabled",
+			"type":      settings.Type,
+			"error":     err,
+		}).Error("Invalid event sink configuration, events are not published")
+		return nil
+	}
+
+	return startEventPublisher(cfg, publisher, recipient)
+}
+
+// startEventPublisher publishes the events selected by event_sink through publisher,
+// sealed for recipient unless it is nil
+func startEventPublisher(cfg *config.Config, publisher eventsink.Publisher, recipient *ecdh.PublicKey) *eventPublisher {
+	settings := cfg.EventSink
+	topicPrefix := settings.TopicPrefix
+	if topicPrefix == "" {
+		topicPrefix = defaultEventTopicPrefix
+	}
+	events := settings.Events
+	if len(events) == 0 {
+		events = defaultSinkEvents
+	} else if slices.Contains(events, "*") {
+		events = nil
+	}
+	queueSize := settings.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultEventSinkQueueSize
+	}
+
+	p := &eventPublisher{
+		publisher:   publisher,
+		topicPrefix: topicPrefix,
+		topics:      settings.Topics,
+		events:      events,
+		queue:       make(chan sinkMessage, queueSize),
+		done:        make(chan struct{}),
+		recipient:   recipient,
+		keyID:       settings.Encryption.KeyID,
+	}
+	go p.run()
+
+	logger.WithFields(logrus.Fields{
+		"component": "svc_openai_api ",
+		"action":    "event_sink_enabled",
+		"type":      settings.Type,
+		"brokers":   settings.Brokers,
+		"events":    events,
+		"encrypted": recipient != nil,
+	}).Info("Events are published to the event sink")
+	return p
+}
+
+// topic returns the topic of an event type
+func (p *eventPublisher) topic(eventType string) string {
+	if topic, ok := p.topics[eventType]; ok {
+		return topic
+	}
+	return p.topicPrefix + eventType
+}
+
+// publish queues an encoded event of a session when its type is published
+func (p *eventPublisher) publish(session *Session, eventType string, data []byte) {
+	if p.events != nil && !slices.Contains(p.events, eventType) {
+		return
+	}
+	if p.recipient != nil && !slices.Contains(lifecycleSinkEvents, eventType) {
+		token, err := jwe.Encrypt(data, p.recipient, p.keyID, "application/json")
+		if err != nil {
+			p.failed.Add(1)
+			logger.WithFields(logrus.Fields{
+				"component": "svc_openai_api ",
+				"action":    "event_sink_encrypt_failed",
+				"sessionID": session.ID,
+				"type":      eventType,
+				"error":     err,
+			}).Warn("Failed to encrypt event, not published")
+			return
+		}
+		data = []byte(token)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return
+	}
+	select {
+	case p.queue <- sinkMessage{topic: p.topic(eventType), key: session.ID, data: data}:
+	default:
+		if p.dropped.Add(1) == 1 {
+			logger.WithFields(logrus.Fields{
+				"component": "svc_openai_api ",
+				"action":    "event_sink_dropped",
+				"sessionID": session.ID,
+				"type":      eventType,
+			}).Warn("Event sink queue is full, dropping events")
+		}
+	}
+}
+
+// sessionEnded publishes the session.ended event of a session
+func (p *eventPublisher) sessionEnded(session *Session, reason string) {
+	now := time.Now()
+	event := &SessionEndedEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeSessionEnded,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		Reason:     reason,
+		Tenant:     session.Tenant,
+		CreatedAt:  session.CreatedAt,
+		EndedAt:    now,
+		DurationMs: now.Sub(session.CreatedAt).Milliseconds(),
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	p.publish(session, EventTypeSessionEnded, data)
+}
+
+func (p *eventPublisher) run() {
+	defer close(p.done)
+
+	for message := range p.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := p.publisher.Publish(ctx, message.topic, []byte(message.key), message.data)
+		cancel()
+		if err != nil {
+			p.failed.Add(1)
+			logger.WithFields(logrus.Fields{
+				"component": "svc_openai_api ",
+				"action":    "event_sink_publish_failed",
+				"sessionID": message.key,
+				"topic":     message.topic,
+				"error":     err,
+			}).Warn("Failed to publish event")
+			continue
+		}
+		p.published.Add(1)
+	}
+}
+
+// close publishes the queued events, waiting at most timeout, and disconnects
+func (p *eventPublisher) close(timeout time.Duration) {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	p.mutex.Unlock()
+
+	select {
+	case <-p.done:
+	case <-time.After(timeout):
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "event_sink_flush_timeout",
+			"pending":   len(p.queue),
+		}).Warn("Events still queued at shutdown are lost")
+	}
+	p.publisher.Close()
+}
+
+// Stats returns the publication counters
+func (p *eventPublisher) Stats() EventSinkStats {
+	return EventSinkStats{
+		Published: p.published.Load(),
+		Dropped:   p.dropped.Load(),
+		Failed:    p.failed.Load(),
+	}
+}
+
+// eventType returns the type of a server event
+func eventType(event interface{}) string {
+	if e, ok := event.(interface{ baseEvent() BaseEvent }); ok {
+		return e.baseEvent().Type
+	}
+	return ""
+}
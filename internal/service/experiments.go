@@ -0,0 +1,202 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/experiment"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// experimentRunner assigns sessions to the variants of the configured experiment and
+// records the recognition outcomes of each variant for comparison
+type experimentRunner struct {
+	name     string
+	variants []config.ExperimentVariant
+	weights  []experiment.Variant
+	recorder *experiment.Recorder
+}
+
+// ExperimentVariantReport is the outcome of a variant with its configuration
+type ExperimentVariantReport struct {
+	experiment.Summary
+	Weight   float64 `json:"weight"`
+	Pipeline string  `json:"pipeline,omitempty"`
+}
+
+// ExperimentReport compares the variants of the running experiment, the first being
+// the control
+type ExperimentReport struct {
+	Experiment string                    `json:"experiment"`
+	Variants   []ExperimentVariantReport `json:"variants"`
+}
+
+// newExperimentRunner validates the experiment section of the configuration: variant
+// names must be unique, their pipelines configured and their weights sum to at most 100
+func newExperimentRunner(cfg *config.Config) (*experimentRunner, error) {
+	section := cfg.Experiment
+	if section.Name == "" {
+		return nil, fmt.Errorf("experiment name is required")
+	}
+	if len(section.Variants) == 0 {
+		return nil, fmt.Errorf("experiment %s has no variants", section.Name)
+	}
+
+	runner := &experimentRunner{name: section.Name, variants: section.Variants}
+	names := make([]string, 0, len(section.Variants))
+	seen := make(map[string]bool, len(section.Variants))
+	var total float64
+	for _, variant := range section.Variants {
+		if variant.Name == "" || seen[variant.Name] {
+			return nil, fmt.Errorf("experiment %s: variant names must be set and unique", section.Name)
+		}
+		if variant.Weight < 0 {
+			return nil, fmt.Errorf("experiment %s: variant %s has a negative weight", section.Name, variant.Name)
+		}
+		if variant.Pipeline != "" {
+			if _, err := lookupPipeline(cfg, variant.Pipeline); err != nil {
+				return nil, fmt.Errorf("experiment %s: variant %s: %v", section.Name, variant.Name, err)
+			}
+		}
+		seen[variant.Name] = true
+		total += variant.Weight
+		names = append(names, variant.Name)
+		runner.weights = append(runner.weights, experiment.Variant{Name: variant.Name, Weight: variant.Weight})
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("experiment %s: variant weights sum to %.1f%%, more than 100%%", section.Name, total)
+	}
+
+	runner.recorder = experiment.NewRecorder(names)
+	return runner, nil
+}
+
+// assignExperiment puts a session into a variant of the experiment and applies the
+// variant's pipeline. Sessions are keyed by API key, or tenant, so a client sees the
+// same variant on every connection; anonymous sessions are keyed by session ID.
+func (s *OpenAIService) assignExperiment(session *Session) {
+	if s.experiments == nil {
+		return
+	}
+
+	key := session.ID
+	if session.APIKey != "" {
+		key = session.APIKey
+	} else if session.Tenant != "" {
+		key = session.Tenant
+	}
+	index := experiment.Assign(s.experiments.name, key, s.experiments.weights)
+	if index < 0 {
+		return
+	}
+	variant := s.experiments.variants[index]
+
+	s.sessionManager.UpdateSession(session.ID, func(sess *Session) {
+		if variant.Pipeline != "" {
+			if err := applyPipeline(sess, s.appConfig(), variant.Pipeline); err != nil {
+				return
+			}
+		}
+		sess.Experiment = s.experiments.name
+		sess.Variant = variant.Name
+	})
+
+	session.Logger().WithFields(logrus.Fields{
+		"component":  "mg_session_ctrl",
+		"action":     "experiment_assigned",
+		"sessionID":  session.ID,
+		"experiment": s.experiments.name,
+		"variant":    session.Variant,
+		"pipeline":   variant.Pipeline,
+	}).Info("Session assigned to experiment variant")
+}
+
+// leaveExperiment takes a session out of its experiment once the client selects a
+// pipeline itself, its outcomes would no longer reflect the variant. It is called with
+// the session manager lock held.
+func leaveExperiment(sess *Session) {
+	if sess.Variant == "" {
+		return
+	}
+	sess.Logger().WithFields(logrus.Fields{
+		"component":  "mg_session_ctrl",
+		"action":     "experiment_left",
+		"sessionID":  sess.ID,
+		"experiment": sess.Experiment,
+		"variant":    sess.Variant,
+	}).Info("Session left experiment after selecting a pipeline")
+	sess.Experiment = ""
+	sess.Variant = ""
+}
+
+// recordExperimentItem records the recognition of an item for the session's variant.
+// Confidence is the mean word confidence and quality the audio quality score, -1 when
+// not measured.
+func (s *OpenAIService) recordExperimentItem(session *Session, latency time.Duration, failed bool, confidence, quality float64) {
+	if s.experiments == nil || session.Variant == "" {
+		return
+	}
+	s.experiments.recorder.RecordItem(session.Variant, latency, failed, confidence, quality)
+}
+
+// recordExperimentSession records the end of a session with the audio it received
+func (s *OpenAIService) recordExperimentSession(session *Session) {
+	if s.experiments == nil || session.Variant == "" {
+		return
+	}
+	audio := time.Duration(session.vadSamplesFed.Load()) * time.Second / time.Duration(s.sessionManager.vadSampleRate())
+	s.experiments.recorder.RecordSession(session.Variant, audio)
+}
+
+// meanWordConfidence returns the mean confidence of the words that carry one, or -1
+func meanWordConfidence(words []llm.TranscriptionWord) float64 {
+	var sum float64
+	scored := 0
+	for _, word := range words {
+		if word.Confidence >= 0 {
+			sum += word.Confidence
+			scored++
+		}
+	}
+	if scored == 0 {
+		return -1
+	}
+	return sum / float64(scored)
+}
+
+// ExperimentReport returns the comparison of the experiment's variants, false when no
+// experiment is running
+func (s *OpenAIService) ExperimentReport() (ExperimentReport, bool) {
+	if s.experiments == nil {
+		return ExperimentReport{}, false
+	}
+	report := ExperimentReport{Experiment: s.experiments.name}
+	for i, summary := range s.experiments.recorder.Summaries() {
+		report.Variants = append(report.Variants, ExperimentVariantReport{
+			Summary:  summary,
+			Weight:   s.experiments.variants[i].Weight,
+			Pipeline: s.experiments.variants[i].Pipeline,
+		})
+	}
+	return report, true
+}
+
+// handleExperimentReport returns the latency and accuracy of the experiment's variants
+func handleExperimentReport(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	report, ok := openAIService.ExperimentReport()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no experiment configured"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
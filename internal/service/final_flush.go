@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/audioquality"
+	"github.com/go-restream/stt/pkg/jwe"
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/textformat"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultFinalFlushTimeout = 30 * time.Second
+
+// FinalTranscript is the result of transcribing the audio a client left uncommitted
+// when it disconnected, delivered to the configured webhook and/or directory
+type FinalTranscript struct {
+	SessionID    string                  `json:"session_id"`
+	Tier         string                  `json:"tier,omitempty"`
+	Variant      string                  `json:"variant,omitempty"` // experiment variant
+	Transcript   string                  `json:"transcript"`
+	Direction    textformat.Direction    `json:"direction,omitempty"` // base direction of the transcript
+	Channel      *int                    `json:"channel,omitempty"`   // input channel of multi-channel sessions
+	AudioStartMs int64                   `json:"audio_start_ms"`
+	DurationMs   int64                   `json:"duration_ms"`
+	Quality      *audioquality.Metrics   `json:"quality,omitempty"`
+	Words        []llm.TranscriptionWord `json:"words,omitempty"` // per-word confidence, see asr.word_confidence
+	Error        string                  `json:"error,omitempty"`
+	CreatedAt    time.Time               `json:"created_at"`
+}
+
+// finalFlush collects the speech left in the session's VAD buffers after the client
+// disconnected and transcribes it in the background, one transcript per channel of
+// multi-channel sessions. It must run before the session is deleted.
+func (s *OpenAIService) finalFlush(session *Session) {
+	if cfg := s.appConfig(); cfg == nil || !cfg.FinalFlush.Enable {
+		return
+	}
+
+	if s.vadIntegration != nil {
+		s.vadIntegration.Flush(session.ID)
+	}
+
+	for _, source := range session.vadStreams() {
+		s.finalFlushStream(session, source)
+	}
+}
+
+// finalFlushStream starts the final flush of the VAD buffer of source, the session or
+// one of its channel streams
+func (s *OpenAIService) finalFlushStream(session *Session, source *Session) {
+	buffer, _ := source.commitAudio(false)
+	if len(buffer) == 0 {
+		return
+	}
+	source.clearCommitAudio(false)
+
+	session.Logger().WithFields(logrus.Fields{
+		"component":   "proc_audio_main",
+		"action":      "final_flush_started",
+		"sessionID":   session.ID,
+		"sampleCount": len(buffer),
+	}).Info("Client disconnected with uncommitted speech, running final flush")
+
+	result := FinalTranscript{
+		SessionID:    session.ID,
+		Tier:         session.Tier,
+		Variant:      session.Variant,
+		Channel:      source.channelIndex(),
+		AudioStartMs: source.takeUtteranceStart(),
+		DurationMs:   int64(len(buffer)) * 1000 / 16000,
+	}
+	headers := session.ForwardedHeaders
+	endpoint := session.ASREndpoint
+	format := s.sessionManager.snapshotItemSettings(session).format
+
+	go s.runFinalFlush(result, buffer, headers, endpoint, format)
+}
+
+func (s *OpenAIService) runFinalFlush(result FinalTranscript, buffer []int16, headers http.Header, endpoint *llm.Endpoint, format textformat.Options) {
+	quality := audioquality.Analyze(buffer, 16000)
+	result.Quality = &quality
+
+	wavData, err := s.convertToWAV(buffer)
+	if err == nil {
+		var transcription *llm.Transcription
+		transcription, err = s.callRecognitionAPI(context.Background(), wavData, headers, endpoint)
+		if err == nil {
+			result.Transcript = textformat.Apply(transcription.Text, format)
+			result.Direction = textformat.DetectDirection(result.Transcript)
+			result.Words = transcription.Words
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.CreatedAt = time.Now()
+
+	fields := logrus.Fields{
+		"component": "proc_audio_main",
+		"action":    "final_flush_completed",
+		"sessionID": result.SessionID,
+		"text":      result.Transcript,
+	}
+	if err != nil {
+		fields["error"] = err
+	}
+	logger.WithFields(fields).Info("Final flush recognition finished")
+
+	cfg := s.appConfig().FinalFlush
+	if store, prefix, ok := s.transcriptStore(result.SessionID, result.CreatedAt); ok {
+		if err := saveFinalTranscript(store, prefix, result, cfg.SaveEncryption); err != nil {
+			logger.WithFields(logrus.Fields{
+				"component": "proc_audio_main",
+				"action":    "final_flush_save_failed",
+				"sessionID": result.SessionID,
+				"error":     err,
+			}).Error("Failed to save final flush transcript")
+		}
+	}
+	if cfg.WebhookURL != "" {
+		timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+		if timeout <= 0 {
+			timeout = defaultFinalFlushTimeout
+		}
+		if err := postFinalTranscript(cfg.WebhookURL, timeout, result, cfg.WebhookEncryption); err != nil {
+			logger.WithFields(logrus.Fields{
+				"component": "proc_audio_main",
+				"action":    "final_flush_webhook_failed",
+				"sessionID": result.SessionID,
+				"error":     err,
+			}).Error("Failed to deliver final flush transcript to webhook")
+		}
+	}
+}
+
+func saveFinalTranscript(store ObjectStore, prefix string, result FinalTranscript, encryption config.PayloadEncryption) error {
+	base := "final_" + result.SessionID
+	if result.Channel != nil {
+		base += fmt.Sprintf("_ch%d", *result.Channel)
+	}
+	name, contentType := base+".json", "application/json"
+	if encryption.RecipientKey != "" {
+		name, contentType = base+".jwe", "application/jose"
+	}
+	if err := validRecordingID(result.SessionID); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript: %v", err)
+	}
+	if data, _, err = encryptTranscript(data, encryption); err != nil {
+		return err
+	}
+	return store.Put(context.Background(), prefix+name, data, contentType)
+}
+
+func postFinalTranscript(url string, timeout time.Duration, result FinalTranscript, encryption config.PayloadEncryption) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript: %v", err)
+	}
+	data, contentType, err := encryptTranscript(data, encryption)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// encryptTranscript encrypts a JSON transcript for the recipient of the destination as
+// a JWE compact token, and returns the payload with its content type. Without a
+// recipient key the JSON is returned unchanged.
+func encryptTranscript(data []byte, encryption config.PayloadEncryption) ([]byte, string, error) {
+	if encryption.RecipientKey == "" {
+		return data, "application/json", nil
+	}
+
+	recipient, err := jwe.ParsePublicKey(encryption.RecipientKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid recipient_key: %v", err)
+	}
+	token, err := jwe.Encrypt(data, recipient, encryption.KeyID, "application/json")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt transcript: %v", err)
+	}
+	return []byte(token), "application/jose", nil
+}
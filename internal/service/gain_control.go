@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/agc"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GainControlConfig is the input_audio_gain_control of session.update. Zero values
+// take the agc settings of the server.
+type GainControlConfig struct {
+	Enabled    bool    `json:"enabled"`
+	TargetDBFS float64 `json:"target_dbfs,omitempty"` // RMS level speech is brought to, below 0
+	MaxGainDB  float64 `json:"max_gain_db,omitempty"`
+	AttackMs   float64 `json:"attack_ms,omitempty"`
+	ReleaseMs  float64 `json:"release_ms,omitempty"`
+}
+
+// validate rejects levels above full scale and negative settings
+func (c *GainControlConfig) validate() error {
+	if c.TargetDBFS > 0 {
+		return fmt.Errorf("target_dbfs %v must be below 0", c.TargetDBFS)
+	}
+	if c.MaxGainDB < 0 || c.AttackMs < 0 || c.ReleaseMs < 0 {
+		return fmt.Errorf("max_gain_db, attack_ms and release_ms must not be negative")
+	}
+	return nil
+}
+
+// gainControlOptions returns the agc settings of cfg with the overrides of a session
+func gainControlOptions(cfg *config.Config, c *GainControlConfig) agc.Options {
+	settings := cfg.AGC
+	opts := agc.Options{
+		TargetDBFS:     settings.TargetDBFS,
+		MaxGainDB:      settings.MaxGainDB,
+		AttackMs:       settings.AttackMs,
+		ReleaseMs:      settings.ReleaseMs,
+		NoiseFloorDBFS: settings.NoiseFloorDBFS,
+		PeakDBFS:       settings.PeakDBFS,
+	}
+	if c == nil {
+		return opts
+	}
+	if c.TargetDBFS < 0 {
+		opts.TargetDBFS = c.TargetDBFS
+	}
+	if c.MaxGainDB > 0 {
+		opts.MaxGainDB = c.MaxGainDB
+	}
+	if c.AttackMs > 0 {
+		opts.AttackMs = c.AttackMs
+	}
+	if c.ReleaseMs > 0 {
+		opts.ReleaseMs = c.ReleaseMs
+	}
+	return opts
+}
+
+// applyGainControl creates or removes the gain control of a session as its
+// GainControl asks. A new gain control starts at unity gain.
+func applyGainControl(sess *Session, cfg *config.Config) {
+	c := sess.GainControl
+	if c == nil {
+		return
+	}
+
+	opts := gainControlOptions(cfg, c)
+	sess.gainControl = nil
+	if c.Enabled {
+		sess.gainControl = agc.New(16000, opts)
+	}
+
+	sess.Logger().WithFields(logrus.Fields{
+		"component":  "mg_session_ctrl",
+		"action":     "gain_control_applied",
+		"sessionID":  sess.ID,
+		"enabled":    c.Enabled,
+		"targetDBFS": opts.TargetDBFS,
+		"maxGainDB":  opts.MaxGainDB,
+	}).Info("Session gain control updated")
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-restream/stt/pkg/errcode"
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// sessionLease is what a session holds of its client's quotas. It is released when
+// the session ends, on whichever transport serves it by then.
+type sessionLease struct {
+	principal *Principal
+	rateKey   string
+	rateIP    string
+	rateOpen  bool
+}
+
+// releaseLease gives the quotas of a session back
+func (s *OpenAIService) releaseLease(lease *sessionLease) {
+	if lease.rateOpen {
+		s.rateLimiter.closeSession(lease.rateKey, lease.rateIP)
+	}
+	if lease.principal != nil {
+		s.sessionQuota.release(lease.principal)
+	}
+}
+
+// newSessionToken creates the resume token of a session
+func newSessionToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// attachTransport makes the caller's connection the one serving the session and
+// returns its generation, the caller holds the session mutex. A transport whose
+// generation is no longer current has handed the session off.
+func (session *Session) attachTransport() int64 {
+	session.transport++
+	return session.transport
+}
+
+// detachTransport ends the session when the transport of generation gen, which is
+// going away, still serves it, and reports whether it did. A session handed off
+// lives on.
+func (s *OpenAIService) detachTransport(session *Session, gen int64, reason string) bool {
+	session.mutex.Lock()
+	current := session.transport == gen && !session.ended
+	if current {
+		session.ended = true
+	}
+	if session.evicted {
+		reason = SessionEndEvicted
+	}
+	session.mutex.Unlock()
+
+	if current {
+		s.endSession(session, reason)
+	}
+	return current
+}
+
+// endSession releases everything a session holds once its client is gone. Speech left
+// uncommitted is flushed before the session is removed, which cancels the recognitions
+// still in flight.
+func (s *OpenAIService) endSession(session *Session, reason string) {
+	s.recordExperimentSession(session)
+	s.abandonItemTrace(session, "disconnected")
+	s.finalFlush(session)
+	session.interim.take() // abandons a recognition stream still open
+	s.sessionManager.sendSessionAnalytics(session)
+	s.sessionManager.drainOutbound(session, time.Second)
+	s.sessionManager.RemoveSession(session.ID, reason)
+	if !session.waitTasks(sessionTaskTimeout) {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "mg_session_ctrl",
+			"action":    "session_tasks_pending",
+			"sessionID": session.ID,
+		}).Warn("Session goroutines still running after the session ended")
+	}
+	s.forgetStoredSession(session, reason)
+	logSessionBandwidth(session)
+	if session.lease != nil {
+		s.releaseLease(session.lease)
+	}
+}
+
+// resumeToken returns the resume token of a handoff request, from the session token
+// header or, as browsers cannot set WebSocket headers, the resume_token parameter
+func resumeToken(c *gin.Context) string {
+	if token := c.GetHeader(sessionTokenHeader); token != "" {
+		return token
+	}
+	return c.Query("resume_token")
+}
+
+// resumableSession returns the live session a handoff request names when its resume
+// token matches, writing the error response otherwise
+func (s *OpenAIService) resumableSession(c *gin.Context, sessionID string) (*Session, bool) {
+	session, exists := s.sessionManager.GetSession(sessionID)
+	if !exists {
+		openAIError(c, http.StatusNotFound, "invalid_request_error", "session not found", "resume")
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(resumeToken(c)), []byte(session.ResumeToken)) != 1 {
+		openAIError(c, http.StatusUnauthorized, "invalid_request_error", "invalid resume token", "resume_token")
+		return nil, false
+	}
+	return session, true
+}
+
+// resumeOnWebSocket moves a long-poll session onto the WebSocket of the request. The
+// events the client has not polled yet are sent first; the conversation, the audio
+// buffers and the quotas the session holds carry over.
+func (s *OpenAIService) resumeOnWebSocket(c *gin.Context, sessionID string) {
+	session, ok := s.resumableSession(c, sessionID)
+	if !ok {
+		return
+	}
+	after, err := pollAfter(c)
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error(), "after")
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "websocket_upgrade_failed",
+			"error":     err,
+		}).Error("WebSocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	session.mutex.Lock()
+	outbox := session.poll
+	if session.ended || outbox == nil {
+		session.mutex.Unlock()
+		s.rejectHandoff(conn, session, "session is not on the long-poll transport")
+		return
+	}
+	session.poll = nil
+	session.Conn = conn
+	session.outbound = newOutboundQueue(s.outbound)
+	gen := session.attachTransport()
+
+	// Still holding the mutex, so the queued events precede any new one
+	batch, _ := outbox.take(after)
+	for _, event := range batch.Events {
+		conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if err := conn.WriteMessage(websocket.TextMessage, event); err != nil {
+			break
+		}
+		session.bandwidth.addOutbound(len(event))
+	}
+	session.mutex.Unlock()
+
+	if client := s.polls.take(sessionID); client != nil {
+		client.cancel()
+	}
+	outbox.close()
+
+	session.Logger().WithFields(logrus.Fields{
+		"component": "svc_openai_api ",
+		"action":    "session_handoff",
+		"sessionID": session.ID,
+		"transport": "websocket",
+		"replayed":  len(batch.Events),
+	}).Info("Session moved from long-poll to WebSocket")
+
+	reason := SessionEndClosed
+	defer func() { s.detachTransport(session, gen, reason) }()
+	reason = s.serveWebSocket(c.Request.Context(), conn, session, gen)
+}
+
+// resumeOnLongPoll moves a WebSocket session to the long-poll transport. Its socket is
+// closed with a normal closure once the session no longer writes to it.
+func (s *OpenAIService) resumeOnLongPoll(c *gin.Context, sessionID string) {
+	session, ok := s.resumableSession(c, sessionID)
+	if !ok {
+		return
+	}
+
+	outbox := newPollOutbox(s.polls.bufferLimit)
+	session.mutex.Lock()
+	conn := session.Conn
+	if session.ended || conn == nil {
+		session.mutex.Unlock()
+		openAIError(c, http.StatusConflict, "invalid_request_error", "session is not on the WebSocket transport", "resume")
+		return
+	}
+	session.Conn = nil
+	session.poll = outbox
+	// Events the WebSocket writer has not sent yet go out on the new transport
+	for _, data := range session.outbound.take() {
+		outbox.push(data)
+	}
+	session.outbound = nil
+	gen := session.attachTransport()
+	session.mutex.Unlock()
+
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session_handoff")
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &pollClient{session: session, outbox: outbox, gen: gen, cancel: cancel}
+	client.lastSeen.Store(time.Now().UnixNano())
+	s.polls.add(client)
+	go s.reportLoop(ctx, session)
+
+	session.Logger().WithFields(logrus.Fields{
+		"component": "svc_openai_api ",
+		"action":    "session_handoff",
+		"sessionID": session.ID,
+		"transport": "long_poll",
+	}).Info("Session moved from WebSocket to long-poll")
+
+	c.JSON(http.StatusOK, s.pollSessionResponse(session))
+}
+
+// rejectHandoff refuses a WebSocket resuming a session that cannot move to it
+func (s *OpenAIService) rejectHandoff(conn *websocket.Conn, session *Session, message string) {
+	errorEvent := &ErrorEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeError,
+			EventID:   GenerateEventID(),
+			SessionID: session.ID,
+		},
+	}
+	errorEvent.Error.Type = "invalid_request_error"
+	errorEvent.Error.Code = "session_not_resumable"
+	errorEvent.Error.Category = errcode.InvalidRequest
+	errorEvent.Error.Message = message
+	rejectConnection(conn, errorEvent, "session_not_resumable")
+}
+
+// resumeSessionID returns the session named by the resume field of a POST
+// /v1/realtime/sessions body, empty when a new session is requested
+func resumeSessionID(c *gin.Context) string {
+	if c.Request.ContentLength == 0 || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return ""
+	}
+	var req struct {
+		Resume string `json:"resume"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ""
+	}
+	return req.Resume
+}
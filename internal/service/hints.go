@@ -0,0 +1,41 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-restream/stt/config"
+)
+
+// Default limits of input_audio_transcription.hints when asr.hints leaves them unset
+const (
+	defaultMaxHints      = 100
+	defaultMaxHintLength = 64
+)
+
+// validateHints checks the custom vocabulary of a session.update against the limits
+// of asr.hints. Every hint must be a non-empty term of at most max_hint_length
+// characters.
+func validateHints(hints []string, cfg *config.Config) error {
+	maxHints, maxLength := defaultMaxHints, defaultMaxHintLength
+	if cfg.ASR.Hints.MaxHints > 0 {
+		maxHints = cfg.ASR.Hints.MaxHints
+	}
+	if cfg.ASR.Hints.MaxHintLength > 0 {
+		maxLength = cfg.ASR.Hints.MaxHintLength
+	}
+
+	if len(hints) > maxHints {
+		return fmt.Errorf("too many hints: %d, at most %d are allowed", len(hints), maxHints)
+	}
+	for i, hint := range hints {
+		if strings.TrimSpace(hint) == "" {
+			return fmt.Errorf("hint %d is empty", i)
+		}
+		if length := utf8.RuneCountInString(hint); length > maxLength {
+			return fmt.Errorf("hint %d is %d characters long, at most %d are allowed", i, length, maxLength)
+		}
+	}
+	return nil
+}
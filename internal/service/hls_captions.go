@@ -0,0 +1,164 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/pkg/hlscaptions"
+
+	"github.com/gin-gonic/gin"
+)
+
+// captionEdge tracks how far into the session audio the captions are final, so that
+// an HLS segment is only published once the speech in it is transcribed. Players load
+// a segment once and never see cues added to it later.
+type captionEdge struct {
+	mutex   sync.Mutex
+	audio   time.Duration // input audio passed to the pipeline
+	holding bool          // speech started and not committed yet
+	holdMs  int64         // audio position the speech started at
+	edgeMs  int64         // published edge, never moves back
+}
+
+// addAudio advances the audio position by input audio of d
+func (e *captionEdge) addAudio(d time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.audio += d
+}
+
+// speechStarted holds the edge at the current audio position until the speech is
+// committed
+func (e *captionEdge) speechStarted() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if !e.holding {
+		e.holding = true
+		e.holdMs = e.audio.Milliseconds()
+	}
+}
+
+// committed releases the hold of speechStarted, the conversation item of the speech
+// holds the edge from then on
+func (e *captionEdge) committed() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.holding = false
+}
+
+// advance moves the edge up to the audio position, or to the start of speech not yet
+// transcribed, pendingMs (-1 when none) or the current hold. Speech holds the edge for
+// maxDelay at most.
+func (e *captionEdge) advance(pendingMs int64, maxDelay time.Duration) int64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	edge := e.audio.Milliseconds()
+	if pendingMs >= 0 && pendingMs < edge {
+		edge = pendingMs
+	}
+	if e.holding && e.holdMs < edge {
+		edge = e.holdMs
+	}
+	if oldest := (e.audio - maxDelay).Milliseconds(); edge < oldest {
+		edge = oldest
+	}
+	if edge > e.edgeMs {
+		e.edgeMs = edge
+	}
+	return e.edgeMs
+}
+
+// hlsCaptionOptions returns the segment settings of output.hls
+func (s *OpenAIService) hlsCaptionOptions() (hlscaptions.Options, time.Duration) {
+	hls := s.appConfig().Output.HLS
+	opts := hlscaptions.Options{
+		SegmentMs:      int64(hls.SegmentSeconds * 1000),
+		MPEGTS:         hls.MPEGTS,
+		WindowSegments: hls.WindowSegments,
+	}
+	maxDelay := time.Duration(hls.MaxDelaySeconds * float64(time.Second))
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+	return opts, maxDelay
+}
+
+// CaptionEdge returns how far into the audio of a session the captions are final: up
+// to the speech still being spoken or transcribed
+func (sm *SessionManager) CaptionEdge(sessionID string, maxDelay time.Duration) (int64, error) {
+	session, exists := sm.GetSession(sessionID)
+	if !exists {
+		return 0, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	pendingMs := int64(-1)
+	session.itemsMutex.RLock()
+	for _, item := range session.ConversationItems {
+		// Items without audio bounds yet are still held by speechStarted
+		if item.Status != "in_progress" || item.AudioEndMs == 0 {
+			continue
+		}
+		if pendingMs < 0 || item.AudioStartMs < pendingMs {
+			pendingMs = item.AudioStartMs
+		}
+	}
+	session.itemsMutex.RUnlock()
+
+	return session.captionEdge.advance(pendingMs, maxDelay), nil
+}
+
+// handleCaptionPlaylist serves the HLS media playlist of the caption segments of a live
+// session, for the subtitle rendition of a restream
+func handleCaptionPlaylist(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	opts, maxDelay := openAIService.hlsCaptionOptions()
+	edgeMs, err := openAIService.sessionManager.CaptionEdge(c.Param("id"), maxDelay)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(hlscaptions.Playlist(edgeMs, "captions/", opts)))
+}
+
+// handleCaptionSegment serves the WebVTT segment of an HLS media sequence, once the
+// captions of its time span are final
+func handleCaptionSegment(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	seq, err := hlscaptions.ParseSegmentName(c.Param("segment"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, maxDelay := openAIService.hlsCaptionOptions()
+	edgeMs, err := openAIService.sessionManager.CaptionEdge(c.Param("id"), maxDelay)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if seq >= hlscaptions.Available(edgeMs, opts) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "caption segment not available yet"})
+		return
+	}
+	cues, err := openAIService.sessionManager.CaptionCues(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Complete segments do not change
+	c.Header("Cache-Control", "max-age=3600")
+	c.Data(http.StatusOK, "text/vtt; charset=utf-8", []byte(hlscaptions.Segment(cues, seq, opts)))
+}
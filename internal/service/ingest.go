@@ -0,0 +1,355 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Live streams configured under ingest are pulled by the server and transcribed like
+// the audio of a client: ffmpeg decodes a stream to 16kHz mono PCM16, which is appended
+// to a session of the stream's own and runs through the same denoising, VAD and
+// recognition. Such sessions have no client. Their events reach the event sink, and
+// their transcripts the transcript history, the caption endpoints and caption files,
+// like those of any session. A dropped stream is reconnected in the same session.
+
+// Ingest stream states
+const (
+	IngestStateConnecting = "connecting"
+	IngestStateStreaming  = "streaming"
+	IngestStateRetrying   = "retrying"
+)
+
+// ingestChunkBytes is the audio appended at a time, 100ms of 16kHz PCM16
+const ingestChunkBytes = 3200
+
+// errIngestStreamEnded is the error of a stream that ffmpeg reached the end of
+var errIngestStreamEnded = errors.New("stream ended")
+
+// IngestStatus describes an ingested stream, as listed by GET /v1/admin/ingest
+type IngestStatus struct {
+	Name         string    `json:"name"`
+	URL          string    `json:"url"` // without credentials
+	State        string    `json:"state"`
+	Since        time.Time `json:"since"` // of the state
+	SessionID    string    `json:"session_id,omitempty"`
+	AudioSeconds float64   `json:"audio_seconds"` // ingested in the session
+	Reconnects   int       `json:"reconnects"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// ingestStream is a configured stream and its status
+type ingestStream struct {
+	config.IngestStream
+
+	mutex  sync.Mutex
+	status IngestStatus
+}
+
+// ingestStreams are the streams of the ingest configuration
+type ingestStreams struct {
+	ffmpegPath string
+	retryDelay time.Duration
+	streams    []*ingestStream
+}
+
+func newIngestStreams(cfg *config.Config) *ingestStreams {
+	i := &ingestStreams{
+		ffmpegPath: cfg.Ingest.FFmpegPath,
+		retryDelay: time.Duration(cfg.Ingest.RetryDelaySeconds) * time.Second,
+	}
+	if i.ffmpegPath == "" {
+		i.ffmpegPath = "ffmpeg"
+	}
+	if i.retryDelay <= 0 {
+		i.retryDelay = 5 * time.Second
+	}
+	for n, streamConfig := range cfg.Ingest.Streams {
+		if streamConfig.Name == "" {
+			streamConfig.Name = fmt.Sprintf("stream-%d", n+1)
+		}
+		stream := &ingestStream{IngestStream: streamConfig}
+		stream.status = IngestStatus{
+			Name:  streamConfig.Name,
+			URL:   redactURL(streamConfig.URL),
+			State: IngestStateConnecting,
+			Since: time.Now(),
+		}
+		i.streams = append(i.streams, stream)
+	}
+	return i
+}
+
+// redactURL removes the credentials of a stream URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = nil
+	return u.String()
+}
+
+// setState records a state of the stream, with the error that caused it if any
+func (st *ingestStream) setState(state string, sessionID string, err error) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if state == IngestStateRetrying {
+		st.status.Reconnects++
+	}
+	if sessionID != st.status.SessionID {
+		st.status.AudioSeconds = 0
+	}
+	st.status.State = state
+	st.status.Since = time.Now()
+	st.status.SessionID = sessionID
+	if err != nil {
+		st.status.LastError = err.Error()
+	}
+}
+
+func (st *ingestStream) addAudio(d time.Duration) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	st.status.AudioSeconds += d.Seconds()
+}
+
+func (st *ingestStream) snapshot() IngestStatus {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return st.status
+}
+
+// list returns the status of every stream
+func (i *ingestStreams) list() []IngestStatus {
+	statuses := make([]IngestStatus, 0, len(i.streams))
+	for _, stream := range i.streams {
+		statuses = append(statuses, stream.snapshot())
+	}
+	return statuses
+}
+
+// runIngestStream transcribes a stream until ctx ends, reconnecting it whenever it
+// drops. The session of the stream is kept across reconnects, and replaced when it
+// ended meanwhile, e.g. removed by the session sweep.
+func (s *OpenAIService) runIngestStream(ctx context.Context, stream *ingestStream) {
+	var session *Session
+	defer func() {
+		if session != nil {
+			s.endIngestSession(session)
+		}
+	}()
+
+	for {
+		var err error
+		if session == nil || !s.sessionManager.SessionExists(session.ID) {
+			session, err = s.createIngestSession(stream)
+		}
+		if err == nil {
+			stream.setState(IngestStateConnecting, session.ID, nil)
+			err = s.pullIngestStream(ctx, stream, session)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		sessionID := ""
+		if session != nil {
+			sessionID = session.ID
+			// Speech cut off by the drop is transcribed rather than held until the
+			// stream is back
+			if s.sessionManager.SessionExists(session.ID) {
+				s.commitIngestAudio(session)
+			}
+		}
+		stream.setState(IngestStateRetrying, sessionID, err)
+		log := logger.Scoped(false)
+		if session != nil {
+			log = session.Logger()
+		}
+		log.WithFields(logrus.Fields{
+			"component": "svc_ingest",
+			"action":    "ingest_stream_dropped",
+			"stream":    stream.Name,
+			"sessionID": sessionID,
+			"error":     err,
+			"retryIn":   s.ingest.retryDelay,
+		}).Warn("Ingest stream dropped, reconnecting")
+
+		select {
+		case <-time.After(s.ingest.retryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// createIngestSession opens the session a stream is transcribed in, with the
+// transcription settings and pipeline of the stream
+func (s *OpenAIService) createIngestSession(stream *ingestStream) (*Session, error) {
+	session, err := s.sessionManager.CreateSession(nil, "audio")
+	if err != nil {
+		return nil, err
+	}
+
+	var pipelineErr error
+	s.sessionManager.UpdateSession(session.ID, func(sess *Session) {
+		sess.ingest = stream.Name
+		pipelineErr = applyServerSessionSettings(sess, s.appConfig(), stream.Language, stream.Model, stream.Pipeline)
+	})
+	if pipelineErr != nil {
+		s.sessionManager.RemoveSession(session.ID, SessionEndClosed)
+		return nil, fmt.Errorf("invalid pipeline of stream %s: %w", stream.Name, pipelineErr)
+	}
+
+	s.sendSessionCreated(session)
+	session.Logger().WithFields(logrus.Fields{
+		"component": "svc_ingest",
+		"action":    "ingest_session_created",
+		"stream":    stream.Name,
+		"sessionID": session.ID,
+	}).Info("Created session of ingest stream")
+	return session, nil
+}
+
+// applyServerSessionSettings sets the transcription language, model and pipeline of a
+// session the server opens itself, empty ones keeping the defaults. It is called with
+// the session manager lock held, like applyPipeline.
+func applyServerSessionSettings(sess *Session, cfg *config.Config, language, model, pipeline string) error {
+	if language != "" {
+		sess.InputAudioTranscription.Language = language
+	}
+	if model != "" {
+		sess.InputAudioTranscription.Model = model
+	}
+	if pipeline != "" {
+		return applyPipeline(sess, cfg, pipeline)
+	}
+	return nil
+}
+
+// pullIngestStream decodes a stream with ffmpeg and appends its audio to the session
+// until the stream drops or ctx ends
+func (s *OpenAIService) pullIngestStream(ctx context.Context, stream *ingestStream, session *Session) error {
+	args := []string{"-nostdin", "-v", "error"}
+	if strings.HasPrefix(stream.URL, "rtsp://") || strings.HasPrefix(stream.URL, "rtsps://") {
+		// UDP loses packets behind NAT and firewalls
+		args = append(args, "-rtsp_transport", "tcp")
+	}
+	args = append(args, "-i", stream.URL, "-vn", "-f", "s16le", "-acodec", "pcm_s16le", "-ac", "1", "-ar", "16000", "-")
+
+	cmd := exec.CommandContext(ctx, s.ingest.ffmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	readErr := s.appendIngestAudio(stream, session, stdout)
+	// Stops ffmpeg when the session failed to take the audio
+	stdout.Close()
+	waitErr := cmd.Wait()
+
+	switch {
+	case readErr != nil:
+		return readErr
+	case waitErr != nil && stderr.Len() > 0:
+		return fmt.Errorf("ffmpeg: %w: %s", waitErr, strings.TrimSpace(stderr.String()))
+	case waitErr != nil:
+		return fmt.Errorf("ffmpeg: %w", waitErr)
+	}
+	return errIngestStreamEnded
+}
+
+// appendIngestAudio appends the decoded audio of a stream to its session as it arrives
+func (s *OpenAIService) appendIngestAudio(stream *ingestStream, session *Session, audio io.Reader) error {
+	chunk := make([]byte, ingestChunkBytes)
+	streaming := false
+	for {
+		n, err := io.ReadFull(audio, chunk)
+		n -= n % 2
+		if n > 0 {
+			if !streaming {
+				streaming = true
+				stream.setState(IngestStateStreaming, session.ID, nil)
+			}
+			if err := s.sessionManager.UpdateHeartbeat(session.ID); err != nil {
+				// Ended by the session sweep or a usage limit
+				return err
+			}
+			if err := s.appendInputAudio(session, chunk[:n], n, time.Now()); err != nil {
+				return err
+			}
+			stream.addAudio(time.Duration(n/2) * time.Second / 16000)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// commitIngestAudio commits the audio of a stream's session, as a client would before
+// a pause
+func (s *OpenAIService) commitIngestAudio(session *Session) {
+	commit := &InputAudioBufferCommitEvent{
+		BaseEvent: BaseEvent{
+			Type:    EventTypeInputAudioBufferCommit,
+			EventID: GenerateEventID(),
+		},
+	}
+	if err := s.handleInputAudioBufferCommit(session, commit); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "svc_ingest",
+			"action":    "ingest_commit_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Warn("Failed to commit the audio of ingest stream")
+	}
+}
+
+// endIngestSession ends the session of a stream when the service stops
+func (s *OpenAIService) endIngestSession(session *Session) {
+	session.mutex.Lock()
+	ended := session.ended
+	session.ended = true
+	session.mutex.Unlock()
+
+	if !ended && s.sessionManager.SessionExists(session.ID) {
+		s.endSession(session, SessionEndClosed)
+	}
+}
+
+// handleIngestStatus is GET /v1/admin/ingest, the state of the ingested streams
+func handleIngestStatus(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+	if openAIService.ingest == nil {
+		c.JSON(http.StatusOK, gin.H{"streams": []IngestStatus{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"streams": openAIService.ingest.list()})
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-restream/stt/pkg/audioframe"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Encodings of the input audio a WebSocket client appends, see session.input_encoding.
+// Under binary the client may send audio as binary frames, laid out by the audioframe
+// package, sparing the Base64 encoding; JSON append events stay accepted either way.
+const (
+	InputEncodingBase64 = "base64"
+	InputEncodingBinary = "binary"
+)
+
+// validateInputEncoding checks the input_encoding of a session.update
+func validateInputEncoding(encoding string) error {
+	if encoding != InputEncodingBase64 && encoding != InputEncodingBinary {
+		return fmt.Errorf("unknown input encoding %q, expected %q or %q", encoding, InputEncodingBase64, InputEncodingBinary)
+	}
+	return nil
+}
+
+// handleBinaryMessage appends the audio of a binary frame to the input buffer, as an
+// input_audio_buffer.append event would
+func (s *OpenAIService) handleBinaryMessage(session *Session, message []byte) error {
+	appendStart := time.Now()
+
+	if session.InputEncoding != InputEncodingBinary {
+		s.sendErrorEvent(session, "invalid_request_error", "binary_audio_disabled",
+			"binary frames require session.input_encoding \"binary\"", "session.input_encoding")
+		return nil
+	}
+
+	frame, err := audioframe.Decode(message)
+	if err != nil {
+		s.sendErrorEvent(session, "invalid_request_error", "invalid_audio_frame", err.Error(), "")
+		return nil
+	}
+
+	if session.IsDebug() {
+		s.sessionManager.RecordJournal(session, JournalDirectionIn, journalAudioFrame(frame))
+	}
+
+	if checksum, ok := frame.Verify(); !ok {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "proc_audio_main",
+			"action":    "checksum_mismatch",
+			"sessionID": session.ID,
+			"expected":  *frame.CRC32,
+			"actual":    checksum,
+		}).Warn("Audio frame checksum mismatch, dropping frame")
+
+		s.sendErrorEvent(session, "invalid_request_error", "checksum_mismatch",
+			fmt.Sprintf("crc32 mismatch for binary frame: expected %08x, got %08x", *frame.CRC32, checksum),
+			"crc32")
+		return nil
+	}
+
+	return s.appendInputAudio(session, frame.Audio, len(message), appendStart)
+}
+
+// journalAudioFrame returns the append event equivalent to a binary frame, recorded in
+// the journal of debug sessions so replays need not handle binary frames
+func journalAudioFrame(frame audioframe.Frame) []byte {
+	event := &InputAudioBufferAppendEvent{
+		BaseEvent: BaseEvent{Type: EventTypeInputAudioBufferAppend},
+		Audio:     EncodeAudioToBase64(frame.Audio),
+		CRC32:     frame.CRC32,
+	}
+	data, _ := json.Marshal(event)
+	return data
+}
@@ -0,0 +1,853 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/internal/testutil"
+	"github.com/go-restream/stt/pkg/errcode"
+	"github.com/go-restream/stt/pkg/jwe"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests in this file run the whole pipeline, from input_audio_buffer.append through
+// the VAD and commit to the transcription events, against the fake ASR engine of
+// internal/testutil.
+
+const (
+	testSampleRate = 16000
+	eventTimeout   = 10 * time.Second
+)
+
+// testConfig is the configuration of the tests, with the energy VAD so no model is needed
+const testConfig = `
+asr:
+  base_url: "%s"
+  api_key: "sk-test"
+  model: "test-model"
+vad:
+  enable: true
+  engine: "energy"
+  threshold: 0.5
+  min_silence_duration: 0.3
+  min_speech_duration: 0.1
+  window_size: 512
+  max_speech_duration: 8.0
+  sample_rate: 16000
+audio:
+  enable: false
+`
+
+// startTestServer starts the service with the test configuration and serves its routes
+func startTestServer(t *testing.T, asr *testutil.ASRServer) *httptest.Server {
+	t.Helper()
+	return startTestServerConfig(t, asr, testConfig)
+}
+
+// startTestServerConfig starts the service with a configuration whose %s is replaced by
+// the URL of the fake ASR engine
+func startTestServerConfig(t *testing.T, asr *testutil.ASRServer, configYAML string) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(strings.Replace(configYAML, "%s", asr.BaseURL(), 1)), 0600))
+
+	previous := openAIService
+	service := NewOpenAIService(DefaultOpenAIConfig(), configPath)
+	openAIService = service
+	r := gin.New()
+	registerRoutes(r)
+	server := httptest.NewServer(r)
+	t.Cleanup(func() {
+		server.Close()
+		service.cancel()
+		openAIService = previous
+	})
+	return server
+}
+
+// speak streams an utterance followed by silence, waits for the VAD to end the turn
+// and commits it as clients do after input_audio_buffer.speech_stopped
+func speak(t *testing.T, client *testutil.WSClient, speech time.Duration) {
+	t.Helper()
+	client.StreamAudio(testutil.Utterance(speech, 800*time.Millisecond, testSampleRate), testSampleRate, 20*time.Millisecond)
+	client.WaitFor(EventTypeInputAudioBufferSpeechStarted, eventTimeout)
+	client.WaitFor(EventTypeInputAudioBufferSpeechStopped, eventTimeout)
+	client.Commit()
+}
+
+// itemID returns the ID of the item of a transcription completed event
+func itemID(t *testing.T, completed testutil.Event) string {
+	t.Helper()
+	var event ConversationItemInputAudioTranscriptionCompletedEvent
+	require.NoError(t, completed.Decode(&event))
+	return event.Item.ID
+}
+
+// dialSession connects a client and waits for its session
+func dialSession(t *testing.T, server *httptest.Server) *testutil.WSClient {
+	t.Helper()
+	client := testutil.DialRealtime(t, server.URL, http.Header{})
+	client.WaitFor(EventTypeSessionCreated, eventTimeout)
+	return client
+}
+
+func TestServerVADTranscription(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	asr.SetTranscripts("hello world")
+	client := dialSession(t, startTestServer(t, asr))
+
+	client.StreamAudio(testutil.Silence(200*time.Millisecond, testSampleRate), testSampleRate, 20*time.Millisecond)
+	speak(t, client, 600*time.Millisecond)
+
+	committed := client.WaitFor(EventTypeInputAudioBufferCommitted, eventTimeout)
+	completed := client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+
+	assert.Equal(t, "hello world", completed.Transcript())
+	assert.Equal(t, committed.Field("item_id"), itemID(t, completed))
+
+	requests := asr.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "test-model", requests[0].Model)
+	assert.Equal(t, "RIFF", string(requests[0].Audio[:4]))
+	assert.Equal(t, "Bearer sk-test", requests[0].Header.Get("Authorization"))
+}
+
+func TestUtterancesTranscribedInOrder(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	asr.SetTranscripts("first", "second")
+	client := dialSession(t, startTestServer(t, asr))
+
+	speak(t, client, 600*time.Millisecond)
+	first := client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+	speak(t, client, 600*time.Millisecond)
+	second := client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+
+	assert.Equal(t, "first", first.Transcript())
+	assert.Equal(t, "second", second.Transcript())
+	assert.NotEqual(t, itemID(t, first), itemID(t, second))
+}
+
+func TestManualCommitTranscription(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	asr.SetTranscripts("committed by hand")
+	client := dialSession(t, startTestServer(t, asr))
+
+	// Sent at once, the speech never ends a turn and is committed by the client
+	client.AppendAudio(testutil.Speech(time.Second, testSampleRate), 3200)
+	client.Commit()
+
+	client.WaitFor(EventTypeInputAudioBufferCommitted, eventTimeout)
+	completed := client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+	assert.Equal(t, "committed by hand", completed.Transcript())
+}
+
+func TestASRLatency(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	asr.SetLatency(500 * time.Millisecond)
+	client := dialSession(t, startTestServer(t, asr))
+
+	speak(t, client, 600*time.Millisecond)
+	committed := time.Now()
+	completed := client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+
+	assert.Equal(t, "transcript 1", completed.Transcript())
+	assert.GreaterOrEqual(t, time.Since(committed), 500*time.Millisecond)
+}
+
+func TestASRFailure(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	asr.FailNext(10, http.StatusInternalServerError)
+	client := dialSession(t, startTestServer(t, asr))
+
+	speak(t, client, 600*time.Millisecond)
+
+	var failed ConversationItemInputAudioTranscriptionFailedEvent
+	require.NoError(t, client.WaitFor(EventTypeConversationItemInputAudioTranscriptionFailed, eventTimeout).Decode(&failed))
+	assert.Equal(t, "recognition_error", failed.Error.Code)
+	assert.Equal(t, errcode.ASRUnavailable, failed.Error.Category)
+	assert.NotContains(t, client.Types(), EventTypeConversationItemInputAudioTranscriptionCompleted)
+}
+
+func TestErrorCategory(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	client := dialSession(t, startTestServer(t, asr))
+
+	client.UpdateSession(map[string]any{"modality": "audio", "input_audio_format": map[string]any{"type": "pcm16", "channels": 99}})
+
+	var event ErrorEvent
+	require.NoError(t, client.WaitFor(EventTypeError, eventTimeout).Decode(&event))
+	assert.Equal(t, "unsupported_audio_format", event.Error.Code)
+	assert.Equal(t, errcode.InvalidAudioFormat, event.Error.Category)
+	assert.Equal(t, "session.input_audio_format.channels", event.Error.Param)
+}
+
+func TestSessionStatsRequest(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	client := dialSession(t, startTestServer(t, asr))
+
+	speak(t, client, 600*time.Millisecond)
+	client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+	client.Send(EventTypeSessionStatsRequest, map[string]any{"event_id": "stats_1"})
+
+	var event SessionStatsEvent
+	require.NoError(t, client.WaitFor(EventTypeSessionStats, eventTimeout).Decode(&event))
+	assert.Equal(t, "stats_1", event.RequestEventID)
+	assert.Equal(t, int64(1), event.Stats.ASRCalls)
+	assert.Zero(t, event.Stats.ASRErrors)
+	assert.InDelta(t, 1.4, event.Stats.AudioSeconds, 0.1)
+	assert.Greater(t, event.Stats.SpeechSeconds, 0.4)
+	assert.Less(t, event.Stats.SpeechSeconds, event.Stats.AudioSeconds)
+	assert.Positive(t, event.Stats.AvgRecognitionMs)
+	assert.Zero(t, event.Stats.DroppedSamples)
+}
+
+func TestMaxUtteranceForcesCommit(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	asr.SetTranscripts("first part", "second part")
+	configYAML := strings.Replace(testConfig, "max_speech_duration: 8.0", "max_speech_duration: 0.25\n  max_utterance_seconds: 1", 1)
+	client := dialSession(t, startTestServerConfig(t, asr, configYAML))
+
+	// Continuous speech well past the limit, with no pause for the VAD
+	client.StreamAudio(testutil.Utterance(1800*time.Millisecond, 800*time.Millisecond, testSampleRate), testSampleRate, 20*time.Millisecond)
+
+	var stopped InputAudioBufferSpeechStoppedEvent
+	require.NoError(t, client.WaitFor(EventTypeInputAudioBufferSpeechStopped, eventTimeout).Decode(&stopped))
+	assert.Equal(t, SpeechStoppedReasonMaxDuration, stopped.Reason)
+
+	// The server commits the first item itself, the client commits the rest after the pause
+	var committed InputAudioBufferCommittedEvent
+	require.NoError(t, client.WaitFor(EventTypeInputAudioBufferCommitted, eventTimeout).Decode(&committed))
+	assert.NotEmpty(t, committed.ItemID)
+	assert.GreaterOrEqual(t, committed.DurationMs, int64(1000))
+	first := client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+	assert.Equal(t, committed.ItemID, itemID(t, first))
+	assert.Equal(t, "first part", first.Transcript())
+
+	var paused InputAudioBufferSpeechStoppedEvent
+	require.NoError(t, client.WaitFor(EventTypeInputAudioBufferSpeechStopped, eventTimeout).Decode(&paused))
+	assert.Empty(t, paused.Reason)
+	client.Commit()
+	second := client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+	assert.Equal(t, "second part", second.Transcript())
+	assert.NotEqual(t, committed.ItemID, itemID(t, second))
+	assert.Len(t, asr.Requests(), 2)
+}
+
+func TestChunkedRecognition(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	asr.SetTranscripts("part", "part", "part", "part", "part", "part", "part", "part")
+	asr.SetLatency(100 * time.Millisecond)
+	configYAML := strings.NewReplacer(
+		"max_speech_duration: 8.0", "max_speech_duration: 0.25",
+		`model: "test-model"`, `model: "test-model"
+  chunking:
+    enable: true
+    threshold_seconds: 1
+    chunk_seconds: 0.5
+    deltas: true`,
+	).Replace(testConfig)
+	client := dialSession(t, startTestServerConfig(t, asr, configYAML))
+
+	// 1.8s of speech in segments of 256ms is split into chunks of two segments or more
+	speak(t, client, 1800*time.Millisecond)
+	completed := client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+
+	chunks := len(asr.Requests())
+	assert.GreaterOrEqual(t, chunks, 3)
+	assert.Equal(t, strings.TrimSpace(strings.Repeat("part ", chunks)), completed.Transcript())
+
+	// Each chunk grows the transcript by a delta, in order
+	var deltas []string
+	for _, event := range client.Received() {
+		if event.Type == EventTypeConversationItemInputAudioTranscriptionDelta {
+			var delta ConversationItemInputAudioTranscriptionDeltaEvent
+			require.NoError(t, event.Decode(&delta))
+			assert.Equal(t, itemID(t, completed), delta.ItemID)
+			deltas = append(deltas, delta.Transcript)
+		}
+	}
+	require.Len(t, deltas, chunks)
+	for i, transcript := range deltas {
+		assert.Equal(t, strings.TrimSpace(strings.Repeat("part ", i+1)), transcript)
+	}
+}
+
+func TestSessionEndCancelsRecognition(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	asr.SetLatency(time.Minute)
+	client := dialSession(t, startTestServer(t, asr))
+
+	speak(t, client, 600*time.Millisecond)
+	client.WaitFor(EventTypeInputAudioBufferCommitted, eventTimeout)
+	require.Eventually(t, func() bool { return len(asr.Requests()) == 1 }, eventTimeout, 10*time.Millisecond)
+
+	// Removing the session aborts the ASR call instead of waiting out its latency
+	client.Close()
+	assert.Eventually(t, func() bool { return asr.Cancelled() == 1 }, eventTimeout, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return openAIService.sessionManager.GetActiveSessionCount() == 0 }, eventTimeout, 10*time.Millisecond)
+}
+
+func TestAdminSessionControl(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	server := startTestServer(t, asr)
+	client := dialSession(t, server)
+	speak(t, client, 600*time.Millisecond)
+	client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+
+	var list struct {
+		Data []LiveSession `json:"data"`
+	}
+	resp, err := http.Get(server.URL + "/v1/admin/sessions/live")
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	require.Len(t, list.Data, 1)
+	live := list.Data[0]
+	assert.Equal(t, TransportWebSocket, live.Transport)
+	assert.False(t, live.Speaking)
+	assert.InDelta(t, 0.5, live.TurnDetection.Threshold, 0.001)
+	assert.Equal(t, 300, live.TurnDetection.SilenceDurationMs)
+
+	// A VAD change applies with the next audio
+	resp, err = http.Post(server.URL+"/v1/admin/sessions/"+live.ID+"/vad", "application/json", strings.NewReader(`{"silence_duration_ms": 600}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	client.StreamAudio(testutil.Silence(100*time.Millisecond, testSampleRate), testSampleRate, 20*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		live := openAIService.sessionManager.LiveSessions("")
+		return len(live) == 1 && live[0].TurnDetection.SilenceDurationMs == 600 && !live[0].VADUpdatePending
+	}, eventTimeout, 10*time.Millisecond)
+
+	resp, err = http.Post(server.URL+"/v1/admin/sessions/"+live.ID+"/vad", "application/json", strings.NewReader(`{"threshold": 2}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Closing ends the session without parking it for a resume
+	resp, err = http.Post(server.URL+"/v1/admin/sessions/"+live.ID+"/close", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Eventually(t, func() bool { return openAIService.sessionManager.GetActiveSessionCount() == 0 }, eventTimeout, 10*time.Millisecond)
+	assert.Equal(t, int64(1), openAIService.sessionManager.GCStats().EvictedSessions)
+
+	resp, err = http.Post(server.URL+"/v1/admin/sessions/"+live.ID+"/close", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestDebugJournalSavedWithSessionAudio(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	saveDir := t.TempDir()
+	server := startTestServerConfig(t, asr, testConfig+"  save_dir: \""+saveDir+"\"\n")
+	client := dialSession(t, server)
+
+	live := openAIService.sessionManager.LiveSessions("")
+	require.Len(t, live, 1)
+	sessionID := live[0].ID
+	resp, err := http.Post(server.URL+"/v1/admin/sessions/"+sessionID+"/debug", "application/json", strings.NewReader(`{"enabled": true}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	speak(t, client, 600*time.Millisecond)
+	client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+	require.NoError(t, client.Close())
+
+	// The journal is written next to the session's audio, readable by the service only
+	journal := filepath.Join(saveDir, sessionID, journalFile)
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(journal)
+		return err == nil
+	}, eventTimeout, 10*time.Millisecond)
+	info, err := os.Stat(journal)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	data, err := os.ReadFile(journal)
+	require.NoError(t, err)
+	var first JournalEntry
+	require.NoError(t, json.Unmarshal([]byte(strings.SplitN(string(data), "\n", 2)[0]), &first))
+	assert.NotEmpty(t, first.Type)
+	assert.Contains(t, string(data), EventTypeConversationItemInputAudioTranscriptionCompleted)
+}
+
+func TestEvictAllSessions(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	server := startTestServer(t, asr)
+	dialSession(t, server)
+	dialSession(t, server)
+
+	// A cluster instance stepping down ends every session it serves
+	assert.Equal(t, 2, openAIService.evictAllSessions(closeReasonDemoted))
+	assert.Eventually(t, func() bool { return openAIService.sessionManager.GetActiveSessionCount() == 0 }, eventTimeout, 10*time.Millisecond)
+	assert.Equal(t, int64(2), openAIService.sessionManager.GCStats().EvictedSessions)
+}
+
+func TestSessionAnalyticsAtSessionEnd(t *testing.T) {
+	tests := []struct {
+		name string
+		end  func(t *testing.T, session *Session)
+	}{
+		{name: "evicted", end: func(t *testing.T, session *Session) {
+			require.NoError(t, openAIService.evictSession(session, closeReasonEvicted))
+		}},
+		{name: "idle sweep", end: func(t *testing.T, session *Session) {
+			sm := openAIService.sessionManager
+			sm.mutex.Lock()
+			session.LastActive = time.Now().Add(-2 * sm.SessionTimeout)
+			sm.mutex.Unlock()
+			assert.Equal(t, 1, sm.sweepInactiveSessions(true).Removed)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asr := testutil.NewASRServer(t)
+			client := dialSession(t, startTestServer(t, asr))
+			speak(t, client, 600*time.Millisecond)
+			client.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+
+			sessions := openAIService.sessionManager.listSessions()
+			require.Len(t, sessions, 1)
+			tt.end(t, sessions[0])
+
+			var event SessionAnalyticsEvent
+			require.NoError(t, client.WaitFor(EventTypeSessionAnalytics, eventTimeout).Decode(&event))
+			assert.Positive(t, event.Analytics.TalkTimeMs)
+			assert.Eventually(t, func() bool { return openAIService.sessionManager.GetActiveSessionCount() == 0 }, eventTimeout, 10*time.Millisecond)
+		})
+	}
+}
+
+// resumeConfig enables session.resume
+const resumeConfig = testConfig + `
+resume:
+  enable: true
+  grace_seconds: 30
+`
+
+// createdSession returns the ID and resume token of a session.created event
+func createdSession(t *testing.T, created testutil.Event) (string, string) {
+	t.Helper()
+	var event SessionCreatedEvent
+	require.NoError(t, created.Decode(&event))
+	require.NotEmpty(t, event.ResumeToken)
+	return event.Session.ID, event.ResumeToken
+}
+
+// bearer returns the header authenticating with an API key
+func bearer(key string) http.Header {
+	return http.Header{"Authorization": []string{"Bearer " + key}}
+}
+
+func TestSessionResumeRefused(t *testing.T) {
+	const tenantsConfig = resumeConfig + `
+auth:
+  enable: true
+  api_keys:
+    - key: "key-a"
+      tenant: "tenant-a"
+    - key: "key-b"
+      tenant: "tenant-b"
+`
+	asr := testutil.NewASRServer(t)
+	server := startTestServerConfig(t, asr, tenantsConfig)
+	owner := testutil.DialRealtime(t, server.URL, bearer("key-a"))
+	sessionID, token := createdSession(t, owner.WaitFor(EventTypeSessionCreated, eventTimeout))
+
+	tests := []struct {
+		name  string
+		key   string
+		token string
+	}{
+		{name: "wrong token", key: "key-a", token: "wrong"},
+		{name: "other tenant", key: "key-b", token: token},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testutil.DialRealtime(t, server.URL, bearer(tt.key))
+			fresh, _ := createdSession(t, client.WaitFor(EventTypeSessionCreated, eventTimeout))
+			client.Send(EventTypeSessionResume, map[string]any{"session_id": sessionID, "resume_token": tt.token})
+
+			refused := client.WaitFor(EventTypeError, eventTimeout)
+			var event ErrorEvent
+			require.NoError(t, refused.Decode(&event))
+			assert.Equal(t, "session_not_resumable", event.Error.Code)
+			// The client stays on its own session, the owner keeps its one
+			assert.Equal(t, fresh, event.SessionID)
+			assert.True(t, openAIService.sessionManager.SessionExists(fresh))
+		})
+	}
+
+	// Moving the session by URL checks the token before the upgrade
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/realtime?resume=" + sessionID + "&resume_token=wrong"
+	_, resp, err := websocket.DefaultDialer.Dial(url, bearer("key-a"))
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	assert.True(t, openAIService.sessionManager.SessionExists(sessionID))
+}
+
+func TestSessionResumeReplaysMissedEvents(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	asr.SetTranscripts("first", "second")
+	server := startTestServerConfig(t, asr, resumeConfig)
+	first := testutil.DialRealtime(t, server.URL, http.Header{})
+	sessionID, token := createdSession(t, first.WaitFor(EventTypeSessionCreated, eventTimeout))
+
+	speak(t, first, 600*time.Millisecond)
+	seen := first.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+	speak(t, first, 600*time.Millisecond)
+	first.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+	// Let the events of the second utterance settle before the connection drops
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, first.Drop())
+
+	received := first.Received()
+	var missed []testutil.Event
+	for i, event := range received {
+		if string(event.Raw) == string(seen.Raw) {
+			missed = received[i+1:]
+			break
+		}
+	}
+	require.NotEmpty(t, missed)
+
+	second := dialSession(t, server)
+	var lastSeen BaseEvent
+	require.NoError(t, seen.Decode(&lastSeen))
+	second.Send(EventTypeSessionResume, map[string]any{"session_id": sessionID, "resume_token": token, "last_event_id": lastSeen.EventID})
+
+	resumedEvent := second.WaitFor(EventTypeSessionResumed, eventTimeout)
+	var resumed SessionResumedEvent
+	require.NoError(t, resumedEvent.Decode(&resumed))
+	assert.Equal(t, sessionID, resumed.SessionID)
+	assert.False(t, resumed.EventsLost)
+	require.Equal(t, len(missed), resumed.Replayed)
+
+	// Exactly the events after last_event_id, in order
+	for _, want := range missed {
+		got := second.Next(eventTimeout)
+		assert.JSONEq(t, string(want.Raw), string(got.Raw))
+	}
+
+	// The session goes on on the new connection
+	speak(t, second, 600*time.Millisecond)
+	completed := second.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout)
+	var event BaseEvent
+	require.NoError(t, completed.Decode(&event))
+	assert.Equal(t, sessionID, event.SessionID)
+}
+
+// longPollConfig enables the long-poll transport
+const longPollConfig = testConfig + `
+long_poll:
+  enable: true
+  max_wait_seconds: 5
+`
+
+// pollSession is a client of the long-poll transport
+type pollSession struct {
+	t      *testing.T
+	server *httptest.Server
+	ID     string `json:"id"`
+	Token  string `json:"token"`
+	after  uint64
+}
+
+// createPollSession opens a long-poll session
+func createPollSession(t *testing.T, server *httptest.Server) *pollSession {
+	t.Helper()
+	resp, err := http.Post(server.URL+"/v1/realtime/sessions", "application/json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	session := &pollSession{t: t, server: server}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(session))
+	require.NotEmpty(t, session.Token)
+	return session
+}
+
+// do sends a request of the session with its token, returning the response
+func (p *pollSession) do(method, path, contentType string, body []byte) *http.Response {
+	p.t.Helper()
+	req, err := http.NewRequest(method, p.server.URL+"/v1/realtime/"+p.ID+path, strings.NewReader(string(body)))
+	require.NoError(p.t, err)
+	req.Header.Set(sessionTokenHeader, p.Token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(p.t, err)
+	return resp
+}
+
+// send posts a client event
+func (p *pollSession) send(eventType string, fields map[string]any) {
+	p.t.Helper()
+	event := map[string]any{"type": eventType}
+	for key, value := range fields {
+		event[key] = value
+	}
+	data, err := json.Marshal(event)
+	require.NoError(p.t, err)
+	resp := p.do(http.MethodPost, "/events", "application/json", data)
+	resp.Body.Close()
+	require.Equal(p.t, http.StatusAccepted, resp.StatusCode)
+}
+
+// appendAudio posts PCM16 audio in 200ms requests
+func (p *pollSession) appendAudio(pcm []byte) {
+	p.t.Helper()
+	const chunk = 6400
+	for start := 0; start < len(pcm); start += chunk {
+		resp := p.do(http.MethodPost, "/audio", "application/octet-stream", pcm[start:min(start+chunk, len(pcm))])
+		resp.Body.Close()
+		require.Equal(p.t, http.StatusNoContent, resp.StatusCode)
+	}
+}
+
+// poll returns the next batch of events, acknowledging the previous one
+func (p *pollSession) poll() pollBatch {
+	p.t.Helper()
+	resp := p.do(http.MethodGet, fmt.Sprintf("/events?after=%d&wait=1", p.after), "", nil)
+	defer resp.Body.Close()
+	require.Equal(p.t, http.StatusOK, resp.StatusCode)
+	var batch pollBatch
+	require.NoError(p.t, json.NewDecoder(resp.Body).Decode(&batch))
+	if batch.Next > 0 {
+		p.after = batch.Next
+	}
+	return batch
+}
+
+// waitFor polls until an event of the type arrives, returning the events up to it
+func (p *pollSession) waitFor(eventType string) []testutil.Event {
+	p.t.Helper()
+	var events []testutil.Event
+	deadline := time.Now().Add(eventTimeout)
+	for time.Now().Before(deadline) {
+		for _, data := range p.poll().Events {
+			var head BaseEvent
+			require.NoError(p.t, json.Unmarshal(data, &head))
+			events = append(events, testutil.Event{Type: head.Type, Raw: data})
+			if head.Type == eventType {
+				return events
+			}
+		}
+	}
+	p.t.Fatalf("no %s event polled within %v", eventType, eventTimeout)
+	return nil
+}
+
+func TestLongPollSession(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	asr.SetTranscripts("over long-poll")
+	server := startTestServerConfig(t, asr, longPollConfig)
+	session := createPollSession(t, server)
+	session.waitFor(EventTypeSessionCreated)
+
+	session.appendAudio(testutil.Speech(time.Second, testSampleRate))
+	session.send("input_audio_buffer.commit", nil)
+
+	events := session.waitFor(EventTypeConversationItemInputAudioTranscriptionCompleted)
+	completed := events[len(events)-1]
+	assert.Equal(t, "over long-poll", completed.Transcript())
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Contains(t, types, EventTypeInputAudioBufferCommitted)
+
+	// Deleting returns what was not polled yet and ends the session
+	resp := session.do(http.MethodDelete, "", "", nil)
+	var last pollBatch
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&last))
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, last.Closed)
+	assert.False(t, openAIService.sessionManager.SessionExists(session.ID))
+
+	resp = session.do(http.MethodGet, "/events", "", nil)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestLongPollSessionToken(t *testing.T) {
+	server := startTestServerConfig(t, testutil.NewASRServer(t), longPollConfig)
+	session := createPollSession(t, server)
+	token := session.Token
+
+	for _, tt := range []struct {
+		method, path string
+	}{
+		{http.MethodPost, "/audio"},
+		{http.MethodPost, "/events"},
+		{http.MethodGet, "/events"},
+		{http.MethodDelete, ""},
+	} {
+		session.Token = "wrong"
+		resp := session.do(tt.method, tt.path, "application/octet-stream", []byte{0, 0})
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "%s %s", tt.method, tt.path)
+	}
+
+	// The refused requests left the session alone
+	session.Token = token
+	session.waitFor(EventTypeSessionCreated)
+	assert.True(t, openAIService.sessionManager.SessionExists(session.ID))
+}
+
+func TestLongPollOutboxOverflow(t *testing.T) {
+	server := startTestServerConfig(t, testutil.NewASRServer(t), longPollConfig+"  max_buffered_events: 2\n")
+	session := createPollSession(t, server)
+
+	// session.created, conversation.created and three session.updated queue while nobody polls
+	for i := 0; i < 3; i++ {
+		session.send(EventTypeSessionUpdate, map[string]any{"session": map[string]any{"modality": "audio", "instructions": fmt.Sprintf("update %d", i)}})
+	}
+	require.Eventually(t, func() bool {
+		openAIService.sessionManager.mutex.RLock()
+		defer openAIService.sessionManager.mutex.RUnlock()
+		outbox := openAIService.sessionManager.sessions[session.ID].poll
+		outbox.mutex.Lock()
+		defer outbox.mutex.Unlock()
+		return outbox.lastSeq == 5
+	}, eventTimeout, 10*time.Millisecond)
+
+	batch := session.poll()
+	assert.Equal(t, 3, batch.Dropped)
+	require.Len(t, batch.Events, 2)
+	for _, data := range batch.Events {
+		var head BaseEvent
+		require.NoError(t, json.Unmarshal(data, &head))
+		assert.Equal(t, EventTypeSessionUpdated, head.Type)
+	}
+	assert.Zero(t, session.poll().Dropped)
+}
+
+func TestSessionHandoffBetweenTransports(t *testing.T) {
+	asr := testutil.NewASRServer(t)
+	asr.SetTranscripts("on the socket", "back on long-poll")
+	server := startTestServerConfig(t, asr, longPollConfig)
+	session := createPollSession(t, server)
+
+	// The WebSocket receives the events nobody polled, then serves the session
+	ws := testutil.DialRealtimeQuery(t, server.URL, url.Values{"resume": {session.ID}, "resume_token": {session.Token}}, http.Header{})
+	sessionID, _ := createdSession(t, ws.WaitFor(EventTypeSessionCreated, eventTimeout))
+	assert.Equal(t, session.ID, sessionID)
+	speak(t, ws, 600*time.Millisecond)
+	assert.Equal(t, "on the socket", ws.WaitFor(EventTypeConversationItemInputAudioTranscriptionCompleted, eventTimeout).Transcript())
+
+	resp := session.do(http.MethodGet, "/events", "", nil)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "long-poll still served after the handoff")
+
+	// Moving back needs the token of the session
+	handoff := func(token string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/realtime/sessions", strings.NewReader(`{"resume": "`+session.ID+`"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(sessionTokenHeader, token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+	resp = handoff("wrong")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = handoff(session.Token)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	session.after = 0
+	session.appendAudio(testutil.Speech(time.Second, testSampleRate))
+	session.send("input_audio_buffer.commit", nil)
+	events := session.waitFor(EventTypeConversationItemInputAudioTranscriptionCompleted)
+	assert.Equal(t, "back on long-poll", events[len(events)-1].Transcript())
+	assert.True(t, openAIService.sessionManager.SessionExists(session.ID))
+}
+
+// fakePublisher records what an eventPublisher hands to the broker
+type fakePublisher struct {
+	mutex    sync.Mutex
+	messages []sinkMessage
+}
+
+func (p *fakePublisher) Publish(_ context.Context, topic string, key, data []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.messages = append(p.messages, sinkMessage{topic: topic, key: string(key), data: data})
+	return nil
+}
+
+func (p *fakePublisher) Close() error { return nil }
+
+func TestEventSinkSealsAllButLifecycleEvents(t *testing.T) {
+	privateKey, publicKey, err := jwe.GenerateKey()
+	require.NoError(t, err)
+	recipient, err := jwe.ParsePublicKey(publicKey)
+	require.NoError(t, err)
+	key, err := jwe.ParsePrivateKey(privateKey)
+	require.NoError(t, err)
+
+	cfg := &config.Config{}
+	cfg.EventSink.Events = []string{"*"}
+	cfg.EventSink.Encryption.KeyID = "bus-1"
+	fake := &fakePublisher{}
+	p := startEventPublisher(cfg, fake, recipient)
+
+	session := &Session{ID: "sess_1", CreatedAt: time.Now()}
+	published := map[string]string{
+		EventTypeSessionCreated:                                   `{"type":"session.created"}`,
+		EventTypeSessionUpdated:                                   `{"type":"session.updated"}`,
+		EventTypeConversationItemCreated:                          `{"type":"conversation.item.created","item":{"content":[{"type":"input_audio","audio":"AAAA"}]}}`,
+		EventTypeConversationItemInputAudioTranscriptionCompleted: `{"type":"conversation.item.input_audio_transcription.completed","transcript":"hello"}`,
+		EventTypeConversationItemInputAudioTranscriptionFailed:    `{"type":"conversation.item.input_audio_transcription.failed"}`,
+	}
+	for eventType, data := range published {
+		p.publish(session, eventType, []byte(data))
+	}
+	p.sessionEnded(session, SessionEndClosed)
+	p.close(time.Second)
+
+	require.Len(t, fake.messages, len(published)+1)
+	for _, message := range fake.messages {
+		eventType := strings.TrimPrefix(message.topic, defaultEventTopicPrefix)
+		assert.Equal(t, "sess_1", message.key)
+		switch eventType {
+		case EventTypeSessionCreated, EventTypeSessionUpdated:
+			assert.JSONEq(t, published[eventType], string(message.data), eventType)
+		case EventTypeSessionEnded:
+			var ended SessionEndedEvent
+			require.NoError(t, json.Unmarshal(message.data, &ended), "session.ended is sealed")
+			assert.Equal(t, SessionEndClosed, ended.Reason)
+		default:
+			plain, header, err := jwe.Decrypt(string(message.data), key)
+			require.NoError(t, err, "%s is not sealed", eventType)
+			assert.Equal(t, "bus-1", header.KeyID)
+			assert.JSONEq(t, published[eventType], string(plain), eventType)
+		}
+	}
+	assert.Equal(t, EventSinkStats{Published: int64(len(published) + 1)}, p.Stats())
+}
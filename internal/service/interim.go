@@ -0,0 +1,188 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/textmerge"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Delta modes of input_audio_transcription.delta_mode
+const (
+	DeltaModeFull    = "full"    // every delta carries the full hypothesis
+	DeltaModeCompact = "compact" // deltas carry the length of the kept prefix and the text after it
+)
+
+const (
+	// interimStepMs is the new speech between two interim recognition passes
+	interimStepMs = 1000
+	// maxInterimMs bounds the utterance audio kept for interim passes, longer
+	// utterances only get their final transcript
+	maxInterimMs = 30000
+)
+
+// interimTranscript keeps the audio of the utterance in progress and the hypotheses
+// recognized from it so far
+type interimTranscript struct {
+	mutex      sync.Mutex
+	samples    []int16
+	sinceStep  int
+	inFlight   bool
+	generation int // bumped on commit and clear so late hypotheses are dropped
+	itemID     string
+	tokens     []textmerge.Token
+	stream     *llm.RecognitionStream // utterance streamed to the engine, see asr_stream.go
+}
+
+// take ends the utterance in progress and returns the item ID its deltas were sent
+// with, empty when none were sent. A recognition stream not taken is abandoned.
+func (t *interimTranscript) take() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.stream != nil {
+		t.stream.Close()
+		t.stream = nil
+	}
+	itemID := t.itemID
+	t.samples = nil
+	t.sinceStep = 0
+	t.itemID = ""
+	t.tokens = nil
+	t.generation++
+	return itemID
+}
+
+// feedInterim buffers 16kHz audio while the session is speaking and starts an interim
+// recognition pass of the utterance so far every step. Sessions recognized by a
+// stream send the audio to it instead.
+func (s *OpenAIService) feedInterim(session *Session, samples []int16) {
+	if session.VADDetector == nil || len(samples) == 0 {
+		return
+	}
+	if !session.IsSpeaking && !session.VADDetector.IsSpeech() {
+		return
+	}
+	if s.streamsSession(session) {
+		s.feedStream(session, samples)
+		return
+	}
+	if !session.InputAudioTranscription.InterimResults {
+		return
+	}
+
+	t := &session.interim
+	t.mutex.Lock()
+	if len(t.samples) >= maxInterimMs*16 {
+		t.mutex.Unlock()
+		return
+	}
+	t.samples = append(t.samples, samples...)
+	t.sinceStep += len(samples)
+
+	// A pass still running skips this step, its successor sees the newer audio
+	if t.sinceStep < interimStepMs*16 || t.inFlight {
+		t.mutex.Unlock()
+		return
+	}
+	t.sinceStep = 0
+	t.inFlight = true
+	if t.itemID == "" {
+		t.itemID = session.NewItemID()
+	}
+	utterance := append([]int16(nil), t.samples...)
+	generation := t.generation
+	t.mutex.Unlock()
+
+	session.spawn(func() { s.transcribeInterim(session, utterance, generation) })
+}
+
+// transcribeInterim recognizes the utterance so far and sends the change from the
+// previous hypothesis
+func (s *OpenAIService) transcribeInterim(session *Session, utterance []int16, generation int) {
+	t := &session.interim
+	defer func() {
+		t.mutex.Lock()
+		t.inFlight = false
+		t.mutex.Unlock()
+	}()
+
+	wavData, err := s.convertToWAV(utterance)
+	if err != nil {
+		return
+	}
+	start := time.Now()
+	result, err := s.callRecognitionAPI(session.Context(), wavData, session.ForwardedHeaders, session.ASREndpoint)
+	session.recordASRCall(time.Since(start), err)
+	if err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "audio_recogniz",
+			"action":    "interim_recognition_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Warn("Interim recognition failed")
+		return
+	}
+	s.sendInterimDelta(session, generation, result.Text)
+}
+
+// sendInterimDelta sends the change of a hypothesis of the utterance from the previous
+// one as conversation.item.input_audio_transcription.delta
+func (s *OpenAIService) sendInterimDelta(session *Session, generation int, hypothesis string) {
+	t := &session.interim
+
+	// Holding the lock until sent keeps deltas ahead of the commit that ends the utterance
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if generation != t.generation {
+		return
+	}
+
+	merged := textmerge.Merge(t.tokens, hypothesis)
+	if merged.Delta == "" && merged.Replaced == 0 {
+		return
+	}
+	previous := textmerge.Join(t.tokens)
+	t.tokens = merged.Tokens
+
+	deltaEvent := &ConversationItemInputAudioTranscriptionDeltaEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeConversationItemInputAudioTranscriptionDelta,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		ItemID:        t.itemID,
+		ContentIndex:  0,
+		Delta:         merged.Delta,
+		Transcript:    merged.Text(),
+		ReplacedWords: merged.Replaced,
+	}
+	// Compact deltas leave the hypothesis to be rebuilt from the previous one by the client
+	if session.InputAudioTranscription.DeltaMode == DeltaModeCompact {
+		stable, rest := textmerge.CommonPrefix(previous, deltaEvent.Transcript)
+		deltaEvent.StablePrefix = &stable
+		deltaEvent.Delta = rest
+		deltaEvent.Transcript = ""
+	}
+	if err := s.sessionManager.SendEvent(session, deltaEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "ws_event_send ",
+			"action":    "send_transcription_delta_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Failed to send transcription delta event")
+		return
+	}
+
+	session.Logger().WithFields(logrus.Fields{
+		"component": "audio_recogniz",
+		"action":    "transcription_delta_sent",
+		"sessionID": session.ID,
+		"itemID":    t.itemID,
+		"delta":     merged.Delta,
+		"replaced":  merged.Replaced,
+	}).Debug("Sent interim transcription delta")
+}
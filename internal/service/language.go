@@ -0,0 +1,43 @@
+package service
+
+import (
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/langid"
+)
+
+// detectsLanguage reports whether the session leaves the language of its audio to be
+// detected: input_audio_transcription.language is unset or "auto"
+func (s *Session) detectsLanguage() bool {
+	language := s.InputAudioTranscription.Language
+	return language == "" || language == langid.Auto
+}
+
+// recognitionEndpoint returns the ASR endpoint of an item of the session with its
+// custom vocabulary, asking the backend to report the language it detected when the
+// session detects the language
+func (s *Session) recognitionEndpoint(hints []string) *llm.Endpoint {
+	detect := s.detectsLanguage()
+	if !detect && len(hints) == 0 {
+		return s.ASREndpoint
+	}
+	var endpoint llm.Endpoint
+	if s.ASREndpoint != nil {
+		endpoint = *s.ASREndpoint
+	}
+	endpoint.DetectLanguage = detect && endpoint.Language == ""
+	endpoint.Hints = hints
+	return &endpoint
+}
+
+// transcriptLanguage returns the language of a transcript for the language field of
+// the completed event: the language the engine detected or, when it reported none,
+// the one identified from the text. It is empty for sessions with a set language.
+func (s *Session) transcriptLanguage(result *llm.Transcription, text string) string {
+	if !s.detectsLanguage() {
+		return ""
+	}
+	if language := langid.Normalize(result.Language); language != "" {
+		return language
+	}
+	return langid.Detect(text)
+}
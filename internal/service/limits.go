@@ -0,0 +1,273 @@
+package service
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/config"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// Limits enforced by a LimitPolicy
+const (
+	LimitSessionDuration = "session_duration"
+	LimitAudioMinutes    = "audio_minutes"
+)
+
+const (
+	defaultWarnRatio   = 0.8
+	defaultPeriodHours = 24
+)
+
+// LimitStatus reports a limit of a session's tier that is approaching or exceeded
+type LimitStatus struct {
+	Limit    string
+	Tier     string
+	Used     time.Duration
+	Max      time.Duration
+	Exceeded bool
+}
+
+// LimitPolicy decides which usage limits apply to a session and when they are reached
+type LimitPolicy interface {
+	// Identify returns the API key and tier of the WebSocket upgrade request
+	Identify(r *http.Request) (apiKey string, tier string)
+	// Check records audio consumed by the session and returns the limits at or above
+	// their warning threshold
+	Check(session *Session, audio time.Duration) []LimitStatus
+}
+
+type keyUsage struct {
+	periodStart time.Time
+	audio       time.Duration
+}
+
+// TierLimitPolicy enforces the per-tier limits of the configuration, keeping audio
+// usage per API key in memory
+type TierLimitPolicy struct {
+	defaultTier string
+	warnRatio   float64
+	tiers       map[string]config.TierLimits
+	apiKeys     map[string]string
+
+	usage map[string]*keyUsage
+	mutex sync.Mutex
+}
+
+// NewTierLimitPolicy creates a policy from the limits section of the configuration
+func NewTierLimitPolicy(cfg *config.Config) *TierLimitPolicy {
+	warnRatio := cfg.Limits.WarnRatio
+	if warnRatio <= 0 || warnRatio > 1 {
+		warnRatio = defaultWarnRatio
+	}
+	return &TierLimitPolicy{
+		defaultTier: cfg.Limits.DefaultTier,
+		warnRatio:   warnRatio,
+		tiers:       cfg.Limits.Tiers,
+		apiKeys:     cfg.Limits.APIKeys,
+		usage:       make(map[string]*keyUsage),
+	}
+}
+
+// Identify reads the API key from the Authorization bearer token, the X-API-Key
+// header or the api_key query parameter
+func (p *TierLimitPolicy) Identify(r *http.Request) (string, string) {
+	apiKey := ""
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		apiKey = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	} else if key := r.Header.Get("X-API-Key"); key != "" {
+		apiKey = key
+	} else {
+		apiKey = r.URL.Query().Get("api_key")
+	}
+
+	if tier, ok := p.apiKeys[apiKey]; ok && apiKey != "" {
+		return apiKey, tier
+	}
+	return apiKey, p.defaultTier
+}
+
+// Check adds the audio to the API key's usage and evaluates the tier's limits
+func (p *TierLimitPolicy) Check(session *Session, audio time.Duration) []LimitStatus {
+	limits, ok := p.tiers[session.Tier]
+	if !ok {
+		return nil
+	}
+
+	var statuses []LimitStatus
+	if limits.MaxSessionSeconds > 0 {
+		max := time.Duration(limits.MaxSessionSeconds) * time.Second
+		if status, ok := p.evaluate(LimitSessionDuration, session.Tier, time.Since(session.CreatedAt), max); ok {
+			statuses = append(statuses, status)
+		}
+	}
+
+	if limits.MaxAudioMinutes > 0 {
+		max := time.Duration(limits.MaxAudioMinutes * float64(time.Minute))
+		if status, ok := p.evaluate(LimitAudioMinutes, session.Tier, p.addAudio(session.APIKey, limits, audio), max); ok {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// addAudio records audio against an API key and returns its usage in the current period
+func (p *TierLimitPolicy) addAudio(apiKey string, limits config.TierLimits, audio time.Duration) time.Duration {
+	period := time.Duration(limits.PeriodHours) * time.Hour
+	if period <= 0 {
+		period = defaultPeriodHours * time.Hour
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	usage, ok := p.usage[apiKey]
+	if !ok || time.Since(usage.periodStart) >= period {
+		usage = &keyUsage{periodStart: time.Now()}
+		p.usage[apiKey] = usage
+	}
+	usage.audio += audio
+	return usage.audio
+}
+
+func (p *TierLimitPolicy) evaluate(limit, tier string, used, max time.Duration) (LimitStatus, bool) {
+	if float64(used) < float64(max)*p.warnRatio {
+		return LimitStatus{}, false
+	}
+	return LimitStatus{
+		Limit:    limit,
+		Tier:     tier,
+		Used:     used,
+		Max:      max,
+		Exceeded: used >= max,
+	}, true
+}
+
+// SetLimitPolicy replaces the usage limit policy used for new sessions, nil disables limits
+func (s *OpenAIService) SetLimitPolicy(policy LimitPolicy) {
+	s.limitPolicy = policy
+}
+
+// enforceLimits checks the session against its tier's limits, sending a warning the
+// first time a limit approaches. When a limit is exceeded it sends the terminal
+// session.limit_exceeded event, closes the connection and returns true.
+func (s *OpenAIService) enforceLimits(session *Session, audio time.Duration) bool {
+	if s.limitPolicy == nil {
+		return false
+	}
+
+	for _, status := range s.limitPolicy.Check(session, audio) {
+		if status.Exceeded {
+			s.terminateForLimit(session, status)
+			return true
+		}
+
+		session.limitMutex.Lock()
+		warned := session.limitWarned[status.Limit]
+		if !warned {
+			if session.limitWarned == nil {
+				session.limitWarned = make(map[string]bool)
+			}
+			session.limitWarned[status.Limit] = true
+		}
+		session.limitMutex.Unlock()
+		if warned {
+			continue
+		}
+
+		warningEvent := &SessionLimitWarningEvent{
+			BaseEvent: BaseEvent{
+				Type:      EventTypeSessionLimitWarning,
+				EventID:   session.NewEventID(),
+				SessionID: session.ID,
+			},
+			Limit: newLimitDetails(status),
+		}
+		if err := s.sessionManager.SendEvent(session, warningEvent); err != nil {
+			session.Logger().WithFields(logrus.Fields{
+				"component": "ws_event_send ",
+				"action":    "send_limit_warning_failed",
+				"sessionID": session.ID,
+				"error":     err,
+			}).Error("Failed to send session.limit_warning event")
+		}
+
+		session.Logger().WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "limit_approaching",
+			"sessionID": session.ID,
+			"tier":      status.Tier,
+			"limit":     status.Limit,
+			"used":      status.Used.String(),
+			"max":       status.Max.String(),
+		}).Info("Session approaching usage limit")
+	}
+	return false
+}
+
+// terminateForLimit sends session.limit_exceeded and closes the connection with a
+// policy violation close frame
+func (s *OpenAIService) terminateForLimit(session *Session, status LimitStatus) {
+	session.limitMutex.Lock()
+	if session.limitExceeded {
+		session.limitMutex.Unlock()
+		return
+	}
+	session.limitExceeded = true
+	session.limitMutex.Unlock()
+
+	session.Logger().WithFields(logrus.Fields{
+		"component": "svc_openai_api ",
+		"action":    "limit_exceeded",
+		"sessionID": session.ID,
+		"tier":      status.Tier,
+		"limit":     status.Limit,
+		"used":      status.Used.String(),
+		"max":       status.Max.String(),
+	}).Warn("Session exceeded usage limit, terminating")
+
+	exceededEvent := &SessionLimitExceededEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeSessionLimitExceeded,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		Limit: newLimitDetails(status),
+	}
+	if err := s.sessionManager.SendEvent(session, exceededEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "ws_event_send ",
+			"action":    "send_limit_exceeded_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Failed to send session.limit_exceeded event")
+	}
+	s.sessionManager.sendSessionAnalytics(session)
+	s.sessionManager.drainOutbound(session, time.Second)
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if session.poll != nil {
+		session.poll.close()
+	}
+	if session.Conn != nil {
+		message := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "limit_exceeded")
+		session.Conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+		session.Conn.Close()
+	}
+}
+
+func newLimitDetails(status LimitStatus) LimitDetails {
+	return LimitDetails{
+		Name:             status.Limit,
+		Tier:             status.Tier,
+		UsedSeconds:      math.Round(status.Used.Seconds()*10) / 10,
+		LimitSeconds:     status.Max.Seconds(),
+		RemainingSeconds: math.Max(0, math.Round((status.Max-status.Used).Seconds()*10)/10),
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/offlineasr"
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/tracing"
+	"github.com/go-restream/stt/pkg/wav"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newLocalRecognizer loads the offline model of asr.mode "local". When it cannot be
+// loaded the service falls back to the HTTP engine rather than refusing to start.
+func newLocalRecognizer(cfg *config.Config) *offlineasr.Recognizer {
+	recognizer, err := offlineasr.NewRecognizer(cfg)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "local_asr_unavailable",
+			"modelType": cfg.ASR.Local.ModelType,
+			"error":     err,
+		}).Error("Failed to load the local recognition model, falling back to the HTTP engine")
+		return nil
+	}
+	return recognizer
+}
+
+// transcribeLocal recognizes a WAV utterance with the in-process model
+func (s *OpenAIService) transcribeLocal(ctx context.Context, wavData []byte) (*llm.Transcription, error) {
+	reader, err := wav.NewReader(bytes.NewReader(wavData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio: %v", err)
+	}
+	format := reader.GetFormat()
+	pcm := make([]int16, reader.GetDataSize()/2)
+	n, err := reader.ReadSamples(pcm)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	samples := make([]float32, n)
+	for i, sample := range pcm[:n] {
+		samples[i] = float32(sample) / 32768.0
+	}
+
+	start := time.Now()
+	result := s.localASR.Transcribe(samples, int(format.SampleRate))
+
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttribute("asr.backend", "local")
+	if result.Language != "" {
+		span.SetAttribute("asr.language", result.Language)
+	}
+	logger.WithFields(logrus.Fields{
+		"component": "api_asr_core",
+		"action":    "local_recognition_completed",
+		"samples":   n,
+		"language":  result.Language,
+		"decodeMs":  time.Since(start).Milliseconds(),
+	}).Debug("Local recognition completed")
+
+	return &llm.Transcription{Text: result.Text, Language: result.Language}, nil
+}
@@ -0,0 +1,598 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-restream/stt/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// sessionTokenHeader carries the resume token of the session, returned when a
+// long-poll session is created. Session IDs are predictable, so every request of the
+// session must present it.
+const sessionTokenHeader = "X-Session-Token"
+
+// maxPollAudioBytes limits the body of one audio POST
+const maxPollAudioBytes = 4 << 20
+
+// pollOutbox keeps the events of a long-poll session until the client acknowledges
+// them. Events are numbered; a poll with after=N acknowledges the events up to N, so a
+// response lost on a flaky mobile network is delivered again by the next poll.
+type pollOutbox struct {
+	mutex     sync.Mutex
+	events    []polledEvent
+	lastSeq   uint64
+	delivered uint64 // last sequence returned, acknowledged by polls without after
+	dropped   int    // events lost to the buffer limit since the last poll
+	limit     int
+	closed    bool
+	wake      chan struct{} // closed and replaced on every push
+}
+
+type polledEvent struct {
+	seq  uint64
+	data json.RawMessage
+}
+
+// pollBatch is the response of an events poll
+type pollBatch struct {
+	Events  []json.RawMessage `json:"events"`
+	Next    uint64            `json:"next"`              // after value acknowledging this batch
+	Dropped int               `json:"dropped,omitempty"` // events lost because the client polled too slowly
+	Closed  bool              `json:"closed,omitempty"`  // the session ended, no events follow these
+}
+
+func newPollOutbox(limit int) *pollOutbox {
+	return &pollOutbox{limit: limit, wake: make(chan struct{})}
+}
+
+// push queues an event, dropping the oldest one beyond the buffer limit
+func (o *pollOutbox) push(data []byte) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.closed {
+		return fmt.Errorf("session closed")
+	}
+	o.lastSeq++
+	o.events = append(o.events, polledEvent{seq: o.lastSeq, data: data})
+	if len(o.events) > o.limit {
+		drop := len(o.events) - o.limit
+		o.events = o.events[drop:]
+		o.dropped += drop
+	}
+	close(o.wake)
+	o.wake = make(chan struct{})
+	return nil
+}
+
+// close ends the outbox; queued events can still be polled
+func (o *pollOutbox) close() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if !o.closed {
+		o.closed = true
+		close(o.wake)
+	}
+}
+
+func (o *pollOutbox) isClosed() bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.closed
+}
+
+// take acknowledges the events up to after, or up to the last delivered one when
+// after is negative, and returns the later ones along with the channel closed by the
+// next push
+func (o *pollOutbox) take(after int64) (pollBatch, <-chan struct{}) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	ack := o.delivered
+	if after >= 0 {
+		ack = uint64(after)
+	}
+	i := 0
+	for i < len(o.events) && o.events[i].seq <= ack {
+		i++
+	}
+	o.events = o.events[i:]
+
+	batch := pollBatch{Events: make([]json.RawMessage, 0, len(o.events)), Next: ack, Dropped: o.dropped}
+	for _, event := range o.events {
+		batch.Events = append(batch.Events, event.data)
+		batch.Next = event.seq
+	}
+	o.dropped = 0
+	o.delivered = batch.Next
+	batch.Closed = o.closed
+	return batch, o.wake
+}
+
+// wait returns the events after the cursor as soon as there are some, or an empty
+// batch once maxWait has passed
+func (o *pollOutbox) wait(ctx context.Context, after int64, maxWait time.Duration) pollBatch {
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+
+	for {
+		batch, wake := o.take(after)
+		if len(batch.Events) > 0 || batch.Closed {
+			return batch
+		}
+		select {
+		case <-wake:
+		case <-timer.C:
+			return batch
+		case <-ctx.Done():
+			return batch
+		}
+	}
+}
+
+// pollClient is a session served by the long-poll transport
+type pollClient struct {
+	session  *Session
+	outbox   *pollOutbox
+	gen      int64 // transport generation, see handoff.go
+	cancel   context.CancelFunc
+	lastSeen atomic.Int64 // unix nanoseconds of the last request
+}
+
+// pollSessions is the registry of long-poll sessions
+type pollSessions struct {
+	mutex       sync.Mutex
+	clients     map[string]*pollClient
+	maxWait     time.Duration
+	idleTimeout time.Duration
+	bufferLimit int
+}
+
+func newPollSessions(cfg *config.Config) *pollSessions {
+	p := &pollSessions{
+		clients:     make(map[string]*pollClient),
+		maxWait:     time.Duration(cfg.LongPoll.MaxWaitSeconds) * time.Second,
+		idleTimeout: time.Duration(cfg.LongPoll.IdleTimeoutSeconds) * time.Second,
+		bufferLimit: cfg.LongPoll.MaxBufferedEvents,
+	}
+	if p.maxWait <= 0 {
+		p.maxWait = 30 * time.Second
+	}
+	if p.idleTimeout <= 0 {
+		p.idleTimeout = 60 * time.Second
+	}
+	// A client must be able to poll again before it counts as gone
+	if p.idleTimeout < 2*p.maxWait {
+		p.idleTimeout = 2 * p.maxWait
+	}
+	if p.bufferLimit <= 0 {
+		p.bufferLimit = 1000
+	}
+	return p
+}
+
+func (p *pollSessions) add(client *pollClient) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.clients[client.session.ID] = client
+}
+
+func (p *pollSessions) remove(sessionID string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.clients, sessionID)
+}
+
+// take removes a session from the registry and returns it, nil when it is not there
+func (p *pollSessions) take(sessionID string) *pollClient {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	client := p.clients[sessionID]
+	delete(p.clients, sessionID)
+	return client
+}
+
+func (p *pollSessions) list() []*pollClient {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	clients := make([]*pollClient, 0, len(p.clients))
+	for _, client := range p.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// lookup returns the session of the request when its token matches, writing the error
+// response otherwise
+func (p *pollSessions) lookup(c *gin.Context) (*pollClient, bool) {
+	p.mutex.Lock()
+	client, ok := p.clients[c.Param("session")]
+	p.mutex.Unlock()
+
+	if !ok {
+		openAIError(c, http.StatusNotFound, "invalid_request_error", "session not found", "session")
+		return nil, false
+	}
+	token := c.GetHeader(sessionTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(client.session.ResumeToken)) != 1 {
+		openAIError(c, http.StatusUnauthorized, "invalid_request_error", "invalid session token", sessionTokenHeader)
+		return nil, false
+	}
+	client.lastSeen.Store(time.Now().UnixNano())
+	return client, true
+}
+
+// createPollSession opens a session for a client of the long-poll transport. The
+// checks and setup are those of a WebSocket upgrade; refusals are HTTP errors.
+func (s *OpenAIService) createPollSession(c *gin.Context) {
+	var principal *Principal
+	if s.authenticator != nil {
+		var err error
+		if principal, err = s.authenticator.Authenticate(c.Request); err != nil {
+			rejectUnauthenticated(c, err)
+			return
+		}
+		if !principal.HasScope(ScopeTranscribe) {
+			rejectMissingScope(c, principal, ScopeTranscribe)
+			return
+		}
+	}
+
+	lease := &sessionLease{}
+	if principal != nil {
+		if !s.sessionQuota.acquire(principal) {
+			openAIError(c, http.StatusTooManyRequests, "invalid_request_error",
+				fmt.Sprintf("concurrent session limit of %d reached", principal.MaxSessions), "")
+			return
+		}
+		lease.principal = principal
+	}
+
+	if s.rateLimiter != nil {
+		lease.rateKey, lease.rateIP = rateLimitClient(c, principal)
+		if details := s.rateLimiter.openSession(lease.rateKey, lease.rateIP); details != nil {
+			s.releaseLease(lease)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": newRateLimitErrorEvent(GenerateEventID(), "", *details).Error})
+			return
+		}
+		lease.rateOpen = true
+	}
+
+	session, err := s.sessionManager.CreateSession(nil, "audio")
+	if err != nil {
+		s.releaseLease(lease)
+		openAIError(c, http.StatusServiceUnavailable, "server_error", err.Error(), "")
+		return
+	}
+	session.lease = lease
+	outbox := newPollOutbox(s.polls.bufferLimit)
+	session.poll = outbox
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &pollClient{session: session, outbox: outbox, gen: session.attachTransport(), cancel: cancel}
+	client.lastSeen.Store(time.Now().UnixNano())
+
+	s.setupSession(c, session, principal, lease.rateKey, lease.rateIP)
+	s.polls.add(client)
+	s.sendSessionCreated(session)
+	go s.reportLoop(ctx, session)
+
+	session.Logger().WithFields(logrus.Fields{
+		"component": "svc_openai_api ",
+		"action":    "poll_session_created",
+		"sessionID": session.ID,
+		"clientIP":  c.ClientIP(),
+	}).Info("Created long-poll session")
+
+	c.JSON(http.StatusCreated, s.pollSessionResponse(session))
+}
+
+// pollSessionResponse describes a long-poll session to its client
+func (s *OpenAIService) pollSessionResponse(session *Session) gin.H {
+	base := "/v1/realtime/" + session.ID
+	return gin.H{
+		"id":                   session.ID,
+		"object":               "realtime.session",
+		"token":                session.ResumeToken,
+		"audio_url":            base + "/audio",
+		"events_url":           base + "/events",
+		"max_wait_seconds":     int(s.polls.maxWait.Seconds()),
+		"idle_timeout_seconds": int(s.polls.idleTimeout.Seconds()),
+	}
+}
+
+// endPollSession tears a long-poll session down the way a closed WebSocket is, unless
+// it moved to a WebSocket meanwhile. Queued events stay available to the client until
+// it is removed from the registry.
+func (s *OpenAIService) endPollSession(client *pollClient, reason string) {
+	client.cancel()
+	// The outbox closes after the session ended, so its end events are queued
+	defer client.outbox.close()
+	if s.detachTransport(client.session, client.gen, reason) {
+		client.session.Logger().WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "poll_session_ended",
+			"sessionID": client.session.ID,
+			"reason":    reason,
+		}).Info("Ended long-poll session")
+	}
+}
+
+// pollGCLoop ends the long-poll sessions whose client stopped polling, and those a
+// usage limit or the session sweep ended, and applies the duration limits that the
+// heartbeat loop enforces on WebSocket sessions
+func (s *OpenAIService) pollGCLoop(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, client := range s.polls.list() {
+				if now.Sub(time.Unix(0, client.lastSeen.Load())) > s.polls.idleTimeout {
+					s.endPollSession(client, SessionEndTimeout)
+					s.polls.remove(client.session.ID)
+					continue
+				}
+
+				if client.outbox.isClosed() {
+					s.endPollSession(client, SessionEndClosed)
+				} else if !s.sessionManager.SessionExists(client.session.ID) {
+					s.endPollSession(client, SessionEndTimeout)
+				} else {
+					s.enforceLimits(client.session, 0)
+				}
+			}
+		}
+	}
+}
+
+// pollAfter parses the after cursor of an events poll, -1 when absent
+func pollAfter(c *gin.Context) (int64, error) {
+	value := c.Query("after")
+	if value == "" {
+		return -1, nil
+	}
+	after, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || after < 0 {
+		return 0, fmt.Errorf("after must be a non-negative integer")
+	}
+	return after, nil
+}
+
+// pollWait returns the wait query parameter bounded by max_wait_seconds
+func (p *pollSessions) pollWait(c *gin.Context) time.Duration {
+	wait := p.maxWait
+	if value, err := strconv.Atoi(c.Query("wait")); err == nil && value >= 0 {
+		if requested := time.Duration(value) * time.Second; requested < wait {
+			wait = requested
+		}
+	}
+	return wait
+}
+
+// longPoll returns the service when the long-poll transport is enabled, writing the
+// error response otherwise
+func longPoll(c *gin.Context) (*OpenAIService, bool) {
+	if openAIService == nil {
+		openAIError(c, http.StatusServiceUnavailable, "server_error", "OpenAI service not initialized", "")
+		return nil, false
+	}
+	if openAIService.polls == nil {
+		openAIError(c, http.StatusNotFound, "invalid_request_error", "long-poll transport is disabled", "")
+		return nil, false
+	}
+	return openAIService, true
+}
+
+// handlePollSessionCreate is POST /v1/realtime/sessions, opening a long-poll session or,
+// with {"resume": "<session>"}, moving a WebSocket session to long-poll
+func handlePollSessionCreate(c *gin.Context) {
+	s, ok := longPoll(c)
+	if !ok {
+		return
+	}
+	if resumeID := resumeSessionID(c); resumeID != "" {
+		s.resumeOnLongPoll(c, resumeID)
+		return
+	}
+	s.createPollSession(c)
+}
+
+// handlePollAudio is POST /v1/realtime/:session/audio. The body is raw audio in the
+// session's input_audio_format, appended as an input_audio_buffer.append would.
+func handlePollAudio(c *gin.Context) {
+	s, ok := longPoll(c)
+	if !ok {
+		return
+	}
+	client, ok := s.polls.lookup(c)
+	if !ok {
+		return
+	}
+	session := client.session
+	if client.outbox.isClosed() {
+		openAIError(c, http.StatusGone, "invalid_request_error", "session closed", "")
+		return
+	}
+
+	audio, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPollAudioBytes))
+	if err != nil {
+		openAIError(c, http.StatusRequestEntityTooLarge, "invalid_request_error",
+			fmt.Sprintf("audio chunk exceeds %d bytes", maxPollAudioBytes), "")
+		return
+	}
+	if len(audio) == 0 {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "audio is required", "")
+		return
+	}
+
+	event := &InputAudioBufferAppendEvent{
+		BaseEvent: BaseEvent{
+			Type:    EventTypeInputAudioBufferAppend,
+			EventID: GenerateEventID(),
+		},
+		Audio: base64.StdEncoding.EncodeToString(audio),
+	}
+
+	// Counted in its base64 size, as audio is over the WebSocket, so the inbound audio
+	// share of the bandwidth stats stays comparable between transports
+	session.bandwidth.addInbound(len(event.Audio))
+	if !s.allowClientEvent(session) {
+		openAIError(c, http.StatusTooManyRequests, "invalid_request_error", "event rate limit exceeded", "")
+		return
+	}
+	s.sessionManager.UpdateHeartbeat(session.ID)
+
+	if err := s.handleInputAudioBufferAppend(session, event); err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error(), "")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handlePollClientEvent is POST /v1/realtime/:session/events, taking one client event
+// such as session.update or input_audio_buffer.commit as its JSON body
+func handlePollClientEvent(c *gin.Context) {
+	s, ok := longPoll(c)
+	if !ok {
+		return
+	}
+	client, ok := s.polls.lookup(c)
+	if !ok {
+		return
+	}
+	session := client.session
+	if client.outbox.isClosed() {
+		openAIError(c, http.StatusGone, "invalid_request_error", "session closed", "")
+		return
+	}
+
+	message, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPollAudioBytes))
+	if err != nil {
+		openAIError(c, http.StatusRequestEntityTooLarge, "invalid_request_error",
+			fmt.Sprintf("event exceeds %d bytes", maxPollAudioBytes), "")
+		return
+	}
+
+	session.bandwidth.addInbound(len(message))
+	if !s.allowClientEvent(session) {
+		openAIError(c, http.StatusTooManyRequests, "invalid_request_error", "event rate limit exceeded", "")
+		return
+	}
+	s.sessionManager.UpdateHeartbeat(session.ID)
+
+	if err := s.handleTextMessage(session, message); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "handle_message_error",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Error handling message")
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error(), "")
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// handlePollEvents is GET /v1/realtime/:session/events. It answers as soon as events
+// are queued, or empty after the wait; with stream=true events are written as NDJSON
+// lines over a chunked response instead, until the wait ends.
+func handlePollEvents(c *gin.Context) {
+	s, ok := longPoll(c)
+	if !ok {
+		return
+	}
+	client, ok := s.polls.lookup(c)
+	if !ok {
+		return
+	}
+	session := client.session
+	if !client.outbox.isClosed() {
+		s.sessionManager.UpdateHeartbeat(session.ID)
+	}
+
+	after, err := pollAfter(c)
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error(), "after")
+		return
+	}
+	wait := s.polls.pollWait(c)
+
+	if c.Query("stream") != "true" {
+		batch := client.outbox.wait(c.Request.Context(), after, wait)
+		if batch.Closed && len(batch.Events) == 0 {
+			s.polls.remove(session.ID)
+		}
+		c.JSON(http.StatusOK, batch)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), wait)
+	defer cancel()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	for ctx.Err() == nil {
+		batch := client.outbox.wait(ctx, after, wait)
+		for _, event := range batch.Events {
+			c.Writer.Write(event)
+			c.Writer.Write([]byte("\n"))
+		}
+		c.Writer.Flush()
+		// Streamed events count as delivered once written
+		after = int64(batch.Next)
+		if batch.Closed {
+			s.polls.remove(session.ID)
+			return
+		}
+	}
+}
+
+// handlePollSessionDelete is DELETE /v1/realtime/:session. It ends the session and
+// returns the events not polled yet, ending with session.analytics.
+func handlePollSessionDelete(c *gin.Context) {
+	s, ok := longPoll(c)
+	if !ok {
+		return
+	}
+	client, ok := s.polls.lookup(c)
+	if !ok {
+		return
+	}
+	session := client.session
+
+	s.endPollSession(client, SessionEndClosed)
+	s.polls.remove(session.ID)
+
+	batch, _ := client.outbox.take(-1)
+	c.JSON(http.StatusOK, batch)
+}
+
+// stopPollSessions ends every long-poll session on shutdown
+func (s *OpenAIService) stopPollSessions() {
+	for _, client := range s.polls.list() {
+		s.endPollSession(client, SessionEndClosed)
+		s.polls.remove(client.session.ID)
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// SpeechStoppedReasonMaxDuration is the reason of input_audio_buffer.speech_stopped when
+// the utterance reached vad.max_utterance_seconds. The server commits the speech as an
+// item itself and the speech that follows starts the next item.
+const SpeechStoppedReasonMaxDuration = "max_duration"
+
+// maxUtteranceSamples returns the 16kHz samples of speech source may buffer for one
+// item, 0 for no limit
+func (s *OpenAIService) maxUtteranceSamples(source *Session) int {
+	cfg := source.vadConfig
+	if cfg == nil {
+		cfg = s.sessionManager.Config()
+	}
+	if cfg == nil {
+		return 0
+	}
+	return int(cfg.Vad.MaxUtteranceSeconds * 16000)
+}
+
+// enforceMaxUtterance commits the speech buffered by source, the session or one of its
+// channel streams, once it reaches vad.max_utterance_seconds, so continuous speech does
+// not grow into a single huge recognition request. The buffer grows by VAD segments,
+// which vad.max_speech_duration bounds, so the limit is enforced at that granularity.
+func (s *OpenAIService) enforceMaxUtterance(session *Session, source *Session) {
+	limit := s.maxUtteranceSamples(source)
+	if limit <= 0 || !source.IsSpeaking {
+		return
+	}
+	size := source.vadAudioSize()
+	if size < limit {
+		return
+	}
+
+	session.Logger().WithFields(logrus.Fields{
+		"component":   "proc_audio_main",
+		"action":      "max_utterance_reached",
+		"sessionID":   session.ID,
+		"sampleCount": size,
+		"channel":     source.channelIndex(),
+	}).Info("Utterance reached the maximum length, committing without a pause")
+
+	s.vadIntegration.stopSpeech(source, SpeechStoppedReasonMaxDuration)
+
+	// The commit has a server event ID, so clients tell it from their own commits
+	commit := &InputAudioBufferCommitEvent{
+		BaseEvent: BaseEvent{
+			Type:    EventTypeInputAudioBufferCommit,
+			EventID: GenerateEventID(),
+		},
+	}
+	if _, err := s.recognizeStream(session, source, commit); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "proc_audio_main",
+			"action":    "max_utterance_commit_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Warn("Failed to commit the utterance that reached the maximum length")
+	}
+}
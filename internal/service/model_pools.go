@@ -0,0 +1,141 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/denoiser"
+	"github.com/go-restream/stt/pkg/modelpool"
+	"github.com/go-restream/stt/vad"
+)
+
+// ModelPoolStats are the counters of the pool of one VAD or denoiser configuration
+type ModelPoolStats struct {
+	Kind  string `json:"kind"` // "vad" or "denoiser"
+	Model string `json:"model"`
+	modelpool.Stats
+}
+
+// sessionModelPools keeps a pool per VAD and denoiser configuration, so pipelines
+// with different settings never share instances. Pools are created on first use.
+//
+// VAD detectors are stateful and leased for the lifetime of a session; the pool
+// saves the model load of each new session once earlier sessions ended. Denoisers
+// only process a segment at a time and are leased per segment, so a few instances
+// serve every session.
+type sessionModelPools struct {
+	mutex    sync.Mutex
+	vad      map[string]*modelpool.Pool[*vad.VADDetector]
+	denoiser map[string]*modelpool.Pool[*denoiser.DenoiserProcessor]
+	models   map[string]string // pool key to model path, for stats
+}
+
+var modelPools = &sessionModelPools{
+	vad:      make(map[string]*modelpool.Pool[*vad.VADDetector]),
+	denoiser: make(map[string]*modelpool.Pool[*denoiser.DenoiserProcessor]),
+	models:   make(map[string]string),
+}
+
+// vadPool returns the pool of VAD detectors for the VAD settings of cfg
+func (p *sessionModelPools) vadPool(cfg *config.Config) *modelpool.Pool[*vad.VADDetector] {
+	key := fmt.Sprintf("vad %+v", cfg.Vad)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	pool, exists := p.vad[key]
+	if !exists {
+		pool = modelpool.New(func() (*vad.VADDetector, error) {
+			return vad.NewVADDetector(cfg), nil
+		}, (*vad.VADDetector).Reset, (*vad.VADDetector).Close, cfg.ModelPool.MaxIdle)
+		p.vad[key] = pool
+		p.models[key] = cfg.Vad.Model
+	}
+	return pool
+}
+
+// denoiserPool returns the pool of denoisers for the denoiser settings of cfg
+func (p *sessionModelPools) denoiserPool(cfg *config.Config) *modelpool.Pool[*denoiser.DenoiserProcessor] {
+	key := fmt.Sprintf("denoiser %+v", cfg.Denoiser)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	pool, exists := p.denoiser[key]
+	if !exists {
+		pool = modelpool.New(func() (*denoiser.DenoiserProcessor, error) {
+			return denoiser.NewDenoiserProcessor(cfg), nil
+		}, (*denoiser.DenoiserProcessor).Reset, (*denoiser.DenoiserProcessor).Close, cfg.ModelPool.MaxIdle)
+		p.denoiser[key] = pool
+		p.models[key] = cfg.Denoiser.Model
+	}
+	return pool
+}
+
+// Stats returns the counters of every pool, ordered by kind and model
+func (p *sessionModelPools) Stats() []ModelPoolStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stats := make([]ModelPoolStats, 0, len(p.vad)+len(p.denoiser))
+	for key, pool := range p.vad {
+		stats = append(stats, ModelPoolStats{Kind: "vad", Model: p.models[key], Stats: pool.Stats()})
+	}
+	for key, pool := range p.denoiser {
+		stats = append(stats, ModelPoolStats{Kind: "denoiser", Model: p.models[key], Stats: pool.Stats()})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Kind != stats[j].Kind {
+			return stats[i].Kind < stats[j].Kind
+		}
+		return stats[i].Model < stats[j].Model
+	})
+	return stats
+}
+
+// acquireVADDetector leases a VAD detector with the VAD settings of cfg, returning
+// the session's previous detector to its pool
+func (s *Session) acquireVADDetector(cfg *config.Config) {
+	s.releaseVADDetector()
+
+	pool := modelPools.vadPool(cfg)
+	detector, err := pool.Get()
+	if err != nil {
+		return
+	}
+	s.VADDetector = detector
+	s.vadPool = pool
+	s.vadConfig = cfg
+}
+
+// releaseVADDetector returns the session's VAD detector to its pool
+func (s *Session) releaseVADDetector() {
+	if s.VADDetector == nil {
+		return
+	}
+	if s.vadPool != nil {
+		s.vadPool.Put(s.VADDetector)
+	} else {
+		s.VADDetector.Close()
+	}
+	s.VADDetector = nil
+	s.vadPool = nil
+	s.vadConfig = nil
+}
+
+// denoise runs a speech segment through a denoiser leased from the session's pool,
+// returning nil when the session has no denoiser
+func (s *Session) denoise(segment *vad.SpeechSegment) *vad.SpeechSegment {
+	pool := s.denoiserPool
+	if pool == nil {
+		return nil
+	}
+	processor, err := pool.Get()
+	if err != nil {
+		return nil
+	}
+	defer pool.Put(processor)
+	return processor.ProcessSegment(segment)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/denoise"
+	"github.com/go-restream/stt/vad"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NoiseReductionConfig is the input_audio_noise_reduction of session.update. An object
+// enables the denoiser for the speech segments of the session, null disables it as in
+// the OpenAI API.
+type NoiseReductionConfig struct {
+	Type     string   `json:"type,omitempty"`     // "near_field" or "far_field", accepted for compatibility
+	Enabled  *bool    `json:"enabled,omitempty"`  // false disables noise reduction like null
+	Strength *float64 `json:"strength,omitempty"` // 0-1 blend of the denoised into the original audio, default denoiser.strength
+}
+
+// enabled reports whether the config turns noise reduction on
+func (c *NoiseReductionConfig) enabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// parseNoiseReduction decodes input_audio_noise_reduction, nil when it was not given
+func parseNoiseReduction(raw json.RawMessage) (*NoiseReductionConfig, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if string(raw) == "null" {
+		disabled := false
+		return &NoiseReductionConfig{Enabled: &disabled}, nil
+	}
+
+	var c NoiseReductionConfig
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("invalid input_audio_noise_reduction: %v", err)
+	}
+	if c.Strength != nil && (*c.Strength < 0 || *c.Strength > 1) {
+		return nil, fmt.Errorf("noise reduction strength %v out of range 0-1", *c.Strength)
+	}
+	return &c, nil
+}
+
+// applyNoiseReduction enables or disables the denoiser of a session as its
+// NoiseReduction asks, with the denoiser settings of cfg. Sessions that never sent
+// input_audio_noise_reduction keep the denoiser of their defaults or pipeline.
+func applyNoiseReduction(sess *Session, cfg *config.Config) {
+	c := sess.NoiseReduction
+	if c == nil {
+		return
+	}
+
+	sess.denoiserPool = nil
+	if c.enabled() {
+		derived := *cfg
+		derived.Denoiser.Enable = true
+		sess.denoiserPool = modelPools.denoiserPool(&derived)
+	}
+
+	sess.Logger().WithFields(logrus.Fields{
+		"component": "mg_session_ctrl",
+		"action":    "noise_reduction_applied",
+		"sessionID": sess.ID,
+		"enabled":   c.enabled(),
+		"strength":  sess.noiseReductionStrength(cfg),
+	}).Info("Session noise reduction updated")
+}
+
+// noiseReductionStrength returns the blend of denoised audio of the session
+func (s *Session) noiseReductionStrength(cfg *config.Config) float64 {
+	if s.NoiseReduction != nil && s.NoiseReduction.Strength != nil {
+		return *s.NoiseReduction.Strength
+	}
+	if cfg != nil && cfg.Denoiser.Strength > 0 {
+		return cfg.Denoiser.Strength
+	}
+	return 1
+}
+
+// denoiseWithStrength denoises a segment and blends it into the original at the
+// session's strength, nil when the session has no denoiser
+func (s *Session) denoiseWithStrength(segment *vad.SpeechSegment, cfg *config.Config) *vad.SpeechSegment {
+	enhanced := s.denoise(segment)
+	if enhanced == nil || enhanced == segment {
+		return enhanced
+	}
+	return &vad.SpeechSegment{
+		Start:   segment.Start,
+		Samples: denoise.Mix(segment.Samples, enhanced.Samples, s.noiseReductionStrength(cfg)),
+	}
+}
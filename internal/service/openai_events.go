@@ -4,8 +4,14 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"hash/crc32"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/go-restream/stt/pkg/audioquality"
+	"github.com/go-restream/stt/pkg/errcode"
+	"github.com/go-restream/stt/pkg/textformat"
 )
 
 // Event types for OpenAI Realtime API
@@ -25,9 +31,23 @@ const (
 	EventTypeConversationItemCreated    = "conversation.item.created"
 	EventTypeConversationItemInputAudioTranscriptionCompleted = "conversation.item.input_audio_transcription.completed"
 	EventTypeConversationItemInputAudioTranscriptionFailed = "conversation.item.input_audio_transcription.failed"
+	EventTypeConversationItemInputAudioTranscriptionDelta = "conversation.item.input_audio_transcription.delta"
 	EventTypeConversationItemDeleted    = "conversation.item.deleted"
 	EventTypeInputAudioBufferCleared    = "input_audio_buffer.cleared"
+	EventTypeInputAudioBufferQualityWarning = "input_audio_buffer.quality_warning"
 	EventTypeError                      = "error"
+	EventTypeConversationTruncated      = "conversation.truncated"
+	EventTypeVADSegment                 = "vad.segment"
+	EventTypeSessionAnalytics           = "session.analytics"
+	EventTypeCaptionCue                 = "caption.cue"
+	EventTypeSessionLimitWarning        = "session.limit_warning"
+	EventTypeSessionLimitExceeded       = "session.limit_exceeded"
+	EventTypeTranscriptionWindow        = "transcription.window"
+	EventTypeSessionBandwidth           = "session.bandwidth"
+	EventTypeSessionStats               = "session.stats"
+	EventTypeSessionStatsRequest        = "session.stats.request"
+	EventTypeSessionResume              = "session.resume"
+	EventTypeSessionResumed             = "session.resumed"
 )
 
 // BaseEvent represents the common structure for all OpenAI events
@@ -35,6 +55,7 @@ type BaseEvent struct {
 	Type      string `json:"type"`
 	EventID   string `json:"event_id,omitempty"`
 	SessionID string `json:"session_id,omitempty"`
+	Seq       uint64 `json:"seq,omitempty"` // per-session sequence number of server events, set by SendEvent
 }
 
 // SessionCreatedEvent represents session.created event
@@ -46,6 +67,8 @@ type SessionCreatedEvent struct {
 		Model      string   `json:"model"`
 		Modalities []string `json:"modalities"`
 	} `json:"session"`
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	ResumeToken  string        `json:"resume_token,omitempty"` // moves the session to another transport
 }
 
 // SessionUpdateEvent represents session.update event
@@ -61,14 +84,18 @@ type SessionUpdateEvent struct {
 			SampleRate     int    `json:"sample_rate"`
 			Channels       int    `json:"channels"`
 		} `json:"input_audio_format,omitempty"`
+		InputEncoding *string `json:"input_encoding,omitempty"` // "base64" (default) or "binary", see input_encoding.go
 		OutputAudioFormat struct {
 			Type       string `json:"type"`
 			SampleRate int    `json:"sample_rate"`
 			Voice      string `json:"voice,omitempty"`
 		} `json:"output_audio_format,omitempty"`
 		InputAudioTranscription *struct {
-			Model    string `json:"model"`
-			Language string `json:"language"`
+			Model          string `json:"model"`
+			Language       string `json:"language"`
+			InterimResults bool   `json:"interim_results,omitempty"` // Emit transcription.delta events while speech continues
+			DeltaMode      string `json:"delta_mode,omitempty"`      // "full" (default) or "compact"
+			Hints          []string `json:"hints,omitempty"`         // custom vocabulary, an empty list clears it
 		} `json:"input_audio_transcription,omitempty"`
 		TurnDetection *struct {
 			Type              string  `json:"type"`
@@ -78,6 +105,16 @@ type SessionUpdateEvent struct {
 		} `json:"turn_detection,omitempty"`
 		Tools []interface{} `json:"tools,omitempty"`
 		ToolChoice string `json:"tool_choice,omitempty"`
+		Debug *bool `json:"debug,omitempty"` // Scoped debug mode for this session only
+		DeterministicSeed *int64 `json:"deterministic_seed,omitempty"` // Derive IDs and timestamps from a seed, for tests
+		TranscriptFormat *textformat.Options `json:"transcript_format,omitempty"` // Formatting profile applied to transcripts
+		Captions *CaptionConfig `json:"captions,omitempty"` // Emit caption.cue events for transcripts
+		Recognition *RecognitionConfig `json:"recognition,omitempty"` // Recognition mode, e.g. sliding_window
+		Pipeline *string `json:"pipeline,omitempty"` // Named pipeline from the server configuration
+		BandwidthReportSeconds *int `json:"bandwidth_report_seconds,omitempty"` // Interval of session.bandwidth events, 0 disables
+		StatsReportSeconds *int `json:"stats_report_seconds,omitempty"` // Interval of session.stats events, 0 disables
+		InputAudioNoiseReduction json.RawMessage `json:"input_audio_noise_reduction,omitempty"` // Denoiser on/off and strength, null disables
+		InputAudioGainControl *GainControlConfig `json:"input_audio_gain_control,omitempty"` // Automatic gain control before the VAD
 	} `json:"session"`
 }
 
@@ -105,6 +142,7 @@ type ConversationCreatedEvent struct {
 type InputAudioBufferAppendEvent struct {
 	BaseEvent
 	Audio string `json:"audio"` // Base64 encoded audio data
+	CRC32 *uint32 `json:"crc32,omitempty"` // Optional IEEE CRC32 of the decoded audio bytes
 }
 
 // InputAudioBufferCommitEvent represents input_audio_buffer.commit event
@@ -112,9 +150,16 @@ type InputAudioBufferCommitEvent struct {
 	BaseEvent
 }
 
-// InputAudioBufferCommittedEvent represents input_audio_buffer.committed event
+// InputAudioBufferCommittedEvent represents input_audio_buffer.committed event. It is
+// sent for each item a commit creates, before conversation.item.created, or once
+// without an item when there was no audio to commit.
 type InputAudioBufferCommittedEvent struct {
 	BaseEvent
+	CommitEventID string `json:"commit_event_id,omitempty"` // event_id of the client's commit
+	ItemID       string `json:"item_id,omitempty"`
+	DurationMs   int64  `json:"duration_ms"`   // committed audio
+	SegmentCount int    `json:"segment_count"` // speech segments of the committed audio, 0 without VAD
+	Channel      *int   `json:"channel,omitempty"` // input channel of multi-channel sessions
 }
 
 // InputAudioBufferClearEvent represents input_audio_buffer.clear event
@@ -126,12 +171,29 @@ type InputAudioBufferClearEvent struct {
 type InputAudioBufferSpeechStartedEvent struct {
 	BaseEvent
 	AudioStartMs int `json:"audio_start_ms"`
+	Channel      *int `json:"channel,omitempty"` // input channel of multi-channel sessions
 }
 
 // InputAudioBufferSpeechStoppedEvent represents input_audio_buffer.speech_stopped event
 type InputAudioBufferSpeechStoppedEvent struct {
 	BaseEvent
-	AudioEndMs int `json:"audio_end_ms"`
+	AudioEndMs int  `json:"audio_end_ms"`
+	Channel    *int `json:"channel,omitempty"` // input channel of multi-channel sessions
+	// Why the turn ended when not at a pause: SpeechStoppedReasonMaxDuration means the
+	// server committed the speech itself and the client must not commit
+	Reason string `json:"reason,omitempty"`
+}
+
+// InputAudioBufferQualityWarningEvent represents input_audio_buffer.quality_warning event,
+// sent when appended audio shows sustained clipping or DC offset
+type InputAudioBufferQualityWarningEvent struct {
+	BaseEvent
+	Warning       string  `json:"warning"` // "clipping" or "dc_offset"
+	Message       string  `json:"message"`
+	ClippingRatio float64 `json:"clipping_ratio,omitempty"`
+	DCOffset      float64 `json:"dc_offset,omitempty"`
+	AudioStartMs  int64   `json:"audio_start_ms"`
+	AudioEndMs    int64   `json:"audio_end_ms"`
 }
 
 // ConversationItemCreatedEvent represents conversation.item.created event
@@ -147,6 +209,7 @@ type ConversationItemCreatedEvent struct {
 		} `json:"audio,omitempty"`
 		Content   []interface{} `json:"content,omitempty"`
 	} `json:"item"`
+	Channel *int `json:"channel,omitempty"` // input channel of multi-channel sessions
 }
 
 // ConversationItemInputAudioTranscriptionCompletedEvent represents transcription completed event
@@ -161,18 +224,35 @@ type ConversationItemInputAudioTranscriptionCompletedEvent struct {
 			Transcript string `json:"transcript"`
 		} `json:"content"`
 	} `json:"item"`
+	Quality  *audioquality.Metrics `json:"quality,omitempty"`  // quality of the utterance's input audio
+	TraceID  string                `json:"trace_id,omitempty"` // trace of the item when tracing is enabled
+	Channel  *int                  `json:"channel,omitempty"`  // input channel of multi-channel sessions
+	Language string                `json:"language,omitempty"` // detected language when the session's language is unset or "auto"
+	AudioStartMs int64             `json:"audio_start_ms,omitempty"` // start of the item's speech in the session audio
+	AudioEndMs   int64             `json:"audio_end_ms,omitempty"`   // end of the item's speech in the session audio
 }
 
 // ConversationItemInputAudioTranscriptionFailedEvent represents transcription failed event
 type ConversationItemInputAudioTranscriptionFailedEvent struct {
 	BaseEvent
-	ItemID string `json:"item_id"`
-	Error struct {
-		Type    string `json:"type"`
-		Code    string `json:"code"`
-		Message string `json:"message"`
-		Param   string `json:"param,omitempty"`
-	} `json:"error"`
+	ItemID  string       `json:"item_id"`
+	Error   ErrorDetails `json:"error"`
+	TraceID string `json:"trace_id,omitempty"` // trace of the item when tracing is enabled
+	Channel *int   `json:"channel,omitempty"`  // input channel of multi-channel sessions
+}
+
+// ConversationItemInputAudioTranscriptionDeltaEvent represents
+// conversation.item.input_audio_transcription.delta event, an interim hypothesis of
+// the utterance in progress. The item is created with the same ID once it is committed.
+type ConversationItemInputAudioTranscriptionDeltaEvent struct {
+	BaseEvent
+	ItemID        string `json:"item_id"`
+	ContentIndex  int    `json:"content_index"`
+	Delta         string `json:"delta"`                // text appended to the previous hypothesis
+	Transcript    string `json:"transcript,omitempty"` // full current hypothesis, omitted by compact deltas
+	ReplacedWords int    `json:"replaced_words"`       // trailing words of the previous hypothesis revised by this one
+	// Compact deltas only: characters of the previous hypothesis kept, Delta replaces the rest
+	StablePrefix *int `json:"stable_prefix,omitempty"`
 }
 
 // ConversationItemDeletedEvent represents conversation.item.deleted event
@@ -200,15 +280,164 @@ type HeartbeatPongEvent struct {
 
 // ErrorEvent represents error event
 type ErrorEvent struct {
+	BaseEvent
+	Error ErrorDetails `json:"error"`
+}
+
+// ErrorDetails is the error of error events and transcription failures. Code is the
+// specific error, Category the class of errors it belongs to, see pkg/errcode.
+type ErrorDetails struct {
+	Type     string       `json:"type"`
+	Code     string       `json:"code"`
+	Category errcode.Code `json:"category"`
+	Message  string       `json:"message"`
+	Param    string       `json:"param,omitempty"`
+}
+
+// newErrorDetails returns the error of an error event or transcription failure with
+// the category of its code
+func newErrorDetails(errorType string, code string, message string, param string) ErrorDetails {
+	return ErrorDetails{
+		Type:     errorType,
+		Code:     code,
+		Category: errcode.Of(code),
+		Message:  message,
+		Param:    param,
+	}
+}
+
+// RateLimitDetails describes the per-client rate limit behind a rate_limit_exceeded error
+type RateLimitDetails struct {
+	Name         string  `json:"name"` // one of the RateLimit* names
+	Limit        float64 `json:"limit"`
+	RetryAfterMs int64   `json:"retry_after_ms,omitempty"`
+}
+
+// RateLimitErrorEvent is an error event with code rate_limit_exceeded, carrying the
+// limit that refused the session or dropped the event
+type RateLimitErrorEvent struct {
 	BaseEvent
 	Error struct {
-		Type    string `json:"type"`
-		Code    string `json:"code"`
-		Message string `json:"message"`
-		Param   string `json:"param,omitempty"`
+		Type      string           `json:"type"`
+		Code      string           `json:"code"`
+		Category  errcode.Code     `json:"category"`
+		Message   string           `json:"message"`
+		RateLimit RateLimitDetails `json:"rate_limit"`
 	} `json:"error"`
 }
 
+// ConversationTruncatedEvent represents conversation.truncated event, sent when the
+// oldest conversation items are evicted to respect the configured limits
+type ConversationTruncatedEvent struct {
+	BaseEvent
+	ItemIDs        []string `json:"item_ids"`
+	Reason         string   `json:"reason"` // "max_items" or "max_transcript_chars"
+	RemainingItems int      `json:"remaining_items"`
+}
+
+// VADSegmentEvent represents vad.segment event, describing a detected speech segment
+// independently of its transcription. Times are relative to the start of the session audio.
+type VADSegmentEvent struct {
+	BaseEvent
+	SegmentIndex int     `json:"segment_index"`
+	StartMs      int64   `json:"start_ms"`
+	EndMs        int64   `json:"end_ms"`
+	DurationMs   int64   `json:"duration_ms"`
+	RMS          float64 `json:"rms"` // root mean square amplitude in [0, 1]
+	Channel      *int    `json:"channel,omitempty"` // input channel of multi-channel sessions
+}
+
+// SessionAnalyticsEvent represents session.analytics event, sent when the session ends
+type SessionAnalyticsEvent struct {
+	BaseEvent
+	Analytics SessionAnalytics `json:"analytics"`
+}
+
+// CaptionCueEvent represents caption.cue event, a block of display lines of a
+// transcript with its timing in the session audio
+type CaptionCueEvent struct {
+	BaseEvent
+	ItemID   string   `json:"item_id"`
+	CueIndex int      `json:"cue_index"`
+	StartMs  int64    `json:"start_ms"`
+	EndMs    int64    `json:"end_ms"`
+	Lines    []string `json:"lines"`
+	Final    bool     `json:"final"` // last cue of the item
+	Direction string  `json:"direction"` // "ltr" or "rtl", base direction for rendering the lines
+}
+
+// LimitDetails describes a usage limit of the session's API key tier
+type LimitDetails struct {
+	Name             string  `json:"name"` // "session_duration" or "audio_minutes"
+	Tier             string  `json:"tier"`
+	UsedSeconds      float64 `json:"used_seconds"`
+	LimitSeconds     float64 `json:"limit_seconds"`
+	RemainingSeconds float64 `json:"remaining_seconds"`
+}
+
+// SessionLimitWarningEvent represents session.limit_warning event, sent once per limit
+// when usage reaches the configured warning ratio
+type SessionLimitWarningEvent struct {
+	BaseEvent
+	Limit LimitDetails `json:"limit"`
+}
+
+// SessionLimitExceededEvent represents session.limit_exceeded event, the last event
+// before the server closes a session that reached a limit
+type SessionLimitExceededEvent struct {
+	BaseEvent
+	Limit LimitDetails `json:"limit"`
+}
+
+// TranscriptionWindowEvent represents transcription.window event, the running
+// transcript of a sliding_window session after merging the latest window
+type TranscriptionWindowEvent struct {
+	BaseEvent
+	Revision      int    `json:"revision"`
+	Text          string `json:"text"`           // full running transcript
+	Delta         string `json:"delta"`          // text appended by this window
+	ReplacedWords int    `json:"replaced_words"` // trailing words of the previous text revised by this window
+	WindowStartMs int64  `json:"window_start_ms"`
+	WindowEndMs   int64  `json:"window_end_ms"`
+}
+
+// SessionBandwidthEvent represents session.bandwidth event, the byte counters of the
+// session sent at the configured report interval
+type SessionBandwidthEvent struct {
+	BaseEvent
+	Bandwidth BandwidthStats `json:"bandwidth"`
+}
+
+// SessionStatsEvent represents session.stats event, the recognition counters of the
+// session sent at the configured report interval or in answer to session.stats.request
+type SessionStatsEvent struct {
+	BaseEvent
+	RequestEventID string       `json:"request_event_id,omitempty"` // event_id of the session.stats.request answered
+	Stats          SessionStats `json:"stats"`
+}
+
+// SessionStatsRequestEvent represents session.stats.request event, asking for a
+// session.stats event at once
+type SessionStatsRequestEvent struct {
+	BaseEvent
+}
+
+// SessionResumeEvent represents session.resume event, the first event of a client
+// reconnecting to the session named by session_id after its connection dropped
+type SessionResumeEvent struct {
+	BaseEvent
+	ResumeToken string `json:"resume_token"`
+	LastEventID string `json:"last_event_id,omitempty"` // last event the client received, empty replays all buffered events
+}
+
+// SessionResumedEvent represents session.resumed event, sent before the events the
+// client missed
+type SessionResumedEvent struct {
+	BaseEvent
+	Replayed   int  `json:"replayed"`
+	EventsLost bool `json:"events_lost,omitempty"` // the replay buffer no longer reached back to last_event_id
+}
+
 // EventParser handles parsing and validation of OpenAI events
 type EventParser struct{}
 
@@ -352,6 +581,104 @@ func (p *EventParser) ParseEvent(data []byte) (interface{}, error) {
 		}
 		return &event, nil
 
+	case EventTypeConversationTruncated:
+		var event ConversationTruncatedEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse conversation.truncated event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeVADSegment:
+		var event VADSegmentEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse vad.segment event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionAnalytics:
+		var event SessionAnalyticsEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.analytics event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeCaptionCue:
+		var event CaptionCueEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse caption.cue event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeInputAudioBufferQualityWarning:
+		var event InputAudioBufferQualityWarningEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse input_audio_buffer.quality_warning event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionLimitWarning:
+		var event SessionLimitWarningEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.limit_warning event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionLimitExceeded:
+		var event SessionLimitExceededEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.limit_exceeded event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeTranscriptionWindow:
+		var event TranscriptionWindowEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse transcription.window event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeConversationItemInputAudioTranscriptionDelta:
+		var event ConversationItemInputAudioTranscriptionDeltaEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse conversation.item.input_audio_transcription.delta event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionBandwidth:
+		var event SessionBandwidthEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.bandwidth event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionStats:
+		var event SessionStatsEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.stats event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionStatsRequest:
+		var event SessionStatsRequestEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.stats.request event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionResume:
+		var event SessionResumeEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.resume event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionResumed:
+		var event SessionResumedEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.resumed event: %v", err)
+		}
+		return &event, nil
+
 	default:
 		return nil, fmt.Errorf("unknown event type: %s", baseEvent.Type)
 	}
@@ -395,6 +722,34 @@ func (p *EventParser) ValidateEvent(event interface{}) error {
 		return p.validateHeartbeatPingEvent(e)
 	case *HeartbeatPongEvent:
 		return p.validateHeartbeatPongEvent(e)
+	case *ConversationTruncatedEvent:
+		return p.validateConversationTruncatedEvent(e)
+	case *VADSegmentEvent:
+		return p.validateVADSegmentEvent(e)
+	case *SessionAnalyticsEvent:
+		return p.validateSessionAnalyticsEvent(e)
+	case *CaptionCueEvent:
+		return p.validateCaptionCueEvent(e)
+	case *InputAudioBufferQualityWarningEvent:
+		return p.validateInputAudioBufferQualityWarningEvent(e)
+	case *SessionLimitWarningEvent:
+		return p.validateSessionLimitWarningEvent(e)
+	case *SessionLimitExceededEvent:
+		return p.validateSessionLimitExceededEvent(e)
+	case *TranscriptionWindowEvent:
+		return p.validateTranscriptionWindowEvent(e)
+	case *ConversationItemInputAudioTranscriptionDeltaEvent:
+		return p.validateConversationItemInputAudioTranscriptionDeltaEvent(e)
+	case *SessionBandwidthEvent:
+		return p.validateSessionBandwidthEvent(e)
+	case *SessionStatsEvent:
+		return p.validateSessionStatsEvent(e)
+	case *SessionStatsRequestEvent:
+		return p.validateSessionStatsRequestEvent(e)
+	case *SessionResumeEvent:
+		return p.validateSessionResumeEvent(e)
+	case *SessionResumedEvent:
+		return p.validateSessionResumedEvent(e)
 	default:
 		return fmt.Errorf("unknown event type for validation")
 	}
@@ -425,6 +780,19 @@ func (p *EventParser) validateSessionUpdateEvent(event *SessionUpdateEvent) erro
 	if event.Session.Modality != "text" && event.Session.Modality != "audio" && event.Session.Modality != "text_and_audio" {
 		return fmt.Errorf("invalid session modality: %s", event.Session.Modality)
 	}
+	if event.Session.TranscriptFormat != nil {
+		if err := event.Session.TranscriptFormat.Validate(); err != nil {
+			return err
+		}
+	}
+	if event.Session.Recognition != nil {
+		if err := event.Session.Recognition.Validate(); err != nil {
+			return err
+		}
+	}
+	if event.Session.Pipeline != nil && *event.Session.Pipeline == "" {
+		return fmt.Errorf("pipeline name cannot be empty")
+	}
 	return nil
 }
 
@@ -559,11 +927,125 @@ func (p *EventParser) validateHeartbeatPongEvent(_ *HeartbeatPongEvent) error {
 	return nil
 }
 
+func (p *EventParser) validateConversationTruncatedEvent(event *ConversationTruncatedEvent) error {
+	if len(event.ItemIDs) == 0 {
+		return fmt.Errorf("truncated item IDs are required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateVADSegmentEvent(event *VADSegmentEvent) error {
+	if event.StartMs < 0 || event.EndMs < event.StartMs {
+		return fmt.Errorf("invalid segment bounds: start_ms=%d end_ms=%d", event.StartMs, event.EndMs)
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionAnalyticsEvent(_ *SessionAnalyticsEvent) error {
+	return nil
+}
+
+func (p *EventParser) validateCaptionCueEvent(event *CaptionCueEvent) error {
+	if event.ItemID == "" {
+		return fmt.Errorf("item_id is required")
+	}
+	if event.EndMs < event.StartMs {
+		return fmt.Errorf("invalid cue bounds: start_ms=%d end_ms=%d", event.StartMs, event.EndMs)
+	}
+	return nil
+}
+
+func (p *EventParser) validateInputAudioBufferQualityWarningEvent(event *InputAudioBufferQualityWarningEvent) error {
+	switch event.Warning {
+	case audioquality.WarningClipping, audioquality.WarningDCOffset:
+	default:
+		return fmt.Errorf("unknown quality warning: %s", event.Warning)
+	}
+	if event.AudioEndMs < event.AudioStartMs {
+		return fmt.Errorf("invalid audio bounds: audio_start_ms=%d audio_end_ms=%d", event.AudioStartMs, event.AudioEndMs)
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionLimitWarningEvent(event *SessionLimitWarningEvent) error {
+	if event.Limit.Name == "" {
+		return fmt.Errorf("limit.name is required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionLimitExceededEvent(event *SessionLimitExceededEvent) error {
+	if event.Limit.Name == "" {
+		return fmt.Errorf("limit.name is required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateTranscriptionWindowEvent(event *TranscriptionWindowEvent) error {
+	if event.Revision <= 0 {
+		return fmt.Errorf("revision must be positive")
+	}
+	return nil
+}
+
+func (p *EventParser) validateConversationItemInputAudioTranscriptionDeltaEvent(event *ConversationItemInputAudioTranscriptionDeltaEvent) error {
+	if event.ItemID == "" {
+		return fmt.Errorf("item ID is required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionBandwidthEvent(event *SessionBandwidthEvent) error {
+	if event.Bandwidth.DurationMs < 0 {
+		return fmt.Errorf("duration_ms must not be negative")
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionStatsEvent(event *SessionStatsEvent) error {
+	if event.Stats.DurationMs < 0 {
+		return fmt.Errorf("duration_ms must not be negative")
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionStatsRequestEvent(_ *SessionStatsRequestEvent) error {
+	// No specific validation needed for stats requests
+	return nil
+}
+
+func (p *EventParser) validateSessionResumeEvent(event *SessionResumeEvent) error {
+	if event.SessionID == "" {
+		return fmt.Errorf("session ID is required")
+	}
+	if event.ResumeToken == "" {
+		return fmt.Errorf("resume_token is required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionResumedEvent(event *SessionResumedEvent) error {
+	if event.Replayed < 0 {
+		return fmt.Errorf("replayed must not be negative")
+	}
+	return nil
+}
+
 // GenerateEventID generates a unique event ID
 func GenerateEventID() string {
-	return fmt.Sprintf("event_%d", time.Now().UnixNano())
+	for {
+		last := lastEventID.Load()
+		next := max(time.Now().UnixNano(), last+1)
+		if lastEventID.CompareAndSwap(last, next) {
+			return fmt.Sprintf("event_%d", next)
+		}
+	}
 }
 
+// lastEventID is the clock reading of the last generated event ID, IDs generated
+// within the same nanosecond take the following ones
+var lastEventID atomic.Int64
+
 // GenerateSessionID generates a unique session ID
 func GenerateSessionID() string {
 	return fmt.Sprintf("sess_%d", time.Now().UnixNano())
@@ -600,6 +1082,20 @@ func IsValidEventType(eventType string) bool {
 		EventTypeError,
 		EventTypeHeartbeatPing,
 		EventTypeHeartbeatPong,
+		EventTypeConversationTruncated,
+		EventTypeVADSegment,
+		EventTypeSessionAnalytics,
+		EventTypeCaptionCue,
+		EventTypeInputAudioBufferQualityWarning,
+		EventTypeSessionLimitWarning,
+		EventTypeSessionLimitExceeded,
+		EventTypeTranscriptionWindow,
+		EventTypeConversationItemInputAudioTranscriptionDelta,
+		EventTypeSessionBandwidth,
+		EventTypeSessionStats,
+		EventTypeSessionStatsRequest,
+		EventTypeSessionResume,
+		EventTypeSessionResumed,
 	}
 
 	for _, validType := range validTypes {
@@ -610,6 +1106,16 @@ func IsValidEventType(eventType string) bool {
 	return false
 }
 
+// ComputeAudioChecksum returns the IEEE CRC32 of the decoded Base64 audio payload,
+// matching the crc32 field computed by clients on input_audio_buffer.append
+func ComputeAudioChecksum(base64Audio string) (uint32, error) {
+	audioData, err := base64.StdEncoding.DecodeString(base64Audio)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Base64 audio data: %v", err)
+	}
+	return crc32.ChecksumIEEE(audioData), nil
+}
+
 // DecodeBase64Audio decodes Base64 audio data to PCM bytes
 func DecodeBase64Audio(base64Audio string) ([]byte, error) {
 	base64Audio = strings.TrimPrefix(base64Audio, "data:audio/wav;base64,")
@@ -2,13 +2,22 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	config "github.com/go-restream/stt/config"
 	llm "github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/offlineasr"
 	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/audioquality"
+	"github.com/go-restream/stt/pkg/opus"
+	"github.com/go-restream/stt/pkg/resampler"
+	"github.com/go-restream/stt/pkg/textformat"
+	"github.com/go-restream/stt/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -19,11 +28,71 @@ type OpenAIService struct {
 	upgrader       websocket.Upgrader
 	eventParser    *EventParser
 	audioUtils     *AudioUtils
+	retention      audioRetention
 	sessionManager *SessionManager
 	vadIntegration *VADIntegration
 	config         *OpenAIConfig
-	appConfig      *config.Config
+	configPath     string // file the configuration is reloaded from, see config_reload.go
 	cancel         context.CancelFunc
+
+	// Selects upgrade request credentials forwarded to the ASR backend, nil disables forwarding
+	credentialPropagator CredentialPropagator
+	limitPolicy          LimitPolicy
+
+	// Checks the upgrade request of every connection, nil accepts all; see auth.go
+	authenticator Authenticator
+	sessionQuota  sessionQuota
+
+	// Per-client session, audio and event rate limits, nil disables them
+	rateLimiter *RateLimiter
+
+	// Traces of the recognition pipeline, nil disables tracing; see tracing.go
+	tracer *tracing.Tracer
+
+	// A/B experiment assigning sessions to pipeline variants, nil when none runs; see experiments.go
+	experiments *experimentRunner
+
+	// Load balancing and failover over ASR engine replicas, nil with a single backend; see asr_backends.go
+	asrBackends *asrBackends
+
+	// In-process offline recognition of asr.mode "local", nil calls the HTTP engine; see local_asr.go
+	localASR *offlineasr.Recognizer
+
+	// Streaming recognition of utterances while they are spoken, nil sends one WAV per
+	// commit; see asr_stream.go
+	asrStreaming *asrStreaming
+
+	// Concurrency limits of recognition calls per provider and model, nil without any;
+	// see asr_concurrency.go
+	asrConcurrency *asrConcurrency
+
+	// Persistence of session state for clients resuming after a dropped connection,
+	// nil keeps sessions in process only; see session_store.go
+	sessionStore *sessionStoreSync
+
+	// Persistence of finished conversation items, nil without a transcript store; see
+	// transcript_store.go
+	transcripts *transcriptPersistence
+
+	// Sessions of the HTTP long-poll transport, nil when it is disabled; see long_poll.go
+	polls *pollSessions
+
+	// Parking of sessions whose connection dropped for session.resume, nil when it is
+	// disabled; see session_resume.go
+	resume *sessionResume
+
+	// Outbound queue settings of WebSocket sessions, see outbound.go
+	outbound *outboundSettings
+
+	// Live streams transcribed by the server, nil without any; see ingest.go
+	ingest *ingestStreams
+
+	// SIP user agent PBXs fork calls to, nil unless sip.enable; see sip.go
+	sip *sipServer
+
+	// Bucket of saved audio and transcripts with storage.type "s3", nil keeps them on
+	// local disk; see storage.go
+	bucket *s3Store
 }
 
 type OpenAIConfig struct {
@@ -60,14 +129,13 @@ func NewOpenAIService(openAIConfig *OpenAIConfig, configPath string) *OpenAIServ
 	sessionManager := NewSessionManager(openAIConfig.SessionTimeout, openAIConfig.MaxSessions, appConfig)
 
 	// Set ASR configuration from config file to ensure config file takes precedence
-	llm.SetAsrBaseURL(appConfig.ASR.BaseURL)
-	llm.SetAsrApiKey(appConfig.ASR.APIKey)
-	llm.SetAsrModel(appConfig.ASR.Model)
+	applyASRSettings(appConfig)
 
 	logger.WithFields(logrus.Fields{
 		"component": "svc_openai_api ",
 		"action":    "asr_config_set",
 		"baseURL":   appConfig.ASR.BaseURL,
+		"backends":  appConfig.ASRBackends(),
 		"model":     appConfig.ASR.Model,
 		"hasApiKey": appConfig.ASR.APIKey != "",
 	}).Info("ASR configuration set from config file")
@@ -75,7 +143,7 @@ func NewOpenAIService(openAIConfig *OpenAIConfig, configPath string) *OpenAIServ
 	// Initialize VAD integration
 	var vadIntegration *VADIntegration
 	if appConfig.Vad.Enable {
-		vadIntegration = NewVADIntegration(sessionManager, appConfig)
+		vadIntegration = NewVADIntegration(sessionManager)
 		logger.WithFields(logrus.Fields{
 			"component": "svc_openai_api ",
 			"action":    "vad_integration_enabled",
@@ -103,18 +171,152 @@ func NewOpenAIService(openAIConfig *OpenAIConfig, configPath string) *OpenAIServ
 		sessionManager: sessionManager,
 		vadIntegration: vadIntegration,
 		config:         openAIConfig,
-		appConfig:      appConfig,
+		configPath:     configPath,
 		cancel:         cancel,
 	}
 
+	if len(appConfig.ASR.ForwardHeaders) > 0 {
+		service.credentialPropagator = NewHeaderAllowlistPropagator(appConfig.ASR.ForwardHeaders)
+	}
+	if appConfig.Limits.Enable {
+		service.limitPolicy = NewTierLimitPolicy(appConfig)
+	}
+	if appConfig.Auth.Enable {
+		service.authenticator = NewConfigAuthenticator(appConfig)
+	}
+	if appConfig.RateLimits.Enable {
+		service.rateLimiter = NewRateLimiter(appConfig)
+	}
+	if appConfig.Tracing.Enable {
+		service.tracer = newTracer(appConfig)
+	}
+	if appConfig.ASR.Mode == "local" {
+		service.localASR = newLocalRecognizer(appConfig)
+	}
+	if service.localASR == nil {
+		service.asrBackends = newASRBackends(appConfig)
+	}
+	if appConfig.ASR.Streaming.Enable && service.localASR == nil {
+		service.asrStreaming = newASRStreaming(appConfig)
+	}
+	if len(appConfig.ASR.Concurrency) > 0 {
+		service.asrConcurrency = newASRConcurrency(appConfig)
+	}
+	if appConfig.SessionStore.Type != "" {
+		service.sessionStore = newSessionStore(appConfig)
+	}
+	if appConfig.EventSink.Type != "" {
+		sessionManager.events = newEventPublisher(appConfig)
+	}
+	if appConfig.TranscriptStore.Type != "" {
+		service.transcripts = newTranscriptPersistence(appConfig)
+	}
+	if appConfig.Resume.Enable {
+		service.resume = newSessionResume(appConfig)
+	}
+	service.outbound = newOutboundSettings(appConfig)
+	if appConfig.Experiment.Enable {
+		experiments, err := newExperimentRunner(appConfig)
+		if err != nil {
+			logger.WithFields(logrus.Fields{
+				"component": "svc_openai_api ",
+				"action":    "experiment_disabled",
+				"error":     err,
+			}).Error("Invalid experiment configuration, sessions run without experiment")
+		} else {
+			service.experiments = experiments
+		}
+	}
+
+	if appConfig.Storage.Type == "s3" {
+		bucket, err := newS3Store(appConfig)
+		if err != nil {
+			logger.WithFields(logrus.Fields{
+				"component": "svc_openai_api ",
+				"action":    "storage_disabled",
+				"error":     err,
+			}).Error("Invalid S3 storage configuration, saved audio and transcripts stay on local disk")
+		} else {
+			service.bucket = bucket
+		}
+	}
+
+	sessionManager.saveJournal = service.saveEventJournal
+
 	// Start audio file cleanup routine
-	go service.startAudioCleanup(ctx)
+	go service.audioRetentionLoop(ctx)
+
+	if appConfig.SessionGC.IntervalSeconds > 0 {
+		go service.sessionGCLoop(ctx, time.Duration(appConfig.SessionGC.IntervalSeconds)*time.Second)
+	}
+
+	if appConfig.LongPoll.Enable {
+		service.polls = newPollSessions(appConfig)
+		go service.pollGCLoop(ctx)
+	}
+
+	if service.sessionStore != nil {
+		go service.sessionStoreLoop(ctx)
+	}
+
+	if service.transcripts != nil {
+		go service.transcriptRetentionLoop(ctx)
+	}
+
+	if appConfig.SIP.Enable {
+		if err := service.startSIP(ctx, appConfig); err != nil {
+			logger.WithFields(logrus.Fields{
+				"component": "svc_sip",
+				"action":    "sip_listen_failed",
+				"error":     err,
+			}).Error("Failed to start SIP listener")
+		}
+	}
+
+	if len(appConfig.Ingest.Streams) > 0 {
+		service.ingest = newIngestStreams(appConfig)
+		for _, stream := range service.ingest.streams {
+			go service.runIngestStream(ctx, stream)
+		}
+	}
+
+	if service.asrBackends != nil && appConfig.ASR.Balancing.HealthCheckIntervalSeconds > 0 {
+		go service.asrHealthLoop(ctx, time.Duration(appConfig.ASR.Balancing.HealthCheckIntervalSeconds)*time.Second)
+	}
 
 	return service
 }
 
 // HandleOpenAIWebSocket handles OpenAI Realtime API WebSocket connections
 func (s *OpenAIService) HandleOpenAIWebSocket(c *gin.Context) {
+	// Unauthenticated clients are refused before the upgrade
+	var principal *Principal
+	if s.authenticator != nil {
+		var err error
+		if principal, err = s.authenticator.Authenticate(c.Request); err != nil {
+			rejectUnauthenticated(c, err)
+			return
+		}
+		if !principal.HasScope(ScopeTranscribe) {
+			rejectMissingScope(c, principal, ScopeTranscribe)
+			return
+		}
+	}
+
+	// A session moving over from the long-poll transport, see handoff.go, or resumed
+	// from the session store after its connection dropped, see session_store.go
+	var record *SessionRecord
+	if resumeID := c.Query("resume"); resumeID != "" {
+		if s.sessionStore == nil || s.sessionManager.SessionExists(resumeID) {
+			s.resumeOnWebSocket(c, resumeID)
+			return
+		}
+		var ok bool
+		if record, ok = s.storedSession(c, resumeID, principal); !ok {
+			return
+		}
+	}
+
 	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		logger.WithFields(logrus.Fields{
@@ -126,94 +328,110 @@ func (s *OpenAIService) HandleOpenAIWebSocket(c *gin.Context) {
 	}
 	defer conn.Close()
 
+	lease := &sessionLease{}
+	if principal != nil {
+		if !s.sessionQuota.acquire(principal) {
+			rejectOverQuota(conn, principal)
+			return
+		}
+		lease.principal = principal
+	}
+
+	if s.rateLimiter != nil {
+		lease.rateKey, lease.rateIP = rateLimitClient(c, principal)
+		if details := s.rateLimiter.openSession(lease.rateKey, lease.rateIP); details != nil {
+			s.releaseLease(lease)
+			rejectRateLimited(conn, lease.rateKey, lease.rateIP, *details)
+			return
+		}
+		lease.rateOpen = true
+	}
+
 	// Create initial session (will be updated with session.update event)
-	session, err := s.sessionManager.CreateSession(conn, "audio")
+	var session *Session
+	if record != nil {
+		session, err = s.sessionManager.CreateSessionWithID(conn, "audio", record.ID, record.ResumeToken)
+	} else {
+		session, err = s.sessionManager.CreateSession(conn, "audio")
+	}
 	if err != nil {
+		s.releaseLease(lease)
 		logger.WithFields(logrus.Fields{
 			"component": "svc_openai_api ",
 			"action":    "create_session_failed",
 			"error":     err,
 		}).Error("Failed to create session")
+		if errors.Is(err, errMaxSessions) {
+			rejectOverCapacity(conn, s.sessionManager.MaxSessions)
+		}
 		return
 	}
-	defer s.sessionManager.DeleteSession(session.ID)
-
-	// Send session.created event to client
-	createdEvent := &SessionCreatedEvent{
-		BaseEvent: BaseEvent{
-			Type:      EventTypeSessionCreated,
-			EventID:   GenerateEventID(),
-			SessionID: session.ID,
-		},
-		Session: struct {
-			ID         string   `json:"id"`
-			Object     string   `json:"object"`
-			Model      string   `json:"model"`
-			Modalities []string `json:"modalities"`
-		}{
-			ID:         session.ID,
-			Object:     "realtime.session",
-			Model:      "gpt-4",
-			Modalities: []string{"audio"},
-		},
+	session.lease = lease
+	session.mutex.Lock()
+	gen := session.attachTransport()
+	if s.resume != nil {
+		session.replay = newReplayBuffer(s.resume.bufferSize)
 	}
+	session.outbound = newOutboundQueue(s.outbound)
+	session.mutex.Unlock()
 
-	if err := s.sessionManager.SendEvent(session, createdEvent); err != nil {
-		logger.WithFields(logrus.Fields{
-			"component": "svc_openai_api ",
-			"action":    "send_session_created_failed",
-			"sessionID": session.ID,
-			"error":     err,
-		}).Error("Failed to send session.created event")
-	} else {
-		logger.WithFields(logrus.Fields{
-			"component": "svc_openai_api ",
-			"action":    "session_created_sent",
-			"sessionID": session.ID,
-		}).Info("Sent session.created event to client")
-	}
+	// The session ends with the connection unless it was handed off to another transport
+	reason := SessionEndClosed
+	defer func() { s.detachTransport(session, gen, reason) }()
 
-	// Send conversation.created event to client
-	conversationCreatedEvent := &ConversationCreatedEvent{
-		BaseEvent: BaseEvent{
-			Type:      EventTypeConversationCreated,
-			EventID:   GenerateEventID(),
-			SessionID: session.ID,
-		},
-		Conversation: struct {
-			ID     string `json:"id"`
-			Object string `json:"object"`
-		}{
-			ID:     GenerateConversationID(),
-			Object: "realtime.conversation",
-		},
-	}
-
-	if err := s.sessionManager.SendEvent(session, conversationCreatedEvent); err != nil {
-		logger.WithFields(logrus.Fields{
-			"component": "svc_openai_api ",
-			"action":    "send_conversation_created_failed",
-			"sessionID": session.ID,
-			"error":     err,
-		}).Error("Failed to send conversation.created event")
-	} else {
-		logger.WithFields(logrus.Fields{
-			"component": "svc_openai_api ",
-			"action":    "conversation_created_sent",
-			"sessionID": session.ID,
-		}).Info("Sent conversation.created event to client")
+	s.setupSession(c, session, principal, lease.rateKey, lease.rateIP)
+	if record != nil {
+		s.restoreSession(session, record)
 	}
+	s.sendSessionCreated(session)
+	reason = s.serveWebSocket(c.Request.Context(), conn, session, gen)
+}
 
+// serveWebSocket runs the session of transport generation gen on the connection until
+// it closes and returns the reason the session ends with. A client may resume another
+// session with the first event of the connection, which is then served instead; a
+// session whose connection drops is parked for resume, see session_resume.go.
+func (s *OpenAIService) serveWebSocket(parent context.Context, conn *websocket.Conn, session *Session, gen int64) string {
 	// Start heartbeat goroutine
-	ctx, cancel := context.WithCancel(c.Request.Context())
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
-	go s.heartbeatLoop(ctx, session)
+	// Serializes the writes of the event writers of the sessions the connection serves
+	var writeMutex sync.Mutex
+
+	sessionCtx, stopSession := withSessionContext(ctx, session)
+	go s.outboundLoop(sessionCtx, session, conn, &writeMutex)
+	go s.heartbeatLoop(sessionCtx, session)
+	go s.reportLoop(sessionCtx, session)
+
+	// The session the connection serves, replaced when the client resumes another
+	var servedMutex sync.Mutex
+	served, servedGen := session, gen
+
+	// A client closing the connection gets the events of the session's end, among them
+	// session.analytics, before the close frame is answered
+	conn.SetCloseHandler(func(code int, _ string) error {
+		servedMutex.Lock()
+		current, currentGen := served, servedGen
+		servedMutex.Unlock()
+
+		reason := SessionEndClosed
+		if code != websocket.CloseNormalClosure && code != websocket.CloseGoingAway {
+			reason = SessionEndError
+		}
+		s.detachTransport(current, currentGen, reason)
+
+		message := websocket.FormatCloseMessage(code, "")
+		conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+		return nil
+	})
 
 	// Main message processing loop
 	errChan := make(chan error, 1)
 
 	go func() {
+		session := session
+		firstEvent := true
 		for {
 			select {
 			case <-ctx.Done():
@@ -224,9 +442,33 @@ func (s *OpenAIService) HandleOpenAIWebSocket(c *gin.Context) {
 					errChan <- err
 					return
 				}
+				session.bandwidth.addInbound(len(message))
+
+				if firstEvent && messageType == websocket.TextMessage {
+					firstEvent = false
+					resumed, resumedGen, handled := s.resumeSession(conn, session, gen, message)
+					if resumed != nil {
+						stopSession()
+						sessionCtx, stopSession = withSessionContext(ctx, resumed)
+						go s.outboundLoop(sessionCtx, resumed, conn, &writeMutex)
+						go s.heartbeatLoop(sessionCtx, resumed)
+						go s.reportLoop(sessionCtx, resumed)
+
+						servedMutex.Lock()
+						served, servedGen = resumed, resumedGen
+						servedMutex.Unlock()
+						session = resumed
+					}
+					if handled {
+						continue
+					}
+				}
+				if !s.allowClientEvent(session) {
+					continue
+				}
 
 				if err := s.handleMessage(session, messageType, message); err != nil {
-					logger.WithFields(logrus.Fields{
+					session.Logger().WithFields(logrus.Fields{
 						"component": "svc_openai_api ",
 						"action":    "handle_message_error",
 						"sessionID": session.ID,
@@ -236,19 +478,10 @@ func (s *OpenAIService) HandleOpenAIWebSocket(c *gin.Context) {
 					errorEvent := &ErrorEvent{
 						BaseEvent: BaseEvent{
 							Type:      EventTypeError,
-							EventID:   GenerateEventID(),
+							EventID:   session.NewEventID(),
 							SessionID: session.ID,
 						},
-						Error: struct {
-							Type    string `json:"type"`
-							Code    string `json:"code"`
-							Message string `json:"message"`
-							Param   string `json:"param,omitempty"`
-						}{
-							Type:    "invalid_request_error",
-							Code:    "message_processing_error",
-							Message: err.Error(),
-						},
+						Error: newErrorDetails("invalid_request_error", "message_processing_error", err.Error(), ""),
 					}
 					s.sessionManager.SendEvent(session, errorEvent)
 				}
@@ -257,40 +490,162 @@ func (s *OpenAIService) HandleOpenAIWebSocket(c *gin.Context) {
 	}()
 
 	// Wait for error or context cancellation
+	var reason string
+	var readErr error
 	select {
-	case err := <-errChan:
-		if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-			logger.WithFields(logrus.Fields{
+	case readErr = <-errChan:
+		servedMutex.Lock()
+		session := served
+		servedMutex.Unlock()
+		if websocket.IsUnexpectedCloseError(readErr, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			session.Logger().WithFields(logrus.Fields{
 				"component": "svc_openai_api ",
 				"action":    "websocket_unexpected_close_error",
 				"sessionID": session.ID,
-				"error":     err,
+				"error":     readErr,
 			}).Error("WebSocket unexpected close error")
-
-			// Clean up session resources
-			s.sessionManager.RemoveSession(session.ID)
+			reason = SessionEndError
 		} else {
-			logger.WithFields(logrus.Fields{
+			session.Logger().WithFields(logrus.Fields{
 				"component": "svc_openai_api ",
 				"action":    "websocket_closed_normally",
 				"sessionID": session.ID,
-				"error":     err,
+				"error":     readErr,
 			}).Info("WebSocket connection closed normally")
-
-			// Clean up session resources
-			s.sessionManager.RemoveSession(session.ID)
+			reason = SessionEndClosed
 		}
-		return
 	case <-ctx.Done():
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component": "svc_openai_api ",
 			"action":    "websocket_closed_by_context",
 			"sessionID": session.ID,
 		}).Info("WebSocket connection closed by context")
+		reason = SessionEndClosed
+	}
 
-		// Clean up session resources
-		s.sessionManager.RemoveSession(session.ID)
-		return
+	servedMutex.Lock()
+	current, currentGen := served, servedGen
+	servedMutex.Unlock()
+
+	// A dropped connection leaves its session to be resumed
+	if readErr != nil && s.resume != nil && resumeDropped(readErr) && s.parkSession(current, currentGen) {
+		return reason
+	}
+	// The caller ends the session it passed in, a resumed one ends here
+	if current != session {
+		s.detachTransport(current, currentGen, reason)
+	}
+	return reason
+}
+
+// setupSession prepares a new session for its client: tracing, forwarded credentials,
+// rate and usage limits, experiments and the deterministic test mode
+func (s *OpenAIService) setupSession(c *gin.Context, session *Session, principal *Principal, rateKey, rateIP string) {
+	s.startSessionTrace(session, c.Request)
+
+	// Capture per-user credentials to forward to the ASR backend
+	if s.credentialPropagator != nil {
+		session.ForwardedHeaders = s.credentialPropagator.Propagate(c.Request)
+	}
+
+	if principal != nil {
+		session.Tenant = principal.Tenant
+	}
+	if s.rateLimiter != nil {
+		// Audio is budgeted per API key, or per IP address for clients without one
+		session.rateClient = rateIP
+		if rateKey != "" {
+			session.rateClient = rateKey
+		}
+		session.eventBucket = s.rateLimiter.newEventBucket()
+	}
+
+	// Resolve the API key tier whose usage limits apply to this session
+	if s.limitPolicy != nil {
+		session.APIKey, session.Tier = s.limitPolicy.Identify(c.Request)
+	}
+
+	// Experiment variants are assigned once the client is identified
+	s.assignExperiment(session)
+
+	// Deterministic test mode requested on connect, so session.created is stable too
+	if seed := c.Query("seed"); seed != "" {
+		if value, err := strconv.ParseInt(seed, 10, 64); err == nil {
+			session.SetDeterministicSeed(value)
+		}
+	}
+}
+
+// sendSessionCreated sends the session.created and conversation.created events that
+// open every session
+func (s *OpenAIService) sendSessionCreated(session *Session) {
+	// Send session.created event to client
+	createdEvent := &SessionCreatedEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeSessionCreated,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		Session: struct {
+			ID         string   `json:"id"`
+			Object     string   `json:"object"`
+			Model      string   `json:"model"`
+			Modalities []string `json:"modalities"`
+		}{
+			ID:         session.ID,
+			Object:     "realtime.session",
+			Model:      "gpt-4",
+			Modalities: []string{"audio"},
+		},
+	}
+	caps := s.Capabilities()
+	createdEvent.Capabilities = &caps
+	createdEvent.ResumeToken = session.ResumeToken
+
+	if err := s.sessionManager.SendEvent(session, createdEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "send_session_created_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Failed to send session.created event")
+	} else {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "session_created_sent",
+			"sessionID": session.ID,
+		}).Info("Sent session.created event to client")
+	}
+
+	// Send conversation.created event to client
+	conversationCreatedEvent := &ConversationCreatedEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeConversationCreated,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		Conversation: struct {
+			ID     string `json:"id"`
+			Object string `json:"object"`
+		}{
+			ID:     session.NewConversationID(),
+			Object: "realtime.conversation",
+		},
+	}
+
+	if err := s.sessionManager.SendEvent(session, conversationCreatedEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "send_conversation_created_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Failed to send conversation.created event")
+	} else {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "conversation_created_sent",
+			"sessionID": session.ID,
+		}).Info("Sent conversation.created event to client")
 	}
 }
 
@@ -302,9 +657,9 @@ func (s *OpenAIService) handleMessage(session *Session, messageType int, message
 	case websocket.TextMessage:
 		return s.handleTextMessage(session, message)
 	case websocket.BinaryMessage:
-		return fmt.Errorf("binary messages not supported in OpenAI Realtime API")
+		return s.handleBinaryMessage(session, message)
 	case websocket.PingMessage:
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component": "mont_hrtbeat_act",
 			"action":    "received_ping",
 			"sessionID": session.ID,
@@ -317,13 +672,14 @@ func (s *OpenAIService) handleMessage(session *Session, messageType int, message
 			return fmt.Errorf("session connection closed")
 		}
 
-		if err := session.Conn.SetWriteDeadline(time.Now().Add(2 * time.Second)); err != nil {
-			return fmt.Errorf("failed to set write deadline for pong: %v", err)
+		// A control frame, which may be written alongside the session's event writer
+		if err := session.Conn.WriteControl(websocket.PongMessage, nil, time.Now().Add(2*time.Second)); err != nil {
+			return err
 		}
-
-		return session.Conn.WriteMessage(websocket.PongMessage, nil)
+		session.bandwidth.addOutbound(0)
+		return nil
 	case websocket.PongMessage:
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component": "mont_hrtbeat_act",
 			"action":    "received_pong",
 			"sessionID": session.ID,
@@ -345,6 +701,10 @@ func (s *OpenAIService) handleTextMessage(session *Session, message []byte) erro
 		return fmt.Errorf("event validation failed: %v", err)
 	}
 
+	if session.IsDebug() {
+		s.sessionManager.RecordJournal(session, JournalDirectionIn, message)
+	}
+
 	// Process the specific event type
 	switch e := event.(type) {
 	case *SessionUpdateEvent:
@@ -365,10 +725,15 @@ func (s *OpenAIService) handleTextMessage(session *Session, message []byte) erro
 		return s.handleHeartbeatPing(session, e)
 	case *HeartbeatPongEvent:
 		return s.handleHeartbeatPong(session, e)
+	case *SessionStatsRequestEvent:
+		return s.handleSessionStatsRequest(session, e)
 	case *ConversationItemDeletedEvent:
 		return s.handleConversationItemDeleted(session, e)
 	case *InputAudioBufferClearedEvent:
 		return s.handleInputAudioBufferCleared(session, e)
+	case *SessionResumeEvent:
+		// Resuming replaces the session of the connection, see session_resume.go
+		return fmt.Errorf("session.resume must be the first event of a connection")
 	default:
 		return fmt.Errorf("unsupported event type: %T", event)
 	}
@@ -376,7 +741,7 @@ func (s *OpenAIService) handleTextMessage(session *Session, message []byte) erro
 
 // handleSessionUpdate processes session.update events
 func (s *OpenAIService) handleSessionUpdate(session *Session, event *SessionUpdateEvent) error {
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component": "mg_session_ctrl",
 		"action":    "session_update_received",
 		"sessionID": session.ID,
@@ -384,6 +749,59 @@ func (s *OpenAIService) handleSessionUpdate(session *Session, event *SessionUpda
 		"outputSampleRate": event.Session.OutputAudioFormat.SampleRate,
 	}).Info("Session update received")
 
+	// Pipelines are configured server side, reject unknown names before changing anything
+	if event.Session.Pipeline != nil {
+		if _, err := lookupPipeline(s.appConfig(), *event.Session.Pipeline); err != nil {
+			s.sendErrorEvent(session, "invalid_request_error", "unknown_pipeline", err.Error(), "session.pipeline")
+			return nil
+		}
+	}
+
+	if t := event.Session.InputAudioTranscription; t != nil && t.DeltaMode != "" && t.DeltaMode != DeltaModeFull && t.DeltaMode != DeltaModeCompact {
+		s.sendErrorEvent(session, "invalid_request_error", "invalid_delta_mode",
+			fmt.Sprintf("unknown delta mode %q, expected %q or %q", t.DeltaMode, DeltaModeFull, DeltaModeCompact),
+			"session.input_audio_transcription.delta_mode")
+		return nil
+	}
+
+	if t := event.Session.InputAudioTranscription; t != nil {
+		if err := validateHints(t.Hints, s.appConfig()); err != nil {
+			s.sendErrorEvent(session, "invalid_request_error", "invalid_hints", err.Error(), "session.input_audio_transcription.hints")
+			return nil
+		}
+	}
+
+	noiseReduction, err := parseNoiseReduction(event.Session.InputAudioNoiseReduction)
+	if err != nil {
+		s.sendErrorEvent(session, "invalid_request_error", "invalid_noise_reduction", err.Error(), "session.input_audio_noise_reduction")
+		return nil
+	}
+
+	if c := event.Session.InputAudioGainControl; c != nil {
+		if err := c.validate(); err != nil {
+			s.sendErrorEvent(session, "invalid_request_error", "invalid_gain_control", err.Error(), "session.input_audio_gain_control")
+			return nil
+		}
+	}
+
+	if event.Session.InputEncoding != nil {
+		if err := validateInputEncoding(*event.Session.InputEncoding); err != nil {
+			s.sendErrorEvent(session, "invalid_request_error", "invalid_input_encoding", err.Error(), "session.input_encoding")
+			return nil
+		}
+	}
+
+	if err := validateInputChannels(event.Session.InputAudioFormat.Type, event.Session.InputAudioFormat.Channels); err != nil {
+		s.sendErrorEvent(session, "invalid_request_error", "unsupported_audio_format", err.Error(), "session.input_audio_format.channels")
+		return nil
+	}
+
+	// Opus input needs libopus, which builds without the opus tag lack
+	if event.Session.InputAudioFormat.Type == InputAudioFormatOpus && !opus.Supported {
+		s.sendErrorEvent(session, "invalid_request_error", "unsupported_audio_format", opus.ErrUnsupported.Error(), "session.input_audio_format.type")
+		return nil
+	}
+
 	// Update session configuration
 	s.sessionManager.UpdateSession(session.ID, func(sess *Session) {
 		sess.Modality = event.Session.Modality
@@ -391,6 +809,9 @@ func (s *OpenAIService) handleSessionUpdate(session *Session, event *SessionUpda
 		sess.Voice = event.Session.Voice
 		sess.InputAudioFormat = event.Session.InputAudioFormat
 		sess.OutputAudioFormat = event.Session.OutputAudioFormat
+		if event.Session.InputEncoding != nil {
+			sess.InputEncoding = *event.Session.InputEncoding
+		}
 		sess.Tools = event.Session.Tools
 		sess.ToolChoice = event.Session.ToolChoice
 
@@ -402,22 +823,128 @@ func (s *OpenAIService) handleSessionUpdate(session *Session, event *SessionUpda
 			sess.OutputAudioFormat.SampleRate = event.Session.OutputAudioFormat.SampleRate
 		}
 
-		// Update audio transcription configuration
+		// Opus packets are decoded straight to 16kHz, whatever rate they were encoded at
+		if sess.InputAudioFormat.Type == InputAudioFormatOpus {
+			if sess.opusDecoder == nil {
+				decoder, err := opus.NewDecoder(16000, 1)
+				if err != nil {
+					session.Logger().WithFields(logrus.Fields{
+						"component": "mg_session_ctrl",
+						"action":    "opus_decoder_failed",
+						"sessionID": session.ID,
+						"error":     err,
+					}).Error("Failed to create Opus decoder")
+				}
+				sess.opusDecoder = decoder
+			}
+			sess.InputAudioFormat.SampleRate = 16000
+		} else if sess.opusDecoder != nil {
+			sess.opusDecoder.Close()
+			sess.opusDecoder = nil
+		}
+
+		// G.711 is always 8kHz telephony audio, upsampled to 16kHz on append
+		if isG711Format(sess.InputAudioFormat.Type) {
+			sess.InputAudioFormat.SampleRate = 8000
+		}
+
+		// Update audio transcription configuration, empty fields keep the session defaults
 		if event.Session.InputAudioTranscription != nil {
-			sess.InputAudioTranscription.Model = event.Session.InputAudioTranscription.Model
-			sess.InputAudioTranscription.Language = event.Session.InputAudioTranscription.Language
+			if model := event.Session.InputAudioTranscription.Model; model != "" {
+				sess.InputAudioTranscription.Model = model
+			}
+			if language := event.Session.InputAudioTranscription.Language; language != "" {
+				sess.InputAudioTranscription.Language = language
+			}
+			sess.InputAudioTranscription.InterimResults = event.Session.InputAudioTranscription.InterimResults
+			if mode := event.Session.InputAudioTranscription.DeltaMode; mode != "" {
+				sess.InputAudioTranscription.DeltaMode = mode
+			}
+			// Hints are replaced as a whole, an empty list clears them
+			if hints := event.Session.InputAudioTranscription.Hints; hints != nil {
+				sess.InputAudioTranscription.Hints = hints
+			}
+		}
+
+		// Toggle scoped debug mode
+		if event.Session.Debug != nil {
+			sess.SetDebug(*event.Session.Debug)
 		}
 
-		// Update turn detection configuration
-		if event.Session.TurnDetection != nil {
-			sess.TurnDetection.Type = event.Session.TurnDetection.Type
-			sess.TurnDetection.Threshold = event.Session.TurnDetection.Threshold
-			sess.TurnDetection.PrefixPaddingMs = event.Session.TurnDetection.PrefixPaddingMs
-			sess.TurnDetection.SilenceDurationMs = event.Session.TurnDetection.SilenceDurationMs
+		// Switch to deterministic test mode
+		if event.Session.DeterministicSeed != nil {
+			sess.SetDeterministicSeed(*event.Session.DeterministicSeed)
 		}
 
+		// Change the interval of session.bandwidth events
+		if event.Session.BandwidthReportSeconds != nil {
+			sess.SetBandwidthReportSeconds(*event.Session.BandwidthReportSeconds)
+		}
+
+		// Change the interval of session.stats events
+		if event.Session.StatsReportSeconds != nil {
+			sess.SetStatsReportSeconds(*event.Session.StatsReportSeconds)
+		}
+
+		// Switch pipeline first so explicit settings in the same update take precedence
+		if event.Session.Pipeline != nil {
+			if err := applyPipeline(sess, s.appConfig(), *event.Session.Pipeline); err == nil {
+				leaveExperiment(sess)
+			}
+		}
+
+		// Toggle the denoiser after the pipeline, which sets one of its own
+		if noiseReduction != nil {
+			sess.NoiseReduction = noiseReduction
+			applyNoiseReduction(sess, s.appConfig())
+		}
+
+		// Enable or reconfigure the gain control
+		if event.Session.InputAudioGainControl != nil {
+			sess.GainControl = event.Session.InputAudioGainControl
+			applyGainControl(sess, s.appConfig())
+		}
+
+		// Select the transcript formatting profile
+		if event.Session.TranscriptFormat != nil {
+			sess.TranscriptFormat = *event.Session.TranscriptFormat
+		}
+
+		// Enable or reconfigure caption cues
+		if event.Session.Captions != nil {
+			sess.Captions = *event.Session.Captions
+		}
+
+		// Switch recognition mode, a new mode starts from an empty window
+		if event.Session.Recognition != nil {
+			sess.Recognition = *event.Session.Recognition
+			sess.slidingWindow.reset()
+		}
+
+		// Update turn detection configuration, unset fields keep the session defaults
+		if turnDetection := event.Session.TurnDetection; turnDetection != nil {
+			if turnDetection.Type != "" {
+				sess.TurnDetection.Type = turnDetection.Type
+			}
+			if turnDetection.Threshold > 0 {
+				sess.TurnDetection.Threshold = turnDetection.Threshold
+			}
+			if turnDetection.PrefixPaddingMs > 0 {
+				sess.TurnDetection.PrefixPaddingMs = turnDetection.PrefixPaddingMs
+			}
+			if turnDetection.SilenceDurationMs > 0 {
+				sess.TurnDetection.SilenceDurationMs = turnDetection.SilenceDurationMs
+			}
+			if cfg := s.appConfig(); cfg != nil {
+				applyTurnDetection(sess, cfg)
+			}
+		}
+
+		// Split multi-channel input into per-channel streams, after the settings they follow
+		applyChannels(sess, s.appConfig())
+
 		// Log the updated configuration
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component": "mg_session_ctrl",
 			"action":    "session_configuration_updated",
 			"sessionID": session.ID,
@@ -430,7 +957,7 @@ func (s *OpenAIService) handleSessionUpdate(session *Session, event *SessionUpda
 	responseEvent := &SessionUpdatedEvent{
 		BaseEvent: BaseEvent{
 			Type:      EventTypeSessionUpdated,
-			EventID:   GenerateEventID(),
+			EventID:   session.NewEventID(),
 			SessionID: session.ID,
 		},
 		Session: struct {
@@ -451,57 +978,203 @@ func (s *OpenAIService) handleSessionUpdate(session *Session, event *SessionUpda
 
 // handleHeartbeatPing processes heartbeat.ping events
 func (s *OpenAIService) handleHeartbeatPing(session *Session, _ *HeartbeatPingEvent) error {
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component": "mont_hrtbeat_act",
 		"action":    "ping_received",
 		"sessionID": session.ID,
 	}).Debug("Ping received for session")
 
-	// Send heartbeat.pong response
-	pongEvent := &HeartbeatPongEvent{
-		BaseEvent: BaseEvent{
-			Type:      EventTypeHeartbeatPong,
-			EventID:   GenerateEventID(),
-			SessionID: session.ID,
-		},
-		HeartbeatType: 1, // PONG type
+	// Send heartbeat.pong response
+	pongEvent := &HeartbeatPongEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeHeartbeatPong,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		HeartbeatType: 1, // PONG type
+	}
+
+	return s.sessionManager.SendEvent(session, pongEvent)
+}
+
+// handleHeartbeatPong processes heartbeat.pong events
+func (s *OpenAIService) handleHeartbeatPong(session *Session, _ *HeartbeatPongEvent) error {
+	session.Logger().WithFields(logrus.Fields{
+		"component": "mont_hrtbeat_act",
+		"action":    "pong_received",
+		"sessionID": session.ID,
+	}).Debug("Pong received for session")
+	// Update session last active time
+	s.sessionManager.UpdateSession(session.ID, func(sess *Session) {
+		sess.LastActive = time.Now()
+	})
+	return nil
+}
+
+// handleInputAudioBufferAppend processes input_audio_buffer.append events
+func (s *OpenAIService) handleInputAudioBufferAppend(session *Session, event *InputAudioBufferAppendEvent) error {
+	appendStart := time.Now()
+	session.Logger().WithFields(logrus.Fields{
+		"component": "proc_audio_main",
+		"action":    "buffer_append_received",
+		"sessionID": session.ID,
+		"sampleRate": session.InputAudioFormat.SampleRate,
+	}).Debug("Audio buffer append received")
+
+	// Verify chunk checksum when the client provided one
+	if event.CRC32 != nil {
+		checksum, err := ComputeAudioChecksum(event.Audio)
+		if err != nil {
+			return fmt.Errorf("failed to verify audio checksum: %v", err)
+		}
+		if checksum != *event.CRC32 {
+			session.Logger().WithFields(logrus.Fields{
+				"component": "proc_audio_main",
+				"action":    "checksum_mismatch",
+				"sessionID": session.ID,
+				"eventID":   event.EventID,
+				"expected":  *event.CRC32,
+				"actual":    checksum,
+			}).Warn("Audio chunk checksum mismatch, dropping chunk")
+			if data, err := s.audioUtils.DecodeBase64Audio(event.Audio); err == nil {
+				session.recordDroppedSamples(encodedSamples(session.InputAudioFormat.Type, len(data)))
+			}
+
+			s.sendErrorEvent(session, "invalid_request_error", "checksum_mismatch",
+				fmt.Sprintf("crc32 mismatch for event %s: expected %08x, got %08x", event.EventID, *event.CRC32, checksum),
+				"crc32")
+			return nil
+		}
+	}
+
+	data, err := s.audioUtils.DecodeBase64Audio(event.Audio)
+	if err != nil {
+		return fmt.Errorf("failed to decode audio: %v", err)
+	}
+	return s.appendInputAudio(session, data, len(event.Audio), appendStart)
+}
+
+// appendInputAudio runs audio bytes in the session's input format, received as a
+// Base64 append event or a binary frame of wireBytes, through the audio pipeline
+func (s *OpenAIService) appendInputAudio(session *Session, data []byte, wireBytes int, appendStart time.Time) error {
+	s.applyVADAdjustment(session)
+
+	var samples []int16
+	var err error
+	switch format := session.InputAudioFormat.Type; {
+	case format == InputAudioFormatOpus:
+		if session.opusDecoder == nil {
+			return fmt.Errorf("opus decoder not available")
+		}
+		samples, err = session.opusDecoder.Decode(data)
+	case isG711Format(format):
+		samples, err = s.audioUtils.ConvertG711ToPCM16(format, data)
+	default:
+		samples, err = s.audioUtils.ConvertBytesToPCM16(data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode audio: %v", err)
+	}
+	duration := time.Duration(len(samples)) * time.Second / time.Duration(session.inputSampleRate()*session.inputChannels())
+	session.bandwidth.addInboundAudio(wireBytes, duration)
+
+	// Audio over the client's per-minute budget is dropped
+	if !s.allowClientAudio(session, duration) {
+		session.recordDroppedSamples(len(samples))
+		return nil
+	}
+
+	// Enforce the tier's usage limits, the session is closed once one is exceeded
+	if s.enforceLimits(session, duration) {
+		return nil
+	}
+	session.captionEdge.addAudio(duration)
+
+	// Warn the client about capture problems before they turn into bad transcripts
+	s.checkAudioQuality(session, samples)
+
+	// Each channel of a multi-channel session is VAD'd and transcribed on its own
+	if len(session.channels) > 0 {
+		return s.appendChannels(session, samples)
+	}
+
+	// VAD, interim and sliding-window recognition all run on a 16kHz stream
+	reSamples, err := s.resampleForVAD(session, samples)
+	if err != nil {
+		return err
+	}
+
+	// Gate stationary background noise so it does not trigger the VAD
+	if session.noiseGate != nil {
+		reSamples = session.noiseGate.Process(reSamples)
+	}
+
+	// Bring quiet microphones to the level the VAD expects
+	if session.gainControl != nil {
+		reSamples = session.gainControl.Process(reSamples)
+	}
+
+	// Accumulate audio data based on buffer_size configuration, always for debug sessions
+	if s.appConfig().Audio.Enable || session.IsDebug() {
+		if err := s.accumulateAudioForSaving(session, samples); err != nil {
+			session.Logger().WithFields(logrus.Fields{
+				"component": "proc_audio_main",
+				"action":    "accumulate_audio_failed",
+				"sessionID": session.ID,
+				"error":     err,
+			}).Error("Failed to accumulate audio for saving")
+		}
+	}
+
+	// Sliding-window recognition runs on the 16kHz stream independently of VAD
+	s.feedSlidingWindow(session, reSamples)
+
+	// Note: Removed direct addition to AudioBuffer
+	// VAD-processed audio will be added to VADAudioBuffer for ASR processing
+	// This prevents duplicate audio data and ensures only speech segments are processed
+
+	// Without turn detection the client commits the raw audio, kept at 16kHz like the
+	// VAD segments
+	if !s.usesVAD(session) {
+		if err := s.sessionManager.AddAudioToBuffer(session.ID, reSamples); err != nil {
+			return err
+		}
+		session.rawSamplesAppended += int64(len(reSamples))
+	}
+
+	// Process VAD if enabled
+	if s.usesVAD(session) {
+		segmentsBefore := session.vadSegmentCount
+		vadStart := time.Now()
+		if err := s.vadIntegration.ProcessAudioSamples(session.ID, reSamples); err != nil {
+			session.Logger().WithFields(logrus.Fields{
+				"component":   "vad",
+				"action":      "processing_error",
+				"sessionID":   session.ID,
+				"error":       err,
+			}).Error("VAD processing error")
+		}
+		if segments := session.vadSegmentCount - segmentsBefore; segments > 0 {
+			s.traceSpeechAppend(session, len(samples), segments, appendStart, vadStart, time.Now())
+		}
+		s.enforceMaxUtterance(session, session)
 	}
 
-	return s.sessionManager.SendEvent(session, pongEvent)
-}
+	// Interim hypotheses follow the speech state the VAD just updated
+	s.feedInterim(session, reSamples)
 
-// handleHeartbeatPong processes heartbeat.pong events
-func (s *OpenAIService) handleHeartbeatPong(session *Session, _ *HeartbeatPongEvent) error {
-	logger.WithFields(logrus.Fields{
-		"component": "mont_hrtbeat_act",
-		"action":    "pong_received",
-		"sessionID": session.ID,
-	}).Debug("Pong received for session")
-	// Update session last active time
-	s.sessionManager.UpdateSession(session.ID, func(sess *Session) {
-		sess.LastActive = time.Now()
-	})
 	return nil
 }
 
-// handleInputAudioBufferAppend processes input_audio_buffer.append events
-func (s *OpenAIService) handleInputAudioBufferAppend(session *Session, event *InputAudioBufferAppendEvent) error {
-	logger.WithFields(logrus.Fields{
-		"component": "proc_audio_main",
-		"action":    "buffer_append_received",
-		"sessionID": session.ID,
-		"sampleRate": session.InputAudioFormat.SampleRate,
-	}).Debug("Audio buffer append received")
-
-	// Decode Base64 audio to PCM samples
-	samples, err := s.audioUtils.ConvertBase64ToPCM16(event.Audio)
-	if err != nil {
-		return fmt.Errorf("failed to decode audio: %v", err)
-	}
-
-	var reSamples []int16
-	if  session.InputAudioFormat.SampleRate == 48000 {
-		logger.WithFields(logrus.Fields{
+// resampleForVAD converts appended audio of a session or channel stream to the 16kHz
+// the VAD, interim and sliding-window recognition run on
+func (s *OpenAIService) resampleForVAD(session *Session, samples []int16) ([]int16, error) {
+	reSamples := samples
+	var err error
+	switch rate := session.inputSampleRate(); rate {
+	case 16000:
+	case 48000:
+		session.Logger().WithFields(logrus.Fields{
 			"component": "proc_rsmpl_audio",
 			"action":    "resample_required",
 			"sessionID": session.ID,
@@ -509,7 +1182,7 @@ func (s *OpenAIService) handleInputAudioBufferAppend(session *Session, event *In
 
 	   reSamples, err = s.audioUtils.ResampleAudio(samples, 48000, 16000)
 			if err != nil {
-				logger.WithFields(logrus.Fields{
+				session.Logger().WithFields(logrus.Fields{
 					"component":   "resample",
 					"action":      "resample_failed",
 					"sessionID":   session.ID,
@@ -518,7 +1191,7 @@ func (s *OpenAIService) handleInputAudioBufferAppend(session *Session, event *In
 				// Fallback to original samples if resampling fails
 				reSamples = samples
 			} else {
-				logger.WithFields(logrus.Fields{
+				session.Logger().WithFields(logrus.Fields{
 					"component":      "resample",
 					"action":         "resample_completed",
 					"sessionID":      session.ID,
@@ -526,71 +1199,74 @@ func (s *OpenAIService) handleInputAudioBufferAppend(session *Session, event *In
 					"outputSamples":  len(reSamples),
 				}).Debug("Resampled audio from 48kHz to 16kHz")
 			}
-	}
-
-	// Accumulate audio data based on buffer_size configuration
-	if s.appConfig.Audio.Enable {
-		if err := s.accumulateAudioForSaving(session, samples); err != nil {
-			logger.WithFields(logrus.Fields{
-				"component": "proc_audio_main",
-				"action":    "accumulate_audio_failed",
-				"sessionID": session.ID,
-				"error":     err,
-			}).Error("Failed to accumulate audio for saving")
+	default:
+		inputResampler, err := s.inputResampler(session, rate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resample audio from %dHz: %v", rate, err)
 		}
+		reSamples = inputResampler.Process(samples)
 	}
 
-	// Note: Removed direct addition to AudioBuffer
-	// VAD-processed audio will be added to VADAudioBuffer for ASR processing
-	// This prevents duplicate audio data and ensures only speech segments are processed
+	return reSamples, nil
+}
 
-	// Process VAD if enabled
-	if s.vadIntegration != nil {
-		if  session.InputAudioFormat.SampleRate == 48000 {
-			if err := s.vadIntegration.ProcessAudioSamples(session.ID, reSamples); err != nil {
-				logger.WithFields(logrus.Fields{
-					"component":   "vad",
-					"action":      "processing_error",
-					"sessionID":   session.ID,
-					"error":       err,
-				}).Error("VAD processing error")
-			}
-		}
-		if session.InputAudioFormat.SampleRate == 16000 {
-			if err := s.vadIntegration.ProcessAudioSamples(session.ID, samples); err != nil {
-				logger.WithFields(logrus.Fields{
-					"component":   "vad",
-					"action":      "processing_error",
-					"sessionID":   session.ID,
-					"error":       err,
-				}).Error("VAD processing error")
-			}
-		}
+// inputResampler returns the session's streaming converter from rate to 16kHz,
+// replacing it when the input sample rate changed
+func (s *OpenAIService) inputResampler(session *Session, rate int) (*resampler.Resampler, error) {
+	if session.inputResampler != nil && session.inputResamplerRate == rate {
+		return session.inputResampler, nil
+	}
 
+	var qualityName string
+	if cfg := s.appConfig(); cfg != nil {
+		qualityName = cfg.Audio.ResampleQuality
+	}
+	quality, err := resampler.ParseQuality(qualityName)
+	if err != nil {
+		return nil, err
+	}
+	inputResampler, err := resampler.NewResampler(rate, 16000, quality)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	session.inputResampler = inputResampler
+	session.inputResamplerRate = rate
+	session.Logger().WithFields(logrus.Fields{
+		"component":  "proc_rsmpl_audio",
+		"action":     "input_resampler_created",
+		"sessionID":  session.ID,
+		"sampleRate": rate,
+		"quality":    qualityName,
+	}).Info("Created input resampler")
+	return inputResampler, nil
 }
 
 // handleInputAudioBufferCommit processes input_audio_buffer.commit events
-func (s *OpenAIService) handleInputAudioBufferCommit(session *Session, _ *InputAudioBufferCommitEvent) error {
-	logger.WithFields(logrus.Fields{
+func (s *OpenAIService) handleInputAudioBufferCommit(session *Session, event *InputAudioBufferCommitEvent) error {
+	session.Logger().WithFields(logrus.Fields{
 		"component": "proc_audio_main",
 		"action":    "buffer_commit_received",
 		"sessionID": session.ID,
 	}).Info("Audio buffer commit received from client")
 
+	// Speech still in progress belongs to this commit, so clients need not send
+	// trailing silence for the VAD to end the last utterance
+	if s.usesVAD(session) {
+		s.vadIntegration.Flush(session.ID)
+	}
+
 	// Get current VAD buffer size for debugging
 	bufferSize, err := s.sessionManager.GetVADAudioBufferSize(session.ID)
 	if err != nil {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":   "proc_audio_main",
 			"action":      "get_vad_buffer_size_failed",
 			"sessionID":   session.ID,
 			"error":       err,
 		}).Error("Failed to get VAD buffer size")
 	} else {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":  "proc_audio_main",
 			"action":     "vad_buffer_size_checked",
 			"sessionID":  session.ID,
@@ -598,37 +1274,53 @@ func (s *OpenAIService) handleInputAudioBufferCommit(session *Session, _ *InputA
 		}).Info("VAD buffer contains samples before processing")
 	}
 
-	// Send input_audio_buffer.committed confirmation first
+	// Process the accumulated audio for recognition, confirming each item it creates
+	// with input_audio_buffer.committed; an empty commit is confirmed without an item
+	items, err := s.processAudioForRecognition(session, event)
+	if items == 0 {
+		s.sendCommitted(session, event, "", 0, 0, nil)
+	}
+	return err
+}
+
+// sendCommitted sends input_audio_buffer.committed for the item the commit event created
+// from samples of 16kHz audio holding segments speech segments
+func (s *OpenAIService) sendCommitted(session *Session, commit *InputAudioBufferCommitEvent, itemID string, samples int, segments int, channel *int) {
 	committedEvent := &InputAudioBufferCommittedEvent{
 		BaseEvent: BaseEvent{
 			Type:      EventTypeInputAudioBufferCommitted,
-			EventID:   GenerateEventID(),
+			EventID:   session.NewEventID(),
 			SessionID: session.ID,
 		},
+		CommitEventID: commit.EventID,
+		ItemID:       itemID,
+		DurationMs:   int64(samples) * 1000 / 16000,
+		SegmentCount: segments,
+		Channel:      channel,
 	}
 
 	if err := s.sessionManager.SendEvent(session, committedEvent); err != nil {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":   "proc_audio_main",
 			"action":      "send_committed_event_failed",
 			"sessionID":   session.ID,
 			"error":       err,
 		}).Error("Failed to send committed event")
 	} else {
-		logger.WithFields(logrus.Fields{
-			"component": "proc_audio_main",
-			"action":    "committed_event_sent",
-			"sessionID": session.ID,
+		session.Logger().WithFields(logrus.Fields{
+			"component":  "proc_audio_main",
+			"action":     "committed_event_sent",
+			"sessionID":  session.ID,
+			"itemID":     itemID,
+			"durationMs": committedEvent.DurationMs,
+			"segments":   segments,
 		}).Info("Sent committed confirmation to client")
 	}
-
-	// Process the accumulated audio for recognition
-	return s.processAudioForRecognition(session)
 }
 
 // handleInputAudioBufferCommitted processes input_audio_buffer.committed events
 func (s *OpenAIService) handleInputAudioBufferCommitted(session *Session, _ *InputAudioBufferCommittedEvent) error {
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component": "proc_audio_main",
 		"action":    "buffer_committed_received",
 		"sessionID": session.ID,
@@ -640,19 +1332,27 @@ func (s *OpenAIService) handleInputAudioBufferCommitted(session *Session, _ *Inp
 
 // handleInputAudioBufferClear processes input_audio_buffer.clear events
 func (s *OpenAIService) handleInputAudioBufferClear(session *Session, _ *InputAudioBufferClearEvent) error {
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component": "proc_audio_main",
 		"action":    "buffer_clear_received",
 		"sessionID": session.ID,
 	}).Info("Audio buffer clear received")
 
+	// Clear the sliding window and the utterance in progress along with the audio buffer
+	session.slidingWindow.reset()
+	session.interim.take()
+	s.abandonItemTrace(session, "cleared")
+
 	// Clear the audio buffer
+	for _, stream := range session.channels {
+		stream.clearCommitAudio(true)
+	}
 	return s.sessionManager.ClearAudioBuffer(session.ID)
 }
 
 // handleInputAudioBufferSpeechStarted processes speech started events
 func (s *OpenAIService) handleInputAudioBufferSpeechStarted(session *Session, event *InputAudioBufferSpeechStartedEvent) error {
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component":     "vad",
 		"action":        "speech_started",
 		"sessionID":     session.ID,
@@ -669,7 +1369,7 @@ func (s *OpenAIService) handleInputAudioBufferSpeechStarted(session *Session, ev
 
 // handleInputAudioBufferSpeechStopped processes speech stopped events
 func (s *OpenAIService) handleInputAudioBufferSpeechStopped(session *Session, event *InputAudioBufferSpeechStoppedEvent) error {
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component":    "vad",
 		"action":       "speech_stopped",
 		"sessionID":    session.ID,
@@ -681,30 +1381,49 @@ func (s *OpenAIService) handleInputAudioBufferSpeechStopped(session *Session, ev
 	})
 
 	// Auto-commit audio buffer on speech stop
-	return s.processAudioForRecognition(session)
+	_, err := s.processAudioForRecognition(session, nil)
+	return err
+}
+
+// processAudioForRecognition processes accumulated audio for speech recognition and
+// returns the number of conversation items created. Each item of a client commit is
+// confirmed with input_audio_buffer.committed, commit is nil for automatic commits.
+func (s *OpenAIService) processAudioForRecognition(session *Session, commit *InputAudioBufferCommitEvent) (int, error) {
+	items := 0
+	for _, source := range session.vadStreams() {
+		committed, err := s.recognizeStream(session, source, commit)
+		if committed {
+			items++
+		}
+		if err != nil {
+			return items, err
+		}
+	}
+	return items, nil
 }
 
-// processAudioForRecognition processes accumulated audio for speech recognition
-func (s *OpenAIService) processAudioForRecognition(session *Session) error {
+// recognizeStream commits the audio of source, the session itself or one of its
+// channel streams, as a conversation item of the session and reports whether it
+// created one
+func (s *OpenAIService) recognizeStream(session *Session, source *Session, commit *InputAudioBufferCommitEvent) (bool, error) {
 	startTime := time.Now()
 
-	// Get current VAD audio buffer (contains only speech segments)
-	buffer, err := s.sessionManager.GetVADAudioBuffer(session.ID)
-	if err != nil {
-		return fmt.Errorf("failed to get VAD audio buffer: %v", err)
-	}
+	// Get current VAD audio buffer (contains only speech segments), or the raw audio
+	// buffer of sessions without turn detection
+	manual := !s.usesVAD(session)
+	buffer, segmentEnds := source.commitAudio(manual)
 
 	if len(buffer) == 0 {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component": "proc_audio_main",
 			"action":    "no_vad_audio_data",
 			"sessionID": session.ID,
 		}).Info("No VAD audio data to process")
-		return nil
+		return false, nil
 	}
 
 	bufferDuration := float64(len(buffer)) / 16000.0 // Calculate duration in seconds
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component":     "proc_audio_main",
 		"action":        "processing_vad_audio_for_recognition",
 		"sampleCount":   len(buffer),
@@ -712,17 +1431,38 @@ func (s *OpenAIService) processAudioForRecognition(session *Session) error {
 		"sessionID":     session.ID,
 	}).Info("Processing VAD-filtered samples for recognition")
 
-	// Create conversation item for this recognition
-	item, err := s.sessionManager.CreateConversationItem(session.ID, "message", "user")
+	// Create conversation item for this recognition, reusing the ID of its deltas
+	stream := session.interim.takeStream()
+	item, err := s.sessionManager.CreateConversationItemWithID(session.ID, session.interim.take(), "message", "user")
+	if err != nil && stream != nil {
+		stream.Close()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create conversation item: %v", err)
+		return false, fmt.Errorf("failed to create conversation item: %v", err)
+	}
+
+	audioStartMs := source.takeUtteranceStart()
+	if manual {
+		audioStartMs = source.rawAudioStartMs(len(buffer))
+	}
+	settings := s.sessionManager.snapshotItemSettings(session)
+	s.sessionManager.UpdateConversationItem(session.ID, item.ID, func(item *ConversationItem) {
+		item.AudioStartMs = audioStartMs
+		item.AudioEndMs = audioStartMs + int64(len(buffer))*1000/16000
+		item.Channel = source.channelIndex()
+		item.Hints = settings.hints
+	})
+	session.captionEdge.committed()
+
+	if commit != nil {
+		s.sendCommitted(session, commit, item.ID, len(buffer), len(segmentEnds), source.channelIndex())
 	}
 
 	// Send conversation.item.created event
 	itemCreatedEvent := &ConversationItemCreatedEvent{
 		BaseEvent: BaseEvent{
 			Type:      EventTypeConversationItemCreated,
-			EventID:   GenerateEventID(),
+			EventID:   session.NewEventID(),
 			SessionID: session.ID,
 		},
 		Item: struct {
@@ -746,91 +1486,153 @@ func (s *OpenAIService) processAudioForRecognition(session *Session) error {
 				Format: "pcm16",
 			},
 		},
+		Channel: source.channelIndex(),
 	}
 
 	if err := s.sessionManager.SendEvent(session, itemCreatedEvent); err != nil {
-		return fmt.Errorf("failed to send conversation.item.created event: %v", err)
+		return true, fmt.Errorf("failed to send conversation.item.created event: %v", err)
 	}
 
-	// Process recognition asynchronously
-	go s.processRecognition(session, item.ID, buffer)
+	// Process recognition asynchronously, in the trace of the item
+	ctx := s.takeItemContext(session, item.ID)
+	session.spawn(func() { s.processRecognition(ctx, session, item.ID, buffer, segmentEnds, stream, settings) })
 
 	// Clear the VAD audio buffer after processing
-	if err := s.sessionManager.ClearVADAudioBuffer(session.ID); err != nil {
-		logger.WithFields(logrus.Fields{
-			"component":   "proc_audio_main",
-			"action":      "clear_vad_buffer_failed",
-			"sessionID":   session.ID,
-			"error":       err,
-		}).Error("Failed to clear VAD audio buffer")
-	}
+	source.clearCommitAudio(manual)
 
 	processingTimeMs := time.Since(startTime).Milliseconds()
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component":      "proc_audio_main",
 		"action":         "processing_completed",
 		"sessionID":      session.ID,
 		"processingTimeMs": processingTimeMs,
 	}).Debug("Completed audio processing for recognition")
 
-	return nil
+	return true, nil
 }
 
-// processRecognition processes audio recognition asynchronously
-func (s *OpenAIService) processRecognition(session *Session, itemID string, audioData []int16) {
+// processRecognition processes audio recognition asynchronously. ctx carries the root
+// span of the item's trace, which ends with the recognition. The transcript of an
+// utterance streamed to the engine is taken from its stream, the audio is sent as one
+// request when that fails. Long audio is split at segmentEnds, the end offsets of its
+// speech segments, into chunks recognized in parallel, see chunked_asr.go.
+func (s *OpenAIService) processRecognition(ctx context.Context, session *Session, itemID string, audioData []int16, segmentEnds []int, stream *llm.RecognitionStream, settings itemSettings) {
 	startTime := time.Now()
 	conversationItemCreationTime := startTime // Record when conversation item was created
-	logger.WithFields(logrus.Fields{
+
+	root := tracing.SpanFromContext(ctx)
+	defer root.End()
+	ctx, span := s.tracer.Start(ctx, spanRecognition, tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("item.id", itemID)
+	span.SetAttribute("audio.duration_ms", int64(len(audioData))*1000/16000)
+
+	session.Logger().WithFields(logrus.Fields{
 		"component":   "audio_recogniz",
 		"action":      "starting_processing",
 		"itemID":      itemID,
 		"sessionID":   session.ID,
 		"sampleCount": len(audioData),
+		"traceID":     root.TraceID(),
 	}).Debug("Starting recognition processing")
 
-	// Convert audio data to WAV format for recognition
-	wavData, err := s.convertToWAV(audioData)
-	if err != nil {
-		logger.WithFields(logrus.Fields{
-			"component":   "audio_recogniz",
-			"action":      "audio_conversion_failed",
-			"itemID":      itemID,
-			"sessionID":   session.ID,
-			"error":       err,
-		}).Error("Failed to convert audio to WAV")
-		s.sendRecognitionFailed(session, itemID, "audio_conversion_error", err.Error(), conversationItemCreationTime)
-		return
+	var result *llm.Transcription
+	recognitionStartTime := time.Now()
+	if stream != nil {
+		result, _ = s.finishStream(ctx, session, itemID, stream)
 	}
 
-	conversionTimeMs := time.Since(startTime).Milliseconds()
-	logger.WithFields(logrus.Fields{
-		"component":     "audio_recogniz",
-		"action":        "audio_conversion_completed",
-		"sessionID":     session.ID,
-		"wavDataSize":   len(wavData),
-		"conversionTimeMs": conversionTimeMs,
-	}).Info("Audio conversion completed")
+	// The vocabulary the item was committed with
+	var hints []string
+	if item, err := s.sessionManager.GetConversationItem(session.ID, itemID); err == nil {
+		hints = item.Hints
+	}
 
-	// Call speech recognition API
-	recognitionStartTime := time.Now()
-	text, err := s.callRecognitionAPI(wavData)
-	if err != nil {
-		recognitionTimeMs := time.Since(recognitionStartTime).Milliseconds()
-		logger.WithFields(logrus.Fields{
-			"component":      "audio_recogniz",
-			"action":         "recognition_failed",
-			"itemID":         itemID,
-			"sessionID":      session.ID,
-			"recognitionTimeMs": recognitionTimeMs,
-			"error":          err,
-		}).Error("Recognition failed")
-		s.sendRecognitionFailed(session, itemID, "recognition_error", err.Error(), conversationItemCreationTime)
-		return
+	// Chunks record their own ASR calls
+	chunks := s.recognitionChunks(len(audioData), segmentEnds)
+	chunked := result == nil && len(chunks) > 1
+	if chunked {
+		var err error
+		result, err = s.transcribeChunks(ctx, session, itemID, audioData, chunks, hints)
+		if err != nil {
+			span.RecordError(err)
+			if recognitionCancelled(session, itemID) {
+				return
+			}
+			session.Logger().WithFields(logrus.Fields{
+				"component": "audio_recogniz",
+				"action":    "chunked_recognition_failed",
+				"itemID":    itemID,
+				"sessionID": session.ID,
+				"chunks":    len(chunks),
+				"error":     err,
+			}).Error("Chunked recognition failed")
+			s.sendRecognitionFailed(session, itemID, recognitionErrorCode(err), err.Error(), conversationItemCreationTime)
+			s.recordExperimentItem(session, time.Since(startTime), true, -1, -1)
+			return
+		}
+	}
+
+	if result == nil {
+		// Convert audio data to WAV format for recognition
+		_, encodeSpan := s.tracer.Start(ctx, spanEncodeWAV, tracing.KindInternal)
+		wavData, err := s.convertToWAV(audioData)
+		encodeSpan.RecordError(err)
+		encodeSpan.End()
+		if err != nil {
+			span.RecordError(err)
+			session.Logger().WithFields(logrus.Fields{
+				"component":   "audio_recogniz",
+				"action":      "audio_conversion_failed",
+				"itemID":      itemID,
+				"sessionID":   session.ID,
+				"error":       err,
+			}).Error("Failed to convert audio to WAV")
+			s.sendRecognitionFailed(session, itemID, "audio_conversion_error", err.Error(), conversationItemCreationTime)
+			s.recordExperimentItem(session, time.Since(startTime), true, -1, -1)
+			return
+		}
+
+		conversionTimeMs := time.Since(startTime).Milliseconds()
+		session.Logger().WithFields(logrus.Fields{
+			"component":     "audio_recogniz",
+			"action":        "audio_conversion_completed",
+			"sessionID":     session.ID,
+			"wavDataSize":   len(wavData),
+			"conversionTimeMs": conversionTimeMs,
+		}).Info("Audio conversion completed")
+
+		// Call speech recognition API with the vocabulary the item was committed with
+		recognitionStartTime = time.Now()
+		result, err = s.callRecognitionAPI(ctx, wavData, session.ForwardedHeaders, session.recognitionEndpoint(hints))
+		if err != nil {
+			session.recordASRCall(time.Since(recognitionStartTime), err)
+			span.RecordError(err)
+			if recognitionCancelled(session, itemID) {
+				return
+			}
+			recognitionTimeMs := time.Since(recognitionStartTime).Milliseconds()
+			session.Logger().WithFields(logrus.Fields{
+				"component":      "audio_recogniz",
+				"action":         "recognition_failed",
+				"itemID":         itemID,
+				"sessionID":      session.ID,
+				"recognitionTimeMs": recognitionTimeMs,
+				"error":          err,
+			}).Error("Recognition failed")
+			s.sendRecognitionFailed(session, itemID, recognitionErrorCode(err), err.Error(), conversationItemCreationTime)
+			s.recordExperimentItem(session, time.Since(startTime), true, -1, -1)
+			return
+		}
 	}
 
+	text := result.Text
+	if !chunked {
+		session.recordASRCall(time.Since(recognitionStartTime), nil)
+	}
 	recognitionTimeMs := time.Since(recognitionStartTime).Milliseconds()
 	totalTimeMs := time.Since(startTime).Milliseconds()
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component":       "audio_recogniz",
 		"action":          "recognition_successful",
 		"itemID":          itemID,
@@ -840,8 +1642,51 @@ func (s *OpenAIService) processRecognition(session *Session, itemID string, audi
 		"totalTimeMs":     totalTimeMs,
 	}).Info("Recognition successful")
 
+	// Apply the session's formatting profile
+	text = textformat.Apply(text, settings.format)
+
+	// Score the input audio so poor transcripts can be attributed to poor audio
+	quality := audioquality.Analyze(audioData, 16000)
+	session.quality.Add(quality)
+	session.Logger().WithFields(logrus.Fields{
+		"component":     "audio_recogniz",
+		"action":        "audio_quality_scored",
+		"itemID":        itemID,
+		"sessionID":     session.ID,
+		"score":         quality.Score,
+		"snrDb":         quality.SNRDb,
+		"clippingRatio": quality.ClippingRatio,
+		"bandwidthHz":   quality.BandwidthHz,
+	}).Debug("Audio quality scored")
+
+	// Keep word confidence with the item for transcript export
+	if len(result.Words) > 0 {
+		s.sessionManager.SetConversationItemWords(session.ID, itemID, result.Words)
+	}
+
+	// Sessions without a set language learn the language of each item
+	if language := session.transcriptLanguage(result, text); language != "" {
+		s.sessionManager.UpdateConversationItem(session.ID, itemID, func(item *ConversationItem) {
+			item.Language = language
+		})
+	}
+	s.recordExperimentItem(session, time.Since(startTime), false, meanWordConfidence(result.Words), quality.Score)
+
 	// Send transcription completed event
-	s.sendRecognitionCompleted(session, itemID, text, conversationItemCreationTime)
+	s.sendRecognitionCompleted(session, itemID, text, &quality, conversationItemCreationTime)
+
+	// Split the transcript into timed caption cues
+	if settings.captions.Enabled {
+		s.sendCaptionCues(session, itemID, text, int64(len(audioData))*1000/16000, settings.captions.CueOptions)
+	}
+}
+
+// recognitionErrorCode returns the code of the failed event of a recognition error
+func recognitionErrorCode(err error) string {
+	if errors.Is(err, errASROverloaded) {
+		return "asr_overloaded"
+	}
+	return "recognition_error"
 }
 
 // convertToWAV converts PCM audio data to WAV format
@@ -852,6 +1697,11 @@ func (s *OpenAIService) convertToWAV(audioData []int16) ([]byte, error) {
 		"sampleCount": len(audioData),
 	}).Info("Converting PCM samples to WAV format")
 
+	// Pad with silence for engines that clip speech at the edges
+	if cfg := s.appConfig(); cfg != nil {
+		audioData = s.audioUtils.PadSilence(audioData, 16000, cfg.ASR.PadLeadingMs, cfg.ASR.PadTrailingMs)
+	}
+
 	// Use the audio utilities to convert PCM to WAV
 	wavData, err := s.audioUtils.ConvertPCM16ToWAV(audioData, 16000) // Default to 16kHz for ASR
 	if err != nil {
@@ -873,38 +1723,65 @@ func (s *OpenAIService) convertToWAV(audioData []int16) ([]byte, error) {
 	return wavData, nil
 }
 
-// callRecognitionAPI calls the speech recognition API
-func (s *OpenAIService) callRecognitionAPI(wavData []byte) (string, error) {
+// callRecognitionAPI calls the speech recognition API. A span of ctx becomes the
+// parent of the call's span, whose traceparent is sent to the ASR backend.
+func (s *OpenAIService) callRecognitionAPI(ctx context.Context, wavData []byte, headers http.Header, endpoint *llm.Endpoint) (*llm.Transcription, error) {
 	logger.WithFields(logrus.Fields{
 		"component":   "asr_api_core",
 		"action":      "calling_recognition_api",
 		"dataSize":    len(wavData),
 	}).Info("Calling speech recognition API")
 
-	// Use the existing LLM package for speech recognition
-	text, err := llm.CallOpenaiAPI(wavData)
+	ctx, span := s.tracer.Start(ctx, spanASRTranscribe, tracing.KindClient)
+	defer span.End()
+	if span != nil {
+		headers = headers.Clone()
+		if headers == nil {
+			headers = http.Header{}
+		}
+		tracing.Inject(ctx, headers)
+		span.SetAttribute("asr.audio_bytes", len(wavData))
+		if endpoint != nil && endpoint.Model != "" {
+			span.SetAttribute("asr.model", endpoint.Model)
+		}
+	}
+
+	// Use the existing LLM package for speech recognition, balanced over the engine replicas
+	result, err := s.transcribe(ctx, wavData, headers, endpoint)
 	if err != nil {
+		span.RecordError(err)
 		logger.WithFields(logrus.Fields{
 			"component":   "api_asr_core",
 			"action":      "api_call_failed",
 			"dataSize":    len(wavData),
 			"error":       err,
 		}).Error("Speech recognition API call failed")
-		return "", err
+		return nil, err
+	}
+
+	// Word timestamps are relative to the padded audio, move them back to the utterance
+	if cfg := s.appConfig(); cfg != nil && cfg.ASR.PadLeadingMs > 0 {
+		offset := float64(cfg.ASR.PadLeadingMs) / 1000
+		for i := range result.Words {
+			result.Words[i].Start = max(0, result.Words[i].Start-offset)
+			result.Words[i].End = max(0, result.Words[i].End-offset)
+		}
 	}
 
+	span.SetAttribute("asr.words", len(result.Words))
 	logger.WithFields(logrus.Fields{
 		"component":   "api_asr_core",
 		"action":      "api_call_successful",
 		"dataSize":    len(wavData),
-		"recognizedText": text,
+		"recognizedText": result.Text,
+		"words":       len(result.Words),
 	}).Info("Speech recognition API call successful")
-	return text, nil
+	return result, nil
 }
 
 // sendRecognitionCompleted sends transcription completed event
-func (s *OpenAIService) sendRecognitionCompleted(session *Session, itemID string, text string, conversationItemCreationTime time.Time) {
-	logger.WithFields(logrus.Fields{
+func (s *OpenAIService) sendRecognitionCompleted(session *Session, itemID string, text string, quality *audioquality.Metrics, conversationItemCreationTime time.Time) {
+	session.Logger().WithFields(logrus.Fields{
 		"component":   "ws_event_send ",
 		"action":      "sending_transcription_completed",
 		"itemID":      itemID,
@@ -915,7 +1792,7 @@ func (s *OpenAIService) sendRecognitionCompleted(session *Session, itemID string
 	completedEvent := &ConversationItemInputAudioTranscriptionCompletedEvent{
 		BaseEvent: BaseEvent{
 			Type:      EventTypeConversationItemInputAudioTranscriptionCompleted,
-			EventID:   GenerateEventID(),
+			EventID:   session.NewEventID(),
 			SessionID: session.ID,
 		},
 		Item: struct {
@@ -940,10 +1817,18 @@ func (s *OpenAIService) sendRecognitionCompleted(session *Session, itemID string
 				},
 			},
 		},
+		Quality: quality,
+	}
+	if item, err := s.sessionManager.GetConversationItem(session.ID, itemID); err == nil {
+		completedEvent.TraceID = item.TraceID
+		completedEvent.Channel = item.Channel
+		completedEvent.Language = item.Language
+		completedEvent.AudioStartMs = item.AudioStartMs
+		completedEvent.AudioEndMs = item.AudioEndMs
 	}
 
 	if err := s.sessionManager.SendEvent(session, completedEvent); err != nil {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":   "error",
 			"action":      "send_transcription_completed_failed",
 			"itemID":      itemID,
@@ -951,7 +1836,8 @@ func (s *OpenAIService) sendRecognitionCompleted(session *Session, itemID string
 			"error":       err,
 		}).Error("Failed to send transcription completed event")
 	} else {
-		logger.WithFields(logrus.Fields{
+		session.bandwidth.addOutboundTranscript(text)
+		session.Logger().WithFields(logrus.Fields{
 			"component":   "",
 			"action":      "transcription_completed_sent",
 			"itemID":      itemID,
@@ -959,9 +1845,18 @@ func (s *OpenAIService) sendRecognitionCompleted(session *Session, itemID string
 		}).Info("Successfully sent transcription completed event")
 	}
 
-	// Mark conversation item as completed
+	// Store transcript and mark conversation item as completed
+	if err := s.sessionManager.SetConversationItemTranscript(session.ID, itemID, text); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "mg_session_ctrl",
+			"action":    "store_transcript_failed",
+			"itemID":    itemID,
+			"sessionID": session.ID,
+			"error":     err,
+		}).Warn("Failed to store transcript on conversation item")
+	}
 	if err := s.sessionManager.MarkConversationItemCompleted(session.ID, itemID); err != nil {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":   "error",
 			"action":      "mark_item_completed_failed",
 			"itemID":      itemID,
@@ -972,7 +1867,7 @@ func (s *OpenAIService) sendRecognitionCompleted(session *Session, itemID string
 		// Calculate conversation item processing time in milliseconds
 		conversationItemProcessingTimeMs := time.Since(conversationItemCreationTime).Milliseconds()
 
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":                      "mg_session",
 			"action":                         "item_marked_completed",
 			"itemID":                         itemID,
@@ -981,7 +1876,7 @@ func (s *OpenAIService) sendRecognitionCompleted(session *Session, itemID string
 		}).Info("Conversation item processing completed")
 
 		// Additional detailed logging for performance monitoring
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":                      "mg_performance",
 			"action":                         "conversation_item_processed",
 			"itemID":                         itemID,
@@ -989,12 +1884,17 @@ func (s *OpenAIService) sendRecognitionCompleted(session *Session, itemID string
 			"conversationItemProcessingTimeMs": conversationItemProcessingTimeMs,
 			"textLength":                     len(text),
 		}).Info("ASR Conversation item performance metrics")
+
+		s.persistConversationItem(session, itemID)
+		s.writeCaptionFiles(session, itemID)
 	}
+
+	s.enforceConversationLimits(session)
 }
 
 // sendRecognitionFailed sends transcription failed event
 func (s *OpenAIService) sendRecognitionFailed(session *Session, itemID string, errorCode string, errorMessage string, conversationItemCreationTime time.Time) {
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component":    "ws_event_send ",
 		"action":       "sending_transcription_failed",
 		"itemID":       itemID,
@@ -1006,24 +1906,19 @@ func (s *OpenAIService) sendRecognitionFailed(session *Session, itemID string, e
 	failedEvent := &ConversationItemInputAudioTranscriptionFailedEvent{
 		BaseEvent: BaseEvent{
 			Type:      EventTypeConversationItemInputAudioTranscriptionFailed,
-			EventID:   GenerateEventID(),
+			EventID:   session.NewEventID(),
 			SessionID: session.ID,
 		},
 		ItemID: itemID,
-		Error: struct {
-			Type    string `json:"type"`
-			Code    string `json:"code"`
-			Message string `json:"message"`
-			Param   string `json:"param,omitempty"`
-		}{
-			Type:    "api_error",
-			Code:    errorCode,
-			Message: errorMessage,
-		},
+		Error:  newErrorDetails("api_error", errorCode, errorMessage, ""),
+	}
+	if item, err := s.sessionManager.GetConversationItem(session.ID, itemID); err == nil {
+		failedEvent.TraceID = item.TraceID
+		failedEvent.Channel = item.Channel
 	}
 
 	if err := s.sessionManager.SendEvent(session, failedEvent); err != nil {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":   "error",
 			"action":      "send_transcription_failed_failed",
 			"itemID":      itemID,
@@ -1031,7 +1926,7 @@ func (s *OpenAIService) sendRecognitionFailed(session *Session, itemID string, e
 			"error":       err,
 		}).Error("Failed to send transcription failed event")
 	} else {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":   "ws_event_send ",
 			"action":      "transcription_failed_sent",
 			"itemID":      itemID,
@@ -1041,7 +1936,7 @@ func (s *OpenAIService) sendRecognitionFailed(session *Session, itemID string, e
 
 	// Mark conversation item as failed
 	if err := s.sessionManager.MarkConversationItemFailed(session.ID, itemID, errorMessage); err != nil {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":   "error",
 			"action":      "mark_item_failed_failed",
 			"itemID":      itemID,
@@ -1052,7 +1947,7 @@ func (s *OpenAIService) sendRecognitionFailed(session *Session, itemID string, e
 		// Calculate conversation item processing time in milliseconds (failed case)
 		conversationItemProcessingTimeMs := time.Since(conversationItemCreationTime).Milliseconds()
 
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":                      "mg_session",
 			"action":                         "item_marked_failed",
 			"itemID":                         itemID,
@@ -1062,7 +1957,7 @@ func (s *OpenAIService) sendRecognitionFailed(session *Session, itemID string, e
 		}).Info("Conversation item processing failed")
 
 		// Additional detailed logging for performance monitoring (failed case)
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":                      "mg_performance",
 			"action":                         "conversation_item_failed",
 			"itemID":                         itemID,
@@ -1071,12 +1966,80 @@ func (s *OpenAIService) sendRecognitionFailed(session *Session, itemID string, e
 			"errorCode":                      errorCode,
 			"errorMessageLength":             len(errorMessage),
 		}).Info("ASR Conversation item failure metrics")
+
+		s.persistConversationItem(session, itemID)
+	}
+
+	s.enforceConversationLimits(session)
+}
+
+// enforceConversationLimits evicts the oldest finished conversation items beyond the
+// configured limits and notifies the client with a conversation.truncated event
+func (s *OpenAIService) enforceConversationLimits(session *Session) {
+	evicted, reason, err := s.sessionManager.TruncateConversation(session.ID)
+	if err != nil || len(evicted) == 0 {
+		return
+	}
+
+	session.itemsMutex.RLock()
+	remaining := len(session.ConversationItems)
+	session.itemsMutex.RUnlock()
+
+	session.Logger().WithFields(logrus.Fields{
+		"component":      "mg_session_ctrl",
+		"action":         "conversation_truncated",
+		"sessionID":      session.ID,
+		"evictedItems":   len(evicted),
+		"remainingItems": remaining,
+		"reason":         reason,
+	}).Info("Conversation truncated to respect configured limits")
+
+	truncatedEvent := &ConversationTruncatedEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeConversationTruncated,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		ItemIDs:        evicted,
+		Reason:         reason,
+		RemainingItems: remaining,
+	}
+
+	if err := s.sessionManager.SendEvent(session, truncatedEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "ws_event_send",
+			"action":    "send_conversation_truncated_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Failed to send conversation.truncated event")
+	}
+}
+
+// sendErrorEvent sends a structured error event to the client
+func (s *OpenAIService) sendErrorEvent(session *Session, errorType string, code string, message string, param string) {
+	errorEvent := &ErrorEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeError,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		Error: newErrorDetails(errorType, code, message, param),
+	}
+
+	if err := s.sessionManager.SendEvent(session, errorEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "ws_event_send",
+			"action":    "send_error_event_failed",
+			"sessionID": session.ID,
+			"code":      code,
+			"error":     err,
+		}).Error("Failed to send error event")
 	}
 }
 
 // handleConversationItemDeleted processes conversation.item.deleted events
 func (s *OpenAIService) handleConversationItemDeleted(session *Session, event *ConversationItemDeletedEvent) error {
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component": "mg_conv_ctrl",
 		"action":    "item_deleted",
 		"sessionID": session.ID,
@@ -1090,7 +2053,7 @@ func (s *OpenAIService) handleConversationItemDeleted(session *Session, event *C
 
 // handleInputAudioBufferCleared processes input_audio_buffer.cleared events
 func (s *OpenAIService) handleInputAudioBufferCleared(session *Session, event *InputAudioBufferClearedEvent) error {
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component": "proc_audio_main",
 		"action":    "buffer_cleared",
 		"sessionID": session.ID,
@@ -1114,7 +2077,7 @@ func (s *OpenAIService) heartbeatLoop(ctx context.Context, session *Session) {
 			session.mutex.Lock()
 			if session.Conn == nil {
 				session.mutex.Unlock()
-				logger.WithFields(logrus.Fields{
+				session.Logger().WithFields(logrus.Fields{
 					"component":   "mont_hrtbeat_act",
 					"action":      "send_ping_failed",
 					"sessionID":   session.ID,
@@ -1123,20 +2086,9 @@ func (s *OpenAIService) heartbeatLoop(ctx context.Context, session *Session) {
 				return
 			}
 
-			if err := session.Conn.SetWriteDeadline(time.Now().Add(2 * time.Second)); err != nil {
-				session.mutex.Unlock()
-				logger.WithFields(logrus.Fields{
-					"component":   "mont_hrtbeat_act",
-					"action":      "send_ping_failed",
-					"sessionID":   session.ID,
-					"error":       err,
-				}).Error("Failed to set write deadline for ping")
-				return
-			}
-
-			if err := session.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := session.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(2*time.Second)); err != nil {
 				session.mutex.Unlock()
-				logger.WithFields(logrus.Fields{
+				session.Logger().WithFields(logrus.Fields{
 					"component":   "mont_hrtbeat_act",
 					"action":      "send_ping_failed",
 					"sessionID":   session.ID,
@@ -1145,19 +2097,32 @@ func (s *OpenAIService) heartbeatLoop(ctx context.Context, session *Session) {
 				return
 			}
 			session.mutex.Unlock()
+			session.bandwidth.addOutbound(0)
 
-			logger.WithFields(logrus.Fields{
+			session.Logger().WithFields(logrus.Fields{
 				"component": "mont_hrtbeat_act",
 				"action":    "ping_sent",
 				"sessionID": session.ID,
 			}).Debug("Sent ping to session")
+
+			// Session duration limits must hold even while no audio is appended
+			if s.enforceLimits(session, 0) {
+				return
+			}
 		}
 	}
 }
 
 // GetSessionStats returns session statistics
 func (s *OpenAIService) GetSessionStats() map[string]interface{} {
-	return s.sessionManager.GetSessionStats()
+	stats := s.sessionManager.GetSessionStats()
+	if s.asrConcurrency != nil {
+		stats["asr_concurrency"] = s.asrConcurrency.Stats()
+	}
+	if s.sessionManager.events != nil {
+		stats["event_sink"] = s.sessionManager.events.Stats()
+	}
+	return stats
 }
 
 // Cleanup performs cleanup operations
@@ -1167,55 +2132,32 @@ func (s *OpenAIService) Cleanup() {
 		s.cancel()
 	}
 
+	if s.polls != nil {
+		s.stopPollSessions()
+	}
 	s.sessionManager.CleanupInactiveSessions()
-}
-
-// startAudioCleanup starts a routine to clean up old audio files
-func (s *OpenAIService) startAudioCleanup(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Minute) // Check every 5 minutes
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			// Get keep_files from config, default to 10
-			keepFiles := s.appConfig.Audio.KeepFiles
-			if keepFiles <= 0 {
-				keepFiles = 10
-			}
-
-			if err := s.audioUtils.CleanOldAudioFiles(keepFiles); err != nil {
-				logger.WithFields(logrus.Fields{
-					"component": "cln_audio_proc",
-					"action":    "cleanup_failed",
-					"error":     err,
-					"keepFiles": keepFiles,
-				}).Error("Failed to clean up old audio files")
-			}
-		case <-ctx.Done():
-			// Context cancelled, exit gracefully
-			logger.WithFields(logrus.Fields{
-				"component": "cln_audio_proc",
-				"action":    "cleanup_stopped",
-			}).Info("Audio cleanup routine stopped")
-			return
-		}
+	if s.transcripts != nil {
+		s.transcripts.close(10 * time.Second)
+	}
+	if s.sessionManager.events != nil {
+		s.sessionManager.events.close(10 * time.Second)
+	}
+	s.shutdownTracer()
+	if s.localASR != nil {
+		s.localASR.Close()
 	}
 }
 
 // accumulateAudioForSaving accumulates audio data based on buffer_size config and saves at time intervals
 func (s *OpenAIService) accumulateAudioForSaving(session *Session, samples []int16) error {
 	// Get configured buffer_size in seconds
-	bufferSize := s.appConfig.Audio.BufferSize
+	bufferSize := s.appConfig().Audio.BufferSize
 	if bufferSize <= 0 {
 		bufferSize = 10 // default 10 seconds
 	}
 
-	// Get sample rate for time calculations
-	sampleRate := session.InputAudioFormat.SampleRate
-	if sampleRate == 0 {
-		sampleRate = 16000 // fallback to 16kHz
-	}
+	// Saved audio is mono at the input sample rate
+	sampleRate := session.inputSampleRate()
 
 	session.AudioSaveMutex.Lock()
 	defer session.AudioSaveMutex.Unlock()
@@ -1226,7 +2168,7 @@ func (s *OpenAIService) accumulateAudioForSaving(session *Session, samples []int
 	if session.AccumulationStartTime.IsZero() {
 		session.AccumulationStartTime = now
 		session.AccumulatedAudio = make([]int16, 0)
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":       "ws_audio_core ",
 			"action":          "accumulation_started",
 			"sessionID":       session.ID,
@@ -1248,15 +2190,13 @@ func (s *OpenAIService) accumulateAudioForSaving(session *Session, samples []int
 	shouldSave := elapsedTime >= float64(bufferSize) || accumulatedDuration >= float64(bufferSize)
 
 	if shouldSave {
-		// Generate filename
-		filename := fmt.Sprintf("segment_%s_%d.wav", session.ID[:8], session.AccumulationStartTime.UnixNano()/1000000)
-
-		// Save accumulated audio file
-		if err := s.audioUtils.SaveAudioToFile(session.AccumulatedAudio, sampleRate, filename); err != nil {
+		// Save accumulated audio as the next segment of the session's recording
+		filename, err := s.saveRecordingSegment(session, session.AccumulatedAudio, sampleRate, session.AccumulationStartTime)
+		if err != nil {
 			return fmt.Errorf("failed to save accumulated audio: %v", err)
 		}
 
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":          "ws_audio_core ",
 			"action":             "saved_accumulated_segment",
 			"sessionID":          session.ID,
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/errcode"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// Events of a WebSocket session are queued and written by a goroutine of the
+// connection, so a client that reads slowly does not hold up recognition and VAD
+// goroutines sending events. The queue is bounded: once a client falls that far
+// behind, the oldest events are dropped, or the session is closed, and the client is
+// told with a buffer_overflow error. Dropped events stay in the replay buffer, and
+// leave a gap in the seq numbers of the events the client receives.
+
+// Policies of outbound.policy
+const (
+	OutboundPolicyDropOldest   = "drop_oldest"
+	OutboundPolicyCloseSession = "close_session"
+)
+
+// outboundWriteTimeout bounds the write of a single event to a client
+const outboundWriteTimeout = 5 * time.Second
+
+// outboundSettings holds the settings of outbound, applying their defaults
+type outboundSettings struct {
+	queueSize int
+	policy    string
+}
+
+func newOutboundSettings(cfg *config.Config) *outboundSettings {
+	o := &outboundSettings{
+		queueSize: cfg.Outbound.QueueSize,
+		policy:    cfg.Outbound.Policy,
+	}
+	if o.queueSize <= 0 {
+		o.queueSize = 256
+	}
+	if o.policy != OutboundPolicyCloseSession {
+		o.policy = OutboundPolicyDropOldest
+	}
+	return o
+}
+
+// outboundQueue holds the encoded events of a session waiting to be written, guarded
+// by the session mutex
+type outboundQueue struct {
+	events  [][]byte
+	limit   int
+	policy  string
+	ready   chan struct{} // signalled when events are queued
+	dropped int           // events dropped since the last buffer_overflow error
+	closing bool          // the queue overflowed under close_session
+	writing bool          // the writer holds events taken from the queue
+}
+
+func newOutboundQueue(settings *outboundSettings) *outboundQueue {
+	return &outboundQueue{
+		limit:  settings.queueSize,
+		policy: settings.policy,
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// push queues an event and reports whether the queue overflowed under close_session.
+// A full queue under drop_oldest drops its oldest event.
+func (q *outboundQueue) push(data []byte) bool {
+	if q.closing {
+		return false
+	}
+	if len(q.events) >= q.limit {
+		if q.policy == OutboundPolicyCloseSession {
+			q.closing = true
+			q.dropped += len(q.events) + 1
+			q.events = nil
+			q.signal()
+			return true
+		}
+		q.events = q.events[1:]
+		q.dropped++
+	}
+	q.events = append(q.events, data)
+	q.signal()
+	return false
+}
+
+// pushAll queues events regardless of the limit, e.g. those replayed on resume
+func (q *outboundQueue) pushAll(events [][]byte) {
+	q.events = append(q.events, events...)
+	q.signal()
+}
+
+// take removes the queued events for the writer
+func (q *outboundQueue) take() [][]byte {
+	events := q.events
+	q.events = nil
+	q.writing = len(events) > 0
+	return events
+}
+
+// reset drops the queued events, which the replay buffer still holds, when the
+// session moves to another connection or is parked
+func (q *outboundQueue) reset() {
+	q.events = nil
+	q.dropped = 0
+}
+
+func (q *outboundQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pushOutbound queues an encoded event of a WebSocket session, the caller holds the
+// session mutex
+func (sm *SessionManager) pushOutbound(session *Session, jsonData []byte) {
+	if !session.outbound.push(jsonData) {
+		return
+	}
+	session.Logger().WithFields(logrus.Fields{
+		"component": "ws_event_send",
+		"action":    "outbound_queue_overflow",
+		"sessionID": session.ID,
+		"limit":     session.outbound.limit,
+	}).Warn("Client does not read events fast enough, closing the session")
+	session.outbound.pushAll([][]byte{sm.overflowEvent(session)})
+}
+
+// overflowEvent returns the buffer_overflow error telling the client how many events
+// were dropped, numbered and kept for replay like any other event. The caller holds
+// the session mutex.
+func (sm *SessionManager) overflowEvent(session *Session) []byte {
+	errorEvent := &ErrorEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeError,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+	}
+	errorEvent.Error.Type = "rate_limited"
+	errorEvent.Error.Code = "buffer_overflow"
+	errorEvent.Error.Category = errcode.RateLimited
+	errorEvent.Error.Message = fmt.Sprintf("client does not read events fast enough, %d events were dropped", session.outbound.dropped)
+	if session.outbound.closing {
+		errorEvent.Error.Message += ", closing the session"
+	}
+	session.outbound.dropped = 0
+
+	session.eventSeq++
+	errorEvent.Seq = session.eventSeq
+	data, _ := json.Marshal(errorEvent)
+	if session.replay != nil {
+		session.replay.add(errorEvent.EventID, data)
+	}
+	return data
+}
+
+// takeOutbound returns the queued events of the session for the writer of conn,
+// followed by a buffer_overflow error when events were dropped, and whether the
+// session is to be closed once they are written. It reports false when conn no
+// longer serves the session.
+func (sm *SessionManager) takeOutbound(session *Session, conn *websocket.Conn) ([][]byte, bool, bool) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	queue := session.outbound
+	if session.Conn != conn {
+		return nil, false, false
+	}
+	events := queue.take()
+	if queue.dropped > 0 && !queue.closing {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "ws_event_send",
+			"action":    "outbound_events_dropped",
+			"sessionID": session.ID,
+			"dropped":   queue.dropped,
+		}).Warn("Client does not read events fast enough, dropped the oldest events")
+		events = append(events, sm.overflowEvent(session))
+		queue.writing = true
+	}
+	return events, queue.closing, true
+}
+
+// outboundLoop writes the queued events of a session to conn until ctx ends. writeMutex
+// is held for every write, as a connection resuming a session is written by the
+// writers of both the session it was created with and the session it resumed.
+func (s *OpenAIService) outboundLoop(ctx context.Context, session *Session, conn *websocket.Conn, writeMutex *sync.Mutex) {
+	queue := session.outbound
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-queue.ready:
+		}
+
+		events, closing, served := s.sessionManager.takeOutbound(session, conn)
+		if !served {
+			// The events are for the writer of the connection now serving the session
+			queue.signal()
+			return
+		}
+		err := writeOutbound(session, conn, writeMutex, events)
+
+		session.mutex.Lock()
+		queue.writing = false
+		session.mutex.Unlock()
+
+		if err != nil {
+			session.Logger().WithFields(logrus.Fields{
+				"component": "ws_event_send",
+				"action":    "write_event_failed",
+				"sessionID": session.ID,
+				"error":     err,
+			}).Warn("Failed to write events to client, closing the connection")
+			conn.Close()
+			return
+		}
+		if closing {
+			message := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "buffer_overflow")
+			conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+			conn.Close()
+			return
+		}
+	}
+}
+
+// writeOutbound writes encoded events to conn
+func writeOutbound(session *Session, conn *websocket.Conn, writeMutex *sync.Mutex, events [][]byte) error {
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+
+	for _, data := range events {
+		if err := conn.SetWriteDeadline(time.Now().Add(outboundWriteTimeout)); err != nil {
+			return err
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return err
+		}
+		session.bandwidth.addOutbound(len(data))
+	}
+	return nil
+}
+
+// drainOutbound waits up to timeout for the writer to send the queued events of the
+// session, before the connection is closed on the server's initiative
+func (sm *SessionManager) drainOutbound(session *Session, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		session.mutex.RLock()
+		drained := session.outbound == nil || session.Conn == nil ||
+			(len(session.outbound.events) == 0 && !session.outbound.writing)
+		session.mutex.RUnlock()
+		if drained {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
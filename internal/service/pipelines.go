@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/textformat"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pipelineNames returns the configured pipeline names in order
+func pipelineNames(cfg *config.Config) []string {
+	if cfg == nil {
+		return nil
+	}
+	names := make([]string, 0, len(cfg.Pipelines))
+	for name := range cfg.Pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupPipeline returns the named pipeline, reporting an error for unknown names
+func lookupPipeline(cfg *config.Config, name string) (config.Pipeline, error) {
+	if cfg != nil {
+		if pipeline, ok := cfg.Pipelines[name]; ok {
+			return pipeline, nil
+		}
+	}
+	return config.Pipeline{}, fmt.Errorf("unknown pipeline: %s", name)
+}
+
+// applyPipeline switches the session to a pipeline: the VAD detector and denoiser are
+// recreated with the pipeline's settings, ASR calls go to its provider and its
+// post-processing becomes the session's transcript format. It is called with the
+// session manager lock held, from the connection's read loop, so no audio is being
+// processed by the replaced detectors.
+func applyPipeline(sess *Session, cfg *config.Config, name string) error {
+	pipeline, err := lookupPipeline(cfg, name)
+	if err != nil {
+		return err
+	}
+	derived := cfg.WithPipeline(pipeline)
+
+	if derived.Vad.Enable && sess.TurnDetection.Type != TurnDetectionNone {
+		sess.acquireVADDetector(sess.turnDetectionConfig(derived))
+		sess.IsSpeaking = false
+		sess.vadResetOffset = sess.vadSamplesFed.Load()
+	}
+
+	sess.denoiserPool = nil
+	if derived.Denoiser.Enable {
+		sess.denoiserPool = modelPools.denoiserPool(derived)
+	}
+	applyNoiseReduction(sess, derived)
+
+	sess.ASREndpoint = &llm.Endpoint{
+		BaseURL: pipeline.ASR.BaseURL,
+		APIKey:  pipeline.ASR.APIKey,
+		Model:   pipeline.ASR.Model,
+	}
+
+	post := pipeline.PostProcessing
+	sess.TranscriptFormat = textformat.Options{
+		Profile:            post.Profile,
+		MaxLineLength:      post.MaxLineLength,
+		MaxLines:           post.MaxLines,
+		ParagraphSentences: post.ParagraphSentences,
+	}
+	sess.Captions.Enabled = post.Captions
+	sess.Pipeline = name
+
+	sess.Logger().WithFields(logrus.Fields{
+		"component": "mg_session_ctrl",
+		"action":    "pipeline_applied",
+		"sessionID": sess.ID,
+		"pipeline":  name,
+		"vad":       derived.Vad.Enable,
+		"denoiser":  derived.Denoiser.Enable,
+		"asrModel":  derived.ASR.Model,
+	}).Info("Session pipeline applied")
+	return nil
+}
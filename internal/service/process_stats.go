@@ -0,0 +1,108 @@
+package service
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// processStart is when the server process started, near enough
+var processStart = time.Now()
+
+// clockTicks is the unit of the CPU times of /proc/self/stat, USER_HZ, which is 100 on
+// every Linux architecture Go supports
+const clockTicks = 100
+
+// ProcessStats reports the resource usage of the server process, for load tests such
+// as stt bench to sample while they run
+type ProcessStats struct {
+	Timestamp     time.Time `json:"timestamp"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	// User and system CPU time since the process started, from /proc/self/stat; 0
+	// where it is not available
+	CPUSeconds float64 `json:"cpu_seconds"`
+	NumCPU     int     `json:"num_cpu"`
+	// Resident memory from /proc/self/statm, 0 where it is not available
+	RSSBytes       uint64 `json:"rss_bytes"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	SysBytes       uint64 `json:"sys_bytes"` // memory obtained from the OS by the Go runtime
+	GCCycles       uint32 `json:"gc_cycles"`
+	Goroutines     int    `json:"goroutines"`
+	Sessions       int    `json:"sessions"`
+}
+
+// ProcessStats samples the resource usage of the process
+func (s *OpenAIService) ProcessStats() ProcessStats {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	now := time.Now()
+	return ProcessStats{
+		Timestamp:      now,
+		UptimeSeconds:  now.Sub(processStart).Seconds(),
+		CPUSeconds:     processCPUSeconds(),
+		NumCPU:         runtime.NumCPU(),
+		RSSBytes:       processRSSBytes(),
+		HeapAllocBytes: memory.HeapAlloc,
+		SysBytes:       memory.Sys,
+		GCCycles:       memory.NumGC,
+		Goroutines:     runtime.NumGoroutine(),
+		Sessions:       s.sessionManager.GetActiveSessionCount(),
+	}
+}
+
+// processCPUSeconds returns the utime and stime fields of /proc/self/stat
+func processCPUSeconds() float64 {
+	data, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0
+	}
+	// The command name in parentheses may contain spaces, the fields follow it
+	end := strings.LastIndexByte(string(data), ')')
+	if end < 0 {
+		return 0
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	// fields[0] is the state, the third field of the file; utime and stime are the
+	// 14th and 15th
+	if len(fields) < 13 {
+		return 0
+	}
+	utime, err1 := strconv.ParseUint(fields[11], 10, 64)
+	stime, err2 := strconv.ParseUint(fields[12], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return float64(utime+stime) / clockTicks
+}
+
+// processRSSBytes returns the resident set of /proc/self/statm
+func processRSSBytes() uint64 {
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return pages * uint64(os.Getpagesize())
+}
+
+// handleProcessStats returns the resource usage of the server process
+func handleProcessStats(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, openAIService.ProcessStats())
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/errcode"
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// Rate limits enforced by a RateLimiter
+const (
+	RateLimitSessionsPerKey        = "sessions_per_key"
+	RateLimitSessionsPerIP         = "sessions_per_ip"
+	RateLimitAudioSecondsPerMinute = "audio_seconds_per_minute"
+	RateLimitEventsPerSecond       = "events_per_second"
+)
+
+const (
+	// rateLimitNoticeInterval is the minimum time between two rate_limit_exceeded errors
+	// of a limit, a client over its event rate is not answered with as many events
+	rateLimitNoticeInterval = time.Second
+	// rateLimitPruneInterval is how often clients without sessions are forgotten, once
+	// their audio budget has refilled
+	rateLimitPruneInterval = time.Minute
+)
+
+// rateClient is the rate limit state of an API key or IP address
+type rateClient struct {
+	sessions int
+	audio    *ratelimit.Bucket // nil without an audio limit
+}
+
+// RateLimiter enforces the per-client limits of the rate_limits configuration: open
+// sessions per API key and per IP address, input audio per minute across a client's
+// sessions and client events per second of a session. It is safe for concurrent use.
+type RateLimiter struct {
+	maxSessionsPerKey int
+	maxSessionsPerIP  int
+	audioPerMinute    float64
+	eventsPerSecond   float64
+	eventsBurst       float64
+
+	mutex     sync.Mutex
+	clients   map[string]*rateClient
+	lastPrune time.Time
+}
+
+// NewRateLimiter creates a rate limiter from the rate_limits section of the configuration
+func NewRateLimiter(cfg *config.Config) *RateLimiter {
+	limits := cfg.RateLimits
+	burst := float64(limits.EventsBurst)
+	if burst <= 0 {
+		burst = 2 * limits.EventsPerSecond
+	}
+	return &RateLimiter{
+		maxSessionsPerKey: limits.MaxSessionsPerKey,
+		maxSessionsPerIP:  limits.MaxSessionsPerIP,
+		audioPerMinute:    limits.AudioSecondsPerMinute,
+		eventsPerSecond:   limits.EventsPerSecond,
+		eventsBurst:       max(1, burst),
+		clients:           make(map[string]*rateClient),
+		lastPrune:         time.Now(),
+	}
+}
+
+// rateLimitClient returns the rate limit keys of an upgrade request: its authenticated
+// principal or bearer token, empty without one, and its IP address
+func rateLimitClient(c *gin.Context, principal *Principal) (key string, ip string) {
+	if principal != nil {
+		key = principal.QuotaKey
+	} else if token := requestToken(c.Request); token != "" {
+		key = "token:" + token
+	}
+	return key, "ip:" + c.ClientIP()
+}
+
+// client returns the state of a client, the caller holds the mutex
+func (l *RateLimiter) client(name string) *rateClient {
+	client, ok := l.clients[name]
+	if !ok {
+		client = &rateClient{}
+		if l.audioPerMinute > 0 {
+			client.audio = ratelimit.NewBucket(l.audioPerMinute/60, l.audioPerMinute)
+		}
+		l.clients[name] = client
+	}
+	return client
+}
+
+// prune forgets clients without sessions whose state is back to its initial value,
+// the caller holds the mutex
+func (l *RateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < rateLimitPruneInterval {
+		return
+	}
+	l.lastPrune = now
+	for name, client := range l.clients {
+		if client.sessions == 0 && (client.audio == nil || client.audio.Full(now)) {
+			delete(l.clients, name)
+		}
+	}
+}
+
+// openSession counts a new session of the client, or returns the limit refusing it
+func (l *RateLimiter) openSession(key, ip string) *RateLimitDetails {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.prune(time.Now())
+
+	ipClient := l.client(ip)
+	if l.maxSessionsPerIP > 0 && ipClient.sessions >= l.maxSessionsPerIP {
+		return &RateLimitDetails{Name: RateLimitSessionsPerIP, Limit: float64(l.maxSessionsPerIP)}
+	}
+	if key != "" {
+		keyClient := l.client(key)
+		if l.maxSessionsPerKey > 0 && keyClient.sessions >= l.maxSessionsPerKey {
+			return &RateLimitDetails{Name: RateLimitSessionsPerKey, Limit: float64(l.maxSessionsPerKey)}
+		}
+		keyClient.sessions++
+	}
+	ipClient.sessions++
+	return nil
+}
+
+// closeSession ends a session counted by openSession
+func (l *RateLimiter) closeSession(key, ip string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, name := range []string{key, ip} {
+		if client, ok := l.clients[name]; ok && client.sessions > 0 {
+			client.sessions--
+		}
+	}
+}
+
+// takeAudio charges audio to the client's per-minute budget, or returns the limit
+// refusing it
+func (l *RateLimiter) takeAudio(name string, audio time.Duration) *RateLimitDetails {
+	if l.audioPerMinute <= 0 {
+		return nil
+	}
+
+	l.mutex.Lock()
+	bucket := l.client(name).audio
+	l.mutex.Unlock()
+
+	ok, retryAfter := bucket.Take(audio.Seconds(), time.Now())
+	if ok {
+		return nil
+	}
+	return &RateLimitDetails{
+		Name:         RateLimitAudioSecondsPerMinute,
+		Limit:        l.audioPerMinute,
+		RetryAfterMs: retryAfter.Milliseconds(),
+	}
+}
+
+// newEventBucket returns the event budget of a new session, nil when unlimited
+func (l *RateLimiter) newEventBucket() *ratelimit.Bucket {
+	if l.eventsPerSecond <= 0 {
+		return nil
+	}
+	return ratelimit.NewBucket(l.eventsPerSecond, l.eventsBurst)
+}
+
+// SetRateLimiter replaces the per-client rate limiter used for new sessions, nil
+// disables rate limiting
+func (s *OpenAIService) SetRateLimiter(limiter *RateLimiter) {
+	s.rateLimiter = limiter
+}
+
+// allowClientEvent charges a client event to the session's event budget. An event
+// over the budget is dropped with a rate_limit_exceeded error.
+func (s *OpenAIService) allowClientEvent(session *Session) bool {
+	if session.eventBucket == nil {
+		return true
+	}
+
+	ok, retryAfter := session.eventBucket.Take(1, time.Now())
+	if !ok {
+		s.sendRateLimitError(session, RateLimitDetails{
+			Name:         RateLimitEventsPerSecond,
+			Limit:        s.rateLimiter.eventsPerSecond,
+			RetryAfterMs: retryAfter.Milliseconds(),
+		})
+	}
+	return ok
+}
+
+// allowClientAudio charges input audio to the client's budget. Audio over the budget
+// is dropped with a rate_limit_exceeded error.
+func (s *OpenAIService) allowClientAudio(session *Session, audio time.Duration) bool {
+	if s.rateLimiter == nil || session.rateClient == "" {
+		return true
+	}
+
+	details := s.rateLimiter.takeAudio(session.rateClient, audio)
+	if details != nil {
+		s.sendRateLimitError(session, *details)
+	}
+	return details == nil
+}
+
+func newRateLimitErrorEvent(eventID, sessionID string, details RateLimitDetails) *RateLimitErrorEvent {
+	event := &RateLimitErrorEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeError,
+			EventID:   eventID,
+			SessionID: sessionID,
+		},
+	}
+	event.Error.Type = "invalid_request_error"
+	event.Error.Code = "rate_limit_exceeded"
+	event.Error.Category = errcode.RateLimited
+	event.Error.Message = fmt.Sprintf("rate limit %s of %g exceeded", details.Name, details.Limit)
+	event.Error.RateLimit = details
+	return event
+}
+
+// sendRateLimitError tells the client that a limit dropped its event or audio, at most
+// once per rateLimitNoticeInterval for each limit
+func (s *OpenAIService) sendRateLimitError(session *Session, details RateLimitDetails) {
+	now := time.Now()
+	session.limitMutex.Lock()
+	if now.Sub(session.rateLimitNotices[details.Name]) < rateLimitNoticeInterval {
+		session.limitMutex.Unlock()
+		return
+	}
+	if session.rateLimitNotices == nil {
+		session.rateLimitNotices = make(map[string]time.Time)
+	}
+	session.rateLimitNotices[details.Name] = now
+	session.limitMutex.Unlock()
+
+	session.Logger().WithFields(logrus.Fields{
+		"component":    "svc_openai_api ",
+		"action":       "rate_limit_exceeded",
+		"sessionID":    session.ID,
+		"limit":        details.Name,
+		"max":          details.Limit,
+		"retryAfterMs": details.RetryAfterMs,
+	}).Warn("Client exceeded rate limit, dropping input")
+
+	errorEvent := newRateLimitErrorEvent(session.NewEventID(), session.ID, details)
+	if err := s.sessionManager.SendEvent(session, errorEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "ws_event_send ",
+			"action":    "send_rate_limit_error_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Failed to send rate_limit_exceeded error event")
+	}
+}
+
+// rejectRateLimited refuses a connection over a session rate limit
+func rejectRateLimited(conn *websocket.Conn, key, ip string, details RateLimitDetails) {
+	logger.WithFields(logrus.Fields{
+		"component": "svc_openai_api ",
+		"action":    "session_rate_limited",
+		"clientIP":  ip,
+		"limit":     details.Name,
+		"max":       details.Limit,
+		"keyed":     key != "",
+	}).Warn("Rejected session over a rate limit")
+
+	rejectConnection(conn, newRateLimitErrorEvent(GenerateEventID(), "", details), "rate_limit_exceeded")
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-restream/stt/pkg/wav"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audio saved with audio.enable, and always for debug sessions, is kept per session
+// under a key prefix of the audio store, a directory below audio.save_dir by default:
+// WAV segments of up to audio.buffer_size seconds named after their offset in the
+// saved audio, and recording.json describing them. The objects outlive the session, so
+// recordings are listed and downloaded from the store.
+
+const (
+	recordingMetadataFile = "recording.json"
+	wavHeaderSize         = 44 // of the WAV files pkg/wav writes
+)
+
+// RecordingSegment is a WAV file of a session recording
+type RecordingSegment struct {
+	File       string    `json:"file"`
+	URL        string    `json:"url,omitempty"`
+	StartMs    int64     `json:"start_ms"` // offset in the saved audio of the session
+	DurationMs int64     `json:"duration_ms"`
+	SampleRate int       `json:"sample_rate"`
+	Channels   int       `json:"channels"`
+	Bytes      int64     `json:"bytes"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Recording describes the saved audio of a session, as listed by
+// GET /v1/admin/sessions/{id}/audio
+type Recording struct {
+	SessionID  string             `json:"session_id"`
+	SampleRate int                `json:"sample_rate"` // of the first segment
+	Channels   int                `json:"channels"`
+	DurationMs int64              `json:"duration_ms"` // of the segments still stored
+	Bytes      int64              `json:"bytes"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+	Segments   []RecordingSegment `json:"segments"`
+}
+
+// recordingsDir returns the directory holding the session recordings of the local store
+func (s *OpenAIService) recordingsDir() string {
+	if dir := s.appConfig().Audio.SaveDir; dir != "" {
+		return dir
+	}
+	return "audio"
+}
+
+// validRecordingID refuses session IDs that cannot name a key prefix
+func validRecordingID(sessionID string) error {
+	if sessionID == "" || sessionID == "." || sessionID == ".." || strings.ContainsAny(sessionID, `/\{}`) {
+		return fmt.Errorf("invalid session ID %q", sessionID)
+	}
+	return nil
+}
+
+// saveRecordingSegment writes mono samples at sampleRate as the next segment of the
+// session's recording and updates the recording's metadata. The caller holds the
+// session's AudioSaveMutex.
+func (s *OpenAIService) saveRecordingSegment(session *Session, samples []int16, sampleRate int, createdAt time.Time) (string, error) {
+	const channels = 1 // saved audio is mixed down
+	if err := validRecordingID(session.ID); err != nil {
+		return "", err
+	}
+	ctx := context.Background()
+	store := s.audioStore()
+	prefix := renderKeyTemplate(s.audioKeyTemplate(), session.ID, session.CreatedAt)
+
+	recording, err := readRecording(ctx, store, prefix)
+	if err != nil {
+		recording = &Recording{SessionID: session.ID, SampleRate: sampleRate, Channels: channels, CreatedAt: createdAt}
+	}
+	var startMs int64
+	if n := len(recording.Segments); n > 0 {
+		last := recording.Segments[n-1]
+		startMs = last.StartMs + last.DurationMs
+	}
+
+	segment := RecordingSegment{
+		File:       fmt.Sprintf("segment_%09d.wav", startMs),
+		StartMs:    startMs,
+		DurationMs: int64(len(samples)) * 1000 / int64(sampleRate*channels),
+		SampleRate: sampleRate,
+		Channels:   channels,
+		Bytes:      wavHeaderSize + int64(len(samples))*2,
+		CreatedAt:  createdAt,
+	}
+	data, err := s.audioUtils.ConvertPCM16ToWAV(samples, sampleRate)
+	if err != nil {
+		return "", err
+	}
+	if err := store.Put(ctx, prefix+segment.File, data, "audio/wav"); err != nil {
+		return "", fmt.Errorf("failed to store audio segment: %v", err)
+	}
+
+	recording.Segments = append(recording.Segments, segment)
+	recording.DurationMs += segment.DurationMs
+	recording.Bytes += segment.Bytes
+	recording.UpdatedAt = createdAt
+	if err := writeRecording(ctx, store, prefix, recording); err != nil {
+		return "", err
+	}
+	s.enforceRecordingQuota(ctx, session, store, prefix)
+	return prefix + segment.File, nil
+}
+
+// readRecording reads the metadata of the recording at prefix
+func readRecording(ctx context.Context, store ObjectStore, prefix string) (*Recording, error) {
+	data, err := readObject(ctx, store, prefix+recordingMetadataFile)
+	if err != nil {
+		return nil, err
+	}
+	var recording Recording
+	if err := json.Unmarshal(data, &recording); err != nil {
+		return nil, err
+	}
+	return &recording, nil
+}
+
+// writeRecording replaces the metadata of the recording at prefix
+func writeRecording(ctx context.Context, store ObjectStore, prefix string, recording *Recording) error {
+	data, err := json.MarshalIndent(recording, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := store.Put(ctx, prefix+recordingMetadataFile, data, "application/json"); err != nil {
+		return fmt.Errorf("failed to write recording metadata: %v", err)
+	}
+	return nil
+}
+
+// recordingPrefix finds the key prefix of a session's recording. Prefixes with the
+// {date} of an ended session are found by listing the keys before {date}.
+func (s *OpenAIService) recordingPrefix(ctx context.Context, store ObjectStore, sessionID string) (string, error) {
+	template := s.audioKeyTemplate()
+	if session, live := s.sessionManager.GetSession(sessionID); live {
+		return renderKeyTemplate(template, sessionID, session.CreatedAt), nil
+	}
+	dateAt := strings.Index(template, "{date}")
+	if dateAt < 0 {
+		return renderKeyTemplate(template, sessionID, time.Time{}), nil
+	}
+
+	objects, err := store.List(ctx, renderKeyTemplate(template[:dateAt], sessionID, time.Time{}))
+	if err != nil {
+		return "", err
+	}
+	pattern := keyTemplatePattern(template, sessionID)
+	for _, object := range objects {
+		prefix, found := strings.CutSuffix(object.Key, recordingMetadataFile)
+		if found && pattern.MatchString(prefix) {
+			return prefix, nil
+		}
+	}
+	return "", errObjectNotFound
+}
+
+// loadRecording returns the recording of a session with the segments still stored, and
+// its key prefix
+func (s *OpenAIService) loadRecording(ctx context.Context, sessionID string) (*Recording, string, error) {
+	if err := validRecordingID(sessionID); err != nil {
+		return nil, "", err
+	}
+	store := s.audioStore()
+	notFound := fmt.Errorf("no saved audio for session %s", sessionID)
+	prefix, err := s.recordingPrefix(ctx, store, sessionID)
+	if err != nil {
+		return nil, "", notFound
+	}
+	recording, err := readRecording(ctx, store, prefix)
+	if err != nil {
+		return nil, "", notFound
+	}
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	sizes := make(map[string]int64, len(objects))
+	for _, object := range objects {
+		sizes[object.Key] = object.Size
+	}
+
+	// Segments removed by the retention policy are left out
+	segments := recording.Segments[:0]
+	recording.DurationMs, recording.Bytes = 0, 0
+	for _, segment := range recording.Segments {
+		size, stored := sizes[prefix+segment.File]
+		if !stored {
+			continue
+		}
+		segment.Bytes = size
+		segments = append(segments, segment)
+		recording.DurationMs += segment.DurationMs
+		recording.Bytes += segment.Bytes
+	}
+	recording.Segments = segments
+	return recording, prefix, nil
+}
+
+// multiReaderAt reads the concatenation of sections
+type multiReaderAt []*io.SectionReader
+
+func (m multiReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for _, section := range m {
+		if off >= section.Size() {
+			off -= section.Size()
+			continue
+		}
+		n, err := section.ReadAt(p[read:], off)
+		read += n
+		if err != nil && err != io.EOF {
+			return read, err
+		}
+		if read == len(p) {
+			return read, nil
+		}
+		off = 0
+	}
+	return read, io.EOF
+}
+
+// handleSessionAudio lists the saved audio of a session, with the download URL of each
+// segment
+func handleSessionAudio(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	recording, _, err := openAIService.loadRecording(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range recording.Segments {
+		recording.Segments[i].URL = c.Request.URL.Path + "/" + recording.Segments[i].File
+	}
+	c.JSON(http.StatusOK, recording)
+}
+
+// handleSessionAudioSegment downloads a segment of the saved audio of a session, with
+// support for range requests
+func handleSessionAudioSegment(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	recording, prefix, err := openAIService.loadRecording(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	for _, segment := range recording.Segments {
+		if segment.File != c.Param("file") {
+			continue
+		}
+		object, err := openAIService.audioStore().Open(c.Request.Context(), prefix+segment.File)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "audio segment not found"})
+			return
+		}
+		defer object.Close()
+		c.Header("Content-Type", "audio/wav")
+		http.ServeContent(c.Writer, c.Request, segment.File, segment.CreatedAt, io.NewSectionReader(object, 0, object.Info().Size))
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "audio segment not found"})
+}
+
+// handleSessionAudioWAV downloads the saved audio of a session as one WAV file, the
+// segments joined in order, with support for range requests
+func handleSessionAudioWAV(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	recording, prefix, err := openAIService.loadRecording(c.Request.Context(), c.Param("id"))
+	if err != nil || len(recording.Segments) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no saved audio for session %s", c.Param("id"))})
+		return
+	}
+
+	var sections multiReaderAt
+	var dataSize int64
+	for _, segment := range recording.Segments {
+		if segment.SampleRate != recording.SampleRate || segment.Channels != recording.Channels {
+			c.JSON(http.StatusConflict, gin.H{"error": "the input format changed during the session, download the segments instead"})
+			return
+		}
+		object, err := openAIService.audioStore().Open(c.Request.Context(), prefix+segment.File)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "audio segment not found"})
+			return
+		}
+		defer object.Close()
+		size := max(segment.Bytes-wavHeaderSize, 0)
+		sections = append(sections, io.NewSectionReader(object, wavHeaderSize, size))
+		dataSize += size
+	}
+
+	var header bytes.Buffer
+	wavHeader := wav.NewWAVHeader(wav.WAVFormat{
+		AudioFormat:   1,
+		NumChannels:   uint16(recording.Channels),
+		SampleRate:    safeUint32Audio(recording.SampleRate),
+		ByteRate:      safeUint32Audio(recording.SampleRate * recording.Channels * 2),
+		BlockAlign:    uint16(recording.Channels * 2),
+		BitsPerSample: 16,
+	}, safeUint32Audio(int(dataSize)))
+	if err := wavHeader.Write(&header); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sections = append(multiReaderAt{io.NewSectionReader(bytes.NewReader(header.Bytes()), 0, int64(header.Len()))}, sections...)
+
+	content := io.NewSectionReader(sections, 0, int64(header.Len())+dataSize)
+	c.Header("Content-Type", "audio/wav")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.wav"`, recording.SessionID))
+	http.ServeContent(c.Writer, c.Request, recording.SessionID+".wav", recording.UpdatedAt, content)
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Route groups of the HTTP API, each with its own middleware chain
+const (
+	RouteGroupRealtime = "realtime" // /v1/realtime and OpenAI-compatible client APIs
+	RouteGroupAudio    = "audio"    // /v1/audio REST surfaces
+	RouteGroupAdmin    = "admin"    // /v1/admin session management
+	RouteGroupHealth   = "health"   // /v1/health and /v1/capabilities, kept unauthenticated
+)
+
+// routeGroupKey is the gin context key holding the name of the matched route group
+const routeGroupKey = "route_group"
+
+var (
+	groupMiddleware      = make(map[string][]gin.HandlerFunc)
+	groupMiddlewareMutex sync.Mutex
+)
+
+// UseGroupMiddleware adds middleware (auth, rate limits, metrics) to a route group.
+// It must be called before WsServiceRun registers the routes.
+func UseGroupMiddleware(group string, handlers ...gin.HandlerFunc) {
+	groupMiddlewareMutex.Lock()
+	defer groupMiddlewareMutex.Unlock()
+	groupMiddleware[group] = append(groupMiddleware[group], handlers...)
+}
+
+// newRouteGroup creates a gin group tagged with its name and carrying its middleware
+func newRouteGroup(parent *gin.RouterGroup, relativePath string, name string) *gin.RouterGroup {
+	groupMiddlewareMutex.Lock()
+	handlers := append([]gin.HandlerFunc{tagRouteGroup(name)}, groupMiddleware[name]...)
+	groupMiddlewareMutex.Unlock()
+
+	return parent.Group(relativePath, handlers...)
+}
+
+func tagRouteGroup(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(routeGroupKey, name)
+		c.Next()
+	}
+}
+
+// registerRoutes registers all HTTP and WebSocket routes by versioned group
+func registerRoutes(r *gin.Engine) {
+	r.Static("/static", "./static")
+	r.GET("/", func(c *gin.Context) {
+		c.File("./static/index.html")
+	})
+	r.GET("/favicon.ico", func(c *gin.Context) {
+		c.File("./static/favicon.ico")
+	})
+
+	v1 := r.Group("/v1")
+
+	realtime := newRouteGroup(v1, "", RouteGroupRealtime)
+	realtime.GET("/realtime", func(c *gin.Context) {
+		openAIService.HandleOpenAIWebSocket(c)
+	})
+	// Twilio Media Streams of phone calls, see twilio.go
+	realtime.GET("/twilio", func(c *gin.Context) {
+		openAIService.HandleTwilioWebSocket(c)
+	})
+	realtime.POST("/chat/completions", requireScope(ScopeTranscribe), handleChatCompletion)
+
+	// Long-poll fallback of the realtime WebSocket, see long_poll.go
+	realtime.POST("/realtime/sessions", handlePollSessionCreate)
+	realtime.POST("/realtime/:session/audio", handlePollAudio)
+	realtime.POST("/realtime/:session/events", handlePollClientEvent)
+	realtime.GET("/realtime/:session/events", handlePollEvents)
+	realtime.DELETE("/realtime/:session", handlePollSessionDelete)
+
+	// REST audio surfaces are registered here as they are added
+	audio := newRouteGroup(v1, "/audio", RouteGroupAudio)
+	audio.POST("/transcriptions", requireScope(ScopeTranscribe), handleAudioTranscription)
+	audio.GET("/sessions/:id/captions.vtt", requireScope(ScopeExport), handleSessionWebVTT)
+	audio.GET("/sessions/:id/captions.srt", requireScope(ScopeExport), handleSessionSRT)
+
+	// Reports need the observe scope, changes the admin scope
+	admin := newRouteGroup(v1, "/admin", RouteGroupAdmin)
+	registerSessionRoutes(admin.Group("/sessions"))
+	admin.GET("/logging", requireScope(ScopeObserve), handleGetLogLevels)
+	admin.PUT("/logging", requireScope(ScopeAdmin), handleSetLogLevels)
+	admin.POST("/config/reload", requireScope(ScopeAdmin), handleConfigReload)
+	admin.GET("/experiments", requireScope(ScopeObserve), handleExperimentReport)
+	admin.GET("/asr/backends", requireScope(ScopeObserve), handleASRBackends)
+	admin.GET("/asr/concurrency", requireScope(ScopeObserve), handleASRConcurrency)
+	admin.GET("/ingest", requireScope(ScopeObserve), handleIngestStatus)
+	admin.GET("/audio/retention", requireScope(ScopeObserve), handleAudioRetentionStats)
+	admin.POST("/audio/retention", requireScope(ScopeAdmin), handleAudioRetention)
+	admin.GET("/process", requireScope(ScopeObserve), handleProcessStats)
+
+	// Deprecated: session routes predating /v1/admin, kept for existing clients
+	registerSessionRoutes(newRouteGroup(v1, "/sessions", RouteGroupAdmin))
+
+	health := newRouteGroup(v1, "", RouteGroupHealth)
+	health.GET("/health", handleHealth)
+	health.GET("/capabilities", handleCapabilities)
+}
+
+func registerSessionRoutes(sessions *gin.RouterGroup) {
+	observe, admin, export := requireScope(ScopeObserve), requireScope(ScopeAdmin), requireScope(ScopeExport)
+	sessions.GET("", observe, handleListSessions)
+	sessions.GET("/stats", observe, handleSessionStats)
+	sessions.GET("/gc", observe, handleSessionGCStats)
+	sessions.POST("/gc", admin, handleSessionGC)
+	sessions.GET("/live", observe, handleLiveSessions)
+	sessions.POST("/:id/close", admin, handleCloseSession)
+	sessions.POST("/:id/vad", admin, handleSessionVAD)
+	sessions.POST("/:id/debug", admin, handleSessionDebug)
+	sessions.GET("/:id/journal", observe, handleSessionJournal)
+	sessions.GET("/:id/analytics", observe, handleSessionAnalytics)
+	sessions.GET("/:id/transcript", export, handleTranscriptExport)
+	sessions.GET("/:id/items", export, handleSessionItems)
+	sessions.GET("/:id/captions.m3u8", export, handleCaptionPlaylist)
+	sessions.GET("/:id/captions/:segment", export, handleCaptionSegment)
+	sessions.GET("/:id/audio", export, handleSessionAudio)
+	sessions.GET("/:id/audio.wav", export, handleSessionAudioWAV)
+	sessions.GET("/:id/audio/:file", export, handleSessionAudioSegment)
+}
+
+// handleHealth reports service liveness and, in cluster mode, the instance role
+func handleHealth(c *gin.Context) {
+	health := gin.H{
+		"status":    "ok",
+		"timestamp": time.Now().Unix(),
+		"service":   "streamASR",
+	}
+
+	if openAIService != nil {
+		health["openai_service"] = "available"
+	} else {
+		health["openai_service"] = "unavailable"
+	}
+
+	if clusterElector != nil {
+		health["role"] = clusterElector.Role()
+	}
+
+	c.JSON(http.StatusOK, health)
+}
+
+// handleSessionStats returns aggregate statistics of live sessions
+func handleSessionStats(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, openAIService.GetSessionStats())
+}
+
+// handleGetLogLevels returns the base and per-component log levels
+func handleGetLogLevels(c *gin.Context) {
+	level, components := logger.ComponentLevels()
+	c.JSON(http.StatusOK, gin.H{"level": level, "components": components})
+}
+
+// handleSetLogLevels replaces the base and per-component log levels at runtime
+func handleSetLogLevels(c *gin.Context) {
+	var req struct {
+		Level      string            `json:"level"`
+		Components map[string]string `json:"components"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := logger.SetComponentLevels(req.Level, req.Components); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"component":  "mont_srv_status",
+		"action":     "log_levels_changed",
+		"level":      req.Level,
+		"components": req.Components,
+	}).Info("Log levels changed via admin API")
+
+	level, components := logger.ComponentLevels()
+	c.JSON(http.StatusOK, gin.H{"level": level, "components": components})
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// The admin API acts on live sessions: it lists them with their VAD and buffer state,
+// closes them and changes their VAD settings while they run.
+
+// Transports a live session is served by
+const (
+	TransportWebSocket = "websocket"
+	TransportLongPoll  = "long_poll"
+	TransportParked    = "parked" // the connection dropped, the session waits for a resume
+	TransportServer    = "server" // ingest, SIP and Twilio sessions, fed by the server
+)
+
+// errNotClosable is returned for sessions no client connection serves
+var errNotClosable = errors.New("session is not served by a client connection")
+
+// LiveSession is a live session as listed by GET /v1/admin/sessions/live
+type LiveSession struct {
+	ID                 string            `json:"id"`
+	Tenant             string            `json:"tenant,omitempty"`
+	Pipeline           string            `json:"pipeline,omitempty"`
+	Transport          string            `json:"transport"`
+	CreatedAt          time.Time         `json:"created_at"`
+	LastActive         time.Time         `json:"last_active"`
+	LastHeartbeat      time.Time         `json:"last_heartbeat"`
+	Speaking           bool              `json:"speaking"`
+	Debug              bool              `json:"debug"`
+	AudioBufferSamples int               `json:"audio_buffer_samples"`
+	VADBufferSamples   int               `json:"vad_buffer_samples"`
+	TurnDetection      LiveTurnDetection `json:"turn_detection"`
+	VADUpdatePending   bool              `json:"vad_update_pending,omitempty"`
+}
+
+// LiveTurnDetection is the turn detection a session runs: the VAD settings of its
+// detector, or only the type when it has none
+type LiveTurnDetection struct {
+	Type              string  `json:"type"`
+	Threshold         float32 `json:"threshold,omitempty"`
+	SilenceDurationMs int     `json:"silence_duration_ms,omitempty"`
+	PrefixPaddingMs   int     `json:"prefix_padding_ms,omitempty"`
+}
+
+// VADAdjustment is a change of the VAD settings of a session, zero fields keep the
+// current value
+type VADAdjustment struct {
+	Threshold         float32 `json:"threshold"`
+	SilenceDurationMs int     `json:"silence_duration_ms"`
+	PrefixPaddingMs   int     `json:"prefix_padding_ms"`
+}
+
+func (a VADAdjustment) validate() error {
+	if a.Threshold < 0 || a.Threshold >= 1 || a.SilenceDurationMs < 0 || a.PrefixPaddingMs < 0 {
+		return fmt.Errorf("threshold must be between 0 and 1, durations must not be negative")
+	}
+	if a.Threshold == 0 && a.SilenceDurationMs == 0 && a.PrefixPaddingMs == 0 {
+		return fmt.Errorf("one of threshold, silence_duration_ms and prefix_padding_ms is required")
+	}
+	return nil
+}
+
+// LiveSessions returns the live sessions of tenant, of every tenant when empty, oldest
+// first
+func (sm *SessionManager) LiveSessions(tenant string) []LiveSession {
+	var sessions []*Session
+	var live []LiveSession
+	sm.mutex.RLock()
+	for _, session := range sm.sessions {
+		if tenant != "" && session.Tenant != tenant {
+			continue
+		}
+		turnDetection := LiveTurnDetection{Type: session.TurnDetection.Type}
+		if session.vadConfig != nil {
+			turnDetection.Threshold = session.vadConfig.Vad.Threshold
+			turnDetection.SilenceDurationMs = int(session.vadConfig.Vad.MinSilenceDuration * 1000)
+			turnDetection.PrefixPaddingMs = session.vadConfig.Vad.PrefixPaddingMs
+		}
+		sessions = append(sessions, session)
+		live = append(live, LiveSession{
+			ID:               session.ID,
+			Tenant:           session.Tenant,
+			Pipeline:         session.Pipeline,
+			CreatedAt:        session.CreatedAt,
+			LastActive:       session.LastActive,
+			LastHeartbeat:    session.LastHeartbeat,
+			Speaking:         session.IsSpeaking,
+			Debug:            session.IsDebug(),
+			TurnDetection:    turnDetection,
+			VADUpdatePending: session.vadAdjustment.Load() != nil,
+		})
+	}
+	sm.mutex.RUnlock()
+
+	// Transport and buffers are guarded by the session's own mutexes
+	for i, session := range sessions {
+		live[i].Transport = session.servingTransport()
+		session.AudioBufferMutex.RLock()
+		live[i].AudioBufferSamples = len(session.AudioBuffer)
+		session.AudioBufferMutex.RUnlock()
+		session.VADAudioBufferMutex.RLock()
+		live[i].VADBufferSamples = len(session.VADAudioBuffer)
+		session.VADAudioBufferMutex.RUnlock()
+	}
+
+	sort.Slice(live, func(i, j int) bool {
+		if !live[i].CreatedAt.Equal(live[j].CreatedAt) {
+			return live[i].CreatedAt.Before(live[j].CreatedAt)
+		}
+		return live[i].ID < live[j].ID
+	})
+	return live
+}
+
+// servingTransport returns the transport serving the session, one of the Transport*
+// names
+func (s *Session) servingTransport() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	switch {
+	case s.poll != nil:
+		return TransportLongPoll
+	case s.parked:
+		return TransportParked
+	case s.Conn != nil:
+		return TransportWebSocket
+	}
+	return TransportServer
+}
+
+// Close reasons of the WebSocket of an evicted session
+const (
+	closeReasonEvicted = "session_evicted"  // closed via the admin API
+	closeReasonDemoted = "instance_demoted" // the instance lost the cluster leader lock
+)
+
+// evictSession closes a session on behalf of an operator, or of a cluster instance
+// stepping down. Its connection is closed like one over a usage limit, after the
+// events queued for it are written, with closeReason, and the session ends as evicted
+// without waiting for a resume; a parked session ends at once.
+func (s *OpenAIService) evictSession(session *Session, closeReason string) error {
+	session.mutex.Lock()
+	if session.ended {
+		session.mutex.Unlock()
+		return errSessionEnded
+	}
+	if session.poll == nil && session.Conn == nil && !session.parked {
+		session.mutex.Unlock()
+		return errNotClosable
+	}
+	session.evicted = true
+	parked, gen := session.parked, session.transport
+	session.mutex.Unlock()
+
+	session.Logger().WithFields(logrus.Fields{
+		"component": "mg_session_ctrl",
+		"action":    "session_evicted",
+		"sessionID": session.ID,
+		"parked":    parked,
+		"reason":    closeReason,
+	}).Warn("Session evicted")
+
+	if parked {
+		s.detachTransport(session, gen, SessionEndEvicted)
+		return nil
+	}
+
+	// The connection is closed before the session ends, its end events go out first
+	s.sessionManager.sendSessionAnalytics(session)
+	s.sessionManager.drainOutbound(session, time.Second)
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if session.poll != nil {
+		session.poll.close()
+	}
+	if session.Conn != nil {
+		message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, closeReason)
+		session.Conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+		session.Conn.Close()
+	}
+	return nil
+}
+
+// evictAllSessions ends every live session, returning how many it ended. Sessions fed
+// by the server, which evictSession does not close, end right away.
+func (s *OpenAIService) evictAllSessions(closeReason string) int {
+	sessions := s.sessionManager.listSessions()
+	for _, session := range sessions {
+		err := s.evictSession(session, closeReason)
+		if !errors.Is(err, errNotClosable) {
+			continue
+		}
+		session.mutex.Lock()
+		ended := session.ended
+		session.ended = true
+		session.mutex.Unlock()
+		if !ended {
+			s.endSession(session, SessionEndEvicted)
+		}
+	}
+	return len(sessions)
+}
+
+// applyVADAdjustment applies the VAD settings an operator changed since the last
+// audio of the session. It runs from the connection's audio path, the only one
+// feeding the VAD detector, so the detector is idle when it is replaced; speech in
+// progress is dropped as on a session.update.
+func (s *OpenAIService) applyVADAdjustment(session *Session) {
+	adjustment := session.vadAdjustment.Swap(nil)
+	if adjustment == nil {
+		return
+	}
+	cfg := s.appConfig()
+	if cfg == nil {
+		return
+	}
+
+	s.sessionManager.UpdateSession(session.ID, func(sess *Session) {
+		if sess.TurnDetection.Type == TurnDetectionNone {
+			return
+		}
+		if adjustment.Threshold > 0 {
+			sess.TurnDetection.Threshold = adjustment.Threshold
+		}
+		if adjustment.SilenceDurationMs > 0 {
+			sess.TurnDetection.SilenceDurationMs = adjustment.SilenceDurationMs
+		}
+		if adjustment.PrefixPaddingMs > 0 {
+			sess.TurnDetection.PrefixPaddingMs = adjustment.PrefixPaddingMs
+		}
+		applyTurnDetection(sess, cfg)
+		applyChannels(sess, cfg)
+	})
+}
+
+// principalTenant returns the tenant of the authenticated principal, empty without
+func principalTenant(c *gin.Context) string {
+	if value, ok := c.Get(principalKey); ok {
+		if principal, ok := value.(*Principal); ok {
+			return principal.Tenant
+		}
+	}
+	return ""
+}
+
+// tenantSession returns the session of the request's :id when the principal may see it
+func tenantSession(c *gin.Context) (*Session, bool) {
+	session, exists := openAIService.sessionManager.GetSession(c.Param("id"))
+	if !exists {
+		return nil, false
+	}
+	if tenant := principalTenant(c); tenant != "" && session.Tenant != tenant {
+		return nil, false
+	}
+	return session, true
+}
+
+// handleLiveSessions is GET /v1/admin/sessions/live, the live sessions with their VAD
+// and buffer state
+func handleLiveSessions(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	live := openAIService.sessionManager.LiveSessions(principalTenant(c))
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": live})
+}
+
+// handleCloseSession is POST /v1/admin/sessions/:id/close, ending a session and
+// closing its connection
+func handleCloseSession(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	session, exists := tenantSession(c)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("session not found: %s", c.Param("id"))})
+		return
+	}
+	if err := openAIService.evictSession(session, closeReasonEvicted); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"session_id": session.ID, "closing": true})
+}
+
+// handleSessionVAD is POST /v1/admin/sessions/:id/vad, changing the VAD settings of a
+// session. They take effect with the next audio the session receives.
+func handleSessionVAD(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	var req VADAdjustment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, exists := tenantSession(c)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("session not found: %s", c.Param("id"))})
+		return
+	}
+	if !openAIService.usesVAD(session) {
+		c.JSON(http.StatusConflict, gin.H{"error": "session does not use server turn detection"})
+		return
+	}
+
+	// Adjustments not yet applied are merged, the later values win
+	adjustment := req
+	if pending := session.vadAdjustment.Load(); pending != nil {
+		if adjustment.Threshold == 0 {
+			adjustment.Threshold = pending.Threshold
+		}
+		if adjustment.SilenceDurationMs == 0 {
+			adjustment.SilenceDurationMs = pending.SilenceDurationMs
+		}
+		if adjustment.PrefixPaddingMs == 0 {
+			adjustment.PrefixPaddingMs = pending.PrefixPaddingMs
+		}
+	}
+	session.vadAdjustment.Store(&adjustment)
+
+	session.Logger().WithFields(logrus.Fields{
+		"component":         "mg_session_ctrl",
+		"action":            "vad_adjustment_requested",
+		"sessionID":         session.ID,
+		"threshold":         adjustment.Threshold,
+		"silenceDurationMs": adjustment.SilenceDurationMs,
+		"prefixPaddingMs":   adjustment.PrefixPaddingMs,
+	}).Info("Session VAD adjustment requested via admin API")
+
+	c.JSON(http.StatusAccepted, gin.H{"session_id": session.ID, "vad": adjustment, "pending": true})
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSpeaker is the speaker label used when segments carry no speaker or channel information
+const DefaultSpeaker = "speaker_0"
+
+// SpeakerAnalytics holds the talk-time statistics of a single speaker or channel
+type SpeakerAnalytics struct {
+	Speaker       string `json:"speaker"`
+	TalkTimeMs    int64  `json:"talk_time_ms"`
+	Segments      int    `json:"segments"`
+	Interruptions int    `json:"interruptions"` // times this speaker started while another was talking
+	OverlapMs     int64  `json:"overlap_ms"`
+}
+
+// SessionAnalytics summarises talk time, silence, interruptions and overlap of a session
+type SessionAnalytics struct {
+	DurationMs    int64                        `json:"duration_ms"`
+	TalkTimeMs    int64                        `json:"talk_time_ms"` // time at least one speaker was talking
+	SilenceMs     int64                        `json:"silence_ms"`
+	TalkRatio     float64                      `json:"talk_ratio"`
+	OverlapMs     int64                        `json:"overlap_ms"`
+	Interruptions int                          `json:"interruptions"`
+	Speakers      map[string]*SpeakerAnalytics `json:"speakers"`
+	Quality       *QualityStats                `json:"quality,omitempty"` // audio quality of recognized utterances
+	Bandwidth     *BandwidthStats              `json:"bandwidth,omitempty"`
+	Experiment    string                       `json:"experiment,omitempty"`
+	Variant       string                       `json:"variant,omitempty"`
+}
+
+// talkTimeTracker accumulates per-speaker talk time from speech segments. Segments are
+// expected in roughly increasing start order, as produced by the VAD.
+type talkTimeTracker struct {
+	mutex        sync.Mutex
+	speakers     map[string]*SpeakerAnalytics
+	lastSegment  map[string][2]int64 // speaker -> [start, end] of the latest segment
+	coveredUntil int64               // end of the union of all segments so far
+	talkTimeMs   int64
+	overlapMs    int64
+}
+
+func newTalkTimeTracker() *talkTimeTracker {
+	return &talkTimeTracker{
+		speakers:    make(map[string]*SpeakerAnalytics),
+		lastSegment: make(map[string][2]int64),
+	}
+}
+
+// AddSegment records a speech segment of a speaker
+func (t *talkTimeTracker) AddSegment(speaker string, startMs, endMs int64) {
+	if endMs <= startMs {
+		return
+	}
+	if speaker == "" {
+		speaker = DefaultSpeaker
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stats, ok := t.speakers[speaker]
+	if !ok {
+		stats = &SpeakerAnalytics{Speaker: speaker}
+		t.speakers[speaker] = stats
+	}
+	stats.Segments++
+	stats.TalkTimeMs += endMs - startMs
+
+	// Overlap with the latest segment of every other speaker
+	for other, segment := range t.lastSegment {
+		if other == speaker || segment[1] <= startMs {
+			continue
+		}
+		overlap := min(endMs, segment[1]) - max(startMs, segment[0])
+		if overlap <= 0 {
+			continue
+		}
+		stats.OverlapMs += overlap
+		t.speakers[other].OverlapMs += overlap
+		t.overlapMs += overlap
+		if segment[0] < startMs {
+			stats.Interruptions++
+		}
+	}
+	t.lastSegment[speaker] = [2]int64{startMs, endMs}
+
+	// Union of all segments, for the silence ratio
+	if endMs > t.coveredUntil {
+		t.talkTimeMs += endMs - max(startMs, t.coveredUntil)
+		t.coveredUntil = endMs
+	}
+}
+
+// Snapshot returns the analytics of a session with the given total audio duration
+func (t *talkTimeTracker) Snapshot(durationMs int64) SessionAnalytics {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if durationMs < t.coveredUntil {
+		durationMs = t.coveredUntil
+	}
+
+	analytics := SessionAnalytics{
+		DurationMs: durationMs,
+		TalkTimeMs: t.talkTimeMs,
+		SilenceMs:  durationMs - t.talkTimeMs,
+		OverlapMs:  t.overlapMs,
+		Speakers:   make(map[string]*SpeakerAnalytics, len(t.speakers)),
+	}
+	if durationMs > 0 {
+		analytics.TalkRatio = float64(t.talkTimeMs) / float64(durationMs)
+	}
+	for speaker, stats := range t.speakers {
+		copied := *stats
+		analytics.Speakers[speaker] = &copied
+		analytics.Interruptions += stats.Interruptions
+	}
+	return analytics
+}
+
+// Analytics returns the talk-time and audio quality analytics of the session so far
+func (s *Session) Analytics(sampleRate int) SessionAnalytics {
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+	analytics := s.talkTime.Snapshot(s.vadSamplesFed.Load() * 1000 / int64(sampleRate))
+	analytics.Quality = s.quality.Snapshot()
+	bandwidth := s.Bandwidth()
+	analytics.Bandwidth = &bandwidth
+	analytics.Experiment = s.Experiment
+	analytics.Variant = s.Variant
+	return analytics
+}
+
+// GetSessionAnalytics returns the talk-time analytics of a session
+func (sm *SessionManager) GetSessionAnalytics(sessionID string) (SessionAnalytics, error) {
+	session, exists := sm.GetSession(sessionID)
+	if !exists {
+		return SessionAnalytics{}, fmt.Errorf("session not found: %s", sessionID)
+	}
+	return session.Analytics(sm.vadSampleRate()), nil
+}
+
+func (sm *SessionManager) vadSampleRate() int {
+	if sm.Config() != nil && sm.Config().Vad.SampleRate > 0 {
+		return sm.Config().Vad.SampleRate
+	}
+	return 16000
+}
+
+// sendSessionAnalytics sends session.analytics when the session ends, however it ends,
+// before its outbound queue is drained. Only the first call sends it, so the paths
+// ending a session may each call it.
+func (sm *SessionManager) sendSessionAnalytics(session *Session) {
+	if !session.analyticsSent.CompareAndSwap(false, true) {
+		return
+	}
+	analyticsEvent := &SessionAnalyticsEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeSessionAnalytics,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		Analytics: session.Analytics(sm.vadSampleRate()),
+	}
+
+	if err := sm.SendEvent(session, analyticsEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "ws_event_send",
+			"action":    "send_session_analytics_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Warn("Failed to send session.analytics event")
+		return
+	}
+	session.Logger().WithFields(logrus.Fields{
+		"component":  "ws_event_send",
+		"action":     "session_analytics_sent",
+		"sessionID":  session.ID,
+		"talkTimeMs": analyticsEvent.Analytics.TalkTimeMs,
+		"silenceMs":  analyticsEvent.Analytics.SilenceMs,
+	}).Info("Sent session.analytics event")
+}
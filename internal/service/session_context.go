@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errSessionEnded is returned for events sent to a session after it was removed
+var errSessionEnded = errors.New("session ended")
+
+// sessionTaskTimeout bounds how long an ending session waits for its goroutines
+const sessionTaskTimeout = 5 * time.Second
+
+// sessionLifecycle scopes the work of a session to its lifetime. The SessionManager
+// creates its context with the session and cancels it when it deletes or removes the
+// session, so recognitions still in flight abort their ASR calls and send nothing to
+// a connection that is gone.
+type sessionLifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	tasks  sync.WaitGroup // goroutines started with Session.spawn
+}
+
+// newSessionLifecycle creates the lifecycle of a new session
+func newSessionLifecycle() sessionLifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return sessionLifecycle{ctx: ctx, cancel: cancel}
+}
+
+// Context returns the context of the session, cancelled when the session is removed.
+// Channel streams of multi-channel sessions share their session's context.
+func (s *Session) Context() context.Context {
+	owner := s.owner()
+	if owner.lifecycle.ctx == nil {
+		return context.Background()
+	}
+	return owner.lifecycle.ctx
+}
+
+// end cancels the context of the session, the SessionManager calls it on removal
+func (s *Session) end() {
+	if s.lifecycle.cancel != nil {
+		s.lifecycle.cancel()
+	}
+}
+
+// spawn runs fn in a goroutine the session waits for when it ends, see waitTasks
+func (s *Session) spawn(fn func()) {
+	owner := s.owner()
+	owner.lifecycle.tasks.Add(1)
+	go func() {
+		defer owner.lifecycle.tasks.Done()
+		fn()
+	}()
+}
+
+// waitTasks waits up to timeout for the goroutines of the session to return after its
+// context was cancelled and reports whether they did
+func (s *Session) waitTasks(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.lifecycle.tasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// withSessionContext returns a context of parent that is also cancelled when the
+// session ends, for the loops serving the session on a connection
+func withSessionContext(parent context.Context, session *Session) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(session.Context(), cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// recognitionCancelled reports whether a recognition failed because its session ended,
+// in which case there is nobody to send the failure to
+func recognitionCancelled(session *Session, itemID string) bool {
+	if session.Context().Err() == nil {
+		return false
+	}
+	session.Logger().WithFields(logrus.Fields{
+		"component": "audio_recogniz",
+		"action":    "recognition_cancelled",
+		"itemID":    itemID,
+		"sessionID": session.ID,
+	}).Info("Session ended during recognition, result discarded")
+	return true
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	JournalDirectionIn  = "in"
+	JournalDirectionOut = "out"
+
+	// maxJournalEntries bounds the per-session event journal
+	maxJournalEntries = 2000
+	// journalFile is the journal of a debug session, written next to the session's
+	// saved audio when the session ends
+	journalFile = "journal.jsonl"
+)
+
+// JournalEntry records a single inbound or outbound event of a debug session
+type JournalEntry struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Direction string          `json:"direction"` // "in" or "out"
+	Type      string          `json:"type"`
+	EventID   string          `json:"event_id,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// IsDebug reports whether scoped debug mode is enabled for this session
+func (s *Session) IsDebug() bool {
+	if s.parent != nil {
+		return s.parent.IsDebug()
+	}
+	return s.debug.Load()
+}
+
+// SetDebug enables or disables scoped debug mode for this session
+func (s *Session) SetDebug(enabled bool) {
+	s.debug.Store(enabled)
+}
+
+// Logger returns the logger for this session, elevated to debug level in debug mode
+func (s *Session) Logger() *logrus.Logger {
+	return logger.Scoped(s.IsDebug())
+}
+
+// SetSessionDebug toggles scoped debug mode for a session
+func (sm *SessionManager) SetSessionDebug(sessionID string, enabled bool) error {
+	session, exists := sm.GetSession(sessionID)
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	session.SetDebug(enabled)
+
+	logger.WithFields(logrus.Fields{
+		"component": "mg_session_ctrl",
+		"action":    "debug_mode_changed",
+		"sessionID": sessionID,
+		"enabled":   enabled,
+	}).Info("Session debug mode changed")
+
+	return nil
+}
+
+// RecordJournal appends a raw event to the session's event journal. Audio payloads
+// of append events are replaced by their length to keep the journal small.
+func (sm *SessionManager) RecordJournal(session *Session, direction string, data []byte) {
+	var header struct {
+		Type    string `json:"type"`
+		EventID string `json:"event_id"`
+		Audio   string `json:"audio"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return
+	}
+
+	payload := json.RawMessage(append([]byte(nil), data...))
+	if header.Audio != "" {
+		payload, _ = json.Marshal(map[string]interface{}{
+			"type":         header.Type,
+			"event_id":     header.EventID,
+			"audio_length": len(header.Audio),
+		})
+	}
+
+	session.journalMutex.Lock()
+	defer session.journalMutex.Unlock()
+
+	session.EventJournal = append(session.EventJournal, JournalEntry{
+		Timestamp: time.Now(),
+		Direction: direction,
+		Type:      header.Type,
+		EventID:   header.EventID,
+		Payload:   payload,
+	})
+	if len(session.EventJournal) > maxJournalEntries {
+		session.EventJournal = session.EventJournal[len(session.EventJournal)-maxJournalEntries:]
+	}
+}
+
+// GetEventJournal returns a copy of the session's event journal
+func (sm *SessionManager) GetEventJournal(sessionID string) ([]JournalEntry, error) {
+	session, exists := sm.GetSession(sessionID)
+	if !exists {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	session.journalMutex.Lock()
+	defer session.journalMutex.Unlock()
+
+	journal := make([]JournalEntry, len(session.EventJournal))
+	copy(journal, session.EventJournal)
+	return journal, nil
+}
+
+// saveEventJournal hands the journal of a debug session, as JSON lines, to saveJournal.
+// The journal is written in the background, the caller holds the manager's lock.
+func (sm *SessionManager) saveEventJournal(session *Session) {
+	if sm.saveJournal == nil {
+		return
+	}
+
+	session.journalMutex.Lock()
+	defer session.journalMutex.Unlock()
+
+	if len(session.EventJournal) == 0 {
+		return
+	}
+
+	var journal bytes.Buffer
+	encoder := json.NewEncoder(&journal)
+	for _, entry := range session.EventJournal {
+		if err := encoder.Encode(entry); err != nil {
+			break
+		}
+	}
+	go sm.saveJournal(session, journal.Bytes(), len(session.EventJournal))
+}
+
+// saveEventJournal stores the journal of a debug session under the key prefix of the
+// session's saved audio, where the audio retention policy reclaims it. Journals hold
+// the events of the session, so on local disk only the service user can read them.
+func (s *OpenAIService) saveEventJournal(session *Session, journal []byte, entries int) {
+	if err := validRecordingID(session.ID); err != nil {
+		return
+	}
+	key := renderKeyTemplate(s.audioKeyTemplate(), session.ID, session.CreatedAt) + journalFile
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := s.journalStore().Put(ctx, key, journal, "application/x-ndjson"); err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "mg_session_ctrl",
+			"action":    "journal_save_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Failed to save event journal")
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"component": "mg_session_ctrl",
+		"action":    "journal_saved",
+		"sessionID": session.ID,
+		"key":       key,
+		"entries":   entries,
+	}).Info("Saved event journal of debug session")
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"github.com/go-restream/stt/config"
+)
+
+// applySessionDefaults fills the configuration of a new session from the
+// session_defaults of the server config, before any session.update is received
+func applySessionDefaults(sess *Session, cfg *config.Config) {
+	defaults := cfg.SessionDefaults
+	sess.InputAudioTranscription.Model = defaults.InputAudioTranscription.Model
+	sess.InputAudioTranscription.Language = defaults.InputAudioTranscription.Language
+
+	sess.TurnDetection.Type = defaults.TurnDetection.Type
+	sess.TurnDetection.Threshold = defaults.TurnDetection.Threshold
+	sess.TurnDetection.PrefixPaddingMs = defaults.TurnDetection.PrefixPaddingMs
+	sess.TurnDetection.SilenceDurationMs = defaults.TurnDetection.SilenceDurationMs
+}
+
+// noiseSuppressionDefault reports whether new sessions start with a denoiser,
+// session_defaults.noise_suppression overriding denoiser.enable when set
+func noiseSuppressionDefault(cfg *config.Config) bool {
+	if cfg.SessionDefaults.NoiseSuppression != nil {
+		return *cfg.SessionDefaults.NoiseSuppression
+	}
+	return cfg.Denoiser.Enable
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// deterministicTick is how far the deterministic clock advances on every reading
+	deterministicTick = 10 * time.Millisecond
+)
+
+// deterministicEpoch is the start of the deterministic clock, offset by the seed
+var deterministicEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// DeterministicSource derives event IDs, item IDs and timestamps from a seed and a
+// counter so that a replayed session emits byte-identical events. It is meant for
+// end-to-end protocol tests and golden-file comparisons only.
+type DeterministicSource struct {
+	seed  int64
+	ids   atomic.Uint64
+	ticks atomic.Int64
+}
+
+// NewDeterministicSource creates a deterministic ID and clock source for a seed
+func NewDeterministicSource(seed int64) *DeterministicSource {
+	return &DeterministicSource{seed: seed}
+}
+
+// Seed returns the seed the source was created with
+func (d *DeterministicSource) Seed() int64 {
+	return d.seed
+}
+
+// NextID returns the next ID with the given prefix, e.g. "event_42_000003"
+func (d *DeterministicSource) NextID(prefix string) string {
+	return fmt.Sprintf("%s_%d_%06d", prefix, d.seed, d.ids.Add(1))
+}
+
+// Now returns the next reading of the deterministic clock
+func (d *DeterministicSource) Now() time.Time {
+	tick := d.ticks.Add(1)
+	return deterministicEpoch.Add(time.Duration(d.seed)*time.Second + time.Duration(tick)*deterministicTick)
+}
+
+// SetDeterministicSeed switches the session to deterministic mode with the given seed,
+// restarting the ID counter and clock
+func (s *Session) SetDeterministicSeed(seed int64) {
+	s.deterministic.Store(NewDeterministicSource(seed))
+}
+
+// IsDeterministic reports whether the session derives IDs and timestamps from a seed
+func (s *Session) IsDeterministic() bool {
+	return s.deterministic.Load() != nil
+}
+
+// NewEventID returns an event ID for an event emitted on this session
+func (s *Session) NewEventID() string {
+	if source := s.deterministic.Load(); source != nil {
+		return source.NextID("event")
+	}
+	return GenerateEventID()
+}
+
+// NewItemID returns a conversation item ID for this session
+func (s *Session) NewItemID() string {
+	if source := s.deterministic.Load(); source != nil {
+		return source.NextID("item")
+	}
+	return GenerateItemID()
+}
+
+// NewConversationID returns a conversation ID for this session
+func (s *Session) NewConversationID() string {
+	if source := s.deterministic.Load(); source != nil {
+		return source.NextID("conv")
+	}
+	return GenerateConversationID()
+}
+
+// Now returns the time used for timestamps in events emitted on this session
+func (s *Session) Now() time.Time {
+	if source := s.deterministic.Load(); source != nil {
+		return source.Now()
+	}
+	return time.Now()
+}
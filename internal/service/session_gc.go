@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Reasons a session ends, counted in SessionGCStats
+const (
+	SessionEndClosed  = "closed"  // the connection closed normally
+	SessionEndError   = "error"   // the connection failed
+	SessionEndTimeout = "timeout" // removed by an inactivity sweep
+	SessionEndEvicted = "evicted" // closed via the admin API or on cluster demotion
+)
+
+// SessionGCStats reports how sessions were cleaned up since the service started, so
+// operators can tell whether the inactivity sweep keeps up
+type SessionGCStats struct {
+	ClosedSessions       int64     `json:"closed_sessions"`
+	ErrorSessions        int64     `json:"error_sessions"`
+	TimedOutSessions     int64     `json:"timed_out_sessions"`
+	EvictedSessions      int64     `json:"evicted_sessions"`
+	BufferBytesReclaimed int64     `json:"buffer_bytes_reclaimed"` // audio buffers of ended sessions
+	Sweeps               int64     `json:"sweeps"`
+	ForcedSweeps         int64     `json:"forced_sweeps"`
+	LastSweepAt          time.Time `json:"last_sweep_at,omitempty"`
+	LastSweepRemoved     int       `json:"last_sweep_removed"`
+	LastSweepDurationMs  float64   `json:"last_sweep_duration_ms"`
+	SessionTimeoutMs     int64     `json:"session_timeout_ms"`
+}
+
+// SweepResult is the outcome of an inactivity sweep
+type SweepResult struct {
+	Removed              int     `json:"removed"`
+	BufferBytesReclaimed int64   `json:"buffer_bytes_reclaimed"`
+	DurationMs           float64 `json:"duration_ms"`
+}
+
+// sessionGCCounters accumulates SessionGCStats, safe for concurrent use
+type sessionGCCounters struct {
+	closed       atomic.Int64
+	errored      atomic.Int64
+	timedOut     atomic.Int64
+	evicted      atomic.Int64
+	bytes        atomic.Int64
+	sweeps       atomic.Int64
+	forcedSweeps atomic.Int64
+
+	sweepMutex sync.Mutex
+	lastSweep  time.Time
+	lastResult SweepResult
+}
+
+// sessionBufferBytes is the memory held by the audio buffers of a session
+func sessionBufferBytes(session *Session) int64 {
+	session.VADAudioBufferMutex.RLock()
+	defer session.VADAudioBufferMutex.RUnlock()
+	return int64(cap(session.AudioBuffer)+cap(session.VADAudioBuffer)) * 2
+}
+
+// recordSessionEnd counts a session that is about to be deleted and the buffer
+// memory it releases, returning the latter
+func (c *sessionGCCounters) recordSessionEnd(session *Session, reason string) int64 {
+	switch reason {
+	case SessionEndTimeout:
+		c.timedOut.Add(1)
+	case SessionEndError:
+		c.errored.Add(1)
+	case SessionEndEvicted:
+		c.evicted.Add(1)
+	default:
+		c.closed.Add(1)
+	}
+	bytes := sessionBufferBytes(session)
+	c.bytes.Add(bytes)
+	return bytes
+}
+
+// recordSweep stores the outcome of an inactivity sweep
+func (c *sessionGCCounters) recordSweep(result SweepResult, forced bool) {
+	c.sweeps.Add(1)
+	if forced {
+		c.forcedSweeps.Add(1)
+	}
+	c.sweepMutex.Lock()
+	c.lastSweep = time.Now()
+	c.lastResult = result
+	c.sweepMutex.Unlock()
+}
+
+// GCStats returns the session cleanup counters
+func (sm *SessionManager) GCStats() SessionGCStats {
+	sm.gc.sweepMutex.Lock()
+	lastSweep, lastResult := sm.gc.lastSweep, sm.gc.lastResult
+	sm.gc.sweepMutex.Unlock()
+
+	return SessionGCStats{
+		ClosedSessions:       sm.gc.closed.Load(),
+		ErrorSessions:        sm.gc.errored.Load(),
+		TimedOutSessions:     sm.gc.timedOut.Load(),
+		EvictedSessions:      sm.gc.evicted.Load(),
+		BufferBytesReclaimed: sm.gc.bytes.Load(),
+		Sweeps:               sm.gc.sweeps.Load(),
+		ForcedSweeps:         sm.gc.forcedSweeps.Load(),
+		LastSweepAt:          lastSweep,
+		LastSweepRemoved:     lastResult.Removed,
+		LastSweepDurationMs:  lastResult.DurationMs,
+		SessionTimeoutMs:     sm.SessionTimeout.Milliseconds(),
+	}
+}
+
+// sessionGCLoop sweeps inactive sessions at the configured interval until ctx is done
+func (s *OpenAIService) sessionGCLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result := s.sessionManager.CleanupInactiveSessions()
+			if result.Removed > 0 {
+				logger.WithFields(logrus.Fields{
+					"component":            "mg_session_ctrl",
+					"action":               "session_sweep",
+					"removed":              result.Removed,
+					"bufferBytesReclaimed": result.BufferBytesReclaimed,
+					"durationMs":           result.DurationMs,
+				}).Info("Swept inactive sessions")
+			}
+		}
+	}
+}
+
+// handleSessionGC runs an inactivity sweep immediately and returns its outcome with
+// the cleanup counters
+func handleSessionGC(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	result := openAIService.sessionManager.sweepInactiveSessions(true)
+	logger.WithFields(logrus.Fields{
+		"component":            "mg_session_ctrl",
+		"action":               "forced_session_sweep",
+		"removed":              result.Removed,
+		"bufferBytesReclaimed": result.BufferBytesReclaimed,
+		"durationMs":           result.DurationMs,
+	}).Info("Forced session sweep via admin API")
+
+	c.JSON(http.StatusOK, gin.H{"sweep": result, "gc": openAIService.sessionManager.GCStats()})
+}
+
+// handleSessionGCStats returns the session cleanup counters
+func handleSessionGCStats(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, openAIService.sessionManager.GCStats())
+}
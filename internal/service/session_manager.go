@@ -2,12 +2,24 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/llm"
 	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/agc"
+	"github.com/go-restream/stt/pkg/audioquality"
+	"github.com/go-restream/stt/pkg/modelpool"
+	"github.com/go-restream/stt/pkg/noisegate"
+	"github.com/go-restream/stt/pkg/ratelimit"
+	"github.com/go-restream/stt/pkg/opus"
+	"github.com/go-restream/stt/pkg/resampler"
+	"github.com/go-restream/stt/pkg/textformat"
 	vad "github.com/go-restream/stt/vad"
 	denoiser "github.com/go-restream/stt/denoiser"
 	"github.com/gorilla/websocket"
@@ -33,6 +45,10 @@ type Session struct {
 		Channels   int    `json:"channels"`
 	} `json:"input_audio_format,omitempty"`
 
+	// Encoding of appended audio, binary frames are accepted under "binary"; see
+	// input_encoding.go
+	InputEncoding string `json:"input_encoding,omitempty"`
+
 	OutputAudioFormat struct {
 		Type       string `json:"type"`
 		SampleRate int    `json:"sample_rate"`
@@ -41,8 +57,11 @@ type Session struct {
 
 	// Audio transcription configuration
 	InputAudioTranscription struct {
-		Model    string `json:"model"`
-		Language string `json:"language"`
+		Model          string `json:"model"`
+		Language       string `json:"language"`
+		InterimResults bool   `json:"interim_results,omitempty"`
+		DeltaMode      string `json:"delta_mode,omitempty"` // see DeltaModeCompact
+		Hints          []string `json:"hints,omitempty"`    // custom vocabulary, see hints.go
 	} `json:"input_audio_transcription,omitempty"`
 
 	// Turn detection configuration
@@ -53,12 +72,70 @@ type Session struct {
 		SilenceDurationMs int     `json:"silence_duration_ms"`
 	} `json:"turn_detection,omitempty"`
 
+	// Transcript formatting profile, applied before transcripts are sent
+	TranscriptFormat textformat.Options `json:"transcript_format,omitempty"`
+
+	// Caption cue generation, see captions.go
+	Captions     CaptionConfig `json:"captions,omitempty"`
+	captionFiles captionFileSet
+	captionEdge  captionEdge // how far HLS caption segments are final, see hls_captions.go
+
+	// Recognition mode, see sliding_window.go
+	Recognition   RecognitionConfig `json:"recognition,omitempty"`
+	slidingWindow slidingWindow
+
+	// Interim hypotheses of the utterance in progress, see interim.go
+	interim interimTranscript
+
+	// Bytes sent and received, see bandwidth.go
+	bandwidth bandwidthCounter
+
+	// Speech, recognition and dropped audio counters, see session_stats.go
+	stats statsCounter
+
+	// session.analytics was sent, see session_analytics.go
+	analyticsSent atomic.Bool
+
+	// Events waiting for a long-poll client, nil on WebSocket sessions, see long_poll.go
+	poll *pollOutbox
+
+	// Name of the live stream the session transcribes, empty for sessions of clients;
+	// see ingest.go
+	ingest string
+
+	// Twilio call whose track the session transcribes, nil for other sessions; see
+	// twilio.go
+	twilio *twilioCall
+
+	// Call-ID of the SIP call whose RTP stream the session transcribes, see sip.go
+	sipCall string
+
+	// Secret with which the client moves the session to another transport, the client's
+	// quotas the session holds and the generation of the transport serving it; see handoff.go
+	ResumeToken string `json:"-"`
+	lease       *sessionLease
+	transport   int64
+	ended       bool
+	evicted     bool // closed via the admin API, see session_admin.go
+
+	// Events sent recently, replayed to a client resuming the session, and whether its
+	// connection dropped and it waits for the client; see session_resume.go
+	replay *replayBuffer
+	parked bool
+
+	// Sequence number of the last event sent, guarded by mutex; see SendEvent
+	eventSeq uint64
+
+	// Events waiting to be written to Conn, see outbound.go
+	outbound *outboundQueue
+
 	// Tools and tool choice
 	Tools      []interface{} `json:"tools,omitempty"`
 	ToolChoice string        `json:"tool_choice,omitempty"`
 
 	// Conversation state
 	ConversationItems []*ConversationItem `json:"conversation_items,omitempty"`
+	itemsMutex        sync.RWMutex
 
 	// Audio buffer state
 	AudioBuffer      []int16 `json:"-"`
@@ -80,16 +157,97 @@ type Session struct {
 	// VAD state
 	IsSpeaking      bool `json:"-"`
 	SpeechStartTime time.Time `json:"-"`
+	speechStartedAt time.Time // session clock reading, used for audio_*_ms in events
+	vadSamplesFed   atomic.Int64 // samples fed to the VAD detector since session start
+	vadResetOffset  int64 // vadSamplesFed at the last detector reset, segment starts are relative to it
+	vadSegmentCount int
+	rawSamplesAppended int64 // 16kHz samples added to AudioBuffer, see TurnDetectionNone
+	utteranceStartMs  int64 // start of the first segment since the last commit
+	vadSegmentEnds    []int // end offsets of the speech segments in VADAudioBuffer, guarded by VADAudioBufferMutex
+	hasUtteranceStart bool
+	talkTime        *talkTimeTracker
+	quality         qualityTracker
+	audioMonitor    *audioquality.Monitor
+
+	// Tenant of the authenticated client, see auth.go
+	Tenant string `json:"tenant,omitempty"`
+
+	// Usage limits of the API key tier, see limits.go
+	APIKey        string `json:"-"`
+	Tier          string `json:"tier,omitempty"`
+	limitWarned   map[string]bool
+	limitExceeded bool
+	limitMutex    sync.Mutex
+
+	// Per-client rate limits, see rate_limits.go
+	rateClient       string            // client whose audio budget the session uses
+	eventBucket      *ratelimit.Bucket // nil without an event rate limit
+	rateLimitNotices map[string]time.Time
 	VADDetector     *vad.VADDetector `json:"-"`
+	vadPool         *modelpool.Pool[*vad.VADDetector]
+	vadConfig       *config.Config // settings of VADDetector, see turn_detection.go
+	turnDetectionSet bool          // a session.update set turn_detection, applied over the pipeline
+	vadAdjustment   atomic.Pointer[VADAdjustment] // set via the admin API, applied with the next audio
+
+	// Denoiser state, a denoiser is leased from the pool for each segment
+	denoiserPool *modelpool.Pool[*denoiser.DenoiserProcessor]
+	NoiseReduction *NoiseReductionConfig `json:"input_audio_noise_reduction,omitempty"` // set by session.update, see noise_reduction.go
+
+	// Spectral noise gate applied to the 16kHz stream before the VAD
+	noiseGate *noisegate.Gate
+
+	// Gain control applied after the noise gate, see gain_control.go
+	gainControl *agc.AGC
+	GainControl *GainControlConfig `json:"input_audio_gain_control,omitempty"` // set by session.update
 
-	// Denoiser state
-	DenoiserProcessor *denoiser.DenoiserProcessor `json:"-"`
+	// Decoder of "opus" input, packets are decoded to 16kHz PCM16
+	opusDecoder *opus.Decoder
+
+	// Streaming converter to 16kHz for input rates other than 16kHz and 48kHz
+	inputResampler     *resampler.Resampler
+	inputResamplerRate int
+
+	// Streams of a multi-channel session, one per input channel with its own VAD and
+	// buffers, and on such a stream the session it belongs to; see channels.go
+	channels []*Session
+	parent   *Session
+	channel  int
 
 	// Recognition state
 	CurrentItemID string `json:"current_item_id,omitempty"`
 
+	// Trace of the conversation item in progress, see tracing.go
+	trace itemTrace
+
 	// Heartbeat tracking
 	LastHeartbeat time.Time `json:"last_heartbeat"`
+
+	// Context cancelled when the session is removed and the goroutines working for it,
+	// see session_context.go
+	lifecycle sessionLifecycle
+
+	// Changes of the persisted state and the count last saved, see session_store.go
+	storeChanges  atomic.Int64
+	storedChanges int64
+
+	// Credentials from the upgrade request forwarded to the ASR backend
+	ForwardedHeaders http.Header `json:"-"`
+
+	// Named pipeline selected with session.update, see pipelines.go
+	Pipeline    string        `json:"pipeline,omitempty"`
+	ASREndpoint *llm.Endpoint `json:"-"` // nil uses the global ASR settings
+
+	// Experiment variant the session was assigned to, see experiments.go
+	Experiment string `json:"experiment,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+
+	// Scoped debug mode: verbose logging, audio saving and event journal for this session only
+	debug        atomic.Bool
+	EventJournal []JournalEntry `json:"-"`
+	journalMutex sync.Mutex
+
+	// Deterministic test mode: IDs and timestamps derived from a seed, nil when disabled
+	deterministic atomic.Pointer[DeterministicSource]
 }
 
 // ConversationItem represents a conversation item in the session
@@ -102,6 +260,13 @@ type ConversationItem struct {
 	Audio     *AudioContent `json:"audio,omitempty"`
 	CreatedAt time.Time     `json:"created_at"`
 	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+	AudioStartMs int64      `json:"audio_start_ms,omitempty"` // start of the item's speech in the session audio
+	AudioEndMs   int64      `json:"audio_end_ms,omitempty"`   // end of the item's speech in the session audio
+	Words     []llm.TranscriptionWord `json:"words,omitempty"` // recognized words with confidence, relative to AudioStartMs
+	TraceID   string        `json:"trace_id,omitempty"` // OpenTelemetry trace of the item's recognition
+	Channel   *int          `json:"channel,omitempty"` // input channel the item was spoken on, multi-channel sessions only
+	Language  string        `json:"language,omitempty"` // detected language of the transcript, see language.go
+	Hints     []string      `json:"hints,omitempty"` // custom vocabulary the item was recognized with
 }
 
 // AudioContent represents audio content in a conversation item
@@ -110,6 +275,9 @@ type AudioContent struct {
 	Format string `json:"format"`
 }
 
+// errMaxSessions is returned when a session would exceed the server's session limit
+var errMaxSessions = errors.New("maximum number of sessions reached")
+
 // SessionManager manages all active sessions
 type SessionManager struct {
 	sessions map[string]*Session
@@ -118,46 +286,96 @@ type SessionManager struct {
 	// Configuration
 	SessionTimeout time.Duration
 	MaxSessions    int
-	Config         *config.Config
+	cfg            atomic.Pointer[config.Config] // replaced on reload, see config_reload.go
+
+	// Counters of ended sessions and inactivity sweeps, see session_gc.go
+	gc sessionGCCounters
+
+	// Publication of events to Kafka or NATS, nil without an event sink; see
+	// event_sink.go
+	events *eventPublisher
+
+	// Writes the journal of a debug session when it ends, see session_debug.go
+	saveJournal func(session *Session, journal []byte, entries int)
 }
 
 // NewSessionManager creates a new session manager
 func NewSessionManager(sessionTimeout time.Duration, maxSessions int, cfg *config.Config) *SessionManager {
-	return &SessionManager{
+	sm := &SessionManager{
 		sessions:       make(map[string]*Session),
 		SessionTimeout: sessionTimeout,
 		MaxSessions:    maxSessions,
-		Config:         cfg,
 	}
+	sm.cfg.Store(cfg)
+	return sm
+}
+
+// Config returns the current configuration, nil when the manager has none
+func (sm *SessionManager) Config() *config.Config {
+	return sm.cfg.Load()
 }
 
 // CreateSession creates a new session for a WebSocket connection
 func (sm *SessionManager) CreateSession(conn *websocket.Conn, modality string) (*Session, error) {
+	return sm.CreateSessionWithID(conn, modality, "", "")
+}
+
+// CreateSessionWithID creates a session with the ID and resume token of a stored
+// session it resumes, see session_store.go; an empty ID generates new ones
+func (sm *SessionManager) CreateSessionWithID(conn *websocket.Conn, modality string, sessionID string, resumeToken string) (*Session, error) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
 	if len(sm.sessions) >= sm.MaxSessions {
-		return nil, fmt.Errorf("maximum number of sessions reached")
+		return nil, errMaxSessions
+	}
+
+	if sessionID == "" {
+		token, err := newSessionToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create resume token: %v", err)
+		}
+		sessionID, resumeToken = GenerateSessionID(), token
+	} else if _, exists := sm.sessions[sessionID]; exists {
+		return nil, fmt.Errorf("session already live: %s", sessionID)
 	}
 
-	sessionID := GenerateSessionID()
 	session := &Session{
 		ID:        sessionID,
+		ResumeToken: resumeToken,
 		Conn:      conn,
 		CreatedAt: time.Now(),
 		LastActive: time.Now(),
 		Modality:  modality,
 		AudioBuffer: make([]int16, 0),
 		LastHeartbeat: time.Now(),
+		talkTime:  newTalkTimeTracker(),
+		audioMonitor: audioquality.NewMonitor(),
+		lifecycle: newSessionLifecycle(),
 	}
 
-	session.InputAudioFormat.Type = "pcm16"
+	session.InputAudioFormat.Type = InputAudioFormatPCM16
 	session.InputAudioFormat.SampleRate = 0
 	session.InputAudioFormat.Channels = 1
 
+	cfg := sm.Config()
+	if cfg != nil {
+		session.SetBandwidthReportSeconds(cfg.Bandwidth.ReportIntervalSeconds)
+		session.SetStatsReportSeconds(cfg.SessionStats.ReportIntervalSeconds)
+		applySessionDefaults(session, cfg)
+	}
+
+	if cfg != nil && cfg.NoiseGate.Enable {
+		session.noiseGate = newNoiseGate(cfg)
+	}
+
+	if cfg != nil && cfg.AGC.Enable {
+		session.gainControl = agc.New(16000, gainControlOptions(cfg, nil))
+	}
+
 	// Initialize per-session VAD detector if VAD is enabled
-	if sm.Config != nil && sm.Config.Vad.Enable {
-		session.VADDetector = vad.NewVADDetector(sm.Config)
+	if cfg != nil && cfg.Vad.Enable && session.TurnDetection.Type != TurnDetectionNone {
+		session.acquireVADDetector(cfg)
 		logger.WithFields(logrus.Fields{
 			"component": "mg_session_ctrl",
 			"action":    "vad_detector_initialized",
@@ -166,8 +384,8 @@ func (sm *SessionManager) CreateSession(conn *websocket.Conn, modality string) (
 	}
 
 	// Initialize per-session denoiser processor if denoiser is enabled
-	if sm.Config != nil && sm.Config.Denoiser.Enable {
-		session.DenoiserProcessor = denoiser.NewDenoiserProcessor(sm.Config)
+	if cfg != nil && noiseSuppressionDefault(cfg) {
+		session.denoiserPool = modelPools.denoiserPool(cfg)
 		logger.WithFields(logrus.Fields{
 			"component": "mg_session_ctrl",
 			"action":    "denoiser_processor_initialized",
@@ -176,6 +394,7 @@ func (sm *SessionManager) CreateSession(conn *websocket.Conn, modality string) (
 	}
 
 	sm.sessions[sessionID] = session
+	session.markChanged()
 
 	logger.WithFields(logrus.Fields{
 		"component": "mg_session_ctrl",
@@ -186,6 +405,17 @@ func (sm *SessionManager) CreateSession(conn *websocket.Conn, modality string) (
 	return session, nil
 }
 
+// newNoiseGate creates the spectral noise gate configured in noise_gate
+func newNoiseGate(cfg *config.Config) *noisegate.Gate {
+	gate := cfg.NoiseGate
+	return noisegate.New(16000, noisegate.Options{
+		ThresholdDB: gate.ThresholdDB,
+		ReductionDB: gate.ReductionDB,
+		AttackMs:    gate.AttackMs,
+		ReleaseMs:   gate.ReleaseMs,
+	})
+}
+
 // GetSession retrieves a session by ID
 func (sm *SessionManager) GetSession(sessionID string) (*Session, bool) {
 	sm.mutex.RLock()
@@ -216,35 +446,73 @@ func (sm *SessionManager) UpdateSession(sessionID string, updateFunc func(*Sessi
 
 	updateFunc(session)
 	session.LastActive = time.Now()
+	session.markChanged()
 
 	return nil
 }
 
+// itemSettings are the session settings a committed item is recognized and presented
+// with, so a session.update arriving meanwhile applies from the next item on
+type itemSettings struct {
+	format   textformat.Options
+	captions CaptionConfig
+	hints    []string
+}
+
+// snapshotItemSettings reads the item settings of a session under the lock
+// session.update writes them with
+func (sm *SessionManager) snapshotItemSettings(session *Session) itemSettings {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	return itemSettings{
+		format:   session.TranscriptFormat,
+		captions: session.Captions,
+		hints:    session.InputAudioTranscription.Hints,
+	}
+}
+
+// updateStream applies updateFunc to a session or one of its channel streams, which
+// the manager does not hold by ID
+func (sm *SessionManager) updateStream(stream *Session, updateFunc func(*Session)) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	updateFunc(stream)
+	owner := stream.owner()
+	owner.LastActive = time.Now()
+	owner.markChanged()
+}
+
 // DeleteSession removes a session
 func (sm *SessionManager) DeleteSession(sessionID string) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
 	if session, exists := sm.sessions[sessionID]; exists {
+		sm.gc.recordSessionEnd(session, SessionEndClosed)
+		if sm.events != nil {
+			sm.events.sessionEnded(session, SessionEndClosed)
+		}
+
 		// Clean up VAD detector if it exists
 		if session.VADDetector != nil {
-			session.VADDetector.Close()
+			session.releaseVADDetector()
 			logger.WithFields(logrus.Fields{
 				"component": "mg_session_ctrl",
-				"action":    "vad_detector_closed",
+				"action":    "vad_detector_released",
 				"sessionID": sessionID,
-			}).Info("Per-session VAD detector closed")
+			}).Info("Per-session VAD detector returned to pool")
 		}
-
-		// Clean up denoiser processor if it exists
-		if session.DenoiserProcessor != nil {
-			session.DenoiserProcessor.Close()
-			logger.WithFields(logrus.Fields{
-				"component": "mg_session_ctrl",
-				"action":    "denoiser_processor_closed",
-				"sessionID": sessionID,
-			}).Info("Per-session denoiser processor closed")
+		releaseChannels(session)
+		if session.opusDecoder != nil {
+			session.opusDecoder.Close()
+		}
+		session.captionFiles.close()
+		if session.IsDebug() {
+			sm.saveEventJournal(session)
 		}
+		session.end()
 		session.AudioBuffer = nil
 		delete(sm.sessions, sessionID)
 		logger.WithFields(logrus.Fields{
@@ -255,8 +523,9 @@ func (sm *SessionManager) DeleteSession(sessionID string) {
 	}
 }
 
-// RemoveSession removes a specific session
-func (sm *SessionManager) RemoveSession(sessionID string) {
+// RemoveSession removes a specific session, counting it as ended for reason, one of
+// the SessionEnd* reasons
+func (sm *SessionManager) RemoveSession(sessionID string, reason string) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
@@ -264,6 +533,11 @@ func (sm *SessionManager) RemoveSession(sessionID string) {
 	if !exists {
 		return
 	}
+	sm.gc.recordSessionEnd(session, reason)
+	if sm.events != nil {
+		sm.events.sessionEnded(session, reason)
+	}
+	session.end()
 
 	if session.Conn != nil {
 		session.Conn.Close()
@@ -272,22 +546,18 @@ func (sm *SessionManager) RemoveSession(sessionID string) {
 
 	// Clean up VAD detector if it exists
 	if session.VADDetector != nil {
-		session.VADDetector.Close()
+		session.releaseVADDetector()
 		logger.WithFields(logrus.Fields{
 			"component": "mg_session_ctrl",
-			"action":    "vad_detector_closed",
+			"action":    "vad_detector_released",
 			"sessionID": sessionID,
-		}).Info("Per-session VAD detector closed during removal")
+		}).Info("Per-session VAD detector returned to pool during removal")
 	}
+	releaseChannels(session)
+	session.captionFiles.close()
 
-	// Clean up denoiser processor if it exists
-	if session.DenoiserProcessor != nil {
-		session.DenoiserProcessor.Close()
-		logger.WithFields(logrus.Fields{
-			"component": "mg_session_ctrl",
-			"action":    "denoiser_processor_closed",
-			"sessionID": sessionID,
-		}).Info("Per-session denoiser processor closed during removal")
+	if session.IsDebug() {
+		sm.saveEventJournal(session)
 	}
 
 	session.AudioBuffer = nil
@@ -302,36 +572,72 @@ func (sm *SessionManager) RemoveSession(sessionID string) {
 }
 
 // CleanupInactiveSessions removes sessions that have timed out
-func (sm *SessionManager) CleanupInactiveSessions() {
-	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
+func (sm *SessionManager) CleanupInactiveSessions() SweepResult {
+	return sm.sweepInactiveSessions(false)
+}
 
+// sweepInactiveSessions removes timed out sessions, forced when requested by an operator
+func (sm *SessionManager) sweepInactiveSessions(forced bool) SweepResult {
+	var result SweepResult
 	now := time.Now()
-	for sessionID, session := range sm.sessions {
+	defer func() {
+		result.DurationMs = float64(time.Since(now).Microseconds()) / 1000
+		sm.gc.recordSweep(result, forced)
+	}()
+
+	sm.mutex.RLock()
+	var expired []*Session
+	for _, session := range sm.sessions {
 		if now.Sub(session.LastActive) > sm.SessionTimeout {
+			expired = append(expired, session)
+		}
+	}
+	sm.mutex.RUnlock()
+	if len(expired) == 0 {
+		return result
+	}
+
+	// Expired sessions get their session.analytics before their connections close,
+	// outside the lock as their events take a while to drain
+	var drained sync.WaitGroup
+	for _, session := range expired {
+		drained.Add(1)
+		go func() {
+			defer drained.Done()
+			sm.sendSessionAnalytics(session)
+			sm.drainOutbound(session, time.Second)
+		}()
+	}
+	drained.Wait()
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	for _, session := range expired {
+		sessionID := session.ID
+		if sm.sessions[sessionID] == session {
+			result.Removed++
+			result.BufferBytesReclaimed += sm.gc.recordSessionEnd(session, SessionEndTimeout)
+			if sm.events != nil {
+				sm.events.sessionEnded(session, SessionEndTimeout)
+			}
+			session.end()
+
 			if session.Conn != nil {
 				session.Conn.Close()
 			}
 
 			// Clean up VAD detector if it exists
 			if session.VADDetector != nil {
-				session.VADDetector.Close()
-				logger.WithFields(logrus.Fields{
-					"component": "mg_session_ctrl",
-					"action":    "vad_detector_closed",
-					"sessionID": sessionID,
-				}).Info("Per-session VAD detector closed during cleanup")
-			}
-
-			// Clean up denoiser processor if it exists
-			if session.DenoiserProcessor != nil {
-				session.DenoiserProcessor.Close()
+				session.releaseVADDetector()
 				logger.WithFields(logrus.Fields{
 					"component": "mg_session_ctrl",
-					"action":    "denoiser_processor_closed",
+					"action":    "vad_detector_released",
 					"sessionID": sessionID,
-				}).Info("Per-session denoiser processor closed during cleanup")
+				}).Info("Per-session VAD detector returned to pool during cleanup")
 			}
+			releaseChannels(session)
+			session.captionFiles.close()
 
 			session.AudioBuffer = nil
 			delete(sm.sessions, sessionID)
@@ -344,6 +650,7 @@ func (sm *SessionManager) CleanupInactiveSessions() {
 			}).Info("Cleaned up inactive session")
 		}
 	}
+	return result
 }
 
 // GetActiveSessionCount returns the number of active sessions
@@ -364,29 +671,91 @@ func (sm *SessionManager) SendEventToSession(sessionID string, event interface{}
 	return sm.SendEvent(session, event)
 }
 
-// SendEvent sends an event to a session
+// SendEvent sends an event to a session. Events get the next sequence number of the
+// session, under the session mutex so they go out in the order they are numbered.
+// Events of a session that was removed are dropped with errSessionEnded.
 func (sm *SessionManager) SendEvent(session *Session, event interface{}) error {
-	if session.Conn == nil {
-		return fmt.Errorf("session connection is nil")
+	if session.Context().Err() != nil {
+		return errSessionEnded
 	}
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
 
+	if e, ok := event.(interface{ setSeq(uint64) }); ok {
+		session.eventSeq++
+		e.setSeq(session.eventSeq)
+	}
 	jsonData, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %v", err)
 	}
 
-	session.mutex.Lock()
-	defer session.mutex.Unlock()
+	if sm.events != nil {
+		sm.events.publish(session, eventType(event), jsonData)
+	}
+
+	return sm.writeEvent(session, eventID(event), jsonData)
+}
+
+func (e *BaseEvent) setSeq(seq uint64) { e.Seq = seq }
+
+// serverSide reports whether the server opened the session itself to transcribe an
+// ingested stream, a Twilio call or a SIP call, without a client reading its events
+func (s *Session) serverSide() bool {
+	return s.ingest != "" || s.twilio != nil || s.sipCall != ""
+}
 
+// writeEvent sends an encoded event to a session, the caller holds the session mutex
+func (sm *SessionManager) writeEvent(session *Session, id string, jsonData []byte) error {
+	if session.replay != nil {
+		session.replay.add(id, jsonData)
+	}
+	if session.poll != nil {
+		return sm.queueEvent(session, jsonData)
+	}
+	if session.serverSide() {
+		// Nobody reads the events of the session but the event sink
+		return nil
+	}
+	if session.parked {
+		// Kept in the replay buffer until the client resumes the session
+		return nil
+	}
 	if session.Conn == nil {
 		return fmt.Errorf("session connection closed")
 	}
 
+	if session.IsDebug() {
+		sm.RecordJournal(session, JournalDirectionOut, jsonData)
+	}
+	if session.outbound != nil {
+		sm.pushOutbound(session, jsonData)
+		return nil
+	}
+
 	if err := session.Conn.SetWriteDeadline(time.Now().Add(5 * time.Second)); err != nil {
 		return fmt.Errorf("failed to set write deadline: %v", err)
 	}
 
-	return session.Conn.WriteMessage(websocket.TextMessage, jsonData)
+	if err := session.Conn.WriteMessage(websocket.TextMessage, jsonData); err != nil {
+		return err
+	}
+	session.bandwidth.addOutbound(len(jsonData))
+	return nil
+}
+
+// queueEvent keeps an event of a long-poll session until the client polls it, the
+// caller holds the session mutex
+func (sm *SessionManager) queueEvent(session *Session, jsonData []byte) error {
+	if session.IsDebug() {
+		sm.RecordJournal(session, JournalDirectionOut, jsonData)
+	}
+
+	if err := session.poll.push(jsonData); err != nil {
+		return err
+	}
+	session.bandwidth.addOutbound(len(jsonData))
+	return nil
 }
 
 // AddAudioToBuffer adds audio data to the session's audio buffer
@@ -457,13 +826,18 @@ func (sm *SessionManager) AddVADAudioToBuffer(sessionID string, audioData []int1
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	session.VADAudioBufferMutex.Lock()
-	defer session.VADAudioBufferMutex.Unlock()
+	session.appendVADAudio(audioData)
+	return nil
+}
 
-	session.VADAudioBuffer = append(session.VADAudioBuffer, audioData...)
-	session.LastActive = time.Now()
+// appendVADAudio adds speech to the VAD audio buffer of a session or channel stream
+func (s *Session) appendVADAudio(audioData []int16) {
+	s.VADAudioBufferMutex.Lock()
+	defer s.VADAudioBufferMutex.Unlock()
 
-	return nil
+	s.VADAudioBuffer = append(s.VADAudioBuffer, audioData...)
+	s.vadSegmentEnds = append(s.vadSegmentEnds, len(s.VADAudioBuffer))
+	s.owner().LastActive = time.Now()
 }
 
 // GetVADAudioBuffer retrieves the current VAD audio buffer
@@ -489,10 +863,15 @@ func (sm *SessionManager) GetVADAudioBufferSize(sessionID string) (int, error) {
 		return 0, fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	session.VADAudioBufferMutex.RLock()
-	defer session.VADAudioBufferMutex.RUnlock()
+	return session.vadAudioSize(), nil
+}
+
+// vadAudioSize returns the number of samples in the VAD audio buffer
+func (s *Session) vadAudioSize() int {
+	s.VADAudioBufferMutex.RLock()
+	defer s.VADAudioBufferMutex.RUnlock()
 
-	return len(session.VADAudioBuffer), nil
+	return len(s.VADAudioBuffer)
 }
 
 // ClearVADAudioBuffer clears the VAD audio buffer
@@ -506,29 +885,71 @@ func (sm *SessionManager) ClearVADAudioBuffer(sessionID string) error {
 	defer session.VADAudioBufferMutex.Unlock()
 
 	session.VADAudioBuffer = make([]int16, 0)
+	session.vadSegmentEnds = nil
 	session.LastActive = time.Now()
 
 	return nil
 }
 
+// commitAudio returns a copy of the buffer input_audio_buffer.commit sends to
+// recognition, the raw audio buffer when the client commits, else the VAD audio buffer,
+// and the end offsets of the speech segments it holds, none for the raw audio buffer
+func (s *Session) commitAudio(manual bool) ([]int16, []int) {
+	if manual {
+		s.AudioBufferMutex.RLock()
+		defer s.AudioBufferMutex.RUnlock()
+		return append([]int16(nil), s.AudioBuffer...), nil
+	}
+	s.VADAudioBufferMutex.RLock()
+	defer s.VADAudioBufferMutex.RUnlock()
+	return append([]int16(nil), s.VADAudioBuffer...), append([]int(nil), s.vadSegmentEnds...)
+}
+
+// clearCommitAudio empties the buffer returned by commitAudio
+func (s *Session) clearCommitAudio(manual bool) {
+	mutex, buffer := &s.VADAudioBufferMutex, &s.VADAudioBuffer
+	if manual {
+		mutex, buffer = &s.AudioBufferMutex, &s.AudioBuffer
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	*buffer = make([]int16, 0)
+	if !manual {
+		s.vadSegmentEnds = nil
+	}
+	s.owner().LastActive = time.Now()
+}
+
 // CreateConversationItem creates a new conversation item in the session
 func (sm *SessionManager) CreateConversationItem(sessionID string, itemType string, role string) (*ConversationItem, error) {
+	return sm.CreateConversationItemWithID(sessionID, "", itemType, role)
+}
+
+// CreateConversationItemWithID creates a conversation item with an ID announced
+// earlier, e.g. by transcription deltas; an empty ID generates a new one
+func (sm *SessionManager) CreateConversationItemWithID(sessionID string, itemID string, itemType string, role string) (*ConversationItem, error) {
 	session, exists := sm.GetSession(sessionID)
 	if !exists {
 		return nil, fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	itemID := GenerateItemID()
+	if itemID == "" {
+		itemID = session.NewItemID()
+	}
 	item := &ConversationItem{
 		ID:        itemID,
 		Type:      itemType,
 		Status:    "in_progress",
 		Role:      role,
 		Content:   make([]interface{}, 0),
-		CreatedAt: time.Now(),
+		CreatedAt: session.Now(),
 	}
 
+	session.itemsMutex.Lock()
 	session.ConversationItems = append(session.ConversationItems, item)
+	session.itemsMutex.Unlock()
+	session.markChanged()
 	session.CurrentItemID = itemID
 	session.LastActive = time.Now()
 
@@ -542,10 +963,14 @@ func (sm *SessionManager) UpdateConversationItem(sessionID string, itemID string
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
+	session.itemsMutex.Lock()
+	defer session.itemsMutex.Unlock()
+
 	for _, item := range session.ConversationItems {
 		if item.ID == itemID {
 			updateFunc(item)
 			session.LastActive = time.Now()
+			session.markChanged()
 			return nil
 		}
 	}
@@ -560,6 +985,9 @@ func (sm *SessionManager) GetConversationItem(sessionID string, itemID string) (
 		return nil, fmt.Errorf("session not found: %s", sessionID)
 	}
 
+	session.itemsMutex.RLock()
+	defer session.itemsMutex.RUnlock()
+
 	for _, item := range session.ConversationItems {
 		if item.ID == itemID {
 			return item, nil
@@ -569,20 +997,121 @@ func (sm *SessionManager) GetConversationItem(sessionID string, itemID string) (
 	return nil, fmt.Errorf("conversation item not found: %s", itemID)
 }
 
+// inputSampleRate returns the sample rate of appended audio, 16kHz until the client sets one
+func (s *Session) inputSampleRate() int {
+	if s.InputAudioFormat.SampleRate <= 0 {
+		return 16000
+	}
+	return s.InputAudioFormat.SampleRate
+}
+
+// sessionNow returns the session clock, which is seeded in deterministic mode
+func (sm *SessionManager) sessionNow(sessionID string) time.Time {
+	if session, exists := sm.GetSession(sessionID); exists {
+		return session.Now()
+	}
+	return time.Now()
+}
+
 // MarkConversationItemCompleted marks a conversation item as completed
 func (sm *SessionManager) MarkConversationItemCompleted(sessionID string, itemID string) error {
 	return sm.UpdateConversationItem(sessionID, itemID, func(item *ConversationItem) {
 		item.Status = "completed"
-		now := time.Now()
+		now := sm.sessionNow(sessionID)
 		item.CompletedAt = &now
 	})
 }
 
+// SetConversationItemTranscript stores the recognized transcript on a conversation item
+func (sm *SessionManager) SetConversationItemTranscript(sessionID string, itemID string, transcript string) error {
+	return sm.UpdateConversationItem(sessionID, itemID, func(item *ConversationItem) {
+		item.Content = append(item.Content, map[string]interface{}{
+			"type":       "transcript",
+			"transcript": transcript,
+		})
+	})
+}
+
+// SetConversationItemWords stores the recognized words and their confidence on a
+// conversation item
+func (sm *SessionManager) SetConversationItemWords(sessionID string, itemID string, words []llm.TranscriptionWord) error {
+	return sm.UpdateConversationItem(sessionID, itemID, func(item *ConversationItem) {
+		item.Words = words
+	})
+}
+
+// TruncateConversation evicts the oldest finished conversation items until the session
+// is within the configured item and transcript size limits. In-progress items are never
+// evicted. It returns the evicted item IDs and the limit that triggered eviction.
+func (sm *SessionManager) TruncateConversation(sessionID string) ([]string, string, error) {
+	session, exists := sm.GetSession(sessionID)
+	if !exists {
+		return nil, "", fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	limits := sm.Config().Conversation
+	maxItems := limits.MaxItems
+	maxChars := limits.MaxTranscriptChars
+	if maxItems <= 0 && maxChars <= 0 {
+		return nil, "", nil
+	}
+
+	session.itemsMutex.Lock()
+	defer session.itemsMutex.Unlock()
+
+	totalChars := 0
+	for _, item := range session.ConversationItems {
+		totalChars += conversationItemTranscriptChars(item)
+	}
+
+	var evicted []string
+	reason := ""
+	kept := make([]*ConversationItem, 0, len(session.ConversationItems))
+	remaining := len(session.ConversationItems)
+	for _, item := range session.ConversationItems {
+		overItems := maxItems > 0 && remaining > maxItems
+		overChars := maxChars > 0 && totalChars > maxChars
+		if (overItems || overChars) && item.Status != "in_progress" {
+			evicted = append(evicted, item.ID)
+			remaining--
+			totalChars -= conversationItemTranscriptChars(item)
+			if reason == "" {
+				if overItems {
+					reason = "max_items"
+				} else {
+					reason = "max_transcript_chars"
+				}
+			}
+			continue
+		}
+		kept = append(kept, item)
+	}
+	session.ConversationItems = kept
+	if len(evicted) > 0 {
+		session.markChanged()
+	}
+
+	return evicted, reason, nil
+}
+
+// conversationItemTranscriptChars counts the transcript characters stored on an item
+func conversationItemTranscriptChars(item *ConversationItem) int {
+	chars := 0
+	for _, content := range item.Content {
+		if entry, ok := content.(map[string]interface{}); ok {
+			if transcript, ok := entry["transcript"].(string); ok {
+				chars += len([]rune(transcript))
+			}
+		}
+	}
+	return chars
+}
+
 // MarkConversationItemFailed marks a conversation item as failed
 func (sm *SessionManager) MarkConversationItemFailed(sessionID string, itemID string, errorMsg string) error {
 	return sm.UpdateConversationItem(sessionID, itemID, func(item *ConversationItem) {
 		item.Status = "failed"
-		now := time.Now()
+		now := sm.sessionNow(sessionID)
 		item.CompletedAt = &now
 		errorContent := map[string]interface{}{
 			"type": "error",
@@ -599,8 +1128,12 @@ func (sm *SessionManager) UpdateHeartbeat(sessionID string) error {
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
+	// Recognition marks the session active under itemsMutex while long-poll requests
+	// of the same session are served
+	session.itemsMutex.Lock()
 	session.LastHeartbeat = time.Now()
 	session.LastActive = time.Now()
+	session.itemsMutex.Unlock()
 
 	return nil
 }
@@ -612,6 +1145,7 @@ func (sm *SessionManager) GetSessionStats() map[string]interface{} {
 
 	stats := make(map[string]interface{})
 	stats["total_sessions"] = len(sm.sessions)
+	stats["gc"] = sm.GCStats()
 
 	modalityCount := make(map[string]int)
 	for _, session := range sm.sessions {
@@ -619,5 +1153,55 @@ func (sm *SessionManager) GetSessionStats() map[string]interface{} {
 	}
 	stats["sessions_by_modality"] = modalityCount
 
+	variantCount := make(map[string]int)
+	for _, session := range sm.sessions {
+		if session.Variant != "" {
+			variantCount[session.Variant]++
+		}
+	}
+	stats["sessions_by_variant"] = variantCount
+
+	var talkTimeMs int64
+	for _, session := range sm.sessions {
+		talkTimeMs += session.Analytics(sm.vadSampleRate()).TalkTimeMs
+	}
+	stats["talk_time_ms"] = talkTimeMs
+
+	var utterances, lowQuality int
+	var scoreSum float64
+	for _, session := range sm.sessions {
+		if quality := session.quality.Snapshot(); quality != nil {
+			utterances += quality.Utterances
+			lowQuality += quality.LowQuality
+			scoreSum += quality.AverageScore * float64(quality.Utterances)
+		}
+	}
+	audioQuality := map[string]interface{}{
+		"utterances":  utterances,
+		"low_quality": lowQuality,
+	}
+	if utterances > 0 {
+		audioQuality["average_score"] = scoreSum / float64(utterances)
+	}
+	stats["audio_quality"] = audioQuality
+
+	var inbound, inboundAudio, outbound, outboundTranscript, audioMs int64
+	for _, session := range sm.sessions {
+		bandwidth := session.Bandwidth()
+		inbound += bandwidth.InboundBytes
+		inboundAudio += bandwidth.InboundAudioBytes
+		outbound += bandwidth.OutboundBytes
+		outboundTranscript += bandwidth.OutboundTranscriptBytes
+		audioMs += bandwidth.AudioMs
+	}
+	stats["bandwidth"] = map[string]interface{}{
+		"inbound_bytes":             inbound,
+		"inbound_audio_bytes":       inboundAudio,
+		"outbound_bytes":            outbound,
+		"outbound_transcript_bytes": outboundTranscript,
+		"audio_ms":                  audioMs,
+	}
+	stats["model_pools"] = modelPools.Stats()
+
 	return stats
 }
\ No newline at end of file
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/errcode"
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// A WebSocket session whose connection drops without a close from the client is
+// parked: it keeps recognizing the speech it has and buffers its events for the grace
+// period. A client reconnecting opens a new connection and sends session.resume as
+// its first event; the connection then serves the parked session, and the events the
+// client missed are replayed before any new one.
+
+// sessionResume holds the settings of resume
+type sessionResume struct {
+	grace      time.Duration
+	bufferSize int
+}
+
+// newSessionResume reads the resume settings, applying their defaults
+func newSessionResume(cfg *config.Config) *sessionResume {
+	r := &sessionResume{
+		grace:      time.Duration(cfg.Resume.GraceSeconds) * time.Second,
+		bufferSize: cfg.Resume.ReplayBuffer,
+	}
+	if r.grace <= 0 {
+		r.grace = 30 * time.Second
+	}
+	if r.bufferSize <= 0 {
+		r.bufferSize = 256
+	}
+	return r
+}
+
+// replayBuffer keeps the last events sent to a session with their IDs, guarded by the
+// session mutex
+type replayBuffer struct {
+	events []replayedEvent
+	limit  int
+}
+
+type replayedEvent struct {
+	id   string
+	data []byte
+}
+
+func newReplayBuffer(limit int) *replayBuffer {
+	return &replayBuffer{limit: limit}
+}
+
+// add appends an event, dropping the oldest one beyond the limit
+func (b *replayBuffer) add(id string, data []byte) {
+	b.events = append(b.events, replayedEvent{id: id, data: data})
+	if len(b.events) > b.limit {
+		b.events = b.events[len(b.events)-b.limit:]
+	}
+}
+
+// after returns the events following the one with ID id. When the buffer no longer
+// holds that event, or id is empty, it returns every buffered event and reports
+// whether events may be missing.
+func (b *replayBuffer) after(id string) ([][]byte, bool) {
+	start, lost := 0, id != ""
+	if id != "" {
+		for i := len(b.events) - 1; i >= 0; i-- {
+			if b.events[i].id == id {
+				start, lost = i+1, false
+				break
+			}
+		}
+	}
+
+	events := make([][]byte, 0, len(b.events)-start)
+	for _, event := range b.events[start:] {
+		events = append(events, event.data)
+	}
+	return events, lost
+}
+
+// eventID returns the ID of a server event, empty for an event without one
+func eventID(event interface{}) string {
+	if e, ok := event.(interface{ baseEvent() BaseEvent }); ok {
+		return e.baseEvent().EventID
+	}
+	return ""
+}
+
+func (e BaseEvent) baseEvent() BaseEvent { return e }
+
+// resumeDropped reports whether a read error ending a connection lets its session be
+// resumed: the connection dropped, the client did not close it
+func resumeDropped(err error) bool {
+	return !websocket.IsCloseError(err, websocket.CloseNormalClosure)
+}
+
+// parkSession keeps a session whose connection of generation gen dropped for the
+// grace period and reports whether it did. Its events are buffered until the client
+// resumes it; without a resume within the grace period it ends as timed out.
+func (s *OpenAIService) parkSession(session *Session, gen int64) bool {
+	session.mutex.Lock()
+	if session.replay == nil || session.ended || session.evicted || session.transport != gen || session.outbound.closing {
+		session.mutex.Unlock()
+		return false
+	}
+	session.Conn = nil
+	session.parked = true
+	session.outbound.reset()
+	parkedGen := session.attachTransport()
+	session.mutex.Unlock()
+
+	grace := s.resume.grace
+	time.AfterFunc(grace, func() {
+		if s.detachTransport(session, parkedGen, SessionEndTimeout) {
+			session.Logger().WithFields(logrus.Fields{
+				"component": "svc_openai_api ",
+				"action":    "session_resume_expired",
+				"sessionID": session.ID,
+			}).Info("Parked session was not resumed, ending it")
+		}
+	})
+
+	session.Logger().WithFields(logrus.Fields{
+		"component": "svc_openai_api ",
+		"action":    "session_parked",
+		"sessionID": session.ID,
+		"graceMs":   grace.Milliseconds(),
+	}).Info("Connection dropped, session waits for its client to resume")
+	return true
+}
+
+// resumeSession handles the first message of the connection serving fresh, the
+// session created for it. When it is a session.resume event naming a resumable
+// session with its token, the connection takes that session over: session.resumed and
+// the events after last_event_id are sent, and fresh ends. It returns the session the
+// connection serves from now on with its transport generation, nil when the message
+// is no session.resume event or the resume was refused; handled reports whether the
+// message was consumed.
+func (s *OpenAIService) resumeSession(conn *websocket.Conn, fresh *Session, freshGen int64, message []byte) (resumed *Session, gen int64, handled bool) {
+	event, err := s.eventParser.ParseEvent(message)
+	if err != nil {
+		return nil, 0, false
+	}
+	resume, ok := event.(*SessionResumeEvent)
+	if !ok {
+		return nil, 0, false
+	}
+	if err := s.eventParser.ValidateEvent(resume); err != nil {
+		s.refuseResume(fresh, err.Error())
+		return nil, 0, true
+	}
+	if s.resume == nil {
+		s.refuseResume(fresh, "session resume is disabled")
+		return nil, 0, true
+	}
+
+	target, exists := s.sessionManager.GetSession(resume.SessionID)
+	if !exists || target == fresh || target.Tenant != fresh.Tenant {
+		s.refuseResume(fresh, "session not found")
+		return nil, 0, true
+	}
+	if subtle.ConstantTimeCompare([]byte(resume.ResumeToken), []byte(target.ResumeToken)) != 1 {
+		s.refuseResume(fresh, "invalid resume token")
+		return nil, 0, true
+	}
+
+	target.mutex.Lock()
+	if target.ended || target.poll != nil || target.replay == nil {
+		target.mutex.Unlock()
+		s.refuseResume(fresh, "session is not resumable")
+		return nil, 0, true
+	}
+	if old := target.Conn; old != nil {
+		// The client reconnected before the server noticed its old connection drop
+		message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session_resumed")
+		old.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+		old.Close()
+	}
+	target.Conn = conn
+	target.parked = false
+	gen = target.attachTransport()
+	target.outbound.reset()
+
+	// Still holding the mutex, so the missed events are queued before any new one
+	events, lost := target.replay.after(resume.LastEventID)
+	resumedEvent := &SessionResumedEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeSessionResumed,
+			EventID:   target.NewEventID(),
+			SessionID: target.ID,
+		},
+		Replayed:   len(events),
+		EventsLost: lost,
+	}
+	if data, err := json.Marshal(resumedEvent); err == nil {
+		s.sessionManager.writeEvent(target, resumedEvent.EventID, data)
+	}
+	target.outbound.pushAll(events)
+	target.mutex.Unlock()
+
+	// The session created for the connection is not needed any more, nor are its analytics
+	fresh.mutex.Lock()
+	fresh.Conn = nil
+	fresh.mutex.Unlock()
+	fresh.analyticsSent.Store(true)
+	s.detachTransport(fresh, freshGen, SessionEndClosed)
+
+	target.Logger().WithFields(logrus.Fields{
+		"component":  "svc_openai_api ",
+		"action":     "session_resumed",
+		"sessionID":  target.ID,
+		"replayed":   len(events),
+		"eventsLost": lost,
+	}).Info("Client resumed its session")
+	return target, gen, true
+}
+
+// refuseResume answers a session.resume event that cannot be honored; the client
+// stays on the session created for its connection
+func (s *OpenAIService) refuseResume(session *Session, message string) {
+	errorEvent := &ErrorEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeError,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+	}
+	errorEvent.Error.Type = "invalid_request_error"
+	errorEvent.Error.Code = "session_not_resumable"
+	errorEvent.Error.Category = errcode.InvalidRequest
+	errorEvent.Error.Message = message
+	errorEvent.Error.Param = "session_id"
+	s.sessionManager.SendEvent(session, errorEvent)
+
+	logger.WithFields(logrus.Fields{
+		"component": "svc_openai_api ",
+		"action":    "session_resume_refused",
+		"sessionID": session.ID,
+		"reason":    message,
+	}).Warn("Refused to resume session")
+}
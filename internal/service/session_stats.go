@@ -0,0 +1,116 @@
+package service
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SessionStats reports the audio a session received and what recognition made of it
+type SessionStats struct {
+	DurationMs       int64   `json:"duration_ms"`
+	AudioSeconds     float64 `json:"audio_seconds"`  // input audio received
+	SpeechSeconds    float64 `json:"speech_seconds"` // speech the VAD detected in it
+	ASRCalls         int64   `json:"asr_calls"`      // requests for utterances or their chunks, interim and sliding window recognition
+	ASRErrors        int64   `json:"asr_errors"`
+	AvgRecognitionMs float64 `json:"avg_recognition_ms"` // mean duration of the ASR calls
+	// Input samples discarded before recognition: over the client's audio rate limit
+	// or in chunks failing their checksum
+	DroppedSamples int64 `json:"dropped_samples"`
+}
+
+// statsCounter accumulates the recognition counters of a session, safe for concurrent
+// use. Channel streams of multi-channel sessions count into their session's counter.
+type statsCounter struct {
+	speechMicros   atomic.Int64
+	asrCalls       atomic.Int64
+	asrErrors      atomic.Int64
+	asrMicros      atomic.Int64
+	droppedSamples atomic.Int64
+	reportSeconds  atomic.Int64 // interval of session.stats events, 0 disables
+}
+
+// recordSpeech counts a speech segment the VAD detected
+func (s *Session) recordSpeech(d time.Duration) {
+	s.owner().stats.speechMicros.Add(d.Microseconds())
+}
+
+// recordASRCall counts a recognition that took d
+func (s *Session) recordASRCall(d time.Duration, err error) {
+	counter := &s.owner().stats
+	counter.asrCalls.Add(1)
+	counter.asrMicros.Add(d.Microseconds())
+	if err != nil {
+		counter.asrErrors.Add(1)
+	}
+}
+
+// recordDroppedSamples counts input samples discarded before recognition
+func (s *Session) recordDroppedSamples(samples int) {
+	s.owner().stats.droppedSamples.Add(int64(samples))
+}
+
+// encodedSamples returns the samples in bytes of audio in an input format, 0 for Opus
+// whose frames have to be decoded to tell
+func encodedSamples(format string, bytes int) int {
+	switch {
+	case format == InputAudioFormatOpus:
+		return 0
+	case isG711Format(format):
+		return bytes
+	default:
+		return bytes / 2
+	}
+}
+
+// Stats returns the recognition counters of the session so far
+func (s *Session) Stats() SessionStats {
+	counter := &s.owner().stats
+	stats := SessionStats{
+		DurationMs:     time.Since(s.CreatedAt).Milliseconds(),
+		AudioSeconds:   float64(s.owner().bandwidth.audioMicros.Load()) / 1e6,
+		SpeechSeconds:  float64(counter.speechMicros.Load()) / 1e6,
+		ASRCalls:       counter.asrCalls.Load(),
+		ASRErrors:      counter.asrErrors.Load(),
+		DroppedSamples: counter.droppedSamples.Load(),
+	}
+	if stats.ASRCalls > 0 {
+		stats.AvgRecognitionMs = float64(counter.asrMicros.Load()) / 1000 / float64(stats.ASRCalls)
+	}
+	return stats
+}
+
+// SetStatsReportSeconds sets the interval of session.stats events, 0 disables them
+func (s *Session) SetStatsReportSeconds(seconds int) {
+	s.stats.reportSeconds.Store(int64(max(0, seconds)))
+}
+
+// sendSessionStats sends session.stats, answering the session.stats.request of
+// requestEventID when it is not empty
+func (s *OpenAIService) sendSessionStats(session *Session, requestEventID string) error {
+	statsEvent := &SessionStatsEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeSessionStats,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		RequestEventID: requestEventID,
+		Stats:          session.Stats(),
+	}
+	if err := s.sessionManager.SendEvent(session, statsEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "ws_event_send",
+			"action":    "send_session_stats_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Warn("Failed to send session.stats event")
+		return err
+	}
+	return nil
+}
+
+// handleSessionStatsRequest processes session.stats.request events
+func (s *OpenAIService) handleSessionStatsRequest(session *Session, event *SessionStatsRequestEvent) error {
+	return s.sendSessionStats(session, event.EventID)
+}
@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/cluster"
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/textformat"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSessionStoreTTL          = 5 * time.Minute
+	defaultSessionStoreSyncInterval = 2 * time.Second
+)
+
+// ErrSessionNotStored is returned by SessionStore.Load for unknown or expired sessions
+var ErrSessionNotStored = errors.New("session not stored")
+
+// SessionStore persists the state of sessions outside the process, so a client whose
+// connection dropped can resume its session after a restart or on another instance.
+// Records expire once no instance has refreshed them for the store's TTL.
+type SessionStore interface {
+	// Save writes the record, making instance the one serving the session
+	Save(ctx context.Context, record *SessionRecord) error
+	// Heartbeat refreshes the record while instance still serves the session
+	Heartbeat(ctx context.Context, sessionID, instance string, at time.Time) error
+	// Load returns the record of a session, ErrSessionNotStored when there is none
+	Load(ctx context.Context, sessionID string) (*SessionRecord, error)
+	// Delete removes the record unless another instance has taken the session over
+	Delete(ctx context.Context, sessionID, instance string) error
+}
+
+// SessionRecord is the persisted state of a session: its configuration, conversation
+// and heartbeat. Audio buffers and VAD state are not kept, speech not committed
+// before the disconnect is lost.
+type SessionRecord struct {
+	ID            string    `json:"id"`
+	ResumeToken   string    `json:"resume_token"`
+	Instance      string    `json:"instance"` // instance serving the session
+	Tenant        string    `json:"tenant,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastActive    time.Time `json:"last_active"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+
+	Modality     string `json:"modality"`
+	Instructions string `json:"instructions,omitempty"`
+	Voice        string `json:"voice,omitempty"`
+	Pipeline     string `json:"pipeline,omitempty"`
+
+	InputAudioFormat struct {
+		Type       string `json:"type"`
+		SampleRate int    `json:"sample_rate"`
+		Channels   int    `json:"channels"`
+	} `json:"input_audio_format"`
+	InputEncoding           string `json:"input_encoding,omitempty"`
+	InputAudioTranscription struct {
+		Model          string   `json:"model"`
+		Language       string   `json:"language"`
+		InterimResults bool     `json:"interim_results,omitempty"`
+		DeltaMode      string   `json:"delta_mode,omitempty"` // see DeltaModeCompact
+		Hints          []string `json:"hints,omitempty"`      // custom vocabulary, see hints.go
+	} `json:"input_audio_transcription"`
+	TurnDetection struct {
+		Type              string  `json:"type"`
+		Threshold         float32 `json:"threshold"`
+		PrefixPaddingMs   int     `json:"prefix_padding_ms"`
+		SilenceDurationMs int     `json:"silence_duration_ms"`
+	} `json:"turn_detection"`
+	TurnDetectionSet bool                  `json:"turn_detection_set,omitempty"` // applied to the VAD
+	NoiseReduction   *NoiseReductionConfig `json:"input_audio_noise_reduction,omitempty"`
+	GainControl      *GainControlConfig    `json:"input_audio_gain_control,omitempty"`
+	TranscriptFormat textformat.Options    `json:"transcript_format"`
+	Captions         CaptionConfig         `json:"captions"`
+	Recognition      RecognitionConfig     `json:"recognition"`
+
+	ConversationItems []*ConversationItem `json:"conversation_items,omitempty"`
+}
+
+// sessionStoreSync keeps the records of the sessions served by this instance up to date
+type sessionStoreSync struct {
+	store    SessionStore
+	instance string
+	interval time.Duration
+}
+
+// newSessionStore creates the store of session_store.type, nil keeps sessions in
+// process only
+func newSessionStore(cfg *config.Config) *sessionStoreSync {
+	settings := cfg.SessionStore
+	ttl := time.Duration(settings.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultSessionStoreTTL
+	}
+
+	var store SessionStore
+	switch settings.Type {
+	case "memory":
+		store = newMemorySessionStore(ttl)
+	case "redis":
+		prefix := settings.KeyPrefix
+		if prefix == "" {
+			prefix = "stt"
+		}
+		client := cluster.NewRedisClient(settings.RedisAddr, settings.RedisPassword, settings.RedisDB)
+		store = NewRedisSessionStore(client, prefix, ttl)
+	default:
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "unknown_session_store",
+			"type":      settings.Type,
+		}).Warn("Unknown session store type, sessions are kept in process only")
+		return nil
+	}
+
+	instance := cfg.Cluster.InstanceID
+	if instance == "" {
+		instance = cluster.DefaultInstanceID()
+	}
+	interval := time.Duration(settings.SyncIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultSessionStoreSyncInterval
+	}
+	return &sessionStoreSync{store: store, instance: instance, interval: interval}
+}
+
+// markChanged records a change of the session's persisted state
+func (s *Session) markChanged() {
+	s.storeChanges.Add(1)
+}
+
+// listSessions returns the live sessions
+func (sm *SessionManager) listSessions() []*Session {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	sessions := make([]*Session, 0, len(sm.sessions))
+	for _, session := range sm.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// sessionRecord captures the persisted state of a session. Items are copied so the
+// record can be encoded while the session goes on.
+func (sm *SessionManager) sessionRecord(session *Session, instance string) *SessionRecord {
+	record := &SessionRecord{Instance: instance}
+
+	sm.mutex.RLock()
+	record.ID = session.ID
+	record.ResumeToken = session.ResumeToken
+	record.Tenant = session.Tenant
+	record.CreatedAt = session.CreatedAt
+	record.LastActive = session.LastActive
+	record.LastHeartbeat = session.LastHeartbeat
+	record.Modality = session.Modality
+	record.Instructions = session.Instructions
+	record.Voice = session.Voice
+	record.Pipeline = session.Pipeline
+	record.InputAudioFormat = session.InputAudioFormat
+	record.InputEncoding = session.InputEncoding
+	record.InputAudioTranscription = session.InputAudioTranscription
+	record.TurnDetection = session.TurnDetection
+	record.TurnDetectionSet = session.turnDetectionSet
+	record.NoiseReduction = session.NoiseReduction
+	record.GainControl = session.GainControl
+	record.TranscriptFormat = session.TranscriptFormat
+	record.Captions = session.Captions
+	record.Recognition = session.Recognition
+	sm.mutex.RUnlock()
+
+	session.itemsMutex.RLock()
+	record.ConversationItems = cloneItems(session.ConversationItems)
+	session.itemsMutex.RUnlock()
+	return record
+}
+
+// cloneItems copies conversation items. The maps of their content are shared, they
+// are never changed once added.
+func cloneItems(items []*ConversationItem) []*ConversationItem {
+	cloned := make([]*ConversationItem, 0, len(items))
+	for _, item := range items {
+		copied := *item
+		copied.Content = slices.Clone(item.Content)
+		copied.Words = slices.Clone(item.Words)
+		cloned = append(cloned, &copied)
+	}
+	return cloned
+}
+
+// sessionStoreLoop saves the sessions changed since the last pass and refreshes the
+// heartbeat of the others until ctx is done
+func (s *OpenAIService) sessionStoreLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.sessionStore.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, session := range s.sessionManager.listSessions() {
+			s.syncSession(ctx, session)
+		}
+	}
+}
+
+// syncSession persists a session, only its heartbeat when nothing else changed.
+// Only the store loop calls it, so storedChanges needs no lock.
+func (s *OpenAIService) syncSession(ctx context.Context, session *Session) {
+	store := s.sessionStore
+	changes := session.storeChanges.Load()
+
+	var err error
+	if changes != session.storedChanges {
+		err = store.store.Save(ctx, s.sessionManager.sessionRecord(session, store.instance))
+		if err == nil {
+			session.storedChanges = changes
+		}
+	} else {
+		err = store.store.Heartbeat(ctx, session.ID, store.instance, session.LastHeartbeat)
+	}
+	if err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "mg_session_ctrl",
+			"action":    "session_store_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Warn("Failed to persist session state")
+	}
+}
+
+// forgetStoredSession deletes the record of a session the server ended. Sessions whose
+// connection closed keep their record until it expires, so the client can resume.
+func (s *OpenAIService) forgetStoredSession(session *Session, reason string) {
+	if s.sessionStore == nil || reason != SessionEndTimeout {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := s.sessionStore.store.Delete(ctx, session.ID, s.sessionStore.instance); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "mg_session_ctrl",
+			"action":    "session_store_delete_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Warn("Failed to delete persisted session state")
+	}
+}
+
+// storedSession returns the record of a session that is not live on this instance
+// when the request's resume token matches, writing the error response otherwise
+func (s *OpenAIService) storedSession(c *gin.Context, sessionID string, principal *Principal) (*SessionRecord, bool) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	record, err := s.sessionStore.store.Load(ctx, sessionID)
+	if errors.Is(err, ErrSessionNotStored) {
+		openAIError(c, http.StatusNotFound, "invalid_request_error", "session not found", "resume")
+		return nil, false
+	}
+	if err != nil {
+		openAIError(c, http.StatusServiceUnavailable, "server_error", "session store unavailable: "+err.Error(), "")
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(resumeToken(c)), []byte(record.ResumeToken)) != 1 {
+		openAIError(c, http.StatusUnauthorized, "invalid_request_error", "invalid resume token", "resume_token")
+		return nil, false
+	}
+	if principal != nil && record.Tenant != "" && record.Tenant != principal.Tenant {
+		openAIError(c, http.StatusNotFound, "invalid_request_error", "session not found", "resume")
+		return nil, false
+	}
+	return record, true
+}
+
+// restoreSession applies a stored record to the session resuming it. The pipeline is
+// switched first so the stored settings take precedence, as in session.update.
+func (s *OpenAIService) restoreSession(session *Session, record *SessionRecord) {
+	s.sessionManager.UpdateSession(session.ID, func(sess *Session) {
+		if record.Pipeline != "" && record.Pipeline != sess.Pipeline {
+			if err := applyPipeline(sess, s.appConfig(), record.Pipeline); err == nil {
+				leaveExperiment(sess)
+			}
+		}
+		sess.CreatedAt = record.CreatedAt
+		sess.Modality = record.Modality
+		sess.Instructions = record.Instructions
+		sess.Voice = record.Voice
+		sess.InputAudioFormat = record.InputAudioFormat
+		sess.InputEncoding = record.InputEncoding
+		sess.InputAudioTranscription = record.InputAudioTranscription
+		sess.TurnDetection = record.TurnDetection
+		if record.TurnDetectionSet {
+			applyTurnDetection(sess, s.appConfig())
+		}
+		if record.NoiseReduction != nil {
+			sess.NoiseReduction = record.NoiseReduction
+			applyNoiseReduction(sess, s.appConfig())
+		}
+		if record.GainControl != nil {
+			sess.GainControl = record.GainControl
+			applyGainControl(sess, s.appConfig())
+		}
+		applyChannels(sess, s.appConfig())
+		sess.TranscriptFormat = record.TranscriptFormat
+		sess.Captions = record.Captions
+		sess.Recognition = record.Recognition
+	})
+
+	session.itemsMutex.Lock()
+	session.ConversationItems = record.ConversationItems
+	session.itemsMutex.Unlock()
+	session.markChanged() // saved at once, this instance serves the session now
+
+	session.Logger().WithFields(logrus.Fields{
+		"component":    "mg_session_ctrl",
+		"action":       "session_restored",
+		"sessionID":    session.ID,
+		"fromInstance": record.Instance,
+		"items":        len(record.ConversationItems),
+	}).Info("Session restored from the session store")
+}
+
+// memorySessionStore keeps records in process. Sessions survive a dropped connection
+// but not a restart, and only resume on the same instance.
+type memorySessionStore struct {
+	ttl time.Duration
+
+	mutex   sync.Mutex
+	records map[string]*memoryRecord
+}
+
+type memoryRecord struct {
+	record  SessionRecord
+	expires time.Time
+}
+
+func newMemorySessionStore(ttl time.Duration) *memorySessionStore {
+	return &memorySessionStore{ttl: ttl, records: make(map[string]*memoryRecord)}
+}
+
+func (m *memorySessionStore) Save(_ context.Context, record *SessionRecord) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	for id, stored := range m.records {
+		if now.After(stored.expires) {
+			delete(m.records, id)
+		}
+	}
+	m.records[record.ID] = &memoryRecord{record: *record, expires: now.Add(m.ttl)}
+	return nil
+}
+
+func (m *memorySessionStore) Heartbeat(_ context.Context, sessionID, instance string, at time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if stored, exists := m.records[sessionID]; exists && stored.record.Instance == instance {
+		stored.record.LastHeartbeat = at
+		stored.expires = time.Now().Add(m.ttl)
+	}
+	return nil
+}
+
+func (m *memorySessionStore) Load(_ context.Context, sessionID string) (*SessionRecord, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored, exists := m.records[sessionID]
+	if !exists || time.Now().After(stored.expires) {
+		return nil, ErrSessionNotStored
+	}
+	record := stored.record
+	record.ConversationItems = cloneItems(record.ConversationItems)
+	return &record, nil
+}
+
+func (m *memorySessionStore) Delete(_ context.Context, sessionID, instance string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if stored, exists := m.records[sessionID]; exists && stored.record.Instance == instance {
+		delete(m.records, sessionID)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-restream/stt/pkg/cluster"
+)
+
+// A session is stored as a hash with the fields record, instance and heartbeat, so
+// heartbeats do not rewrite the record.
+
+// saveSessionScript writes the record and takes the session over
+const saveSessionScript = `redis.call("hset", KEYS[1], "record", ARGV[1], "instance", ARGV[2], "heartbeat", ARGV[3]) return redis.call("pexpire", KEYS[1], ARGV[4])`
+
+// heartbeatSessionScript refreshes the session only while this instance serves it
+const heartbeatSessionScript = `if redis.call("hget", KEYS[1], "instance") == ARGV[1] then redis.call("hset", KEYS[1], "heartbeat", ARGV[2]) return redis.call("pexpire", KEYS[1], ARGV[3]) else return 0 end`
+
+// deleteSessionScript deletes the session only while this instance serves it
+const deleteSessionScript = `if redis.call("hget", KEYS[1], "instance") == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// RedisSessionStore implements SessionStore in Redis, shared by every instance behind
+// a load balancer
+type RedisSessionStore struct {
+	client *cluster.RedisClient
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisSessionStore creates a store keeping sessions at <prefix>:session:<id> for
+// ttl after their last save or heartbeat
+func NewRedisSessionStore(client *cluster.RedisClient, prefix string, ttl time.Duration) *RedisSessionStore {
+	return &RedisSessionStore{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (r *RedisSessionStore) key(sessionID string) string {
+	return r.prefix + ":session:" + sessionID
+}
+
+func (r *RedisSessionStore) Save(ctx context.Context, record *SessionRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.Do(ctx, "EVAL", saveSessionScript, "1", r.key(record.ID),
+		string(data), record.Instance, strconv.FormatInt(record.LastHeartbeat.UnixMilli(), 10),
+		strconv.FormatInt(r.ttl.Milliseconds(), 10))
+	return err
+}
+
+func (r *RedisSessionStore) Heartbeat(ctx context.Context, sessionID, instance string, at time.Time) error {
+	_, err := r.client.Do(ctx, "EVAL", heartbeatSessionScript, "1", r.key(sessionID),
+		instance, strconv.FormatInt(at.UnixMilli(), 10), strconv.FormatInt(r.ttl.Milliseconds(), 10))
+	return err
+}
+
+func (r *RedisSessionStore) Load(ctx context.Context, sessionID string) (*SessionRecord, error) {
+	reply, err := r.client.Do(ctx, "HMGET", r.key(sessionID), "record", "heartbeat")
+	if err != nil {
+		return nil, err
+	}
+	fields, _ := reply.([]interface{})
+	if len(fields) != 2 || fields[0] == nil {
+		return nil, ErrSessionNotStored
+	}
+
+	var record SessionRecord
+	if err := json.Unmarshal([]byte(fields[0].(string)), &record); err != nil {
+		return nil, fmt.Errorf("invalid session record: %v", err)
+	}
+	// The heartbeat field is newer than the one saved with the record
+	if heartbeat, ok := fields[1].(string); ok {
+		if ms, err := strconv.ParseInt(heartbeat, 10, 64); err == nil {
+			record.LastHeartbeat = time.UnixMilli(ms)
+		}
+	}
+	return &record, nil
+}
+
+func (r *RedisSessionStore) Delete(ctx context.Context, sessionID, instance string) error {
+	_, err := r.client.Do(ctx, "EVAL", deleteSessionScript, "1", r.key(sessionID), instance)
+	return err
+}
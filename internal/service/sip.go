@@ -0,0 +1,468 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/rtp"
+	"github.com/go-restream/stt/pkg/sip"
+
+	"github.com/sirupsen/logrus"
+)
+
+// With sip.enable the server is a receive-only SIP user agent a PBX forks the audio of
+// calls to. An INVITE is answered right away with a port of the RTP range for every
+// audio stream of its offer that carries PCMU or PCMA, and each stream is transcribed
+// in a session of its own, like an ingested stream: results reach the event sink and
+// the transcript history. BYE, or RTP stopping for rtp_timeout_seconds, ends the call.
+
+// sipAllow lists the methods the user agent answers
+const sipAllow = "INVITE, ACK, BYE, CANCEL, OPTIONS"
+
+// sipServer answers the SIP requests of the PBXs and tracks their calls
+type sipServer struct {
+	conn       *net.UDPConn
+	publicIP   string
+	allowed    []*net.IPNet
+	ports      *rtpPorts
+	rtpTimeout time.Duration
+	settings   sipSessionSettings
+
+	mutex sync.Mutex
+	calls map[string]*sipCall // by Call-ID
+}
+
+// sipSessionSettings are the transcription settings of the sessions of calls
+type sipSessionSettings struct {
+	language, model, pipeline string
+}
+
+// sipCall is an answered call with the RTP streams it sends
+type sipCall struct {
+	id      string
+	toTag   string
+	answer  []byte // SDP answer, repeated for retransmitted INVITEs and re-INVITEs
+	streams []*rtpStream
+
+	endOnce sync.Once
+	ended   chan struct{}
+}
+
+// rtpStream is an audio stream of a call, received on a port of the RTP range
+type rtpStream struct {
+	conn        *net.UDPConn
+	payloadType int
+	session     *Session
+}
+
+// rtpPorts hands out the even ports of the RTP range, the odd ones left to RTCP
+type rtpPorts struct {
+	mutex    sync.Mutex
+	min, max int
+	next     int
+}
+
+// listen binds the next free port of the range
+func (p *rtpPorts) listen() (*net.UDPConn, int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for tries := 0; tries <= (p.max-p.min)/2; tries++ {
+		if p.next < p.min || p.next > p.max {
+			p.next = p.min + p.min%2
+		}
+		port := p.next
+		p.next += 2
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+		if err == nil {
+			return conn, port, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("no free RTP port in %d-%d", p.min, p.max)
+}
+
+// startSIP listens for the SIP requests of the PBXs until ctx ends
+func (s *OpenAIService) startSIP(ctx context.Context, cfg *config.Config) error {
+	server := &sipServer{
+		publicIP:   cfg.SIP.PublicIP,
+		ports:      &rtpPorts{min: cfg.SIP.RTPPortMin, max: cfg.SIP.RTPPortMax},
+		rtpTimeout: time.Duration(cfg.SIP.RTPTimeoutSeconds) * time.Second,
+		settings:   sipSessionSettings{cfg.SIP.Language, cfg.SIP.Model, cfg.SIP.Pipeline},
+		calls:      make(map[string]*sipCall),
+	}
+	if server.ports.min <= 0 {
+		server.ports.min = 20000
+	}
+	if server.ports.max < server.ports.min {
+		server.ports.max = server.ports.min + 999
+	}
+	if server.rtpTimeout <= 0 {
+		server.rtpTimeout = 30 * time.Second
+	}
+	for _, source := range cfg.SIP.AllowedSources {
+		if !strings.Contains(source, "/") {
+			if strings.Contains(source, ":") {
+				source += "/128"
+			} else {
+				source += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(source)
+		if err != nil {
+			return fmt.Errorf("invalid sip.allowed_sources entry %q: %w", source, err)
+		}
+		server.allowed = append(server.allowed, network)
+	}
+
+	listen := cfg.SIP.Listen
+	if listen == "" {
+		listen = ":5060"
+	}
+	addr, err := net.ResolveUDPAddr("udp", listen)
+	if err != nil {
+		return fmt.Errorf("invalid sip.listen %q: %w", listen, err)
+	}
+	if server.conn, err = net.ListenUDP("udp", addr); err != nil {
+		return err
+	}
+	s.sip = server
+
+	go func() {
+		<-ctx.Done()
+		server.conn.Close()
+		for _, call := range server.activeCalls() {
+			s.endSIPCall(call, "shutdown")
+		}
+	}()
+	go s.serveSIP(server)
+
+	logger.WithFields(logrus.Fields{
+		"component": "svc_sip",
+		"action":    "sip_listening",
+		"listen":    server.conn.LocalAddr().String(),
+		"rtpPorts":  fmt.Sprintf("%d-%d", server.ports.min, server.ports.max),
+	}).Info("SIP listener started")
+	return nil
+}
+
+// serveSIP answers the requests arriving at the SIP socket until it is closed
+func (s *OpenAIService) serveSIP(server *sipServer) {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := server.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		req, err := sip.Parse(buf[:n])
+		if err != nil || !req.IsRequest() {
+			// Keep-alives, responses and noise
+			continue
+		}
+		if !server.allows(addr.IP) {
+			server.respond(req, addr, 403, "Forbidden", "")
+			continue
+		}
+
+		switch req.Method {
+		case sip.MethodInvite:
+			s.handleSIPInvite(server, req, addr)
+		case sip.MethodAck:
+		case sip.MethodBye:
+			call := server.call(req.Get("Call-ID"))
+			if call == nil {
+				server.respond(req, addr, 481, "Call/Transaction Does Not Exist", "")
+				continue
+			}
+			server.respond(req, addr, 200, "OK", call.toTag)
+			s.endSIPCall(call, "bye")
+		case sip.MethodCancel:
+			// Calls are answered on the INVITE, there is nothing left to cancel
+			server.respond(req, addr, 200, "OK", "")
+		case sip.MethodOptions:
+			server.respond(req, addr, 200, "OK", "")
+		default:
+			server.respond(req, addr, 405, "Method Not Allowed", "")
+		}
+	}
+}
+
+// handleSIPInvite answers an INVITE with an RTP port and a session for every audio
+// stream it offers in G.711
+func (s *OpenAIService) handleSIPInvite(server *sipServer, req *sip.Message, addr *net.UDPAddr) {
+	callID := req.Get("Call-ID")
+	if call := server.call(callID); call != nil {
+		server.answer(req, addr, call)
+		return
+	}
+	server.respond(req, addr, 100, "Trying", "")
+
+	offer, err := sip.ParseOffer(req.Body)
+	if err != nil {
+		server.respond(req, addr, 400, "Bad Request", "")
+		return
+	}
+
+	call := &sipCall{id: callID, toTag: newSIPTag(), ended: make(chan struct{})}
+	answers := make([]sip.AnswerMedia, len(offer))
+	for i, media := range offer {
+		answers[i] = sip.AnswerMedia{PayloadType: -1}
+		if len(media.PayloadTypes) > 0 {
+			answers[i].PayloadType = media.PayloadTypes[0]
+		}
+		if media.Port == 0 || media.Protocol != "RTP/AVP" {
+			continue
+		}
+		payloadType, encoding := g711PayloadType(media)
+		if payloadType < 0 {
+			continue
+		}
+
+		stream, port, err := s.openRTPStream(server, call, payloadType, encoding)
+		if err != nil {
+			s.endSIPCall(call, "setup_failed")
+			logger.WithFields(logrus.Fields{
+				"component": "svc_sip",
+				"action":    "sip_call_setup_failed",
+				"callID":    callID,
+				"error":     err,
+			}).Error("Failed to set up SIP call")
+			server.respond(req, addr, 503, "Service Unavailable", "")
+			return
+		}
+		call.streams = append(call.streams, stream)
+		answers[i] = sip.AnswerMedia{Port: port, PayloadType: payloadType, Encoding: encoding}
+	}
+	if len(call.streams) == 0 {
+		server.respond(req, addr, 488, "Not Acceptable Here", "")
+		return
+	}
+
+	call.answer = sip.Answer(server.localIP(addr), time.Now().Unix(), answers)
+	server.mutex.Lock()
+	server.calls[callID] = call
+	server.mutex.Unlock()
+	server.answer(req, addr, call)
+
+	for _, stream := range call.streams {
+		go s.receiveRTP(server, call, stream)
+	}
+	logger.WithFields(logrus.Fields{
+		"component": "svc_sip",
+		"action":    "sip_call_answered",
+		"callID":    callID,
+		"from":      req.Get("From"),
+		"streams":   len(call.streams),
+	}).Info("Answered SIP call")
+}
+
+// g711PayloadType returns the first payload type of an offered stream in PCMU or PCMA,
+// -1 when it has none
+func g711PayloadType(media sip.Media) (int, string) {
+	for _, payloadType := range media.PayloadTypes {
+		if encoding := media.Encoding(payloadType); encoding == "PCMU" || encoding == "PCMA" {
+			return payloadType, encoding
+		}
+	}
+	return -1, ""
+}
+
+// openRTPStream binds a port for a stream of a call and opens its session
+func (s *OpenAIService) openRTPStream(server *sipServer, call *sipCall, payloadType int, encoding string) (*rtpStream, int, error) {
+	conn, port, err := server.ports.listen()
+	if err != nil {
+		return nil, 0, err
+	}
+	session, err := s.sessionManager.CreateSession(nil, "audio")
+	if err != nil {
+		conn.Close()
+		return nil, 0, err
+	}
+
+	var pipelineErr error
+	s.sessionManager.UpdateSession(session.ID, func(sess *Session) {
+		sess.sipCall = call.id
+		sess.InputAudioFormat.Type = InputAudioFormatULaw
+		if encoding == "PCMA" {
+			sess.InputAudioFormat.Type = InputAudioFormatALaw
+		}
+		sess.InputAudioFormat.SampleRate = 8000
+		pipelineErr = applyServerSessionSettings(sess, s.appConfig(),
+			server.settings.language, server.settings.model, server.settings.pipeline)
+	})
+	if pipelineErr != nil {
+		conn.Close()
+		s.endSession(session, SessionEndError)
+		return nil, 0, fmt.Errorf("invalid sip.pipeline: %w", pipelineErr)
+	}
+
+	s.sendSessionCreated(session)
+	session.Logger().WithFields(logrus.Fields{
+		"component": "svc_sip",
+		"action":    "sip_session_created",
+		"sessionID": session.ID,
+		"callID":    call.id,
+		"rtpPort":   port,
+		"encoding":  encoding,
+	}).Info("Created session of SIP call stream")
+	return &rtpStream{conn: conn, payloadType: payloadType, session: session}, port, nil
+}
+
+// receiveRTP appends the audio of a stream to its session until the call ends, the
+// session ends or the stream stops sending
+func (s *OpenAIService) receiveRTP(server *sipServer, call *sipCall, stream *rtpStream) {
+	buf := make([]byte, 2048)
+	lastPacket := time.Now()
+	for {
+		stream.conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := stream.conn.Read(buf)
+		select {
+		case <-call.ended:
+			return
+		default:
+		}
+		if err != nil {
+			if time.Since(lastPacket) > server.rtpTimeout {
+				s.endSIPCall(call, "rtp_timeout")
+				return
+			}
+			continue
+		}
+
+		packet, err := rtp.Parse(buf[:n])
+		if err != nil || int(packet.PayloadType) != stream.payloadType {
+			// Comfort noise and telephone events are not transcribed
+			continue
+		}
+		lastPacket = time.Now()
+		if err := s.sessionManager.UpdateHeartbeat(stream.session.ID); err != nil {
+			// Ended by the session sweep or a usage limit
+			s.endSIPCall(call, "session_ended")
+			return
+		}
+		if err := s.appendInputAudio(stream.session, packet.Payload, n, time.Now()); err != nil {
+			stream.session.Logger().WithFields(logrus.Fields{
+				"component": "svc_sip",
+				"action":    "rtp_append_failed",
+				"sessionID": stream.session.ID,
+				"error":     err,
+			}).Warn("Failed to append RTP audio")
+		}
+	}
+}
+
+// endSIPCall closes the streams of a call and ends their sessions. The PBX is not
+// sent a BYE; it sees the ports close.
+func (s *OpenAIService) endSIPCall(call *sipCall, reason string) {
+	call.endOnce.Do(func() {
+		close(call.ended)
+		if s.sip != nil {
+			s.sip.mutex.Lock()
+			if s.sip.calls[call.id] == call {
+				delete(s.sip.calls, call.id)
+			}
+			s.sip.mutex.Unlock()
+		}
+
+		for _, stream := range call.streams {
+			stream.conn.Close()
+			if s.sessionManager.SessionExists(stream.session.ID) {
+				s.endSession(stream.session, SessionEndClosed)
+			}
+		}
+		logger.WithFields(logrus.Fields{
+			"component": "svc_sip",
+			"action":    "sip_call_ended",
+			"callID":    call.id,
+			"reason":    reason,
+		}).Info("SIP call ended")
+	})
+}
+
+func (server *sipServer) call(callID string) *sipCall {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.calls[callID]
+}
+
+func (server *sipServer) activeCalls() []*sipCall {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	calls := make([]*sipCall, 0, len(server.calls))
+	for _, call := range server.calls {
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// allows reports whether a PBX at ip may send requests
+func (server *sipServer) allows(ip net.IP) bool {
+	if len(server.allowed) == 0 {
+		return true
+	}
+	for _, network := range server.allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// localIP returns the address the PBX at addr reaches the server at, for SDP answers
+// and the Contact header
+func (server *sipServer) localIP(addr *net.UDPAddr) string {
+	if server.publicIP != "" {
+		return server.publicIP
+	}
+	if local := server.conn.LocalAddr().(*net.UDPAddr); !local.IP.IsUnspecified() {
+		return local.IP.String()
+	}
+	// Connecting a UDP socket sends nothing, it only picks the outgoing interface
+	probe, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer probe.Close()
+	return probe.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// answer sends the 200 OK of an INVITE with the SDP answer of its call
+func (server *sipServer) answer(req *sip.Message, addr *net.UDPAddr, call *sipCall) {
+	res := sip.NewResponse(req, 200, "OK", call.toTag)
+	res.Add("Contact", fmt.Sprintf("<sip:stt@%s:%d>", server.localIP(addr), server.conn.LocalAddr().(*net.UDPAddr).Port))
+	res.Add("Allow", sipAllow)
+	res.Add("Content-Type", "application/sdp")
+	res.Body = call.answer
+	server.conn.WriteToUDP(res.Bytes(), addr)
+}
+
+// respond sends a response without body to the sender of a request
+func (server *sipServer) respond(req *sip.Message, addr *net.UDPAddr, code int, reason string, toTag string) {
+	if toTag == "" && code > 100 {
+		toTag = newSIPTag()
+	}
+	res := sip.NewResponse(req, code, reason, toTag)
+	if code == 405 || req.Method == sip.MethodOptions {
+		res.Add("Allow", sipAllow)
+	}
+	server.conn.WriteToUDP(res.Bytes(), addr)
+}
+
+// newSIPTag returns a random tag identifying the server's side of a dialog
+func newSIPTag() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/textmerge"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Recognition modes
+const (
+	RecognitionModeVAD           = "vad"            // transcribe speech segments on commit
+	RecognitionModeSlidingWindow = "sliding_window" // also transcribe the most recent audio periodically
+)
+
+const (
+	defaultWindowMs = 8000
+	defaultStepMs   = 2000
+	maxWindowMs     = 30000
+	// maxWindowTokens bounds the running transcript kept for merging
+	maxWindowTokens = 2000
+)
+
+// RecognitionConfig selects how a session's audio is transcribed
+type RecognitionConfig struct {
+	Mode     string `json:"mode"`
+	WindowMs int    `json:"window_ms,omitempty"` // sliding_window: audio transcribed per pass
+	StepMs   int    `json:"step_ms,omitempty"`   // sliding_window: new audio between passes
+}
+
+// Validate checks the mode and window bounds
+func (c RecognitionConfig) Validate() error {
+	switch c.Mode {
+	case "", RecognitionModeVAD, RecognitionModeSlidingWindow:
+	default:
+		return fmt.Errorf("unknown recognition mode: %s", c.Mode)
+	}
+	if c.WindowMs < 0 || c.StepMs < 0 || c.WindowMs > maxWindowMs {
+		return fmt.Errorf("window_ms must be between 0 and %d and step_ms non-negative", maxWindowMs)
+	}
+	if c.WindowMs > 0 && c.StepMs > c.WindowMs {
+		return fmt.Errorf("step_ms must not exceed window_ms, windows must overlap")
+	}
+	return nil
+}
+
+// slidingWindow keeps the most recent audio of a session and the transcript merged
+// from the windows transcribed so far
+type slidingWindow struct {
+	mutex      sync.Mutex
+	samples    []int16
+	fed        int64 // samples fed since the session started, for window timing
+	sinceStep  int
+	inFlight   bool
+	generation int // bumped on reset so late results of a cleared window are dropped
+	tokens     []textmerge.Token
+	revision   int
+}
+
+// reset drops buffered audio and the running transcript
+func (w *slidingWindow) reset() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.samples = nil
+	w.sinceStep = 0
+	w.tokens = nil
+	w.revision = 0
+	w.generation++
+}
+
+// feedSlidingWindow buffers 16kHz audio of a sliding-window session and starts a
+// recognition pass every step
+func (s *OpenAIService) feedSlidingWindow(session *Session, samples []int16) {
+	config := session.Recognition
+	if config.Mode != RecognitionModeSlidingWindow || len(samples) == 0 {
+		return
+	}
+
+	windowMs := config.WindowMs
+	if windowMs <= 0 {
+		windowMs = defaultWindowMs
+	}
+	stepMs := config.StepMs
+	if stepMs <= 0 {
+		stepMs = min(defaultStepMs, windowMs)
+	}
+	windowLen := windowMs * 16
+	stepLen := stepMs * 16
+
+	w := &session.slidingWindow
+	w.mutex.Lock()
+	w.samples = append(w.samples, samples...)
+	if len(w.samples) > windowLen {
+		w.samples = append(w.samples[:0], w.samples[len(w.samples)-windowLen:]...)
+	}
+	w.sinceStep += len(samples)
+	w.fed += int64(len(samples))
+
+	// A pass still running skips this step, its successor sees the newer audio
+	if w.sinceStep < stepLen || w.inFlight {
+		w.mutex.Unlock()
+		return
+	}
+	w.sinceStep = 0
+	w.inFlight = true
+	window := append([]int16(nil), w.samples...)
+	generation := w.generation
+	endMs := w.fed * 1000 / 16000
+	w.mutex.Unlock()
+
+	session.spawn(func() { s.transcribeWindow(session, window, generation, endMs) })
+}
+
+// transcribeWindow recognizes one window, merges it into the running transcript and
+// sends transcription.window
+func (s *OpenAIService) transcribeWindow(session *Session, window []int16, generation int, endMs int64) {
+	w := &session.slidingWindow
+	defer func() {
+		w.mutex.Lock()
+		w.inFlight = false
+		w.mutex.Unlock()
+	}()
+
+	wavData, err := s.convertToWAV(window)
+	if err == nil {
+		var result *llm.Transcription
+		start := time.Now()
+		result, err = s.callRecognitionAPI(session.Context(), wavData, session.ForwardedHeaders, session.ASREndpoint)
+		session.recordASRCall(time.Since(start), err)
+		if err == nil {
+			s.mergeWindow(session, result.Text, generation, endMs-int64(len(window))*1000/16000, endMs)
+			return
+		}
+	}
+
+	session.Logger().WithFields(logrus.Fields{
+		"component": "audio_recogniz",
+		"action":    "window_recognition_failed",
+		"sessionID": session.ID,
+		"error":     err,
+	}).Warn("Sliding window recognition failed")
+}
+
+func (s *OpenAIService) mergeWindow(session *Session, text string, generation int, startMs, endMs int64) {
+	w := &session.slidingWindow
+	w.mutex.Lock()
+	if generation != w.generation {
+		w.mutex.Unlock()
+		return
+	}
+	result := textmerge.Merge(w.tokens, text)
+	w.tokens = result.Tokens
+	if len(w.tokens) > maxWindowTokens {
+		w.tokens = w.tokens[len(w.tokens)-maxWindowTokens:]
+	}
+	w.revision++
+	windowEvent := &TranscriptionWindowEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeTranscriptionWindow,
+			EventID:   session.NewEventID(),
+			SessionID: session.ID,
+		},
+		Revision:      w.revision,
+		Text:          textmerge.Join(w.tokens),
+		Delta:         result.Delta,
+		ReplacedWords: result.Replaced,
+		WindowStartMs: startMs,
+		WindowEndMs:   endMs,
+	}
+	w.mutex.Unlock()
+
+	if err := s.sessionManager.SendEvent(session, windowEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "ws_event_send ",
+			"action":    "send_transcription_window_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Failed to send transcription.window event")
+		return
+	}
+
+	session.Logger().WithFields(logrus.Fields{
+		"component": "audio_recogniz",
+		"action":    "window_merged",
+		"sessionID": session.ID,
+		"revision":  windowEvent.Revision,
+		"delta":     windowEvent.Delta,
+		"replaced":  windowEvent.ReplacedWords,
+	}).Debug("Merged sliding window transcript")
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/s3"
+)
+
+// Saved audio and final flush transcripts are objects under slash-separated keys of an
+// ObjectStore: a local directory, or with storage.type "s3" a bucket of S3-compatible
+// storage, where key prefixes come from templates with {session_id} and {date}.
+
+// errObjectNotFound is returned by ObjectStore.Open for missing objects
+var errObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// StoredObject reads a stored object at random offsets
+type StoredObject interface {
+	io.ReaderAt
+	io.Closer
+	Info() ObjectInfo
+}
+
+// ObjectStore holds saved audio and transcripts
+type ObjectStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Open returns errObjectNotFound for missing objects
+	Open(ctx context.Context, key string) (StoredObject, error)
+	// List returns the objects whose keys start with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Delete removes an object; missing objects are not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// localStore keeps objects as files below root, keys being their relative paths
+type localStore struct {
+	root string
+	mode os.FileMode // of the files written, 0 means 0640
+}
+
+func (l localStore) path(key string) (string, error) {
+	for _, element := range strings.Split(key, "/") {
+		if element == ".." || strings.Contains(element, `\`) {
+			return "", fmt.Errorf("invalid object key %q", key)
+		}
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file through a temporary file so that readers never see it half
+// written
+func (l localStore) Put(_ context.Context, key string, data []byte, _ string) error {
+	file, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	mode := l.mode
+	if mode == 0 {
+		mode = 0640
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func (l localStore) Open(_ context.Context, key string) (StoredObject, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, errObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return localObject{File: file, info: ObjectInfo{Key: key, Size: stat.Size(), ModTime: stat.ModTime()}}, nil
+}
+
+func (l localStore) List(_ context.Context, prefix string) ([]ObjectInfo, error) {
+	// Only the directory holding the prefix is walked
+	dir, err := l.path(path.Dir(prefix + "x"))
+	if err != nil {
+		return nil, err
+	}
+	var objects []ObjectInfo
+	err = filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasSuffix(name, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, name)
+		if err != nil {
+			return nil
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
+
+// Delete removes the file and the directories it leaves empty
+func (l localStore) Delete(_ context.Context, key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	root := filepath.Clean(l.root)
+	for dir := filepath.Dir(name); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+type localObject struct {
+	*os.File
+	info ObjectInfo
+}
+
+func (o localObject) Info() ObjectInfo {
+	return o.info
+}
+
+// s3Store keeps objects in a bucket
+type s3Store struct {
+	client *s3.Client
+}
+
+// newS3Store creates the store of storage.s3, taking missing credentials from the
+// AWS_* environment variables
+func newS3Store(cfg *config.Config) (*s3Store, error) {
+	settings := cfg.Storage.S3
+	if settings.AudioPrefix != "" && !strings.Contains(settings.AudioPrefix, "{session_id}") {
+		return nil, fmt.Errorf("storage.s3.audio_prefix must contain {session_id}")
+	}
+	credential := func(value, env string) string {
+		if value != "" {
+			return value
+		}
+		return os.Getenv(env)
+	}
+	client, err := s3.New(s3.Options{
+		Endpoint:        settings.Endpoint,
+		Region:          settings.Region,
+		Bucket:          settings.Bucket,
+		AccessKeyID:     credential(settings.AccessKeyID, "AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: credential(settings.SecretAccessKey, "AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    credential(settings.SessionToken, "AWS_SESSION_TOKEN"),
+		PathStyle:       settings.PathStyle,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Store{client: client}, nil
+}
+
+func (b *s3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	return b.client.Put(ctx, key, data, contentType)
+}
+
+func (b *s3Store) Open(ctx context.Context, key string) (StoredObject, error) {
+	reader, err := b.client.NewReader(ctx, key)
+	if err == s3.ErrNotFound {
+		return nil, errObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s3Object{reader}, nil
+}
+
+func (b *s3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	listed, err := b.client.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]ObjectInfo, 0, len(listed))
+	for _, object := range listed {
+		objects = append(objects, ObjectInfo{Key: object.Key, Size: object.Size, ModTime: object.LastModified})
+	}
+	return objects, nil
+}
+
+func (b *s3Store) Delete(ctx context.Context, key string) error {
+	return b.client.Delete(ctx, key)
+}
+
+type s3Object struct {
+	*s3.ObjectReader
+}
+
+func (o s3Object) Info() ObjectInfo {
+	object := o.Object()
+	return ObjectInfo{Key: object.Key, Size: object.Size, ModTime: object.LastModified}
+}
+
+// readObject reads a whole stored object
+func readObject(ctx context.Context, store ObjectStore, key string) ([]byte, error) {
+	object, err := store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	data := make([]byte, object.Info().Size)
+	if _, err := object.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// renderKeyTemplate replaces {session_id} and {date}, the UTC day of at, in a key
+// template
+func renderKeyTemplate(template, sessionID string, at time.Time) string {
+	return strings.NewReplacer("{session_id}", sessionID, "{date}", at.UTC().Format("2006-01-02")).Replace(template)
+}
+
+// keyTemplatePattern matches the key prefixes a template renders to for a session, on
+// any day
+func keyTemplatePattern(template, sessionID string) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(template)
+	pattern = strings.ReplaceAll(pattern, regexp.QuoteMeta("{session_id}"), regexp.QuoteMeta(sessionID))
+	pattern = strings.ReplaceAll(pattern, regexp.QuoteMeta("{date}"), `\d{4}-\d{2}-\d{2}`)
+	return regexp.MustCompile("^" + pattern + "$")
+}
+
+// audioStore returns the store of saved audio
+func (s *OpenAIService) audioStore() ObjectStore {
+	if s.bucket != nil {
+		return s.bucket
+	}
+	return localStore{root: s.recordingsDir()}
+}
+
+// journalStore returns the store of debug session journals, the store of saved audio
+// with files only the service user can read
+func (s *OpenAIService) journalStore() ObjectStore {
+	if s.bucket != nil {
+		return s.bucket
+	}
+	return localStore{root: s.recordingsDir(), mode: 0600}
+}
+
+// audioKeyTemplate returns the key prefix template of a session's saved audio
+func (s *OpenAIService) audioKeyTemplate() string {
+	if s.bucket == nil {
+		return "{session_id}/"
+	}
+	if template := s.appConfig().Storage.S3.AudioPrefix; template != "" {
+		return template
+	}
+	return "audio/{session_id}/"
+}
+
+// transcriptStore returns the store and key prefix of final flush transcripts, false
+// when they are not saved
+func (s *OpenAIService) transcriptStore(sessionID string, at time.Time) (ObjectStore, string, bool) {
+	cfg := s.appConfig()
+	if s.bucket != nil {
+		template := cfg.Storage.S3.TranscriptPrefix
+		return s.bucket, renderKeyTemplate(template, sessionID, at), template != ""
+	}
+	return localStore{root: cfg.FinalFlush.SaveDir}, "", cfg.FinalFlush.SaveDir != ""
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/pkg/tracing"
+	"github.com/go-restream/stt/vad"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Span names of the recognition pipeline
+const (
+	spanConversationItem = "conversation.item"
+	spanAudioAppend      = "input_audio_buffer.append"
+	spanVADProcess       = "vad.process"
+	spanRecognition      = "recognition.process"
+	spanEncodeWAV        = "audio.encode_wav"
+	spanASRTranscribe    = "asr.transcribe"
+)
+
+// itemTrace is the trace of the conversation item in progress. Its root span starts
+// with the append that completes the first speech segment of the utterance, or with
+// the commit when none did, and ends once the transcript or failure is sent. Appends
+// without speech are not recorded, a span per audio chunk would swamp the collector.
+type itemTrace struct {
+	mutex  sync.Mutex
+	parent context.Context // carries the traceparent of the upgrade request, if any
+	ctx    context.Context // carries the root span, nil between items
+}
+
+// newTracer creates the tracer of the tracing section of the configuration
+func newTracer(cfg *config.Config) *tracing.Tracer {
+	ratio := cfg.Tracing.SampleRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	return tracing.NewTracer(tracing.Options{
+		ServiceName: cfg.Tracing.ServiceName,
+		Endpoint:    cfg.Tracing.OTLPEndpoint,
+		Headers:     cfg.Tracing.Headers,
+		SampleRatio: ratio,
+		Timeout:     time.Duration(cfg.Tracing.TimeoutSeconds) * time.Second,
+	})
+}
+
+// SetTracer replaces the tracer of the recognition pipeline, nil disables tracing
+func (s *OpenAIService) SetTracer(tracer *tracing.Tracer) {
+	s.tracer = tracer
+}
+
+// startSessionTrace makes the items of the session continue the trace of the upgrade
+// request when it carries a W3C traceparent header
+func (s *OpenAIService) startSessionTrace(session *Session, r *http.Request) {
+	parent := session.Context()
+	if sc, ok := tracing.Extract(r.Header); ok {
+		parent = tracing.ContextWithRemoteParent(parent, sc)
+	}
+	session.trace.mutex.Lock()
+	session.trace.parent = parent
+	session.trace.mutex.Unlock()
+}
+
+// itemContext returns the context of the item in progress, starting its root span at
+// start when there is none; the caller holds the trace mutex
+func (s *OpenAIService) itemContext(session *Session, start time.Time) context.Context {
+	if session.trace.ctx != nil {
+		return session.trace.ctx
+	}
+	parent := session.trace.parent
+	if parent == nil {
+		parent = session.Context()
+	}
+	ctx, root := s.tracer.StartAt(parent, spanConversationItem, tracing.KindServer, start)
+	root.SetAttribute("session.id", session.ID)
+	if session.Tenant != "" {
+		root.SetAttribute("tenant", session.Tenant)
+	}
+	if session.Pipeline != "" {
+		root.SetAttribute("pipeline", session.Pipeline)
+	}
+	if session.Variant != "" {
+		root.SetAttribute("experiment.name", session.Experiment)
+		root.SetAttribute("experiment.variant", session.Variant)
+	}
+	// Unsampled items keep their context too, so they are not sampled again per segment
+	session.trace.ctx = ctx
+	return ctx
+}
+
+// traceSpeechAppend records an append whose VAD processing completed speech segments,
+// with the VAD processing as child span
+func (s *OpenAIService) traceSpeechAppend(session *Session, samples, segments int, appendStart, vadStart, vadEnd time.Time) {
+	if s.tracer == nil {
+		return
+	}
+	session.trace.mutex.Lock()
+	ctx := s.itemContext(session, appendStart)
+	session.trace.mutex.Unlock()
+
+	tracing.SpanFromContext(ctx).AddInt("vad.segments", int64(segments))
+
+	appendCtx, appendSpan := s.tracer.StartAt(ctx, spanAudioAppend, tracing.KindInternal, appendStart)
+	appendSpan.SetAttribute("audio.samples", samples)
+
+	_, vadSpan := s.tracer.StartAt(appendCtx, spanVADProcess, tracing.KindInternal, vadStart)
+	vadSpan.SetAttribute("vad.engine", vad.EngineName(s.appConfig()))
+	vadSpan.SetAttribute("vad.segments", segments)
+	vadSpan.EndAt(vadEnd)
+
+	appendSpan.End()
+}
+
+// takeItemContext hands the context of the item in progress over to its recognition,
+// whose end also ends the root span. It is cancelled when the session ends.
+func (s *OpenAIService) takeItemContext(session *Session, itemID string) context.Context {
+	if s.tracer == nil {
+		return session.Context()
+	}
+	session.trace.mutex.Lock()
+	ctx := s.itemContext(session, time.Now())
+	session.trace.ctx = nil
+	session.trace.mutex.Unlock()
+
+	root := tracing.SpanFromContext(ctx)
+	root.SetAttribute("item.id", itemID)
+	if traceID := root.TraceID(); traceID != "" {
+		s.sessionManager.UpdateConversationItem(session.ID, itemID, func(item *ConversationItem) {
+			item.TraceID = traceID
+		})
+	}
+	return ctx
+}
+
+// abandonItemTrace ends the trace of an item that will not be recognized, e.g. when
+// the buffer is cleared or the client disconnects
+func (s *OpenAIService) abandonItemTrace(session *Session, outcome string) {
+	session.trace.mutex.Lock()
+	ctx := session.trace.ctx
+	session.trace.ctx = nil
+	session.trace.mutex.Unlock()
+
+	if ctx == nil {
+		return
+	}
+	root := tracing.SpanFromContext(ctx)
+	root.SetAttribute("item.outcome", outcome)
+	root.End()
+}
+
+// shutdownTracer exports the spans still queued
+func (s *OpenAIService) shutdownTracer() {
+	if s.tracer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.tracer.Shutdown(ctx); err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "tracer_shutdown_failed",
+			"error":     err,
+		}).Warn("Failed to export remaining trace spans")
+	}
+}
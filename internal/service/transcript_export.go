@@ -0,0 +1,203 @@
+package service
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-restream/stt/pkg/textformat"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultLowConfidence is the word confidence below which export marks a word as low
+const defaultLowConfidence = 0.6
+
+// TranscriptExport is a session transcript with per-word confidence, used by editors
+// reviewing machine transcripts
+type TranscriptExport struct {
+	SessionID     string                 `json:"session_id"`
+	Pipeline      string                 `json:"pipeline,omitempty"`
+	Variant       string                 `json:"variant,omitempty"` // experiment variant, see experiments.go
+	LowConfidence float64                `json:"low_confidence_threshold"`
+	Direction     textformat.Direction   `json:"direction"` // base direction of the whole transcript
+	Items         []TranscriptExportItem `json:"items"`
+	ExportedAt    time.Time              `json:"exported_at"`
+}
+
+// TranscriptExportItem is the transcript of a conversation item. Confidence is the mean
+// word confidence, or -1 when the ASR backend reported none.
+type TranscriptExportItem struct {
+	ItemID       string                 `json:"item_id"`
+	AudioStartMs int64                  `json:"audio_start_ms"`
+	Transcript   string                 `json:"transcript"`
+	Direction    textformat.Direction   `json:"direction"` // "rtl" for Arabic, Hebrew and other right-to-left transcripts
+	Confidence   float64                `json:"confidence"`
+	Words        []TranscriptExportWord `json:"words,omitempty"`
+}
+
+// TranscriptExportWord is a word with its position in the session audio
+type TranscriptExportWord struct {
+	Word       string  `json:"word"`
+	StartMs    int64   `json:"start_ms"`
+	EndMs      int64   `json:"end_ms"`
+	Confidence float64 `json:"confidence"`
+	Low        bool    `json:"low"`
+}
+
+// ExportTranscript collects the completed transcripts of a session with their words,
+// flagging words below the low confidence threshold
+func (sm *SessionManager) ExportTranscript(sessionID string, lowConfidence float64) (TranscriptExport, error) {
+	session, exists := sm.GetSession(sessionID)
+	if !exists {
+		return TranscriptExport{}, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	export := TranscriptExport{
+		SessionID:     sessionID,
+		Pipeline:      session.Pipeline,
+		Variant:       session.Variant,
+		LowConfidence: lowConfidence,
+		Items:         []TranscriptExportItem{},
+		ExportedAt:    time.Now(),
+	}
+
+	session.itemsMutex.RLock()
+	defer session.itemsMutex.RUnlock()
+
+	var transcripts []string
+	for _, item := range session.ConversationItems {
+		transcript := conversationItemTranscript(item)
+		if item.Status != "completed" || transcript == "" {
+			continue
+		}
+
+		exported := TranscriptExportItem{
+			ItemID:       item.ID,
+			AudioStartMs: item.AudioStartMs,
+			Transcript:   transcript,
+			Direction:    textformat.DetectDirection(transcript),
+			Confidence:   -1,
+		}
+
+		var sum float64
+		scored := 0
+		for _, word := range item.Words {
+			exported.Words = append(exported.Words, TranscriptExportWord{
+				Word:       word.Word,
+				StartMs:    item.AudioStartMs + int64(word.Start*1000),
+				EndMs:      item.AudioStartMs + int64(word.End*1000),
+				Confidence: word.Confidence,
+				Low:        word.Confidence >= 0 && word.Confidence < lowConfidence,
+			})
+			if word.Confidence >= 0 {
+				sum += word.Confidence
+				scored++
+			}
+		}
+		if scored > 0 {
+			exported.Confidence = math.Round(sum/float64(scored)*1000) / 1000
+		}
+		export.Items = append(export.Items, exported)
+		transcripts = append(transcripts, transcript)
+	}
+	export.Direction = textformat.DetectDirection(strings.Join(transcripts, " "))
+	return export, nil
+}
+
+// conversationItemTranscript joins the transcripts stored on a conversation item
+func conversationItemTranscript(item *ConversationItem) string {
+	var parts []string
+	for _, content := range item.Content {
+		if entry, ok := content.(map[string]interface{}); ok {
+			if transcript, ok := entry["transcript"].(string); ok && transcript != "" {
+				parts = append(parts, transcript)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+var transcriptHTML = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"heat":    confidenceColor,
+	"percent": func(c float64) string { return strconv.Itoa(int(math.Round(c * 100))) },
+	"clock":   func(ms int64) string { return (time.Duration(ms) * time.Millisecond).Truncate(time.Second).String() },
+}).Parse(`<!DOCTYPE html>
+<html dir="{{.Direction}}">
+<head>
+<meta charset="utf-8">
+<title>Transcript {{.SessionID}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; line-height: 1.8; }
+.time { color: #888; font-size: 0.8em; margin-right: 0.5em; }
+.word { padding: 0 0.1em; border-radius: 0.2em; }
+.low { border-bottom: 2px solid #c00; }
+</style>
+</head>
+<body>
+<h1>Transcript {{.SessionID}}</h1>
+<p>Words below {{percent .LowConfidence}}% confidence are underlined; the redder the background, the lower the confidence.</p>
+{{range .Items}}<p id="{{.ItemID}}" dir="{{.Direction}}"><bdi class="time">{{clock .AudioStartMs}}</bdi>
+{{- if .Words}}{{range .Words}}<span class="word{{if .Low}} low{{end}}" style="background: {{heat .Confidence}}" title="{{if ge .Confidence 0.0}}{{percent .Confidence}}%{{else}}no confidence{{end}}">{{.Word}}</span> {{end}}
+{{- else}}{{.Transcript}}{{end}}</p>
+{{end}}</body>
+</html>
+`))
+
+// confidenceColor maps a confidence to a background, transparent for confident words
+// and increasingly red below 0.9
+func confidenceColor(confidence float64) template.CSS {
+	if confidence < 0 || confidence >= 0.9 {
+		return "transparent"
+	}
+	alpha := math.Min(1, (0.9-confidence)/0.9)
+	return template.CSS(fmt.Sprintf("rgba(220, 0, 0, %.2f)", alpha*0.6))
+}
+
+// writeTranscriptHTML renders a transcript export as a confidence heatmap
+func writeTranscriptHTML(w io.Writer, export TranscriptExport) error {
+	return transcriptHTML.Execute(w, export)
+}
+
+// handleTranscriptExport exports a session transcript with per-word confidence as
+// JSON, or as an HTML heatmap with ?format=html. ?low_confidence overrides the
+// threshold below which words are flagged.
+func handleTranscriptExport(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	lowConfidence := defaultLowConfidence
+	if value := c.Query("low_confidence"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "low_confidence must be between 0 and 1"})
+			return
+		}
+		lowConfidence = parsed
+	}
+
+	sessionID := c.Param("id")
+	export, err := openAIService.sessionManager.ExportTranscript(sessionID, lowConfidence)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, export)
+	case "html":
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		writeTranscriptHTML(c.Writer, export)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or html"})
+	}
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The history API lists the live sessions and the conversation items they keep, so
+// transcripts can be fetched after the events announcing them were sent. Lists are
+// paginated like the OpenAI list endpoints: limit bounds a page, after continues
+// behind the ID of the last entry of the previous page.
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// SessionSummary is a live session as listed by GET /v1/sessions
+type SessionSummary struct {
+	ID             string    `json:"id"`
+	Tenant         string    `json:"tenant,omitempty"`
+	Modality       string    `json:"modality"`
+	Pipeline       string    `json:"pipeline,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	LastActive     time.Time `json:"last_active"`
+	Items          int       `json:"items"`
+	CompletedItems int       `json:"completed_items"`
+	FailedItems    int       `json:"failed_items"`
+}
+
+// HistoryItem is a conversation item with its transcript, or the error of a failed
+// transcription
+type HistoryItem struct {
+	ID           string     `json:"id"`
+	Status       string     `json:"status"`
+	Role         string     `json:"role,omitempty"`
+	Transcript   string     `json:"transcript,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	AudioStartMs int64      `json:"audio_start_ms,omitempty"`
+	AudioEndMs   int64      `json:"audio_end_ms,omitempty"`
+}
+
+// historyFilter selects entries created in [Since, Until) with Status, zero values
+// select everything
+type historyFilter struct {
+	Since  time.Time
+	Until  time.Time
+	Status string
+	Tenant string
+}
+
+func (f historyFilter) matchesTime(at time.Time) bool {
+	if !f.Since.IsZero() && at.Before(f.Since) {
+		return false
+	}
+	return f.Until.IsZero() || at.Before(f.Until)
+}
+
+// historyPage is a page of a list, after and limit as parsed from the query
+type historyPage struct {
+	After string
+	Limit int
+}
+
+// paginate returns the page of ids, in list order, following page.After and whether
+// more follow it
+func paginate(ids []string, page historyPage) (start, end int, hasMore bool) {
+	if page.After != "" {
+		for i, id := range ids {
+			if id == page.After {
+				start = i + 1
+				break
+			}
+		}
+	}
+	end = min(start+page.Limit, len(ids))
+	return start, end, end < len(ids)
+}
+
+// ListSessions returns the live sessions matching filter ordered by creation time,
+// the page of them following page.After and whether more follow
+func (sm *SessionManager) ListSessions(filter historyFilter, page historyPage) ([]SessionSummary, bool) {
+	sm.mutex.RLock()
+	summaries := make([]SessionSummary, 0, len(sm.sessions))
+	for _, session := range sm.sessions {
+		if filter.Tenant != "" && session.Tenant != filter.Tenant {
+			continue
+		}
+		if !filter.matchesTime(session.CreatedAt) {
+			continue
+		}
+		summaries = append(summaries, SessionSummary{
+			ID:         session.ID,
+			Tenant:     session.Tenant,
+			Modality:   session.Modality,
+			Pipeline:   session.Pipeline,
+			CreatedAt:  session.CreatedAt,
+			LastActive: session.LastActive,
+		})
+	}
+	sm.mutex.RUnlock()
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].CreatedAt.Equal(summaries[j].CreatedAt) {
+			return summaries[i].CreatedAt.Before(summaries[j].CreatedAt)
+		}
+		return summaries[i].ID < summaries[j].ID
+	})
+
+	ids := make([]string, len(summaries))
+	for i, summary := range summaries {
+		ids[i] = summary.ID
+	}
+	start, end, hasMore := paginate(ids, page)
+	summaries = summaries[start:end]
+
+	// Count items only for the page returned
+	for i := range summaries {
+		session, exists := sm.GetSession(summaries[i].ID)
+		if !exists {
+			continue
+		}
+		session.itemsMutex.RLock()
+		summaries[i].Items = len(session.ConversationItems)
+		for _, item := range session.ConversationItems {
+			switch item.Status {
+			case "completed":
+				summaries[i].CompletedItems++
+			case "failed":
+				summaries[i].FailedItems++
+			}
+		}
+		session.itemsMutex.RUnlock()
+	}
+	return summaries, hasMore
+}
+
+// ListConversationItems returns the conversation items of a session matching filter
+// in conversation order, the page of them following page.After and whether more
+// follow. Items evicted by the conversation limits are no longer listed.
+func (sm *SessionManager) ListConversationItems(sessionID string, filter historyFilter, page historyPage) ([]HistoryItem, bool, error) {
+	session, exists := sm.GetSession(sessionID)
+	if !exists || (filter.Tenant != "" && session.Tenant != filter.Tenant) {
+		return nil, false, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	session.itemsMutex.RLock()
+	items := make([]HistoryItem, 0, len(session.ConversationItems))
+	for _, item := range session.ConversationItems {
+		if filter.Status != "" && item.Status != filter.Status {
+			continue
+		}
+		if !filter.matchesTime(item.CreatedAt) {
+			continue
+		}
+		items = append(items, HistoryItem{
+			ID:           item.ID,
+			Status:       item.Status,
+			Role:         item.Role,
+			Transcript:   conversationItemTranscript(item),
+			Error:        conversationItemError(item),
+			CreatedAt:    item.CreatedAt,
+			CompletedAt:  item.CompletedAt,
+			AudioStartMs: item.AudioStartMs,
+			AudioEndMs:   item.AudioEndMs,
+		})
+	}
+	session.itemsMutex.RUnlock()
+
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	start, end, hasMore := paginate(ids, page)
+	return items[start:end], hasMore, nil
+}
+
+// conversationItemError returns the error stored on a failed item
+func conversationItemError(item *ConversationItem) string {
+	for _, content := range item.Content {
+		if entry, ok := content.(map[string]interface{}); ok && entry["type"] == "error" {
+			if text, ok := entry["text"].(string); ok {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// parseHistoryQuery reads the filter and page of a history request, writing the error
+// response of an invalid parameter
+func parseHistoryQuery(c *gin.Context) (historyFilter, historyPage, bool) {
+	filter := historyFilter{Status: c.Query("status")}
+	page := historyPage{After: c.Query("after"), Limit: defaultHistoryLimit}
+
+	switch filter.Status {
+	case "", "in_progress", "completed", "failed":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be in_progress, completed or failed"})
+		return filter, page, false
+	}
+
+	for _, bound := range []struct {
+		name string
+		at   *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		value := c.Query(bound.name)
+		if value == "" {
+			continue
+		}
+		at, err := parseHistoryTime(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": bound.name + " must be an RFC 3339 time or Unix seconds"})
+			return filter, page, false
+		}
+		*bound.at = at
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxHistoryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxHistoryLimit)})
+			return filter, page, false
+		}
+		page.Limit = limit
+	}
+
+	// A client of a tenant sees only the sessions of its tenant
+	if value, ok := c.Get(principalKey); ok {
+		if principal, ok := value.(*Principal); ok {
+			filter.Tenant = principal.Tenant
+		}
+	}
+	return filter, page, true
+}
+
+// parseHistoryTime parses an RFC 3339 time or Unix seconds
+func parseHistoryTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// handleListSessions is GET /v1/sessions, the live sessions created in the time range
+func handleListSessions(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	filter, page, ok := parseHistoryQuery(c)
+	if !ok {
+		return
+	}
+	sessions, hasMore := openAIService.sessionManager.ListSessions(filter, page)
+	c.JSON(http.StatusOK, historyList(sessions, hasMore, func(s SessionSummary) string { return s.ID }))
+}
+
+// handleSessionItems is GET /v1/sessions/:id/items, the conversation items of a session
+// with their transcripts
+func handleSessionItems(c *gin.Context) {
+	if openAIService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "OpenAI service not initialized"})
+		return
+	}
+
+	filter, page, ok := parseHistoryQuery(c)
+	if !ok {
+		return
+	}
+	items, hasMore, err := openAIService.sessionManager.ListConversationItems(c.Param("id"), filter, page)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	list := historyList(items, hasMore, func(item HistoryItem) string { return item.ID })
+	list["session_id"] = c.Param("id")
+	c.JSON(http.StatusOK, list)
+}
+
+// historyList is the response body of a page of a list
+func historyList[T any](data []T, hasMore bool, id func(T) string) gin.H {
+	list := gin.H{"object": "list", "data": data, "has_more": hasMore}
+	if len(data) > 0 {
+		list["first_id"] = id(data[0])
+		list["last_id"] = id(data[len(data)-1])
+	}
+	return list
+}
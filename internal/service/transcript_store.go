@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/llm"
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTranscriptQueueSize       = 1000
+	defaultTranscriptCleanupInterval = time.Hour
+	transcriptStoreTimeout           = 5 * time.Second
+)
+
+// TranscriptStore persists the finished conversation items of every session, so their
+// transcripts outlive the session and the conversation limits
+type TranscriptStore interface {
+	// Save writes the record, replacing an earlier one of the same item
+	Save(ctx context.Context, record *TranscriptRecord) error
+	// DeleteBefore removes the records of items finished before cutoff and returns how
+	// many were removed
+	DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	Close() error
+}
+
+// TranscriptRecord is a completed or failed conversation item as persisted
+type TranscriptRecord struct {
+	SessionID    string
+	ItemID       string
+	Tenant       string
+	Status       string // "completed" or "failed"
+	Transcript   string
+	Error        string // reason of a failed transcription
+	CreatedAt    time.Time
+	CompletedAt  time.Time
+	AudioStartMs int64
+	AudioEndMs   int64
+	DurationMs   int64                   // length of the item's speech
+	Words        []llm.TranscriptionWord // timing and confidence of the recognized words
+	Language     string                  // detected language of the transcript
+}
+
+// transcriptPersistence writes records in the background, so a slow database never
+// holds up the recognition of the next utterance. Records arriving while the queue is
+// full are dropped.
+type transcriptPersistence struct {
+	store TranscriptStore
+	queue chan *TranscriptRecord
+	done  chan struct{}
+
+	mutex  sync.Mutex
+	closed bool
+}
+
+// newTranscriptPersistence opens the store of transcript_store.type, nil when there is
+// none or it cannot be opened
+func newTranscriptPersistence(cfg *config.Config) *transcriptPersistence {
+	settings := cfg.TranscriptStore
+	store, err := openSQLTranscriptStore(settings.Type, settings.DSN)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "transcript_store_disabled",
+			"type":      settings.Type,
+			"error":     err,
+		}).Error("Failed to open transcript store, transcripts are not persisted")
+		return nil
+	}
+
+	queueSize := settings.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultTranscriptQueueSize
+	}
+	p := &transcriptPersistence{
+		store: store,
+		queue: make(chan *TranscriptRecord, queueSize),
+		done:  make(chan struct{}),
+	}
+	go p.run()
+
+	logger.WithFields(logrus.Fields{
+		"component": "svc_openai_api ",
+		"action":    "transcript_store_enabled",
+		"type":      settings.Type,
+		"retention": settings.RetentionDays,
+	}).Info("Transcripts are persisted")
+	return p
+}
+
+func (p *transcriptPersistence) run() {
+	defer close(p.done)
+
+	for record := range p.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), transcriptStoreTimeout)
+		err := p.store.Save(ctx, record)
+		cancel()
+		if err != nil {
+			logger.WithFields(logrus.Fields{
+				"component": "mg_session_ctrl",
+				"action":    "transcript_store_failed",
+				"sessionID": record.SessionID,
+				"itemID":    record.ItemID,
+				"error":     err,
+			}).Warn("Failed to persist transcript")
+		}
+	}
+}
+
+// enqueue queues a record for writing, reporting false when it was dropped
+func (p *transcriptPersistence) enqueue(record *TranscriptRecord) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return false
+	}
+	select {
+	case p.queue <- record:
+		return true
+	default:
+		return false
+	}
+}
+
+// close writes the queued records, waiting at most timeout, and closes the store
+func (p *transcriptPersistence) close(timeout time.Duration) {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	p.mutex.Unlock()
+
+	select {
+	case <-p.done:
+	case <-time.After(timeout):
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "transcript_store_flush_timeout",
+			"pending":   len(p.queue),
+		}).Warn("Transcripts still queued at shutdown are lost")
+	}
+	if err := p.store.Close(); err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "svc_openai_api ",
+			"action":    "transcript_store_close_failed",
+			"error":     err,
+		}).Warn("Failed to close transcript store")
+	}
+}
+
+// persistConversationItem queues a finished conversation item for the transcript store
+func (s *OpenAIService) persistConversationItem(session *Session, itemID string) {
+	if s.transcripts == nil {
+		return
+	}
+	item, err := s.sessionManager.GetConversationItem(session.ID, itemID)
+	if err != nil {
+		return
+	}
+
+	// Read under the items lock, the item may be evicted and its content extended
+	// while the record is built
+	session.itemsMutex.RLock()
+	record := &TranscriptRecord{
+		SessionID:    session.ID,
+		ItemID:       item.ID,
+		Tenant:       session.Tenant,
+		Status:       item.Status,
+		Transcript:   conversationItemTranscript(item),
+		Error:        conversationItemError(item),
+		CreatedAt:    item.CreatedAt,
+		AudioStartMs: item.AudioStartMs,
+		AudioEndMs:   item.AudioEndMs,
+		Words:        slices.Clone(item.Words),
+		Language:     item.Language,
+	}
+	if item.CompletedAt != nil {
+		record.CompletedAt = *item.CompletedAt
+	}
+	session.itemsMutex.RUnlock()
+
+	if record.CompletedAt.IsZero() {
+		record.CompletedAt = time.Now()
+	}
+	if record.AudioEndMs > record.AudioStartMs {
+		record.DurationMs = record.AudioEndMs - record.AudioStartMs
+	}
+
+	if !s.transcripts.enqueue(record) {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "mg_session_ctrl",
+			"action":    "transcript_store_dropped",
+			"sessionID": session.ID,
+			"itemID":    itemID,
+		}).Warn("Transcript store queue is full, transcript not persisted")
+	}
+}
+
+// transcriptRetentionLoop deletes the transcripts older than the retention period until
+// ctx is done. The period and the interval are read on every pass, so a reload changes
+// them.
+func (s *OpenAIService) transcriptRetentionLoop(ctx context.Context) {
+	interval := s.transcriptCleanupInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if next := s.transcriptCleanupInterval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+
+			retentionDays := s.appConfig().TranscriptStore.RetentionDays
+			if retentionDays <= 0 {
+				continue
+			}
+
+			cutoff := time.Now().AddDate(0, 0, -retentionDays)
+			deleteCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			deleted, err := s.transcripts.store.DeleteBefore(deleteCtx, cutoff)
+			cancel()
+			if err != nil {
+				logger.WithFields(logrus.Fields{
+					"component":     "cln_transcript ",
+					"action":        "cleanup_failed",
+					"error":         err,
+					"retentionDays": retentionDays,
+				}).Error("Failed to delete expired transcripts")
+				continue
+			}
+			if deleted > 0 {
+				logger.WithFields(logrus.Fields{
+					"component":     "cln_transcript ",
+					"action":        "transcripts_deleted",
+					"deleted":       deleted,
+					"retentionDays": retentionDays,
+				}).Info("Deleted expired transcripts")
+			}
+		case <-ctx.Done():
+			logger.WithFields(logrus.Fields{
+				"component": "cln_transcript ",
+				"action":    "cleanup_stopped",
+			}).Info("Transcript cleanup routine stopped")
+			return
+		}
+	}
+}
+
+// transcriptCleanupInterval returns the configured interval between retention passes
+func (s *OpenAIService) transcriptCleanupInterval() time.Duration {
+	interval := time.Duration(s.appConfig().TranscriptStore.CleanupIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = defaultTranscriptCleanupInterval
+	}
+	return interval
+}
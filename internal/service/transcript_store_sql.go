@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	// Registers the "postgres" driver; the "sqlite3" driver needs CGO and is compiled
+	// in with the sqlite build tag, see transcript_store_sqlite.go
+	_ "github.com/lib/pq"
+)
+
+const createTranscriptsTable = `CREATE TABLE IF NOT EXISTS transcripts (
+	session_id     TEXT NOT NULL,
+	item_id        TEXT NOT NULL,
+	tenant         TEXT NOT NULL DEFAULT '',
+	status         TEXT NOT NULL,
+	transcript     TEXT NOT NULL DEFAULT '',
+	error          TEXT NOT NULL DEFAULT '',
+	created_at     %[1]s NOT NULL,
+	completed_at   %[1]s NOT NULL,
+	audio_start_ms BIGINT NOT NULL DEFAULT 0,
+	audio_end_ms   BIGINT NOT NULL DEFAULT 0,
+	duration_ms    BIGINT NOT NULL DEFAULT 0,
+	words          TEXT NOT NULL DEFAULT '',
+	language       TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (session_id, item_id)
+)`
+
+// addedTranscriptsColumns are added to transcripts tables created by earlier versions
+var addedTranscriptsColumns = []string{
+	"words TEXT NOT NULL DEFAULT ''",
+	"language TEXT NOT NULL DEFAULT ''",
+}
+
+const createTranscriptsIndex = `CREATE INDEX IF NOT EXISTS transcripts_completed_at ON transcripts (completed_at)`
+
+const saveTranscript = `INSERT INTO transcripts
+	(session_id, item_id, tenant, status, transcript, error, created_at, completed_at, audio_start_ms, audio_end_ms, duration_ms, words, language)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (session_id, item_id) DO UPDATE SET
+	status = excluded.status, transcript = excluded.transcript, error = excluded.error,
+	completed_at = excluded.completed_at, audio_start_ms = excluded.audio_start_ms,
+	audio_end_ms = excluded.audio_end_ms, duration_ms = excluded.duration_ms,
+	words = excluded.words, language = excluded.language`
+
+const deleteTranscripts = `DELETE FROM transcripts WHERE completed_at < ?`
+
+// sqlDialect is what differs between the databases a SQLTranscriptStore supports
+type sqlDialect struct {
+	driver        string
+	timestampType string
+	numbered      bool // placeholders are $1, $2, ... instead of ?
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"sqlite":   {driver: "sqlite3", timestampType: "DATETIME"},
+	"postgres": {driver: "postgres", timestampType: "TIMESTAMPTZ", numbered: true},
+}
+
+// SQLTranscriptStore implements TranscriptStore in a transcripts table of SQLite or
+// PostgreSQL, created when missing
+type SQLTranscriptStore struct {
+	db         *sql.DB
+	saveQuery  string
+	purgeQuery string
+}
+
+// openSQLTranscriptStore connects to the database of kind "sqlite" or "postgres" at dsn
+func openSQLTranscriptStore(kind, dsn string) (*SQLTranscriptStore, error) {
+	dialect, ok := sqlDialects[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown transcript store type %q", kind)
+	}
+	if !slices.Contains(sql.Drivers(), dialect.driver) {
+		return nil, fmt.Errorf("%s support is not compiled in, build with -tags %s", kind, kind)
+	}
+
+	db, err := sql.Open(dialect.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if dialect.driver == "sqlite3" {
+		// SQLite allows one writer at a time
+		db.SetMaxOpenConns(1)
+	}
+
+	store, err := NewSQLTranscriptStore(db, kind)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewSQLTranscriptStore creates the transcripts table in db unless it exists. kind is
+// the database behind db, "sqlite" or "postgres".
+func NewSQLTranscriptStore(db *sql.DB, kind string) (*SQLTranscriptStore, error) {
+	dialect, ok := sqlDialects[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown transcript store type %q", kind)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, statement := range []string{fmt.Sprintf(createTranscriptsTable, dialect.timestampType), createTranscriptsIndex} {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return nil, fmt.Errorf("create transcripts table: %w", err)
+		}
+	}
+	for _, column := range addedTranscriptsColumns {
+		name, _, _ := strings.Cut(column, " ")
+		// Neither database reports a missing column the same way, probe for it instead
+		if _, err := db.ExecContext(ctx, "SELECT "+name+" FROM transcripts WHERE 1 = 0"); err == nil {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE transcripts ADD COLUMN "+column); err != nil {
+			return nil, fmt.Errorf("add transcripts column %s: %w", name, err)
+		}
+	}
+
+	return &SQLTranscriptStore{
+		db:         db,
+		saveQuery:  dialect.rebind(saveTranscript),
+		purgeQuery: dialect.rebind(deleteTranscripts),
+	}, nil
+}
+
+// rebind replaces the ? placeholders of query with the ones of the dialect
+func (d sqlDialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var rebound strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			rebound.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		rebound.WriteRune(r)
+	}
+	return rebound.String()
+}
+
+func (s *SQLTranscriptStore) Save(ctx context.Context, record *TranscriptRecord) error {
+	// words is a JSON array, empty when the provider reported no words
+	var words []byte
+	if len(record.Words) > 0 {
+		var err error
+		if words, err = json.Marshal(record.Words); err != nil {
+			return err
+		}
+	}
+	_, err := s.db.ExecContext(ctx, s.saveQuery,
+		record.SessionID, record.ItemID, record.Tenant, record.Status, record.Transcript, record.Error,
+		record.CreatedAt.UTC(), record.CompletedAt.UTC(), record.AudioStartMs, record.AudioEndMs, record.DurationMs,
+		string(words), record.Language)
+	return err
+}
+
+func (s *SQLTranscriptStore) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, s.purgeQuery, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *SQLTranscriptStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package service
+
+// Registers the "sqlite3" driver of the sqlite transcript store, it needs CGO
+import _ "github.com/mattn/go-sqlite3"
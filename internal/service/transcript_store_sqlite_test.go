@@ -0,0 +1,104 @@
+//go:build sqlite
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-restream/stt/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transcriptsTableV1 is the transcripts table created before words and language existed
+const transcriptsTableV1 = `CREATE TABLE transcripts (
+	session_id     TEXT NOT NULL,
+	item_id        TEXT NOT NULL,
+	tenant         TEXT NOT NULL DEFAULT '',
+	status         TEXT NOT NULL,
+	transcript     TEXT NOT NULL DEFAULT '',
+	error          TEXT NOT NULL DEFAULT '',
+	created_at     DATETIME NOT NULL,
+	completed_at   DATETIME NOT NULL,
+	audio_start_ms BIGINT NOT NULL DEFAULT 0,
+	audio_end_ms   BIGINT NOT NULL DEFAULT 0,
+	duration_ms    BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (session_id, item_id)
+)`
+
+func TestSQLTranscriptStoreMigratesAndSaves(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "transcripts.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(transcriptsTableV1)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO transcripts (session_id, item_id, status, transcript, created_at, completed_at)
+		VALUES ('sess_old', 'item_old', 'completed', 'before the upgrade', ?, ?)`, time.Now().UTC(), time.Now().UTC())
+	require.NoError(t, err)
+
+	store, err := NewSQLTranscriptStore(db, "sqlite")
+	require.NoError(t, err)
+	// Opening the migrated table again adds nothing
+	_, err = NewSQLTranscriptStore(db, "sqlite")
+	require.NoError(t, err)
+
+	var words, language string
+	require.NoError(t, db.QueryRow(`SELECT words, language FROM transcripts WHERE item_id = 'item_old'`).Scan(&words, &language))
+	assert.Empty(t, words)
+	assert.Empty(t, language)
+
+	ctx := context.Background()
+	created := time.Now().Add(-time.Minute)
+	record := &TranscriptRecord{
+		SessionID:   "sess_1",
+		ItemID:      "item_1",
+		Tenant:      "acme",
+		Status:      "failed",
+		Error:       "asr_timeout",
+		CreatedAt:   created,
+		CompletedAt: created,
+	}
+	require.NoError(t, store.Save(ctx, record))
+
+	// A retried item replaces its earlier record
+	record.Status = "completed"
+	record.Error = ""
+	record.Transcript = "hello world"
+	record.CompletedAt = time.Now()
+	record.DurationMs = 900
+	record.Words = []llm.TranscriptionWord{
+		{Word: "hello", Start: 0.1, End: 0.4, Confidence: 0.98},
+		{Word: "world", Start: 0.5, End: 0.9, Confidence: 0.91},
+	}
+	record.Language = "en"
+	require.NoError(t, store.Save(ctx, record))
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM transcripts WHERE session_id = 'sess_1'`).Scan(&count))
+	assert.Equal(t, 1, count)
+
+	var status, transcript, errText string
+	var durationMs int64
+	require.NoError(t, db.QueryRow(`SELECT status, transcript, error, duration_ms, words, language FROM transcripts WHERE item_id = 'item_1'`).
+		Scan(&status, &transcript, &errText, &durationMs, &words, &language))
+	assert.Equal(t, "completed", status)
+	assert.Equal(t, "hello world", transcript)
+	assert.Empty(t, errText)
+	assert.EqualValues(t, 900, durationMs)
+	assert.Equal(t, "en", language)
+
+	var saved []llm.TranscriptionWord
+	require.NoError(t, json.Unmarshal([]byte(words), &saved))
+	assert.Equal(t, record.Words, saved)
+
+	deleted, err := store.DeleteBefore(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, deleted)
+}
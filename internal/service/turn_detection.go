@@ -0,0 +1,89 @@
+package service
+
+import (
+	"github.com/go-restream/stt/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TurnDetectionNone disables server turn detection for a session: no VAD runs and
+// nothing is committed automatically, input_audio_buffer.commit sends the audio
+// appended since the last commit to recognition
+const TurnDetectionNone = "none"
+
+// usesVAD reports whether the session's audio goes through the VAD. Without, the
+// raw audio buffer is committed by the client, also when VAD is disabled on the server.
+func (s *OpenAIService) usesVAD(session *Session) bool {
+	return s.vadIntegration != nil && session.TurnDetection.Type != TurnDetectionNone
+}
+
+// turnDetectionConfig returns cfg with the session's turn detection settings applied
+// to the VAD, once a session.update set them. Until then the VAD runs the server or
+// pipeline settings.
+func (s *Session) turnDetectionConfig(cfg *config.Config) *config.Config {
+	if !s.turnDetectionSet {
+		return cfg
+	}
+	return cfg.WithTurnDetection(s.TurnDetection.Threshold, s.TurnDetection.SilenceDurationMs, s.TurnDetection.PrefixPaddingMs)
+}
+
+// applyTurnDetection leases a VAD detector with the session's turn detection settings,
+// on top of its pipeline, or returns it for turn detection "none". Like applyPipeline
+// it is called with the session manager lock held from the connection's read loop, so
+// the replaced detector is idle; speech in progress is dropped. A detector already
+// running the settings is kept.
+func applyTurnDetection(sess *Session, cfg *config.Config) {
+	sess.turnDetectionSet = true
+
+	if sess.TurnDetection.Type == TurnDetectionNone {
+		if sess.VADDetector != nil {
+			sess.releaseVADDetector()
+			sess.IsSpeaking = false
+			sess.Logger().WithFields(logrus.Fields{
+				"component": "mg_session_ctrl",
+				"action":    "turn_detection_disabled",
+				"sessionID": sess.ID,
+			}).Info("Session VAD disabled, audio is committed by the client")
+		}
+		return
+	}
+
+	base := cfg
+	if sess.Pipeline != "" {
+		if pipeline, err := lookupPipeline(cfg, sess.Pipeline); err == nil {
+			base = cfg.WithPipeline(pipeline)
+		}
+	}
+	if !base.Vad.Enable {
+		return
+	}
+	if sess.VADDetector == nil {
+		// Raw audio appended without turn detection is not committed by the VAD
+		sess.AudioBufferMutex.Lock()
+		sess.AudioBuffer = sess.AudioBuffer[:0]
+		sess.AudioBufferMutex.Unlock()
+	}
+	derived := sess.turnDetectionConfig(base)
+	if sess.VADDetector != nil && modelPools.vadPool(derived) == sess.vadPool {
+		return
+	}
+
+	sess.acquireVADDetector(derived)
+	sess.IsSpeaking = false
+	sess.vadResetOffset = sess.vadSamplesFed.Load()
+
+	sess.Logger().WithFields(logrus.Fields{
+		"component":         "mg_session_ctrl",
+		"action":            "turn_detection_applied",
+		"sessionID":         sess.ID,
+		"threshold":         derived.Vad.Threshold,
+		"minSilenceSeconds": derived.Vad.MinSilenceDuration,
+		"prefixPaddingMs":   derived.Vad.PrefixPaddingMs,
+	}).Info("Session VAD reconfigured from turn_detection")
+}
+
+// rawAudioStartMs returns the stream position of the raw audio buffer holding samples,
+// counted like the audio_start_ms of VAD segments
+func (s *Session) rawAudioStartMs(samples int) int64 {
+	return (s.rawSamplesAppended - int64(samples)) * 1000 / 16000
+}
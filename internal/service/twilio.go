@@ -0,0 +1,313 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// Twilio Media Streams connect /v1/twilio from the <Stream> verb of a call and send its
+// audio as JSON messages: connected, start with the call and the stream's custom
+// parameters, media with 20ms of 8kHz μ-law per message, and stop. Each track of the
+// call is transcribed in a session of its own, like a client appending g711_ulaw
+// audio. Twilio reads no events, so transcripts reach the event sink, the transcript
+// history and the caption endpoints only.
+
+// Twilio Media Streams events
+const (
+	twilioEventConnected = "connected"
+	twilioEventStart     = "start"
+	twilioEventMedia     = "media"
+	twilioEventStop      = "stop"
+)
+
+// twilioMessage is a message of a Twilio media stream, the fields of its event set
+type twilioMessage struct {
+	Event          string `json:"event"`
+	SequenceNumber string `json:"sequenceNumber"`
+	StreamSid      string `json:"streamSid"`
+	Start          *struct {
+		AccountSid       string            `json:"accountSid"`
+		CallSid          string            `json:"callSid"`
+		Tracks           []string          `json:"tracks"`
+		CustomParameters map[string]string `json:"customParameters"`
+		MediaFormat      struct {
+			Encoding   string `json:"encoding"`
+			SampleRate int    `json:"sampleRate"`
+			Channels   int    `json:"channels"`
+		} `json:"mediaFormat"`
+	} `json:"start,omitempty"`
+	Media *struct {
+		Track     string `json:"track"`
+		Chunk     string `json:"chunk"`
+		Timestamp string `json:"timestamp"`
+		Payload   string `json:"payload"`
+	} `json:"media,omitempty"`
+}
+
+// twilioCall identifies the Twilio call a session transcribes a track of
+type twilioCall struct {
+	AccountSid string `json:"account_sid"`
+	CallSid    string `json:"call_sid"`
+	StreamSid  string `json:"stream_sid"`
+	Track      string `json:"track"` // "inbound" or "outbound"
+}
+
+// twilioStream is a media stream being transcribed, with a session per track
+type twilioStream struct {
+	call       twilioCall
+	parameters map[string]string
+	principal  *Principal
+	rateKey    string
+	rateIP     string
+	lease      *sessionLease // taken over by the first session
+	sessions   map[string]*Session
+}
+
+// HandleTwilioWebSocket serves a Twilio media stream. Twilio cannot set headers on
+// the connection nor query parameters on the stream URL, so an API key or token is
+// sent as the token custom parameter of the stream.
+func (s *OpenAIService) HandleTwilioWebSocket(c *gin.Context) {
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "svc_twilio",
+			"action":    "websocket_upgrade_failed",
+			"error":     err,
+		}).Error("WebSocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	var stream *twilioStream
+	defer func() {
+		if stream != nil {
+			s.endTwilioStream(stream)
+		}
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if stream != nil && websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.WithFields(logrus.Fields{
+					"component": "svc_twilio",
+					"action":    "twilio_stream_dropped",
+					"callSid":   stream.call.CallSid,
+					"error":     err,
+				}).Warn("Twilio media stream closed without stop message")
+			}
+			return
+		}
+
+		var msg twilioMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			logger.WithFields(logrus.Fields{
+				"component": "svc_twilio",
+				"action":    "twilio_message_invalid",
+				"error":     err,
+			}).Warn("Ignoring invalid Twilio message")
+			continue
+		}
+
+		switch msg.Event {
+		case twilioEventConnected:
+		case twilioEventStart:
+			if stream != nil || msg.Start == nil {
+				continue
+			}
+			if stream, err = s.startTwilioStream(c, &msg); err != nil {
+				logger.WithFields(logrus.Fields{
+					"component": "svc_twilio",
+					"action":    "twilio_stream_rejected",
+					"streamSid": msg.StreamSid,
+					"error":     err,
+				}).Warn("Rejected Twilio media stream")
+				closeTwilioStream(conn, err.Error())
+				return
+			}
+		case twilioEventMedia:
+			if stream == nil || msg.Media == nil {
+				continue
+			}
+			if err := s.appendTwilioMedia(c, stream, msg.Media.Track, msg.Media.Payload); err != nil {
+				logger.WithFields(logrus.Fields{
+					"component": "svc_twilio",
+					"action":    "twilio_media_failed",
+					"callSid":   stream.call.CallSid,
+					"track":     msg.Media.Track,
+					"error":     err,
+				}).Warn("Ending Twilio media stream")
+				closeTwilioStream(conn, "session_ended")
+				return
+			}
+		case twilioEventStop:
+			return
+		default:
+			// mark and dtmf messages carry nothing to transcribe
+		}
+	}
+}
+
+// startTwilioStream authenticates the start message of a stream and takes its quotas.
+// Sessions are opened as the tracks send audio.
+func (s *OpenAIService) startTwilioStream(c *gin.Context, msg *twilioMessage) (*twilioStream, error) {
+	format := msg.Start.MediaFormat
+	if format.Encoding != "audio/x-mulaw" || format.SampleRate != 8000 || format.Channels > 1 {
+		return nil, fmt.Errorf("unsupported media format %s/%d/%d", format.Encoding, format.SampleRate, format.Channels)
+	}
+
+	var principal *Principal
+	if s.authenticator != nil {
+		r := c.Request
+		if token := msg.Start.CustomParameters["token"]; token != "" && requestToken(r) == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		var err error
+		if principal, err = s.authenticator.Authenticate(r); err != nil {
+			return nil, err
+		}
+		if !principal.HasScope(ScopeTranscribe) {
+			return nil, fmt.Errorf("missing %s scope", ScopeTranscribe)
+		}
+	}
+
+	lease := &sessionLease{}
+	if principal != nil {
+		if !s.sessionQuota.acquire(principal) {
+			return nil, fmt.Errorf("concurrent session limit of %d reached", principal.MaxSessions)
+		}
+		lease.principal = principal
+	}
+	if s.rateLimiter != nil {
+		lease.rateKey, lease.rateIP = rateLimitClient(c, principal)
+		if details := s.rateLimiter.openSession(lease.rateKey, lease.rateIP); details != nil {
+			s.releaseLease(lease)
+			return nil, fmt.Errorf("rate limit %s exceeded", details.Name)
+		}
+		lease.rateOpen = true
+	}
+
+	stream := &twilioStream{
+		call: twilioCall{
+			AccountSid: msg.Start.AccountSid,
+			CallSid:    msg.Start.CallSid,
+			StreamSid:  msg.StreamSid,
+		},
+		parameters: msg.Start.CustomParameters,
+		principal:  principal,
+		rateKey:    lease.rateKey,
+		rateIP:     lease.rateIP,
+		lease:      lease,
+		sessions:   make(map[string]*Session),
+	}
+	logger.WithFields(logrus.Fields{
+		"component": "svc_twilio",
+		"action":    "twilio_stream_started",
+		"callSid":   stream.call.CallSid,
+		"streamSid": stream.call.StreamSid,
+		"tracks":    msg.Start.Tracks,
+	}).Info("Twilio media stream started")
+	return stream, nil
+}
+
+// appendTwilioMedia appends the μ-law payload of a media message to the session of its
+// track, opening the session on the track's first message
+func (s *OpenAIService) appendTwilioMedia(c *gin.Context, stream *twilioStream, track string, payload string) error {
+	if track == "" {
+		track = "inbound"
+	}
+	session, ok := stream.sessions[track]
+	if !ok {
+		var err error
+		if session, err = s.createTwilioSession(c, stream, track); err != nil {
+			return err
+		}
+		stream.sessions[track] = session
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return fmt.Errorf("invalid media payload: %w", err)
+	}
+	if err := s.sessionManager.UpdateHeartbeat(session.ID); err != nil {
+		// Ended by the session sweep or a usage limit
+		return err
+	}
+	return s.appendInputAudio(session, data, len(payload), time.Now())
+}
+
+// createTwilioSession opens the session of a track, with the language, model and
+// pipeline of the stream's custom parameters
+func (s *OpenAIService) createTwilioSession(c *gin.Context, stream *twilioStream, track string) (*Session, error) {
+	session, err := s.sessionManager.CreateSession(nil, "audio")
+	if err != nil {
+		return nil, err
+	}
+	if stream.lease != nil {
+		session.lease, stream.lease = stream.lease, nil
+	}
+	s.setupSession(c, session, stream.principal, stream.rateKey, stream.rateIP)
+
+	call := stream.call
+	call.Track = track
+	var pipelineErr error
+	s.sessionManager.UpdateSession(session.ID, func(sess *Session) {
+		sess.twilio = &call
+		sess.InputAudioFormat.Type = InputAudioFormatULaw
+		sess.InputAudioFormat.SampleRate = 8000
+		pipelineErr = applyServerSessionSettings(sess, s.appConfig(),
+			stream.parameters["language"], stream.parameters["model"], stream.parameters["pipeline"])
+	})
+	if pipelineErr != nil {
+		s.endSession(session, SessionEndError)
+		return nil, fmt.Errorf("invalid pipeline: %w", pipelineErr)
+	}
+
+	s.sendSessionCreated(session)
+	session.Logger().WithFields(logrus.Fields{
+		"component": "svc_twilio",
+		"action":    "twilio_session_created",
+		"sessionID": session.ID,
+		"callSid":   call.CallSid,
+		"track":     track,
+	}).Info("Created session of Twilio call track")
+	return session, nil
+}
+
+// endTwilioStream ends the sessions of a stream, whose uncommitted speech goes to the
+// final flush, and gives back the quotas of a stream that never sent audio
+func (s *OpenAIService) endTwilioStream(stream *twilioStream) {
+	for _, session := range stream.sessions {
+		if s.sessionManager.SessionExists(session.ID) {
+			s.endSession(session, SessionEndClosed)
+		}
+	}
+	if stream.lease != nil {
+		s.releaseLease(stream.lease)
+	}
+	logger.WithFields(logrus.Fields{
+		"component": "svc_twilio",
+		"action":    "twilio_stream_ended",
+		"callSid":   stream.call.CallSid,
+		"streamSid": stream.call.StreamSid,
+	}).Info("Twilio media stream ended")
+}
+
+// closeTwilioStream closes a media stream that cannot be transcribed; Twilio reads no
+// events, the close reason is logged in its debugger
+func closeTwilioStream(conn *websocket.Conn, reason string) {
+	if len(reason) > 120 {
+		reason = reason[:120]
+	}
+	message := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+}
@@ -2,12 +2,12 @@ package service
 
 import (
 	"fmt"
+	"math"
 	"time"
 
-	"github.com/go-restream/stt/config"
 	"github.com/go-restream/stt/pkg/logger"
+	"github.com/go-restream/stt/vad"
 
-	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,20 +15,17 @@ type VADIntegration struct {
 	sessionManager      *SessionManager
 	sampleBuffer        []float32
 	lastProcessingTime  time.Time
-	config              *config.Config
 }
 
-func NewVADIntegration(sessionManager *SessionManager, cfg *config.Config) *VADIntegration {
+func NewVADIntegration(sessionManager *SessionManager) *VADIntegration {
 	return &VADIntegration{
 		sessionManager:     sessionManager,
 		sampleBuffer:       make([]float32, 0),
 		lastProcessingTime: time.Now(),
-		config:             cfg,
 	}
 }
 
 func (vi *VADIntegration) ProcessAudioSamples(sessionID string, samples []int16) error {
-	startTime := time.Now()
 	if len(samples) == 0 {
 		logger.WithFields(logrus.Fields{
 			"component": "proc_vad_audio",
@@ -44,8 +41,16 @@ func (vi *VADIntegration) ProcessAudioSamples(sessionID string, samples []int16)
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
+	return vi.processSamples(session, samples)
+}
+
+// processSamples runs samples through the VAD detector of a session or of one of its
+// channel streams, see channels.go
+func (vi *VADIntegration) processSamples(session *Session, samples []int16) error {
+	startTime := time.Now()
+	sessionID := session.ID
 	if session.VADDetector == nil {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component": "proc_vad_audio",
 			"action":    "vad_detector_not_found",
 			"sessionID": sessionID,
@@ -70,7 +75,7 @@ func (vi *VADIntegration) ProcessAudioSamples(sessionID string, samples []int16)
 	}
 	avgAmplitude := float64(sumAmplitude) / float64(len(samples))
 
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component":    "proc_vad_audio",
 		"action":       "starting_processing",
 		"sampleCount":  len(samples),
@@ -86,7 +91,7 @@ func (vi *VADIntegration) ProcessAudioSamples(sessionID string, samples []int16)
 		floatSamples[i] = float32(sample) / 32768.0
 	}
 	conversionTime := time.Since(conversionStart)
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component":     "proc_vad_audio",
 		"action":        "conversion_completed",
 		"inputSamples":  len(samples),
@@ -95,6 +100,10 @@ func (vi *VADIntegration) ProcessAudioSamples(sessionID string, samples []int16)
 		"sessionID":     sessionID,
 	}).Debug("Converted int16 samples to float32 samples")
 
+	vadConfig := vi.sessionManager.Config().Vad
+	if session.vadConfig != nil {
+		vadConfig.MinSilenceDuration = session.vadConfig.Vad.MinSilenceDuration // session turn_detection
+	}
 	chunksProcessed := 0
 	speechSegmentsDetected := 0
 	vadProcessingTime := time.Duration(0)
@@ -110,6 +119,7 @@ func (vi *VADIntegration) ProcessAudioSamples(sessionID string, samples []int16)
 
 		if len(vi.sampleBuffer) >= 160 {
 			chunksProcessed++
+			session.vadSamplesFed.Add(int64(len(vi.sampleBuffer)))
 			vadStart := time.Now()
 			segment := session.VADDetector.ProcessSamples(vi.sampleBuffer)
 			vadProcessingTime += time.Since(vadStart)
@@ -117,7 +127,7 @@ func (vi *VADIntegration) ProcessAudioSamples(sessionID string, samples []int16)
 
 			if segment != nil && len(segment.Samples) > 0 {
 				speechSegmentsDetected++
-				logger.WithFields(logrus.Fields{
+				session.Logger().WithFields(logrus.Fields{
 					"component":   "proc_vad_audio",
 					"action":      "speech_segment_detected",
 					"sampleCount": len(segment.Samples),
@@ -126,38 +136,39 @@ func (vi *VADIntegration) ProcessAudioSamples(sessionID string, samples []int16)
 				}).Info("Speech segment detected")
 
 				if !session.IsSpeaking {
-					logger.WithFields(logrus.Fields{
+					session.Logger().WithFields(logrus.Fields{
 						"component": "proc_vad_audio",
 						"action":    "transition_to_speaking",
 						"sessionID": sessionID,
 					}).Info("Transition to speaking state")
-					vi.handleSpeechStarted(sessionID)
+					vi.handleSpeechStarted(session)
 				}
 				session.SpeechStartTime = time.Now()
 
-				vi.processSpeechSegment(sessionID, segment)
+				vi.sendSegmentEvent(session, segment)
+				vi.processSpeechSegment(session, segment)
 			} else {
 				silenceTimeout := 500 * time.Millisecond // Default 500ms silence timeout
-				if vi.config.Vad.MinSilenceDuration > 0 {
-					silenceTimeout = time.Duration(vi.config.Vad.MinSilenceDuration * 1000) * time.Millisecond
+				if vadConfig.MinSilenceDuration > 0 {
+					silenceTimeout = time.Duration(vadConfig.MinSilenceDuration * 1000) * time.Millisecond
 				}
 
 				if session.IsSpeaking && time.Since(session.SpeechStartTime) > silenceTimeout {
-					logger.WithFields(logrus.Fields{
+					session.Logger().WithFields(logrus.Fields{
 						"component":       "proc_vad_audio",
 						"action":          "speech_timeout_detected",
 						"sessionID":       sessionID,
 						"silenceDuration": time.Since(session.SpeechStartTime),
 						"timeout":         silenceTimeout,
 					}).Info("Speech timeout detected - stopping speech")
-					vi.handleSpeechStopped(sessionID)
+					vi.handleSpeechStopped(session)
 				}
 			}
 		}
 	}
 
 	totalTime := time.Since(startTime)
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component":           "proc_vad_audio",
 		"action":              "processing_completed",
 		"chunksProcessed":     chunksProcessed,
@@ -168,29 +179,29 @@ func (vi *VADIntegration) ProcessAudioSamples(sessionID string, samples []int16)
 		"conversionTime":      conversionTime,
 	}).Debug("Completed VAD processing")
 
-		if vi.config.Vad.ForceASRAfterSeconds > 0 {
-				if bufferSize, err := vi.sessionManager.GetVADAudioBuffer(sessionID); err == nil && len(bufferSize) > 16000 { // 1 second of audio at 16kHz
+		if vadConfig.ForceASRAfterSeconds > 0 {
+				if bufferSize := session.vadAudioSize(); bufferSize > 16000 { // 1 second of audio at 16kHz
 			timeSinceLastProcess := time.Since(vi.lastProcessingTime)
-			logger.WithFields(logrus.Fields{
+			session.Logger().WithFields(logrus.Fields{
 				"component":           "vad",
 				"action":              "checking_timer",
 				"sessionID":           sessionID,
-				"vadBufferSize":       len(bufferSize),
+				"vadBufferSize":       bufferSize,
 				"timeSinceLastProcess": timeSinceLastProcess.Seconds(),
-				"forceAfterSeconds":   vi.config.Vad.ForceASRAfterSeconds,
+				"forceAfterSeconds":   vadConfig.ForceASRAfterSeconds,
 			}).Debug("Checking ASR trigger timer")
 
-			if timeSinceLastProcess.Seconds() >= float64(vi.config.Vad.ForceASRAfterSeconds) {
-				logger.WithFields(logrus.Fields{
+			if timeSinceLastProcess.Seconds() >= float64(vadConfig.ForceASRAfterSeconds) {
+				session.Logger().WithFields(logrus.Fields{
 					"component":           "vad",
 					"action":              "force_asr_trigger",
 					"sessionID":           sessionID,
-					"vadBufferSize":       len(bufferSize),
+					"vadBufferSize":       bufferSize,
 					"timeSinceLastProcess": timeSinceLastProcess.Seconds(),
-					"forceAfterSeconds":   vi.config.Vad.ForceASRAfterSeconds,
+					"forceAfterSeconds":   vadConfig.ForceASRAfterSeconds,
 				}).Warn("Force triggering ASR processing (testing mode)")
 
-								vi.handleSpeechStopped(sessionID)
+								vi.handleSpeechStopped(session)
 
 								vi.lastProcessingTime = time.Now()
 			}
@@ -200,37 +211,37 @@ func (vi *VADIntegration) ProcessAudioSamples(sessionID string, samples []int16)
 	return nil
 }
 
-func (vi *VADIntegration) handleSpeechStarted(sessionID string) {
-	vi.sessionManager.UpdateSession(sessionID, func(sess *Session) {
+func (vi *VADIntegration) handleSpeechStarted(session *Session) {
+	sessionID := session.ID
+	owner := session.owner()
+	vi.sessionManager.updateStream(session, func(sess *Session) {
 		sess.IsSpeaking = true
 		sess.SpeechStartTime = time.Now()
+		sess.speechStartedAt = owner.Now()
 	})
+	owner.captionEdge.speechStarted()
 
-	session, exists := vi.sessionManager.GetSession(sessionID)
-	if !exists {
-		return
-	}
-
-	audioStartMs := int(time.Since(session.SpeechStartTime).Milliseconds())
+	audioStartMs := int(owner.Now().Sub(session.speechStartedAt).Milliseconds())
 
 	speechStartedEvent := &InputAudioBufferSpeechStartedEvent{
 		BaseEvent: BaseEvent{
 			Type:      EventTypeInputAudioBufferSpeechStarted,
-			EventID:   GenerateEventID(),
+			EventID:   owner.NewEventID(),
 			SessionID: sessionID,
 		},
 		AudioStartMs: audioStartMs,
+		Channel:      session.channelIndex(),
 	}
 
-	if err := vi.sessionManager.SendEventToSession(sessionID, speechStartedEvent); err != nil {
-		logger.WithFields(logrus.Fields{
+	if err := vi.sessionManager.SendEvent(owner, speechStartedEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
 			"component":   "ws_event_send",
 			"action":      "send_speech_started_event_failed",
 			"sessionID":   sessionID,
 			"error":       err,
 		}).Error("Failed to send speech started event")
 	} else {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":    "ws_event_send",
 			"action":       "speech_started_detected",
 			"sessionID":    sessionID,
@@ -239,14 +250,17 @@ func (vi *VADIntegration) handleSpeechStarted(sessionID string) {
 	}
 }
 
-func (vi *VADIntegration) handleSpeechStopped(sessionID string) {
-	session, exists := vi.sessionManager.GetSession(sessionID)
-	if !exists {
-		return
-	}
+func (vi *VADIntegration) handleSpeechStopped(session *Session) {
+	vi.stopSpeech(session, "")
+}
 
+// stopSpeech ends the turn of a session or channel stream with speech_stopped giving
+// reason, empty when the speaker paused
+func (vi *VADIntegration) stopSpeech(session *Session, reason string) {
+	sessionID := session.ID
+	owner := session.owner()
 	if !session.IsSpeaking {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component": "proc_vad_audio",
 			"action":    "speech_stopped_already_not_speaking",
 			"sessionID": sessionID,
@@ -254,30 +268,32 @@ func (vi *VADIntegration) handleSpeechStopped(sessionID string) {
 		return
 	}
 
-	vi.sessionManager.UpdateSession(sessionID, func(sess *Session) {
+	vi.sessionManager.updateStream(session, func(sess *Session) {
 		sess.IsSpeaking = false
 	})
 
-	audioEndMs := int(time.Since(session.SpeechStartTime).Milliseconds())
+	audioEndMs := int(owner.Now().Sub(session.speechStartedAt).Milliseconds())
 
 	speechStoppedEvent := &InputAudioBufferSpeechStoppedEvent{
 		BaseEvent: BaseEvent{
 			Type:      EventTypeInputAudioBufferSpeechStopped,
-			EventID:   GenerateEventID(),
+			EventID:   owner.NewEventID(),
 			SessionID: sessionID,
 		},
 		AudioEndMs: audioEndMs,
+		Channel:    session.channelIndex(),
+		Reason:     reason,
 	}
 
-	if err := vi.sessionManager.SendEventToSession(sessionID, speechStoppedEvent); err != nil {
-		logger.WithFields(logrus.Fields{
+	if err := vi.sessionManager.SendEvent(owner, speechStoppedEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
 			"component":   "ws_event_send",
 			"action":      "send_speech_stopped_event_failed",
 			"sessionID":   sessionID,
 			"error":       err,
 		}).Error("Failed to send speech stopped event")
 	} else {
-		logger.WithFields(logrus.Fields{
+		session.Logger().WithFields(logrus.Fields{
 			"component":  "ws_event_send",
 			"action":     "speech_stopped_detected",
 			"sessionID":  sessionID,
@@ -286,15 +302,81 @@ func (vi *VADIntegration) handleSpeechStopped(sessionID string) {
 	}
 
 	// OpenAI Realtime API spec: CLIENT sends input_audio_buffer.commit after speech_stopped
-	logger.WithFields(logrus.Fields{
+	session.Logger().WithFields(logrus.Fields{
 		"component": "ws_event_send",
 		"action":    "speech_stopped_completed",
 		"sessionID": sessionID,
 	}).Info("Speech stopped completed - waiting for client to send commit message")
 }
 
-func (vi *VADIntegration) processSpeechSegment(sessionID string, segment *sherpa.SpeechSegment) {
+// sendSegmentEvent emits a vad.segment event describing the bounds and level of a
+// detected speech segment, before any denoising
+func (vi *VADIntegration) sendSegmentEvent(session *Session, segment *vad.SpeechSegment) {
+	// An empty segment has no RMS, the NaN would fail encoding the event
+	if len(segment.Samples) == 0 {
+		return
+	}
+
+	sampleRate := vi.sessionManager.Config().Vad.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+
+	var sumSquares float64
+	for _, sample := range segment.Samples {
+		sumSquares += float64(sample) * float64(sample)
+	}
+	rms := math.Sqrt(sumSquares / float64(len(segment.Samples)))
+
+	startSample := session.vadResetOffset + int64(segment.Start)
+	endSample := startSample + int64(len(segment.Samples))
+	startMs := startSample * 1000 / int64(sampleRate)
+	endMs := endSample * 1000 / int64(sampleRate)
+
+	segmentEvent := &VADSegmentEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeVADSegment,
+			EventID:   session.owner().NewEventID(),
+			SessionID: session.ID,
+		},
+		SegmentIndex: session.vadSegmentCount,
+		StartMs:      startMs,
+		EndMs:        endMs,
+		DurationMs:   endMs - startMs,
+		RMS:          math.Round(rms*10000) / 10000,
+		Channel:      session.channelIndex(),
+	}
+	session.vadSegmentCount++
+	if !session.hasUtteranceStart {
+		session.utteranceStartMs = startMs
+		session.hasUtteranceStart = true
+	}
+	session.talkTime.AddSegment(session.speaker(), startMs, endMs)
+	session.recordSpeech(time.Duration(len(segment.Samples)) * time.Second / time.Duration(sampleRate))
+
+	if err := vi.sessionManager.SendEvent(session.owner(), segmentEvent); err != nil {
+		session.Logger().WithFields(logrus.Fields{
+			"component": "ws_event_send",
+			"action":    "send_vad_segment_failed",
+			"sessionID": session.ID,
+			"error":     err,
+		}).Error("Failed to send vad.segment event")
+		return
+	}
+
+	session.Logger().WithFields(logrus.Fields{
+		"component":  "ws_event_send",
+		"action":     "vad_segment_sent",
+		"sessionID":  session.ID,
+		"startMs":    startMs,
+		"durationMs": endMs - startMs,
+		"rms":        segmentEvent.RMS,
+	}).Debug("Sent vad.segment event")
+}
+
+func (vi *VADIntegration) processSpeechSegment(session *Session, segment *vad.SpeechSegment) {
 	startTime := time.Now()
+	sessionID := session.ID
 
 	if segment == nil || len(segment.Samples) == 0 {
 		logger.WithFields(logrus.Fields{
@@ -316,10 +398,9 @@ func (vi *VADIntegration) processSpeechSegment(sessionID string, segment *sherpa
 
 	// Apply denoising if enabled and available
 	processedSegment := segment
-	session, exists := vi.sessionManager.GetSession(sessionID)
-	if exists && session.DenoiserProcessor != nil && vi.config.Denoiser.Enable {
+	if session.denoiserPool != nil {
 		denoiserStart := time.Now()
-		enhancedSegment := session.DenoiserProcessor.ProcessSegment(segment)
+		enhancedSegment := session.denoiseWithStrength(segment, vi.sessionManager.Config())
 		denoiserTime := time.Since(denoiserStart)
 
 		if enhancedSegment != nil && len(enhancedSegment.Samples) > 0 {
@@ -359,34 +440,16 @@ func (vi *VADIntegration) processSpeechSegment(sessionID string, segment *sherpa
 	}).Info("Converted float32 samples to int16 samples")
 
 		bufferAddStart := time.Now()
-	if err := vi.sessionManager.AddVADAudioToBuffer(sessionID, samples); err != nil {
-		logger.WithFields(logrus.Fields{
-			"component":   "proc_vad_audio",
-			"action":      "add_speech_to_vad_buffer_failed",
-			"sessionID":   sessionID,
-			"error":       err,
-		}).Error("Failed to add speech segment to VAD buffer")
-		return
-	}
+	session.appendVADAudio(samples)
 	bufferAddTime := time.Since(bufferAddStart)
 
-		bufferSize, err := vi.sessionManager.GetVADAudioBufferSize(sessionID)
-	if err != nil {
-		logger.WithFields(logrus.Fields{
-			"component":   "proc_vad_audio",
-			"action":      "get_vad_buffer_size_failed",
-			"sessionID":   sessionID,
-			"error":       err,
-		}).Error("Failed to get VAD audio buffer size")
-	} else {
-		logger.WithFields(logrus.Fields{
-			"component":    "proc_vad_audio",
-			"action":       "speech_segment_added_to_vad_buffer",
-			"vadBufferSize":   bufferSize,
-			"addTime":      bufferAddTime,
-			"sessionID":    sessionID,
-		}).Info("Audio buffer now contains samples after adding speech segment")
-	}
+	logger.WithFields(logrus.Fields{
+		"component":    "proc_vad_audio",
+		"action":       "speech_segment_added_to_vad_buffer",
+		"vadBufferSize":   session.vadAudioSize(),
+		"addTime":      bufferAddTime,
+		"sessionID":    sessionID,
+	}).Info("Audio buffer now contains samples after adding speech segment")
 
 	// Speech segments accumulated in VAD buffer, committed when client sends input_audio_buffer.commit
 	logger.WithFields(logrus.Fields{
@@ -409,6 +472,7 @@ func (vi *VADIntegration) Reset(sessionID string) {
 
 	vi.sessionManager.UpdateSession(sessionID, func(sess *Session) {
 		sess.IsSpeaking = false
+		sess.vadResetOffset = sess.vadSamplesFed.Load()
 	})
 }
 
@@ -418,4 +482,22 @@ func (vi *VADIntegration) IsSpeaking(sessionID string) bool {
 		return false
 	}
 	return session.IsSpeaking
-}
\ No newline at end of file
+}
+// Flush moves the speech still held by the session's VAD detectors into the VAD audio
+// buffers, used when the stream ends before the speaker paused
+func (vi *VADIntegration) Flush(sessionID string) {
+	session, exists := vi.sessionManager.GetSession(sessionID)
+	if !exists {
+		return
+	}
+
+	for _, stream := range session.vadStreams() {
+		if stream.VADDetector == nil {
+			continue
+		}
+		segments := stream.VADDetector.Flush()
+		for i := range segments {
+			vi.processSpeechSegment(stream, &segments[i])
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Package testutil provides the doubles of the service's integration tests: a fake
+// OpenAI-compatible ASR engine, a realtime WebSocket client and synthetic audio.
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ASRRequest is a transcription request the fake ASR engine received
+type ASRRequest struct {
+	Model          string
+	Language       string
+	Prompt         string
+	ResponseFormat string
+	Audio          []byte // the uploaded WAV file
+	Header         http.Header
+}
+
+// ASRServer is a fake OpenAI-compatible ASR engine serving POST /v1/audio/transcriptions.
+// It answers with a deterministic transcript per request, after the configured latency,
+// and fails the requests it was told to fail.
+type ASRServer struct {
+	*httptest.Server
+
+	mutex       sync.Mutex
+	transcripts []string
+	language    string
+	latency     time.Duration
+	failures    int
+	failStatus  int
+	requests    []ASRRequest
+	cancelled   int
+}
+
+// NewASRServer starts a fake ASR engine, closed when the test ends
+func NewASRServer(t testing.TB) *ASRServer {
+	t.Helper()
+	s := &ASRServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/audio/transcriptions", s.handleTranscription)
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": []any{}})
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// BaseURL returns the URL to configure as asr.base_url
+func (s *ASRServer) BaseURL() string {
+	return s.URL + "/v1"
+}
+
+// SetTranscripts sets the transcripts of the following requests, in order. Requests
+// beyond the list get "transcript N", N counting requests from 1.
+func (s *ASRServer) SetTranscripts(transcripts ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.transcripts = append([]string(nil), transcripts...)
+}
+
+// SetLanguage sets the language reported in verbose_json responses
+func (s *ASRServer) SetLanguage(language string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.language = language
+}
+
+// SetLatency delays every response by d
+func (s *ASRServer) SetLatency(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.latency = d
+}
+
+// FailNext makes the next n requests fail with the HTTP status
+func (s *ASRServer) FailNext(n int, status int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures, s.failStatus = n, status
+}
+
+// Cancelled returns the number of requests the client abandoned before the response
+func (s *ASRServer) Cancelled() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.cancelled
+}
+
+// Requests returns the requests received so far
+func (s *ASRServer) Requests() []ASRRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]ASRRequest(nil), s.requests...)
+}
+
+func (s *ASRServer) handleTranscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "missing file")
+		return
+	}
+	audio, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	request := ASRRequest{
+		Model:          r.FormValue("model"),
+		Language:       r.FormValue("language"),
+		Prompt:         r.FormValue("prompt"),
+		ResponseFormat: r.FormValue("response_format"),
+		Audio:          audio,
+		Header:         r.Header.Clone(),
+	}
+
+	s.mutex.Lock()
+	s.requests = append(s.requests, request)
+	n := len(s.requests)
+	latency := s.latency
+	fail := s.failures > 0
+	status := s.failStatus
+	if fail {
+		s.failures--
+	}
+	text := fmt.Sprintf("transcript %d", n)
+	if len(s.transcripts) > 0 {
+		text, s.transcripts = s.transcripts[0], s.transcripts[1:]
+	}
+	language := s.language
+	s.mutex.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			s.mutex.Lock()
+			s.cancelled++
+			s.mutex.Unlock()
+			return
+		}
+	}
+	if fail {
+		writeError(w, status, "injected failure")
+		return
+	}
+
+	response := map[string]any{"text": text}
+	if request.ResponseFormat == "verbose_json" {
+		response["language"] = language
+		response["words"] = words(text)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// words gives the words of text consecutive half second timings
+func words(text string) []map[string]any {
+	var result []map[string]any
+	for i, word := range strings.Fields(text) {
+		result = append(result, map[string]any{
+			"word":        word,
+			"start":       float64(i) * 0.5,
+			"end":         float64(i+1) * 0.5,
+			"probability": 0.9,
+		})
+	}
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{"error": map[string]any{"message": message, "type": "test_error"}})
+}
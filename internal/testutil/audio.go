@@ -0,0 +1,40 @@
+package testutil
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// Speech returns d of voiced, speech-like PCM16 audio at sampleRate: a 150Hz tone with
+// harmonics and a syllable-rate envelope, loud enough for either VAD engine
+func Speech(d time.Duration, sampleRate int) []byte {
+	n := samples(d, sampleRate)
+	pcm := make([]byte, 2*n)
+	for i := 0; i < n; i++ {
+		t := float64(i) / float64(sampleRate)
+		envelope := 0.7 + 0.3*math.Sin(2*math.Pi*4*t)
+		value := 0.0
+		for harmonic := 1; harmonic <= 4; harmonic++ {
+			value += math.Sin(2*math.Pi*150*float64(harmonic)*t) / float64(harmonic)
+		}
+		sample := int16(value * envelope * 0.25 * math.MaxInt16)
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(sample))
+	}
+	return pcm
+}
+
+// Silence returns d of PCM16 silence at sampleRate
+func Silence(d time.Duration, sampleRate int) []byte {
+	return make([]byte, 2*samples(d, sampleRate))
+}
+
+// Utterance returns speech of d followed by trailing silence, which ends the turn of
+// server VAD sessions
+func Utterance(d, trailing time.Duration, sampleRate int) []byte {
+	return append(Speech(d, sampleRate), Silence(trailing, sampleRate)...)
+}
+
+func samples(d time.Duration, sampleRate int) int {
+	return int(d.Seconds() * float64(sampleRate))
+}
@@ -0,0 +1,255 @@
+package testutil
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Event is a server event received by a WSClient
+type Event struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+// Decode unmarshals the event into v
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Raw, v)
+}
+
+// Field returns a top-level field of the event, nil when missing
+func (e Event) Field(name string) any {
+	var fields map[string]any
+	if json.Unmarshal(e.Raw, &fields) != nil {
+		return nil
+	}
+	return fields[name]
+}
+
+// Transcript returns the transcript of a conversation.item.input_audio_transcription
+// completed event, empty for other events
+func (e Event) Transcript() string {
+	var event struct {
+		Item struct {
+			Content []struct {
+				Transcript string `json:"transcript"`
+			} `json:"content"`
+		} `json:"item"`
+	}
+	if json.Unmarshal(e.Raw, &event) != nil {
+		return ""
+	}
+	var parts []string
+	for _, content := range event.Item.Content {
+		parts = append(parts, content.Transcript)
+	}
+	return strings.Join(parts, " ")
+}
+
+// WSClient is a realtime API client for tests. A goroutine reads the server events so
+// that tests can wait for them while sending.
+type WSClient struct {
+	t      testing.TB
+	conn   *websocket.Conn
+	events chan Event
+
+	writeMutex sync.Mutex
+	mutex      sync.Mutex
+	received   []Event
+	readErr    error
+}
+
+// DialRealtime connects to the realtime WebSocket of the server at baseURL, an http://
+// URL such as httptest.Server.URL. The connection is closed when the test ends.
+func DialRealtime(t testing.TB, baseURL string, header http.Header) *WSClient {
+	t.Helper()
+	return DialRealtimeQuery(t, baseURL, nil, header)
+}
+
+// DialRealtimeQuery connects like DialRealtime, with query as the query string of
+// the WebSocket URL
+func DialRealtimeQuery(t testing.TB, baseURL string, query url.Values, header http.Header) *WSClient {
+	t.Helper()
+	target := "ws" + strings.TrimPrefix(baseURL, "http") + "/v1/realtime"
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(target, header)
+	if err != nil {
+		t.Fatalf("dial %s: %v", target, err)
+	}
+	c := &WSClient{t: t, conn: conn, events: make(chan Event, 1024)}
+	go c.readLoop()
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func (c *WSClient) readLoop() {
+	defer close(c.events)
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.mutex.Lock()
+			c.readErr = err
+			c.mutex.Unlock()
+			return
+		}
+		var head struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(data, &head) != nil {
+			continue
+		}
+		event := Event{Type: head.Type, Raw: json.RawMessage(data)}
+		c.mutex.Lock()
+		c.received = append(c.received, event)
+		c.mutex.Unlock()
+		c.events <- event
+	}
+}
+
+// Send sends a client event, filling in its type
+func (c *WSClient) Send(eventType string, fields map[string]any) {
+	c.t.Helper()
+	event := map[string]any{"type": eventType}
+	for key, value := range fields {
+		event[key] = value
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		c.t.Fatalf("marshal %s: %v", eventType, err)
+	}
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		c.t.Fatalf("send %s: %v", eventType, err)
+	}
+}
+
+// UpdateSession sends session.update with the given session fields
+func (c *WSClient) UpdateSession(session map[string]any) {
+	c.t.Helper()
+	c.Send("session.update", map[string]any{"session": session})
+}
+
+// AppendAudio sends PCM16 audio in input_audio_buffer.append events of chunk bytes
+// each, all at once when chunk is not positive
+func (c *WSClient) AppendAudio(pcm []byte, chunk int) {
+	c.t.Helper()
+	if chunk <= 0 {
+		chunk = len(pcm)
+	}
+	for start := 0; start < len(pcm); start += chunk {
+		end := min(start+chunk, len(pcm))
+		c.Send("input_audio_buffer.append", map[string]any{"audio": base64.StdEncoding.EncodeToString(pcm[start:end])})
+	}
+}
+
+// StreamAudio sends PCM16 audio at sampleRate in chunks of the given duration, paced
+// in real time like a microphone. The VAD ends speech after silence measured on the
+// wall clock, so turns only end when audio is streamed.
+func (c *WSClient) StreamAudio(pcm []byte, sampleRate int, chunk time.Duration) {
+	c.t.Helper()
+	size := 2 * samples(chunk, sampleRate)
+	start := time.Now()
+	for offset := 0; offset < len(pcm); offset += size {
+		end := min(offset+size, len(pcm))
+		c.AppendAudio(pcm[offset:end], 0)
+		sent := time.Duration(float64(end/2) / float64(sampleRate) * float64(time.Second))
+		time.Sleep(time.Until(start.Add(sent)))
+	}
+}
+
+// Commit sends input_audio_buffer.commit
+func (c *WSClient) Commit() {
+	c.t.Helper()
+	c.Send("input_audio_buffer.commit", nil)
+}
+
+// Next returns the next server event, failing the test when none arrives within timeout
+func (c *WSClient) Next(timeout time.Duration) Event {
+	c.t.Helper()
+	select {
+	case event, ok := <-c.events:
+		if !ok {
+			c.t.Fatalf("connection closed: %v", c.err())
+		}
+		return event
+	case <-time.After(timeout):
+		c.t.Fatalf("no event within %v", timeout)
+		return Event{}
+	}
+}
+
+// WaitFor returns the next event of the type, skipping others, failing the test when
+// none arrives within timeout
+func (c *WSClient) WaitFor(eventType string, timeout time.Duration) Event {
+	c.t.Helper()
+	event, err := c.waitFor(eventType, timeout)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return event
+}
+
+func (c *WSClient) waitFor(eventType string, timeout time.Duration) (Event, error) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case event, ok := <-c.events:
+			if !ok {
+				return Event{}, fmt.Errorf("connection closed waiting for %s: %v", eventType, c.err())
+			}
+			if event.Type == eventType {
+				return event, nil
+			}
+		case <-deadline:
+			return Event{}, fmt.Errorf("no %s event within %v, received %v", eventType, timeout, c.Types())
+		}
+	}
+}
+
+// Received returns all events received so far, in order
+func (c *WSClient) Received() []Event {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]Event(nil), c.received...)
+}
+
+// Types returns the types of all events received so far, in order
+func (c *WSClient) Types() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	types := make([]string, len(c.received))
+	for i, event := range c.received {
+		types[i] = event.Type
+	}
+	return types
+}
+
+// Close closes the connection
+func (c *WSClient) Close() error {
+	c.writeMutex.Lock()
+	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.writeMutex.Unlock()
+	return c.conn.Close()
+}
+
+// Drop closes the connection without a close frame, as a network failure does
+func (c *WSClient) Drop() error {
+	return c.conn.Close()
+}
+
+func (c *WSClient) err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.readErr
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-restream/stt/pkg/jwe"
+)
+
+const keygenUsage = `Usage: stt keygen
+
+Generates an X25519 key pair for transcript encryption. The public key goes into a
+recipient_key of the configuration, the private key stays with the recipient, which
+decrypts the JWE payloads with it.
+`
+
+// runKeygenCommand implements "stt keygen" and returns the exit code
+func runKeygenCommand(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprint(os.Stderr, keygenUsage)
+		return 2
+	}
+
+	privateKey, publicKey, err := jwe.GenerateKey()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✘ generate key failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("public key (recipient_key): %s\n", publicKey)
+	fmt.Printf("private key (keep secret):  %s\n", privateKey)
+	return 0
+}
@@ -2,12 +2,19 @@ package llm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-restream/stt/pkg/logger"
@@ -18,29 +25,161 @@ var  (
 	asrApiKey = os.Getenv("OPENAI_API_KEY")
 	asrBaseURL = "http://localhost:3000/v1"
 	asrModel = "FunAudioLLM/SenseVoiceSmall"
+	asrWordConfidence = false
+	asrHintsField = HintsFieldPrompt
+
+	// The settings are replaced while calls are running when the configuration is reloaded
+	asrSettingsMutex sync.RWMutex
 )
 
 func SetAsrBaseURL(url string) {
+	asrSettingsMutex.Lock()
+	defer asrSettingsMutex.Unlock()
 	asrBaseURL = url
 }
 func SetAsrApiKey(ak string) {
+	asrSettingsMutex.Lock()
+	defer asrSettingsMutex.Unlock()
 	asrApiKey = ak
 }
 func SetAsrModel(model string) {
+	asrSettingsMutex.Lock()
+	defer asrSettingsMutex.Unlock()
 	asrModel = model
 }
 
+// SetAsrWordConfidence requests verbose_json responses with word timestamps, from
+// which per-word confidence is read. The backend must support the verbose format.
+func SetAsrWordConfidence(enabled bool) {
+	asrSettingsMutex.Lock()
+	defer asrSettingsMutex.Unlock()
+	asrWordConfidence = enabled
+}
+
+// SetAsrHintsField selects the form field custom vocabulary is sent in, HintsFieldPrompt
+// or HintsFieldHotwords
+func SetAsrHintsField(field string) {
+	asrSettingsMutex.Lock()
+	defer asrSettingsMutex.Unlock()
+	if field == "" {
+		field = HintsFieldPrompt
+	}
+	asrHintsField = field
+}
+
+// asrSettings returns the global base URL, API key and model of calls, and whether
+// word confidence is requested
+func asrSettings() (string, string, string, bool) {
+	asrSettingsMutex.RLock()
+	defer asrSettingsMutex.RUnlock()
+	return asrBaseURL, asrApiKey, asrModel, asrWordConfidence
+}
+
 // CallOpenaiAPI calls OpenAI-compatible speech recognition API at "$BaseURL + /audio/transcriptions"
 func CallOpenaiAPI(audioData []byte) (string, error) {
+	return CallOpenaiAPIWithHeaders(audioData, nil)
+}
+
+// CallOpenaiAPIWithHeaders is like CallOpenaiAPI but adds the given headers to the
+// backend request. A forwarded Authorization header replaces the shared API key so
+// quotas can be enforced per user by the engine.
+func CallOpenaiAPIWithHeaders(audioData []byte, headers http.Header) (string, error) {
+	return CallOpenaiAPIWithEndpoint(audioData, headers, nil)
+}
+
+// Endpoint selects the ASR backend of a call, empty fields use the global settings
+type Endpoint struct {
+	BaseURL  string
+	APIKey   string
+	Model    string
+	Language string // ISO-639-1 hint sent with the request, empty lets the backend detect it
+	DetectLanguage bool // asks for verbose_json, which names the detected language
+	Hints    []string // custom vocabulary sent in the hints field, see SetAsrHintsField
+	Timeout  time.Duration // bounds the whole call, 0 waits indefinitely
+}
+
+// Form fields custom vocabulary is sent in: Whisper's prompt, which biases decoding
+// towards the words it contains, or the hotwords of engines like FunASR and
+// faster-whisper servers
+const (
+	HintsFieldPrompt   = "prompt"
+	HintsFieldHotwords = "hotwords"
+)
+
+// hintsField returns the form field and value of custom vocabulary
+func hintsField(hints []string) (string, string) {
+	asrSettingsMutex.RLock()
+	field := asrHintsField
+	asrSettingsMutex.RUnlock()
+
+	if field == HintsFieldHotwords {
+		return field, strings.Join(hints, " ")
+	}
+	return field, strings.Join(hints, ", ")
+}
+
+// StatusError is an error response of the ASR backend
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API error: %s, response: %s", e.Status, e.Body)
+}
+
+// Retryable reports whether a failed call may succeed on another replica of the
+// backend: the backend was unreachable, timed out or answered with a 5xx status
+func Retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+	var netErr net.Error // e.g. the timeout expiring while the response is read
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// CallOpenaiAPIWithEndpoint is like CallOpenaiAPIWithHeaders but sends the request to
+// the given endpoint, e.g. the provider of a session's pipeline
+func CallOpenaiAPIWithEndpoint(audioData []byte, headers http.Header, endpoint *Endpoint) (string, error) {
+	result, err := TranscribeWithEndpoint(context.Background(), audioData, headers, endpoint)
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+// TranscribeWithEndpoint is like CallOpenaiAPIWithEndpoint but returns the words of
+// the transcript with their confidence when word confidence is enabled. The request is
+// abandoned when ctx is cancelled.
+func TranscribeWithEndpoint(ctx context.Context, audioData []byte, headers http.Header, endpoint *Endpoint) (*Transcription, error) {
 	startTime := time.Now()
 
+	baseURL, apiKey, model, wordConfidence := asrSettings()
+	if endpoint != nil {
+		if endpoint.BaseURL != "" {
+			baseURL = endpoint.BaseURL
+		}
+		if endpoint.APIKey != "" {
+			apiKey = endpoint.APIKey
+		}
+		if endpoint.Model != "" {
+			model = endpoint.Model
+		}
+	}
+
 	logger.WithFields(logrus.Fields{
 		"component": "api_asr_service",
 		"action":        "call_start",
 		"audioSize":     len(audioData),
-		"baseURL":       asrBaseURL,
-		"model":         asrModel,
-		"hasApiKey":     asrApiKey != "",
+		"baseURL":       baseURL,
+		"model":         model,
+		"hasApiKey":     apiKey != "",
 	}).Info("Starting ASR API call")
 
 	body := &bytes.Buffer{}
@@ -53,7 +192,7 @@ func CallOpenaiAPI(audioData []byte) (string, error) {
 			"action":    "create_form_file_failed",
 			"error":     err,
 		}).Error("Failed to create form file")
-		return "", fmt.Errorf("failed to create form file: %v", err)
+		return nil, fmt.Errorf("failed to create form file: %v", err)
 	}
 	if _, err := part.Write(audioData); err != nil {
 		logger.WithFields(logrus.Fields{
@@ -61,17 +200,33 @@ func CallOpenaiAPI(audioData []byte) (string, error) {
 			"action":    "write_audio_data_failed",
 			"error":     err,
 		}).Error("Failed to write audio data")
-		return "", fmt.Errorf("failed to write audio data: %v", err)
+		return nil, fmt.Errorf("failed to write audio data: %v", err)
 	}
 
-	if err := writer.WriteField("model", asrModel); err != nil {
+	if wordConfidence {
+		writer.WriteField("response_format", "verbose_json")
+		writer.WriteField("timestamp_granularities[]", "word")
+	} else if endpoint != nil && endpoint.DetectLanguage {
+		writer.WriteField("response_format", "verbose_json")
+	}
+
+	if err := writer.WriteField("model", model); err != nil {
 		logger.WithFields(logrus.Fields{
 			"component": "api_asr_service",
 			"action":    "write_model_field_failed",
 			"error":     err,
-			"model":     asrModel,
+			"model":     model,
 		}).Error("Failed to write model field")
-		return "", fmt.Errorf("failed to write model field: %v", err)
+		return nil, fmt.Errorf("failed to write model field: %v", err)
+	}
+
+	if endpoint != nil && endpoint.Language != "" {
+		writer.WriteField("language", endpoint.Language)
+	}
+
+	if endpoint != nil && len(endpoint.Hints) > 0 {
+		field, value := hintsField(endpoint.Hints)
+		writer.WriteField(field, value)
 	}
 
 	if err := writer.Close(); err != nil {
@@ -80,11 +235,11 @@ func CallOpenaiAPI(audioData []byte) (string, error) {
 			"action":    "close_writer_failed",
 			"error":     err,
 		}).Error("Failed to close multipart writer")
-		return "", fmt.Errorf("failed to close multipart writer: %v", err)
+		return nil, fmt.Errorf("failed to close multipart writer: %v", err)
 	}
 
-	requestURL := asrBaseURL + "/audio/transcriptions"
-	req, err := http.NewRequest("POST", requestURL, body)
+	requestURL := baseURL + "/audio/transcriptions"
+	req, err := http.NewRequestWithContext(ctx, "POST", requestURL, body)
 	if err != nil {
 		logger.WithFields(logrus.Fields{
 			"component": "api_asr_service",
@@ -92,10 +247,16 @@ func CallOpenaiAPI(audioData []byte) (string, error) {
 			"error":       err,
 			"requestURL":  requestURL,
 		}).Error("Failed to create HTTP request")
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+asrApiKey)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	for key, values := range headers {
+		req.Header.Del(key)
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	logger.WithFields(logrus.Fields{
@@ -104,10 +265,14 @@ func CallOpenaiAPI(audioData []byte) (string, error) {
 		"requestURL":      requestURL,
 		"bodySize":        body.Len(),
 		"contentType":     writer.FormDataContentType(),
-		"hasAuthorization": asrApiKey != "",
+		"hasAuthorization": apiKey != "" || headers.Get("Authorization") != "",
+		"forwardedHeaders": len(headers),
 	}).Info("Sending ASR API request")
 
 	client := &http.Client{}
+	if endpoint != nil {
+		client.Timeout = endpoint.Timeout
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.WithFields(logrus.Fields{
@@ -117,7 +282,7 @@ func CallOpenaiAPI(audioData []byte) (string, error) {
 			"requestURL":  requestURL,
 			"duration":    time.Since(startTime).Milliseconds(),
 		}).Error("ASR API request failed")
-		return "", fmt.Errorf("request failed: %v", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -139,7 +304,7 @@ func CallOpenaiAPI(audioData []byte) (string, error) {
 			"response":    string(responseBody),
 			"duration":    time.Since(startTime).Milliseconds(),
 		}).Error("ASR API returned error response")
-		return "", fmt.Errorf("API error: %s, response: %s", resp.Status, string(responseBody))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(responseBody)}
 	}
 
 	responseBody, err := io.ReadAll(resp.Body)
@@ -150,7 +315,7 @@ func CallOpenaiAPI(audioData []byte) (string, error) {
 			"error":     err,
 			"duration":  time.Since(startTime).Milliseconds(),
 		}).Error("Failed to read ASR API response")
-		return "", fmt.Errorf("failed to read response: %v", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	logger.WithFields(logrus.Fields{
@@ -160,9 +325,7 @@ func CallOpenaiAPI(audioData []byte) (string, error) {
 		"duration":    time.Since(startTime).Milliseconds(),
 	}).Debug("ASR API response body read")
 
-	var result struct {
-		Text string `json:"text"`
-	}
+	var result verboseTranscription
 	if err := json.Unmarshal(responseBody, &result); err != nil {
 		logger.WithFields(logrus.Fields{
 			"component": "api_asr_service",
@@ -171,7 +334,7 @@ func CallOpenaiAPI(audioData []byte) (string, error) {
 			"response":    string(responseBody),
 			"duration":    time.Since(startTime).Milliseconds(),
 		}).Error("Failed to decode ASR API response")
-		return "", fmt.Errorf("failed to decode response: %v", err)
+		return nil, fmt.Errorf("failed to decode response: %v", err)
 	}
 
 	totalDuration := time.Since(startTime)
@@ -182,7 +345,55 @@ func CallOpenaiAPI(audioData []byte) (string, error) {
 		"textLength":     len(result.Text),
 		"totalDuration":  totalDuration.Milliseconds(),
 		"audioSize":      len(audioData),
+		"words":          len(result.Words),
 	}).Info("ASR API call completed successfully")
 
-	return result.Text, nil
+	return result.transcription(), nil
+}
+
+// verboseTranscription is the OpenAI verbose_json response; plain json responses
+// only fill Text
+type verboseTranscription struct {
+	Text     string `json:"text"`
+	Language string `json:"language"`
+	Words []struct {
+		Word        string   `json:"word"`
+		Start       float64  `json:"start"`
+		End         float64  `json:"end"`
+		Probability *float64 `json:"probability"` // faster-whisper and whisper.cpp servers
+	} `json:"words"`
+	Segments []struct {
+		Start      float64 `json:"start"`
+		End        float64 `json:"end"`
+		AvgLogprob float64 `json:"avg_logprob"`
+	} `json:"segments"`
+}
+
+// transcription converts the response, taking a word's confidence from its own
+// probability or else from the average log probability of its segment
+func (v *verboseTranscription) transcription() *Transcription {
+	t := &Transcription{Text: v.Text, Language: v.Language}
+	for _, w := range v.Words {
+		word := TranscriptionWord{
+			Word:       strings.TrimSpace(w.Word),
+			Start:      w.Start,
+			End:        w.End,
+			Confidence: -1,
+		}
+		if w.Probability != nil {
+			word.Confidence = *w.Probability
+		} else {
+			for _, segment := range v.Segments {
+				if w.Start >= segment.Start && w.Start < segment.End {
+					word.Confidence = math.Exp(segment.AvgLogprob)
+					break
+				}
+			}
+		}
+		if word.Confidence >= 0 {
+			word.Confidence = math.Round(word.Confidence*1000) / 1000
+		}
+		t.Words = append(t.Words, word)
+	}
+	return t
 }
\ No newline at end of file
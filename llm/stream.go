@@ -0,0 +1,249 @@
+package llm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// streamMessage is a message of the streaming recognition protocol. The client sends
+// 16kHz PCM16 little-endian audio as binary frames and {"type":"end"} once the
+// utterance is over; the engine answers with "partial" hypotheses of the audio so far
+// and one "final" transcript, or an "error".
+type streamMessage struct {
+	Type    string              `json:"type"`
+	Text    string              `json:"text,omitempty"`
+	Words   []TranscriptionWord `json:"words,omitempty"`
+	Message string              `json:"message,omitempty"`
+}
+
+// RecognitionStream recognizes one utterance while its audio arrives. Audio is queued
+// without blocking, the connection is opened and written in the background.
+type RecognitionStream struct {
+	mutex    sync.Mutex
+	pending  [][]byte // audio frames not written yet
+	ending   bool
+	wake     chan struct{}
+	partials chan string
+	done     chan struct{} // closed once the result or the error is known
+	result   *Transcription
+	err      error
+	cancel   context.CancelFunc
+}
+
+// StartStream starts streaming an utterance to the engine at streamURL, a ws:// or
+// wss:// URL. The endpoint's model and language are sent as query parameters and
+// its API key as bearer token unless headers carry an Authorization of their own.
+func StartStream(streamURL string, headers http.Header, endpoint *Endpoint) *RecognitionStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &RecognitionStream{
+		wake:     make(chan struct{}, 1),
+		partials: make(chan string, 16),
+		done:     make(chan struct{}),
+		cancel:   cancel,
+	}
+	go s.run(ctx, streamURL, headers, endpoint)
+	return s
+}
+
+// Send queues 16kHz audio of the utterance, dropped once the stream has ended
+func (s *RecognitionStream) Send(samples []int16) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	frame := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(frame[i*2:], uint16(sample))
+	}
+
+	s.mutex.Lock()
+	if !s.ending {
+		s.pending = append(s.pending, frame)
+	}
+	s.mutex.Unlock()
+	s.notify()
+}
+
+// Partials returns the hypotheses of the utterance so far, each replacing the last.
+// The channel is closed when the stream ends; hypotheses not read in time are dropped.
+func (s *RecognitionStream) Partials() <-chan string {
+	return s.partials
+}
+
+// Finish ends the utterance and waits for its final transcript
+func (s *RecognitionStream) Finish(ctx context.Context) (*Transcription, error) {
+	s.mutex.Lock()
+	s.ending = true
+	s.mutex.Unlock()
+	s.notify()
+
+	select {
+	case <-s.done:
+		return s.result, s.err
+	case <-ctx.Done():
+		s.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// Close abandons the utterance
+func (s *RecognitionStream) Close() {
+	s.cancel()
+}
+
+func (s *RecognitionStream) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// complete records the outcome of the stream, the first one wins
+func (s *RecognitionStream) complete(result *Transcription, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	s.result, s.err = result, err
+	close(s.done)
+}
+
+func (s *RecognitionStream) run(ctx context.Context, streamURL string, headers http.Header, endpoint *Endpoint) {
+	conn, err := dialStream(ctx, streamURL, headers, endpoint)
+	if err != nil {
+		close(s.partials)
+		s.complete(nil, err)
+		logger.WithFields(logrus.Fields{
+			"component": "api_asr_service",
+			"action":    "stream_connect_failed",
+			"url":       streamURL,
+			"error":     err,
+		}).Warn("Failed to connect to the streaming ASR engine")
+		return
+	}
+	defer conn.Close()
+
+	go s.read(conn)
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.complete(nil, ctx.Err())
+			return
+		case <-s.done:
+			return
+		case <-s.wake:
+		}
+
+		s.mutex.Lock()
+		frames, ending := s.pending, s.ending
+		s.pending = nil
+		s.mutex.Unlock()
+
+		for _, frame := range frames {
+			if err := conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+				s.complete(nil, fmt.Errorf("failed to send audio: %w", err))
+				return
+			}
+		}
+		if ending {
+			if err := conn.WriteJSON(streamMessage{Type: "end"}); err != nil {
+				s.complete(nil, fmt.Errorf("failed to end the utterance: %w", err))
+				return
+			}
+			// The final transcript or the cancellation ends the stream
+			select {
+			case <-s.done:
+			case <-ctx.Done():
+				s.complete(nil, ctx.Err())
+			}
+			return
+		}
+	}
+}
+
+// read relays the engine's messages until the final transcript or an error
+func (s *RecognitionStream) read(conn *websocket.Conn) {
+	defer close(s.partials)
+	for {
+		var message streamMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			s.complete(nil, fmt.Errorf("stream closed before the final transcript: %w", err))
+			return
+		}
+
+		switch message.Type {
+		case "partial":
+			select {
+			case s.partials <- message.Text:
+			default:
+			}
+		case "final":
+			s.complete(&Transcription{Text: message.Text, Words: message.Words}, nil)
+			return
+		case "error":
+			s.complete(nil, errors.New("ASR engine error: "+message.Message))
+			return
+		}
+	}
+}
+
+// dialStream opens the streaming connection of an utterance
+func dialStream(ctx context.Context, streamURL string, headers http.Header, endpoint *Endpoint) (*websocket.Conn, error) {
+	u, err := url.Parse(streamURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stream URL: %v", err)
+	}
+
+	_, apiKey, model, _ := asrSettings()
+	query := u.Query()
+	if endpoint != nil {
+		if endpoint.APIKey != "" {
+			apiKey = endpoint.APIKey
+		}
+		if endpoint.Model != "" {
+			model = endpoint.Model
+		}
+		if endpoint.Language != "" {
+			query.Set("language", endpoint.Language)
+		}
+	}
+	query.Set("model", model)
+	query.Set("sample_rate", "16000")
+	u.RawQuery = query.Encode()
+
+	header := headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if header.Get("Authorization") == "" && apiKey != "" {
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
+	conn, resp, err := dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(err.Error())}
+		}
+		return nil, err
+	}
+	return conn, nil
+}
@@ -1,5 +1,21 @@
 package llm
 
+// Transcription is the result of a speech recognition call
+type Transcription struct {
+	Text     string
+	Words    []TranscriptionWord // empty unless word confidence is enabled and supported
+	Language string              // language the backend detected, as it reported it; empty when it reported none
+}
+
+// TranscriptionWord is a recognized word with its timing in seconds from the start of
+// the audio and its confidence from 0 to 1, -1 when the backend reported none
+type TranscriptionWord struct {
+	Word       string  `json:"word"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Confidence float64 `json:"confidence"`
+}
+
 type CompletionRequest struct {
 	Model       string   `json:"model"`
 	Prompt      string   `json:"prompt"`
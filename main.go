@@ -3,6 +3,9 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/go-restream/stt/config"
 	"github.com/go-restream/stt/internal/service"
@@ -15,19 +18,70 @@ import (
 
 var AppConfig *config.Config
 
+const usage = `Usage: stt <command> [options]
+
+Commands:
+  serve       Run the server (the default when no command is given)
+  transcribe  Transcribe audio files with a running server
+  health      Check the health of a running server or of the configured ASR engines
+  bench       Load test a server with concurrent sessions streaming a corpus
+  models      Manage the model packs of the configuration
+  keygen      Generate a key pair for transcript encryption
+
+Run "stt <command> -h" for the options of a command.
+`
+
 func main() {
-	versionFlag := flag.Bool("v", false, "Show version information")
-	versionFullFlag := flag.Bool("version", false, "Show full version information")
-	configPath := flag.String("c", "config.yaml", "Path to configuration file")
-	flag.Parse()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			os.Exit(runServeCommand(os.Args[2:]))
+		case "transcribe":
+			os.Exit(runTranscribeCommand(os.Args[2:]))
+		case "health":
+			os.Exit(runHealthCommand(os.Args[2:]))
+		case "bench":
+			os.Exit(runBenchCommand(os.Args[2:]))
+		case "models":
+			os.Exit(runModelsCommand(os.Args[2:]))
+		case "keygen":
+			os.Exit(runKeygenCommand(os.Args[2:]))
+		case "help":
+			fmt.Print(usage)
+			return
+		}
+	}
+
+	// Without a command the arguments are those of serve, as before subcommands existed
+	os.Exit(runServeCommand(os.Args[1:]))
+}
 
-		if *versionFlag {
+// runServeCommand implements "stt serve", it returns only when the flags are invalid
+// or version information was asked for
+func runServeCommand(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	versionFlag := fs.Bool("v", false, "Show version information")
+	versionFullFlag := fs.Bool("version", false, "Show full version information")
+	configPath := fs.String("c", "config.yaml", "Path to configuration file")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fmt.Fprint(os.Stderr, "\nOptions of serve:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if *versionFlag {
 		fmt.Println(version.Short())
-		return
+		return 0
 	}
 	if *versionFullFlag {
 		fmt.Println(version.Full())
-		return
+		return 0
 	}
 
 	var err error
@@ -63,6 +117,14 @@ func main() {
 		})
 	}
 
+	if err := logger.SetComponentLevels(AppConfig.Logging.Level, AppConfig.Logging.Components); err != nil {
+		logger.WithFields(logrus.Fields{
+			"component": "mont_srv_status",
+			"action":    "log_levels_invalid",
+		}).Warnf("✘ invalid log levels, using defaults: %v", err)
+	}
+	go reloadConfigOnSignal(*configPath)
+
 	logger.WithFields(logrus.Fields{
 			"component": "mont_srv_status",
 			"action":        "health_check_status",
@@ -71,7 +133,12 @@ func main() {
 			"git_commit":    version.GetGitCommit(),
 		}).Infof("✔ Starting StreamASR %s with config: %s", version.Short(), *configPath)
 
-	if err := checkASREngineHealth(); err != nil {
+	if AppConfig.ASR.Mode == "local" {
+		logger.WithFields(logrus.Fields{
+			"component": "mont_srv_status",
+			"action":    "health_check_skipped",
+		}).Info("✔ ASR runs in-process (asr.mode: local), no engine to check")
+	} else if err := checkASREngineHealth(AppConfig); err != nil {
 		logger.WithFields(logrus.Fields{
 			"component": "mont_srv_status",
 			"action":    "health_check_failed",
@@ -88,42 +155,78 @@ func main() {
 	}
 
 	service.WsServiceRun(AppConfig.ServicePort, *configPath)
+	return 0
+}
+
+// reloadConfigOnSignal re-reads the config file on SIGHUP and applies it to the
+// running service, see service.ReloadConfig
+func reloadConfigOnSignal(configPath string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		restart, err := service.ReloadConfig(configPath)
+		if err != nil {
+			logger.WithFields(logrus.Fields{
+				"component": "mont_srv_status",
+				"action":    "config_reload_failed",
+			}).Errorf("✘ failed to reload config: %v", err)
+			continue
+		}
+		if len(restart) > 0 {
+			logger.WithFields(logrus.Fields{
+				"component":       "mont_srv_status",
+				"action":          "config_reload_partial",
+				"restartRequired": restart,
+			}).Warn("✔ Reloaded config, some changed settings only apply after a restart")
+		}
+	}
 }
 
-func checkASREngineHealth() error {
+func checkASREngineHealth(cfg *config.Config) error {
 	logger.WithFields(logrus.Fields{
 		"component": "mont_srv_status",
 		"action":    "health_check_start",
 	}).Debug("Checking ASR engine health...")
 
-	healthChecker := health.NewHealthChecker(
-		AppConfig.ASR.BaseURL,
-		AppConfig.ASR.APIKey,
-		AppConfig.ASR.Model,
-	)
+	// With replicas configured, transcription works as long as one of them is healthy
+	backends := cfg.ASRBackends()
+	if len(backends) == 0 {
+		backends = []string{cfg.ASR.BaseURL}
+	}
 
-	result := healthChecker.CheckASREngineHealth()
-	logger.WithFields(logrus.Fields{
-		"component": "sys_startup_main",
-		"action":        "asr_health_check",
-		"status":        result.Status,
-		"asrEngineURL":  result.ASREngineURL,
-		"totalChecks":   len(result.Checks),
-	}).Debug("ASR engine health check completed")
-
-	for _, check := range result.Checks {
+	var lastError string
+	for _, baseURL := range backends {
+		healthChecker := health.NewHealthChecker(
+			baseURL,
+			cfg.ASR.APIKey,
+			cfg.ASR.Model,
+		)
+
+		result := healthChecker.CheckASREngineHealth()
 		logger.WithFields(logrus.Fields{
 			"component": "sys_startup_main",
-			"endpoint":  check.Service,
-			"status":    check.Status,
-			"latency":   check.Latency.Milliseconds(),
-			"error":     check.Error,
-		}).Debugf("ASR %s endpoint check", check.Service)
-	}
-
-	if result.Status == "ok" {
-		return nil
+			"action":        "asr_health_check",
+			"status":        result.Status,
+			"asrEngineURL":  result.ASREngineURL,
+			"totalChecks":   len(result.Checks),
+		}).Debug("ASR engine health check completed")
+
+		for _, check := range result.Checks {
+			logger.WithFields(logrus.Fields{
+				"component": "sys_startup_main",
+				"endpoint":  check.Service,
+				"status":    check.Status,
+				"latency":   check.Latency.Milliseconds(),
+				"error":     check.Error,
+			}).Debugf("ASR %s endpoint check", check.Service)
+		}
+
+		if result.Status == "ok" {
+			return nil
+		}
+		lastError = result.Error
 	}
 
-	return fmt.Errorf("ASR engine health check failed: %s", result.Error)
+	return fmt.Errorf("ASR engine health check failed: %s", lastError)
 }
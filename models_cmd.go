@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/models"
+)
+
+const modelsUsage = `Usage: stt models <command> [options] [name...]
+
+Commands:
+  list        Show configured model packs and their install status
+  download    Download model packs (all when no name is given)
+  verify      Verify checksums and pinned versions of installed packs
+
+Options:
+`
+
+// runModelsCommand implements the "stt models" subcommands and returns the exit code
+func runModelsCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, modelsUsage)
+		return 2
+	}
+
+	command := args[0]
+	fs := flag.NewFlagSet("models "+command, flag.ContinueOnError)
+	configPath := fs.String("c", "config.yaml", "Path to configuration file")
+	force := fs.Bool("force", false, "Re-download packs even if they verify")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, modelsUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✘ load config failed: %v\n", err)
+		return 1
+	}
+
+	manager := models.NewManager(cfg)
+	names := fs.Args()
+	if len(names) == 0 {
+		names = manager.Names()
+	}
+
+	switch command {
+	case "list":
+		statuses, err := manager.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✘ %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSION\tINSTALLED\tSTATUS\tSIZE\tPATH")
+		for _, s := range statuses {
+			installed := s.InstalledVersion
+			if installed == "" {
+				installed = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", s.Name, s.Version, installed, s.Status, s.Size, s.Path)
+		}
+		w.Flush()
+		return 0
+
+	case "download":
+		failed := 0
+		for _, name := range names {
+			fmt.Printf("⬇ downloading %s...\n", name)
+			if err := manager.Download(name, *force); err != nil {
+				fmt.Fprintf(os.Stderr, "✘ %v\n", err)
+				failed++
+				continue
+			}
+			fmt.Printf("✔ %s installed\n", name)
+		}
+		if failed > 0 {
+			return 1
+		}
+		return 0
+
+	case "verify":
+		failed := 0
+		for _, name := range names {
+			if err := manager.Verify(name); err != nil {
+				fmt.Fprintf(os.Stderr, "✘ %v\n", err)
+				failed++
+				continue
+			}
+			fmt.Printf("✔ %s ok\n", name)
+		}
+		if failed > 0 {
+			return 1
+		}
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown models command: %s\n\n", command)
+		fs.Usage()
+		return 2
+	}
+}
@@ -0,0 +1,25 @@
+//go:build nolocalasr || novad
+
+package offlineasr
+
+import (
+	"fmt"
+
+	yaml "github.com/go-restream/stt/config"
+)
+
+// Available reports whether local recognition is compiled in, false in builds with
+// the nolocalasr tag and, as it runs on sherpa-onnx like the Silero VAD, the novad tag
+const Available = false
+
+type engine struct{}
+
+func newEngine(cfg *yaml.Config) (*engine, error) {
+	return nil, fmt.Errorf("local recognition is not compiled into this build")
+}
+
+func deleteEngine(e *engine) {}
+
+func decode(e *engine, samples []float32, sampleRate int) Result {
+	return Result{}
+}
@@ -0,0 +1,92 @@
+//go:build !nolocalasr && !novad
+
+package offlineasr
+
+import (
+	"fmt"
+	"strings"
+
+	yaml "github.com/go-restream/stt/config"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// Available reports whether local recognition is compiled in, false in builds with
+// the nolocalasr tag
+const Available = true
+
+type engine = sherpa.OfflineRecognizer
+
+func newEngine(cfg *yaml.Config) (*engine, error) {
+	config, err := initRecognizerConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	e := sherpa.NewOfflineRecognizer(config)
+	if e == nil {
+		return nil, fmt.Errorf("failed to load %s model, check the asr.local paths", modelType(cfg))
+	}
+	return e, nil
+}
+
+func deleteEngine(e *engine) {
+	sherpa.DeleteOfflineRecognizer(e)
+}
+
+func decode(e *engine, samples []float32, sampleRate int) Result {
+	stream := sherpa.NewOfflineStream(e)
+	defer sherpa.DeleteOfflineStream(stream)
+
+	stream.AcceptWaveform(sampleRate, samples)
+	e.Decode(stream)
+
+	result := stream.GetResult()
+	if result == nil {
+		return Result{}
+	}
+	// SenseVoice reports the language as a tag like "<|en|>"
+	language := strings.Trim(result.Lang, "<|>")
+	return Result{Text: strings.TrimSpace(result.Text), Language: language}
+}
+
+func initRecognizerConfig(cfg *yaml.Config) (*sherpa.OfflineRecognizerConfig, error) {
+	local := cfg.ASR.Local
+	config := sherpa.OfflineRecognizerConfig{}
+
+	config.FeatConfig.SampleRate = 16000
+	config.FeatConfig.FeatureDim = 80
+	config.DecodingMethod = "greedy_search"
+
+	switch modelType(cfg) {
+	case "sense_voice":
+		config.ModelConfig.SenseVoice.Model = local.Model
+		config.ModelConfig.SenseVoice.Language = local.Language
+		config.ModelConfig.SenseVoice.UseInverseTextNormalization = 1
+	case "whisper":
+		config.ModelConfig.Whisper.Encoder = local.Encoder
+		config.ModelConfig.Whisper.Decoder = local.Decoder
+		config.ModelConfig.Whisper.Language = local.Language
+		config.ModelConfig.Whisper.Task = "transcribe"
+		config.ModelConfig.Whisper.TailPaddings = -1
+	case "paraformer":
+		config.ModelConfig.Paraformer.Model = local.Model
+	case "transducer":
+		config.ModelConfig.Transducer.Encoder = local.Encoder
+		config.ModelConfig.Transducer.Decoder = local.Decoder
+		config.ModelConfig.Transducer.Joiner = local.Joiner
+	default:
+		return nil, fmt.Errorf("unknown asr.local.model_type: %s", local.ModelType)
+	}
+
+	config.ModelConfig.Tokens = local.Tokens
+	config.ModelConfig.NumThreads = local.NumThreads
+	if config.ModelConfig.NumThreads <= 0 {
+		config.ModelConfig.NumThreads = 1
+	}
+	config.ModelConfig.Provider = local.Provider
+	if config.ModelConfig.Provider == "" {
+		config.ModelConfig.Provider = "cpu"
+	}
+
+	return &config, nil
+}
@@ -0,0 +1,46 @@
+//go:build !nolocalasr && !novad
+
+package offlineasr
+
+import (
+	"testing"
+
+	yaml "github.com/go-restream/stt/config"
+)
+
+func TestInitRecognizerConfig(t *testing.T) {
+	cfg := &yaml.Config{}
+	cfg.ASR.Local.Model = "./model/sense_voice.int8.onnx"
+	cfg.ASR.Local.Tokens = "./model/sense_voice_tokens.txt"
+	cfg.ASR.Local.Language = "zh"
+
+	config, err := initRecognizerConfig(cfg)
+	if err != nil {
+		t.Fatalf("Expected sense_voice to be the default model type, got %v", err)
+	}
+	if config.ModelConfig.SenseVoice.Model != "./model/sense_voice.int8.onnx" {
+		t.Errorf("Expected sense voice model path, got '%s'", config.ModelConfig.SenseVoice.Model)
+	}
+	if config.ModelConfig.SenseVoice.Language != "zh" {
+		t.Errorf("Expected language 'zh', got '%s'", config.ModelConfig.SenseVoice.Language)
+	}
+	if config.ModelConfig.Provider != "cpu" || config.ModelConfig.NumThreads != 1 {
+		t.Errorf("Expected cpu provider with 1 thread, got '%s' with %d", config.ModelConfig.Provider, config.ModelConfig.NumThreads)
+	}
+
+	cfg.ASR.Local.ModelType = "whisper"
+	cfg.ASR.Local.Encoder = "./model/tiny-encoder.onnx"
+	cfg.ASR.Local.Decoder = "./model/tiny-decoder.onnx"
+	config, err = initRecognizerConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ModelConfig.Whisper.Encoder != "./model/tiny-encoder.onnx" || config.ModelConfig.SenseVoice.Model != "" {
+		t.Errorf("Expected only the whisper model to be set, got %+v", config.ModelConfig)
+	}
+
+	cfg.ASR.Local.ModelType = "kaldi"
+	if _, err := initRecognizerConfig(cfg); err == nil {
+		t.Error("Expected an error for an unknown model type")
+	}
+}
@@ -0,0 +1,84 @@
+// Package offlineasr recognizes utterances in-process with a sherpa-onnx offline
+// model, for deployments without an external ASR engine.
+package offlineasr
+
+import (
+	"fmt"
+	"time"
+
+	yaml "github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Result is the recognition of an utterance
+type Result struct {
+	Text     string
+	Language string // detected or configured language, empty when the model reports none
+}
+
+// Recognizer runs utterances through loaded offline models, one utterance per model
+// at a time
+type Recognizer struct {
+	engines chan *engine
+	loaded  int
+}
+
+// NewRecognizer loads the instances of the asr.local model. It fails when the model
+// cannot be loaded or local recognition is not compiled in.
+func NewRecognizer(cfg *yaml.Config) (*Recognizer, error) {
+	if !Available {
+		return nil, fmt.Errorf("local recognition is not compiled into this build (nolocalasr or novad tag)")
+	}
+
+	local := cfg.ASR.Local
+	count := local.Instances
+	if count <= 0 {
+		count = 1
+	}
+
+	start := time.Now()
+	r := &Recognizer{engines: make(chan *engine, count)}
+	for i := 0; i < count; i++ {
+		e, err := newEngine(cfg)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.engines <- e
+		r.loaded++
+	}
+
+	logger.WithFields(logrus.Fields{
+		"component": "eng_offline_asr",
+		"action":    "models_loaded",
+		"modelType": modelType(cfg),
+		"instances": count,
+		"provider":  local.Provider,
+		"loadMs":    time.Since(start).Milliseconds(),
+	}).Info("Offline recognition models loaded")
+	return r, nil
+}
+
+// Transcribe recognizes 16kHz mono samples in [-1, 1], waiting for a free model when
+// all are busy
+func (r *Recognizer) Transcribe(samples []float32, sampleRate int) Result {
+	e := <-r.engines
+	defer func() { r.engines <- e }()
+	return decode(e, samples, sampleRate)
+}
+
+// Close releases the loaded models, waiting for running recognitions
+func (r *Recognizer) Close() {
+	for ; r.loaded > 0; r.loaded-- {
+		deleteEngine(<-r.engines)
+	}
+}
+
+func modelType(cfg *yaml.Config) string {
+	if cfg.ASR.Local.ModelType == "" {
+		return "sense_voice"
+	}
+	return cfg.ASR.Local.ModelType
+}
@@ -0,0 +1,134 @@
+// Package admission bounds the concurrent calls to a backend, queueing the calls
+// beyond the limit in arrival order.
+package admission
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when a call finds the queue full
+var ErrQueueFull = errors.New("admission queue is full")
+
+// Stats are the counters of a limiter. Active and Queued are the calls running and
+// waiting now, the others count since the limiter was created.
+type Stats struct {
+	MaxConcurrent int   `json:"max_concurrent"`
+	MaxQueued     int   `json:"max_queued"`
+	Active        int   `json:"active"`
+	Queued        int   `json:"queued"`
+	PeakQueued    int   `json:"peak_queued"`
+	Admitted      int64 `json:"admitted"`
+	Delayed       int64 `json:"delayed"`   // admitted after waiting in the queue
+	Rejected      int64 `json:"rejected"`  // refused because the queue was full
+	Abandoned     int64 `json:"abandoned"` // gave up waiting, e.g. on timeout
+	WaitMsTotal   int64 `json:"wait_ms_total"`
+}
+
+// Limiter admits up to maxConcurrent calls at a time. Further calls wait in a FIFO
+// queue of at most maxQueued calls; maxQueued <= 0 queues without bound. It is safe
+// for concurrent use.
+type Limiter struct {
+	maxConcurrent int
+	maxQueued     int
+
+	mutex   sync.Mutex
+	active  int
+	waiting []chan struct{} // closed when the waiter is admitted
+	stats   Stats
+}
+
+// New creates a limiter, maxConcurrent must be positive
+func New(maxConcurrent, maxQueued int) *Limiter {
+	return &Limiter{
+		maxConcurrent: max(maxConcurrent, 1),
+		maxQueued:     maxQueued,
+	}
+}
+
+// Acquire waits for a slot and returns the function releasing it. It returns
+// ErrQueueFull at once when the queue is full, and ctx's error when ctx ends before
+// the call is admitted.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	l.mutex.Lock()
+	if l.active < l.maxConcurrent && len(l.waiting) == 0 {
+		l.active++
+		l.stats.Admitted++
+		l.mutex.Unlock()
+		return l.releaseFunc(), nil
+	}
+	if l.maxQueued > 0 && len(l.waiting) >= l.maxQueued {
+		l.stats.Rejected++
+		l.mutex.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	admitted := make(chan struct{})
+	l.waiting = append(l.waiting, admitted)
+	l.stats.PeakQueued = max(l.stats.PeakQueued, len(l.waiting))
+	l.mutex.Unlock()
+
+	start := time.Now()
+	select {
+	case <-admitted:
+		l.mutex.Lock()
+		l.stats.Delayed++
+		l.stats.WaitMsTotal += time.Since(start).Milliseconds()
+		l.mutex.Unlock()
+		return l.releaseFunc(), nil
+	case <-ctx.Done():
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i, waiter := range l.waiting {
+		if waiter == admitted {
+			l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+			l.stats.Abandoned++
+			return nil, ctx.Err()
+		}
+	}
+	// Admitted while ctx ended, hand the slot on
+	l.stats.Abandoned++
+	l.stats.Admitted--
+	l.releaseLocked()
+	return nil, ctx.Err()
+}
+
+// releaseFunc returns the release of an admitted call, effective once
+func (l *Limiter) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			l.releaseLocked()
+			l.mutex.Unlock()
+		})
+	}
+}
+
+// releaseLocked frees a slot, admitting the first waiter; the caller holds the mutex
+func (l *Limiter) releaseLocked() {
+	if len(l.waiting) > 0 {
+		close(l.waiting[0])
+		l.waiting = l.waiting[1:]
+		l.stats.Admitted++
+		return
+	}
+	l.active--
+}
+
+// Stats returns the current counters
+func (l *Limiter) Stats() Stats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	stats := l.stats
+	stats.MaxConcurrent = l.maxConcurrent
+	stats.MaxQueued = l.maxQueued
+	stats.Active = l.active
+	stats.Queued = len(l.waiting)
+	return stats
+}
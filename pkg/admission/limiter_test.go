@@ -0,0 +1,77 @@
+package admission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterQueuesBeyondLimitInOrder(t *testing.T) {
+	limiter := New(1, 0)
+	release, err := limiter.Acquire(context.Background())
+	require.NoError(t, err)
+
+	order := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		go func() {
+			next, err := limiter.Acquire(context.Background())
+			if err == nil {
+				order <- i
+				next()
+			}
+		}()
+		// Queue the waiters one after the other
+		assert.Eventually(t, func() bool { return limiter.Stats().Queued == i }, time.Second, time.Millisecond)
+	}
+
+	release()
+	assert.Equal(t, 1, <-order)
+	assert.Equal(t, 2, <-order)
+
+	stats := limiter.Stats()
+	assert.Equal(t, 0, stats.Active)
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, 2, stats.PeakQueued)
+	assert.Equal(t, int64(3), stats.Admitted)
+	assert.Equal(t, int64(2), stats.Delayed)
+}
+
+func TestLimiterRejectsWhenQueueIsFull(t *testing.T) {
+	limiter := New(1, 1)
+	release, err := limiter.Acquire(context.Background())
+	require.NoError(t, err)
+	defer release()
+
+	go limiter.Acquire(context.Background())
+	assert.Eventually(t, func() bool { return limiter.Stats().Queued == 1 }, time.Second, time.Millisecond)
+
+	_, err = limiter.Acquire(context.Background())
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Equal(t, int64(1), limiter.Stats().Rejected)
+}
+
+func TestLimiterAbandonedWaiterLeavesQueue(t *testing.T) {
+	limiter := New(1, 0)
+	release, err := limiter.Acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	stats := limiter.Stats()
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, int64(1), stats.Abandoned)
+
+	release()
+	release() // releasing twice frees the slot once
+	assert.Equal(t, 0, limiter.Stats().Active)
+
+	next, err := limiter.Acquire(context.Background())
+	require.NoError(t, err)
+	next()
+}
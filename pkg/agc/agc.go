@@ -0,0 +1,136 @@
+// Package agc is an automatic gain control for speech in pure Go. It measures the
+// level of the stream in short blocks and moves a gain towards the one bringing it to
+// a target RMS level, so quiet far-field microphones reach the level the VAD and the
+// recognizer expect. A peak limiter keeps the amplified signal from clipping.
+package agc
+
+import "math"
+
+const blockMs = 10
+
+// Options configure the gain control. Zero values use defaults.
+type Options struct {
+	TargetDBFS     float64 // RMS level speech is brought to, default -20
+	MaxGainDB      float64 // largest amplification, default 30
+	AttackMs       float64 // time for the gain to fall on louder input, default 20
+	ReleaseMs      float64 // time for the gain to rise on quieter input, default 800
+	NoiseFloorDBFS float64 // blocks quieter than this hold the gain, so silence is not amplified, default -60
+	PeakDBFS       float64 // limit of the amplified peaks, default -1
+}
+
+func (o Options) withDefaults() Options {
+	if o.TargetDBFS >= 0 {
+		o.TargetDBFS = -20
+	}
+	if o.MaxGainDB <= 0 {
+		o.MaxGainDB = 30
+	}
+	if o.AttackMs <= 0 {
+		o.AttackMs = 20
+	}
+	if o.ReleaseMs <= 0 {
+		o.ReleaseMs = 800
+	}
+	if o.NoiseFloorDBFS >= 0 {
+		o.NoiseFloorDBFS = -60
+	}
+	if o.PeakDBFS >= 0 {
+		o.PeakDBFS = -1
+	}
+	return o
+}
+
+// AGC is a streaming gain control. Output has as many samples as the input and is not
+// delayed. It is not safe for concurrent use.
+type AGC struct {
+	target     float64 // linear RMS, full scale 1
+	maxGain    float64
+	noiseFloor float64
+	peak       float64
+	attack     float64 // per-block smoothing coefficients
+	release    float64
+	blockSize  int
+	gain       float64
+}
+
+// New creates a gain control for audio at sampleRate
+func New(sampleRate int, opts Options) *AGC {
+	opts = opts.withDefaults()
+	return &AGC{
+		target:     dbToLinear(opts.TargetDBFS),
+		maxGain:    dbToLinear(opts.MaxGainDB),
+		noiseFloor: dbToLinear(opts.NoiseFloorDBFS),
+		peak:       dbToLinear(opts.PeakDBFS) * 32767,
+		attack:     math.Exp(-blockMs / opts.AttackMs),
+		release:    math.Exp(-blockMs / opts.ReleaseMs),
+		blockSize:  max(sampleRate*blockMs/1000, 1),
+		gain:       1,
+	}
+}
+
+// Process amplifies the next chunk of the stream, returning as many samples as given
+func (a *AGC) Process(samples []int16) []int16 {
+	out := make([]int16, len(samples))
+	for start := 0; start < len(samples); start += a.blockSize {
+		end := min(start+a.blockSize, len(samples))
+		a.processBlock(samples[start:end], out[start:end])
+	}
+	return out
+}
+
+func (a *AGC) processBlock(in, out []int16) {
+	var sum, peak float64
+	for _, s := range in {
+		v := float64(s)
+		sum += v * v
+		peak = max(peak, math.Abs(v))
+	}
+	level := math.Sqrt(sum/float64(len(in))) / 32768
+
+	previous := a.gain
+	if level > a.noiseFloor {
+		desired := min(a.target/level, a.maxGain)
+		coeff := a.release
+		if desired < a.gain {
+			coeff = a.attack
+		}
+		a.gain = desired + (a.gain-desired)*coeff
+	}
+	// The limiter takes effect at once, the smoothing would let the peak clip
+	if peak*a.gain > a.peak {
+		a.gain = a.peak / peak
+	}
+
+	// Ramp from the previous gain over the block, so gain changes do not click
+	step := (a.gain - previous) / float64(len(in))
+	gain := previous
+	for i, s := range in {
+		gain += step
+		out[i] = clampInt16(float64(s) * min(gain, a.peak/max(math.Abs(float64(s)), 1)))
+	}
+}
+
+// GainDB returns the current gain in dB
+func (a *AGC) GainDB() float64 {
+	return 20 * math.Log10(a.gain)
+}
+
+// Reset returns the gain to unity
+func (a *AGC) Reset() {
+	a.gain = 1
+}
+
+func dbToLinear(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+func clampInt16(v float64) int16 {
+	v = math.Round(v)
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return int16(v)
+}
@@ -0,0 +1,71 @@
+package agc
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tone(n int, amplitude float64) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(amplitude * math.Sin(2*math.Pi*300*float64(i)/16000))
+	}
+	return samples
+}
+
+func rmsDBFS(samples []int16) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return 20 * math.Log10(math.Sqrt(sum/float64(len(samples)))/32768)
+}
+
+func TestAGCKeepsLength(t *testing.T) {
+	a := New(16000, Options{})
+	for _, n := range []int{0, 1, 160, 333, 1600} {
+		assert.Len(t, a.Process(make([]int16, n)), n)
+	}
+}
+
+func TestAGCBringsQuietSpeechToTarget(t *testing.T) {
+	a := New(16000, Options{TargetDBFS: -20})
+	in := tone(64000, 300) // about -44 dBFS
+	out := a.Process(in)
+	assert.InDelta(t, -20, rmsDBFS(out[48000:]), 1)
+	assert.InDelta(t, -20-rmsDBFS(in), a.GainDB(), 1)
+}
+
+func TestAGCAttenuatesLoudSpeech(t *testing.T) {
+	a := New(16000, Options{TargetDBFS: -20})
+	out := a.Process(tone(16000, 20000))
+	assert.InDelta(t, -20, rmsDBFS(out[8000:]), 1)
+}
+
+func TestAGCLimitsGain(t *testing.T) {
+	a := New(16000, Options{TargetDBFS: -20, MaxGainDB: 10})
+	a.Process(tone(64000, 100))
+	assert.InDelta(t, 10, a.GainDB(), 0.1)
+}
+
+func TestAGCHoldsGainOnSilence(t *testing.T) {
+	a := New(16000, Options{})
+	a.Process(tone(32000, 1000))
+	gain := a.GainDB()
+	out := a.Process(make([]int16, 16000))
+	assert.Equal(t, gain, a.GainDB())
+	assert.Equal(t, make([]int16, 16000), out)
+}
+
+func TestAGCDoesNotClip(t *testing.T) {
+	a := New(16000, Options{MaxGainDB: 40})
+	a.Process(tone(32000, 100))
+	// A sudden loud burst after the gain rose is limited, not clipped
+	limit := int16(math.Round(math.Pow(10, -1.0/20) * 32767))
+	for _, s := range a.Process(tone(1600, 30000)) {
+		assert.LessOrEqual(t, s, limit)
+		assert.GreaterOrEqual(t, s, -limit)
+	}
+}
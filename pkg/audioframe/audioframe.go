@@ -0,0 +1,84 @@
+// Package audioframe encodes the binary WebSocket frames carrying input audio, which
+// spare clients the Base64 encoding of input_audio_buffer.append events.
+//
+// A frame is an 8 byte header followed by the audio bytes in the session's input
+// format:
+//
+//	byte 0     version, 1
+//	byte 1     type, 1 for an append
+//	byte 2     flags, bit 0 set when the checksum is present
+//	byte 3     reserved, 0
+//	bytes 4-7  IEEE CRC32 of the audio bytes, little-endian
+package audioframe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Version is the version of the frame layout
+const Version = 1
+
+// HeaderSize is the length of the header preceding the audio
+const HeaderSize = 8
+
+// TypeAppend marks a frame appending audio to the input buffer
+const TypeAppend = 1
+
+// FlagChecksum marks a frame carrying the CRC32 of its audio
+const FlagChecksum = 1 << 0
+
+// ErrShortFrame is returned for frames shorter than the header
+var ErrShortFrame = errors.New("audio frame shorter than its header")
+
+// Frame is a decoded binary frame
+type Frame struct {
+	Type  byte
+	Audio []byte // aliases the decoded data
+	// CRC32 of the audio, nil when the sender left it out
+	CRC32 *uint32
+}
+
+// Encode returns an append frame carrying audio, with its CRC32 when checksum is set
+func Encode(audio []byte, checksum bool) []byte {
+	data := make([]byte, HeaderSize+len(audio))
+	data[0] = Version
+	data[1] = TypeAppend
+	if checksum {
+		data[2] = FlagChecksum
+		binary.LittleEndian.PutUint32(data[4:], crc32.ChecksumIEEE(audio))
+	}
+	copy(data[HeaderSize:], audio)
+	return data
+}
+
+// Decode parses a binary frame without copying its audio
+func Decode(data []byte) (Frame, error) {
+	if len(data) < HeaderSize {
+		return Frame{}, ErrShortFrame
+	}
+	if data[0] != Version {
+		return Frame{}, fmt.Errorf("unsupported audio frame version %d", data[0])
+	}
+	if data[1] != TypeAppend {
+		return Frame{}, fmt.Errorf("unsupported audio frame type %d", data[1])
+	}
+	frame := Frame{Type: data[1], Audio: data[HeaderSize:]}
+	if data[2]&FlagChecksum != 0 {
+		checksum := binary.LittleEndian.Uint32(data[4:])
+		frame.CRC32 = &checksum
+	}
+	return frame, nil
+}
+
+// Verify reports whether the audio of the frame matches its checksum, frames without
+// one always do
+func (f Frame) Verify() (uint32, bool) {
+	if f.CRC32 == nil {
+		return 0, true
+	}
+	checksum := crc32.ChecksumIEEE(f.Audio)
+	return checksum, checksum == *f.CRC32
+}
@@ -0,0 +1,59 @@
+package audioframe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	audio := []byte{0x01, 0x02, 0x03, 0x04}
+
+	frame, err := Decode(Encode(audio, true))
+	require.NoError(t, err)
+	assert.Equal(t, byte(TypeAppend), frame.Type)
+	assert.Equal(t, audio, frame.Audio)
+	require.NotNil(t, frame.CRC32)
+	_, ok := frame.Verify()
+	assert.True(t, ok)
+
+	frame, err = Decode(Encode(audio, false))
+	require.NoError(t, err)
+	assert.Nil(t, frame.CRC32)
+	assert.Equal(t, audio, frame.Audio)
+}
+
+func TestDecodeEmptyAudio(t *testing.T) {
+	frame, err := Decode(Encode(nil, true))
+	require.NoError(t, err)
+	assert.Empty(t, frame.Audio)
+}
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	data := Encode([]byte{0x10, 0x20, 0x30, 0x40}, true)
+	data[HeaderSize+1] ^= 0xff
+
+	frame, err := Decode(data)
+	require.NoError(t, err)
+	_, ok := frame.Verify()
+	assert.False(t, ok)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"short", []byte{Version, TypeAppend, 0}},
+		{"version", []byte{2, TypeAppend, 0, 0, 0, 0, 0, 0}},
+		{"type", []byte{Version, 9, 0, 0, 0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.data)
+			assert.Error(t, err)
+		})
+	}
+}
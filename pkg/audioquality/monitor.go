@@ -0,0 +1,132 @@
+package audioquality
+
+import "math"
+
+// Warning types reported by Monitor
+const (
+	WarningClipping = "clipping"
+	WarningDCOffset = "dc_offset"
+)
+
+const (
+	monitorWindowMs = 500
+	// sustainedWindows is how many consecutive windows must exceed a threshold
+	sustainedWindows = 2
+	// warningCooldownMs is the audio time before the same warning is repeated
+	warningCooldownMs = 10000
+
+	// ClippingThreshold is the clipped sample ratio of a window considered clipping
+	ClippingThreshold = 0.01
+	// DCOffsetThreshold is the absolute window mean, relative to full scale, considered a DC offset
+	DCOffsetThreshold = 0.05
+)
+
+// Warning describes a sustained capture problem detected in streamed audio
+type Warning struct {
+	Type          string
+	ClippingRatio float64 // clipped sample ratio over the affected windows
+	DCOffset      float64 // mean sample value relative to full scale, -1 to 1
+	StartMs       int64   // start of the affected audio, relative to the first fed sample
+	EndMs         int64
+}
+
+type windowStreak struct {
+	windows    int
+	startMs    int64
+	sum        float64 // sum of the metric over the streak, for averaging
+	lastWarnMs int64
+	warned     bool
+}
+
+// Monitor watches streamed audio for sustained clipping and DC offset. It is not
+// safe for concurrent use.
+type Monitor struct {
+	window    []int16
+	fedMs     float64
+	windowEnd int64
+	clipping  windowStreak
+	dcOffset  windowStreak
+}
+
+// NewMonitor creates a monitor for a single audio stream
+func NewMonitor() *Monitor {
+	return &Monitor{}
+}
+
+// Feed adds 16-bit PCM mono samples and returns the warnings raised by them
+func (m *Monitor) Feed(samples []int16, sampleRate int) []Warning {
+	if sampleRate <= 0 {
+		return nil
+	}
+
+	windowLen := sampleRate * monitorWindowMs / 1000
+	var warnings []Warning
+	for len(samples) > 0 {
+		n := min(windowLen-len(m.window), len(samples))
+		m.window = append(m.window, samples[:n]...)
+		samples = samples[n:]
+		m.fedMs += float64(n) * 1000 / float64(sampleRate)
+
+		if len(m.window) < windowLen {
+			break
+		}
+		warnings = append(warnings, m.checkWindow()...)
+		m.window = m.window[:0]
+	}
+	return warnings
+}
+
+func (m *Monitor) checkWindow() []Warning {
+	startMs := m.windowEnd
+	endMs := int64(m.fedMs)
+	m.windowEnd = endMs
+
+	var sum float64
+	for _, s := range m.window {
+		sum += float64(s)
+	}
+	dcOffset := sum / float64(len(m.window)) / 32768
+	clipping := clippingRatio(m.window)
+
+	var warnings []Warning
+	if average, streakStart, ok := m.clipping.update(clipping >= ClippingThreshold, clipping, startMs, endMs); ok {
+		warnings = append(warnings, Warning{
+			Type:          WarningClipping,
+			ClippingRatio: round(average, 4),
+			StartMs:       streakStart,
+			EndMs:         endMs,
+		})
+	}
+	if average, streakStart, ok := m.dcOffset.update(math.Abs(dcOffset) >= DCOffsetThreshold, dcOffset, startMs, endMs); ok {
+		warnings = append(warnings, Warning{
+			Type:     WarningDCOffset,
+			DCOffset: round(average, 4),
+			StartMs:  streakStart,
+			EndMs:    endMs,
+		})
+	}
+	return warnings
+}
+
+// update advances the streak with a window and reports whether a warning is due,
+// along with the streak's average value and start
+func (s *windowStreak) update(exceeded bool, value float64, startMs, endMs int64) (float64, int64, bool) {
+	if !exceeded {
+		s.windows = 0
+		s.sum = 0
+		return 0, 0, false
+	}
+
+	if s.windows == 0 {
+		s.startMs = startMs
+	}
+	s.windows++
+	s.sum += value
+
+	if s.windows < sustainedWindows || (s.warned && endMs-s.lastWarnMs < warningCooldownMs) {
+		return 0, 0, false
+	}
+	s.warned = true
+	s.lastWarnMs = endMs
+	return s.sum / float64(s.windows), s.startMs, true
+}
@@ -0,0 +1,148 @@
+package audioquality
+
+import (
+	"math"
+	"sort"
+
+	"github.com/go-restream/stt/pkg/dsp"
+)
+
+const (
+	frameMs   = 20
+	fftSize   = 512
+	maxFrames = 64 // spectral frames averaged for the bandwidth estimate
+
+	// clipLevel is the absolute sample value treated as clipped
+	clipLevel = 32440
+	// maxSNRDb caps the SNR estimate of noiseless (e.g. synthetic) audio
+	maxSNRDb = 60
+	// bandwidthFloorDb is how far below the spectral peak a bin still counts as content
+	bandwidthFloorDb = 50
+	// wideband is the bandwidth considered full quality for speech recognition
+	widebandHz = 7000
+
+	// LowScore is the score below which an utterance is considered poor input audio
+	LowScore = 0.5
+)
+
+// Metrics describes the quality of an utterance's audio
+type Metrics struct {
+	SNRDb         float64 `json:"snr_db"`         // estimated signal-to-noise ratio
+	ClippingRatio float64 `json:"clipping_ratio"` // fraction of clipped samples
+	BandwidthHz   float64 `json:"bandwidth_hz"`   // highest frequency carrying signal
+	Score         float64 `json:"score"`          // combined score, 0 (unusable) to 1 (clean)
+}
+
+// Analyze computes quality metrics of 16-bit PCM mono samples
+func Analyze(samples []int16, sampleRate int) Metrics {
+	if len(samples) == 0 || sampleRate <= 0 {
+		return Metrics{}
+	}
+
+	m := Metrics{
+		SNRDb:         estimateSNR(samples, sampleRate),
+		ClippingRatio: clippingRatio(samples),
+		BandwidthHz:   estimateBandwidth(samples, sampleRate),
+	}
+
+	snrScore := clamp(m.SNRDb / 30)
+	clipScore := 1 - clamp(m.ClippingRatio/0.01)
+	bandwidthScore := clamp(m.BandwidthHz / math.Min(widebandHz, float64(sampleRate)/2))
+	m.Score = round(0.5*snrScore+0.25*clipScore+0.25*bandwidthScore, 3)
+
+	m.SNRDb = round(m.SNRDb, 1)
+	m.ClippingRatio = round(m.ClippingRatio, 4)
+	m.BandwidthHz = math.Round(m.BandwidthHz)
+	return m
+}
+
+// estimateSNR compares the energy of the loudest frames against the quietest ones,
+// which in a VAD segment are the leading and trailing padding
+func estimateSNR(samples []int16, sampleRate int) float64 {
+	frameLen := sampleRate * frameMs / 1000
+	if frameLen <= 0 || len(samples) < frameLen*2 {
+		return 0
+	}
+
+	energies := make([]float64, 0, len(samples)/frameLen)
+	for start := 0; start+frameLen <= len(samples); start += frameLen {
+		var sum float64
+		for _, s := range samples[start : start+frameLen] {
+			sum += float64(s) * float64(s)
+		}
+		energies = append(energies, sum/float64(frameLen))
+	}
+	sort.Float64s(energies)
+
+	noise := energies[len(energies)/10]
+	signal := energies[len(energies)*9/10]
+	if signal == 0 {
+		return 0
+	}
+	if noise == 0 {
+		return maxSNRDb
+	}
+	return math.Min(10*math.Log10(signal/noise), maxSNRDb)
+}
+
+func clippingRatio(samples []int16) float64 {
+	clipped := 0
+	for _, s := range samples {
+		if s >= clipLevel || s <= -clipLevel {
+			clipped++
+		}
+	}
+	return float64(clipped) / float64(len(samples))
+}
+
+// estimateBandwidth averages the power spectrum over evenly spaced frames and
+// returns the highest frequency within bandwidthFloorDb of the peak
+func estimateBandwidth(samples []int16, sampleRate int) float64 {
+	frames := len(samples) / fftSize
+	if frames == 0 {
+		return 0
+	}
+	step := 1
+	if frames > maxFrames {
+		step = frames / maxFrames
+	}
+
+	power := make([]float64, fftSize/2)
+	buf := make([]complex128, fftSize)
+	for f := 0; f < frames; f += step {
+		frame := samples[f*fftSize : (f+1)*fftSize]
+		for i, s := range frame {
+			window := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(fftSize-1))
+			buf[i] = complex(float64(s)*window, 0)
+		}
+		dsp.FFT(buf)
+		for i := range power {
+			power[i] += real(buf[i])*real(buf[i]) + imag(buf[i])*imag(buf[i])
+		}
+	}
+
+	var peak float64
+	for _, p := range power[1:] {
+		peak = math.Max(peak, p)
+	}
+	if peak == 0 {
+		return 0
+	}
+
+	floor := peak * math.Pow(10, -bandwidthFloorDb/10.0)
+	for i := len(power) - 1; i > 0; i-- {
+		if power[i] >= floor {
+			return float64(i) * float64(sampleRate) / fftSize
+		}
+	}
+	return 0
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func round(v float64, digits int) float64 {
+	scale := math.Pow(10, float64(digits))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,77 @@
+package audioquality
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tone(freq float64, amplitude float64, n int, sampleRate int) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		v := amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate))
+		samples[i] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, v))) // saturate like an overdriven ADC
+	}
+	return samples
+}
+
+func TestAnalyzeClean(t *testing.T) {
+	// Half a second of silence followed by a second of a 1 kHz tone
+	samples := append(make([]int16, 8000), tone(1000, 8000, 16000, 16000)...)
+	m := Analyze(samples, 16000)
+
+	assert.Equal(t, float64(maxSNRDb), m.SNRDb)
+	assert.Zero(t, m.ClippingRatio)
+	assert.InDelta(t, 1000, m.BandwidthHz, 500)
+}
+
+func TestAnalyzeClipped(t *testing.T) {
+	m := Analyze(tone(300, 60000, 16000, 16000), 16000)
+	assert.Greater(t, m.ClippingRatio, 0.1)
+}
+
+func TestAnalyzeNoisyScoresLower(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	clean := append(make([]int16, 8000), tone(1000, 8000, 16000, 16000)...)
+	noisy := make([]int16, len(clean))
+	for i, s := range clean {
+		noisy[i] = s + int16(rng.NormFloat64()*3000)
+	}
+
+	assert.Less(t, Analyze(noisy, 16000).Score, Analyze(clean, 16000).Score)
+	assert.Less(t, Analyze(noisy, 16000).SNRDb, 20.0)
+}
+
+func TestAnalyzeEmpty(t *testing.T) {
+	assert.Equal(t, Metrics{}, Analyze(nil, 16000))
+}
+
+func TestMonitor(t *testing.T) {
+	m := NewMonitor()
+
+	// A single clipped window is not sustained
+	assert.Empty(t, m.Feed(tone(300, 60000, 8000, 16000), 16000))
+	assert.Empty(t, m.Feed(tone(300, 8000, 8000, 16000), 16000))
+
+	warnings := m.Feed(tone(300, 60000, 16000, 16000), 16000)
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, WarningClipping, warnings[0].Type)
+		assert.Equal(t, int64(1000), warnings[0].StartMs)
+		assert.Equal(t, int64(2000), warnings[0].EndMs)
+	}
+
+	// Repeated within the cooldown
+	assert.Empty(t, m.Feed(tone(300, 60000, 16000, 16000), 16000))
+
+	offset := make([]int16, 16000)
+	for i := range offset {
+		offset[i] = 4000
+	}
+	warnings = m.Feed(offset, 16000)
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, WarningDCOffset, warnings[0].Type)
+		assert.InDelta(t, 0.122, warnings[0].DCOffset, 0.001)
+	}
+}
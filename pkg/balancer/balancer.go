@@ -0,0 +1,203 @@
+// Package balancer orders replicas of a backend for each call, skipping replicas that
+// recently failed, so a single replica outage does not fail the calls.
+package balancer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Strategy selects the order in which healthy backends are tried
+type Strategy string
+
+const (
+	// RoundRobin starts every call at the next healthy backend
+	RoundRobin Strategy = "round_robin"
+	// LeastLatency prefers the backend with the lowest recent call latency
+	LeastLatency Strategy = "least_latency"
+)
+
+// latencyWeight is the weight of a new call in the moving average latency
+const latencyWeight = 0.3
+
+// Options configures a pool
+type Options struct {
+	Strategy Strategy      // empty means RoundRobin
+	Cooldown time.Duration // how long a failed backend is skipped, 0 means 30s
+}
+
+// BackendStats is the state of a backend
+type BackendStats struct {
+	URL       string     `json:"url"`
+	Healthy   bool       `json:"healthy"`
+	LatencyMs float64    `json:"latency_ms"` // moving average of successful calls
+	Requests  int64      `json:"requests"`
+	Failures  int64      `json:"failures"`
+	DownUntil *time.Time `json:"down_until,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type backend struct {
+	url       string
+	latency   float64 // milliseconds, 0 until measured
+	requests  int64
+	failures  int64
+	downUntil time.Time
+	lastError string
+}
+
+// Pool tracks the health of the backends, safe for concurrent use
+type Pool struct {
+	mutex    sync.Mutex
+	backends []*backend
+	strategy Strategy
+	cooldown time.Duration
+	next     int
+	now      func() time.Time
+}
+
+// New creates a pool of the backends at the given URLs
+func New(urls []string, opts Options) *Pool {
+	if opts.Strategy == "" {
+		opts.Strategy = RoundRobin
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 30 * time.Second
+	}
+	p := &Pool{strategy: opts.Strategy, cooldown: opts.Cooldown, now: time.Now}
+	for _, url := range urls {
+		p.backends = append(p.backends, &backend{url: url})
+	}
+	return p
+}
+
+// Order returns the URLs to try for a call: the healthy backends in strategy order,
+// then the failed ones soonest to recover first, as a last resort
+func (p *Pool) Order() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := p.now()
+	var up, down []*backend
+	for _, b := range p.backends {
+		if now.Before(b.downUntil) {
+			down = append(down, b)
+		} else {
+			up = append(up, b)
+		}
+	}
+
+	switch p.strategy {
+	case LeastLatency:
+		// Unmeasured backends sort first so they get measured
+		sort.SliceStable(up, func(i, j int) bool { return up[i].latency < up[j].latency })
+	default:
+		if len(up) > 0 {
+			start := p.next % len(up)
+			up = append(up[start:], up[:start]...)
+			p.next++
+		}
+	}
+	sort.SliceStable(down, func(i, j int) bool { return down[i].downUntil.Before(down[j].downUntil) })
+
+	urls := make([]string, 0, len(p.backends))
+	for _, b := range append(up, down...) {
+		urls = append(urls, b.url)
+	}
+	return urls
+}
+
+// Succeeded records a successful call to a backend and brings it back up
+func (p *Pool) Succeeded(url string, latency time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	b := p.find(url)
+	if b == nil {
+		return
+	}
+	b.requests++
+	b.downUntil = time.Time{}
+	ms := float64(latency) / float64(time.Millisecond)
+	if b.latency == 0 {
+		b.latency = ms
+	} else {
+		b.latency += latencyWeight * (ms - b.latency)
+	}
+}
+
+// Failed records a failed call to a backend, which is skipped for the cooldown
+func (p *Pool) Failed(url string, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	b := p.find(url)
+	if b == nil {
+		return
+	}
+	b.requests++
+	b.failures++
+	b.downUntil = p.now().Add(p.cooldown)
+	if err != nil {
+		b.lastError = err.Error()
+	}
+}
+
+// SetHealth records the result of a health check of a backend: a failed check takes
+// it down for the cooldown, a passed one brings it back up
+func (p *Pool) SetHealth(url string, healthy bool, reason string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	b := p.find(url)
+	if b == nil {
+		return
+	}
+	if healthy {
+		b.downUntil = time.Time{}
+		return
+	}
+	b.downUntil = p.now().Add(p.cooldown)
+	b.lastError = reason
+}
+
+// URLs returns the URLs of all backends in configuration order
+func (p *Pool) URLs() []string {
+	urls := make([]string, len(p.backends))
+	for i, b := range p.backends {
+		urls[i] = b.url
+	}
+	return urls
+}
+
+// Stats returns the state of all backends in configuration order
+func (p *Pool) Stats() []BackendStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := p.now()
+	stats := make([]BackendStats, 0, len(p.backends))
+	for _, b := range p.backends {
+		s := BackendStats{
+			URL:       b.url,
+			Healthy:   !now.Before(b.downUntil),
+			LatencyMs: float64(int64(b.latency*10)) / 10,
+			Requests:  b.requests,
+			Failures:  b.failures,
+			LastError: b.lastError,
+		}
+		if !s.Healthy {
+			downUntil := b.downUntil
+			s.DownUntil = &downUntil
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+func (p *Pool) find(url string) *backend {
+	for _, b := range p.backends {
+		if b.url == url {
+			return b
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package balancer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundRobinRotatesAndSkipsFailedBackends(t *testing.T) {
+	p := New([]string{"a", "b", "c"}, Options{Cooldown: time.Minute})
+	now := time.Unix(1000, 0)
+	p.now = func() time.Time { return now }
+
+	assert.Equal(t, []string{"a", "b", "c"}, p.Order())
+	assert.Equal(t, []string{"b", "c", "a"}, p.Order())
+
+	p.Failed("b", errors.New("502 Bad Gateway"))
+	order := p.Order()
+	assert.Equal(t, "b", order[2], "failed backend is tried last")
+	assert.ElementsMatch(t, []string{"a", "c"}, order[:2])
+
+	stats := p.Stats()
+	require.Len(t, stats, 3)
+	assert.False(t, stats[1].Healthy)
+	assert.Equal(t, "502 Bad Gateway", stats[1].LastError)
+	require.NotNil(t, stats[1].DownUntil)
+
+	// Back in rotation once the cooldown has passed
+	now = now.Add(time.Minute)
+	assert.True(t, p.Stats()[1].Healthy)
+	assert.Len(t, p.Order(), 3)
+}
+
+func TestLeastLatencyPrefersFastAndUnmeasuredBackends(t *testing.T) {
+	p := New([]string{"slow", "fast", "new"}, Options{Strategy: LeastLatency})
+
+	p.Succeeded("slow", 900*time.Millisecond)
+	p.Succeeded("fast", 200*time.Millisecond)
+	assert.Equal(t, []string{"new", "fast", "slow"}, p.Order())
+
+	p.Succeeded("new", 500*time.Millisecond)
+	assert.Equal(t, []string{"fast", "new", "slow"}, p.Order())
+
+	// The moving average follows a backend slowing down
+	for i := 0; i < 5; i++ {
+		p.Succeeded("fast", 2*time.Second)
+	}
+	assert.Equal(t, []string{"new", "slow", "fast"}, p.Order())
+}
+
+func TestHealthChecksTakeBackendsDownAndUp(t *testing.T) {
+	p := New([]string{"a", "b"}, Options{Strategy: LeastLatency})
+
+	p.SetHealth("a", false, "All health checks failed")
+	assert.Equal(t, []string{"b", "a"}, p.Order())
+
+	p.SetHealth("a", true, "")
+	p.SetHealth("b", false, "All health checks failed")
+	assert.Equal(t, []string{"a", "b"}, p.Order())
+
+	// A successful call brings a backend back before its cooldown ends
+	p.Succeeded("b", 100*time.Millisecond)
+	assert.True(t, p.Stats()[1].Healthy)
+
+	p.SetHealth("unknown", false, "ignored")
+	assert.Equal(t, []string{"a", "b"}, p.URLs())
+}
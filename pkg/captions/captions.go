@@ -0,0 +1,162 @@
+// Package captions writes transcripts as caption files while they are produced, one
+// segment at a time: SubRip (.srt), WebVTT (.vtt) or JSON lines (.jsonl) with one cue
+// per line. Segments are split into cues with the line-breaking rules of textformat.
+package captions
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-restream/stt/pkg/textformat"
+)
+
+// Format of a caption file
+type Format string
+
+// Caption formats
+const (
+	FormatSRT   Format = "srt"
+	FormatVTT   Format = "vtt"
+	FormatJSONL Format = "jsonl"
+)
+
+// ParseFormat returns the format named name, accepting "webvtt" for vtt
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "srt":
+		return FormatSRT, nil
+	case "vtt", "webvtt":
+		return FormatVTT, nil
+	case "jsonl":
+		return FormatJSONL, nil
+	}
+	return "", fmt.Errorf("unknown caption format %q, expected srt, vtt or jsonl", name)
+}
+
+// Ext returns the file extension of the format, with its dot
+func (f Format) Ext() string {
+	return "." + string(f)
+}
+
+// Segment is a transcript with its position in the audio
+type Segment struct {
+	ItemID   string
+	StartMs  int64
+	EndMs    int64
+	Text     string
+	Language string
+}
+
+// jsonCue is a line of a JSON lines caption file
+type jsonCue struct {
+	Number    int      `json:"number"`
+	ItemID    string   `json:"item_id,omitempty"`
+	StartMs   int64    `json:"start_ms"`
+	EndMs     int64    `json:"end_ms"`
+	Lines     []string `json:"lines"`
+	Language  string   `json:"language,omitempty"`
+	Direction string   `json:"direction,omitempty"`
+}
+
+// Writer writes the cues of segments to a caption file as they are transcribed. It is
+// not safe for concurrent use.
+type Writer struct {
+	w       io.Writer
+	format  Format
+	opts    textformat.CueOptions
+	cues    int  // cues written, numbering the next one
+	started bool // the header, if the format has one, is written
+}
+
+// NewWriter returns a writer of format to w, splitting segments into cues with opts
+func NewWriter(w io.Writer, format Format, opts textformat.CueOptions) *Writer {
+	return &Writer{w: w, format: format, opts: opts}
+}
+
+// Resume continues a file already holding cues cues and its header, numbering the cues
+// written next after them
+func (w *Writer) Resume(cues int) {
+	w.cues = cues
+	w.started = true
+}
+
+// Cues returns the number of cues in the file
+func (w *Writer) Cues() int {
+	return w.cues
+}
+
+// WriteSegment splits a segment into cues and writes them. Segments without text are
+// skipped.
+func (w *Writer) WriteSegment(segment Segment) error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, cue := range textformat.Cues(segment.Text, segment.StartMs, segment.EndMs, w.opts) {
+		number := w.cues + 1
+		switch w.format {
+		case FormatSRT:
+			if number > 1 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(textformat.SRTCue(number, cue))
+		case FormatVTT:
+			b.WriteString(textformat.WebVTTCue(number, cue))
+		default:
+			line, err := json.Marshal(jsonCue{
+				Number:    number,
+				ItemID:    segment.ItemID,
+				StartMs:   cue.StartMs,
+				EndMs:     cue.EndMs,
+				Lines:     cue.Lines,
+				Language:  segment.Language,
+				Direction: string(cue.Direction),
+			})
+			if err != nil {
+				return err
+			}
+			b.Write(line)
+			b.WriteByte('\n')
+		}
+		w.cues++
+	}
+	_, err := io.WriteString(w.w, b.String())
+	return err
+}
+
+// Flush writes the header of the format unless written, so that a file without cues
+// is valid too
+func (w *Writer) Flush() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	if w.format == FormatVTT {
+		_, err := io.WriteString(w.w, textformat.WebVTTHeader)
+		return err
+	}
+	return nil
+}
+
+// CountCues returns the number of cues of a caption file of format, for a Writer
+// resuming it
+func CountCues(r io.Reader, format Format) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	cues := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		if format == FormatJSONL {
+			if strings.TrimSpace(line) != "" {
+				cues++
+			}
+		} else if strings.Contains(line, " --> ") {
+			cues++
+		}
+	}
+	return cues, scanner.Err()
+}
@@ -0,0 +1,93 @@
+package captions
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-restream/stt/pkg/textformat"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var segments = []Segment{
+	{ItemID: "item_1", StartMs: 0, EndMs: 1500, Text: "hello world"},
+	{ItemID: "item_2", StartMs: 2000, EndMs: 4000, Text: "one two three four", Language: "en"},
+}
+
+func TestWriterMatchesWholeFile(t *testing.T) {
+	opts := textformat.CueOptions{MaxLineLength: 9, MaxLines: 1}
+	var cues []textformat.Cue
+	for _, segment := range segments {
+		cues = append(cues, textformat.Cues(segment.Text, segment.StartMs, segment.EndMs, opts)...)
+	}
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatSRT, textformat.SRT(cues)},
+		{FormatVTT, textformat.WebVTT(cues)},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var b bytes.Buffer
+			w := NewWriter(&b, tt.format, opts)
+			for _, segment := range segments {
+				require.NoError(t, w.WriteSegment(segment))
+			}
+			assert.Equal(t, tt.want, b.String())
+			assert.Equal(t, len(cues), w.Cues())
+
+			count, err := CountCues(strings.NewReader(b.String()), tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, len(cues), count)
+		})
+	}
+}
+
+func TestWriterResume(t *testing.T) {
+	var first, second bytes.Buffer
+	w := NewWriter(&first, FormatSRT, textformat.CueOptions{})
+	require.NoError(t, w.WriteSegment(segments[0]))
+
+	count, err := CountCues(strings.NewReader(first.String()), FormatSRT)
+	require.NoError(t, err)
+	resumed := NewWriter(&second, FormatSRT, textformat.CueOptions{})
+	resumed.Resume(count)
+	require.NoError(t, resumed.WriteSegment(segments[1]))
+
+	assert.True(t, strings.HasPrefix(second.String(), "\n2\n00:00:02,000 --> "))
+}
+
+func TestWriterJSONL(t *testing.T) {
+	var b bytes.Buffer
+	w := NewWriter(&b, FormatJSONL, textformat.CueOptions{})
+	for _, segment := range segments {
+		require.NoError(t, w.WriteSegment(segment))
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"number":1,"item_id":"item_1","start_ms":0,"end_ms":1500,"lines":["hello world"],"direction":"ltr"}`, lines[0])
+	assert.JSONEq(t, `{"number":2,"item_id":"item_2","start_ms":2000,"end_ms":4000,"lines":["one two three four"],"language":"en","direction":"ltr"}`, lines[1])
+}
+
+func TestFlushWritesEmptyFile(t *testing.T) {
+	var b bytes.Buffer
+	w := NewWriter(&b, FormatVTT, textformat.CueOptions{})
+	require.NoError(t, w.Flush())
+	require.NoError(t, w.WriteSegment(Segment{}))
+	assert.Equal(t, "WEBVTT\n", b.String())
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("WebVTT")
+	require.NoError(t, err)
+	assert.Equal(t, FormatVTT, format)
+	assert.Equal(t, ".vtt", format.Ext())
+
+	_, err = ParseFormat("ass")
+	assert.Error(t, err)
+}
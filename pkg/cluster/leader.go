@@ -0,0 +1,225 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-restream/stt/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Role of an instance in an active-passive pair
+type Role string
+
+const (
+	RoleActive  Role = "active"
+	RoleStandby Role = "standby"
+)
+
+// LeaderLock is a lease-based lock held by the active instance
+type LeaderLock interface {
+	// TryAcquire takes the lock if it is free, reporting whether this instance holds it
+	TryAcquire(ctx context.Context) (bool, error)
+	// Renew extends the lease, reporting false if the lock was lost
+	Renew(ctx context.Context) (bool, error)
+	// Release gives up the lock if held by this instance
+	Release(ctx context.Context) error
+}
+
+// renewScript extends the lease only if this instance still owns the lock
+const renewScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+
+// releaseScript deletes the lock only if this instance still owns it
+const releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// RedisLeaderLock implements LeaderLock with SET NX PX and ownership-checked scripts
+type RedisLeaderLock struct {
+	client     *RedisClient
+	key        string
+	instanceID string
+	ttl        time.Duration
+}
+
+// NewRedisLeaderLock creates a leader lock stored at key with the given lease TTL
+func NewRedisLeaderLock(client *RedisClient, key, instanceID string, ttl time.Duration) *RedisLeaderLock {
+	return &RedisLeaderLock{
+		client:     client,
+		key:        key,
+		instanceID: instanceID,
+		ttl:        ttl,
+	}
+}
+
+func (l *RedisLeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	reply, err := l.client.Do(ctx, "SET", l.key, l.instanceID, "NX", "PX", strconv.FormatInt(l.ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	return reply == "OK", nil
+}
+
+func (l *RedisLeaderLock) Renew(ctx context.Context) (bool, error) {
+	reply, err := l.client.Do(ctx, "EVAL", renewScript, "1", l.key, l.instanceID, strconv.FormatInt(l.ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	n, _ := reply.(int64)
+	return n == 1, nil
+}
+
+func (l *RedisLeaderLock) Release(ctx context.Context) error {
+	_, err := l.client.Do(ctx, "EVAL", releaseScript, "1", l.key, l.instanceID)
+	return err
+}
+
+// Elector runs the acquire/renew loop and reports role changes
+type Elector struct {
+	lock     LeaderLock
+	ttl      time.Duration
+	interval time.Duration
+
+	// OnElected is called when this instance becomes active. If it fails, for example
+	// because the service port cannot be bound, the instance stays standby and releases
+	// the lock so that another instance can take over.
+	OnElected func() error
+	// OnDemoted is called when this instance loses the lock and must stop serving
+	OnDemoted func()
+
+	mutex sync.RWMutex
+	role  Role
+}
+
+// NewElector creates an elector that renews the lease every third of its TTL
+func NewElector(lock LeaderLock, ttl time.Duration) *Elector {
+	return &Elector{
+		lock:     lock,
+		ttl:      ttl,
+		interval: ttl / 3,
+		role:     RoleStandby,
+	}
+}
+
+// Role returns the current role of this instance
+func (e *Elector) Role() Role {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.role
+}
+
+// IsActive reports whether this instance holds the leader lock
+func (e *Elector) IsActive() bool {
+	return e.Role() == RoleActive
+}
+
+// Run blocks until ctx is cancelled. An active instance then steps down, calling
+// OnDemoted, before it releases the lock.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			if e.IsActive() {
+				e.setRole(RoleStandby)
+				if e.OnDemoted != nil {
+					e.OnDemoted()
+				}
+				releaseCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+				e.lock.Release(releaseCtx)
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) tick(ctx context.Context) {
+	tickCtx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+
+	if !e.IsActive() {
+		acquired, err := e.lock.TryAcquire(tickCtx)
+		if err != nil {
+			logger.WithFields(logrus.Fields{
+				"component": "cluster_leader ",
+				"action":    "acquire_failed",
+				"error":     err,
+			}).Warn("Failed to acquire leader lock")
+			return
+		}
+		if !acquired {
+			return
+		}
+		e.setRole(RoleActive)
+		if e.OnElected == nil {
+			return
+		}
+		if err := e.OnElected(); err != nil {
+			logger.WithFields(logrus.Fields{
+				"component": "cluster_leader ",
+				"action":    "activation_failed",
+				"error":     err,
+			}).Error("Failed to become active, releasing leader lock")
+			e.setRole(RoleStandby)
+			if err := e.lock.Release(tickCtx); err != nil {
+				logger.WithFields(logrus.Fields{
+					"component": "cluster_leader ",
+					"action":    "release_failed",
+					"error":     err,
+				}).Warn("Failed to release leader lock")
+			}
+		}
+		return
+	}
+
+	renewed, err := e.lock.Renew(tickCtx)
+	if err == nil && renewed {
+		return
+	}
+
+	// Lost the lease, or could not confirm it before it expires: step down so
+	// that two instances never serve at the same time
+	logger.WithFields(logrus.Fields{
+		"component": "cluster_leader ",
+		"action":    "leadership_lost",
+		"error":     err,
+	}).Warn("Lost leader lock, switching to standby")
+	e.setRole(RoleStandby)
+	if e.OnDemoted != nil {
+		e.OnDemoted()
+	}
+}
+
+func (e *Elector) setRole(role Role) {
+	e.mutex.Lock()
+	previous := e.role
+	e.role = role
+	e.mutex.Unlock()
+
+	if previous != role {
+		logger.WithFields(logrus.Fields{
+			"component": "cluster_leader ",
+			"action":    "role_changed",
+			"from":      previous,
+			"to":        role,
+		}).Info("Cluster role changed")
+	}
+}
+
+// DefaultInstanceID returns hostname-pid, unique enough for a leader lock owner
+func DefaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "stt"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
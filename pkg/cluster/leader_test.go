@@ -0,0 +1,169 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLock is a LeaderLock whose answers the test sets
+type fakeLock struct {
+	mutex      sync.Mutex
+	free       bool
+	renewOK    bool
+	renewErr   error
+	released   int
+	operations []string
+}
+
+func (l *fakeLock) TryAcquire(context.Context) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.free {
+		return false, nil
+	}
+	l.free = false
+	return true, nil
+}
+
+func (l *fakeLock) Renew(context.Context) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.renewOK, l.renewErr
+}
+
+func (l *fakeLock) Release(context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.released++
+	l.free = true
+	l.operations = append(l.operations, "release")
+	return nil
+}
+
+func (l *fakeLock) set(fn func(l *fakeLock)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	fn(l)
+}
+
+func (l *fakeLock) log(operation string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.operations = append(l.operations, operation)
+}
+
+func (l *fakeLock) snapshot() (released int, operations []string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.released, append([]string(nil), l.operations...)
+}
+
+func TestElectorRoles(t *testing.T) {
+	tests := []struct {
+		name     string
+		renewOK  bool
+		renewErr error
+		active   bool // role after the second tick
+		demoted  int
+	}{
+		{name: "renewed", renewOK: true, active: true},
+		{name: "lock lost", renewOK: false, active: false, demoted: 1},
+		{name: "renew failed", renewErr: errors.New("timeout"), active: false, demoted: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lock := &fakeLock{free: true, renewOK: tt.renewOK, renewErr: tt.renewErr}
+			elector := NewElector(lock, 30*time.Millisecond)
+			elected, demoted := 0, 0
+			elector.OnElected = func() error {
+				elected++
+				return nil
+			}
+			elector.OnDemoted = func() { demoted++ }
+
+			assert.Equal(t, RoleStandby, elector.Role())
+			elector.tick(context.Background())
+			assert.True(t, elector.IsActive())
+			assert.Equal(t, 1, elected)
+
+			elector.tick(context.Background())
+			assert.Equal(t, tt.active, elector.IsActive())
+			assert.Equal(t, tt.demoted, demoted)
+		})
+	}
+}
+
+func TestElectorStandbyWhileLockHeld(t *testing.T) {
+	lock := &fakeLock{}
+	elector := NewElector(lock, 30*time.Millisecond)
+	elector.OnElected = func() error {
+		t.Fatal("elected while the lock is held elsewhere")
+		return nil
+	}
+
+	elector.tick(context.Background())
+	assert.Equal(t, RoleStandby, elector.Role())
+}
+
+func TestElectorReleasesLockWhenActivationFails(t *testing.T) {
+	lock := &fakeLock{free: true, renewOK: true}
+	elector := NewElector(lock, 30*time.Millisecond)
+	bindErr := errors.New("listen tcp :8080: bind: address already in use")
+	demoted := 0
+	elector.OnElected = func() error { return bindErr }
+	elector.OnDemoted = func() { demoted++ }
+
+	elector.tick(context.Background())
+	assert.Equal(t, RoleStandby, elector.Role())
+	released, _ := lock.snapshot()
+	assert.Equal(t, 1, released)
+	// Nothing was served, so there is nothing to step down from
+	assert.Zero(t, demoted)
+
+	// Once the port is free the next election succeeds
+	bindErr = nil
+	elector.tick(context.Background())
+	assert.True(t, elector.IsActive())
+}
+
+func TestElectorStepsDownBeforeRelease(t *testing.T) {
+	lock := &fakeLock{free: true, renewOK: true}
+	elector := NewElector(lock, 30*time.Millisecond)
+	elector.OnDemoted = func() { lock.log("demoted") }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		elector.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, elector.IsActive, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	released, operations := lock.snapshot()
+	assert.Equal(t, 1, released)
+	assert.Equal(t, []string{"demoted", "release"}, operations)
+	assert.Equal(t, RoleStandby, elector.Role())
+}
+
+func TestElectorStandbyExitKeepsLock(t *testing.T) {
+	lock := &fakeLock{}
+	elector := NewElector(lock, 30*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	elector.Run(ctx)
+
+	lock.set(func(l *fakeLock) { assert.Zero(t, l.released) })
+}
@@ -0,0 +1,203 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNil is returned for a nil reply, e.g. GET of a missing key
+var ErrNil = errors.New("redis: nil reply")
+
+// RedisClient is a minimal Redis client speaking RESP2 over a single connection.
+// It implements only what the leader lock and the session store need.
+type RedisClient struct {
+	Addr     string
+	Password string
+	DB       int
+	Timeout  time.Duration
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisClient creates a client for the Redis server at addr
+func NewRedisClient(addr, password string, db int) *RedisClient {
+	return &RedisClient{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+		Timeout:  3 * time.Second,
+	}
+}
+
+// Do sends a command and returns its reply: string, int64, []interface{} or nil.
+// The connection is re-established after any error.
+func (c *RedisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := c.roundTrip(ctx, args)
+	if err != nil {
+		var redisErr redisError
+		if !errors.As(err, &redisErr) {
+			c.conn.Close()
+			c.conn = nil
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Get returns the value of key, or ErrNil if it does not exist
+func (c *RedisClient) Get(ctx context.Context, key string) (string, error) {
+	reply, err := c.Do(ctx, "GET", key)
+	if err != nil {
+		return "", err
+	}
+	if reply == nil {
+		return "", ErrNil
+	}
+	value, _ := reply.(string)
+	return value, nil
+}
+
+// SetWithTTL sets key to value with an expiry
+func (c *RedisClient) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := c.Do(ctx, "SET", key, value, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// Close closes the underlying connection
+func (c *RedisClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *RedisClient) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: c.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return fmt.Errorf("redis dial %s failed: %v", c.Addr, err)
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	if c.Password != "" {
+		if _, err := c.roundTrip(ctx, []string{"AUTH", c.Password}); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return fmt.Errorf("redis auth failed: %v", err)
+		}
+	}
+	if c.DB != 0 {
+		if _, err := c.roundTrip(ctx, []string{"SELECT", strconv.Itoa(c.DB)}); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return fmt.Errorf("redis select failed: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c *RedisClient) roundTrip(ctx context.Context, args []string) (interface{}, error) {
+	deadline := time.Now().Add(c.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	return c.readReply()
+}
+
+// redisError is an error reply sent by the server; the connection stays usable
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func (c *RedisClient) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("redis: short reply %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
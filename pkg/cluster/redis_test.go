@@ -0,0 +1,195 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis serves GET, SET, AUTH and EVAL of the leader lock scripts from a map,
+// enough to run the client and the lock against
+type fakeRedis struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	values   map[string]string
+	password string
+}
+
+func startFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeRedis{listener: listener, values: make(map[string]string), password: password}
+	t.Cleanup(func() { listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (s *fakeRedis) addr() string { return s.listener.Addr().String() }
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if strings.ToUpper(args[0]) == "AUTH" {
+			if args[1] != s.password {
+				fmt.Fprint(conn, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			authed = true
+			fmt.Fprint(conn, "+OK\r\n")
+			continue
+		}
+		if !authed {
+			fmt.Fprint(conn, "-NOAUTH Authentication required\r\n")
+			continue
+		}
+		fmt.Fprint(conn, s.execute(args))
+	}
+}
+
+func (s *fakeRedis) execute(args []string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		value, ok := s.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		if len(args) > 3 && strings.EqualFold(args[3], "NX") {
+			if _, ok := s.values[args[1]]; ok {
+				return "$-1\r\n"
+			}
+		}
+		s.values[args[1]] = args[2]
+		return "+OK\r\n"
+	case "EVAL":
+		// Both scripts act only while ARGV[1] owns KEYS[1]
+		key, owner := args[3], args[4]
+		if s.values[key] != owner {
+			return ":0\r\n"
+		}
+		if args[1] == releaseScript {
+			delete(s.values, key)
+		}
+		return ":1\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func TestRedisClientGetSet(t *testing.T) {
+	server := startFakeRedis(t, "secret")
+	client := NewRedisClient(server.addr(), "secret", 0)
+	defer client.Close()
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNil)
+
+	require.NoError(t, client.SetWithTTL(ctx, "key", "value\r\nwith newline", time.Minute))
+	value, err := client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value\r\nwith newline", value)
+
+	// An error reply keeps the connection usable
+	_, err = client.Do(ctx, "UNKNOWN")
+	assert.ErrorContains(t, err, "unknown command")
+	value, err = client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value\r\nwith newline", value)
+}
+
+func TestRedisClientAuthFailure(t *testing.T) {
+	server := startFakeRedis(t, "secret")
+	client := NewRedisClient(server.addr(), "wrong", 0)
+	defer client.Close()
+
+	_, err := client.Get(context.Background(), "key")
+	assert.ErrorContains(t, err, "auth failed")
+}
+
+func TestRedisLeaderLock(t *testing.T) {
+	server := startFakeRedis(t, "")
+	client := NewRedisClient(server.addr(), "", 0)
+	defer client.Close()
+	ctx := context.Background()
+	first := NewRedisLeaderLock(client, "stt:leader", "a", time.Second)
+	second := NewRedisLeaderLock(client, "stt:leader", "b", time.Second)
+
+	acquired, err := first.TryAcquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	acquired, err = second.TryAcquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, acquired, "lock held by the first instance")
+
+	renewed, err := second.Renew(ctx)
+	require.NoError(t, err)
+	assert.False(t, renewed, "only the owner renews")
+	require.NoError(t, second.Release(ctx))
+	renewed, err = first.Renew(ctx)
+	require.NoError(t, err)
+	assert.True(t, renewed, "release by another instance keeps the lock")
+
+	require.NoError(t, first.Release(ctx))
+	acquired, err = second.TryAcquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
@@ -0,0 +1,222 @@
+package denoise
+
+import (
+	"math"
+
+	"github.com/go-restream/stt/pkg/dsp"
+)
+
+const (
+	frameSize = 512 // 32ms at 16kHz
+	hopSize   = frameSize / 2
+
+	// Band energy is smoothed over a few frames, so the random fluctuation of noise
+	// is not taken for speech
+	energySmoothing = 0.5
+
+	// The noise estimate follows a falling band energy at once and rises slowly, so it
+	// tracks the minimum between words and learns a louder background within seconds
+	noiseRise = 0.02
+
+	// Weight of the previous frame in the decision-directed estimate of the speech to
+	// noise ratio, which keeps the gains from fluctuating on noise ("musical noise")
+	priorSmoothing = 0.9
+)
+
+// bandEdges are the band centers in Hz, the 5ms bands of RNNoise up to 8kHz
+var bandEdges = []float64{0, 200, 400, 600, 800, 1000, 1200, 1400, 1600, 2000, 2400, 2800, 3200, 4000, 4800, 5600, 6800, 8000}
+
+// BandOptions configure a BandDenoiser. Zero values use defaults.
+type BandOptions struct {
+	MaxReductionDB float64 // attenuation of bands holding only noise, default 30
+}
+
+// BandDenoiser suppresses noise RNNoise-style: the spectrum is split into triangular
+// bands on a Bark-like scale, a gain is computed per band and interpolated over the
+// frequency bins. Where RNNoise infers the gains with a neural network, they come from
+// a Wiener filter over a minimum-tracking noise estimate here, which handles
+// stationary noise well and needs no model.
+//
+// The noise estimate carries over calls, so a stream is best processed by one
+// denoiser; Reset starts over. Output is not delayed.
+type BandDenoiser struct {
+	sampleRate int
+	minGain    float64
+	window     []float64 // applied before the FFT and after the IFFT
+	bins       []binBand // band position of each bin
+	energy     []float64 // smoothed band energy
+	noise      []float64
+	gain       []float64
+	prior      []float64 // band energy after the gains of the previous frame
+	primed     bool
+	spectrum   []complex128
+}
+
+// binBand locates a frequency bin between the centers of band and band+1
+type binBand struct {
+	band int
+	frac float64 // weight of band+1
+}
+
+// NewBandDenoiser creates a band denoiser for audio at sampleRate
+func NewBandDenoiser(sampleRate int, opts BandOptions) *BandDenoiser {
+	if opts.MaxReductionDB <= 0 {
+		opts.MaxReductionDB = 30
+	}
+	bands := len(bandEdges)
+	d := &BandDenoiser{
+		sampleRate: sampleRate,
+		minGain:    math.Pow(10, -opts.MaxReductionDB/20),
+		window:     dsp.SqrtHann(frameSize),
+		bins:       make([]binBand, frameSize/2+1),
+		energy:     make([]float64, bands),
+		noise:      make([]float64, bands),
+		gain:       make([]float64, bands),
+		prior:      make([]float64, bands),
+		spectrum:   make([]complex128, frameSize),
+	}
+	for bin := range d.bins {
+		freq := float64(bin) * float64(sampleRate) / frameSize
+		band := 0
+		for band < bands-2 && freq >= bandEdges[band+1] {
+			band++
+		}
+		frac := (freq - bandEdges[band]) / (bandEdges[band+1] - bandEdges[band])
+		d.bins[bin] = binBand{band: band, frac: min(frac, 1)}
+	}
+	return d
+}
+
+// Process denoises the samples. They are framed on their own, with the noise
+// estimate of the previous calls; the first call starts from the quietest frames.
+func (d *BandDenoiser) Process(samples []float32) []float32 {
+	if len(samples) == 0 {
+		return samples
+	}
+
+	// Pad so every sample is covered by two frames. Frames reaching into the padding
+	// are not learned from, their energy is too low.
+	frames := (len(samples)+hopSize-1)/hopSize + 1
+	padded := make([]float64, (frames+1)*hopSize)
+	for i, s := range samples {
+		padded[hopSize+i] = float64(s)
+	}
+	inside := func(f int) bool {
+		return f > 0 && f*hopSize+frameSize <= hopSize+len(samples)
+	}
+	if !d.primed {
+		d.learnNoise(padded, frames, inside)
+	}
+
+	output := make([]float64, len(padded))
+	for f := 0; f < frames; f++ {
+		start := f * hopSize
+		d.analyze(padded[start : start+frameSize])
+		d.processFrame(inside(f))
+		dsp.IFFT(d.spectrum)
+		for i := range d.spectrum {
+			output[start+i] += real(d.spectrum[i]) * d.window[i]
+		}
+	}
+
+	out := make([]float32, len(samples))
+	for i := range out {
+		out[i] = float32(output[hopSize+i])
+	}
+	return out
+}
+
+// learnNoise sets the initial noise estimate to the lowest band energies of the frames
+func (d *BandDenoiser) learnNoise(padded []float64, frames int, inside func(int) bool) {
+	learned := false
+	for f := 0; f < frames; f++ {
+		if !inside(f) {
+			continue
+		}
+		d.analyze(padded[f*hopSize : f*hopSize+frameSize])
+		for band, e := range d.bandEnergy() {
+			if !learned || e < d.noise[band] {
+				d.noise[band] = e
+			}
+		}
+		learned = true
+	}
+	if learned {
+		copy(d.energy, d.noise)
+		copy(d.prior, d.noise)
+		d.primed = true
+	}
+}
+
+// analyze puts the spectrum of a windowed frame in d.spectrum
+func (d *BandDenoiser) analyze(frame []float64) {
+	for i := range d.spectrum {
+		d.spectrum[i] = complex(frame[i]*d.window[i], 0)
+	}
+	dsp.FFT(d.spectrum)
+}
+
+// bandEnergy returns the energy of each band of d.spectrum
+func (d *BandDenoiser) bandEnergy() []float64 {
+	energy := make([]float64, len(d.energy))
+	for bin, position := range d.bins {
+		c := d.spectrum[bin]
+		power := real(c)*real(c) + imag(c)*imag(c)
+		energy[position.band] += power * (1 - position.frac)
+		energy[position.band+1] += power * position.frac
+	}
+	for band := range energy {
+		energy[band] += 1e-9 // digital silence
+	}
+	return energy
+}
+
+// processFrame applies the band gains to d.spectrum, updating the noise estimate
+// when learn is set
+func (d *BandDenoiser) processFrame(learn bool) {
+	for band, e := range d.bandEnergy() {
+		if d.primed {
+			e = d.energy[band]*energySmoothing + e*(1-energySmoothing)
+		}
+		d.energy[band] = e
+
+		switch {
+		case !d.primed:
+			d.noise[band] = e
+		case !learn:
+		case e < d.noise[band]:
+			d.noise[band] = e
+		default:
+			d.noise[band] *= 1 + noiseRise
+		}
+
+		// Wiener gain from the decision-directed a priori speech to noise ratio
+		snr := math.Max(e/d.noise[band]-1, 0)
+		if d.primed {
+			snr = priorSmoothing*d.prior[band]/d.noise[band] + (1-priorSmoothing)*snr
+		}
+		gain := math.Max(snr/(1+snr), d.minGain)
+		d.gain[band] = gain
+		d.prior[band] = gain * gain * e
+	}
+	d.primed = true
+
+	for bin, position := range d.bins {
+		gain := d.gain[position.band]*(1-position.frac) + d.gain[position.band+1]*position.frac
+		d.spectrum[bin] *= complex(gain, 0)
+		if bin > 0 && bin < frameSize/2 {
+			d.spectrum[frameSize-bin] = complex(real(d.spectrum[bin]), -imag(d.spectrum[bin]))
+		}
+	}
+}
+
+// Reset forgets the noise estimate
+func (d *BandDenoiser) Reset() {
+	d.primed = false
+	for band := range d.gain {
+		d.energy[band], d.noise[band], d.gain[band], d.prior[band] = 0, 0, 0, 0
+	}
+}
+
+// Close is a no-op, the denoiser holds no resources
+func (d *BandDenoiser) Close() {}
@@ -0,0 +1,88 @@
+package denoise
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rms(samples []float32) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func noise(rng *rand.Rand, n int, level float64) []float32 {
+	samples := make([]float32, n)
+	for i := range samples {
+		samples[i] = float32(rng.NormFloat64() * level)
+	}
+	return samples
+}
+
+func TestBandDenoiserKeepsLength(t *testing.T) {
+	d := NewBandDenoiser(16000, BandOptions{})
+	for _, n := range []int{1, 100, 256, 333, 1600} {
+		assert.Len(t, d.Process(make([]float32, n)), n)
+	}
+	assert.Empty(t, d.Process(nil))
+}
+
+func TestBandDenoiserAttenuatesSteadyNoise(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	d := NewBandDenoiser(16000, BandOptions{})
+	d.Process(noise(rng, 16000, 0.01))
+
+	in := noise(rng, 16000, 0.01)
+	assert.Less(t, rms(d.Process(in)), rms(in)*0.3)
+}
+
+func TestBandDenoiserPassesToneOverNoise(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	d := NewBandDenoiser(16000, BandOptions{})
+	d.Process(noise(rng, 16000, 0.01))
+
+	in := noise(rng, 16000, 0.01)
+	for i := range in {
+		in[i] += float32(0.3 * math.Sin(2*math.Pi*440*float64(i)/16000))
+	}
+	out := d.Process(in)
+	// The first frames ramp up the gain of the tone
+	assert.InDelta(t, rms(in[1600:]), rms(out[1600:]), rms(in[1600:])*0.1)
+}
+
+func TestBandDenoiserLearnsNoiseOnFirstCall(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// Speech-like bursts with noise between them, the noise is learned from the gaps
+	in := noise(rng, 32000, 0.01)
+	for i := range in {
+		if (i/4000)%2 == 0 {
+			in[i] += float32(0.3 * math.Sin(2*math.Pi*300*float64(i)/16000))
+		}
+	}
+	out := NewBandDenoiser(16000, BandOptions{}).Process(in)
+	gap := func(samples []float32) []float32 { return samples[5000:7000] }
+	assert.Less(t, rms(gap(out)), rms(gap(in))*0.5)
+}
+
+func TestBandDenoiserReset(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	d := NewBandDenoiser(16000, BandOptions{})
+	d.Process(noise(rng, 16000, 0.1))
+	d.Reset()
+	assert.False(t, d.primed)
+	assert.Zero(t, d.noise[3])
+}
+
+func TestMix(t *testing.T) {
+	original := []float32{1, 1, 1}
+	denoised := []float32{0, 0.5, 1}
+	assert.Equal(t, denoised, Mix(original, denoised, 1))
+	assert.Equal(t, original, Mix(original, denoised, 0))
+	assert.Equal(t, []float32{0.5, 0.75, 1}, Mix(original, denoised, 0.5))
+	assert.Len(t, Mix(original, denoised[:2], 0.5), 2)
+}
@@ -0,0 +1,32 @@
+// Package denoise holds the Denoiser interface of the noise reduction engines and
+// BandDenoiser, a pure-Go engine needing no model.
+package denoise
+
+// Denoiser removes background noise from mono speech. Implementations are not safe
+// for concurrent use.
+type Denoiser interface {
+	// Process returns the samples with the noise removed, as many as given
+	Process(samples []float32) []float32
+	// Reset forgets the noise learned so far
+	Reset()
+	// Close releases the resources of the denoiser
+	Close()
+}
+
+// Mix blends denoised samples into the original ones: strength 1 returns the
+// denoised samples, 0 the original. Samples beyond the shorter slice are dropped.
+func Mix(original, denoised []float32, strength float64) []float32 {
+	if strength >= 1 {
+		return denoised
+	}
+	n := min(len(original), len(denoised))
+	if strength <= 0 {
+		return original[:n]
+	}
+	out := make([]float32, n)
+	wet := float32(strength)
+	for i := range out {
+		out[i] = original[i] + (denoised[i]-original[i])*wet
+	}
+	return out
+}
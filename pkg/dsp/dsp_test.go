@@ -0,0 +1,41 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFFTRoundTrip(t *testing.T) {
+	x := make([]complex128, 64)
+	for i := range x {
+		x[i] = complex(float64(i%7), float64(i%3))
+	}
+	y := append([]complex128(nil), x...)
+	FFT(y)
+	IFFT(y)
+	for i := range x {
+		assert.InDelta(t, 0, cmplx.Abs(x[i]-y[i]), 1e-9)
+	}
+}
+
+func TestFFTTone(t *testing.T) {
+	x := make([]complex128, 32)
+	for i := range x {
+		x[i] = complex(math.Cos(2*math.Pi*4*float64(i)/32), 0)
+	}
+	FFT(x)
+	assert.InDelta(t, 16, cmplx.Abs(x[4]), 1e-9)
+	assert.InDelta(t, 0, cmplx.Abs(x[5]), 1e-9)
+}
+
+func TestSqrtHannOverlapAdds(t *testing.T) {
+	const n = 16
+	window := SqrtHann(n)
+	assert.Zero(t, window[0])
+	for i := 0; i < n/2; i++ {
+		assert.InDelta(t, 1, window[i]*window[i]+window[i+n/2]*window[i+n/2], 1e-12)
+	}
+}
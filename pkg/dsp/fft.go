@@ -0,0 +1,47 @@
+// Package dsp holds the signal processing building blocks shared by the audio
+// packages: a radix-2 FFT and the analysis windows of the spectral processors.
+package dsp
+
+import (
+	"math"
+	"math/bits"
+)
+
+// FFT transforms x in place with an iterative radix-2 FFT; len(x) must be a power of
+// two
+func FFT(x []complex128) {
+	transform(x, -1)
+}
+
+// IFFT inverts FFT in place, scaling by 1/len(x)
+func IFFT(x []complex128) {
+	transform(x, 1)
+	scale := complex(1/float64(len(x)), 0)
+	for i := range x {
+		x[i] *= scale
+	}
+}
+
+// transform is the unscaled transform, sign is that of the twiddle exponent
+func transform(x []complex128, sign float64) {
+	n := len(x)
+	shift := 64 - uint(bits.Len(uint(n))-1)
+	for i := range x {
+		if j := int(bits.Reverse64(uint64(i)) >> shift); j > i {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := complex(math.Cos(2*math.Pi/float64(size)), sign*math.Sin(2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], w*x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
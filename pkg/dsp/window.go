@@ -0,0 +1,14 @@
+package dsp
+
+import "math"
+
+// SqrtHann returns the square root of the periodic Hann window of n samples. Used
+// for both analysis and synthesis its squares sum to one at 50% overlap, so frames
+// processed with unity gain are rebuilt exactly.
+func SqrtHann(n int) []float64 {
+	window := make([]float64, n)
+	for i := range window {
+		window[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n)))
+	}
+	return window
+}
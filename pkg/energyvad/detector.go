@@ -0,0 +1,253 @@
+// Package energyvad is a pure-Go voice activity detector for deployments without the
+// ONNX runtime or the Silero model. It marks frames whose level rises far enough above
+// a tracked noise floor as speech and groups them into segments with the same duration
+// rules as the Silero VAD, behind the same method set as the sherpa-onnx detector.
+// Noise-like frames, those crossing zero as often as broadband noise does, must rise
+// further above the floor to count as speech.
+package energyvad
+
+import "math"
+
+const (
+	// minSpeechDB is the level below which a frame is never speech, whatever the floor
+	minSpeechDB = -55.0
+	// initialFloorDB is the noise floor assumed before the first frame
+	initialFloorDB = -70.0
+	// prefixFrames of audio before the first speech frame are kept in the segment so
+	// soft onsets are not clipped
+	prefixFrames = 3
+	// noiseZeroCrossingRate is the rate of zero crossings per sample above which a
+	// frame sounds like noise rather than voiced speech; white noise crosses at 0.5,
+	// vowels below 0.15 and fricatives around 0.3
+	noiseZeroCrossingRate = 0.35
+	// noiseMarginDB is the extra level above the floor a noise-like frame needs
+	noiseMarginDB = 10.0
+)
+
+// Options configures a Detector. Durations are in seconds, as in the vad section of
+// the configuration.
+type Options struct {
+	SampleRate         int
+	FrameSize          int     // samples per analysis frame
+	Threshold          float32 // 0 to 1 like the Silero threshold, higher needs louder speech
+	MinSilenceDuration float32 // silence ending a segment
+	MinSpeechDuration  float32 // shorter bursts are dropped
+	MaxSpeechDuration  float32 // longer speech is split, 0 never splits
+}
+
+// Segment is a detected speech segment. Start is its first sample, counted from the
+// creation or last Reset of the detector.
+type Segment struct {
+	Start   int
+	Samples []float32
+}
+
+// Detector finds speech segments in a stream of samples. It is not safe for
+// concurrent use.
+type Detector struct {
+	frameSize        int
+	marginDB         float64
+	minSilenceFrames int
+	minSpeechFrames  int
+	maxSpeechSamples int
+
+	pending   []float32 // samples not yet forming a whole frame
+	processed int       // samples consumed into frames
+	floorDB   float64
+	prefix    [][]float32
+
+	inSpeech     bool
+	speechStart  int
+	speech       []float32
+	voiceFrames  int
+	silentFrames int
+
+	segments []Segment
+}
+
+// New creates a detector, defaulting to 16kHz audio in 512 sample frames
+func New(opts Options) *Detector {
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = 16000
+	}
+	if opts.FrameSize <= 0 {
+		opts.FrameSize = 512
+	}
+	if opts.Threshold <= 0 || opts.Threshold >= 1 {
+		opts.Threshold = 0.5
+	}
+	framesPer := func(seconds float32) int {
+		return int(math.Ceil(float64(seconds) * float64(opts.SampleRate) / float64(opts.FrameSize)))
+	}
+
+	d := &Detector{
+		frameSize:        opts.FrameSize,
+		marginDB:         6 + 12*float64(opts.Threshold), // 12dB above the floor at 0.5
+		minSilenceFrames: max(1, framesPer(opts.MinSilenceDuration)),
+		minSpeechFrames:  max(1, framesPer(opts.MinSpeechDuration)),
+		maxSpeechSamples: int(opts.MaxSpeechDuration * float32(opts.SampleRate)),
+	}
+	d.Reset()
+	return d
+}
+
+// AcceptWaveform analyzes the samples, queueing every speech segment that ends in them
+func (d *Detector) AcceptWaveform(samples []float32) {
+	d.pending = append(d.pending, samples...)
+	for len(d.pending) >= d.frameSize {
+		frame := append([]float32(nil), d.pending[:d.frameSize]...)
+		d.pending = d.pending[d.frameSize:]
+		d.processFrame(frame)
+	}
+}
+
+func (d *Detector) processFrame(frame []float32) {
+	start := d.processed
+	d.processed += len(frame)
+
+	level := levelDB(frame)
+	margin := d.marginDB
+	if zeroCrossingRate(frame) > noiseZeroCrossingRate {
+		margin += noiseMarginDB
+	}
+	voiced := level > minSpeechDB && level > d.floorDB+margin
+
+	// The floor follows quiet frames quickly downwards and slowly upwards, and creeps up
+	// even during speech so a lasting rise of the background is eventually learned
+	switch {
+	case level < d.floorDB:
+		d.floorDB += (level - d.floorDB) * 0.5
+	case !voiced:
+		d.floorDB += (level - d.floorDB) * 0.05
+	default:
+		d.floorDB += (level - d.floorDB) * 0.01
+	}
+
+	if !d.inSpeech {
+		if !voiced {
+			d.prefix = append(d.prefix, frame)
+			if len(d.prefix) > prefixFrames {
+				d.prefix = d.prefix[1:]
+			}
+			return
+		}
+		d.inSpeech = true
+		d.speechStart = start
+		d.speech = d.speech[:0]
+		for _, previous := range d.prefix {
+			d.speechStart -= len(previous)
+			d.speech = append(d.speech, previous...)
+		}
+		d.prefix = d.prefix[:0]
+		d.voiceFrames = 0
+		d.silentFrames = 0
+	}
+
+	d.speech = append(d.speech, frame...)
+	if voiced {
+		d.voiceFrames++
+		d.silentFrames = 0
+	} else {
+		d.silentFrames++
+	}
+
+	switch {
+	case d.silentFrames >= d.minSilenceFrames:
+		d.endSpeech()
+	case d.maxSpeechSamples > 0 && len(d.speech) >= d.maxSpeechSamples:
+		// Split long speech, the next frames continue a new segment
+		d.endSpeech()
+		d.inSpeech = true
+		d.speechStart = d.processed
+		d.voiceFrames = d.minSpeechFrames
+	}
+}
+
+// endSpeech queues the speech in progress when it was long enough
+func (d *Detector) endSpeech() {
+	if d.voiceFrames >= d.minSpeechFrames && len(d.speech) > 0 {
+		d.segments = append(d.segments, Segment{
+			Start:   d.speechStart,
+			Samples: append([]float32(nil), d.speech...),
+		})
+	}
+	d.inSpeech = false
+	d.speech = d.speech[:0]
+	d.voiceFrames = 0
+	d.silentFrames = 0
+}
+
+// IsSpeech reports whether speech is in progress
+func (d *Detector) IsSpeech() bool {
+	return d.inSpeech
+}
+
+// IsEmpty reports whether no segment is queued
+func (d *Detector) IsEmpty() bool {
+	return len(d.segments) == 0
+}
+
+// Front returns the oldest queued segment, nil when none is queued
+func (d *Detector) Front() *Segment {
+	if len(d.segments) == 0 {
+		return nil
+	}
+	segment := d.segments[0]
+	return &segment
+}
+
+// Pop removes the oldest queued segment
+func (d *Detector) Pop() {
+	if len(d.segments) > 0 {
+		d.segments = d.segments[1:]
+	}
+}
+
+// Flush ends the speech in progress, queueing it as a segment
+func (d *Detector) Flush() {
+	if d.inSpeech {
+		if len(d.pending) > 0 {
+			d.speech = append(d.speech, d.pending...)
+			d.processed += len(d.pending)
+		}
+		d.endSpeech()
+	}
+	d.pending = d.pending[:0]
+}
+
+// Reset drops all state, including queued segments, and restarts the sample count
+func (d *Detector) Reset() {
+	d.pending = d.pending[:0]
+	d.processed = 0
+	d.floorDB = initialFloorDB
+	d.prefix = d.prefix[:0]
+	d.inSpeech = false
+	d.speech = d.speech[:0]
+	d.voiceFrames = 0
+	d.silentFrames = 0
+	d.segments = nil
+}
+
+// zeroCrossingRate is the share of adjacent samples of a frame differing in sign
+func zeroCrossingRate(frame []float32) float64 {
+	if len(frame) < 2 {
+		return 0
+	}
+	crossings := 0
+	for i := 1; i < len(frame); i++ {
+		if (frame[i-1] >= 0) != (frame[i] >= 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / float64(len(frame)-1)
+}
+
+// levelDB is the RMS level of a frame in dBFS
+func levelDB(frame []float32) float64 {
+	var sum float64
+	for _, sample := range frame {
+		sum += float64(sample) * float64(sample)
+	}
+	rms := math.Sqrt(sum / float64(len(frame)))
+	return 20 * math.Log10(rms+1e-10)
+}
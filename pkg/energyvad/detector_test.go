@@ -0,0 +1,133 @@
+package energyvad
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleRate = 16000
+
+func tone(seconds float64, amplitude float32) []float32 {
+	samples := make([]float32, int(seconds*sampleRate))
+	for i := range samples {
+		samples[i] = amplitude * float32(math.Sin(2*math.Pi*220*float64(i)/sampleRate))
+	}
+	return samples
+}
+
+func noise(seconds float64, amplitude float32) []float32 {
+	samples := make([]float32, int(seconds*sampleRate))
+	state := uint32(1)
+	for i := range samples {
+		state = state*1664525 + 1013904223
+		samples[i] = amplitude * (float32(state>>8)/float32(1<<24)*2 - 1)
+	}
+	return samples
+}
+
+func newDetector() *Detector {
+	return New(Options{
+		SampleRate:         sampleRate,
+		Threshold:          0.5,
+		MinSilenceDuration: 0.3,
+		MinSpeechDuration:  0.1,
+		MaxSpeechDuration:  10,
+	})
+}
+
+func TestDetectorFindsSpeechBetweenSilence(t *testing.T) {
+	d := newDetector()
+	d.AcceptWaveform(noise(1, 0.001))
+	assert.False(t, d.IsSpeech())
+
+	d.AcceptWaveform(tone(1, 0.3))
+	assert.True(t, d.IsSpeech())
+	assert.True(t, d.IsEmpty())
+
+	d.AcceptWaveform(noise(1, 0.001))
+	require.False(t, d.IsEmpty())
+	assert.False(t, d.IsSpeech())
+
+	segment := d.Front()
+	d.Pop()
+	assert.True(t, d.IsEmpty())
+	assert.InDelta(t, sampleRate, segment.Start, 3*512+512, "starts with the tone, less the prefix padding")
+	assert.InDelta(t, 1.3*sampleRate, len(segment.Samples), 4*512+512, "tone plus padding and trailing silence")
+}
+
+func TestDetectorDropsShortBursts(t *testing.T) {
+	d := newDetector()
+	d.AcceptWaveform(noise(1, 0.001))
+	d.AcceptWaveform(tone(0.03, 0.3))
+	d.AcceptWaveform(noise(1, 0.001))
+	assert.True(t, d.IsEmpty())
+}
+
+func TestDetectorIgnoresSteadyNoise(t *testing.T) {
+	d := newDetector()
+	d.AcceptWaveform(noise(0.5, 0.001))
+	d.AcceptWaveform(noise(8, 0.05))
+	d.Flush()
+	require.LessOrEqual(t, len(d.segments), 1, "only the onset of the louder background may register")
+	if segment := d.Front(); segment != nil {
+		assert.Less(t, len(segment.Samples), 5*sampleRate)
+	}
+}
+
+func TestDetectorNeedsMoreLevelForNoiseLikeFrames(t *testing.T) {
+	// A tone and a hiss of the same level, 16dB above the background: above the
+	// margin of voiced frames, below the one of noise-like frames
+	hiss := noise(1, 0.0063)
+	hum := tone(1, 0.0051)
+	assert.InDelta(t, levelDB(hiss), levelDB(hum), 0.5)
+	assert.Greater(t, zeroCrossingRate(hiss), noiseZeroCrossingRate)
+	assert.Less(t, zeroCrossingRate(hum), 0.05)
+
+	d := newDetector()
+	d.AcceptWaveform(noise(1, 0.001))
+	d.AcceptWaveform(hiss)
+	assert.False(t, d.IsSpeech(), "hiss is not speech")
+
+	d = newDetector()
+	d.AcceptWaveform(noise(1, 0.001))
+	d.AcceptWaveform(hum)
+	assert.True(t, d.IsSpeech())
+}
+
+func TestDetectorSplitsLongSpeech(t *testing.T) {
+	d := New(Options{
+		SampleRate:         sampleRate,
+		MinSilenceDuration: 0.3,
+		MinSpeechDuration:  0.1,
+		MaxSpeechDuration:  2,
+	})
+	d.AcceptWaveform(noise(0.5, 0.001))
+	d.AcceptWaveform(tone(5, 0.3))
+	d.Flush()
+
+	count := 0
+	for !d.IsEmpty() {
+		assert.LessOrEqual(t, len(d.Front().Samples), 2*sampleRate+512)
+		d.Pop()
+		count++
+	}
+	assert.GreaterOrEqual(t, count, 3)
+}
+
+func TestDetectorFlushAndReset(t *testing.T) {
+	d := newDetector()
+	d.AcceptWaveform(noise(0.5, 0.001))
+	d.AcceptWaveform(tone(0.5, 0.3))
+	assert.True(t, d.IsEmpty())
+
+	d.Flush()
+	require.False(t, d.IsEmpty())
+	assert.False(t, d.IsSpeech())
+
+	d.Reset()
+	assert.True(t, d.IsEmpty())
+	assert.Nil(t, d.Front())
+}
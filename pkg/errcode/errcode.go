@@ -0,0 +1,70 @@
+// Package errcode defines the error categories of the realtime API. Error events and
+// transcription failures carry a category in error.category next to their specific
+// error.code, so that clients can handle a whole class of errors, e.g. retry when the
+// ASR engine is unavailable, without knowing every code the server may send.
+package errcode
+
+// Code is an error category
+type Code string
+
+const (
+	// InvalidRequest is a client event the server cannot act on
+	InvalidRequest Code = "invalid_request"
+	// InvalidAudioFormat is input audio the server cannot decode or does not support
+	InvalidAudioFormat Code = "invalid_audio_format"
+	// SessionLimit is a session refused over the server's or the client's session limit
+	SessionLimit Code = "session_limit"
+	// ASRUnavailable is a recognition the ASR engine failed or was too busy to run
+	ASRUnavailable Code = "asr_unavailable"
+	// RateLimited is audio or events dropped because the client exceeded a rate limit
+	// or did not read events fast enough
+	RateLimited Code = "rate_limited"
+	// Internal is a failure of the server itself
+	Internal Code = "internal"
+)
+
+// categories maps the specific error codes of the server to their category
+var categories = map[string]Code{
+	"binary_audio_disabled":    InvalidRequest,
+	"invalid_delta_mode":       InvalidRequest,
+	"invalid_gain_control":     InvalidRequest,
+	"invalid_hints":            InvalidRequest,
+	"invalid_noise_reduction":  InvalidRequest,
+	"message_processing_error": InvalidRequest,
+	"session_not_resumable":    InvalidRequest,
+	"unknown_pipeline":         InvalidRequest,
+
+	"checksum_mismatch":        InvalidAudioFormat,
+	"invalid_audio_frame":      InvalidAudioFormat,
+	"invalid_input_encoding":   InvalidAudioFormat,
+	"unsupported_audio_format": InvalidAudioFormat,
+
+	"max_sessions_reached":   SessionLimit,
+	"session_quota_exceeded": SessionLimit,
+
+	"asr_overloaded":    ASRUnavailable,
+	"recognition_error": ASRUnavailable,
+
+	"buffer_overflow":     RateLimited,
+	"rate_limit_exceeded": RateLimited,
+
+	"audio_conversion_error": Internal,
+}
+
+// Of returns the category of a specific error code, Internal for codes it does not know
+func Of(code string) Code {
+	if category, ok := categories[code]; ok {
+		return category
+	}
+	return Internal
+}
+
+// Retryable reports whether a request that failed with an error of the category may
+// succeed when it is repeated later unchanged
+func (c Code) Retryable() bool {
+	switch c {
+	case SessionLimit, ASRUnavailable, RateLimited:
+		return true
+	}
+	return false
+}
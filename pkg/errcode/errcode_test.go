@@ -0,0 +1,23 @@
+package errcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOf(t *testing.T) {
+	assert.Equal(t, InvalidAudioFormat, Of("unsupported_audio_format"))
+	assert.Equal(t, SessionLimit, Of("session_quota_exceeded"))
+	assert.Equal(t, ASRUnavailable, Of("asr_overloaded"))
+	assert.Equal(t, RateLimited, Of("rate_limit_exceeded"))
+	assert.Equal(t, InvalidRequest, Of("unknown_pipeline"))
+	assert.Equal(t, Internal, Of("no_such_code"))
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, ASRUnavailable.Retryable())
+	assert.True(t, RateLimited.Retryable())
+	assert.False(t, InvalidAudioFormat.Retryable())
+	assert.False(t, Internal.Retryable())
+}
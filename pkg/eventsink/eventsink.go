@@ -0,0 +1,78 @@
+// Package eventsink publishes messages to a message broker, Kafka or NATS, with
+// minimal clients implementing only publishing
+package eventsink
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// Publisher writes messages to the topics of a broker. Publishers are safe for
+// concurrent use and reconnect after errors on the next Publish.
+type Publisher interface {
+	// Publish writes value to topic. The key selects the Kafka partition, so messages
+	// with the same key keep their order; NATS ignores it.
+	Publish(ctx context.Context, topic string, key, value []byte) error
+	Close() error
+}
+
+// Options configure the publisher created by New
+type Options struct {
+	Type     string   // "kafka" or "nats"
+	Brokers  []string // host:port of the Kafka brokers or NATS servers, tried in order
+	ClientID string
+
+	// Kafka acknowledgements: 1 waits for the partition leader, -1 for all in-sync
+	// replicas; 0 means 1
+	Acks int
+
+	// NATS credentials, a user and password or a token
+	Username string
+	Password string
+	Token    string
+
+	Timeout time.Duration // per request, 0 means 5s
+}
+
+// New creates the publisher of opts.Type. It connects on the first Publish.
+func New(opts Options) (Publisher, error) {
+	if len(opts.Brokers) == 0 {
+		return nil, fmt.Errorf("eventsink: no brokers configured")
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	switch opts.Type {
+	case "kafka":
+		producer := NewKafkaProducer(opts.Brokers, opts.ClientID)
+		producer.Timeout = timeout
+		if opts.Acks == -1 {
+			producer.Acks = -1
+		}
+		return producer, nil
+	case "nats":
+		publisher := NewNATSPublisher(opts.Brokers, opts.ClientID)
+		publisher.Username = opts.Username
+		publisher.Password = opts.Password
+		publisher.Token = opts.Token
+		publisher.Timeout = timeout
+		return publisher, nil
+	default:
+		return nil, fmt.Errorf("eventsink: unknown type %q", opts.Type)
+	}
+}
+
+// requestDeadline returns the deadline of a request: timeout from now, or the deadline
+// of ctx when it is earlier
+func requestDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return deadline
+}
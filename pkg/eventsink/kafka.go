@@ -0,0 +1,473 @@
+package eventsink
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kafka API keys and the versions used
+const (
+	kafkaAPIProduce  = 0
+	kafkaAPIMetadata = 3
+
+	kafkaProduceVersion  = 3 // the first version carrying record batches
+	kafkaMetadataVersion = 1
+)
+
+// Kafka error codes after which the partition leaders are looked up again
+const (
+	kafkaErrUnknownTopicOrPartition = 3
+	kafkaErrLeaderNotAvailable      = 5
+	kafkaErrNotLeaderForPartition   = 6
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// KafkaError is an error code returned by a broker
+type KafkaError struct {
+	Code  int16
+	Topic string
+}
+
+func (e KafkaError) Error() string {
+	return "kafka: error code " + strconv.Itoa(int(e.Code)) + " for topic " + e.Topic
+}
+
+// retriable reports whether the error is fixed by looking up the leaders again
+func (e KafkaError) retriable() bool {
+	switch e.Code {
+	case kafkaErrUnknownTopicOrPartition, kafkaErrLeaderNotAvailable, kafkaErrNotLeaderForPartition:
+		return true
+	}
+	return false
+}
+
+// KafkaProducer is a minimal Kafka producer. It looks up the partition leaders of a
+// topic with Metadata v1 and writes each message as an uncompressed record batch with
+// Produce v3, over one connection per broker. Messages with a key go to the partition
+// the Java client would pick, messages without one are spread round-robin.
+type KafkaProducer struct {
+	Brokers  []string // bootstrap brokers, host:port
+	ClientID string
+	Acks     int16 // 1 waits for the partition leader, -1 for all in-sync replicas
+	Timeout  time.Duration
+
+	mutex         sync.Mutex
+	conns         map[int32]*kafkaConn // by node ID
+	nodes         map[int32]string     // address of each node ID
+	leaders       map[string][]int32   // leader node ID of each partition of a topic
+	correlationID int32
+	next          uint32 // partition of the next message without a key
+}
+
+type kafkaConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewKafkaProducer creates a producer bootstrapping from brokers
+func NewKafkaProducer(brokers []string, clientID string) *KafkaProducer {
+	return &KafkaProducer{
+		Brokers:  brokers,
+		ClientID: clientID,
+		Acks:     1,
+		Timeout:  defaultTimeout,
+		conns:    make(map[int32]*kafkaConn),
+		nodes:    make(map[int32]string),
+		leaders:  make(map[string][]int32),
+	}
+}
+
+func (p *KafkaProducer) Publish(ctx context.Context, topic string, key, value []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	err := p.produce(ctx, topic, key, value)
+	var kafkaErr KafkaError
+	if err != nil && (!errors.As(err, &kafkaErr) || kafkaErr.retriable()) {
+		// The leader moved or the connection failed: look the leaders up again
+		delete(p.leaders, topic)
+		err = p.produce(ctx, topic, key, value)
+	}
+	return err
+}
+
+// Close closes the connections to the brokers
+func (p *KafkaProducer) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var errs []error
+	for id, conn := range p.conns {
+		errs = append(errs, conn.conn.Close())
+		delete(p.conns, id)
+	}
+	return errors.Join(errs...)
+}
+
+func (p *KafkaProducer) produce(ctx context.Context, topic string, key, value []byte) error {
+	leaders, err := p.partitionLeaders(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	var partition int32
+	if key != nil {
+		partition = int32(uint32(murmur2(key)&0x7fffffff) % uint32(len(leaders)))
+	} else {
+		partition = int32(p.next % uint32(len(leaders)))
+		p.next++
+	}
+
+	var body kafkaEncoder
+	body.int16(-1) // no transactional ID
+	body.int16(p.Acks)
+	body.int32(int32(p.Timeout.Milliseconds()))
+	body.int32(1) // topics
+	body.string(topic)
+	body.int32(1) // partitions
+	body.int32(partition)
+	body.bytes(recordBatch(key, value, time.Now()))
+
+	response, err := p.request(ctx, leaders[partition], kafkaAPIProduce, kafkaProduceVersion, body.buf)
+	if err != nil {
+		return err
+	}
+
+	d := kafkaDecoder{buf: response}
+	for topics := d.int32(); topics > 0; topics-- {
+		name := d.string()
+		for partitions := d.int32(); partitions > 0; partitions-- {
+			d.int32() // partition
+			code := d.int16()
+			d.int64() // base offset
+			d.int64() // log append time
+			if d.err == nil && code != 0 {
+				return KafkaError{Code: code, Topic: name}
+			}
+		}
+	}
+	return d.err
+}
+
+// partitionLeaders returns the leader of every partition of topic, from the metadata
+// of the first broker answering
+func (p *KafkaProducer) partitionLeaders(ctx context.Context, topic string) ([]int32, error) {
+	if leaders, ok := p.leaders[topic]; ok {
+		return leaders, nil
+	}
+
+	var body kafkaEncoder
+	body.int32(1)
+	body.string(topic)
+
+	var errs []error
+	for i, addr := range p.Brokers {
+		// Bootstrap brokers are known by their address only, they get negative IDs
+		id := int32(-1 - i)
+		p.nodes[id] = addr
+		response, err := p.request(ctx, id, kafkaAPIMetadata, kafkaMetadataVersion, body.buf)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		leaders, err := p.readMetadata(response, topic)
+		if err != nil {
+			return nil, err
+		}
+		p.leaders[topic] = leaders
+		return leaders, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// readMetadata notes the brokers of a metadata response and returns the leaders of
+// the partitions of topic
+func (p *KafkaProducer) readMetadata(response []byte, topic string) ([]int32, error) {
+	d := kafkaDecoder{buf: response}
+	for brokers := d.int32(); brokers > 0 && d.err == nil; brokers-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		p.nodes[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller ID
+
+	var leaders []int32
+	for topics := d.int32(); topics > 0 && d.err == nil; topics-- {
+		code := d.int16()
+		name := d.string()
+		d.int8() // internal
+		partitions := d.int32()
+		if name == topic {
+			if code != 0 {
+				return nil, KafkaError{Code: code, Topic: topic}
+			}
+			leaders = make([]int32, partitions)
+		}
+		for ; partitions > 0 && d.err == nil; partitions-- {
+			d.int16() // partition error code
+			index := d.int32()
+			leader := d.int32()
+			d.skipArray(4) // replicas
+			d.skipArray(4) // in-sync replicas
+			if name == topic && index >= 0 && int(index) < len(leaders) {
+				leaders[index] = leader
+			}
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(leaders) == 0 {
+		return nil, KafkaError{Code: kafkaErrUnknownTopicOrPartition, Topic: topic}
+	}
+	for _, leader := range leaders {
+		if leader < 0 {
+			return nil, KafkaError{Code: kafkaErrLeaderNotAvailable, Topic: topic}
+		}
+	}
+	return leaders, nil
+}
+
+// request sends a request to the node and returns the response after its header. The
+// connection is closed after any error.
+func (p *KafkaProducer) request(ctx context.Context, node int32, apiKey, version int16, body []byte) ([]byte, error) {
+	conn, err := p.connection(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	response, err := p.roundTrip(ctx, conn, apiKey, version, body)
+	if err != nil {
+		conn.conn.Close()
+		delete(p.conns, node)
+		return nil, err
+	}
+	return response, nil
+}
+
+func (p *KafkaProducer) connection(ctx context.Context, node int32) (*kafkaConn, error) {
+	if conn, ok := p.conns[node]; ok {
+		return conn, nil
+	}
+	addr, ok := p.nodes[node]
+	if !ok {
+		return nil, fmt.Errorf("kafka: unknown broker %d", node)
+	}
+
+	dialer := net.Dialer{Timeout: p.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("kafka dial %s failed: %v", addr, err)
+	}
+	c := &kafkaConn{conn: conn, reader: bufio.NewReader(conn)}
+	p.conns[node] = c
+	return c, nil
+}
+
+func (p *KafkaProducer) roundTrip(ctx context.Context, conn *kafkaConn, apiKey, version int16, body []byte) ([]byte, error) {
+	if err := conn.conn.SetDeadline(requestDeadline(ctx, p.Timeout)); err != nil {
+		return nil, err
+	}
+
+	p.correlationID++
+	var request kafkaEncoder
+	request.int32(0) // size, set below
+	request.int16(apiKey)
+	request.int16(version)
+	request.int32(p.correlationID)
+	request.string(p.ClientID)
+	request.buf = append(request.buf, body...)
+	binary.BigEndian.PutUint32(request.buf, uint32(len(request.buf)-4))
+	if _, err := conn.conn.Write(request.buf); err != nil {
+		return nil, err
+	}
+
+	var header [8]byte
+	if _, err := io.ReadFull(conn.reader, header[:]); err != nil {
+		return nil, err
+	}
+	size := int32(binary.BigEndian.Uint32(header[:4]))
+	if correlationID := int32(binary.BigEndian.Uint32(header[4:])); correlationID != p.correlationID {
+		return nil, fmt.Errorf("kafka: response %d to request %d", correlationID, p.correlationID)
+	}
+	if size < 4 {
+		return nil, fmt.Errorf("kafka: short response of %d bytes", size)
+	}
+	response := make([]byte, size-4)
+	if _, err := io.ReadFull(conn.reader, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// recordBatch encodes a batch of one record in the v2 message format
+func recordBatch(key, value []byte, at time.Time) []byte {
+	var record []byte
+	record = append(record, 0)              // attributes
+	record = binary.AppendVarint(record, 0) // timestamp delta
+	record = binary.AppendVarint(record, 0) // offset delta
+	if key == nil {
+		record = binary.AppendVarint(record, -1)
+	} else {
+		record = binary.AppendVarint(record, int64(len(key)))
+		record = append(record, key...)
+	}
+	record = binary.AppendVarint(record, int64(len(value)))
+	record = append(record, value...)
+	record = binary.AppendVarint(record, 0) // headers
+
+	// The CRC covers everything from the attributes on
+	var checked kafkaEncoder
+	checked.int16(0) // attributes: no compression
+	checked.int32(0) // last offset delta
+	checked.int64(at.UnixMilli())
+	checked.int64(at.UnixMilli())
+	checked.int64(-1) // producer ID
+	checked.int16(-1) // producer epoch
+	checked.int32(-1) // base sequence
+	checked.int32(1)  // records
+	checked.buf = binary.AppendVarint(checked.buf, int64(len(record)))
+	checked.buf = append(checked.buf, record...)
+
+	var batch kafkaEncoder
+	batch.int64(0) // base offset
+	batch.int32(int32(4 + 1 + 4 + len(checked.buf)))
+	batch.int32(-1) // partition leader epoch
+	batch.int8(2)   // magic
+	batch.int32(int32(crc32.Checksum(checked.buf, castagnoli)))
+	batch.buf = append(batch.buf, checked.buf...)
+	return batch.buf
+}
+
+// murmur2 is the hash the Java client partitions keys with
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	length := len(data)
+	h := seed ^ uint32(length)
+	tail := length &^ 3
+	for i := 0; i < tail; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	switch length & 3 {
+	case 3:
+		h ^= uint32(data[tail+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[tail+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[tail])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// kafkaEncoder appends the big-endian primitives of the Kafka protocol
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int8(v int8)   { e.buf = append(e.buf, byte(v)) }
+func (e *kafkaEncoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *kafkaEncoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *kafkaEncoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// kafkaDecoder reads the primitives of a response, keeping the first error and
+// returning zero values after it
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.err = fmt.Errorf("kafka: truncated response")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string reads a string, a null string reads as empty
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+// skipArray skips an array of elements of size bytes
+func (d *kafkaDecoder) skipArray(size int) {
+	if n := d.int32(); n > 0 {
+		d.take(int(n) * size)
+	}
+}
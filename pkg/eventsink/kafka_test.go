@@ -0,0 +1,200 @@
+package eventsink
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMurmur2MatchesJavaClient(t *testing.T) {
+	// Vectors of the Java client's Utils.murmur2
+	cases := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for key, want := range cases {
+		assert.Equal(t, want, murmur2([]byte(key)), key)
+	}
+}
+
+func TestRecordBatch(t *testing.T) {
+	at := time.UnixMilli(1700000000000)
+	batch := recordBatch([]byte("sess_1"), []byte(`{"type":"x"}`), at)
+
+	d := kafkaDecoder{buf: batch}
+	assert.Equal(t, int64(0), d.int64())
+	assert.Equal(t, int(d.int32()), len(batch)-12)
+	assert.Equal(t, int32(-1), d.int32())
+	assert.Equal(t, int8(2), d.int8())
+	crc := uint32(d.int32())
+	assert.Equal(t, crc32.Checksum(d.buf, castagnoli), crc)
+
+	assert.Equal(t, int16(0), d.int16())
+	assert.Equal(t, int32(0), d.int32())
+	assert.Equal(t, at.UnixMilli(), d.int64())
+	assert.Equal(t, at.UnixMilli(), d.int64())
+	d.take(8 + 2 + 4)
+	assert.Equal(t, int32(1), d.int32())
+	require.NoError(t, d.err)
+
+	length, n := binary.Varint(d.buf)
+	record := d.buf[n:]
+	require.Equal(t, int(length), len(record))
+	record = record[1:] // attributes
+	for range 2 {
+		_, n = binary.Varint(record) // timestamp and offset deltas
+		record = record[n:]
+	}
+	keyLength, n := binary.Varint(record)
+	record = record[n:]
+	assert.Equal(t, "sess_1", string(record[:keyLength]))
+	record = record[keyLength:]
+	valueLength, n := binary.Varint(record)
+	record = record[n:]
+	assert.Equal(t, `{"type":"x"}`, string(record[:valueLength]))
+}
+
+// fakeBroker answers Metadata with itself as the leader of partitions of the topic
+// and records the partition of every Produce
+type fakeBroker struct {
+	listener   net.Listener
+	partitions int32
+	produceErr int16
+	produced   chan int32
+}
+
+func newFakeBroker(t *testing.T, partitions int32) *fakeBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	b := &fakeBroker{listener: listener, partitions: partitions, produced: make(chan int32, 16)}
+	t.Cleanup(func() { listener.Close() })
+	go b.serve()
+	return b
+}
+
+func (b *fakeBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *fakeBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(reader, size[:]); err != nil {
+			return
+		}
+		request := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(reader, request); err != nil {
+			return
+		}
+		d := kafkaDecoder{buf: request}
+		apiKey := d.int16()
+		d.int16()
+		correlationID := d.int32()
+		d.string() // client ID
+
+		var response kafkaEncoder
+		response.int32(0)
+		response.int32(correlationID)
+		switch apiKey {
+		case kafkaAPIMetadata:
+			d.int32()
+			topic := d.string()
+			host, port, _ := net.SplitHostPort(b.listener.Addr().String())
+			portNumber, _ := strconv.Atoi(port)
+			response.int32(1)
+			response.int32(7)
+			response.string(host)
+			response.int32(int32(portNumber))
+			response.int16(-1)
+			response.int32(7)
+			response.int32(1)
+			response.int16(0)
+			response.string(topic)
+			response.int8(0)
+			response.int32(b.partitions)
+			for i := range b.partitions {
+				response.int16(0)
+				response.int32(i)
+				response.int32(7)
+				response.int32(1)
+				response.int32(7)
+				response.int32(1)
+				response.int32(7)
+			}
+		case kafkaAPIProduce:
+			d.int16()
+			d.int16()
+			d.int32()
+			d.int32()
+			topic := d.string()
+			d.int32()
+			partition := d.int32()
+			b.produced <- partition
+			response.int32(1)
+			response.string(topic)
+			response.int32(1)
+			response.int32(partition)
+			response.int16(b.produceErr)
+			response.int64(0)
+			response.int64(-1)
+			response.int32(0)
+		}
+		binary.BigEndian.PutUint32(response.buf, uint32(len(response.buf)-4))
+		if _, err := conn.Write(response.buf); err != nil {
+			return
+		}
+	}
+}
+
+func TestKafkaProducerPublish(t *testing.T) {
+	broker := newFakeBroker(t, 4)
+	producer := NewKafkaProducer([]string{broker.listener.Addr().String()}, "test")
+	defer producer.Close()
+	ctx := context.Background()
+
+	key := []byte("sess_1")
+	want := int32(uint32(murmur2(key)&0x7fffffff) % 4)
+	for range 2 {
+		require.NoError(t, producer.Publish(ctx, "stt.session.created", key, []byte("{}")))
+		assert.Equal(t, want, <-broker.produced)
+	}
+
+	// Messages without a key are spread over the partitions
+	require.NoError(t, producer.Publish(ctx, "stt.session.created", nil, []byte("{}")))
+	require.NoError(t, producer.Publish(ctx, "stt.session.created", nil, []byte("{}")))
+	assert.NotEqual(t, <-broker.produced, <-broker.produced)
+}
+
+func TestKafkaProducerError(t *testing.T) {
+	broker := newFakeBroker(t, 1)
+	broker.produceErr = 10 // message too large
+	producer := NewKafkaProducer([]string{broker.listener.Addr().String()}, "test")
+	defer producer.Close()
+
+	err := producer.Publish(context.Background(), "events", nil, []byte("{}"))
+	var kafkaErr KafkaError
+	require.ErrorAs(t, err, &kafkaErr)
+	assert.Equal(t, int16(10), kafkaErr.Code)
+	assert.Len(t, broker.produced, 1, "not retried")
+}
@@ -0,0 +1,240 @@
+package eventsink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSPublisher is a minimal NATS client speaking the text protocol over a single
+// connection. It implements only PUB, answering the PINGs of the server while idle.
+// Messages are fire-and-forget as in core NATS: a publish succeeds once written.
+type NATSPublisher struct {
+	Servers  []string // host:port, optionally prefixed with nats://
+	Name     string
+	Username string
+	Password string
+	Token    string
+	Timeout  time.Duration
+
+	mutex sync.Mutex
+	conn  *natsConn
+}
+
+// natsConn is one connection; its reader answers PINGs and notes the error ending it
+type natsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	mutex  sync.Mutex // guards writes and err
+	writer *bufio.Writer
+	err    error
+}
+
+// NewNATSPublisher creates a publisher for the NATS servers, name identifies the
+// connection in the monitoring of the server
+func NewNATSPublisher(servers []string, name string) *NATSPublisher {
+	return &NATSPublisher{Servers: servers, Name: name, Timeout: defaultTimeout}
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, subject string, key, value []byte) error {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("nats: invalid subject %q", subject)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.conn != nil && p.conn.failed() != nil {
+		p.conn.conn.Close()
+		p.conn = nil
+	}
+	if p.conn == nil {
+		conn, err := p.connect(ctx)
+		if err != nil {
+			return err
+		}
+		p.conn = conn
+	}
+
+	if err := p.conn.publish(requestDeadline(ctx, p.Timeout), subject, value); err != nil {
+		p.conn.conn.Close()
+		p.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection
+func (p *NATSPublisher) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// connect connects to the first server accepting the connection
+func (p *NATSPublisher) connect(ctx context.Context) (*natsConn, error) {
+	var errs []error
+	for _, server := range p.Servers {
+		conn, err := p.dial(ctx, strings.TrimPrefix(server, "nats://"))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// dial connects to addr, sends CONNECT and waits for the PONG confirming it
+func (p *NATSPublisher) dial(ctx context.Context, addr string) (*natsConn, error) {
+	dialer := net.Dialer{Timeout: p.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("nats dial %s failed: %v", addr, err)
+	}
+	if err := conn.SetDeadline(requestDeadline(ctx, p.Timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &natsConn{conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+	if err := p.handshake(c); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("nats connect %s failed: %v", addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	go c.read()
+	return c, nil
+}
+
+func (p *NATSPublisher) handshake(c *natsConn) error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"lang":     "go",
+		"protocol": 1,
+	}
+	if p.Name != "" {
+		options["name"] = p.Name
+	}
+	if p.Username != "" {
+		options["user"] = p.Username
+		options["pass"] = p.Password
+	}
+	if p.Token != "" {
+		options["auth_token"] = p.Token
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	c.writer.WriteString("CONNECT ")
+	c.writer.Write(data)
+	c.writer.WriteString("\r\nPING\r\n")
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+
+	// An authorization failure answers with -ERR instead of the PONG
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (c *natsConn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// read answers the PINGs of the server until the connection fails
+func (c *natsConn) read() {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		switch {
+		case line == "PING":
+			c.mutex.Lock()
+			c.conn.SetWriteDeadline(time.Now().Add(defaultTimeout))
+			c.writer.WriteString("PONG\r\n")
+			err = c.writer.Flush()
+			c.mutex.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			err = fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		if err != nil {
+			c.fail(err)
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+func (c *natsConn) fail(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *natsConn) failed() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err
+}
+
+func (c *natsConn) publish(deadline time.Time, subject string, value []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	c.writer.WriteString("PUB ")
+	c.writer.WriteString(subject)
+	c.writer.WriteByte(' ')
+	c.writer.WriteString(strconv.Itoa(len(value)))
+	c.writer.WriteString("\r\n")
+	c.writer.Write(value)
+	c.writer.WriteString("\r\n")
+	return c.writer.Flush()
+}
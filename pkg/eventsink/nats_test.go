@@ -0,0 +1,105 @@
+package eventsink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type natsMessage struct {
+	subject string
+	payload string
+}
+
+// fakeNATSServer accepts the token "secret" and records the messages published
+func fakeNATSServer(t *testing.T) (string, chan natsMessage) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan natsMessage, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				conn.Write([]byte("INFO {\"server_id\":\"test\",\"auth_required\":true}\r\n"))
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					if len(fields) == 0 {
+						continue
+					}
+					switch fields[0] {
+					case "CONNECT":
+						var options map[string]interface{}
+						json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &options)
+						if options["auth_token"] != "secret" {
+							conn.Write([]byte("-ERR 'Authorization Violation'\r\n"))
+							return
+						}
+						// Check that PINGs of the server are answered
+						conn.Write([]byte("PING\r\n"))
+					case "PING":
+						conn.Write([]byte("PONG\r\n"))
+					case "PUB":
+						size, _ := strconv.Atoi(fields[2])
+						payload := make([]byte, size+2)
+						if _, err := io.ReadFull(reader, payload); err != nil {
+							return
+						}
+						messages <- natsMessage{subject: fields[1], payload: string(payload[:size])}
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), messages
+}
+
+func TestNATSPublisher(t *testing.T) {
+	addr, messages := fakeNATSServer(t)
+	publisher := NewNATSPublisher([]string{"nats://" + addr}, "test")
+	publisher.Token = "secret"
+	defer publisher.Close()
+	ctx := context.Background()
+
+	require.NoError(t, publisher.Publish(ctx, "stt.session.created", []byte("sess_1"), []byte(`{"a":1}`)))
+	require.NoError(t, publisher.Publish(ctx, "stt.session.ended", nil, []byte("")))
+	assert.Equal(t, natsMessage{"stt.session.created", `{"a":1}`}, <-messages)
+	assert.Equal(t, natsMessage{"stt.session.ended", ""}, <-messages)
+
+	assert.Error(t, publisher.Publish(ctx, "bad subject", nil, nil))
+}
+
+func TestNATSPublisherAuthorization(t *testing.T) {
+	addr, _ := fakeNATSServer(t)
+	publisher := NewNATSPublisher([]string{addr}, "test")
+	defer publisher.Close()
+
+	err := publisher.Publish(context.Background(), "stt.events", nil, []byte("{}"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Authorization Violation")
+}
+
+func TestNewRejectsUnknownType(t *testing.T) {
+	_, err := New(Options{Type: "pulsar", Brokers: []string{"localhost:6650"}})
+	assert.Error(t, err)
+	_, err = New(Options{Type: "kafka"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,234 @@
+// Package experiment assigns sessions to the variants of an A/B experiment and
+// compares the recognition latency and accuracy the variants achieve.
+package experiment
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxLatencySamples bounds the latencies kept per variant for percentiles, the oldest
+// are overwritten
+const maxLatencySamples = 10000
+
+// Variant is an arm of an experiment receiving Weight percent of the sessions
+type Variant struct {
+	Name   string
+	Weight float64
+}
+
+// Assign picks the variant of a key, the same one every time for the same experiment
+// and key. It returns -1 for keys falling into the share of sessions not covered by
+// the weights, which stay out of the experiment.
+func Assign(experiment, key string, variants []Variant) int {
+	h := fnv.New64a()
+	h.Write([]byte(experiment))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	bucket := float64(h.Sum64()%10000) / 100 // [0, 100) in steps of 0.01
+
+	var upper float64
+	for i, variant := range variants {
+		upper += variant.Weight
+		if bucket < upper {
+			return i
+		}
+	}
+	return -1
+}
+
+// Latency summarizes recognition latencies in milliseconds
+type Latency struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+}
+
+// Comparison is a variant relative to the control, the first variant
+type Comparison struct {
+	LatencyP50Pct   float64 `json:"latency_p50_pct"`     // change of the median latency in percent
+	ErrorRateDelta  float64 `json:"error_rate_delta"`    // difference of the failed item ratios
+	ConfidenceDelta float64 `json:"confidence_delta"`    // difference of the mean word confidences
+	QualityDelta    float64 `json:"quality_score_delta"` // difference of the mean audio quality scores
+}
+
+// Summary is the outcome of a variant so far
+type Summary struct {
+	Name           string      `json:"name"`
+	Sessions       int64       `json:"sessions"`
+	AudioSeconds   float64     `json:"audio_seconds"`
+	Items          int64       `json:"items"`
+	FailedItems    int64       `json:"failed_items"`
+	ErrorRate      float64     `json:"error_rate"`
+	LatencyMs      Latency     `json:"latency_ms"`
+	MeanConfidence float64     `json:"mean_confidence"` // -1 without word confidences
+	MeanQuality    float64     `json:"mean_quality_score"`
+	VsControl      *Comparison `json:"vs_control,omitempty"`
+}
+
+type variantStats struct {
+	sessions        int64
+	audio           time.Duration
+	items           int64
+	failed          int64
+	latencies       []float64
+	next            int // ring position once latencies is full
+	latencySum      float64
+	latencyCount    int64
+	confidenceSum   float64
+	confidenceCount int64
+	qualitySum      float64
+	qualityCount    int64
+}
+
+// Recorder accumulates the outcomes of the variants, safe for concurrent use
+type Recorder struct {
+	mutex    sync.Mutex
+	names    []string
+	variants map[string]*variantStats
+}
+
+// NewRecorder creates a recorder for the named variants, the first being the control
+func NewRecorder(names []string) *Recorder {
+	r := &Recorder{names: names, variants: make(map[string]*variantStats, len(names))}
+	for _, name := range names {
+		r.variants[name] = &variantStats{}
+	}
+	return r
+}
+
+// RecordSession counts an ended session of a variant and its audio
+func (r *Recorder) RecordSession(variant string, audio time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if stats, ok := r.variants[variant]; ok {
+		stats.sessions++
+		stats.audio += audio
+	}
+}
+
+// RecordItem counts a recognized conversation item of a variant. A negative
+// confidence or quality score means none was measured.
+func (r *Recorder) RecordItem(variant string, latency time.Duration, failed bool, confidence, quality float64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	stats, ok := r.variants[variant]
+	if !ok {
+		return
+	}
+
+	stats.items++
+	if failed {
+		stats.failed++
+		return
+	}
+
+	ms := float64(latency) / float64(time.Millisecond)
+	stats.latencySum += ms
+	stats.latencyCount++
+	if len(stats.latencies) < maxLatencySamples {
+		stats.latencies = append(stats.latencies, ms)
+	} else {
+		stats.latencies[stats.next] = ms
+		stats.next = (stats.next + 1) % maxLatencySamples
+	}
+	if confidence >= 0 {
+		stats.confidenceSum += confidence
+		stats.confidenceCount++
+	}
+	if quality >= 0 {
+		stats.qualitySum += quality
+		stats.qualityCount++
+	}
+}
+
+// Summaries returns the outcome of every variant in configuration order, each but the
+// control compared to the control
+func (r *Recorder) Summaries() []Summary {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	summaries := make([]Summary, 0, len(r.names))
+	for _, name := range r.names {
+		summaries = append(summaries, r.variants[name].summary(name))
+	}
+	for i := 1; i < len(summaries); i++ {
+		summaries[i].VsControl = compare(summaries[0], summaries[i])
+	}
+	return summaries
+}
+
+// Reset discards the recorded outcomes
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, name := range r.names {
+		r.variants[name] = &variantStats{}
+	}
+}
+
+func (s *variantStats) summary(name string) Summary {
+	summary := Summary{
+		Name:           name,
+		Sessions:       s.sessions,
+		AudioSeconds:   round(s.audio.Seconds(), 1),
+		Items:          s.items,
+		FailedItems:    s.failed,
+		MeanConfidence: -1,
+		MeanQuality:    -1,
+	}
+	if s.items > 0 {
+		summary.ErrorRate = round(float64(s.failed)/float64(s.items), 4)
+	}
+	if s.latencyCount > 0 {
+		sorted := append([]float64(nil), s.latencies...)
+		sort.Float64s(sorted)
+		summary.LatencyMs = Latency{
+			Mean: round(s.latencySum/float64(s.latencyCount), 1),
+			P50:  round(percentile(sorted, 0.50), 1),
+			P95:  round(percentile(sorted, 0.95), 1),
+		}
+	}
+	if s.confidenceCount > 0 {
+		summary.MeanConfidence = round(s.confidenceSum/float64(s.confidenceCount), 4)
+	}
+	if s.qualityCount > 0 {
+		summary.MeanQuality = round(s.qualitySum/float64(s.qualityCount), 4)
+	}
+	return summary
+}
+
+func compare(control, variant Summary) *Comparison {
+	comparison := &Comparison{ErrorRateDelta: round(variant.ErrorRate-control.ErrorRate, 4)}
+	if control.LatencyMs.P50 > 0 && variant.LatencyMs.P50 > 0 {
+		comparison.LatencyP50Pct = round((variant.LatencyMs.P50/control.LatencyMs.P50-1)*100, 1)
+	}
+	if control.MeanConfidence >= 0 && variant.MeanConfidence >= 0 {
+		comparison.ConfidenceDelta = round(variant.MeanConfidence-control.MeanConfidence, 4)
+	}
+	if control.MeanQuality >= 0 && variant.MeanQuality >= 0 {
+		comparison.QualityDelta = round(variant.MeanQuality-control.MeanQuality, 4)
+	}
+	return comparison
+}
+
+// percentile of sorted values with linear interpolation
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	position := p * float64(len(sorted)-1)
+	lower := int(position)
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*(position-float64(lower))
+}
+
+func round(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,83 @@
+package experiment
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssignIsStableAndFollowsWeights(t *testing.T) {
+	variants := []Variant{{Name: "control", Weight: 50}, {Name: "low_threshold", Weight: 30}}
+
+	counts := make([]int, len(variants))
+	excluded := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("sess_%d", i)
+		index := Assign("vad-threshold", key, variants)
+		assert.Equal(t, index, Assign("vad-threshold", key, variants), "same key, same variant")
+		if index < 0 {
+			excluded++
+			continue
+		}
+		counts[index]++
+	}
+	assert.InDelta(t, 5000, counts[0], 300)
+	assert.InDelta(t, 3000, counts[1], 300)
+	assert.InDelta(t, 2000, excluded, 300)
+}
+
+func TestAssignDependsOnExperiment(t *testing.T) {
+	variants := []Variant{{Name: "a", Weight: 50}, {Name: "b", Weight: 50}}
+	differ := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key_%d", i)
+		if Assign("first", key, variants) != Assign("second", key, variants) {
+			differ++
+		}
+	}
+	assert.InDelta(t, 500, differ, 100, "experiments split the same keys independently")
+}
+
+func TestRecorderSummariesCompareToControl(t *testing.T) {
+	r := NewRecorder([]string{"control", "candidate"})
+
+	r.RecordSession("control", 30*time.Second)
+	r.RecordSession("candidate", 20*time.Second)
+	r.RecordSession("unknown", time.Minute)
+
+	for i := 1; i <= 10; i++ {
+		r.RecordItem("control", time.Duration(i*100)*time.Millisecond, false, 0.8, 0.7)
+		r.RecordItem("candidate", time.Duration(i*80)*time.Millisecond, false, 0.9, -1)
+	}
+	r.RecordItem("candidate", 0, true, -1, -1)
+
+	summaries := r.Summaries()
+	require.Len(t, summaries, 2)
+
+	control, candidate := summaries[0], summaries[1]
+	assert.Equal(t, int64(1), control.Sessions)
+	assert.Equal(t, 30.0, control.AudioSeconds)
+	assert.Equal(t, int64(10), control.Items)
+	assert.Equal(t, 550.0, control.LatencyMs.Mean)
+	assert.Equal(t, 550.0, control.LatencyMs.P50)
+	assert.Equal(t, 955.0, control.LatencyMs.P95)
+	assert.Equal(t, 0.8, control.MeanConfidence)
+	assert.Equal(t, 0.7, control.MeanQuality)
+	assert.Nil(t, control.VsControl)
+
+	assert.Equal(t, int64(11), candidate.Items)
+	assert.Equal(t, int64(1), candidate.FailedItems)
+	assert.Equal(t, 0.0909, candidate.ErrorRate)
+	assert.Equal(t, -1.0, candidate.MeanQuality)
+	require.NotNil(t, candidate.VsControl)
+	assert.Equal(t, -20.0, candidate.VsControl.LatencyP50Pct)
+	assert.Equal(t, 0.0909, candidate.VsControl.ErrorRateDelta)
+	assert.Equal(t, 0.1, candidate.VsControl.ConfidenceDelta)
+	assert.Equal(t, 0.0, candidate.VsControl.QualityDelta, "no quality measured for the candidate")
+
+	r.Reset()
+	assert.Equal(t, int64(0), r.Summaries()[0].Items)
+}
@@ -0,0 +1,118 @@
+// Package g711 converts between 16-bit PCM and the G.711 μ-law and A-law companding
+// used by telephony, following the ITU-T G.711 reference tables.
+package g711
+
+const (
+	ulawBias = 0x84
+	ulawClip = 32635
+)
+
+// DecodeULaw expands μ-law bytes to 16-bit PCM samples
+func DecodeULaw(data []byte) []int16 {
+	samples := make([]int16, len(data))
+	for i, b := range data {
+		samples[i] = ulawToLinear(b)
+	}
+	return samples
+}
+
+// DecodeALaw expands A-law bytes to 16-bit PCM samples
+func DecodeALaw(data []byte) []int16 {
+	samples := make([]int16, len(data))
+	for i, b := range data {
+		samples[i] = alawToLinear(b)
+	}
+	return samples
+}
+
+// EncodeULaw compresses 16-bit PCM samples to μ-law bytes
+func EncodeULaw(samples []int16) []byte {
+	data := make([]byte, len(samples))
+	for i, s := range samples {
+		data[i] = linearToULaw(s)
+	}
+	return data
+}
+
+// EncodeALaw compresses 16-bit PCM samples to A-law bytes
+func EncodeALaw(samples []int16) []byte {
+	data := make([]byte, len(samples))
+	for i, s := range samples {
+		data[i] = linearToALaw(s)
+	}
+	return data
+}
+
+func ulawToLinear(u byte) int16 {
+	u = ^u
+	exponent := int(u>>4) & 0x07
+	mantissa := int(u) & 0x0F
+	sample := ((mantissa << 3) + ulawBias) << exponent
+	sample -= ulawBias
+	if u&0x80 != 0 {
+		return int16(-sample)
+	}
+	return int16(sample)
+}
+
+func linearToULaw(s int16) byte {
+	sample := int(s)
+	var sign byte
+	if sample < 0 {
+		sign = 0x80
+		sample = -sample
+	}
+	if sample > ulawClip {
+		sample = ulawClip
+	}
+	sample += ulawBias
+
+	exponent := 7
+	for mask := 0x4000; sample&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (sample >> (exponent + 3)) & 0x0F
+	return ^(sign | byte(exponent<<4) | byte(mantissa))
+}
+
+func alawToLinear(a byte) int16 {
+	a ^= 0x55
+	exponent := int(a>>4) & 0x07
+	mantissa := int(a) & 0x0F
+	var sample int
+	if exponent == 0 {
+		sample = (mantissa << 4) + 8
+	} else {
+		sample = ((mantissa << 4) + 0x108) << (exponent - 1)
+	}
+	// A-law sets the sign bit for positive samples
+	if a&0x80 != 0 {
+		return int16(sample)
+	}
+	return int16(-sample)
+}
+
+func linearToALaw(s int16) byte {
+	sample := int(s) >> 3
+	mask := byte(0xD5)
+	if sample < 0 {
+		mask = 0x55
+		sample = -sample - 1
+	}
+
+	segment := 0
+	for limit := 0x1F; segment < 8 && sample > limit; limit = limit<<1 | 1 {
+		segment++
+	}
+	if segment >= 8 {
+		return 0x7F ^ mask
+	}
+
+	value := byte(segment << 4)
+	if segment < 2 {
+		value |= byte(sample>>1) & 0x0F
+	} else {
+		value |= byte(sample>>segment) & 0x0F
+	}
+	return value ^ mask
+}
@@ -0,0 +1,43 @@
+package g711
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeReferenceValues(t *testing.T) {
+	assert.Equal(t, []int16{0, 0, -32124, 32124}, DecodeULaw([]byte{0xFF, 0x7F, 0x00, 0x80}))
+	assert.Equal(t, []int16{8, -8, 32256, -32256}, DecodeALaw([]byte{0xD5, 0x55, 0xAA, 0x2A}))
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, s := range []int16{0, 1, -1, 100, -100, 1000, -1000, 12345, -12345, 32767, -32768} {
+		// Companding keeps the error within a quantization step of the segment
+		tolerance := abs(int(s))/16 + 16
+
+		u := DecodeULaw(EncodeULaw([]int16{s}))[0]
+		assert.LessOrEqual(t, abs(int(u)-int(s)), tolerance, "μ-law %d -> %d", s, u)
+
+		a := DecodeALaw(EncodeALaw([]int16{s}))[0]
+		assert.LessOrEqual(t, abs(int(a)-int(s)), tolerance, "A-law %d -> %d", s, a)
+	}
+}
+
+func TestEveryCodeRoundTrips(t *testing.T) {
+	for code := 0; code < 256; code++ {
+		b := byte(code)
+		// μ-law has two codes for zero
+		if b != 0x7F {
+			assert.Equal(t, b, EncodeULaw(DecodeULaw([]byte{b}))[0], "μ-law code %#x", b)
+		}
+		assert.Equal(t, b, EncodeALaw(DecodeALaw([]byte{b}))[0], "A-law code %#x", b)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
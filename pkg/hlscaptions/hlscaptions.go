@@ -0,0 +1,98 @@
+// Package hlscaptions cuts live captions into WebVTT segments of an HLS subtitle
+// rendition. Segment seq covers [seq*SegmentMs, (seq+1)*SegmentMs) of the session audio,
+// the media sequence numbering of a restream segmented at the same duration and
+// started with the session. Cues spanning a segment boundary are repeated in both
+// segments with the same identifier, as players expect.
+package hlscaptions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-restream/stt/pkg/textformat"
+)
+
+// DefaultSegmentMs is the segment duration when Options.SegmentMs is not set
+const DefaultSegmentMs = 6000
+
+// Options of the segments and playlist
+type Options struct {
+	SegmentMs int64 // duration of a segment, DefaultSegmentMs when 0
+	// MPEGTS timestamp (90kHz) of audio position 0 in the restreamed media, written to
+	// the X-TIMESTAMP-MAP header of every segment
+	MPEGTS int64
+	// Segments listed in the playlist, the most recent ones; 0 lists every segment in
+	// an EVENT playlist
+	WindowSegments int
+}
+
+func (o Options) segmentMs() int64 {
+	if o.SegmentMs <= 0 {
+		return DefaultSegmentMs
+	}
+	return o.SegmentMs
+}
+
+// Available returns the number of segments complete by edgeMs, so that media
+// sequences 0 to Available-1 can be served
+func Available(edgeMs int64, opts Options) int64 {
+	if edgeMs <= 0 {
+		return 0
+	}
+	return edgeMs / opts.segmentMs()
+}
+
+// Segment renders media sequence seq as a WebVTT file holding the cues that overlap
+// it. Cues are numbered by their position in cues.
+func Segment(cues []textformat.Cue, seq int64, opts Options) string {
+	start := seq * opts.segmentMs()
+	end := start + opts.segmentMs()
+
+	var b strings.Builder
+	b.WriteString(textformat.WebVTTHeader)
+	fmt.Fprintf(&b, "X-TIMESTAMP-MAP=MPEGTS:%d,LOCAL:00:00:00.000\n", opts.MPEGTS)
+	for i, cue := range cues {
+		if cue.EndMs <= start || cue.StartMs >= end {
+			continue
+		}
+		b.WriteString(textformat.WebVTTCue(i+1, cue))
+	}
+	return b.String()
+}
+
+// Playlist renders the media playlist of the segments complete by edgeMs, with
+// segment URIs prefix followed by "<seq>.vtt"
+func Playlist(edgeMs int64, prefix string, opts Options) string {
+	available := Available(edgeMs, opts)
+	first := int64(0)
+	if opts.WindowSegments > 0 && available > int64(opts.WindowSegments) {
+		first = available - int64(opts.WindowSegments)
+	}
+	duration := float64(opts.segmentMs()) / 1000
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", (opts.segmentMs()+999)/1000)
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", first)
+	if opts.WindowSegments <= 0 {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:EVENT\n")
+	}
+	for seq := first; seq < available; seq++ {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s%d.vtt\n", duration, prefix, seq)
+	}
+	return b.String()
+}
+
+// ParseSegmentName returns the media sequence of a segment URI such as "12.vtt"
+func ParseSegmentName(name string) (int64, error) {
+	number, ok := strings.CutSuffix(name, ".vtt")
+	if !ok {
+		return 0, fmt.Errorf("invalid caption segment %q, expected <seq>.vtt", name)
+	}
+	seq, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, fmt.Errorf("invalid caption segment %q, expected <seq>.vtt", name)
+	}
+	return seq, nil
+}
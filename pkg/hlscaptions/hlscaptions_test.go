@@ -0,0 +1,52 @@
+package hlscaptions
+
+import (
+	"testing"
+
+	"github.com/go-restream/stt/pkg/textformat"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var cues = []textformat.Cue{
+	{StartMs: 1000, EndMs: 2500, Lines: []string{"first"}},
+	{StartMs: 5000, EndMs: 7000, Lines: []string{"across"}},
+	{StartMs: 8000, EndMs: 9000, Lines: []string{"second"}},
+}
+
+func TestSegment(t *testing.T) {
+	opts := Options{SegmentMs: 6000, MPEGTS: 900000}
+
+	assert.Equal(t, "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\n"+
+		"\n1\n00:00:01.000 --> 00:00:02.500\nfirst\n"+
+		"\n2\n00:00:05.000 --> 00:00:07.000\nacross\n", Segment(cues, 0, opts))
+	assert.Equal(t, "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\n"+
+		"\n2\n00:00:05.000 --> 00:00:07.000\nacross\n"+
+		"\n3\n00:00:08.000 --> 00:00:09.000\nsecond\n", Segment(cues, 1, opts))
+	assert.Equal(t, "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\n", Segment(cues, 2, opts))
+}
+
+func TestPlaylist(t *testing.T) {
+	assert.Equal(t, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:6\n#EXT-X-MEDIA-SEQUENCE:0\n"+
+		"#EXT-X-PLAYLIST-TYPE:EVENT\n"+
+		"#EXTINF:6.000,\ncaptions/0.vtt\n#EXTINF:6.000,\ncaptions/1.vtt\n",
+		Playlist(17999, "captions/", Options{}))
+
+	assert.Equal(t, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:3\n"+
+		"#EXTINF:1.500,\n3.vtt\n#EXTINF:1.500,\n4.vtt\n",
+		Playlist(7500, "", Options{SegmentMs: 1500, WindowSegments: 2}))
+
+	assert.Equal(t, int64(0), Available(-1, Options{}))
+}
+
+func TestParseSegmentName(t *testing.T) {
+	seq, err := ParseSegmentName("12.vtt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), seq)
+
+	for _, name := range []string{"12", "-1.vtt", "x.vtt", "12.srt"} {
+		_, err := ParseSegmentName(name)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,196 @@
+// Package jwe encrypts payloads for a recipient's X25519 public key as JWE compact
+// tokens (RFC 7516) using direct key agreement, ECDH-ES with X25519 (RFC 8037) and
+// A256GCM content encryption. Any JOSE library that supports ECDH-ES and the OKP key
+// type can decrypt them.
+package jwe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	algorithm  = "ECDH-ES"
+	encryption = "A256GCM"
+	keyBits    = 256
+)
+
+var (
+	ErrMalformed = errors.New("jwe: malformed token")
+	ErrAlgorithm = errors.New("jwe: unsupported algorithm")
+	ErrDecrypt   = errors.New("jwe: decryption failed")
+)
+
+var b64 = base64.RawURLEncoding
+
+// Header is the protected header of a token
+type Header struct {
+	Algorithm    string `json:"alg"`
+	Encryption   string `json:"enc"`
+	KeyID        string `json:"kid,omitempty"`
+	ContentType  string `json:"cty,omitempty"`
+	EphemeralKey struct {
+		KeyType string `json:"kty"`
+		Curve   string `json:"crv"`
+		X       string `json:"x"`
+	} `json:"epk"`
+}
+
+// GenerateKey returns a new X25519 key pair, both base64url encoded as in the "d" and
+// "x" members of a JWK
+func GenerateKey() (privateKey, publicKey string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return b64.EncodeToString(key.Bytes()), b64.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// ParsePublicKey decodes a base64url X25519 public key
+func ParsePublicKey(s string) (*ecdh.PublicKey, error) {
+	raw, err := b64.DecodeString(strings.TrimRight(strings.TrimSpace(s), "="))
+	if err != nil {
+		return nil, fmt.Errorf("jwe: invalid public key: %v", err)
+	}
+	return ecdh.X25519().NewPublicKey(raw)
+}
+
+// ParsePrivateKey decodes a base64url X25519 private key
+func ParsePrivateKey(s string) (*ecdh.PrivateKey, error) {
+	raw, err := b64.DecodeString(strings.TrimRight(strings.TrimSpace(s), "="))
+	if err != nil {
+		return nil, fmt.Errorf("jwe: invalid private key: %v", err)
+	}
+	return ecdh.X25519().NewPrivateKey(raw)
+}
+
+// Encrypt encrypts the payload for the recipient. keyID and contentType, the kid and
+// cty header members, are optional.
+func Encrypt(payload []byte, recipient *ecdh.PublicKey, keyID, contentType string) (string, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return "", fmt.Errorf("jwe: key agreement failed: %v", err)
+	}
+
+	header := Header{Algorithm: algorithm, Encryption: encryption, KeyID: keyID, ContentType: contentType}
+	header.EphemeralKey.KeyType = "OKP"
+	header.EphemeralKey.Curve = "X25519"
+	header.EphemeralKey.X = b64.EncodeToString(ephemeral.PublicKey().Bytes())
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	protected := b64.EncodeToString(headerJSON)
+
+	gcm, err := newGCM(contentKey(shared))
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, payload, []byte(protected))
+	ciphertext, tag := sealed[:len(payload)], sealed[len(payload):]
+
+	// The encrypted key is empty with direct key agreement
+	return strings.Join([]string{
+		protected,
+		"",
+		b64.EncodeToString(iv),
+		b64.EncodeToString(ciphertext),
+		b64.EncodeToString(tag),
+	}, "."), nil
+}
+
+// Decrypt returns the payload and header of a token encrypted for the key
+func Decrypt(token string, key *ecdh.PrivateKey) ([]byte, Header, error) {
+	var header Header
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 || parts[1] != "" {
+		return nil, header, ErrMalformed
+	}
+
+	headerJSON, err := b64.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(headerJSON, &header) != nil {
+		return nil, header, ErrMalformed
+	}
+	if header.Algorithm != algorithm || header.Encryption != encryption ||
+		header.EphemeralKey.KeyType != "OKP" || header.EphemeralKey.Curve != "X25519" {
+		return nil, header, ErrAlgorithm
+	}
+
+	decoded := make([][]byte, 3)
+	for i, part := range parts[2:] {
+		if decoded[i], err = b64.DecodeString(part); err != nil {
+			return nil, header, ErrMalformed
+		}
+	}
+	iv, ciphertext, tag := decoded[0], decoded[1], decoded[2]
+
+	ephemeral, err := ParsePublicKey(header.EphemeralKey.X)
+	if err != nil {
+		return nil, header, ErrMalformed
+	}
+	shared, err := key.ECDH(ephemeral)
+	if err != nil {
+		return nil, header, ErrDecrypt
+	}
+	gcm, err := newGCM(contentKey(shared))
+	if err != nil {
+		return nil, header, err
+	}
+	if len(iv) != gcm.NonceSize() || len(tag) != gcm.Overhead() {
+		return nil, header, ErrMalformed
+	}
+	payload, err := gcm.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		return nil, header, ErrDecrypt
+	}
+	return payload, header, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// concatKDF derives a content key of up to 256 bits from the shared secret as in RFC
+// 7518 section 4.6.2, a single SHA-256 round being enough for that length
+func concatKDF(shared []byte, algorithmID string, partyU, partyV []byte, bits int) []byte {
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, uint32(1))
+	h.Write(shared)
+	writeLengthPrefixed(h, []byte(algorithmID))
+	writeLengthPrefixed(h, partyU)
+	writeLengthPrefixed(h, partyV)
+	binary.Write(h, binary.BigEndian, uint32(bits))
+	return h.Sum(nil)[:bits/8]
+}
+
+func writeLengthPrefixed(w io.Writer, data []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(data)))
+	w.Write(data)
+}
+
+// contentKey is the A256GCM key of a shared secret, tokens carry no apu or apv
+func contentKey(shared []byte) []byte {
+	return concatKDF(shared, encryption, nil, nil, keyBits)
+}
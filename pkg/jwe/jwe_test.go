@@ -0,0 +1,81 @@
+package jwe
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	privateKey, publicKey, err := GenerateKey()
+	require.NoError(t, err)
+	recipient, err := ParsePublicKey(publicKey)
+	require.NoError(t, err)
+	key, err := ParsePrivateKey(privateKey)
+	require.NoError(t, err)
+
+	payload := []byte(`{"transcript":"你好，世界"}`)
+	token, err := Encrypt(payload, recipient, "hooks-2024", "application/json")
+	require.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(token, "."))
+	assert.NotContains(t, token, "transcript")
+
+	decrypted, header, err := Decrypt(token, key)
+	require.NoError(t, err)
+	assert.Equal(t, payload, decrypted)
+	assert.Equal(t, "ECDH-ES", header.Algorithm)
+	assert.Equal(t, "A256GCM", header.Encryption)
+	assert.Equal(t, "hooks-2024", header.KeyID)
+	assert.Equal(t, "application/json", header.ContentType)
+}
+
+func TestDecryptRejectsWrongKeyAndTampering(t *testing.T) {
+	_, publicKey, _ := GenerateKey()
+	otherPrivate, _, _ := GenerateKey()
+	recipient, _ := ParsePublicKey(publicKey)
+	other, _ := ParsePrivateKey(otherPrivate)
+
+	token, err := Encrypt([]byte("secret"), recipient, "", "")
+	require.NoError(t, err)
+
+	_, _, err = Decrypt(token, other)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	privateKey, publicKey, _ := GenerateKey()
+	recipient, _ = ParsePublicKey(publicKey)
+	key, _ := ParsePrivateKey(privateKey)
+	token, _ = Encrypt([]byte("secret"), recipient, "", "")
+	parts := strings.Split(token, ".")
+
+	// The protected header is authenticated data
+	var header map[string]interface{}
+	raw, _ := b64.DecodeString(parts[0])
+	require.NoError(t, json.Unmarshal(raw, &header))
+	header["kid"] = "forged"
+	raw, _ = json.Marshal(header)
+	forged := b64.EncodeToString(raw) + "." + strings.Join(parts[1:], ".")
+	_, _, err = Decrypt(forged, key)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	_, _, err = Decrypt(strings.Join(parts[:4], "."), key)
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestConcatKDFMatchesRFC7518(t *testing.T) {
+	// RFC 7518 appendix C
+	shared := []byte{158, 86, 217, 29, 129, 113, 53, 211, 114, 131, 66, 131, 191, 132,
+		38, 156, 251, 49, 110, 163, 218, 128, 106, 72, 246, 218, 167, 121,
+		140, 254, 144, 196}
+	key := concatKDF(shared, "A128GCM", []byte("Alice"), []byte("Bob"), 128)
+	assert.Equal(t, "VqqN6vgjbSBcIijNcacQGg", b64.EncodeToString(key))
+}
+
+func TestParsePublicKeyRejectsBadInput(t *testing.T) {
+	_, err := ParsePublicKey("not base64!")
+	assert.Error(t, err)
+	_, err = ParsePublicKey(b64.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+}
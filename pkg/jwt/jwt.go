@@ -0,0 +1,158 @@
+// Package jwt verifies and signs HS256 JSON Web Tokens, the only algorithm accepted
+// for client authentication. Tokens signed with any other algorithm are rejected.
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed = errors.New("jwt: malformed token")
+	ErrAlgorithm = errors.New("jwt: unsupported signing algorithm")
+	ErrSignature = errors.New("jwt: invalid signature")
+	ErrExpired   = errors.New("jwt: token expired")
+	ErrNotYet    = errors.New("jwt: token not valid yet")
+	ErrIssuer    = errors.New("jwt: unexpected issuer")
+	ErrAudience  = errors.New("jwt: unexpected audience")
+)
+
+// Claims are the decoded claims of a token
+type Claims map[string]any
+
+// String returns a string claim, empty when it is missing or not a string
+func (c Claims) String(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// Int returns a numeric claim, 0 when it is missing or not a number
+func (c Claims) Int(name string) int {
+	value, _ := c[name].(float64)
+	return int(value)
+}
+
+// Audience returns the aud claim, which may be a string or a list of strings
+func (c Claims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []any:
+		audience := make([]string, 0, len(aud))
+		for _, entry := range aud {
+			if s, ok := entry.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	}
+	return nil
+}
+
+// time returns a NumericDate claim
+func (c Claims) time(name string) (time.Time, bool) {
+	value, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(value), 0), true
+}
+
+// Expect are the claims a token must carry beyond a valid signature. Empty fields
+// are not checked.
+type Expect struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration // clock skew tolerated on exp and nbf
+}
+
+var encoding = base64.RawURLEncoding
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Verify checks the HS256 signature of a token and its exp, nbf, iss and aud claims
+// at now, returning its claims
+func Verify(token string, secret []byte, expect Expect, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	headerJSON, err := encoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return nil, ErrMalformed
+	}
+	if h.Alg != "HS256" {
+		return nil, fmt.Errorf("%w: %q", ErrAlgorithm, h.Alg)
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !hmac.Equal(signature, sign(parts[0]+"."+parts[1], secret)) {
+		return nil, ErrSignature
+	}
+
+	payload, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrMalformed
+	}
+
+	if exp, ok := claims.time("exp"); ok && !now.Before(exp.Add(expect.Leeway)) {
+		return nil, ErrExpired
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(expect.Leeway).Before(nbf) {
+		return nil, ErrNotYet
+	}
+	if expect.Issuer != "" && claims.String("iss") != expect.Issuer {
+		return nil, ErrIssuer
+	}
+	if expect.Audience != "" {
+		found := false
+		for _, aud := range claims.Audience() {
+			found = found || aud == expect.Audience
+		}
+		if !found {
+			return nil, ErrAudience
+		}
+	}
+	return claims, nil
+}
+
+// Sign creates an HS256 token carrying the claims, for issuing tokens to clients
+func Sign(claims Claims, secret []byte) (string, error) {
+	headerJSON, err := json.Marshal(header{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := encoding.EncodeToString(headerJSON) + "." + encoding.EncodeToString(payload)
+	return signingInput + "." + encoding.EncodeToString(sign(signingInput, secret)), nil
+}
+
+func sign(signingInput string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var secret = []byte("test-secret")
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	token, err := Sign(Claims{
+		"sub":          "user-1",
+		"tenant":       "acme",
+		"max_sessions": 3,
+		"exp":          now.Add(time.Hour).Unix(),
+		"iss":          "issuer",
+		"aud":          []string{"stt", "other"},
+	}, secret)
+	require.NoError(t, err)
+
+	claims, err := Verify(token, secret, Expect{Issuer: "issuer", Audience: "stt"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.String("sub"))
+	assert.Equal(t, "acme", claims.String("tenant"))
+	assert.Equal(t, 3, claims.Int("max_sessions"))
+	assert.Equal(t, []string{"stt", "other"}, claims.Audience())
+}
+
+func TestVerifyRejects(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	valid, err := Sign(Claims{"sub": "user-1", "aud": "stt", "iss": "issuer"}, secret)
+	require.NoError(t, err)
+	expired, _ := Sign(Claims{"exp": now.Add(-time.Minute).Unix()}, secret)
+	notYet, _ := Sign(Claims{"nbf": now.Add(time.Minute).Unix()}, secret)
+
+	parts := strings.Split(valid, ".")
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+
+	cases := []struct {
+		name   string
+		token  string
+		secret []byte
+		expect Expect
+		err    error
+	}{
+		{"garbage", "not-a-token", secret, Expect{}, ErrMalformed},
+		{"wrong secret", valid, []byte("other"), Expect{}, ErrSignature},
+		{"tampered payload", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`)) + "." + parts[2], secret, Expect{}, ErrSignature},
+		{"alg none", noneHeader + "." + parts[1] + ".", secret, Expect{}, ErrAlgorithm},
+		{"expired", expired, secret, Expect{}, ErrExpired},
+		{"not yet valid", notYet, secret, Expect{}, ErrNotYet},
+		{"issuer", valid, secret, Expect{Issuer: "someone-else"}, ErrIssuer},
+		{"audience", valid, secret, Expect{Audience: "billing"}, ErrAudience},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Verify(tc.token, tc.secret, tc.expect, now)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestVerifyLeeway(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	token, err := Sign(Claims{"exp": now.Add(-10 * time.Second).Unix()}, secret)
+	require.NoError(t, err)
+
+	_, err = Verify(token, secret, Expect{Leeway: 30 * time.Second}, now)
+	assert.NoError(t, err)
+}
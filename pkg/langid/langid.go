@@ -0,0 +1,159 @@
+// Package langid identifies the language of a transcript. Engines that detect the
+// language report it in different forms, Normalize turns them into ISO-639-1 codes;
+// Detect guesses the language of a text for engines that report none.
+package langid
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Auto is the language setting that asks for the language to be detected
+const Auto = "auto"
+
+// names maps the language names of Whisper's verbose_json to ISO-639-1 codes
+var names = map[string]string{
+	"arabic":     "ar",
+	"cantonese":  "yue",
+	"chinese":    "zh",
+	"czech":      "cs",
+	"danish":     "da",
+	"dutch":      "nl",
+	"english":    "en",
+	"finnish":    "fi",
+	"french":     "fr",
+	"german":     "de",
+	"greek":      "el",
+	"hebrew":     "he",
+	"hindi":      "hi",
+	"hungarian":  "hu",
+	"indonesian": "id",
+	"italian":    "it",
+	"japanese":   "ja",
+	"korean":     "ko",
+	"malay":      "ms",
+	"norwegian":  "no",
+	"persian":    "fa",
+	"polish":     "pl",
+	"portuguese": "pt",
+	"romanian":   "ro",
+	"russian":    "ru",
+	"spanish":    "es",
+	"swedish":    "sv",
+	"thai":       "th",
+	"turkish":    "tr",
+	"ukrainian":  "uk",
+	"vietnamese": "vi",
+}
+
+// Normalize returns the ISO-639-1 code of a language reported by an engine: a code,
+// a Whisper language name or a SenseVoice tag like "<|en|>". Unknown names are
+// returned in lower case, an empty or "nospeech" language as "".
+func Normalize(language string) string {
+	language = strings.ToLower(strings.Trim(strings.TrimSpace(language), "<|>"))
+	if language == "" || language == "nospeech" || language == Auto {
+		return ""
+	}
+	if code, ok := names[language]; ok {
+		return code
+	}
+	return language
+}
+
+// scripts maps the scripts used by a single language to its code
+var scripts = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Thai, "th"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Arabic, "ar"},
+	{unicode.Cyrillic, "ru"},
+}
+
+// stopwords are frequent short words of the languages written in Latin script
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "you", "to", "of", "it", "that", "this", "what", "have", "with", "for", "not"},
+	"es": {"el", "la", "los", "las", "que", "es", "y", "de", "en", "un", "una", "por", "con", "no", "para"},
+	"fr": {"le", "la", "les", "est", "et", "de", "un", "une", "je", "vous", "que", "pas", "pour", "avec", "dans"},
+	"de": {"der", "die", "das", "und", "ist", "ich", "nicht", "ein", "eine", "zu", "mit", "sie", "es", "auf", "für"},
+	"it": {"il", "la", "che", "di", "e", "è", "un", "una", "non", "per", "con", "sono", "gli", "del", "questo"},
+	"pt": {"o", "a", "os", "que", "de", "é", "e", "um", "uma", "não", "para", "com", "você", "do", "em"},
+	"nl": {"de", "het", "een", "en", "is", "van", "ik", "niet", "dat", "je", "op", "met", "zijn", "voor", "wat"},
+}
+
+// Detect guesses the language of a text. Letters of a script used by one language,
+// like Hangul or Thai, decide by majority; kana before Han so Japanese with kanji is
+// not taken for Chinese. Latin text is scored by its stopwords. It returns "" when
+// the text gives no clue.
+func Detect(text string) string {
+	counts := map[string]int{}
+	var latin, letters int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, script := range scripts {
+			if unicode.Is(script.table, r) {
+				counts[script.code]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// Any kana makes Han text Japanese
+	if counts["ja"] > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+
+	best, bestCount := "", 0
+	for code, count := range counts {
+		if count > bestCount || (count == bestCount && code < best) {
+			best, bestCount = code, count
+		}
+	}
+	if bestCount > latin {
+		return best
+	}
+	return detectLatin(text)
+}
+
+// detectLatin returns the Latin-script language whose stopwords occur most often in
+// text, "" when none occurs
+func detectLatin(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	best, bestScore := "", 0
+	for code, list := range stopwords {
+		score := 0
+		for _, word := range words {
+			for _, stopword := range list {
+				if word == stopword {
+					score++
+					break
+				}
+			}
+		}
+		if score > bestScore || (score == bestScore && score > 0 && code < best) {
+			best, bestScore = code, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,55 @@
+package langid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"en", "en"},
+		{"English", "en"},
+		{"chinese", "zh"},
+		{"<|ja|>", "ja"},
+		{"<|nospeech|>", ""},
+		{"auto", ""},
+		{"", ""},
+		{"klingon", "klingon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			assert.Equal(t, tt.want, Normalize(tt.language))
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"chinese", "今天天气很好，我们去公园吧。", "zh"},
+		{"japanese with kanji", "今日はいい天気ですね", "ja"},
+		{"korean", "안녕하세요 만나서 반갑습니다", "ko"},
+		{"russian", "Привет, как дела?", "ru"},
+		{"arabic with latin brand", "مرحبا بكم في iPhone", "ar"},
+		{"english", "What is the status of this order?", "en"},
+		{"spanish", "Hola, ¿que tal? Estoy en la oficina con un cliente", "es"},
+		{"german", "Ich habe die Rechnung nicht und das ist ein Problem", "de"},
+		{"french", "Je ne sais pas, est-ce que vous avez une minute", "fr"},
+		{"no clue", "OK 42", ""},
+		{"no letters", "123 ...", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Detect(tt.text))
+		})
+	}
+}
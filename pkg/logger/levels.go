@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// componentLevels holds the base level and the per-component overrides
+type componentLevels struct {
+	base       logrus.Level
+	components map[string]logrus.Level
+}
+
+var (
+	levels      *componentLevels
+	levelsMutex sync.RWMutex
+)
+
+// componentFilter wraps the configured formatter and drops entries below the level
+// of their "component" field. The logger itself runs at the most verbose configured
+// level so that components can also be more verbose than the base level.
+type componentFilter struct {
+	logrus.Formatter
+}
+
+// Format implements the logrus.Formatter interface
+func (f *componentFilter) Format(entry *logrus.Entry) ([]byte, error) {
+	component, _ := entry.Data["component"].(string)
+	if !IsComponentLevelEnabled(component, entry.Level) {
+		return nil, nil
+	}
+	return f.Formatter.Format(entry)
+}
+
+// reloadMutex serializes the reloads, which replace the formatter of the global logger
+var reloadMutex sync.Mutex
+
+// SetComponentLevels sets the base log level and per-component overrides, e.g.
+// {"proc_vad_audio": "warn", "audio_recogniz": "info"}. It may be called at any time
+// to reload levels; it must be called again after replacing the formatter.
+func SetComponentLevels(base string, components map[string]string) error {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+	// Debug sessions pick up the formatter, also when the new levels are invalid
+	defer buildDebugLogger()
+
+	if base == "" {
+		base = logrus.InfoLevel.String()
+	}
+	baseLevel, err := logrus.ParseLevel(base)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q: %v", base, err)
+	}
+
+	parsed := make(map[string]logrus.Level, len(components))
+	loggerLevel := baseLevel
+	for component, value := range components {
+		level, err := logrus.ParseLevel(value)
+		if err != nil {
+			return fmt.Errorf("invalid log level %q for component %s: %v", value, component, err)
+		}
+		parsed[strings.TrimSpace(component)] = level
+		if level > loggerLevel {
+			loggerLevel = level
+		}
+	}
+
+	levelsMutex.Lock()
+	levels = &componentLevels{base: baseLevel, components: parsed}
+	levelsMutex.Unlock()
+
+	l := GetLogger()
+	if _, ok := l.Formatter.(*componentFilter); !ok {
+		l.SetFormatter(&componentFilter{Formatter: l.Formatter})
+	}
+	l.SetLevel(loggerLevel)
+	return nil
+}
+
+// ComponentLevels returns the base level and the per-component overrides
+func ComponentLevels() (string, map[string]string) {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+
+	if levels == nil {
+		return GetLogger().GetLevel().String(), map[string]string{}
+	}
+
+	components := make(map[string]string, len(levels.components))
+	for component, level := range levels.components {
+		components[component] = level.String()
+	}
+	return levels.base.String(), components
+}
+
+// IsComponentLevelEnabled reports whether entries of a component at the given level
+// are logged. Component names are matched after trimming padding spaces.
+func IsComponentLevelEnabled(component string, level logrus.Level) bool {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+
+	if levels == nil {
+		return GetLogger().IsLevelEnabled(level)
+	}
+	if componentLevel, ok := levels.components[strings.TrimSpace(component)]; ok {
+		return componentLevel >= level
+	}
+	return levels.base >= level
+}
+
+// unfiltered returns the formatter without the per-component filter
+func unfiltered(formatter logrus.Formatter) logrus.Formatter {
+	if filter, ok := formatter.(*componentFilter); ok {
+		return filter.Formatter
+	}
+	return formatter
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetComponentLevels(t *testing.T) {
+	var out bytes.Buffer
+	Logger = logrus.New()
+	Logger.SetOutput(&out)
+	Logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	defer func() { Logger = nil; levels = nil }()
+
+	assert.NoError(t, SetComponentLevels("warn", map[string]string{
+		"proc_vad_audio": "error",
+		"audio_recogniz": "info",
+	}))
+
+	WithFields(logrus.Fields{"component": "proc_vad_audio"}).Warn("vad warning")
+	WithFields(logrus.Fields{"component": "audio_recogniz"}).Info("recognition info")
+	WithFields(logrus.Fields{"component": "ws_event_send "}).Info("send info")
+	WithFields(logrus.Fields{"component": "ws_event_send "}).Warn("send warning")
+
+	assert.NotContains(t, out.String(), "vad warning")
+	assert.Contains(t, out.String(), "recognition info")
+	assert.NotContains(t, out.String(), "send info")
+	assert.Contains(t, out.String(), "send warning")
+
+	// Debug sessions bypass component levels
+	out.Reset()
+	Scoped(true).WithFields(logrus.Fields{"component": "proc_vad_audio"}).Debug("vad debug")
+	assert.Contains(t, out.String(), "vad debug")
+
+	assert.Error(t, SetComponentLevels("warn", map[string]string{"proc_vad_audio": "loud"}))
+}
+
+func TestScopedDebugLoggerFollowsReload(t *testing.T) {
+	var out bytes.Buffer
+	Logger = logrus.New()
+	Logger.SetOutput(&out)
+	Logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	defer func() { Logger = nil; levels = nil }()
+
+	assert.Same(t, Logger, Scoped(false))
+	assert.NoError(t, SetComponentLevels("info", nil))
+	debug := Scoped(true)
+	assert.NotSame(t, Logger, debug)
+	assert.Same(t, debug, Scoped(true), "debug sessions share one logger")
+	assert.Same(t, Logger.Out, debug.Out, "both loggers write through one lock")
+
+	// A reload with a new formatter reaches the debug logger
+	Logger.SetFormatter(&logrus.JSONFormatter{})
+	assert.NoError(t, SetComponentLevels("info", nil))
+	debug = Scoped(true)
+	assert.IsType(t, &logrus.JSONFormatter{}, debug.Formatter)
+	debug.WithField("component", "proc_vad_audio").Debug("vad debug")
+	assert.Contains(t, out.String(), `"msg":"vad debug"`)
+}
@@ -1,8 +1,10 @@
 package logger
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
@@ -42,6 +44,7 @@ func InitLogger(logLevel string, logFile string) error {
 		Logger.SetOutput(os.Stderr)
 	}
 
+	rebuildDebugLogger()
 	return nil
 }
 
@@ -53,6 +56,83 @@ func GetLogger() *logrus.Logger {
 	return Logger
 }
 
+// Scoped returns the global logger, or the debug-level logger sharing its output,
+// formatter and hooks when debug is true. It lets a single session log verbosely
+// without lowering the level for every other session.
+func Scoped(debug bool) *logrus.Logger {
+	base := GetLogger()
+	if !debug {
+		return base
+	}
+
+	debugMutex.RLock()
+	scoped, scopedBase := debugLogger, debugBase
+	debugMutex.RUnlock()
+	if scopedBase != base {
+		return rebuildDebugLogger()
+	}
+	return scoped
+}
+
+var (
+	// debugLogger serves the debug sessions of debugBase, rebuilt whenever the
+	// formatter of the global logger may have changed
+	debugLogger *logrus.Logger
+	debugBase   *logrus.Logger
+	debugMutex  sync.RWMutex
+)
+
+// rebuildDebugLogger builds the debug logger from the current global logger
+func rebuildDebugLogger() *logrus.Logger {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+	return buildDebugLogger()
+}
+
+// buildDebugLogger is rebuildDebugLogger with reloadMutex held. The global and the
+// debug logger write through one lockedWriter, so their lines never interleave.
+func buildDebugLogger() *logrus.Logger {
+	debugMutex.Lock()
+	defer debugMutex.Unlock()
+
+	base := GetLogger()
+	_, filtered := base.Formatter.(*componentFilter)
+	if !filtered && base.IsLevelEnabled(logrus.DebugLevel) {
+		debugLogger, debugBase = base, base
+		return base
+	}
+
+	out, ok := base.Out.(*lockedWriter)
+	if !ok {
+		out = &lockedWriter{w: base.Out}
+		base.SetOutput(out)
+	}
+	// Debug sessions bypass per-component levels as well
+	debugLogger = &logrus.Logger{
+		Out:          out,
+		Hooks:        base.Hooks,
+		Formatter:    unfiltered(base.Formatter),
+		ReportCaller: base.ReportCaller,
+		Level:        logrus.DebugLevel,
+		ExitFunc:     base.ExitFunc,
+		BufferPool:   base.BufferPool,
+	}
+	debugBase = base
+	return debugLogger
+}
+
+// lockedWriter serializes the writes of the loggers sharing an output
+type lockedWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.w.Write(p)
+}
+
 // Log levels helper functions
 func Debug(args ...interface{}) {
 	GetLogger().Debug(args...)
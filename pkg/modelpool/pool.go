@@ -0,0 +1,112 @@
+// Package modelpool keeps loaded model instances for reuse, so sessions lease an
+// idle instance instead of loading the model from disk for each one.
+package modelpool
+
+import "sync"
+
+// Stats are the counters of a pool. InUse counts the instances leased and not yet
+// returned.
+type Stats struct {
+	InUse  int   `json:"in_use"`
+	Idle   int   `json:"idle"`
+	Loads  int64 `json:"loads"`  // instances created by the pool
+	Reuses int64 `json:"reuses"` // leases served by an idle instance
+	Closed int64 `json:"closed"` // instances freed because the idle list was full
+}
+
+// Pool leases instances of a model, creating them on first use and keeping up to
+// maxIdle returned instances for the next lease. It is safe for concurrent use.
+type Pool[T any] struct {
+	newFunc   func() (T, error)
+	resetFunc func(T)
+	closeFunc func(T)
+	maxIdle   int
+
+	mutex  sync.Mutex
+	idle   []T
+	stats  Stats
+	closed bool
+}
+
+// New creates an empty pool. resetFunc clears the state of a returned instance and
+// closeFunc frees one; either may be nil. maxIdle <= 0 keeps every returned instance.
+func New[T any](newFunc func() (T, error), resetFunc func(T), closeFunc func(T), maxIdle int) *Pool[T] {
+	return &Pool[T]{
+		newFunc:   newFunc,
+		resetFunc: resetFunc,
+		closeFunc: closeFunc,
+		maxIdle:   maxIdle,
+	}
+}
+
+// Get leases an idle instance, or loads a new one when none is idle
+func (p *Pool[T]) Get() (T, error) {
+	p.mutex.Lock()
+	if n := len(p.idle); n > 0 {
+		item := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.stats.InUse++
+		p.stats.Reuses++
+		p.mutex.Unlock()
+		return item, nil
+	}
+	p.mutex.Unlock()
+
+	// Loading can take a while, other leases proceed meanwhile
+	item, err := p.newFunc()
+	if err != nil {
+		return item, err
+	}
+
+	p.mutex.Lock()
+	p.stats.InUse++
+	p.stats.Loads++
+	p.mutex.Unlock()
+	return item, nil
+}
+
+// Put returns a leased instance to the pool
+func (p *Pool[T]) Put(item T) {
+	if p.resetFunc != nil {
+		p.resetFunc(item)
+	}
+
+	p.mutex.Lock()
+	p.stats.InUse--
+	if !p.closed && (p.maxIdle <= 0 || len(p.idle) < p.maxIdle) {
+		p.idle = append(p.idle, item)
+		p.mutex.Unlock()
+		return
+	}
+	p.stats.Closed++
+	p.mutex.Unlock()
+
+	if p.closeFunc != nil {
+		p.closeFunc(item)
+	}
+}
+
+// Stats returns the counters of the pool
+func (p *Pool[T]) Stats() Stats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stats := p.stats
+	stats.Idle = len(p.idle)
+	return stats
+}
+
+// Close frees the idle instances. Leased instances are freed when returned.
+func (p *Pool[T]) Close() {
+	p.mutex.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mutex.Unlock()
+
+	if p.closeFunc != nil {
+		for _, item := range idle {
+			p.closeFunc(item)
+		}
+	}
+}
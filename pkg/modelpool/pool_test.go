@@ -0,0 +1,79 @@
+package modelpool
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type model struct {
+	id     int
+	state  int
+	closed bool
+}
+
+func newTestPool(maxIdle int) (*Pool[*model], *int) {
+	loads := 0
+	pool := New(func() (*model, error) {
+		loads++
+		return &model{id: loads}, nil
+	}, func(m *model) {
+		m.state = 0
+	}, func(m *model) {
+		m.closed = true
+	}, maxIdle)
+	return pool, &loads
+}
+
+func TestPoolReusesReturnedInstances(t *testing.T) {
+	pool, loads := newTestPool(2)
+
+	a, err := pool.Get()
+	require.NoError(t, err)
+	a.state = 42
+	pool.Put(a)
+
+	b, err := pool.Get()
+	require.NoError(t, err)
+	assert.Same(t, a, b)
+	assert.Equal(t, 0, b.state, "returned instances are reset")
+	assert.Equal(t, 1, *loads)
+	assert.Equal(t, Stats{InUse: 1, Loads: 1, Reuses: 1}, pool.Stats())
+}
+
+func TestPoolClosesBeyondMaxIdle(t *testing.T) {
+	pool, _ := newTestPool(1)
+
+	a, _ := pool.Get()
+	b, _ := pool.Get()
+	pool.Put(a)
+	pool.Put(b)
+
+	assert.False(t, a.closed)
+	assert.True(t, b.closed)
+	assert.Equal(t, Stats{Idle: 1, Loads: 2, Closed: 1}, pool.Stats())
+}
+
+func TestPoolClose(t *testing.T) {
+	pool, _ := newTestPool(0)
+
+	a, _ := pool.Get()
+	b, _ := pool.Get()
+	pool.Put(a)
+	pool.Close()
+	assert.True(t, a.closed)
+
+	pool.Put(b)
+	assert.True(t, b.closed, "instances returned after Close are freed")
+	assert.Equal(t, 0, pool.Stats().Idle)
+}
+
+func TestPoolLoadError(t *testing.T) {
+	pool := New(func() (*model, error) { return nil, errors.New("model not found") }, nil, nil, 0)
+
+	_, err := pool.Get()
+	assert.Error(t, err)
+	assert.Equal(t, Stats{}, pool.Stats())
+}
@@ -0,0 +1,284 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-restream/stt/config"
+)
+
+const lockFileName = "models.lock.json"
+
+// Pack status values reported by List
+const (
+	StatusOK       = "ok"
+	StatusMissing  = "missing"
+	StatusOutdated = "outdated"
+	StatusCorrupt  = "checksum_mismatch"
+)
+
+// PackStatus describes the installed state of a configured model pack
+type PackStatus struct {
+	Name             string `json:"name"`
+	Version          string `json:"version"`
+	InstalledVersion string `json:"installed_version,omitempty"`
+	Path             string `json:"path"`
+	Size             int64  `json:"size"`
+	Status           string `json:"status"`
+}
+
+// lockEntry records what was installed for a pack, used for version pinning
+type lockEntry struct {
+	Version     string    `json:"version"`
+	SHA256      string    `json:"sha256"`
+	File        string    `json:"file"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// Manager downloads and verifies the model files listed in the models config section
+type Manager struct {
+	Dir    string
+	Packs  []config.ModelPack
+	Client *http.Client
+}
+
+// NewManager creates a model manager from the application config
+func NewManager(cfg *config.Config) *Manager {
+	dir := cfg.Models.Dir
+	if dir == "" {
+		dir = "model"
+	}
+	return &Manager{
+		Dir:   dir,
+		Packs: cfg.Models.Packs,
+		Client: &http.Client{
+			Timeout: 10 * time.Minute,
+		},
+	}
+}
+
+// List reports the status of every configured pack. Installed files are hashed and
+// compared with their pinned checksum, or the checksum recorded at install time.
+func (m *Manager) List() ([]PackStatus, error) {
+	lock, err := m.readLock()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]PackStatus, 0, len(m.Packs))
+	for _, pack := range m.Packs {
+		status := PackStatus{
+			Name:             pack.Name,
+			Version:          pack.Version,
+			InstalledVersion: lock[pack.Name].Version,
+			Path:             m.packPath(pack),
+			Status:           StatusOK,
+		}
+
+		info, err := os.Stat(status.Path)
+		if err != nil {
+			status.Status = StatusMissing
+		} else {
+			status.Size = info.Size()
+			entry, locked := lock[pack.Name]
+			if expected := expectedSHA256(pack, lock); expected != "" {
+				checksum, err := fileSHA256(status.Path)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read model %s: %v", pack.Name, err)
+				}
+				if !strings.EqualFold(checksum, expected) {
+					status.Status = StatusCorrupt
+				}
+			}
+			if status.Status == StatusOK && locked && pack.Version != "" && entry.Version != pack.Version {
+				status.Status = StatusOutdated
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Download fetches a pack into the model directory. Existing files are kept unless
+// they fail verification or force is set.
+func (m *Manager) Download(name string, force bool) error {
+	pack, err := m.findPack(name)
+	if err != nil {
+		return err
+	}
+	if pack.URL == "" {
+		return fmt.Errorf("model %s has no download url", pack.Name)
+	}
+
+	path := m.packPath(pack)
+	if !force {
+		if err := m.Verify(pack.Name); err == nil {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create model directory: %v", err)
+	}
+
+	resp, err := m.Client.Get(pack.URL)
+	if err != nil {
+		return fmt.Errorf("download %s failed: %v", pack.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s failed: %s", pack.Name, resp.Status)
+	}
+
+	// Write to a temp file first so a failed download never replaces a good model
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.part")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hasher), resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("download %s failed: %v", pack.Name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write model file: %v", err)
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	if pack.SHA256 != "" && !strings.EqualFold(checksum, pack.SHA256) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", pack.Name, pack.SHA256, checksum)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to install model file: %v", err)
+	}
+
+	return m.updateLock(pack, checksum)
+}
+
+// Verify checks that a pack is installed, matches its pinned checksum (or the checksum
+// recorded at install time) and its pinned version
+func (m *Manager) Verify(name string) error {
+	pack, err := m.findPack(name)
+	if err != nil {
+		return err
+	}
+
+	checksum, err := fileSHA256(m.packPath(pack))
+	if err != nil {
+		return fmt.Errorf("model %s is not installed: %v", pack.Name, err)
+	}
+
+	lock, err := m.readLock()
+	if err != nil {
+		return err
+	}
+	entry, locked := lock[pack.Name]
+
+	if expected := expectedSHA256(pack, lock); expected != "" && !strings.EqualFold(checksum, expected) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", pack.Name, expected, checksum)
+	}
+
+	if locked && pack.Version != "" && entry.Version != pack.Version {
+		return fmt.Errorf("model %s is version %s, pinned version is %s", pack.Name, entry.Version, pack.Version)
+	}
+	return nil
+}
+
+// Names returns the names of all configured packs
+func (m *Manager) Names() []string {
+	names := make([]string, 0, len(m.Packs))
+	for _, pack := range m.Packs {
+		names = append(names, pack.Name)
+	}
+	return names
+}
+
+func (m *Manager) findPack(name string) (config.ModelPack, error) {
+	for _, pack := range m.Packs {
+		if pack.Name == name {
+			return pack, nil
+		}
+	}
+	return config.ModelPack{}, fmt.Errorf("unknown model: %s", name)
+}
+
+func (m *Manager) packPath(pack config.ModelPack) string {
+	file := pack.File
+	if file == "" {
+		file = filepath.Base(pack.URL)
+	}
+	return filepath.Join(m.Dir, filepath.Clean("/"+file))
+}
+
+func (m *Manager) readLock() (map[string]lockEntry, error) {
+	lock := make(map[string]lockEntry)
+
+	data, err := os.ReadFile(filepath.Join(m.Dir, lockFileName))
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model lock file: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse model lock file: %v", err)
+	}
+	return lock, nil
+}
+
+func (m *Manager) updateLock(pack config.ModelPack, checksum string) error {
+	lock, err := m.readLock()
+	if err != nil {
+		return err
+	}
+
+	lock[pack.Name] = lockEntry{
+		Version:     pack.Version,
+		SHA256:      checksum,
+		File:        pack.File,
+		InstalledAt: time.Now(),
+	}
+
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode model lock file: %v", err)
+	}
+	return os.WriteFile(filepath.Join(m.Dir, lockFileName), data, 0600)
+}
+
+// expectedSHA256 returns the pinned checksum of a pack, or the one recorded when it was
+// installed
+func expectedSHA256(pack config.ModelPack, lock map[string]lockEntry) string {
+	if pack.SHA256 != "" {
+		return pack.SHA256
+	}
+	return lock[pack.Name].SHA256
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
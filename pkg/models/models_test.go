@@ -0,0 +1,67 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-restream/stt/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var modelData = []byte("model weights")
+
+func modelSHA256() string {
+	sum := sha256.Sum256(modelData)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestListReportsPackStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		pack    config.ModelPack
+		install bool
+		content []byte // replaces the installed file
+		version string // pinned after installing
+		want    string
+	}{
+		{name: "installed", pack: config.ModelPack{SHA256: modelSHA256()}, install: true, want: StatusOK},
+		{name: "missing", pack: config.ModelPack{SHA256: modelSHA256()}, want: StatusMissing},
+		{name: "truncated", pack: config.ModelPack{SHA256: modelSHA256()}, install: true, content: modelData[:5], want: StatusCorrupt},
+		{name: "corrupt against lock", install: true, content: []byte("model weightz"), want: StatusCorrupt},
+		{name: "outdated", pack: config.ModelPack{Version: "1"}, install: true, version: "2", want: StatusOutdated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(modelData)
+			}))
+			defer server.Close()
+
+			pack := tt.pack
+			pack.Name = "vad"
+			pack.URL = server.URL + "/silero.onnx"
+			manager := &Manager{Dir: t.TempDir(), Packs: []config.ModelPack{pack}, Client: server.Client()}
+			if tt.install {
+				require.NoError(t, manager.Download("vad", false))
+			}
+			if tt.content != nil {
+				require.NoError(t, os.WriteFile(filepath.Join(manager.Dir, "silero.onnx"), tt.content, 0600))
+			}
+			if tt.version != "" {
+				manager.Packs[0].Version = tt.version
+			}
+
+			statuses, err := manager.List()
+			require.NoError(t, err)
+			require.Len(t, statuses, 1)
+			assert.Equal(t, tt.want, statuses[0].Status)
+			assert.Equal(t, tt.want == StatusOK, manager.Verify("vad") == nil)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Package noisegate is a lightweight spectral noise gate in pure Go. It tracks the
+// noise floor of each frequency band and attenuates bands that do not rise above
+// it, so stationary background noise (fans, hum, line hiss) stops triggering the
+// VAD. It is a cheap alternative to the ONNX denoiser, not a replacement for it.
+package noisegate
+
+import (
+	"math"
+
+	"github.com/go-restream/stt/pkg/dsp"
+)
+
+const (
+	frameSize = 512 // 32ms at 16kHz
+	hopSize   = frameSize / 2
+
+	// Band power is smoothed over a few frames before it is compared, so the random
+	// fluctuation of noise does not open the gate
+	powerSmoothing = 0.7
+
+	// The noise floor averages the power of gated bands, and only creeps up under
+	// open bands so a sustained change of background noise is eventually learned
+	floorTrack = 0.05
+	floorRise  = 0.002
+)
+
+// Options configure the gate. Zero values use defaults.
+type Options struct {
+	ThresholdDB float64 // band level above the noise floor that opens the gate, default 6
+	ReductionDB float64 // attenuation of gated bands, default 18
+	AttackMs    float64 // time for a band to open, default 5
+	ReleaseMs   float64 // time for a band to close, default 120
+}
+
+func (o Options) withDefaults() Options {
+	if o.ThresholdDB <= 0 {
+		o.ThresholdDB = 6
+	}
+	if o.ReductionDB <= 0 {
+		o.ReductionDB = 18
+	}
+	if o.AttackMs <= 0 {
+		o.AttackMs = 5
+	}
+	if o.ReleaseMs <= 0 {
+		o.ReleaseMs = 120
+	}
+	return o
+}
+
+// Gate is a streaming spectral noise gate. Output is delayed by 32ms at 16kHz and
+// has as many samples as the input. It is not safe for concurrent use.
+type Gate struct {
+	threshold float64 // power ratio over the floor
+	floorGain float64
+	attack    float64 // per-frame smoothing coefficients
+	release   float64
+	window    []float64 // analysis and synthesis window
+	power     []float64 // smoothed band power
+	floor     []float64
+	gain      []float64
+	primed    bool
+
+	input    []float64 // last frameSize input samples
+	pending  int       // input samples received since the last frame
+	overlap  []float64 // synthesis tail carried to the next frame
+	output   []int16   // processed samples not yet returned
+	spectrum []complex128
+}
+
+// New creates a gate for audio at sampleRate
+func New(sampleRate int, opts Options) *Gate {
+	opts = opts.withDefaults()
+	hopMs := float64(hopSize) * 1000 / float64(sampleRate)
+
+	g := &Gate{
+		threshold: math.Pow(10, opts.ThresholdDB/10),
+		floorGain: math.Pow(10, -opts.ReductionDB/20),
+		attack:    math.Exp(-hopMs / opts.AttackMs),
+		release:   math.Exp(-hopMs / opts.ReleaseMs),
+		window:    dsp.SqrtHann(frameSize),
+		power:     make([]float64, frameSize/2+1),
+		floor:     make([]float64, frameSize/2+1),
+		gain:      make([]float64, frameSize/2+1),
+		input:     make([]float64, frameSize),
+		overlap:   make([]float64, frameSize),
+		output:    make([]int16, hopSize), // the latency of the first frame
+		spectrum:  make([]complex128, frameSize),
+	}
+	for i := range g.gain {
+		g.gain[i] = 1
+	}
+	return g
+}
+
+// Process gates the next chunk of the stream, returning as many samples as given
+func (g *Gate) Process(samples []int16) []int16 {
+	for _, s := range samples {
+		copy(g.input, g.input[1:])
+		g.input[frameSize-1] = float64(s)
+		g.pending++
+		if g.pending == hopSize {
+			g.pending = 0
+			g.processFrame()
+		}
+	}
+
+	out := make([]int16, len(samples))
+	copy(out, g.output)
+	g.output = append(g.output[:0], g.output[len(samples):]...)
+	return out
+}
+
+func (g *Gate) processFrame() {
+	for i, s := range g.input {
+		g.spectrum[i] = complex(s*g.window[i], 0)
+	}
+	dsp.FFT(g.spectrum)
+
+	for bin := range g.floor {
+		c := g.spectrum[bin]
+		power := real(c)*real(c) + imag(c)*imag(c)
+		if g.primed {
+			power = g.power[bin]*powerSmoothing + power*(1-powerSmoothing)
+		}
+		g.power[bin] = power
+
+		open := g.primed && power > g.floor[bin]*g.threshold
+		switch {
+		case !g.primed:
+			g.floor[bin] = power
+		case open:
+			g.floor[bin] += (power - g.floor[bin]) * floorRise
+		default:
+			g.floor[bin] += (power - g.floor[bin]) * floorTrack
+		}
+
+		target, coeff := g.floorGain, g.release
+		if open {
+			target, coeff = 1, g.attack
+		}
+		g.gain[bin] = target + (g.gain[bin]-target)*coeff
+
+		g.spectrum[bin] *= complex(g.gain[bin], 0)
+		if bin > 0 && bin < frameSize/2 {
+			g.spectrum[frameSize-bin] = complex(real(g.spectrum[bin]), -imag(g.spectrum[bin]))
+		}
+	}
+	g.primed = true
+	dsp.IFFT(g.spectrum)
+
+	for i := range g.overlap {
+		g.overlap[i] += real(g.spectrum[i]) * g.window[i]
+	}
+	for _, v := range g.overlap[:hopSize] {
+		g.output = append(g.output, clampInt16(v))
+	}
+	copy(g.overlap, g.overlap[hopSize:])
+	for i := hopSize; i < frameSize; i++ {
+		g.overlap[i] = 0
+	}
+}
+
+func clampInt16(v float64) int16 {
+	v = math.Round(v)
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return int16(v)
+}
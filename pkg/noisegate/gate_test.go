@@ -0,0 +1,53 @@
+package noisegate
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rms(samples []int16) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func TestGateKeepsLength(t *testing.T) {
+	g := New(16000, Options{})
+	for _, n := range []int{1, 100, 256, 333, 1600} {
+		assert.Len(t, g.Process(make([]int16, n)), n)
+	}
+}
+
+func TestGateAttenuatesSteadyNoise(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	noise := make([]int16, 32000)
+	for i := range noise {
+		noise[i] = int16(rng.NormFloat64() * 300)
+	}
+
+	out := New(16000, Options{ReductionDB: 18}).Process(noise)
+	// After the floor settled, the noise is close to the full reduction
+	assert.Less(t, rms(out[16000:]), rms(noise[16000:])*0.3)
+}
+
+func TestGatePassesToneOverNoise(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]int16, 48000)
+	for i := range samples {
+		v := rng.NormFloat64() * 300
+		if i >= 32000 {
+			v += 8000 * math.Sin(2*math.Pi*440*float64(i)/16000)
+		}
+		samples[i] = int16(v)
+	}
+
+	out := New(16000, Options{}).Process(samples)
+	// Skip the 32ms delay and the attack at the start of the tone
+	in, gated := samples[34000:], out[34512:]
+	assert.InDelta(t, rms(in), rms(gated), rms(in)*0.1)
+}
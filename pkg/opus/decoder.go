@@ -0,0 +1,69 @@
+//go:build opus
+
+package opus
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Supported reports whether this build can decode Opus
+const Supported = true
+
+// Decoder decodes the packets of one Opus stream. It keeps state between packets and
+// is not safe for concurrent use.
+type Decoder struct {
+	decoder  *C.OpusDecoder
+	channels int
+	pcm      []int16
+}
+
+// NewDecoder creates a decoder producing PCM16 at sampleRate, one of 8000, 12000,
+// 16000, 24000 or 48000. Streams with more channels are mixed down to channels.
+func NewDecoder(sampleRate int, channels int) (*Decoder, error) {
+	var status C.int
+	decoder := C.opus_decoder_create(C.opus_int32(sampleRate), C.int(channels), &status)
+	if status != C.OPUS_OK {
+		return nil, fmt.Errorf("failed to create opus decoder: %s", C.GoString(C.opus_strerror(status)))
+	}
+	return &Decoder{
+		decoder:  decoder,
+		channels: channels,
+		pcm:      make([]int16, sampleRate*maxFrameMs/1000*channels),
+	}, nil
+}
+
+// Decode decodes a single Opus packet to interleaved PCM16 samples
+func (d *Decoder) Decode(packet []byte) ([]int16, error) {
+	if d.decoder == nil {
+		return nil, fmt.Errorf("opus decoder closed")
+	}
+	if len(packet) == 0 {
+		return nil, fmt.Errorf("empty opus packet")
+	}
+
+	frames := C.opus_decode(d.decoder,
+		(*C.uchar)(unsafe.Pointer(&packet[0])), C.opus_int32(len(packet)),
+		(*C.opus_int16)(unsafe.Pointer(&d.pcm[0])), C.int(len(d.pcm)/d.channels), 0)
+	if frames < 0 {
+		return nil, fmt.Errorf("failed to decode opus packet: %s", C.GoString(C.opus_strerror(frames)))
+	}
+
+	samples := make([]int16, int(frames)*d.channels)
+	copy(samples, d.pcm)
+	return samples, nil
+}
+
+// Close releases the decoder
+func (d *Decoder) Close() {
+	if d.decoder != nil {
+		C.opus_decoder_destroy(d.decoder)
+		d.decoder = nil
+	}
+}
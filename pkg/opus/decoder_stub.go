@@ -0,0 +1,22 @@
+//go:build !opus
+
+package opus
+
+// Supported reports whether this build can decode Opus
+const Supported = false
+
+// Decoder is unavailable in builds without the opus tag
+type Decoder struct{}
+
+// NewDecoder returns ErrUnsupported, build with -tags opus to decode Opus
+func NewDecoder(sampleRate int, channels int) (*Decoder, error) {
+	return nil, ErrUnsupported
+}
+
+// Decode returns ErrUnsupported
+func (d *Decoder) Decode(packet []byte) ([]int16, error) {
+	return nil, ErrUnsupported
+}
+
+// Close does nothing
+func (d *Decoder) Close() {}
@@ -0,0 +1,12 @@
+// Package opus decodes Opus packets to PCM16 with libopus. The decoder needs cgo and
+// the libopus headers and is only built with -tags opus; other builds report
+// ErrUnsupported so the server still runs without the library.
+package opus
+
+import "errors"
+
+// ErrUnsupported is returned by NewDecoder in builds without the opus tag
+var ErrUnsupported = errors.New("opus decoding not available, build with -tags opus")
+
+// maxFrameMs is the longest audio a single Opus packet can carry
+const maxFrameMs = 120
@@ -0,0 +1,62 @@
+// Package ratelimit provides the token bucket behind the per-client rate limits of
+// the server.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket refilled at rate tokens per second up to burst tokens. It
+// starts full and is safe for concurrent use.
+type Bucket struct {
+	rate  float64
+	burst float64
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket creates a full bucket
+func NewBucket(rate, burst float64) *Bucket {
+	return &Bucket{rate: rate, burst: burst, tokens: burst}
+}
+
+// refill adds the tokens accumulated since the last call, the caller holds the mutex
+func (b *Bucket) refill(now time.Time) {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	if b.last.IsZero() || now.After(b.last) {
+		b.last = now
+	}
+}
+
+// Take removes cost tokens when the bucket holds them. Otherwise it takes nothing and
+// returns how long until it will.
+func (b *Bucket) Take(cost float64, now time.Time) (bool, time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(now)
+	if b.tokens >= cost {
+		b.tokens -= cost
+		return true, 0
+	}
+	if b.rate <= 0 {
+		return false, 0
+	}
+	wait := (min(cost, b.burst) - b.tokens) / b.rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// Full reports whether the bucket has refilled completely, i.e. whether dropping it
+// would lose no state
+func (b *Bucket) Full(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(now)
+	return b.tokens >= b.burst
+}
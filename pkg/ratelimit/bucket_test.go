@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketAllowsBurstThenRate(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	bucket := NewBucket(10, 5)
+
+	for i := 0; i < 5; i++ {
+		ok, _ := bucket.Take(1, now)
+		assert.True(t, ok, "take %d within burst", i)
+	}
+	ok, retryAfter := bucket.Take(1, now)
+	assert.False(t, ok)
+	assert.Equal(t, 100*time.Millisecond, retryAfter)
+
+	ok, _ = bucket.Take(1, now.Add(100*time.Millisecond))
+	assert.True(t, ok)
+}
+
+func TestBucketRefillIsCappedAtBurst(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	bucket := NewBucket(1, 60)
+
+	ok, _ := bucket.Take(60, now)
+	assert.True(t, ok)
+	assert.False(t, bucket.Full(now.Add(30*time.Second)))
+	assert.True(t, bucket.Full(now.Add(time.Hour)))
+
+	ok, _ = bucket.Take(61, now.Add(time.Hour))
+	assert.False(t, ok, "cost above burst never fits")
+}
+
+func TestBucketFractionalCost(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	bucket := NewBucket(1, 1)
+
+	for i := 0; i < 10; i++ {
+		ok, _ := bucket.Take(0.1, now)
+		assert.True(t, ok)
+	}
+	ok, retryAfter := bucket.Take(0.5, now)
+	assert.False(t, ok)
+	assert.InDelta(t, 500*time.Millisecond, retryAfter, float64(time.Millisecond))
+}
@@ -49,7 +49,8 @@ func Resample48kTo16k(input *audio.IntBuffer) (*audio.IntBuffer, error) {
 	return output, nil
 }
 
-// Resample handles generic sample rate conversion
+// Resample converts a complete buffer to the target rate, with the windowed-sinc
+// resampler at medium quality for any ratio but the 48kHz to 16kHz fast path
 func Resample(input *audio.IntBuffer, targetRate int) (*audio.IntBuffer, error) {
 	if input == nil || input.Format == nil {
 		return nil, errors.New("invalid input buffer")
@@ -59,12 +60,32 @@ func Resample(input *audio.IntBuffer, targetRate int) (*audio.IntBuffer, error)
 		return input, nil
 	}
 
-	switch input.Format.SampleRate {
-	case 48000:
-		if targetRate == 16000 {
-			return Resample48kTo16k(input)
-		}
+	if input.Format.SampleRate == 48000 && targetRate == 16000 {
+		return Resample48kTo16k(input)
+	}
+	if input.Format.NumChannels > 1 {
+		return nil, errors.New("unsupported sample rate conversion of multichannel audio")
 	}
 
-	return nil, errors.New("unsupported sample rate conversion")
+	samples := make([]int16, len(input.Data))
+	for i, v := range input.Data {
+		samples[i] = clampInt16(float64(v))
+	}
+	resampled, err := ResampleInt16(samples, input.Format.SampleRate, targetRate, QualityMedium)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &audio.IntBuffer{
+		Data: make([]int, len(resampled)),
+		Format: &audio.Format{
+			NumChannels: input.Format.NumChannels,
+			SampleRate:  targetRate,
+		},
+		SourceBitDepth: input.SourceBitDepth,
+	}
+	for i, v := range resampled {
+		output.Data[i] = int(v)
+	}
+	return output, nil
 }
\ No newline at end of file
@@ -0,0 +1,215 @@
+package resampler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Quality trades CPU for stopband attenuation in the sinc resampler
+type Quality int
+
+const (
+	QualityLow    Quality = iota // 8 zero crossings, for speech where CPU matters most
+	QualityMedium                // 16 zero crossings, the default
+	QualityHigh                  // 32 zero crossings
+)
+
+// maxPhases bounds the precomputed filter table. Ratios needing more phases, from
+// unusual rates with a small common divisor, evaluate the filter per sample instead.
+const maxPhases = 1024
+
+// rolloff places the cutoff just below the Nyquist frequency of the slower rate
+const rolloff = 0.95
+
+// ParseQuality parses low, medium or high, an empty string meaning medium
+func ParseQuality(name string) (Quality, error) {
+	switch strings.ToLower(name) {
+	case "low":
+		return QualityLow, nil
+	case "", "medium":
+		return QualityMedium, nil
+	case "high":
+		return QualityHigh, nil
+	}
+	return QualityMedium, fmt.Errorf("unknown resample quality: %s", name)
+}
+
+func (q Quality) zeroCrossings() int {
+	switch q {
+	case QualityLow:
+		return 8
+	case QualityHigh:
+		return 32
+	}
+	return 16
+}
+
+// Resampler is a streaming polyphase windowed-sinc converter between two arbitrary
+// sample rates. It keeps the filter history between calls, so audio resampled chunk
+// by chunk has no discontinuities at chunk boundaries. It is not safe for concurrent use.
+type Resampler struct {
+	up, down int // output rate / input rate, reduced
+	halfTaps int // filter half length in input samples
+	cutoff   float64
+	table    [][]float64 // one filter per phase, nil when computed per sample
+	scratch  []float64
+
+	history []float64 // input samples from absolute index base
+	base    int64
+	next    int64 // absolute index of the next output sample
+}
+
+// NewResampler creates a resampler from one sample rate to another
+func NewResampler(fromRate, toRate int, quality Quality) (*Resampler, error) {
+	if fromRate <= 0 || toRate <= 0 {
+		return nil, errors.New("sample rates must be positive")
+	}
+	g := gcd(fromRate, toRate)
+	r := &Resampler{
+		up:   toRate / g,
+		down: fromRate / g,
+	}
+
+	// Downsampling narrows the filter to the output band and widens it in input samples
+	scale := math.Min(1, float64(toRate)/float64(fromRate))
+	r.cutoff = scale * rolloff
+	r.halfTaps = int(math.Ceil(float64(quality.zeroCrossings()) / scale))
+	r.scratch = make([]float64, 2*r.halfTaps)
+
+	if r.up <= maxPhases {
+		r.table = make([][]float64, r.up)
+		for phase := range r.table {
+			r.table[phase] = make([]float64, 2*r.halfTaps)
+			r.fillFilter(r.table[phase], phase)
+		}
+	}
+
+	// Start with silence before the first sample so output is aligned with the input
+	r.history = make([]float64, r.halfTaps)
+	r.base = -int64(r.halfTaps)
+	return r, nil
+}
+
+// fillFilter computes the taps of a phase, tap k weighting input sample center-halfTaps+1+k
+func (r *Resampler) fillFilter(taps []float64, phase int) {
+	frac := float64(phase) / float64(r.up)
+	var sum float64
+	for k := range taps {
+		t := float64(k-r.halfTaps+1) - frac
+		taps[k] = r.cutoff * sinc(r.cutoff*t) * blackman(t/float64(r.halfTaps))
+		sum += taps[k]
+	}
+	// Unity gain at DC
+	if sum != 0 {
+		for k := range taps {
+			taps[k] /= sum
+		}
+	}
+}
+
+// Process resamples the next chunk of a stream. Output lags the input by the filter
+// half length; Flush returns the remainder at the end of the stream.
+func (r *Resampler) Process(samples []int16) []int16 {
+	for _, s := range samples {
+		r.history = append(r.history, float64(s))
+	}
+	return r.drain()
+}
+
+// Flush pads the stream with silence and returns the last output samples. The
+// resampler can be reused for a new stream afterwards.
+func (r *Resampler) Flush() []int16 {
+	// The padding completes the window of every output sample before the end of the
+	// input, and of none after it
+	r.history = append(r.history, make([]float64, r.halfTaps)...)
+	out := r.drain()
+
+	r.history = make([]float64, r.halfTaps)
+	r.base = -int64(r.halfTaps)
+	r.next = 0
+	return out
+}
+
+// drain produces every output sample whose filter window is fully buffered
+func (r *Resampler) drain() []int16 {
+	var out []int16
+	available := r.base + int64(len(r.history))
+	for {
+		pos := r.next * int64(r.down)
+		center := pos / int64(r.up)
+		if center+int64(r.halfTaps) >= available {
+			break
+		}
+		phase := int(pos % int64(r.up))
+
+		taps := r.scratch
+		if r.table != nil {
+			taps = r.table[phase]
+		} else {
+			r.fillFilter(taps, phase)
+		}
+
+		start := int(center - int64(r.halfTaps) + 1 - r.base)
+		var acc float64
+		for k, tap := range taps {
+			acc += r.history[start+k] * tap
+		}
+		out = append(out, clampInt16(acc))
+		r.next++
+	}
+
+	// Keep only the history the next output sample needs
+	center := r.next * int64(r.down) / int64(r.up)
+	if drop := int(center - int64(r.halfTaps) + 1 - r.base); drop > 0 {
+		r.history = append(r.history[:0], r.history[drop:]...)
+		r.base += int64(drop)
+	}
+	return out
+}
+
+// ResampleInt16 converts a complete signal between two sample rates
+func ResampleInt16(samples []int16, fromRate, toRate int, quality Quality) ([]int16, error) {
+	if fromRate == toRate {
+		return samples, nil
+	}
+	r, err := NewResampler(fromRate, toRate, quality)
+	if err != nil {
+		return nil, err
+	}
+	return append(r.Process(samples), r.Flush()...), nil
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman is the Blackman window over [-1, 1]
+func blackman(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+}
+
+func clampInt16(v float64) int16 {
+	v = math.Round(v)
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return int16(v)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
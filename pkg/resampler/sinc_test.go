@@ -0,0 +1,80 @@
+package resampler
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sine(freq float64, rate int, n int) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(10000 * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return samples
+}
+
+// rms of the signal, skipping the filter transients at both ends
+func rms(samples []int16) float64 {
+	trim := len(samples) / 10
+	var sum float64
+	for _, s := range samples[trim : len(samples)-trim] {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)-2*trim))
+}
+
+func TestResampleInt16Length(t *testing.T) {
+	for _, rate := range []int{8000, 11025, 22050, 24000, 32000, 44056, 44100, 48000} {
+		out, err := ResampleInt16(make([]int16, rate), rate, 16000, QualityMedium)
+		require.NoError(t, err)
+		assert.Equal(t, 16000, len(out), "from %d", rate)
+	}
+}
+
+func TestResampleKeepsPassband(t *testing.T) {
+	for _, rate := range []int{8000, 22050, 44100} {
+		in := sine(1000, rate, rate/2)
+		out, err := ResampleInt16(in, rate, 16000, QualityMedium)
+		require.NoError(t, err)
+		assert.InDelta(t, rms(in), rms(out), rms(in)*0.02, "from %d", rate)
+	}
+}
+
+func TestResampleRejectsAliases(t *testing.T) {
+	// 12kHz is above the 8kHz Nyquist frequency of the output and must not fold back
+	in := sine(12000, 44100, 22050)
+	for _, quality := range []Quality{QualityLow, QualityMedium, QualityHigh} {
+		out, err := ResampleInt16(in, 44100, 16000, quality)
+		require.NoError(t, err)
+		assert.Less(t, rms(out), rms(in)*0.01, "quality %d", quality)
+	}
+}
+
+func TestStreamingMatchesOneShot(t *testing.T) {
+	in := sine(440, 44100, 44100)
+	want, err := ResampleInt16(in, 44100, 16000, QualityMedium)
+	require.NoError(t, err)
+
+	r, err := NewResampler(44100, 16000, QualityMedium)
+	require.NoError(t, err)
+	var got []int16
+	for i := 0; i < len(in); i += 441 {
+		got = append(got, r.Process(in[i:min(i+441, len(in))])...)
+	}
+	got = append(got, r.Flush()...)
+	assert.Equal(t, want, got)
+}
+
+func TestParseQuality(t *testing.T) {
+	q, err := ParseQuality("")
+	require.NoError(t, err)
+	assert.Equal(t, QualityMedium, q)
+	q, err = ParseQuality("High")
+	require.NoError(t, err)
+	assert.Equal(t, QualityHigh, q)
+	_, err = ParseQuality("best")
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+// Package retention decides which saved files to remove under a retention policy:
+// files older than a maximum age, the oldest files of a group (a session) over its
+// quota, and the oldest files overall beyond a file count or total size. Planning is
+// separate from removal so callers can apply it to a directory tree or a bucket alike.
+package retention
+
+import (
+	"sort"
+	"time"
+)
+
+// Reasons a file is removed, in the order the limits are applied
+const (
+	ReasonAge   = "age"   // older than Policy.MaxAge
+	ReasonQuota = "quota" // its group is over Policy.MaxGroupBytes
+	ReasonCount = "count" // beyond Policy.MaxFiles
+	ReasonTotal = "total" // over Policy.MaxTotalBytes
+)
+
+// Policy limits the saved files; zero limits are off
+type Policy struct {
+	MaxAge        time.Duration
+	MaxTotalBytes int64
+	MaxGroupBytes int64
+	MaxFiles      int
+}
+
+// Enabled reports whether the policy has a limit
+func (p Policy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxTotalBytes > 0 || p.MaxGroupBytes > 0 || p.MaxFiles > 0
+}
+
+// File is a saved file
+type File struct {
+	Group   string // files of a group share its quota
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Removal is a file to remove and the limit it exceeds
+type Removal struct {
+	File
+	Reason string
+}
+
+// Plan returns the files to remove so that the rest stay within the policy at now,
+// oldest first within each limit
+func Plan(files []File, policy Policy, now time.Time) []Removal {
+	kept := make([]File, len(files))
+	copy(kept, files)
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].ModTime.Before(kept[j].ModTime) })
+
+	var removals []Removal
+	remove := func(keep func(File) bool, reason string) {
+		rest := kept[:0]
+		for _, f := range kept {
+			if keep(f) {
+				rest = append(rest, f)
+			} else {
+				removals = append(removals, Removal{File: f, Reason: reason})
+			}
+		}
+		kept = rest
+	}
+
+	if policy.MaxAge > 0 {
+		cutoff := now.Add(-policy.MaxAge)
+		remove(func(f File) bool { return !f.ModTime.Before(cutoff) }, ReasonAge)
+	}
+
+	if policy.MaxGroupBytes > 0 {
+		// Walking newest first, a group keeps files until its quota is used
+		used := make(map[string]int64)
+		over := make(map[int]bool)
+		for i := len(kept) - 1; i >= 0; i-- {
+			used[kept[i].Group] += kept[i].Size
+			over[i] = used[kept[i].Group] > policy.MaxGroupBytes
+		}
+		i := 0
+		remove(func(File) bool { i++; return !over[i-1] }, ReasonQuota)
+	}
+
+	if policy.MaxFiles > 0 && len(kept) > policy.MaxFiles {
+		excess := len(kept) - policy.MaxFiles
+		i := 0
+		remove(func(File) bool { i++; return i > excess }, ReasonCount)
+	}
+
+	if policy.MaxTotalBytes > 0 {
+		var total int64
+		for _, f := range kept {
+			total += f.Size
+		}
+		remove(func(f File) bool {
+			if total <= policy.MaxTotalBytes {
+				return true
+			}
+			total -= f.Size
+			return false
+		}, ReasonTotal)
+	}
+
+	return removals
+}
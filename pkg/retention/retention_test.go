@@ -0,0 +1,61 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+func file(group, path string, size int64, age time.Duration) File {
+	return File{Group: group, Path: path, Size: size, ModTime: now.Add(-age)}
+}
+
+func paths(removals []Removal) map[string]string {
+	reasons := make(map[string]string)
+	for _, r := range removals {
+		reasons[r.Path] = r.Reason
+	}
+	return reasons
+}
+
+func TestPlan(t *testing.T) {
+	files := []File{
+		file("a", "a/3", 100, 1*time.Minute),
+		file("a", "a/1", 100, 3*time.Hour),
+		file("b", "b/1", 300, 2*time.Hour),
+		file("a", "a/2", 100, 30*time.Minute),
+		file("b", "b/2", 300, 10*time.Minute),
+	}
+
+	assert.Empty(t, Plan(files, Policy{}, now))
+
+	assert.Equal(t, map[string]string{"a/1": ReasonAge, "b/1": ReasonAge},
+		paths(Plan(files, Policy{MaxAge: time.Hour}, now)))
+
+	assert.Equal(t, map[string]string{"b/1": ReasonQuota},
+		paths(Plan(files, Policy{MaxGroupBytes: 350}, now)))
+
+	assert.Equal(t, map[string]string{"a/1": ReasonCount, "b/1": ReasonCount},
+		paths(Plan(files, Policy{MaxFiles: 3}, now)))
+
+	// The oldest files go first until the rest fits
+	assert.Equal(t, map[string]string{"a/1": ReasonTotal, "b/1": ReasonTotal, "a/2": ReasonTotal},
+		paths(Plan(files, Policy{MaxTotalBytes: 450}, now)))
+
+	// Limits apply in order, each to the files the previous ones kept
+	removals := Plan(files, Policy{MaxAge: 150 * time.Minute, MaxGroupBytes: 300, MaxTotalBytes: 300}, now)
+	assert.Equal(t, []Removal{
+		{File: files[1], Reason: ReasonAge},
+		{File: files[2], Reason: ReasonQuota},
+		{File: files[3], Reason: ReasonTotal},
+		{File: files[4], Reason: ReasonTotal},
+	}, removals)
+}
+
+func TestPolicyEnabled(t *testing.T) {
+	assert.False(t, Policy{}.Enabled())
+	assert.True(t, Policy{MaxFiles: 1}.Enabled())
+}
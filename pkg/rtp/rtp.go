@@ -0,0 +1,66 @@
+// Package rtp parses RTP packets (RFC 3550) carrying telephony audio, as a PBX forks
+// the media of a call.
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Static payload types of G.711 audio (RFC 3551)
+const (
+	PayloadTypePCMU = 0
+	PayloadTypePCMA = 8
+)
+
+const headerSize = 12
+
+var (
+	errShortPacket = errors.New("rtp: packet too short")
+	errVersion     = errors.New("rtp: unsupported version")
+)
+
+// Packet is a parsed RTP packet. Payload aliases the parsed buffer.
+type Packet struct {
+	PayloadType    uint8
+	Marker         bool
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	Payload        []byte
+}
+
+// Parse parses an RTP packet, skipping its CSRC list, header extension and padding
+func Parse(data []byte) (*Packet, error) {
+	if len(data) < headerSize {
+		return nil, errShortPacket
+	}
+	if data[0]>>6 != 2 {
+		return nil, errVersion
+	}
+
+	p := &Packet{
+		Marker:         data[1]&0x80 != 0,
+		PayloadType:    data[1] & 0x7f,
+		SequenceNumber: binary.BigEndian.Uint16(data[2:4]),
+		Timestamp:      binary.BigEndian.Uint32(data[4:8]),
+		SSRC:           binary.BigEndian.Uint32(data[8:12]),
+	}
+
+	offset := headerSize + 4*int(data[0]&0x0f)
+	if data[0]&0x10 != 0 {
+		if len(data) < offset+4 {
+			return nil, errShortPacket
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:offset+4]))
+	}
+	end := len(data)
+	if data[0]&0x20 != 0 && end > 0 {
+		end -= int(data[end-1])
+	}
+	if offset > end {
+		return nil, errShortPacket
+	}
+	p.Payload = data[offset:end]
+	return p, nil
+}
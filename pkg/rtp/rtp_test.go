@@ -0,0 +1,55 @@
+package rtp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	data := []byte{
+		0x80, 0x88, 0x01, 0x02, // version 2, marker, PCMA, sequence 258
+		0x00, 0x00, 0x00, 0xa0, // timestamp 160
+		0xde, 0xad, 0xbe, 0xef, // SSRC
+		0xd5, 0xd5, 0xd5,
+	}
+	p, err := Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, &Packet{
+		PayloadType:    PayloadTypePCMA,
+		Marker:         true,
+		SequenceNumber: 258,
+		Timestamp:      160,
+		SSRC:           0xdeadbeef,
+		Payload:        []byte{0xd5, 0xd5, 0xd5},
+	}, p)
+}
+
+func TestParseSkipsCSRCExtensionAndPadding(t *testing.T) {
+	data := []byte{
+		0xb1, 0x00, 0x00, 0x01, // version 2, padding, extension, one CSRC, PCMU
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x02, // CSRC
+		0xbe, 0xde, 0x00, 0x01, // extension of one word
+		0x00, 0x00, 0x00, 0x00,
+		0xff, 0x7f, // payload
+		0x00, 0x02, // padding of 2 bytes
+	}
+	p, err := Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(PayloadTypePCMU), p.PayloadType)
+	assert.Equal(t, []byte{0xff, 0x7f}, p.Payload)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte{0x80, 0x00})
+	assert.Error(t, err)
+
+	_, err = Parse(make([]byte, 12))
+	assert.Error(t, err, "version 0")
+
+	_, err = Parse([]byte{0x8f, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	assert.Error(t, err, "CSRC count past the end")
+}
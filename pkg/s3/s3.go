@@ -0,0 +1,396 @@
+// Package s3 is a minimal client of S3-compatible object storage (AWS S3, MinIO, Ceph
+// RGW): putting, reading, listing and deleting objects of one bucket, with requests
+// signed by AWS Signature Version 4.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// emptySHA256 is the payload hash of requests without a body
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// ErrNotFound is returned for missing objects
+var ErrNotFound = fmt.Errorf("s3: object not found")
+
+// Options of a client
+type Options struct {
+	Endpoint        string // e.g. https://s3.us-east-1.amazonaws.com or http://minio:9000
+	Region          string // us-east-1 when empty
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // of temporary credentials
+	// Address the bucket in the path (http://host/bucket/key) rather than the host
+	// (http://bucket.host/key), as MinIO deployments usually need
+	PathStyle bool
+	Timeout   time.Duration // of a request, 30s when 0
+}
+
+// Client of a bucket, safe for concurrent use
+type Client struct {
+	opts     Options
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// New creates a client of opts.Bucket
+func New(opts Options) (*Client, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("s3: bucket is required")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(opts.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", opts.Endpoint)
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	return &Client{opts: opts, endpoint: endpoint, client: &http.Client{Timeout: opts.Timeout}, now: time.Now}, nil
+}
+
+// Object describes a stored object
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// objectURL returns the URL of a key, or of the bucket when key is empty
+func (c *Client) objectURL(key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	base := strings.TrimSuffix(c.endpoint.EscapedPath(), "/")
+	if c.opts.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.opts.Bucket + "/" + key
+		u.RawPath = base + "/" + escapePath(c.opts.Bucket) + "/" + escapePath(key)
+	} else {
+		u.Host = c.opts.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+		u.RawPath = base + "/" + escapePath(key)
+	}
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// do sends a signed request, returning the response when its status is 2xx
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.ContentLength = int64(len(body))
+	c.sign(req, body)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var apiErr struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(data, &apiErr) == nil && apiErr.Code != "" {
+		return nil, fmt.Errorf("s3: %s %s: %s: %s", method, key, apiErr.Code, apiErr.Message)
+	}
+	return nil, fmt.Errorf("s3: %s %s: status %d", method, key, resp.StatusCode)
+}
+
+// Put writes an object
+func (c *Client) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := c.do(ctx, http.MethodPut, key, nil, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Head returns the size and modification time of an object
+func (c *Client) Head(ctx context.Context, key string) (Object, error) {
+	resp, err := c.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return Object{}, err
+	}
+	resp.Body.Close()
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return Object{Key: key, Size: resp.ContentLength, LastModified: modified}, nil
+}
+
+// Get reads length bytes of an object from offset, or the rest of it when length is
+// negative. The caller closes the returned body.
+func (c *Client) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	if length >= 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := c.do(ctx, http.MethodGet, key, nil, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes an object; missing objects are not an error
+func (c *Client) Delete(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List returns the objects whose keys start with prefix, in key order
+func (c *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3: invalid list response: %v", err)
+		}
+		for _, content := range result.Contents {
+			objects = append(objects, Object{Key: content.Key, Size: content.Size, LastModified: content.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// readAhead is the size of the ranged reads of an ObjectReader
+const readAhead = 1 << 20
+
+// ObjectReader reads an object at random offsets with ranged GETs of at least 1MiB,
+// so sequential reads of small buffers do not each cost a request
+type ObjectReader struct {
+	client *Client
+	ctx    context.Context
+	object Object
+
+	mutex  sync.Mutex
+	offset int64 // of buf in the object
+	buf    []byte
+}
+
+// NewReader returns a reader of an object, reading its size first
+func (c *Client) NewReader(ctx context.Context, key string) (*ObjectReader, error) {
+	object, err := c.Head(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectReader{client: c, ctx: ctx, object: object}, nil
+}
+
+// Object returns the size and modification time of the object
+func (r *ObjectReader) Object() Object {
+	return r.object
+}
+
+// ReadAt implements io.ReaderAt
+func (r *ObjectReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	read := 0
+	for read < len(p) {
+		pos := off + int64(read)
+		if pos >= r.object.Size {
+			return read, io.EOF
+		}
+		if pos < r.offset || pos >= r.offset+int64(len(r.buf)) {
+			length := min(max(int64(len(p)-read), readAhead), r.object.Size-pos)
+			body, err := r.client.Get(r.ctx, r.object.Key, pos, length)
+			if err != nil {
+				return read, err
+			}
+			buf, err := io.ReadAll(body)
+			body.Close()
+			if err != nil {
+				return read, err
+			}
+			if len(buf) == 0 {
+				return read, io.ErrUnexpectedEOF
+			}
+			r.offset, r.buf = pos, buf
+		}
+		read += copy(p[read:], r.buf[pos-r.offset:])
+	}
+	return read, nil
+}
+
+// Close releases the buffered data
+func (r *ObjectReader) Close() error {
+	r.mutex.Lock()
+	r.buf = nil
+	r.mutex.Unlock()
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 Authorization header to a request
+func (c *Client) sign(req *http.Request, body []byte) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := emptySHA256
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.opts.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.opts.SessionToken)
+	}
+
+	scope := date + "/" + c.opts.Region + "/s3/aws4_request"
+	signedHeaders, canonicalHeaders := canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.opts.SecretAccessKey), date)
+	key = hmacSHA256(key, c.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.opts.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalHeaders returns the signed header names and the canonical headers: host,
+// the Content-Type, Range and x-amz-* headers
+func canonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.Join(strings.Fields(headers[name]), " ") + "\n")
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// canonicalQuery encodes a query string sorted by name, with spaces as %20
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, escape(name)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath URI-encodes each segment of a key, keeping the slashes
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escape URI-encodes everything but the unreserved characters, as SigV4 requires
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z') || ('0' <= ch && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
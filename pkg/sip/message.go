@@ -0,0 +1,217 @@
+// Package sip parses and writes the SIP messages (RFC 3261) and SDP bodies (RFC 4566)
+// of a user agent that only answers calls: enough for a PBX to fork the audio of a
+// call to it over UDP, without registration, authentication or transactions beyond
+// answering each request.
+package sip
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Methods of the requests a forked call sends
+const (
+	MethodInvite  = "INVITE"
+	MethodAck     = "ACK"
+	MethodBye     = "BYE"
+	MethodCancel  = "CANCEL"
+	MethodOptions = "OPTIONS"
+)
+
+// compactHeaders maps the compact forms of header names to their full names
+var compactHeaders = map[string]string{
+	"v": "Via",
+	"f": "From",
+	"t": "To",
+	"i": "Call-ID",
+	"m": "Contact",
+	"l": "Content-Length",
+	"c": "Content-Type",
+	"k": "Supported",
+}
+
+// Header is a header field of a message
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Message is a SIP request or response
+type Message struct {
+	Method     string // requests only
+	RequestURI string // requests only
+	StatusCode int    // responses only
+	Reason     string // responses only
+	Headers    []Header
+	Body       []byte
+}
+
+// Parse parses a SIP message received in a datagram
+func Parse(data []byte) (*Message, error) {
+	head, body, found := bytes.Cut(data, []byte("\r\n\r\n"))
+	if !found {
+		head, body, _ = bytes.Cut(data, []byte("\n\n"))
+	}
+	lines := strings.Split(strings.ReplaceAll(string(head), "\r\n", "\n"), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return nil, fmt.Errorf("sip: empty message")
+	}
+
+	m := &Message{}
+	startLine := strings.SplitN(lines[0], " ", 3)
+	if len(startLine) != 3 {
+		return nil, fmt.Errorf("sip: invalid start line %q", lines[0])
+	}
+	if strings.HasPrefix(startLine[0], "SIP/") {
+		code, err := strconv.Atoi(startLine[1])
+		if err != nil {
+			return nil, fmt.Errorf("sip: invalid status code %q", startLine[1])
+		}
+		m.StatusCode, m.Reason = code, startLine[2]
+	} else {
+		if !strings.HasPrefix(startLine[2], "SIP/") {
+			return nil, fmt.Errorf("sip: invalid start line %q", lines[0])
+		}
+		m.Method, m.RequestURI = startLine[0], startLine[1]
+	}
+
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		// Folded continuation of the previous header
+		if (line[0] == ' ' || line[0] == '\t') && len(m.Headers) > 0 {
+			m.Headers[len(m.Headers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("sip: invalid header %q", line)
+		}
+		m.Add(canonicalName(strings.TrimSpace(name)), strings.TrimSpace(value))
+	}
+
+	if length := m.Get("Content-Length"); length != "" {
+		n, err := strconv.Atoi(length)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("sip: invalid Content-Length %q", length)
+		}
+		if n < len(body) {
+			body = body[:n]
+		}
+	}
+	m.Body = body
+	return m, nil
+}
+
+// canonicalName expands compact header names and matches the case of full names
+func canonicalName(name string) string {
+	if full, ok := compactHeaders[strings.ToLower(name)]; ok {
+		return full
+	}
+	switch lower := strings.ToLower(name); lower {
+	case "call-id":
+		return "Call-ID"
+	case "cseq":
+		return "CSeq"
+	default:
+		parts := strings.Split(lower, "-")
+		for i, part := range parts {
+			if part != "" {
+				parts[i] = strings.ToUpper(part[:1]) + part[1:]
+			}
+		}
+		return strings.Join(parts, "-")
+	}
+}
+
+// IsRequest reports whether the message is a request
+func (m *Message) IsRequest() bool {
+	return m.Method != ""
+}
+
+// Get returns the first value of a header, empty when missing
+func (m *Message) Get(name string) string {
+	name = canonicalName(name)
+	for _, h := range m.Headers {
+		if h.Name == name {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// Values returns every value of a header, in order
+func (m *Message) Values(name string) []string {
+	name = canonicalName(name)
+	var values []string
+	for _, h := range m.Headers {
+		if h.Name == name {
+			values = append(values, h.Value)
+		}
+	}
+	return values
+}
+
+// Add appends a header
+func (m *Message) Add(name, value string) {
+	m.Headers = append(m.Headers, Header{Name: name, Value: value})
+}
+
+// Set replaces the values of a header with value
+func (m *Message) Set(name, value string) {
+	name = canonicalName(name)
+	headers := m.Headers[:0]
+	for _, h := range m.Headers {
+		if h.Name != name {
+			headers = append(headers, h)
+		}
+	}
+	m.Headers = append(headers, Header{Name: name, Value: value})
+}
+
+// CSeq returns the sequence number and method of the CSeq header
+func (m *Message) CSeq() (int, string) {
+	number, method, _ := strings.Cut(m.Get("CSeq"), " ")
+	n, _ := strconv.Atoi(number)
+	return n, strings.TrimSpace(method)
+}
+
+// Bytes writes the message, setting Content-Length to the body
+func (m *Message) Bytes() []byte {
+	var b bytes.Buffer
+	if m.IsRequest() {
+		fmt.Fprintf(&b, "%s %s SIP/2.0\r\n", m.Method, m.RequestURI)
+	} else {
+		fmt.Fprintf(&b, "SIP/2.0 %d %s\r\n", m.StatusCode, m.Reason)
+	}
+	for _, h := range m.Headers {
+		if h.Name == "Content-Length" {
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\r\n", h.Name, h.Value)
+	}
+	fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n", len(m.Body))
+	b.Write(m.Body)
+	return b.Bytes()
+}
+
+// NewResponse returns a response to a request, with the Via, From, To, Call-ID and
+// CSeq headers it must echo. A toTag is added to the To header unless it has one.
+func NewResponse(req *Message, code int, reason string, toTag string) *Message {
+	res := &Message{StatusCode: code, Reason: reason}
+	for _, via := range req.Values("Via") {
+		res.Add("Via", via)
+	}
+	res.Add("From", req.Get("From"))
+	to := req.Get("To")
+	if toTag != "" && !strings.Contains(to, ";tag=") {
+		to += ";tag=" + toTag
+	}
+	res.Add("To", to)
+	res.Add("Call-ID", req.Get("Call-ID"))
+	res.Add("CSeq", req.Get("CSeq"))
+	return res
+}
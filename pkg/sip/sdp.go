@@ -0,0 +1,140 @@
+package sip
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Media is an audio stream of an SDP offer
+type Media struct {
+	Port         int
+	Protocol     string
+	PayloadTypes []int
+	Encodings    map[int]string // encoding names of the rtpmap attributes, e.g. "PCMU"
+	Address      string         // of the media, or of the session when the media has none
+}
+
+// Encoding returns the encoding name of a payload type: from its rtpmap attribute, or
+// the static assignment of PCMU and PCMA
+func (m *Media) Encoding(payloadType int) string {
+	if name, ok := m.Encodings[payloadType]; ok {
+		return name
+	}
+	switch payloadType {
+	case 0:
+		return "PCMU"
+	case 8:
+		return "PCMA"
+	}
+	return ""
+}
+
+// ParseOffer returns the audio streams of an SDP offer, in order. Other media are left
+// out; ports of 0 mark streams the offerer disabled.
+func ParseOffer(body []byte) ([]Media, error) {
+	var sessionAddress string
+	var media []Media
+	for _, line := range strings.Split(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n") {
+		kind, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		var current *Media
+		if len(media) > 0 {
+			current = &media[len(media)-1]
+		}
+
+		switch kind {
+		case "c":
+			fields := strings.Fields(value)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("sdp: invalid connection %q", value)
+			}
+			// Multicast addresses carry a TTL after a slash
+			address, _, _ := strings.Cut(fields[2], "/")
+			if current != nil {
+				current.Address = address
+			} else {
+				sessionAddress = address
+			}
+		case "m":
+			fields := strings.Fields(value)
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("sdp: invalid media %q", value)
+			}
+			if fields[0] != "audio" {
+				// Attributes of other media are skipped with it
+				media = append(media, Media{Port: -1})
+				continue
+			}
+			portField, _, _ := strings.Cut(fields[1], "/")
+			port, err := strconv.Atoi(portField)
+			if err != nil {
+				return nil, fmt.Errorf("sdp: invalid media port %q", fields[1])
+			}
+			m := Media{Port: port, Protocol: fields[2], Encodings: map[int]string{}}
+			for _, format := range fields[3:] {
+				if pt, err := strconv.Atoi(format); err == nil {
+					m.PayloadTypes = append(m.PayloadTypes, pt)
+				}
+			}
+			media = append(media, m)
+		case "a":
+			if current == nil || current.Port < 0 {
+				continue
+			}
+			if rtpmap, ok := strings.CutPrefix(value, "rtpmap:"); ok {
+				format, encoding, _ := strings.Cut(rtpmap, " ")
+				pt, err := strconv.Atoi(format)
+				name, _, _ := strings.Cut(encoding, "/")
+				if err == nil && name != "" {
+					current.Encodings[pt] = strings.ToUpper(name)
+				}
+			}
+		}
+	}
+
+	audio := media[:0]
+	for _, m := range media {
+		if m.Port < 0 {
+			continue
+		}
+		if m.Address == "" {
+			m.Address = sessionAddress
+		}
+		audio = append(audio, m)
+	}
+	if len(audio) == 0 {
+		return nil, fmt.Errorf("sdp: no audio stream offered")
+	}
+	return audio, nil
+}
+
+// AnswerMedia is an audio stream of an SDP answer, received at Port with PayloadType
+// of Encoding; a Port of 0 rejects the offered stream
+type AnswerMedia struct {
+	Port        int
+	PayloadType int
+	Encoding    string
+}
+
+// Answer returns the SDP answer of a receive-only user agent at address, with a
+// stream for every stream of the offer
+func Answer(address string, sessionID int64, media []AnswerMedia) []byte {
+	var b strings.Builder
+	b.WriteString("v=0\r\n")
+	fmt.Fprintf(&b, "o=stt %d %d IN IP4 %s\r\n", sessionID, sessionID, address)
+	b.WriteString("s=stt\r\n")
+	fmt.Fprintf(&b, "c=IN IP4 %s\r\n", address)
+	b.WriteString("t=0 0\r\n")
+	for _, m := range media {
+		fmt.Fprintf(&b, "m=audio %d RTP/AVP %d\r\n", m.Port, m.PayloadType)
+		if m.Port == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "a=rtpmap:%d %s/8000\r\n", m.PayloadType, m.Encoding)
+		b.WriteString("a=recvonly\r\n")
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,114 @@
+package sip
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const offer = "v=0\r\n" +
+	"o=pbx 1 1 IN IP4 10.0.0.1\r\n" +
+	"s=call\r\n" +
+	"c=IN IP4 10.0.0.1\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 40000 RTP/AVP 8 0 101\r\n" +
+	"a=rtpmap:101 telephone-event/8000\r\n" +
+	"m=video 40002 RTP/AVP 96\r\n" +
+	"a=rtpmap:96 H264/90000\r\n" +
+	"m=audio 40004 RTP/AVP 0\r\n" +
+	"c=IN IP4 10.0.0.2\r\n" +
+	"a=rtpmap:0 pcmu/8000\r\n"
+
+var invite = "INVITE sip:stt@10.0.0.5 SIP/2.0\r\n" +
+	"Via: SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK1\r\n" +
+	"v: SIP/2.0/UDP 10.0.0.9:5060;branch=z9hG4bK0\r\n" +
+	"From: <sip:pbx@10.0.0.1>;tag=abc\r\n" +
+	"To: <sip:stt@10.0.0.5>\r\n" +
+	"i: call-1@10.0.0.1\r\n" +
+	"CSEQ: 1 INVITE\r\n" +
+	"Content-Type: application/sdp\r\n" +
+	"Content-Length: " + strconv.Itoa(len(offer)) + "\r\n\r\n" + offer
+
+func TestParseRequest(t *testing.T) {
+	m, err := Parse([]byte(invite + "trailing garbage"))
+	require.NoError(t, err)
+	assert.True(t, m.IsRequest())
+	assert.Equal(t, MethodInvite, m.Method)
+	assert.Equal(t, "sip:stt@10.0.0.5", m.RequestURI)
+	assert.Equal(t, "call-1@10.0.0.1", m.Get("Call-ID"))
+	assert.Len(t, m.Values("via"), 2)
+	number, method := m.CSeq()
+	assert.Equal(t, 1, number)
+	assert.Equal(t, MethodInvite, method)
+	assert.Equal(t, offer, string(m.Body))
+}
+
+func TestParseResponse(t *testing.T) {
+	m, err := Parse([]byte("SIP/2.0 200 OK\r\nCall-ID: x\r\n\r\n"))
+	require.NoError(t, err)
+	assert.False(t, m.IsRequest())
+	assert.Equal(t, 200, m.StatusCode)
+	assert.Equal(t, "OK", m.Reason)
+
+	_, err = Parse([]byte("hello\r\n\r\n"))
+	assert.Error(t, err)
+}
+
+func TestNewResponse(t *testing.T) {
+	req, err := Parse([]byte(invite))
+	require.NoError(t, err)
+
+	res := NewResponse(req, 200, "OK", "t1")
+	res.Add("Content-Type", "application/sdp")
+	res.Body = []byte("v=0\r\n")
+	assert.Equal(t, "SIP/2.0 200 OK\r\n"+
+		"Via: SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK1\r\n"+
+		"Via: SIP/2.0/UDP 10.0.0.9:5060;branch=z9hG4bK0\r\n"+
+		"From: <sip:pbx@10.0.0.1>;tag=abc\r\n"+
+		"To: <sip:stt@10.0.0.5>;tag=t1\r\n"+
+		"Call-ID: call-1@10.0.0.1\r\n"+
+		"CSeq: 1 INVITE\r\n"+
+		"Content-Type: application/sdp\r\n"+
+		"Content-Length: 5\r\n\r\nv=0\r\n", string(res.Bytes()))
+
+	parsed, err := Parse(res.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, "<sip:stt@10.0.0.5>;tag=t1", parsed.Get("To"))
+}
+
+func TestParseOffer(t *testing.T) {
+	media, err := ParseOffer([]byte(offer))
+	require.NoError(t, err)
+	require.Len(t, media, 2)
+
+	assert.Equal(t, 40000, media[0].Port)
+	assert.Equal(t, "10.0.0.1", media[0].Address)
+	assert.Equal(t, []int{8, 0, 101}, media[0].PayloadTypes)
+	assert.Equal(t, "PCMA", media[0].Encoding(8))
+	assert.Equal(t, "TELEPHONE-EVENT", media[0].Encoding(101))
+
+	assert.Equal(t, 40004, media[1].Port)
+	assert.Equal(t, "10.0.0.2", media[1].Address)
+	assert.Equal(t, "PCMU", media[1].Encoding(0))
+
+	_, err = ParseOffer([]byte("v=0\r\nm=video 1 RTP/AVP 96\r\n"))
+	assert.Error(t, err)
+}
+
+func TestAnswer(t *testing.T) {
+	answer := Answer("10.0.0.5", 7, []AnswerMedia{
+		{Port: 20000, PayloadType: 8, Encoding: "PCMA"},
+		{Port: 0, PayloadType: 96},
+	})
+	assert.Equal(t, "v=0\r\n"+
+		"o=stt 7 7 IN IP4 10.0.0.5\r\n"+
+		"s=stt\r\n"+
+		"c=IN IP4 10.0.0.5\r\n"+
+		"t=0 0\r\n"+
+		"m=audio 20000 RTP/AVP 8\r\n"+
+		"a=rtpmap:8 PCMA/8000\r\n"+
+		"a=recvonly\r\n"+
+		"m=audio 0 RTP/AVP 96\r\n", string(answer))
+}
@@ -0,0 +1,59 @@
+package textformat
+
+import "unicode"
+
+// Direction is the base writing direction of a transcript
+type Direction string
+
+const (
+	LTR Direction = "ltr"
+	RTL Direction = "rtl"
+)
+
+// rightToLeftMark makes renderers lay out a line right to left even when it starts
+// with a Latin word, digits or punctuation
+const rightToLeftMark = "\u200f"
+
+// rtlTables are the scripts written right to left
+var rtlTables = []*unicode.RangeTable{
+	unicode.Arabic,
+	unicode.Hebrew,
+	unicode.Syriac,
+	unicode.Thaana,
+	unicode.Nko,
+	unicode.Samaritan,
+	unicode.Mandaic,
+	unicode.Adlam,
+}
+
+// DetectDirection returns the base direction of a text: right to left when most of its
+// letters are from a right-to-left script, like Arabic or Hebrew, so a Latin brand
+// name at the start of an Arabic sentence does not flip it. Texts without letters are
+// left to right.
+func DetectDirection(text string) Direction {
+	var rtl, ltr int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if unicode.In(r, rtlTables...) {
+			rtl++
+		} else {
+			ltr++
+		}
+	}
+	if rtl > ltr {
+		return RTL
+	}
+	return LTR
+}
+
+// markLine prefixes a line of a right-to-left cue with the given mark so renderers
+// whose default direction is left to right keep punctuation and embedded Latin words
+// in place
+func markLine(line string, direction Direction, mark string) string {
+	if direction != RTL {
+		return line
+	}
+	return mark + line
+}
@@ -0,0 +1,101 @@
+package textformat
+
+import "strings"
+
+const (
+	defaultCueLines         = 2
+	defaultCueMinDurationMs = 1000
+)
+
+// CueOptions controls how transcripts are split into caption cues. Zero values use defaults.
+type CueOptions struct {
+	MaxLineLength int   `json:"max_line_length,omitempty"` // characters per line, default 32
+	MaxLines      int   `json:"max_lines,omitempty"`       // lines per cue, default 2
+	MinDurationMs int64 `json:"min_duration_ms,omitempty"` // minimum display time of a cue, default 1000
+}
+
+// Cue is a block of caption lines shown together
+type Cue struct {
+	Index   int      `json:"index"`
+	StartMs int64    `json:"start_ms"`
+	EndMs   int64    `json:"end_ms"`
+	Lines   []string `json:"lines"`
+	// Base direction of the transcript the cue is part of; "rtl" lines are marked
+	// right to left in WebVTT and SRT output
+	Direction Direction `json:"direction,omitempty"`
+}
+
+// Cues splits a transcript spoken between startMs and endMs into caption cues. Cue
+// timing is proportional to the characters of each cue; cues shorter than the minimum
+// display duration are extended, pushing later cues back if needed.
+func Cues(text string, startMs, endMs int64, opts CueOptions) []Cue {
+	lineLength := opts.MaxLineLength
+	if lineLength <= 0 {
+		lineLength = defaultCaptionLineLength
+	}
+	linesPerCue := opts.MaxLines
+	if linesPerCue <= 0 {
+		linesPerCue = defaultCueLines
+	}
+	minDuration := opts.MinDurationMs
+	if minDuration <= 0 {
+		minDuration = defaultCueMinDurationMs
+	}
+
+	lines := wrap(strings.Fields(text), lineLength)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var groups [][]string
+	for i := 0; i < len(lines); i += linesPerCue {
+		end := i + linesPerCue
+		if end > len(lines) {
+			end = len(lines)
+		}
+		groups = append(groups, lines[i:end])
+	}
+
+	totalChars := 0
+	chars := make([]int, len(groups))
+	for i, group := range groups {
+		for _, line := range group {
+			chars[i] += len([]rune(line))
+		}
+		totalChars += chars[i]
+	}
+
+	span := endMs - startMs
+	if span < 0 {
+		span = 0
+	}
+
+	direction := DetectDirection(text)
+	cues := make([]Cue, 0, len(groups))
+	elapsed := 0
+	previousEnd := startMs
+	for i, group := range groups {
+		naturalStart := startMs + span*int64(elapsed)/int64(totalChars)
+		elapsed += chars[i]
+		naturalEnd := startMs + span*int64(elapsed)/int64(totalChars)
+
+		cueStart := naturalStart
+		if cueStart < previousEnd {
+			cueStart = previousEnd
+		}
+		cueEnd := naturalEnd
+		if cueEnd-cueStart < minDuration {
+			cueEnd = cueStart + minDuration
+		}
+
+		cues = append(cues, Cue{
+			Index:     i,
+			StartMs:   cueStart,
+			EndMs:     cueEnd,
+			Lines:     append([]string(nil), group...),
+			Direction: direction,
+		})
+		previousEnd = cueEnd
+	}
+	return cues
+}
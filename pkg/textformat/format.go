@@ -0,0 +1,221 @@
+package textformat
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Formatting profiles
+const (
+	ProfileRaw      = "raw"      // transcript as returned by the ASR engine
+	ProfileCaptions = "captions" // uppercase, no punctuation, wrapped to caption lines
+	ProfileDocument = "document" // sentence case, full punctuation, paragraphs
+)
+
+const (
+	defaultCaptionLineLength  = 32
+	defaultParagraphSentences = 4
+)
+
+// Options selects a profile and its parameters. Zero values use the profile defaults.
+type Options struct {
+	Profile            string `json:"profile"`
+	MaxLineLength      int    `json:"max_line_length,omitempty"`     // captions: characters per line
+	MaxLines           int    `json:"max_lines,omitempty"`           // captions: lines kept, 0 keeps all
+	ParagraphSentences int    `json:"paragraph_sentences,omitempty"` // document: sentences per paragraph
+}
+
+// Validate checks that the profile is known and parameters are sane
+func (o Options) Validate() error {
+	switch o.Profile {
+	case "", ProfileRaw, ProfileCaptions, ProfileDocument:
+	default:
+		return fmt.Errorf("unknown formatting profile: %s", o.Profile)
+	}
+	if o.MaxLineLength < 0 || o.MaxLines < 0 || o.ParagraphSentences < 0 {
+		return fmt.Errorf("formatting limits must be non-negative")
+	}
+	return nil
+}
+
+// Apply formats a transcript according to the options
+func Apply(text string, opts Options) string {
+	switch opts.Profile {
+	case ProfileCaptions:
+		return captions(text, opts)
+	case ProfileDocument:
+		return document(text, opts)
+	default:
+		return text
+	}
+}
+
+func captions(text string, opts Options) string {
+	lineLength := opts.MaxLineLength
+	if lineLength <= 0 {
+		lineLength = defaultCaptionLineLength
+	}
+
+	stripped := strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return ' '
+		}
+		return unicode.ToUpper(r)
+	}, text)
+
+	lines := wrap(strings.Fields(stripped), lineLength)
+	if opts.MaxLines > 0 && len(lines) > opts.MaxLines {
+		lines = lines[len(lines)-opts.MaxLines:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrap packs words into lines of at most lineLength runes, splitting words (or runs
+// of CJK characters without spaces) that are longer than a line
+func wrap(words []string, lineLength int) []string {
+	var lines []string
+	var line []rune
+
+	for _, word := range words {
+		runes := []rune(word)
+		for len(runes) > 0 {
+			space := 0
+			if len(line) > 0 && !isCJK(line[len(line)-1]) && !isCJK(runes[0]) {
+				space = 1
+			}
+
+			free := lineLength - len(line) - space
+			if len(runes) <= free {
+				if space == 1 {
+					line = append(line, ' ')
+				}
+				line = append(line, runes...)
+				break
+			}
+
+			// Start a new line unless the word cannot fit on an empty one either
+			if len(line) > 0 && len(runes) <= lineLength {
+				lines = append(lines, string(line))
+				line = line[:0]
+				continue
+			}
+			if free <= 0 {
+				lines = append(lines, string(line))
+				line = line[:0]
+				continue
+			}
+			if space == 1 {
+				line = append(line, ' ')
+			}
+			line = append(line, runes[:free]...)
+			runes = runes[free:]
+			lines = append(lines, string(line))
+			line = line[:0]
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+func document(text string, opts Options) string {
+	perParagraph := opts.ParagraphSentences
+	if perParagraph <= 0 {
+		perParagraph = defaultParagraphSentences
+	}
+
+	sentences := splitSentences(strings.TrimSpace(text))
+	if len(sentences) == 0 {
+		return ""
+	}
+
+	var paragraphs []string
+	var current []string
+	for _, sentence := range sentences {
+		current = append(current, capitalize(terminate(sentence)))
+		if len(current) == perParagraph {
+			paragraphs = append(paragraphs, joinSentences(current))
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, joinSentences(current))
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// splitSentences splits after sentence-ending punctuation, keeping the punctuation
+func splitSentences(text string) []string {
+	var sentences []string
+	var current []rune
+	for _, r := range text {
+		current = append(current, r)
+		if isSentenceEnd(r) {
+			if sentence := strings.TrimSpace(string(current)); sentence != "" {
+				sentences = append(sentences, sentence)
+			}
+			current = current[:0]
+		}
+	}
+	if sentence := strings.TrimSpace(string(current)); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
+
+func joinSentences(sentences []string) string {
+	var b strings.Builder
+	for i, sentence := range sentences {
+		if i > 0 && !isCJK([]rune(sentence)[0]) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(sentence)
+	}
+	return b.String()
+}
+
+// terminate adds a full stop matching the script of the sentence if it has none
+func terminate(sentence string) string {
+	runes := []rune(sentence)
+	last := runes[len(runes)-1]
+	if isSentenceEnd(last) {
+		return sentence
+	}
+	if unicode.IsPunct(last) {
+		runes = runes[:len(runes)-1]
+		if len(runes) == 0 {
+			return sentence
+		}
+		last = runes[len(runes)-1]
+	}
+	if isCJK(last) {
+		return string(runes) + "。"
+	}
+	return string(runes) + "."
+}
+
+func capitalize(sentence string) string {
+	runes := []rune(sentence)
+	for i, r := range runes {
+		if unicode.IsLetter(r) {
+			runes[i] = unicode.ToUpper(r)
+			break
+		}
+	}
+	return string(runes)
+}
+
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '.', '!', '?', '。', '！', '？':
+		return true
+	}
+	return false
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
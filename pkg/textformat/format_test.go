@@ -0,0 +1,98 @@
+package textformat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		opts Options
+		want string
+	}{
+		{"raw", "hello, world", Options{}, "hello, world"},
+		{"captions", "hello, world! this is a caption test", Options{Profile: ProfileCaptions, MaxLineLength: 16}, "HELLO WORLD THIS\nIS A CAPTION\nTEST"},
+		{"captions max lines", "one two three four five six", Options{Profile: ProfileCaptions, MaxLineLength: 9, MaxLines: 2}, "FOUR FIVE\nSIX"},
+		{"captions cjk", "今天天气很好，我们去公园吧。", Options{Profile: ProfileCaptions, MaxLineLength: 8}, "今天天气很好\n我们去公园吧"},
+		{"document", "hello world. how are you? fine thanks", Options{Profile: ProfileDocument, ParagraphSentences: 2}, "Hello world. How are you?\n\nFine thanks."},
+		{"document cjk", "今天天气很好，我们去公园吧", Options{Profile: ProfileDocument}, "今天天气很好，我们去公园吧。"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Apply(tt.text, tt.opts))
+		})
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.NoError(t, Options{Profile: ProfileCaptions}.Validate())
+	assert.Error(t, Options{Profile: "shouting"}.Validate())
+	assert.Error(t, Options{Profile: ProfileCaptions, MaxLineLength: -1}.Validate())
+}
+
+func TestCues(t *testing.T) {
+	cues := Cues("one two three four five six seven eight", 1000, 5000, CueOptions{MaxLineLength: 10, MaxLines: 1, MinDurationMs: 100})
+	if assert.Len(t, cues, 5) {
+		assert.Equal(t, []string{"one two"}, cues[0].Lines)
+		assert.Equal(t, int64(1000), cues[0].StartMs)
+		assert.Equal(t, int64(5000), cues[4].EndMs)
+		for i := 1; i < len(cues); i++ {
+			assert.GreaterOrEqual(t, cues[i].StartMs, cues[i-1].EndMs)
+		}
+	}
+
+	// Short utterances are held on screen for the minimum duration
+	cues = Cues("hi", 0, 200, CueOptions{})
+	if assert.Len(t, cues, 1) {
+		assert.Equal(t, int64(1000), cues[0].EndMs)
+	}
+
+	assert.Empty(t, Cues("  ", 0, 1000, CueOptions{}))
+}
+
+func TestWebVTT(t *testing.T) {
+	cues := []Cue{
+		{Index: 0, StartMs: 1500, EndMs: 3250, Lines: []string{"hello world", "a < b & c"}},
+		{Index: 0, StartMs: 3723004, EndMs: 3725000, Lines: []string{"later"}},
+	}
+	want := "WEBVTT\n" +
+		"\n1\n00:00:01.500 --> 00:00:03.250\nhello world\na &lt; b &amp; c\n" +
+		"\n2\n01:02:03.004 --> 01:02:05.000\nlater\n"
+	assert.Equal(t, want, WebVTT(cues))
+	assert.Equal(t, "WEBVTT\n", WebVTT(nil))
+}
+
+func TestDetectDirection(t *testing.T) {
+	assert.Equal(t, RTL, DetectDirection("مرحبا بكم في البث المباشر"))
+	assert.Equal(t, RTL, DetectDirection("iPhone החדש יוצא היום"), "a leading Latin word does not flip a Hebrew sentence")
+	assert.Equal(t, LTR, DetectDirection("the word שלום means peace"))
+	assert.Equal(t, LTR, DetectDirection("12:30 !"))
+	assert.Equal(t, LTR, DetectDirection(""))
+}
+
+func TestRightToLeftCues(t *testing.T) {
+	cues := Cues("GPT מודל חדש.", 0, 2000, CueOptions{})
+	if assert.Len(t, cues, 1) {
+		assert.Equal(t, RTL, cues[0].Direction)
+		assert.Equal(t, []string{"GPT מודל חדש."}, cues[0].Lines, "JSON lines stay free of control characters")
+	}
+	assert.Equal(t, LTR, Cues("hello", 0, 1000, CueOptions{})[0].Direction)
+
+	assert.Equal(t, "WEBVTT\n\n1\n00:00:00.000 --> 00:00:02.000\n&rlm;GPT מודל חדש.\n", WebVTT(cues))
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:02,000\n\u200fGPT מודל חדש.\n", SRT(cues))
+}
+
+func TestSRT(t *testing.T) {
+	cues := []Cue{
+		{StartMs: 1500, EndMs: 3250, Lines: []string{"hello world", "a < b"}},
+		{StartMs: 3723004, EndMs: 3725000, Lines: []string{"later"}},
+	}
+	want := "1\n00:00:01,500 --> 00:00:03,250\nhello world\na < b\n" +
+		"\n2\n01:02:03,004 --> 01:02:05,000\nlater\n"
+	assert.Equal(t, want, SRT(cues))
+	assert.Equal(t, "", SRT(nil))
+}
@@ -0,0 +1,73 @@
+package textformat
+
+import (
+	"fmt"
+	"strings"
+)
+
+var webVTTEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// WebVTTHeader starts a WebVTT file, followed by its cues
+const WebVTTHeader = "WEBVTT\n"
+
+// WebVTT renders caption cues as a WebVTT file, numbering cues in order
+func WebVTT(cues []Cue) string {
+	var b strings.Builder
+	b.WriteString(WebVTTHeader)
+	for i, cue := range cues {
+		b.WriteString(WebVTTCue(i+1, cue))
+	}
+	return b.String()
+}
+
+// WebVTTCue renders the cue numbered number of a WebVTT file, preceded by the blank
+// line separating it from the header or the previous cue
+func WebVTTCue(number int, cue Cue) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n%d\n%s --> %s\n", number, webVTTTimestamp(cue.StartMs), webVTTTimestamp(cue.EndMs))
+	for _, line := range cue.Lines {
+		b.WriteString(markLine(webVTTEscaper.Replace(line), cue.Direction, "&rlm;"))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// SRT renders caption cues as a SubRip file, numbering cues in order
+func SRT(cues []Cue) string {
+	var b strings.Builder
+	for i, cue := range cues {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(SRTCue(i+1, cue))
+	}
+	return b.String()
+}
+
+// SRTCue renders the cue numbered number of a SubRip file; cues after the first are
+// separated from the previous one by a blank line
+func SRTCue(number int, cue Cue) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d\n%s --> %s\n", number, srtTimestamp(cue.StartMs), srtTimestamp(cue.EndMs))
+	for _, line := range cue.Lines {
+		b.WriteString(markLine(line, cue.Direction, rightToLeftMark))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// webVTTTimestamp formats milliseconds as hh:mm:ss.ttt
+func webVTTTimestamp(ms int64) string {
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// srtTimestamp formats milliseconds as hh:mm:ss,ttt
+func srtTimestamp(ms int64) string {
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,181 @@
+package textmerge
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minOverlap is the number of matching words required to align two windows
+const minOverlap = 2
+
+// Token is a word, or a single character of CJK text
+type Token struct {
+	Text        string
+	SpaceBefore bool
+}
+
+// Result of merging a window transcript into the running transcript
+type Result struct {
+	Tokens   []Token
+	Delta    string // text appended after the aligned overlap
+	Replaced int    // tokens at the end of the previous transcript revised by the window
+}
+
+// Text renders the merged transcript
+func (r Result) Text() string {
+	return Join(r.Tokens)
+}
+
+// Merge aligns the transcript of an overlapping audio window with the running
+// transcript. The longest common run of words between the tail of prev and the
+// window anchors the two; everything after the anchor is taken from the window,
+// which has more right context and so revises the previous ending; the anchor and
+// everything before it stay as they were. Without an anchor the window is appended.
+func Merge(prev []Token, window string) Result {
+	next := Tokenize(window)
+	if len(next) == 0 {
+		return Result{Tokens: prev}
+	}
+
+	// Only the part of prev the window can overlap is searched
+	tailStart := max(0, len(prev)-len(next))
+	a, b, length := longestCommonRun(prev[tailStart:], next)
+	a += tailStart
+
+	required := minOverlap
+	if len(next) < minOverlap {
+		required = 1
+	}
+	if length < required {
+		merged := append(append([]Token(nil), prev...), next...)
+		if len(prev) > 0 {
+			merged[len(prev)].SpaceBefore = needsSpace(prev[len(prev)-1].Text, next[0].Text)
+		}
+		return Result{Tokens: merged, Delta: Join(next)}
+	}
+
+	stable := a + length
+	merged := append(append([]Token(nil), prev[:stable]...), next[b+length:]...)
+	if len(merged) > stable {
+		merged[stable].SpaceBefore = needsSpace(prev[stable-1].Text, next[b+length].Text)
+	}
+	return Result{
+		Tokens:   merged,
+		Delta:    Join(next[b+length:]),
+		Replaced: len(prev) - stable,
+	}
+}
+
+// longestCommonRun returns the start in x, the start in y and the length of the
+// longest run of tokens equal in both, preferring the latest run in x
+func longestCommonRun(x, y []Token) (int, int, int) {
+	bestA, bestB, bestLen := 0, 0, 0
+	prevRow := make([]int, len(y)+1)
+	row := make([]int, len(y)+1)
+	for i := 1; i <= len(x); i++ {
+		for j := 1; j <= len(y); j++ {
+			if normalize(x[i-1].Text) == normalize(y[j-1].Text) {
+				row[j] = prevRow[j-1] + 1
+				if row[j] >= bestLen {
+					bestA, bestB, bestLen = i-row[j], j-row[j], row[j]
+				}
+			} else {
+				row[j] = 0
+			}
+		}
+		prevRow, row = row, prevRow
+	}
+	return bestA, bestB, bestLen
+}
+
+// Tokenize splits text into words, treating each CJK character as a word
+func Tokenize(text string) []Token {
+	var tokens []Token
+	var word strings.Builder
+	space := false
+
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, Token{Text: word.String(), SpaceBefore: space && len(tokens) > 0})
+			word.Reset()
+			space = false
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+			space = true
+		case isCJK(r):
+			flush()
+			tokens = append(tokens, Token{Text: string(r), SpaceBefore: space && len(tokens) > 0})
+			space = false
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// Join renders tokens back into text
+func Join(tokens []Token) string {
+	var b strings.Builder
+	for i, token := range tokens {
+		if i > 0 && token.SpaceBefore {
+			b.WriteByte(' ')
+		}
+		b.WriteString(token.Text)
+	}
+	return b.String()
+}
+
+// Concat joins texts transcribed one after another, separated by a space unless CJK
+// text meets on either side
+func Concat(texts ...string) string {
+	var b strings.Builder
+	for _, text := range texts {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if b.Len() > 0 && needsSpace(b.String(), text) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// normalize compares words case-insensitively and ignoring punctuation
+func normalize(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, unicode.IsPunct))
+}
+
+func needsSpace(left, right string) bool {
+	l := []rune(left)
+	r := []rune(right)
+	if len(l) == 0 || len(r) == 0 {
+		return false
+	}
+	return !isCJK(l[len(l)-1]) && !isCJK(r[0])
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) ||
+		(unicode.IsPunct(r) && r >= 0x3000 && r <= 0xFFEF)
+}
+
+// CommonPrefix returns the number of characters next shares with the start of prev
+// and the text of next after them, so next is rebuilt from prev as
+// string([]rune(prev)[:n]) + rest
+func CommonPrefix(prev, next string) (int, string) {
+	p, n := []rune(prev), []rune(next)
+	i := 0
+	for i < len(p) && i < len(n) && p[i] == n[i] {
+		i++
+	}
+	return i, string(n[i:])
+}
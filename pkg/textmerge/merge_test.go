@@ -0,0 +1,72 @@
+package textmerge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		prev     string
+		window   string
+		want     string
+		delta    string
+		replaced int
+	}{
+		{"first window", "", "hello world", "hello world", "hello world", 0},
+		{"overlap", "the quick brown fox", "quick brown fox jumps over", "the quick brown fox jumps over", "jumps over", 0},
+		{"partial first word", "the quick brown fox", "ick brown fox jumps", "the quick brown fox jumps", "jumps", 0},
+		{"revised ending", "the quick brown fax", "quick brown fox jumps", "the quick brown fox jumps", "fox jumps", 1},
+		{"case and punctuation", "Hello, world.", "hello world how are you", "Hello, world. how are you", "how are you", 0},
+		{"no overlap", "good morning", "completely different", "good morning completely different", "completely different", 0},
+		{"cjk", "今天天气很好", "天气很好我们去公园", "今天天气很好我们去公园", "我们去公园", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Merge(Tokenize(tt.prev), tt.window)
+			assert.Equal(t, tt.want, result.Text())
+			assert.Equal(t, tt.delta, result.Delta)
+			assert.Equal(t, tt.replaced, result.Replaced)
+		})
+	}
+}
+
+func TestTokenizeJoin(t *testing.T) {
+	for _, text := range []string{"hello world", "今天 is 好天气", "a  b"} {
+		tokens := Tokenize(text)
+		assert.NotEmpty(t, tokens)
+		assert.NotContains(t, Join(tokens), "  ")
+	}
+	assert.Equal(t, "今天 is 好天气", Join(Tokenize("今天 is 好天气")))
+}
+
+func TestConcat(t *testing.T) {
+	assert.Equal(t, "hello world again", Concat("hello world", " again "))
+	assert.Equal(t, "今天天气很好我们去公园", Concat("今天天气很好", "我们去公园"))
+	assert.Equal(t, "会议开始OK", Concat("会议开始", "", "OK"))
+	assert.Equal(t, "", Concat("", " "))
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		prev, next string
+		n          int
+		rest       string
+	}{
+		{"", "hello", 0, "hello"},
+		{"hello wor", "hello world", 9, "ld"},
+		{"the quick brown fax", "the quick brown fox jumps", 17, "ox jumps"},
+		{"今天天气", "今天天气很好", 4, "很好"},
+		{"same", "same", 4, ""},
+	}
+
+	for _, tt := range tests {
+		n, rest := CommonPrefix(tt.prev, tt.next)
+		assert.Equal(t, tt.n, n, tt.next)
+		assert.Equal(t, tt.rest, rest, tt.next)
+		assert.Equal(t, tt.next, string([]rune(tt.prev)[:n])+rest)
+	}
+}
@@ -0,0 +1,258 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queueSize bounds the spans waiting for export, spans beyond it are dropped rather
+// than slowing down the recognition pipeline
+const queueSize = 4096
+
+// ExportStats counts the spans handed to the collector
+type ExportStats struct {
+	Exported int64 `json:"exported"`
+	Dropped  int64 `json:"dropped"` // queue full or export failed
+	Failures int64 `json:"failures"`
+}
+
+// exporter batches ended spans and POSTs them to an OTLP/HTTP collector as JSON
+type exporter struct {
+	url         string
+	headers     map[string]string
+	serviceName string
+	batchSize   int
+	interval    time.Duration
+	client      *http.Client
+
+	queue    chan *Span
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	exported atomic.Int64
+	dropped  atomic.Int64
+	failures atomic.Int64
+}
+
+func newExporter(opts Options) *exporter {
+	e := &exporter{
+		url:         strings.TrimRight(opts.Endpoint, "/") + "/v1/traces",
+		headers:     opts.Headers,
+		serviceName: opts.ServiceName,
+		batchSize:   opts.BatchSize,
+		interval:    opts.FlushInterval,
+		client:      &http.Client{Timeout: opts.Timeout},
+		queue:       make(chan *Span, queueSize),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+func (e *exporter) enqueue(span *Span) {
+	select {
+	case e.queue <- span:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+func (e *exporter) loop() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, e.batchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			e.export(batch)
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= e.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.done:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= e.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *exporter) shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.done) })
+	select {
+	case <-e.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *exporter) stats() ExportStats {
+	return ExportStats{
+		Exported: e.exported.Load(),
+		Dropped:  e.dropped.Load(),
+		Failures: e.failures.Load(),
+	}
+}
+
+func (e *exporter) export(batch []*Span) {
+	if err := e.post(batch); err != nil {
+		e.failures.Add(1)
+		e.dropped.Add(int64(len(batch)))
+		return
+	}
+	e.exported.Add(int64(len(batch)))
+}
+
+func (e *exporter) post(batch []*Span) error {
+	data, err := json.Marshal(e.request(batch))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// The OTLP/HTTP JSON encoding of an ExportTraceServiceRequest. IDs are hex and 64 bit
+// integers decimal strings, as the protocol's JSON mapping requires.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpScopeSpans struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            *otlpStatus     `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"` // 2 is STATUS_CODE_ERROR
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+func (e *exporter) request(batch []*Span) otlpRequest {
+	resource := otlpResourceSpans{}
+	resource.Resource.Attributes = []otlpAttribute{attribute("service.name", e.serviceName)}
+	scope := otlpScopeSpans{}
+	scope.Scope.Name = "github.com/go-restream/stt"
+
+	for _, span := range batch {
+		span.mutex.Lock()
+		encoded := otlpSpan{
+			TraceID:           span.sc.TraceID.String(),
+			SpanID:            span.sc.SpanID.String(),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		}
+		if span.parent.IsValid() {
+			encoded.ParentSpanID = span.parent.String()
+		}
+		keys := make([]string, 0, len(span.attributes))
+		for key := range span.attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			encoded.Attributes = append(encoded.Attributes, attribute(key, span.attributes[key]))
+		}
+		if span.errMessage != "" {
+			encoded.Status = &otlpStatus{Code: 2, Message: span.errMessage}
+		}
+		span.mutex.Unlock()
+		scope.Spans = append(scope.Spans, encoded)
+	}
+
+	resource.ScopeSpans = []otlpScopeSpans{scope}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{resource}}
+}
+
+func attribute(key string, value interface{}) otlpAttribute {
+	var encoded map[string]interface{}
+	switch v := value.(type) {
+	case string:
+		encoded = map[string]interface{}{"stringValue": v}
+	case bool:
+		encoded = map[string]interface{}{"boolValue": v}
+	case int:
+		encoded = map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+	case int64:
+		encoded = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float32:
+		encoded = map[string]interface{}{"doubleValue": float64(v)}
+	case float64:
+		encoded = map[string]interface{}{"doubleValue": v}
+	default:
+		encoded = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+	return otlpAttribute{Key: key, Value: encoded}
+}
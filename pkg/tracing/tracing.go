@@ -0,0 +1,323 @@
+// Package tracing records OpenTelemetry compatible spans and exports them to an OTLP
+// collector over HTTP. It implements the small part of the OpenTelemetry model the
+// server needs: parent-based sampling, W3C trace context propagation and batched
+// export. A nil *Tracer and a nil *Span are valid and record nothing, so callers do not
+// need to check whether tracing is enabled.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the ID is not all zeros
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether the ID is not all zeros
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext identifies a span across process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Span kinds, as in the OTLP protocol
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// Span is an operation of a trace. Its methods are safe for concurrent use and do
+// nothing on a nil span.
+type Span struct {
+	tracer *Tracer
+	name   string
+	kind   int
+	sc     SpanContext
+	parent SpanID
+	start  time.Time
+
+	mutex      sync.Mutex
+	end        time.Time
+	attributes map[string]interface{}
+	errMessage string
+	ended      bool
+}
+
+// Context returns the span context, the zero value for a nil span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// TraceID returns the hex trace ID, empty for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.sc.TraceID.String()
+}
+
+// SetAttribute sets an attribute. Strings, bools, integers and floats are exported as
+// such, other values as their fmt representation.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{})
+	}
+	s.attributes[key] = value
+}
+
+// AddInt adds to an integer attribute, for counters accumulated over the span
+func (s *Span) AddInt(key string, delta int64) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{})
+	}
+	current, _ := s.attributes[key].(int64)
+	s.attributes[key] = current + delta
+}
+
+// RecordError marks the span as failed with the error's message, nil is ignored
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.errMessage = err.Error()
+	s.mutex.Unlock()
+}
+
+// End ends the span now
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt ends the span at the given time and queues it for export. Only the first call
+// has an effect.
+func (s *Span) EndAt(t time.Time) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = t
+	s.mutex.Unlock()
+
+	s.tracer.exporter.enqueue(s)
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a context carrying the span as parent of spans started from it
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span of the context, nil if it carries none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+type remoteKey struct{}
+
+// ContextWithRemoteParent returns a context whose spans continue a trace started in
+// another process, see Extract
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Options configures a Tracer
+type Options struct {
+	ServiceName   string
+	Endpoint      string            // OTLP/HTTP base URL, spans go to <Endpoint>/v1/traces
+	Headers       map[string]string // sent with every export, e.g. collector credentials
+	SampleRatio   float64           // fraction of new traces recorded, 0 records none
+	Timeout       time.Duration     // of one export request
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// Tracer starts spans and exports the sampled ones
+type Tracer struct {
+	sampleRatio float64
+	exporter    *exporter
+}
+
+// NewTracer creates a tracer exporting to the OTLP endpoint of the options in the
+// background until Shutdown
+func NewTracer(opts Options) *Tracer {
+	if opts.ServiceName == "" {
+		opts.ServiceName = "stt"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 512
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 5 * time.Second
+	}
+	return &Tracer{
+		sampleRatio: opts.SampleRatio,
+		exporter:    newExporter(opts),
+	}
+}
+
+// Start starts a span as child of the span or remote parent of ctx, or as the root of a
+// new trace, and returns a context carrying it. Spans of unsampled traces are nil.
+func (t *Tracer) Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	return t.StartAt(ctx, name, kind, time.Now())
+}
+
+// StartAt is like Start with an explicit start time, for operations that are only
+// recorded once they turned out to be interesting
+func (t *Tracer) StartAt(ctx context.Context, name string, kind int, start time.Time) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{tracer: t, name: name, kind: kind, start: start}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.sc.TraceID = parent.sc.TraceID
+		span.sc.Sampled = parent.sc.Sampled
+		span.parent = parent.sc.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		span.sc.TraceID = remote.TraceID
+		span.sc.Sampled = remote.Sampled
+		span.parent = remote.SpanID
+	} else {
+		rand.Read(span.sc.TraceID[:])
+		span.sc.Sampled = t.sample(span.sc.TraceID)
+	}
+	if !span.sc.Sampled {
+		return ctx, nil
+	}
+	rand.Read(span.sc.SpanID[:])
+	return ContextWithSpan(ctx, span), span
+}
+
+// sample decides from the trace ID, like the TraceIdRatioBased sampler
+func (t *Tracer) sample(id TraceID) bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	if t.sampleRatio <= 0 {
+		return false
+	}
+	var bound uint64
+	for _, b := range id[8:] {
+		bound = bound<<8 | uint64(b)
+	}
+	return float64(bound>>1) < t.sampleRatio*float64(uint64(1)<<63)
+}
+
+// Shutdown exports the queued spans and stops the exporter
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.shutdown(ctx)
+}
+
+// Stats returns the export counters
+func (t *Tracer) Stats() ExportStats {
+	if t == nil {
+		return ExportStats{}
+	}
+	return t.exporter.stats()
+}
+
+// Inject sets the W3C traceparent header of the span of ctx, for requests to other
+// services. Without a span the header is left alone.
+func Inject(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	header.Set("traceparent", FormatTraceparent(span.sc))
+}
+
+// Extract reads the W3C traceparent header of an incoming request
+func Extract(header http.Header) (SpanContext, bool) {
+	return ParseTraceparent(header.Get("traceparent"))
+}
+
+// FormatTraceparent formats a span context as a version 00 traceparent value
+func FormatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a traceparent value, false when it is missing or invalid
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
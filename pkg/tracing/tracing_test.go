@@ -0,0 +1,165 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(value)
+	require.True(t, ok)
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.Equal(t, value, FormatTraceparent(sc))
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		_, ok := ParseTraceparent(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+func TestNilTracerAndSpanAreNoops(t *testing.T) {
+	var tracer *Tracer
+	ctx, span := tracer.Start(context.Background(), "op", KindInternal)
+	assert.Nil(t, span)
+	assert.Nil(t, SpanFromContext(ctx))
+
+	span.SetAttribute("key", "value")
+	span.AddInt("count", 1)
+	span.RecordError(errors.New("boom"))
+	span.End()
+	assert.Equal(t, "", span.TraceID())
+
+	header := http.Header{}
+	Inject(ctx, header)
+	assert.Empty(t, header.Get("traceparent"))
+	assert.NoError(t, tracer.Shutdown(context.Background()))
+}
+
+type collector struct {
+	mutex    sync.Mutex
+	requests []otlpRequest
+	header   http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var request otlpRequest
+	json.Unmarshal(body, &request)
+	c.mutex.Lock()
+	c.requests = append(c.requests, request)
+	c.header = r.Header.Clone()
+	c.mutex.Unlock()
+}
+
+func (c *collector) spans() []otlpSpan {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var spans []otlpSpan
+	for _, request := range c.requests {
+		for _, resource := range request.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				spans = append(spans, scope.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func TestSpansAreExportedWithParentsAndAttributes(t *testing.T) {
+	sink := &collector{}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	tracer := NewTracer(Options{
+		ServiceName: "stt-test",
+		Endpoint:    server.URL,
+		Headers:     map[string]string{"X-Api-Key": "secret"},
+		SampleRatio: 1,
+	})
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ContextWithRemoteParent(context.Background(), remote)
+
+	ctx, root := tracer.Start(ctx, "conversation.item", KindServer)
+	require.NotNil(t, root)
+	root.SetAttribute("item.id", "item_1")
+	root.AddInt("audio.chunks", 2)
+	root.AddInt("audio.chunks", 3)
+
+	start := time.Now().Add(-time.Second)
+	childCtx, child := tracer.StartAt(ctx, "asr.transcribe", KindClient, start)
+	header := http.Header{}
+	Inject(childCtx, header)
+	assert.Equal(t, FormatTraceparent(child.Context()), header.Get("traceparent"))
+	child.RecordError(errors.New("upstream timeout"))
+	child.EndAt(start.Add(200 * time.Millisecond))
+	root.End()
+	root.End()
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	spans := sink.spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "secret", sink.header.Get("X-Api-Key"))
+	assert.Equal(t, "stt-test", sink.requests[0].ResourceSpans[0].Resource.Attributes[0].Value["stringValue"])
+
+	transcribe, item := spans[0], spans[1]
+	assert.Equal(t, "asr.transcribe", transcribe.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", item.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", item.ParentSpanID)
+	assert.Equal(t, item.TraceID, transcribe.TraceID)
+	assert.Equal(t, item.SpanID, transcribe.ParentSpanID)
+	require.NotNil(t, transcribe.Status)
+	assert.Equal(t, 2, transcribe.Status.Code)
+	assert.Nil(t, item.Status)
+
+	assert.Equal(t, []otlpAttribute{
+		{Key: "audio.chunks", Value: map[string]interface{}{"intValue": "5"}},
+		{Key: "item.id", Value: map[string]interface{}{"stringValue": "item_1"}},
+	}, item.Attributes)
+	assert.Equal(t, ExportStats{Exported: 2}, tracer.Stats())
+}
+
+func TestSamplingFollowsRatioAndParent(t *testing.T) {
+	never := NewTracer(Options{Endpoint: "http://127.0.0.1:0", SampleRatio: 0})
+	defer never.Shutdown(context.Background())
+
+	_, span := never.Start(context.Background(), "op", KindInternal)
+	assert.Nil(t, span)
+
+	// A sampled remote parent is recorded whatever the ratio
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, span = never.Start(ContextWithRemoteParent(context.Background(), remote), "op", KindInternal)
+	assert.NotNil(t, span)
+
+	half := &Tracer{sampleRatio: 0.5}
+	sampled := 0
+	for i := 0; i < 2000; i++ {
+		var id TraceID
+		id[15], id[14], id[8] = byte(i), byte(i>>8), byte(i*37)
+		if half.sample(id) {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 1000, sampled, 150)
+}
@@ -129,23 +129,13 @@ func (a *LegacyEventAdapter) OnTranscriptionCompleted(event *ConversationItemInp
 
 func (a *LegacyEventAdapter) OnTranscriptionFailed(event *ConversationItemInputAudioTranscriptionFailedEvent) {
 	if a.Callback != nil {
-		asrError := &ASRError{
-			Code:    event.Error.Code,
-			Message: event.Error.Message,
-		}
-
-		a.Callback.OnRecognitionError(event.SessionID, asrError)
+		a.Callback.OnRecognitionError(event.SessionID, event.Err())
 	}
 }
 
 func (a *LegacyEventAdapter) OnError(event *ErrorEvent) {
 	if a.Callback != nil {
-		asrError := &ASRError{
-			Code:    event.Error.Code,
-			Message: event.Error.Message,
-		}
-
-		a.Callback.OnRecognitionError("global", asrError)
+		a.Callback.OnRecognitionError("global", event.Err())
 	}
 }
 
@@ -160,6 +150,7 @@ func (a *LegacyEventAdapter) OnAudioBufferCommitted(event *InputAudioBufferCommi
 func (a *LegacyEventAdapter) OnAudioBufferCleared(event *InputAudioBufferClearedEvent) {}
 func (a *LegacyEventAdapter) OnSpeechStarted(event *InputAudioBufferSpeechStartedEvent) {}
 func (a *LegacyEventAdapter) OnSpeechStopped(event *InputAudioBufferSpeechStoppedEvent) {}
+func (a *LegacyEventAdapter) OnTranscriptionDelta(event *ConversationItemInputAudioTranscriptionDeltaEvent) {}
 
 // Helper function
 func (a *LegacyEventAdapter) GenerateSessionID() string {
@@ -202,6 +193,8 @@ func (a *LegacyCallbackAdapter) OnSpeechStarted(event *InputAudioBufferSpeechSta
 
 func (a *LegacyCallbackAdapter) OnSpeechStopped(event *InputAudioBufferSpeechStoppedEvent) {}
 
+func (a *LegacyCallbackAdapter) OnTranscriptionDelta(event *ConversationItemInputAudioTranscriptionDeltaEvent) {}
+
 func (a *LegacyCallbackAdapter) OnTranscriptionCompleted(event *ConversationItemInputAudioTranscriptionCompletedEvent) {
 	if len(event.Item.Content) > 0 {
 		for _, content := range event.Item.Content {
@@ -214,11 +207,11 @@ func (a *LegacyCallbackAdapter) OnTranscriptionCompleted(event *ConversationItem
 }
 
 func (a *LegacyCallbackAdapter) OnTranscriptionFailed(event *ConversationItemInputAudioTranscriptionFailedEvent) {
-	a.callback.OnRecognitionError(event.SessionID, fmt.Errorf("transcription failed: %s", event.Error.Message))
+	a.callback.OnRecognitionError(event.SessionID, fmt.Errorf("transcription failed: %w", event.Err()))
 }
 
 func (a *LegacyCallbackAdapter) OnError(event *ErrorEvent) {
-	a.callback.OnRecognitionError("global", fmt.Errorf("server error: %s", event.Error.Message))
+	a.callback.OnRecognitionError("global", fmt.Errorf("server error: %w", event.Err()))
 }
 
 func (a *LegacyCallbackAdapter) OnConnected() {
@@ -4,8 +4,8 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"fmt"
-	"log"
 	"math"
+	"slices"
 	"sync"
 	"time"
 )
@@ -107,13 +107,16 @@ func PCM16ToBase64(audioData []int16) string {
 		return ""
 	}
 
-	// Convert int16 slice to byte slice
+	return base64.StdEncoding.EncodeToString(PCM16ToBytes(audioData))
+}
+
+// PCM16ToBytes converts 16-bit PCM audio data to little-endian bytes
+func PCM16ToBytes(audioData []int16) []byte {
 	byteData := make([]byte, len(audioData)*2)
 	for i, sample := range audioData {
 		binary.LittleEndian.PutUint16(byteData[i*2:], uint16(sample))
 	}
-
-	return base64.StdEncoding.EncodeToString(byteData)
+	return byteData
 }
 
 // Base64ToPCM16 converts Base64 audio data to 16-bit PCM
@@ -179,8 +182,6 @@ func (au *AudioUtils) ResampleAudio(inputSamples []int16, inputRate, outputRate
 		return nil, nil
 	}
 
-	log.Printf("[🎛 Audio] Resampling from %dHz to %dHz (samples: %d)", inputRate, outputRate, len(inputSamples))
-
 	ratio := float64(outputRate) / float64(inputRate)
 	outputLength := int(math.Ceil(float64(len(inputSamples)) * ratio))
 	outputSamples := make([]int16, outputLength)
@@ -204,14 +205,16 @@ func (au *AudioUtils) ResampleAudio(inputSamples []int16, inputRate, outputRate
 		}
 	}
 
-	log.Printf("[✅ Audio] Resampling completed: %d -> %d samples", len(inputSamples), len(outputSamples))
 	return outputSamples, nil
 }
 
+// SupportedInputSampleRates are the input sample rates the server converts to 16kHz
+var SupportedInputSampleRates = []int{8000, 16000, 22050, 24000, 32000, 44100, 48000}
+
 // ValidateAudioFormat checks if audio format is supported
 func (au *AudioUtils) ValidateAudioFormat(sampleRate, channels int) error {
 	// Check sample rate
-	if sampleRate != 16000 && sampleRate != 48000 {
+	if !slices.Contains(SupportedInputSampleRates, sampleRate) {
 		return ErrInvalidSampleRate
 	}
 
@@ -0,0 +1,157 @@
+package asr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchOptions configures Helper.BatchProcessWithOptions
+type BatchOptions struct {
+	// Timeout for transcribing a single file
+	Timeout time.Duration
+
+	// Parallelism is the number of sessions used to process files concurrently. The
+	// helper's recognizer is always one of them; additional sessions are opened with
+	// the same configuration and closed when the batch finishes. Values below 1 mean 1.
+	Parallelism int
+
+	// OnProgress is called after each file finishes, successfully or not. It may be
+	// called concurrently when Parallelism is greater than 1.
+	OnProgress func(BatchProgress)
+}
+
+// BatchProgress reports the outcome of a single file of a batch
+type BatchProgress struct {
+	Index     int    // index of the file in the batch
+	Completed int    // number of files finished so far, including this one
+	Total     int    // number of files in the batch
+	Text      string // transcript, empty on failure
+	Err       error  // nil on success
+}
+
+// BatchFileError is the failure of a single file of a batch
+type BatchFileError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchFileError) Error() string {
+	return fmt.Sprintf("audio file %d: %v", e.Index+1, e.Err)
+}
+
+func (e *BatchFileError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError reports the files of a batch that failed. Results of the other files
+// are still returned alongside it.
+type BatchError struct {
+	Total  int
+	Errors []*BatchFileError
+}
+
+func (e *BatchError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fileErr := range e.Errors {
+		messages = append(messages, fileErr.Error())
+	}
+	return fmt.Sprintf("%d of %d audio files failed: %s", len(e.Errors), e.Total, strings.Join(messages, "; "))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, fileErr := range e.Errors {
+		errs = append(errs, fileErr)
+	}
+	return errs
+}
+
+// BatchProcess processes multiple audio files in sequence. A failing file does not
+// abort the batch: results of the successful files are returned together with a
+// *BatchError describing the failed ones.
+func (h *Helper) BatchProcess(audioFiles [][]byte, timeout time.Duration) ([]string, error) {
+	return h.BatchProcessWithOptions(audioFiles, BatchOptions{Timeout: timeout})
+}
+
+// BatchProcessWithOptions processes multiple audio files, optionally over several
+// sessions in parallel, reporting progress per file. Results are ordered like
+// audioFiles; failed files have an empty result and are listed in the returned *BatchError.
+func (h *Helper) BatchProcessWithOptions(audioFiles [][]byte, opts BatchOptions) ([]string, error) {
+	if !h.recognizer.IsRunning() {
+		return nil, ErrRecognizerNotRunning
+	}
+
+	parallelism := opts.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	if parallelism > len(audioFiles) {
+		parallelism = len(audioFiles)
+	}
+
+	helpers := []*Helper{h}
+	for i := 1; i < parallelism; i++ {
+		config := *h.recognizer.config
+		recognizer, err := NewRecognizer(&config)
+		if err == nil {
+			err = recognizer.Start()
+		}
+		if err != nil {
+			h.recognizer.logger.Warn("Failed to open extra session", Fields{"component": "batch", "session": i, "sessions": len(helpers), "error": err})
+			break
+		}
+		defer recognizer.Stop()
+		helpers = append(helpers, NewHelper(recognizer))
+	}
+
+	results := make([]string, len(audioFiles))
+	batchErr := &BatchError{Total: len(audioFiles)}
+
+	var (
+		mutex     sync.Mutex
+		completed int
+		wg        sync.WaitGroup
+	)
+
+	indexes := make(chan int)
+	for _, worker := range helpers {
+		wg.Add(1)
+		go func(worker *Helper) {
+			defer wg.Done()
+			for i := range indexes {
+				text, err := worker.ProcessAudioFile(audioFiles[i], opts.Timeout)
+
+				mutex.Lock()
+				completed++
+				progress := BatchProgress{Index: i, Completed: completed, Total: len(audioFiles), Text: text, Err: err}
+				if err != nil {
+					batchErr.Errors = append(batchErr.Errors, &BatchFileError{Index: i, Err: err})
+				} else {
+					results[i] = text
+				}
+				mutex.Unlock()
+
+				if opts.OnProgress != nil {
+					opts.OnProgress(progress)
+				}
+			}
+		}(worker)
+	}
+
+	for i := range audioFiles {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if len(batchErr.Errors) > 0 {
+		sort.Slice(batchErr.Errors, func(a, b int) bool {
+			return batchErr.Errors[a].Index < batchErr.Errors[b].Index
+		})
+		return results, batchErr
+	}
+	return results, nil
+}
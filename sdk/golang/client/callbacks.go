@@ -1,6 +1,9 @@
 package asr
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 // EventHandler defines the interface for handling OpenAI Realtime API events
 type EventHandler interface {
@@ -23,8 +26,10 @@ type EventHandler interface {
 	// Transcription events
 	OnTranscriptionCompleted(*ConversationItemInputAudioTranscriptionCompletedEvent)
 	OnTranscriptionFailed(*ConversationItemInputAudioTranscriptionFailedEvent)
+	OnTranscriptionDelta(*ConversationItemInputAudioTranscriptionDeltaEvent)
 
-	// Connection events
+	// Connection events. Deprecated: OnConnected and OnDisconnected are not called,
+	// Recognizer.StateChanges reports every change of the connection state.
 	OnConnected()
 	OnDisconnected()
 	OnError(*ErrorEvent)
@@ -49,6 +54,7 @@ func (h *DefaultEventHandler) OnSpeechStarted(event *InputAudioBufferSpeechStart
 func (h *DefaultEventHandler) OnSpeechStopped(event *InputAudioBufferSpeechStoppedEvent)            {}
 func (h *DefaultEventHandler) OnTranscriptionCompleted(event *ConversationItemInputAudioTranscriptionCompletedEvent) {}
 func (h *DefaultEventHandler) OnTranscriptionFailed(event *ConversationItemInputAudioTranscriptionFailedEvent) {}
+func (h *DefaultEventHandler) OnTranscriptionDelta(event *ConversationItemInputAudioTranscriptionDeltaEvent) {}
 func (h *DefaultEventHandler) OnConnected()                                             {}
 func (h *DefaultEventHandler) OnDisconnected()                                           {}
 func (h *DefaultEventHandler) OnError(event *ErrorEvent)                                      {}
@@ -76,6 +82,13 @@ type Config struct {
 	// Transcription configuration
 	TranscriptionModel     string        `json:"transcription_model,omitempty"`
 	TranscriptionLanguage  string        `json:"transcription_language,omitempty"`
+	// Interim hypotheses of the utterance in progress, delivered to OnTranscriptionDelta
+	InterimResults         bool          `json:"interim_results,omitempty"`
+	// Ask for compact deltas, which omit the full hypothesis; the SDK rebuilds
+	// Transcript before OnTranscriptionDelta sees them
+	CompactDeltas          bool          `json:"compact_deltas,omitempty"`
+	// Custom vocabulary, such as product names and call signs, forwarded to the engine
+	TranscriptionHints     []string      `json:"transcription_hints,omitempty"`
 
 	// Turn detection configuration
 	TurnDetectionType               string  `json:"turn_detection_type,omitempty"`
@@ -91,9 +104,58 @@ type Config struct {
 	EnableReconnect       bool          `json:"enable_reconnect,omitempty"`
 	MaxReconnectAttempts  int           `json:"max_reconnect_attempts,omitempty"`
 	ReconnectDelay       time.Duration `json:"reconnect_delay,omitempty"`
+	// Audio written while reconnecting is kept up to this duration and sent once the
+	// connection is back, the oldest is dropped beyond it; 0 disables re-sending
+	ResendBufferDuration time.Duration `json:"resend_buffer_duration,omitempty"`
 
 	// Heartbeat configuration
 	HeartbeatInterval     time.Duration `json:"heartbeat_interval,omitempty"`
+
+	// Attach a crc32 checksum to every input_audio_buffer.append event so the server can
+	// detect payloads corrupted in transit
+	EnableChecksum        bool          `json:"enable_checksum,omitempty"`
+
+	// Send audio as binary WebSocket frames instead of Base64 in JSON, a third less
+	// bandwidth; needs a server with the binary_audio feature
+	BinaryAudio           bool          `json:"binary_audio,omitempty"`
+
+	// Event replay configuration, number of recent events kept for handlers attached after Start
+	ReplayBufferSize      int           `json:"replay_buffer_size,omitempty"`
+
+	// Deterministic test mode, the server derives event IDs, item IDs and timestamps
+	// from this seed so protocol tests can compare against golden files
+	DeterministicSeed     *int64        `json:"deterministic_seed,omitempty"`
+
+	// Transcript formatting profile applied by the server, nil keeps raw transcripts
+	TranscriptFormat      *TranscriptFormat `json:"transcript_format,omitempty"`
+
+	// Caption cue generation, the server emits caption.cue events when enabled
+	Captions              *CaptionConfig    `json:"captions,omitempty"`
+
+	// Recognition mode, nil keeps VAD-based commits only
+	Recognition           *RecognitionConfig `json:"recognition,omitempty"`
+
+	// Named server pipeline, e.g. "broadcast", "callcenter" or "dictation". Empty
+	// keeps the server's global settings; the names are listed by /v1/capabilities.
+	Pipeline              string             `json:"pipeline,omitempty"`
+
+	// Interval in seconds of session.bandwidth events, nil keeps the server default
+	// and 0 disables them
+	BandwidthReportSeconds *int              `json:"bandwidth_report_seconds,omitempty"`
+
+	// Interval in seconds of session.stats events delivered to OnSessionStats, nil
+	// keeps the server default and 0 disables them
+	StatsReportSeconds     *int              `json:"stats_report_seconds,omitempty"`
+
+	// Server noise reduction of the session's speech, nil keeps the server default
+	NoiseReduction        *NoiseReductionConfig `json:"input_audio_noise_reduction,omitempty"`
+
+	// Server automatic gain control for quiet microphones, nil keeps the server default
+	GainControl           *GainControlConfig    `json:"input_audio_gain_control,omitempty"`
+
+	// Receives the log output of the SDK, nil logs nothing; see NewStdLogger,
+	// NewSlogLogger and NewLogrusLogger
+	Logger                 Logger            `json:"-"`
 }
 
 // DefaultConfig returns a configuration with sensible defaults
@@ -115,7 +177,10 @@ func DefaultConfig() *Config {
 		EnableReconnect:        true,
 		MaxReconnectAttempts:    3,
 		ReconnectDelay:         2 * time.Second,
+		ResendBufferDuration:   DefaultResendBufferDuration,
 		HeartbeatInterval:      30 * time.Second,
+		EnableChecksum:         true,
+		ReplayBufferSize:       DefaultReplayBufferSize,
 	}
 }
 
@@ -125,7 +190,7 @@ func (c *Config) Validate() error {
 		return ErrInvalidURL
 	}
 
-	if c.InputSampleRate > 0 && (c.InputSampleRate != 16000 && c.InputSampleRate != 48000) {
+	if c.InputSampleRate > 0 && !slices.Contains(SupportedInputSampleRates, c.InputSampleRate) {
 		return ErrInvalidSampleRate
 	}
 
@@ -153,6 +218,14 @@ func (c *Config) Validate() error {
 		c.HeartbeatInterval = 30 * time.Second
 	}
 
+	if c.ReplayBufferSize < 0 {
+		c.ReplayBufferSize = 0
+	}
+
+	if c.ResendBufferDuration < 0 {
+		c.ResendBufferDuration = 0
+	}
+
 	return nil
 }
 
@@ -168,6 +241,9 @@ func (c *Config) ToSessionConfig() SessionConfig {
 		OutputChannels:               c.OutputChannels,
 		TranscriptionModel:            c.TranscriptionModel,
 		TranscriptionLanguage:         c.TranscriptionLanguage,
+		InterimResults:                c.InterimResults,
+		CompactDeltas:                 c.CompactDeltas,
+		TranscriptionHints:            c.TranscriptionHints,
 		TurnDetectionType:            c.TurnDetectionType,
 		TurnDetectionThreshold:       c.TurnDetectionThreshold,
 		TurnDetectionPrefixPaddingMs:   c.TurnDetectionPrefixPaddingMs,
@@ -247,10 +323,13 @@ func (a *RecognitionCallbackAdapter) OnRecognitionEnd(sessionID string) {
 	}
 }
 
+func (a *RecognitionCallbackAdapter) OnTranscriptionDelta(event *ConversationItemInputAudioTranscriptionDeltaEvent) {
+	// Ignored in simple callback interface
+}
+
 func (a *RecognitionCallbackAdapter) OnTranscriptionFailed(event *ConversationItemInputAudioTranscriptionFailedEvent) {
 	if a.Callback != nil {
-		a.Callback.OnRecognitionError(event.SessionID,
-			NewASRError(event.Error.Code, event.Error.Message))
+		a.Callback.OnRecognitionError(event.SessionID, event.Err())
 	}
 }
 
@@ -3,7 +3,6 @@ package asr
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"sync"
 	"time"
@@ -11,7 +10,10 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-// ConnectionManager manages WebSocket connection lifecycle
+// ConnectionManager manages WebSocket connection lifecycle. Connect, ReadMessage and
+// SendMessage honor the cancellation and deadline of their context; Disconnect closes
+// the connection, unblocking a ReadMessage in progress. The lifecycle is a state
+// machine whose transitions StateChanges reports, see ConnectionState.
 type ConnectionManager struct {
 	conn         *websocket.Conn
 	connMutex    sync.RWMutex
@@ -19,12 +21,18 @@ type ConnectionManager struct {
 	headers       http.Header
 	dialer       *websocket.Dialer
 	connected     bool
-	ctx           context.Context
+	ctx           context.Context // lifetime of the manager, canceled by Cleanup
 	cancel        context.CancelFunc
 	pingInterval  time.Duration
 	reconnect     bool
 	maxRetries    int
 	retryDelay    time.Duration
+	logger        Logger
+
+	// State of the connection and its subscribers, see state.go. ownerReconnects is
+	// set when the owner replaces dropped connections itself, as the Recognizer does.
+	state           connectionState
+	ownerReconnects bool
 }
 
 // ConnectionStatus represents the current status of the WebSocket connection
@@ -53,9 +61,15 @@ func NewConnectionManager(url string) *ConnectionManager {
 		reconnect:     true,
 		maxRetries:    3,
 		retryDelay:    2 * time.Second,
+		logger:        NopLogger{},
 	}
 }
 
+// SetLogger sets the logger of the connection, nil discards the log output
+func (cm *ConnectionManager) SetLogger(logger Logger) {
+	cm.logger = loggerOrNop(logger)
+}
+
 // SetHeader sets a custom header for the WebSocket connection
 func (cm *ConnectionManager) SetHeader(key, value string) {
 	cm.headers.Set(key, value)
@@ -73,8 +87,9 @@ func (cm *ConnectionManager) SetReconnectOptions(reconnect bool, maxRetries int,
 	cm.retryDelay = retryDelay
 }
 
-// Connect establishes a WebSocket connection
-func (cm *ConnectionManager) Connect() error {
+// Connect establishes a WebSocket connection. The handshake is abandoned when ctx is
+// canceled or its deadline passes, and takes at most 10 seconds otherwise.
+func (cm *ConnectionManager) Connect(ctx context.Context) error {
 	cm.connMutex.Lock()
 	defer cm.connMutex.Unlock()
 
@@ -82,76 +97,112 @@ func (cm *ConnectionManager) Connect() error {
 		return fmt.Errorf("already connected")
 	}
 
+	// Reconnect attempts connect while the state stays Reconnecting
+	reconnecting := cm.state.get() == StateReconnecting
+	if !reconnecting {
+		cm.state.set(StateConnecting, StateReasonConnect, nil, 0)
+	}
+
+	// A connection closed by the server is still open on our side
+	if cm.conn != nil {
+		cm.conn.Close()
+		cm.conn = nil
+	}
+
 	cm.dialer.HandshakeTimeout = 10 * time.Second
 
-	log.Printf("[🔗 Connection] Connecting to WebSocket: %s", cm.url)
+	cm.logger.Info("Connecting to WebSocket", Fields{"component": "connection", "url": cm.url})
 
-	conn, _, err := cm.dialer.Dial(cm.url, cm.headers)
+	conn, resp, err := cm.dialer.DialContext(ctx, cm.url, cm.headers)
 	if err != nil {
-		log.Printf("[❌ Connection] Failed to connect: %v", err)
-		return fmt.Errorf("connection failed: %w", err)
+		cm.logger.Error("Failed to connect", Fields{"component": "connection", "url": cm.url, "error": err})
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			err = fmt.Errorf("connection failed: %w: check the Authorization header", ErrUnauthorized)
+		} else {
+			err = fmt.Errorf("connection failed: %w", err)
+		}
+		if !reconnecting {
+			cm.state.set(StateClosed, StateReasonConnectFailed, err, 0)
+		}
+		return err
 	}
 
 	cm.conn = conn
 	cm.connected = true
 
 	// Set up ping/pong handlers
-	cm.conn.SetPingHandler(func(appData string) error {
-		log.Printf("[💓 Heartbeat] Received ping from server")
-		return cm.conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(5*time.Second))
+	conn.SetPingHandler(func(appData string) error {
+		cm.logger.Debug("Received ping from server", Fields{"component": "heartbeat"})
+		return conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(5*time.Second))
 	})
 
-	cm.conn.SetPongHandler(func(appData string) error {
-		log.Printf("[💓 Heartbeat] Received pong from server")
+	conn.SetPongHandler(func(appData string) error {
+		cm.logger.Debug("Received pong from server", Fields{"component": "heartbeat"})
 		return nil
 	})
 
-	// Set up close handler
-	cm.conn.SetCloseHandler(func(code int, text string) error {
-		log.Printf("[❌ Connection] Connection closed: %d - %s", code, text)
-		cm.connected = false
+	// Set up close handler, it runs on the goroutine reading the connection
+	conn.SetCloseHandler(func(code int, text string) error {
+		cm.logger.Warn("Connection closed", Fields{"component": "connection", "code": code, "reason": text})
+		cm.connMutex.Lock()
+		current := cm.conn == conn
+		if current {
+			cm.connected = false
+		}
+		cm.connMutex.Unlock()
 
-		if cm.reconnect && code != websocket.CloseNormalClosure {
+		if !current {
+			return nil
+		}
+		if code == websocket.CloseNormalClosure {
+			cm.state.set(StateClosed, StateReasonServerClosed, nil, 0)
+			return nil
+		}
+		cm.state.lost(cm.reconnects(), &websocket.CloseError{Code: code, Text: text})
+		if cm.reconnect {
 			go cm.attemptReconnect()
 		}
 		return nil
 	})
 
-	log.Printf("[✅ Connection] Successfully connected to: %s", cm.url)
+	if reconnecting {
+		cm.state.set(StateConnected, StateReasonReconnected, nil, 0)
+	} else {
+		cm.state.set(StateConnected, StateReasonConnected, nil, 0)
+	}
+	cm.logger.Info("Connected", Fields{"component": "connection", "url": cm.url})
 	return nil
 }
 
-// Disconnect closes the WebSocket connection
+// Disconnect closes the WebSocket connection. A ReadMessage blocked on the connection
+// returns with an error, also when the server had already closed it.
 func (cm *ConnectionManager) Disconnect() error {
 	cm.connMutex.Lock()
 	defer cm.connMutex.Unlock()
 
-	if !cm.connected {
+	cm.state.set(StateClosed, StateReasonDisconnected, nil, 0)
+	if cm.conn == nil {
+		cm.connected = false
 		return nil
 	}
 
-	log.Printf("[🔌 Connection] Disconnecting from WebSocket")
-
-	// Cancel any ongoing operations
-	cm.cancel()
+	cm.logger.Debug("Disconnecting from WebSocket", Fields{"component": "connection"})
 
-	// Close the connection
-	if cm.conn != nil {
+	// Close the connection, the server may already have closed its side
+	if cm.connected {
 		err := cm.conn.WriteControl(websocket.CloseMessage, []byte{}, time.Now().Add(5*time.Second))
 		if err != nil {
-			log.Printf("[⚠️ Connection] Error sending close message: %v", err)
-		}
-
-		err = cm.conn.Close()
-		if err != nil {
-			log.Printf("[⚠️ Connection] Error closing connection: %v", err)
+			cm.logger.Warn("Failed to send close message", Fields{"component": "connection", "error": err})
 		}
+	}
 
-		cm.conn = nil
+	if err := cm.conn.Close(); err != nil {
+		cm.logger.Warn("Failed to close connection", Fields{"component": "connection", "error": err})
 	}
+	cm.conn = nil
 
 	cm.connected = false
-	log.Printf("[✅ Connection] Successfully disconnected")
+	cm.logger.Info("Disconnected", Fields{"component": "connection"})
 	return nil
 }
 
@@ -172,37 +223,76 @@ func (cm *ConnectionManager) GetStatus() ConnectionStatus {
 	}
 
 	if !cm.connected {
+		switch cm.state.get() {
+		case StateConnecting:
+			return ConnectionStatusConnecting
+		case StateReconnecting:
+			return ConnectionStatusReconnecting
+		}
 		return ConnectionStatusDisconnected
 	}
 
 	return ConnectionStatusConnected
 }
 
-// SendMessage sends a text message over the WebSocket
-func (cm *ConnectionManager) SendMessage(message []byte) error {
-	if !cm.IsConnected() {
-		return fmt.Errorf("not connected")
+// SendMessage sends a text message over the WebSocket. The write gives up after 5
+// seconds, at the deadline of ctx if earlier, or when ctx is canceled; an abandoned
+// write leaves the connection unusable, so it is closed and a ReadMessage in progress
+// returns with an error.
+func (cm *ConnectionManager) SendMessage(ctx context.Context, message []byte) error {
+	return cm.send(ctx, websocket.TextMessage, message)
+}
+
+// SendBinary sends a binary message over the WebSocket, as SendMessage does a text one
+func (cm *ConnectionManager) SendBinary(ctx context.Context, message []byte) error {
+	return cm.send(ctx, websocket.BinaryMessage, message)
+}
+
+func (cm *ConnectionManager) send(ctx context.Context, messageType int, message []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
 	cm.connMutex.Lock()
 	defer cm.connMutex.Unlock()
 
-	if cm.conn == nil {
-		return fmt.Errorf("connection is nil")
+	if !cm.connected || cm.conn == nil {
+		return fmt.Errorf("not connected")
 	}
 
-	cm.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-	err := cm.conn.WriteMessage(websocket.TextMessage, message)
+	conn := cm.conn
+	conn.SetWriteDeadline(ioDeadline(ctx, 5*time.Second))
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetWriteDeadline(time.Now())
+	})
+	err := conn.WriteMessage(messageType, message)
+	stop()
 	if err != nil {
-		log.Printf("[❌ Connection] Failed to send message: %v", err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		cm.logger.Error("Failed to send message", Fields{"component": "connection", "error": err})
 		// Mark as disconnected on send error
 		cm.connected = false
+		conn.Close()
+		if ctx.Err() == nil {
+			cm.state.lost(cm.reconnects(), err)
+		}
 		return fmt.Errorf("send message failed: %w", err)
 	}
 
 	return nil
 }
 
+// ioDeadline returns the deadline of ctx, or timeout from now if that is earlier
+func ioDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+	return deadline
+}
+
 // StartPingLoop starts sending ping frames periodically
 func (cm *ConnectionManager) StartPingLoop() {
 	ticker := time.NewTicker(cm.pingInterval)
@@ -215,7 +305,7 @@ func (cm *ConnectionManager) StartPingLoop() {
 		case <-ticker.C:
 			if cm.IsConnected() {
 				if err := cm.sendPing(); err != nil {
-					log.Printf("[⚠️ Heartbeat] Failed to send ping: %v", err)
+					cm.logger.Warn("Failed to send ping", Fields{"component": "heartbeat", "error": err})
 				}
 			}
 		}
@@ -237,58 +327,108 @@ func (cm *ConnectionManager) sendPing() error {
 
 // attemptReconnect tries to reconnect with exponential backoff
 func (cm *ConnectionManager) attemptReconnect() {
-	log.Printf("[🔄 Connection] Starting reconnection attempt")
+	cm.logger.Info("Starting reconnection", Fields{"component": "connection"})
 
-	for attempt := 1; attempt <= cm.maxRetries; attempt++ {
-		select {
-		case <-cm.ctx.Done():
-			return
-		default:
-		}
+	if err := cm.Reconnect(cm.ctx); err != nil {
+		cm.logger.Error("All reconnection attempts failed", Fields{"component": "connection", "error": err})
+	}
+}
+
+// Reconnect replaces a dropped connection, retrying with a growing delay up to the
+// configured number of attempts. It returns the error of the last attempt, or ctx's
+// error when ctx ends first.
+func (cm *ConnectionManager) Reconnect(ctx context.Context) error {
+	// A read error leaves the dropped connection marked as connected
+	cm.connMutex.Lock()
+	cm.connected = false
+	cm.connMutex.Unlock()
 
+	err := fmt.Errorf("no reconnection attempts configured")
+	defer func() {
+		if err != nil {
+			cm.state.set(StateClosed, StateReasonReconnectFailed, err, 0)
+		}
+	}()
+	for attempt := 1; attempt <= cm.maxRetries; attempt++ {
 		delay := time.Duration(attempt) * cm.retryDelay
 		if delay > 30*time.Second {
 			delay = 30 * time.Second
 		}
 
-		log.Printf("[🔄 Connection] Reconnection attempt %d/%d in %v", attempt, cm.maxRetries, delay)
-		time.Sleep(delay)
+		cm.logger.Info("Reconnecting", Fields{"component": "connection", "attempt": attempt, "maxAttempts": cm.maxRetries, "delay": delay})
+		cm.state.set(StateReconnecting, StateReasonReconnectAttempt, nil, attempt)
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return err
+		case <-cm.ctx.Done():
+			err = cm.ctx.Err()
+			return err
+		case <-time.After(delay):
+		}
 
-		err := cm.Connect()
-		if err == nil {
-			log.Printf("[✅ Connection] Successfully reconnected on attempt %d", attempt)
-			return
+		if err = cm.Connect(ctx); err == nil {
+			cm.logger.Info("Reconnected", Fields{"component": "connection", "attempt": attempt})
+			return nil
 		}
 
-		log.Printf("[❌ Connection] Reconnection attempt %d failed: %v", attempt, err)
+		cm.logger.Warn("Reconnection attempt failed", Fields{"component": "connection", "attempt": attempt, "error": err})
 	}
-
-	log.Printf("[❌ Connection] All reconnection attempts failed")
+	return err
 }
 
-// ReadMessage reads the next message from the WebSocket
-func (cm *ConnectionManager) ReadMessage() (messageType int, message []byte, err error) {
-	if !cm.IsConnected() {
-		return 0, nil, fmt.Errorf("not connected")
+// ReadMessage reads the next message from the WebSocket. It waits up to 60 seconds,
+// until the deadline of ctx if earlier, and returns ctx.Err() when ctx is canceled
+// first. As with any read timeout, the connection is unusable after an abandoned read.
+func (cm *ConnectionManager) ReadMessage(ctx context.Context) (messageType int, message []byte, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
 	}
 
 	cm.connMutex.RLock()
 	conn := cm.conn
+	connected := cm.connected
 	cm.connMutex.RUnlock()
 
-	if conn == nil {
-		return 0, nil, fmt.Errorf("connection is nil")
+	if !connected || conn == nil {
+		return 0, nil, fmt.Errorf("not connected")
 	}
 
-	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	return conn.ReadMessage()
+	conn.SetReadDeadline(ioDeadline(ctx, 60*time.Second))
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	messageType, message, err = conn.ReadMessage()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, nil, ctxErr
+		}
+		cm.connMutex.RLock()
+		current := cm.conn == conn
+		cm.connMutex.RUnlock()
+		if current {
+			cm.state.lost(cm.reconnects(), err)
+		}
+		return 0, nil, err
+	}
+	return messageType, message, nil
 }
 
-// Cleanup performs cleanup of connection resources
+// Cleanup performs cleanup of connection resources, the manager cannot reconnect
+// afterwards
 func (cm *ConnectionManager) Cleanup() {
 	cm.cancel()
 
 	if err := cm.Disconnect(); err != nil {
-		log.Printf("[⚠️ Connection] Error during cleanup: %v", err)
+		cm.logger.Warn("Failed to clean up connection", Fields{"component": "connection", "error": err})
 	}
+	cm.state.close()
+}
+
+// reconnects reports whether a dropped connection will be replaced, by the manager or
+// its owner
+func (cm *ConnectionManager) reconnects() bool {
+	return cm.reconnect || cm.ownerReconnects
 }
\ No newline at end of file
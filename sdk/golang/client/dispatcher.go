@@ -2,30 +2,78 @@ package asr
 
 import (
 	"fmt"
-	"log"
 	"sync"
 	"time"
 )
 
-// EventDispatcher handles routing of events to appropriate handlers
+// HandlerID identifies a registered handler or subscription so it can be removed later
+type HandlerID uint64
+
+// EventTypeAny subscribes a listener registered with On to every event type
+const EventTypeAny = "*"
+
+// registeredHandler pairs an EventHandler with its ID. The mutex serializes delivery
+// to the handler so replayed events are always observed before live ones.
+type registeredHandler struct {
+	id      HandlerID
+	handler EventHandler
+	mutex   sync.Mutex
+}
+
+// eventListener is a single event type subscription created with On
+type eventListener struct {
+	id HandlerID
+	fn func(Event)
+}
+
+// EventDispatcher handles routing of events to appropriate handlers.
+//
+// Handlers may be registered and removed at any time, including while events are
+// being dispatched. A handler sees every event whose dispatch starts after its
+// registration returns, and none dispatched after its removal returns (a delivery
+// already in flight may still complete). Handler slices are copy-on-write so a
+// dispatch in progress is never affected by concurrent registration.
 type EventDispatcher struct {
 	handlers    map[string]func(Event, error)
-	handlersMap map[string][]EventHandler
+	handlersMap map[string][]*registeredHandler
+	listeners   map[string][]*eventListener
+	nextID      HandlerID
 	// For backward compatibility with simple callback interface
 	legacyHandler  RecognitionCallback
 	parser        *EventParser
+	replayBuffer  *EventReplayBuffer
+	// Hypotheses of the utterances in progress, to rebuild compact deltas
+	hypotheses    map[string][]rune
 	dispatchMutex sync.RWMutex
+	logger        Logger
 }
 
 // NewEventDispatcher creates a new event dispatcher
 func NewEventDispatcher(parser *EventParser) *EventDispatcher {
 	return &EventDispatcher{
 		handlers:     make(map[string]func(Event, error)),
-		handlersMap:  make(map[string][]EventHandler),
+		handlersMap:  make(map[string][]*registeredHandler),
+		listeners:    make(map[string][]*eventListener),
 		parser:        parser,
+		replayBuffer:  NewEventReplayBuffer(DefaultReplayBufferSize),
+		hypotheses:    make(map[string][]rune),
+		logger:        NopLogger{},
 	}
 }
 
+// SetLogger sets the logger of the dispatcher, nil discards the log output. Set it
+// before dispatching events.
+func (ed *EventDispatcher) SetLogger(logger Logger) {
+	ed.logger = loggerOrNop(logger)
+}
+
+// SetReplayBufferSize resizes the replay buffer, dropping any buffered events
+func (ed *EventDispatcher) SetReplayBufferSize(size int) {
+	ed.dispatchMutex.Lock()
+	defer ed.dispatchMutex.Unlock()
+	ed.replayBuffer = NewEventReplayBuffer(size)
+}
+
 // RegisterHandler registers a handler for specific event type
 func (ed *EventDispatcher) RegisterHandler(eventType string, handler func(Event, error)) {
 	ed.dispatchMutex.Lock()
@@ -33,32 +81,152 @@ func (ed *EventDispatcher) RegisterHandler(eventType string, handler func(Event,
 	ed.handlers[eventType] = handler
 }
 
-// RegisterEventHandler registers an event handler for OpenAI events
-func (ed *EventDispatcher) RegisterEventHandler(handler EventHandler) {
+// handledEventTypes lists the event types delivered to an EventHandler
+var handledEventTypes = []string{
+	EventTypeSessionCreated,
+	EventTypeSessionUpdated,
+	EventTypeConversationCreated,
+	EventTypeConversationItemCreated,
+	EventTypeConversationItemDeleted,
+	EventTypeInputAudioBufferAppend,
+	EventTypeInputAudioBufferCommitted,
+	EventTypeInputAudioBufferCleared,
+	EventTypeInputAudioBufferSpeechStarted,
+	EventTypeInputAudioBufferSpeechStopped,
+	EventTypeConversationItemInputAudioTranscriptionCompleted,
+	EventTypeConversationItemInputAudioTranscriptionFailed,
+	EventTypeConversationItemInputAudioTranscriptionDelta,
+	EventTypeHeartbeatPing,
+	EventTypeHeartbeatPong,
+	EventTypeError,
+}
+
+// RegisterEventHandler registers an event handler for OpenAI events and returns an ID
+// that can be passed to RemoveEventHandler
+func (ed *EventDispatcher) RegisterEventHandler(handler EventHandler) HandlerID {
 	ed.dispatchMutex.Lock()
 	defer ed.dispatchMutex.Unlock()
+	return ed.registerEventHandlerLocked(handler).id
+}
 
-	eventTypes := []string{
-		EventTypeSessionCreated,
-		EventTypeSessionUpdated,
-		EventTypeConversationCreated,
-		EventTypeConversationItemCreated,
-		EventTypeConversationItemDeleted,
-		EventTypeInputAudioBufferAppend,
-		EventTypeInputAudioBufferCommitted,
-		EventTypeInputAudioBufferCleared,
-		EventTypeInputAudioBufferSpeechStarted,
-		EventTypeInputAudioBufferSpeechStopped,
-		EventTypeConversationItemInputAudioTranscriptionCompleted,
-		EventTypeConversationItemInputAudioTranscriptionFailed,
-		EventTypeHeartbeatPing,
-		EventTypeHeartbeatPong,
-		EventTypeError,
+// registerEventHandlerLocked adds handler for every event type; caller must hold dispatchMutex
+func (ed *EventDispatcher) registerEventHandlerLocked(handler EventHandler) *registeredHandler {
+	ed.nextID++
+	entry := &registeredHandler{id: ed.nextID, handler: handler}
+
+	for _, eventType := range handledEventTypes {
+		handlers := ed.handlersMap[eventType]
+		updated := make([]*registeredHandler, len(handlers), len(handlers)+1)
+		copy(updated, handlers)
+		ed.handlersMap[eventType] = append(updated, entry)
+	}
+	return entry
+}
+
+// RegisterEventHandlerWithReplay registers an event handler and immediately replays
+// the buffered recent events to it, so handlers attached after Start still observe
+// session.created and conversation.created. Replayed events are always delivered
+// before any live event, even when registration races with dispatch.
+func (ed *EventDispatcher) RegisterEventHandlerWithReplay(handler EventHandler) HandlerID {
+	// Snapshot under the same lock Dispatch uses to record events, so every event is
+	// either replayed or delivered live to the new handler
+	ed.dispatchMutex.Lock()
+	replay := ed.replayBuffer.Snapshot()
+	entry := ed.registerEventHandlerLocked(handler)
+	// Hold the entry until replay finishes so live dispatch waits behind it
+	entry.mutex.Lock()
+	ed.dispatchMutex.Unlock()
+
+	for _, event := range replay {
+		ed.dispatchToHandler(handler, event)
 	}
+	entry.mutex.Unlock()
 
-	for _, eventType := range eventTypes {
-		ed.handlersMap[eventType] = append(ed.handlersMap[eventType], handler)
+	if len(replay) > 0 {
+		ed.logger.Debug("Replayed buffered events to late-attached handler", Fields{"component": "dispatcher", "events": len(replay)})
 	}
+	return entry.id
+}
+
+// RemoveEventHandler unregisters a handler previously returned by RegisterEventHandler
+// or On. It is safe to call from within a handler.
+func (ed *EventDispatcher) RemoveEventHandler(id HandlerID) bool {
+	ed.dispatchMutex.Lock()
+	defer ed.dispatchMutex.Unlock()
+
+	removed := false
+	for eventType, handlers := range ed.handlersMap {
+		updated := make([]*registeredHandler, 0, len(handlers))
+		for _, entry := range handlers {
+			if entry.id == id {
+				removed = true
+				continue
+			}
+			updated = append(updated, entry)
+		}
+		if len(updated) == 0 {
+			delete(ed.handlersMap, eventType)
+		} else {
+			ed.handlersMap[eventType] = updated
+		}
+	}
+
+	for eventType, listeners := range ed.listeners {
+		updated := make([]*eventListener, 0, len(listeners))
+		for _, listener := range listeners {
+			if listener.id == id {
+				removed = true
+				continue
+			}
+			updated = append(updated, listener)
+		}
+		if len(updated) == 0 {
+			delete(ed.listeners, eventType)
+		} else {
+			ed.listeners[eventType] = updated
+		}
+	}
+
+	if removed {
+		ed.logger.Debug("Removed handler", Fields{"component": "dispatcher", "handlerID": id})
+	}
+	return removed
+}
+
+// On subscribes fn to a single event type, or to every event with EventTypeAny
+func (ed *EventDispatcher) On(eventType string, fn func(Event)) HandlerID {
+	ed.dispatchMutex.Lock()
+	defer ed.dispatchMutex.Unlock()
+
+	ed.nextID++
+	listener := &eventListener{id: ed.nextID, fn: fn}
+
+	listeners := ed.listeners[eventType]
+	updated := make([]*eventListener, len(listeners), len(listeners)+1)
+	copy(updated, listeners)
+	ed.listeners[eventType] = append(updated, listener)
+
+	return listener.id
+}
+
+// Off removes a subscription created with On
+func (ed *EventDispatcher) Off(id HandlerID) bool {
+	return ed.RemoveEventHandler(id)
+}
+
+// RecentEvents returns the buffered recent events ordered from oldest to newest
+func (ed *EventDispatcher) RecentEvents() []Event {
+	ed.dispatchMutex.RLock()
+	defer ed.dispatchMutex.RUnlock()
+	return ed.replayBuffer.Snapshot()
+}
+
+// ClearReplayBuffer drops all buffered events. It takes the write lock as Dispatch
+// does to record events, so no event is recorded while the buffer is cleared.
+func (ed *EventDispatcher) ClearReplayBuffer() {
+	ed.dispatchMutex.Lock()
+	defer ed.dispatchMutex.Unlock()
+	ed.replayBuffer.Clear()
 }
 
 // RegisterLegacyHandler registers a legacy recognition callback
@@ -73,43 +241,51 @@ func (ed *EventDispatcher) Dispatch(data []byte) error {
 	// Parse event
 	event, err := ed.parser.ParseEvent(data)
 	if err != nil {
-		log.Printf("[❌ Dispatcher] Failed to parse event: %v", err)
+		ed.logger.Error("Failed to parse event", Fields{"component": "dispatcher", "error": err})
 		return fmt.Errorf("event parsing failed: %w", err)
 	}
 
-	log.Printf("[📦 Dispatcher] Dispatching event: %s (ID: %s)", event.GetType(), event.GetEventID())
+	ed.logger.Debug("Dispatching event", Fields{"component": "dispatcher", "type": event.GetType(), "eventID": event.GetEventID()})
 
 	// Get event type
 	eventType := event.GetType()
 
 	// Validate event
 	if err := ed.parser.ValidateEvent(event); err != nil {
-		log.Printf("[⚠️ Dispatcher] Event validation failed: %v", err)
+		ed.logger.Warn("Event validation failed", Fields{"component": "dispatcher", "type": event.GetType(), "error": err})
 		// Continue with dispatching even if validation fails
 	}
 
-	// Dispatch to specific handlers
-	ed.dispatchMutex.RLock()
-	specificHandlers, hasSpecific := ed.handlers[eventType]
-	allHandlers, hasAll := ed.handlersMap[eventType]
+	// Record the event for replay and snapshot handlers atomically so late-attached
+	// handlers never miss an event in between
+	ed.dispatchMutex.Lock()
+	ed.trackHypothesis(event)
+	ed.replayBuffer.Add(event)
+	specificHandler := ed.handlers[eventType]
+	allHandlers := ed.handlersMap[eventType]
+	typedListeners := ed.listeners[eventType]
+	anyListeners := ed.listeners[EventTypeAny]
 	legacyHandler := ed.legacyHandler
-	ed.dispatchMutex.RUnlock()
-
-	// Dispatch to specific handlers first
-	if hasSpecific {
-		// Call the specific handler function directly
-		if specificHandlers != nil {
-			// Use explicit function call to avoid compiler issues
-			_ = specificHandlers // Ensure the function is used
-			// Note: We'll handle specific handler errors separately for now
-		}
+	ed.dispatchMutex.Unlock()
+
+	// Dispatch to specific handler first
+	if specificHandler != nil {
+		ed.dispatchToFunc(func(e Event) { specificHandler(e, nil) }, event)
 	}
 
 	// Dispatch to all event handlers
-	if hasAll {
-		for _, handler := range allHandlers {
-			ed.dispatchToHandler(handler, event)
-		}
+	for _, entry := range allHandlers {
+		entry.mutex.Lock()
+		ed.dispatchToHandler(entry.handler, event)
+		entry.mutex.Unlock()
+	}
+
+	// Dispatch to On subscriptions
+	for _, listener := range typedListeners {
+		ed.dispatchToFunc(listener.fn, event)
+	}
+	for _, listener := range anyListeners {
+		ed.dispatchToFunc(listener.fn, event)
 	}
 
 	// Dispatch to legacy handler if registered
@@ -120,11 +296,41 @@ func (ed *EventDispatcher) Dispatch(data []byte) error {
 	return nil
 }
 
+// trackHypothesis fills Transcript of compact transcription deltas from the previous
+// hypothesis of their item; caller must hold dispatchMutex
+func (ed *EventDispatcher) trackHypothesis(event Event) {
+	switch e := event.(type) {
+	case *ConversationItemInputAudioTranscriptionDeltaEvent:
+		if e.StablePrefix != nil {
+			previous := ed.hypotheses[e.ItemID]
+			kept := min(max(*e.StablePrefix, 0), len(previous))
+			e.Transcript = string(previous[:kept]) + e.Delta
+		}
+		ed.hypotheses[e.ItemID] = []rune(e.Transcript)
+	case *ConversationItemInputAudioTranscriptionCompletedEvent:
+		delete(ed.hypotheses, e.Item.ID)
+	case *ConversationItemInputAudioTranscriptionFailedEvent:
+		delete(ed.hypotheses, e.ItemID)
+	case *InputAudioBufferClearedEvent:
+		clear(ed.hypotheses)
+	}
+}
+
+// dispatchToFunc safely calls a subscription function with panic recovery
+func (ed *EventDispatcher) dispatchToFunc(fn func(Event), event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			ed.logger.Error("Subscription panic recovered", Fields{"component": "dispatcher", "panic": r})
+		}
+	}()
+	fn(event)
+}
+
 // dispatchToHandler safely calls a handler with error handling
 func (ed *EventDispatcher) dispatchToHandler(handler EventHandler, event Event) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("[🚨 Dispatcher] Handler panic recovered: %v", r)
+			ed.logger.Error("Handler panic recovered", Fields{"component": "dispatcher", "panic": r})
 		}
 	}()
 
@@ -153,6 +359,8 @@ func (ed *EventDispatcher) dispatchToHandler(handler EventHandler, event Event)
 		handler.OnTranscriptionCompleted(e)
 	case *ConversationItemInputAudioTranscriptionFailedEvent:
 		handler.OnTranscriptionFailed(e)
+	case *ConversationItemInputAudioTranscriptionDeltaEvent:
+		handler.OnTranscriptionDelta(e)
 	case *HeartbeatPingEvent:
 		handler.OnPing(e)
 	case *HeartbeatPongEvent:
@@ -160,7 +368,7 @@ func (ed *EventDispatcher) dispatchToHandler(handler EventHandler, event Event)
 	case *ErrorEvent:
 		handler.OnError(e)
 	default:
-		log.Printf("[⚠️ Dispatcher] Unknown event type for handler: %T", event)
+		ed.logger.Warn("Unknown event type for handler", Fields{"component": "dispatcher", "event": fmt.Sprintf("%T", event)})
 	}
 }
 
@@ -183,9 +391,9 @@ func (ed *EventDispatcher) dispatchToLegacy(handler RecognitionCallback, event E
 			handler.OnRecognitionResult(e.SessionID, text)
 		}
 	case *ConversationItemInputAudioTranscriptionFailedEvent:
-		handler.OnRecognitionError(e.SessionID, NewASRError(e.Error.Code, e.Error.Message))
+		handler.OnRecognitionError(e.SessionID, e.Err())
 	case *ErrorEvent:
-		handler.OnRecognitionError(e.SessionID, NewASRError(e.Error.Code, e.Error.Message))
+		handler.OnRecognitionError(e.SessionID, e.Err())
 	default:
 		// Ignore other events for legacy interface
 	}
@@ -199,7 +407,10 @@ func (ed *EventDispatcher) GetStats() map[string]interface{} {
 	stats := map[string]interface{}{
 		"registered_handlers":     len(ed.handlers),
 		"registered_event_handlers": len(ed.handlersMap),
+		"registered_listeners":    len(ed.listeners),
 		"has_legacy_handler":      ed.legacyHandler != nil,
+		"replay_buffer_size":      ed.replayBuffer.Len(),
+		"replay_buffer_capacity":  ed.replayBuffer.Capacity(),
 	}
 
 	// Count handlers per event type
@@ -210,6 +421,9 @@ func (ed *EventDispatcher) GetStats() map[string]interface{} {
 	for eventType, handlers := range ed.handlersMap {
 		handlerCounts[eventType] = len(handlers)
 	}
+	for eventType, listeners := range ed.listeners {
+		handlerCounts[eventType] += len(listeners)
+	}
 	stats["handler_counts"] = handlerCounts
 
 	return stats
@@ -221,10 +435,11 @@ func (ed *EventDispatcher) ClearHandlers() {
 	defer ed.dispatchMutex.Unlock()
 
 	ed.handlers = make(map[string]func(Event, error))
-	ed.handlersMap = make(map[string][]EventHandler)
+	ed.handlersMap = make(map[string][]*registeredHandler)
+	ed.listeners = make(map[string][]*eventListener)
 	ed.legacyHandler = nil
 
-	log.Printf("[🧹 Dispatcher] Cleared all handlers")
+	ed.logger.Debug("Cleared all handlers", Fields{"component": "dispatcher"})
 }
 
 // RemoveHandler removes a specific handler
@@ -234,7 +449,8 @@ func (ed *EventDispatcher) RemoveHandler(eventType string) {
 
 	delete(ed.handlers, eventType)
 	delete(ed.handlersMap, eventType)
-	log.Printf("[🗑️ Dispatcher] Removed handlers for event type: %s", eventType)
+	delete(ed.listeners, eventType)
+	ed.logger.Debug("Removed handlers for event type", Fields{"component": "dispatcher", "type": eventType})
 }
 
 // IsEventSupported checks if an event type is supported
@@ -244,8 +460,9 @@ func (ed *EventDispatcher) IsEventSupported(eventType string) bool {
 
 	_, hasSpecific := ed.handlers[eventType]
 	_, hasAll := ed.handlersMap[eventType]
+	_, hasListener := ed.listeners[eventType]
 
-	return hasSpecific || hasAll
+	return hasSpecific || hasAll || hasListener
 }
 
 // GetSupportedEventTypes returns list of supported event types
@@ -335,6 +552,4 @@ func (es *EventStats) Reset() {
 	es.ErrorCount = 0
 	es.LastEventTime = time.Time{}
 	es.LastError = ""
-
-	log.Printf("[📊 Stats] Event statistics reset")
 }
\ No newline at end of file
@@ -0,0 +1,319 @@
+package asr
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func completedEvent(itemID, transcript string) map[string]any {
+	return map[string]any{
+		"type":     EventTypeConversationItemInputAudioTranscriptionCompleted,
+		"event_id": "event_" + itemID,
+		"item": map[string]any{
+			"id":      itemID,
+			"type":    "message",
+			"status":  "completed",
+			"content": []map[string]any{{"type": "transcript", "transcript": transcript}},
+		},
+		"language": "en",
+	}
+}
+
+func failedEvent(itemID, code string) map[string]any {
+	return map[string]any{
+		"type":     EventTypeConversationItemInputAudioTranscriptionFailed,
+		"event_id": "event_" + itemID,
+		"item_id":  itemID,
+		"error":    map[string]any{"type": "server_error", "code": code, "message": "recognition failed"},
+	}
+}
+
+func TestDispatcherRebuildsCompactDeltas(t *testing.T) {
+	type delta struct {
+		itemID       string
+		delta        string
+		transcript   string // sent in full mode
+		stablePrefix *int
+		want         string
+	}
+	prefix := func(n int) *int { return &n }
+	tests := []struct {
+		name   string
+		events []delta
+	}{
+		{
+			name: "appended words",
+			events: []delta{
+				{itemID: "item_1", delta: "hello", stablePrefix: prefix(0), want: "hello"},
+				{itemID: "item_1", delta: " world", stablePrefix: prefix(5), want: "hello world"},
+			},
+		},
+		{
+			name: "revised tail",
+			events: []delta{
+				{itemID: "item_1", delta: "I scream", stablePrefix: prefix(0), want: "I scream"},
+				{itemID: "item_1", delta: " ice cream", stablePrefix: prefix(1), want: "I ice cream"},
+			},
+		},
+		{
+			name: "prefix counted in runes",
+			events: []delta{
+				{itemID: "item_1", delta: "你好世界", stablePrefix: prefix(0), want: "你好世界"},
+				{itemID: "item_1", delta: "朋友", stablePrefix: prefix(2), want: "你好朋友"},
+			},
+		},
+		{
+			name: "prefix beyond the hypothesis",
+			events: []delta{
+				{itemID: "item_1", delta: "hi", stablePrefix: prefix(0), want: "hi"},
+				{itemID: "item_1", delta: "!", stablePrefix: prefix(10), want: "hi!"},
+			},
+		},
+		{
+			name: "items kept apart",
+			events: []delta{
+				{itemID: "item_1", delta: "one", stablePrefix: prefix(0), want: "one"},
+				{itemID: "item_2", delta: "two", stablePrefix: prefix(0), want: "two"},
+				{itemID: "item_1", delta: " more", stablePrefix: prefix(3), want: "one more"},
+			},
+		},
+		{
+			name: "full delta resets the hypothesis",
+			events: []delta{
+				{itemID: "item_1", delta: "hel", transcript: "hel", want: "hel"},
+				{itemID: "item_1", delta: "lo", stablePrefix: prefix(3), want: "hello"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dispatcher := NewEventDispatcher(NewEventParser())
+			var got []string
+			dispatcher.On(EventTypeConversationItemInputAudioTranscriptionDelta, func(event Event) {
+				got = append(got, event.(*ConversationItemInputAudioTranscriptionDeltaEvent).Transcript)
+			})
+			for i, d := range tt.events {
+				event := map[string]any{
+					"type":       EventTypeConversationItemInputAudioTranscriptionDelta,
+					"event_id":   "event_" + d.itemID,
+					"item_id":    d.itemID,
+					"delta":      d.delta,
+					"transcript": d.transcript,
+				}
+				if d.stablePrefix != nil {
+					event["stable_prefix"] = *d.stablePrefix
+				}
+				data, _ := json.Marshal(event)
+				if err := dispatcher.Dispatch(data); err != nil {
+					t.Fatal(err)
+				}
+				if got[i] != d.want {
+					t.Fatalf("delta %d rebuilt %q, want %q", i, got[i], d.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatcherForgetsSettledHypotheses(t *testing.T) {
+	dispatcher := NewEventDispatcher(NewEventParser())
+	for _, event := range []map[string]any{
+		{"type": EventTypeConversationItemInputAudioTranscriptionDelta, "event_id": "e1", "item_id": "item_1", "delta": "a", "stable_prefix": 0},
+		{"type": EventTypeConversationItemInputAudioTranscriptionDelta, "event_id": "e2", "item_id": "item_2", "delta": "b", "stable_prefix": 0},
+		{"type": EventTypeConversationItemInputAudioTranscriptionDelta, "event_id": "e3", "item_id": "item_3", "delta": "c", "stable_prefix": 0},
+		completedEvent("item_1", "a"),
+		failedEvent("item_2", "asr_timeout"),
+	} {
+		data, _ := json.Marshal(event)
+		if err := dispatcher.Dispatch(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := dispatcher.hypotheses["item_3"]; len(dispatcher.hypotheses) != 1 || !ok {
+		t.Errorf("hypotheses %v, want only item_3", dispatcher.hypotheses)
+	}
+
+	data, _ := json.Marshal(map[string]any{"type": EventTypeInputAudioBufferCleared, "event_id": "e4"})
+	if err := dispatcher.Dispatch(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(dispatcher.hypotheses) != 0 {
+		t.Errorf("hypotheses %v kept after the buffer was cleared", dispatcher.hypotheses)
+	}
+}
+
+// sequenceHandler records the sequence numbers of the speech_started events it receives
+type sequenceHandler struct {
+	DefaultEventHandler
+	mutex     sync.Mutex
+	sequences []int
+}
+
+func (h *sequenceHandler) OnSpeechStarted(event *InputAudioBufferSpeechStartedEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.sequences = append(h.sequences, event.AudioStartMs)
+}
+
+func (h *sequenceHandler) received() []int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]int(nil), h.sequences...)
+}
+
+// dispatchSequence dispatches count speech_started events numbered from first in
+// their audio_start_ms
+func dispatchSequence(t *testing.T, dispatcher *EventDispatcher, first, count int) {
+	for n := first; n < first+count; n++ {
+		data, _ := json.Marshal(map[string]any{
+			"type":           EventTypeInputAudioBufferSpeechStarted,
+			"event_id":       fmt.Sprintf("event_%d", n),
+			"item_id":        fmt.Sprintf("item_%d", n),
+			"audio_start_ms": n,
+		})
+		if err := dispatcher.Dispatch(data); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+}
+
+// sequenceOf returns the sequence numbers of speech_started events
+func sequenceOf(events []Event) []int {
+	sequences := make([]int, 0, len(events))
+	for _, event := range events {
+		sequences = append(sequences, event.(*InputAudioBufferSpeechStartedEvent).AudioStartMs)
+	}
+	return sequences
+}
+
+// checkConsecutive fails unless sequences counts up by one from its first number
+func checkConsecutive(t *testing.T, sequences []int, what string) {
+	t.Helper()
+	for i := 1; i < len(sequences); i++ {
+		if sequences[i] != sequences[i-1]+1 {
+			t.Fatalf("%s %d follows %d: %v", what, sequences[i], sequences[i-1], sequences)
+		}
+	}
+}
+
+func TestRegisterWithReplayWhileDispatching(t *testing.T) {
+	const events = 200
+	for round := 0; round < 20; round++ {
+		dispatcher := NewEventDispatcher(NewEventParser())
+		handler := &sequenceHandler{}
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			dispatchSequence(t, dispatcher, 0, events)
+		}()
+		go func() {
+			defer wg.Done()
+			dispatcher.RegisterEventHandlerWithReplay(handler)
+		}()
+		wg.Wait()
+
+		// Replayed and live events join without a gap or a repeat
+		got := handler.received()
+		checkConsecutive(t, got, "received")
+		if len(got) == 0 || got[len(got)-1] != events-1 {
+			t.Fatalf("round %d received %v, want the events up to %d", round, got, events-1)
+		}
+		if got[0] != 0 && len(got) < DefaultReplayBufferSize {
+			t.Fatalf("round %d replayed from %d, want the whole replay buffer", round, got[0])
+		}
+	}
+}
+
+func TestReplayBufferReplaysRecentEvents(t *testing.T) {
+	dispatcher := NewEventDispatcher(NewEventParser())
+	dispatchSequence(t, dispatcher, 0, DefaultReplayBufferSize+10)
+
+	handler := &sequenceHandler{}
+	dispatcher.RegisterEventHandlerWithReplay(handler)
+	dispatchSequence(t, dispatcher, DefaultReplayBufferSize+10, 1)
+
+	got := handler.received()
+	if len(got) != DefaultReplayBufferSize+1 || got[0] != 10 {
+		t.Fatalf("received %v, want the last %d events and the live one", got, DefaultReplayBufferSize)
+	}
+	checkConsecutive(t, got, "received")
+
+	dispatcher.ClearReplayBuffer()
+	late := &sequenceHandler{}
+	dispatcher.RegisterEventHandlerWithReplay(late)
+	if got := late.received(); len(got) != 0 {
+		t.Errorf("replayed %v after clearing the buffer", got)
+	}
+}
+
+func TestClearReplayBufferWhileDispatching(t *testing.T) {
+	const events = 500
+	dispatcher := NewEventDispatcher(NewEventParser())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatchSequence(t, dispatcher, 0, events)
+	}()
+	for cleared := false; !cleared; {
+		select {
+		case <-done:
+			cleared = true
+		default:
+		}
+		dispatcher.ClearReplayBuffer()
+		// Whatever was recorded since the clear is a run of the newest events
+		checkConsecutive(t, sequenceOf(dispatcher.RecentEvents()), "buffered")
+	}
+
+	dispatchSequence(t, dispatcher, events, 3)
+	handler := &sequenceHandler{}
+	dispatcher.RegisterEventHandlerWithReplay(handler)
+	if got, want := handler.received(), []int{events, events + 1, events + 2}; !slices.Equal(got, want) {
+		t.Errorf("replayed %v after the last clear, want %v", got, want)
+	}
+}
+
+func TestOffStopsDeliveryWhileDispatching(t *testing.T) {
+	dispatcher := NewEventDispatcher(NewEventParser())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatchSequence(t, dispatcher, 0, 200)
+	}()
+	// Subscriptions come and go while events are dispatched
+	for i := 0; i < 50; i++ {
+		id := dispatcher.On(EventTypeInputAudioBufferSpeechStarted, func(Event) {})
+		if !dispatcher.Off(id) {
+			t.Fatalf("Off(%d) found no subscription", id)
+		}
+	}
+	<-done
+
+	var mutex sync.Mutex
+	var got []int
+	id := dispatcher.On(EventTypeInputAudioBufferSpeechStarted, func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		got = append(got, event.(*InputAudioBufferSpeechStartedEvent).AudioStartMs)
+	})
+	dispatchSequence(t, dispatcher, 200, 2)
+	dispatcher.Off(id)
+	dispatchSequence(t, dispatcher, 202, 2)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if want := []int{200, 201}; !slices.Equal(got, want) {
+		t.Errorf("listener received %v, want %v", got, want)
+	}
+	if dispatcher.Off(id) {
+		t.Error("Off removed a subscription twice")
+	}
+}
@@ -25,11 +25,16 @@ var (
 	ErrConnectionTimeout     = errors.New("connection timeout")
 	ErrNotConnected        = errors.New("not connected")
 	ErrAlreadyConnected     = errors.New("already connected")
+	// ErrUnauthorized the server refused the API key or token of the connection
+	ErrUnauthorized = errors.New("unauthorized")
 
 	// Session errors
 	ErrSessionNotFound      = errors.New("session not found")
 	ErrSessionNotReady     = errors.New("session not ready")
 	ErrInvalidSessionState = errors.New("invalid session state")
+	// ErrSessionNotResumed the server could not resume the session after a reconnect,
+	// recognition continues in a new session
+	ErrSessionNotResumed = errors.New("session not resumed")
 
 	// Audio errors
 	ErrInvalidSampleRate    = errors.New("invalid sample rate")
@@ -55,8 +60,37 @@ var (
 
 	// State errors
 	ErrInvalidState        = errors.New("invalid state")
+
+	// Server error categories, an *ASRError received from the server matches the one
+	// of its category with errors.Is; invalid_audio_format matches ErrInvalidAudioFormat
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrSessionLimit   = errors.New("session limit reached")
+	ErrASRUnavailable = errors.New("ASR engine unavailable")
+	ErrRateLimited    = errors.New("rate limited")
+	ErrServerInternal = errors.New("internal server error")
+)
+
+// Error categories of the server, sent in error.category of error events and
+// transcription failures next to the specific error.code
+const (
+	ErrorCategoryInvalidRequest     = "invalid_request"
+	ErrorCategoryInvalidAudioFormat = "invalid_audio_format"
+	ErrorCategorySessionLimit       = "session_limit"
+	ErrorCategoryASRUnavailable     = "asr_unavailable"
+	ErrorCategoryRateLimited        = "rate_limited"
+	ErrorCategoryInternal           = "internal"
 )
 
+// categoryErrors maps the error categories of the server to their errors
+var categoryErrors = map[string]error{
+	ErrorCategoryInvalidRequest:     ErrInvalidRequest,
+	ErrorCategoryInvalidAudioFormat: ErrInvalidAudioFormat,
+	ErrorCategorySessionLimit:       ErrSessionLimit,
+	ErrorCategoryASRUnavailable:     ErrASRUnavailable,
+	ErrorCategoryRateLimited:        ErrRateLimited,
+	ErrorCategoryInternal:           ErrServerInternal,
+}
+
 // RecognitionError represents recognition error structure
 type RecognitionError struct {
 	Code    int
@@ -75,11 +109,15 @@ func (e *RecognitionError) Unwrap() error {
 	return e.Err
 }
 
-// ASRError represents a detailed error with error code and message
+// ASRError represents a detailed error with error code and message. Errors of the
+// server carry the category of their code, which errors.Is matches against ErrRateLimited,
+// ErrASRUnavailable and the other category errors.
 type ASRError struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-	Details string `json:"details,omitempty"`
+	Code     string `json:"code"`
+	Category string `json:"category,omitempty"` // one of the ErrorCategory* categories
+	Message  string `json:"message"`
+	Param    string `json:"param,omitempty"` // the field of the client event at fault
+	Details  string `json:"details,omitempty"`
 }
 
 func (e *ASRError) Error() string {
@@ -89,6 +127,32 @@ func (e *ASRError) Error() string {
 	return fmt.Sprintf("[%s] %s", e.Code, e.Message)
 }
 
+// Is reports whether target is the error of the category of e
+func (e *ASRError) Is(target error) bool {
+	category, ok := categoryErrors[e.Category]
+	return ok && target == category
+}
+
+// Retryable reports whether the request that failed with e may succeed when repeated
+// later: on rate limits, session limits and ASR engine failures
+func (e *ASRError) Retryable() bool {
+	switch e.Category {
+	case ErrorCategorySessionLimit, ErrorCategoryASRUnavailable, ErrorCategoryRateLimited:
+		return true
+	}
+	return false
+}
+
+// Err returns the error of the event
+func (e *ErrorEvent) Err() *ASRError {
+	return &ASRError{Code: e.Error.Code, Category: e.Error.Category, Message: e.Error.Message, Param: e.Error.Param}
+}
+
+// Err returns the error the transcription failed with
+func (e *ConversationItemInputAudioTranscriptionFailedEvent) Err() *ASRError {
+	return &ASRError{Code: e.Error.Code, Category: e.Error.Category, Message: e.Error.Message, Param: e.Error.Param}
+}
+
 // NewASRError creates a new ASR error
 func NewASRError(code, message string, details ...string) *ASRError {
 	err := &ASRError{
@@ -122,7 +186,8 @@ func IsConnectionError(err error) bool {
 func IsSessionError(err error) bool {
 	return err == ErrSessionNotFound ||
 		err == ErrSessionNotReady ||
-		err == ErrInvalidSessionState
+		err == ErrInvalidSessionState ||
+		err == ErrSessionNotResumed
 }
 
 // IsAudioError checks if error is audio related
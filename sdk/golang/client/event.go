@@ -2,7 +2,6 @@ package asr
 
 import (
 	"encoding/json"
-	"log"
 )
 
 
@@ -27,7 +26,7 @@ func (r *Recognizer) sendErrorEvent(message string, err error) {
 
 	eventData, marshalErr := json.Marshal(errorEvent)
 	if marshalErr != nil {
-		log.Printf("Failed to marshal error event: %v", marshalErr)
+		r.logger.Error("Failed to marshal error event", Fields{"component": "recognizer", "error": marshalErr})
 		return
 	}
 
@@ -20,6 +20,20 @@ const (
 	EventTypeConversationItemDeleted                  = "conversation.item.deleted"
 	EventTypeInputAudioBufferCleared                  = "input_audio_buffer.cleared"
 	EventTypeError                                  = "error"
+	EventTypeConversationTruncated                  = "conversation.truncated"
+	EventTypeVADSegment                             = "vad.segment"
+	EventTypeSessionAnalytics                       = "session.analytics"
+	EventTypeCaptionCue                             = "caption.cue"
+	EventTypeInputAudioBufferQualityWarning         = "input_audio_buffer.quality_warning"
+	EventTypeSessionLimitWarning                    = "session.limit_warning"
+	EventTypeSessionLimitExceeded                   = "session.limit_exceeded"
+	EventTypeTranscriptionWindow                    = "transcription.window"
+	EventTypeConversationItemInputAudioTranscriptionDelta= "conversation.item.input_audio_transcription.delta"
+	EventTypeSessionBandwidth                       = "session.bandwidth"
+	EventTypeSessionResume                          = "session.resume"
+	EventTypeSessionResumed                         = "session.resumed"
+	EventTypeSessionStats                           = "session.stats"
+	EventTypeSessionStatsRequest                    = "session.stats.request"
 )
 
 // BaseEvent represents the common structure for all OpenAI events
@@ -27,6 +41,7 @@ type BaseEvent struct {
 	Type      string `json:"type"`
 	EventID   string `json:"event_id,omitempty"`
 	SessionID string `json:"session_id,omitempty"`
+	Seq       uint64 `json:"seq,omitempty"` // per-session sequence number of server events
 }
 
 // SessionCreatedEvent represents session.created event
@@ -38,6 +53,135 @@ type SessionCreatedEvent struct {
 		Model      string   `json:"model"`
 		Modalities []string `json:"modalities"`
 	} `json:"session"`
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	ResumeToken  string        `json:"resume_token,omitempty"` // authorizes session.resume after a dropped connection
+}
+
+// Transcript formatting profiles
+const (
+	FormatProfileRaw      = "raw"
+	FormatProfileCaptions = "captions"
+	FormatProfileDocument = "document"
+)
+
+// TranscriptFormat selects the server-side formatting profile applied to transcripts.
+// "captions" uppercases, strips punctuation and wraps lines; "document" applies
+// sentence case, full punctuation and paragraphs.
+type TranscriptFormat struct {
+	Profile            string `json:"profile"`
+	MaxLineLength      int    `json:"max_line_length,omitempty"`
+	MaxLines           int    `json:"max_lines,omitempty"`
+	ParagraphSentences int    `json:"paragraph_sentences,omitempty"`
+}
+
+// CaptionConfig enables caption.cue events, splitting each transcript into timed
+// caption cues of at most MaxLines lines of MaxLineLength characters
+type CaptionConfig struct {
+	Enabled       bool  `json:"enabled"`
+	MaxLineLength int   `json:"max_line_length,omitempty"`
+	MaxLines      int   `json:"max_lines,omitempty"`
+	MinDurationMs int64 `json:"min_duration_ms,omitempty"`
+}
+
+// Recognition modes
+const (
+	RecognitionModeVAD           = "vad"
+	RecognitionModeSlidingWindow = "sliding_window"
+)
+
+// RecognitionConfig selects how the server transcribes the session's audio. In
+// sliding_window mode the server periodically transcribes the most recent WindowMs of
+// audio every StepMs and sends the merged running transcript as transcription.window
+// events, even when the speaker never pauses long enough for a VAD commit.
+type RecognitionConfig struct {
+	Mode     string `json:"mode"`
+	WindowMs int    `json:"window_ms,omitempty"`
+	StepMs   int    `json:"step_ms,omitempty"`
+}
+
+// NoiseReductionConfig turns the server denoiser on or off for the session. Strength
+// blends the denoised into the original audio, from 0 (off) to 1 (fully denoised).
+type NoiseReductionConfig struct {
+	Type     string   `json:"type,omitempty"` // "near_field" or "far_field"
+	Enabled  *bool    `json:"enabled,omitempty"`
+	Strength *float64 `json:"strength,omitempty"`
+}
+
+// GainControlConfig enables the server's automatic gain control for the session,
+// zero values keep the server settings
+type GainControlConfig struct {
+	Enabled    bool    `json:"enabled"`
+	TargetDBFS float64 `json:"target_dbfs,omitempty"`
+	MaxGainDB  float64 `json:"max_gain_db,omitempty"`
+	AttackMs   float64 `json:"attack_ms,omitempty"`
+	ReleaseMs  float64 `json:"release_ms,omitempty"`
+}
+
+// AudioQuality describes the quality of an utterance's input audio as scored by the server
+type AudioQuality struct {
+	SNRDb         float64 `json:"snr_db"`
+	ClippingRatio float64 `json:"clipping_ratio"`
+	BandwidthHz   float64 `json:"bandwidth_hz"`
+	Score         float64 `json:"score"` // 0 (unusable) to 1 (clean)
+}
+
+// QualityStats aggregates the audio quality of a session's utterances
+type QualityStats struct {
+	Utterances   int     `json:"utterances"`
+	LowQuality   int     `json:"low_quality"`
+	AverageScore float64 `json:"average_score"`
+	AverageSNRDb float64 `json:"average_snr_db"`
+}
+
+// Usage limits reported in limit events
+const (
+	LimitSessionDuration = "session_duration"
+	LimitAudioMinutes    = "audio_minutes"
+)
+
+// LimitDetails describes a usage limit of the session's API key tier
+type LimitDetails struct {
+	Name             string  `json:"name"`
+	Tier             string  `json:"tier"`
+	UsedSeconds      float64 `json:"used_seconds"`
+	LimitSeconds     float64 `json:"limit_seconds"`
+	RemainingSeconds float64 `json:"remaining_seconds"`
+}
+
+// FeatureStatus describes whether an optional server subsystem is active
+type FeatureStatus struct {
+	Enabled bool   `json:"enabled"`
+	Backend string `json:"backend,omitempty"`
+}
+
+// Capabilities describes the feature set of the deployed server, as reported in
+// session.created and by GET /v1/capabilities
+type Capabilities struct {
+	Version     string            `json:"version"`
+	VAD         FeatureStatus     `json:"vad"`
+	Denoiser    FeatureStatus     `json:"denoiser"`
+	Diarization FeatureStatus     `json:"diarization"`
+	Translation FeatureStatus     `json:"translation"`
+	Storage     FeatureStatus     `json:"storage"` // transcripts: "memory", "sqlite" or "postgres"
+	// Where session state is shared for resume and cluster takeover: "memory" or "redis"
+	SessionStore FeatureStatus `json:"session_store"`
+	// Where saved audio and transcripts are written: "local" or "s3"
+	ObjectStorage FeatureStatus     `json:"object_storage"`
+	Providers     map[string]string `json:"providers"`
+	Features      []string          `json:"features"`
+}
+
+// HasFeature reports whether the server advertises the named feature
+func (c *Capabilities) HasFeature(name string) bool {
+	if c == nil {
+		return false
+	}
+	for _, feature := range c.Features {
+		if feature == name {
+			return true
+		}
+	}
+	return false
 }
 
 // SessionUpdateEvent represents session.update event
@@ -53,14 +197,18 @@ type SessionUpdateEvent struct {
 			SampleRate     int    `json:"sample_rate"`
 			Channels       int    `json:"channels"`
 		} `json:"input_audio_format,omitempty"`
+		InputEncoding *string `json:"input_encoding,omitempty"`
 		OutputAudioFormat struct {
 			Type       string `json:"type"`
 			SampleRate int    `json:"sample_rate"`
 			Voice      string `json:"voice,omitempty"`
 		} `json:"output_audio_format,omitempty"`
 		InputAudioTranscription *struct {
-			Model    string `json:"model"`
-			Language string `json:"language"`
+			Model          string `json:"model"`
+			Language       string `json:"language"`
+			InterimResults bool   `json:"interim_results,omitempty"`
+			DeltaMode      string `json:"delta_mode,omitempty"`
+			Hints          []string `json:"hints,omitempty"`
 		} `json:"input_audio_transcription,omitempty"`
 		TurnDetection *struct {
 			Type              string  `json:"type"`
@@ -70,6 +218,15 @@ type SessionUpdateEvent struct {
 		} `json:"turn_detection,omitempty"`
 		Tools []interface{} `json:"tools,omitempty"`
 		ToolChoice string `json:"tool_choice,omitempty"`
+		DeterministicSeed *int64 `json:"deterministic_seed,omitempty"`
+		TranscriptFormat *TranscriptFormat `json:"transcript_format,omitempty"`
+		Captions *CaptionConfig `json:"captions,omitempty"`
+		Recognition *RecognitionConfig `json:"recognition,omitempty"`
+		Pipeline *string `json:"pipeline,omitempty"`
+		BandwidthReportSeconds *int `json:"bandwidth_report_seconds,omitempty"`
+		StatsReportSeconds *int `json:"stats_report_seconds,omitempty"`
+		InputAudioNoiseReduction *NoiseReductionConfig `json:"input_audio_noise_reduction,omitempty"`
+		InputAudioGainControl *GainControlConfig `json:"input_audio_gain_control,omitempty"`
 	} `json:"session"`
 }
 
@@ -97,6 +254,10 @@ type ConversationCreatedEvent struct {
 type InputAudioBufferAppendEvent struct {
 	BaseEvent
 	Audio string `json:"audio"` // Base64 encoded audio data
+	CRC32 *uint32 `json:"crc32,omitempty"` // Optional IEEE CRC32 of the decoded audio bytes
+
+	// Audio bytes sent as a binary frame instead of Audio, see Config.BinaryAudio
+	raw []byte
 }
 
 // InputAudioBufferCommitEvent represents input_audio_buffer.commit event
@@ -107,6 +268,11 @@ type InputAudioBufferCommitEvent struct {
 // InputAudioBufferCommittedEvent represents input_audio_buffer.committed event
 type InputAudioBufferCommittedEvent struct {
 	BaseEvent
+	CommitEventID string `json:"commit_event_id,omitempty"` // event_id of the commit answered
+	ItemID       string `json:"item_id,omitempty"`      // item the commit created, empty when there was no audio
+	DurationMs   int64  `json:"duration_ms"`            // committed audio
+	SegmentCount int    `json:"segment_count"`          // speech segments of the committed audio, 0 without VAD
+	Channel      *int   `json:"channel,omitempty"`      // input channel of multi-channel sessions
 }
 
 // InputAudioBufferClearEvent represents input_audio_buffer.clear event
@@ -117,15 +283,25 @@ type InputAudioBufferClearEvent struct {
 // InputAudioBufferSpeechStartedEvent represents input_audio_buffer.speech_started event
 type InputAudioBufferSpeechStartedEvent struct {
 	BaseEvent
-	AudioStartMs int `json:"audio_start_ms"`
+	AudioStartMs int  `json:"audio_start_ms"`
+	Channel      *int `json:"channel,omitempty"` // input channel of multi-channel sessions
 }
 
 // InputAudioBufferSpeechStoppedEvent represents input_audio_buffer.speech_stopped event
 type InputAudioBufferSpeechStoppedEvent struct {
 	BaseEvent
-	AudioEndMs int `json:"audio_end_ms"`
+	AudioEndMs int  `json:"audio_end_ms"`
+	Channel    *int `json:"channel,omitempty"` // input channel of multi-channel sessions
+	// Why the turn ended when not at a pause: SpeechStoppedReasonMaxDuration means the
+	// server committed the speech itself and the client must not commit
+	Reason string `json:"reason,omitempty"`
 }
 
+// SpeechStoppedReasonMaxDuration is the reason of input_audio_buffer.speech_stopped when
+// the utterance reached the server's vad.max_utterance_seconds. The server commits it
+// and the speech that follows starts the next item.
+const SpeechStoppedReasonMaxDuration = "max_duration"
+
 // ConversationItemCreatedEvent represents conversation.item.created event
 type ConversationItemCreatedEvent struct {
 	BaseEvent
@@ -139,6 +315,7 @@ type ConversationItemCreatedEvent struct {
 		} `json:"audio,omitempty"`
 		Content   []interface{} `json:"content,omitempty"`
 	} `json:"item"`
+	Channel *int `json:"channel,omitempty"` // input channel of multi-channel sessions
 }
 
 // ConversationItemInputAudioTranscriptionCompletedEvent represents transcription completed event
@@ -153,6 +330,11 @@ type ConversationItemInputAudioTranscriptionCompletedEvent struct {
 			Transcript string `json:"transcript"`
 		} `json:"content"`
 	} `json:"item"`
+	Quality  *AudioQuality `json:"quality,omitempty"`
+	Channel  *int          `json:"channel,omitempty"`  // input channel of multi-channel sessions
+	Language string        `json:"language,omitempty"` // detected language when the session's language is unset or "auto"
+	AudioStartMs int64     `json:"audio_start_ms,omitempty"` // start of the item's speech in the session audio
+	AudioEndMs   int64     `json:"audio_end_ms,omitempty"`   // end of the item's speech in the session audio
 }
 
 // ConversationItemInputAudioTranscriptionFailedEvent represents transcription failed event
@@ -160,11 +342,13 @@ type ConversationItemInputAudioTranscriptionFailedEvent struct {
 	BaseEvent
 	ItemID string `json:"item_id"`
 	Error struct {
-		Type    string `json:"type"`
-		Code    string `json:"code"`
-		Message string `json:"message"`
-		Param   string `json:"param,omitempty"`
+		Type     string `json:"type"`
+		Code     string `json:"code"`
+		Category string `json:"category,omitempty"` // one of the ErrorCategory* categories
+		Message  string `json:"message"`
+		Param    string `json:"param,omitempty"`
 	} `json:"error"`
+	Channel *int `json:"channel,omitempty"` // input channel of multi-channel sessions
 }
 
 // ConversationItemDeletedEvent represents conversation.item.deleted event
@@ -194,13 +378,226 @@ type HeartbeatPongEvent struct {
 type ErrorEvent struct {
 	BaseEvent
 	Error struct {
-		Type    string `json:"type"`
-		Code    string `json:"code"`
-		Message string `json:"message"`
-		Param   string `json:"param,omitempty"`
+		Type     string `json:"type"`
+		Code     string `json:"code"`
+		Category string `json:"category,omitempty"` // one of the ErrorCategory* categories
+		Message  string `json:"message"`
+		Param    string `json:"param,omitempty"`
+		// Set with code rate_limit_exceeded, the limit that refused the session or
+		// dropped the event
+		RateLimit *RateLimitDetails `json:"rate_limit,omitempty"`
 	} `json:"error"`
 }
 
+// RateLimitDetails describes a per-client rate limit of the server: sessions_per_key,
+// sessions_per_ip, audio_seconds_per_minute or events_per_second
+type RateLimitDetails struct {
+	Name         string  `json:"name"`
+	Limit        float64 `json:"limit"`
+	RetryAfterMs int64   `json:"retry_after_ms,omitempty"`
+}
+
+// ConversationTruncatedEvent represents conversation.truncated event, sent when the server
+// evicts the oldest conversation items to respect its configured limits
+type ConversationTruncatedEvent struct {
+	BaseEvent
+	ItemIDs        []string `json:"item_ids"`
+	Reason         string   `json:"reason"`
+	RemainingItems int      `json:"remaining_items"`
+}
+
+// VADSegmentEvent represents vad.segment event, describing a speech segment detected by
+// the server VAD independently of its transcription. Times are relative to the start of the session audio.
+type VADSegmentEvent struct {
+	BaseEvent
+	SegmentIndex int     `json:"segment_index"`
+	StartMs      int64   `json:"start_ms"`
+	EndMs        int64   `json:"end_ms"`
+	DurationMs   int64   `json:"duration_ms"`
+	RMS          float64 `json:"rms"`
+	Channel      *int    `json:"channel,omitempty"` // input channel of multi-channel sessions
+}
+
+// SpeakerAnalytics holds the talk-time statistics of a single speaker or channel
+type SpeakerAnalytics struct {
+	Speaker       string `json:"speaker"`
+	TalkTimeMs    int64  `json:"talk_time_ms"`
+	Segments      int    `json:"segments"`
+	Interruptions int    `json:"interruptions"`
+	OverlapMs     int64  `json:"overlap_ms"`
+}
+
+// SessionAnalytics summarises talk time, silence, interruptions and overlap of a session
+type SessionAnalytics struct {
+	DurationMs    int64                        `json:"duration_ms"`
+	TalkTimeMs    int64                        `json:"talk_time_ms"`
+	SilenceMs     int64                        `json:"silence_ms"`
+	TalkRatio     float64                      `json:"talk_ratio"`
+	OverlapMs     int64                        `json:"overlap_ms"`
+	Interruptions int                          `json:"interruptions"`
+	Speakers      map[string]*SpeakerAnalytics `json:"speakers"`
+	Quality       *QualityStats                `json:"quality,omitempty"`
+	Bandwidth     *BandwidthStats              `json:"bandwidth,omitempty"`
+}
+
+// SessionAnalyticsEvent represents session.analytics event, sent by the server in
+// reply to a graceful close of the connection
+type SessionAnalyticsEvent struct {
+	BaseEvent
+	Analytics SessionAnalytics `json:"analytics"`
+}
+
+// CaptionCueEvent represents caption.cue event, a block of display lines of a
+// transcript with its timing in the session audio
+type CaptionCueEvent struct {
+	BaseEvent
+	ItemID   string   `json:"item_id"`
+	CueIndex int      `json:"cue_index"`
+	StartMs  int64    `json:"start_ms"`
+	EndMs    int64    `json:"end_ms"`
+	Lines    []string `json:"lines"`
+	Final    bool     `json:"final"`
+}
+
+// Transcription delta modes
+const (
+	DeltaModeFull    = "full"
+	DeltaModeCompact = "compact"
+)
+
+// Audio quality warning types
+const (
+	QualityWarningClipping = "clipping"
+	QualityWarningDCOffset = "dc_offset"
+)
+
+// InputAudioBufferQualityWarningEvent represents input_audio_buffer.quality_warning event,
+// sent when appended audio shows sustained clipping or DC offset. Applications can use it
+// to prompt users to lower their input gain or fix their capture chain.
+type InputAudioBufferQualityWarningEvent struct {
+	BaseEvent
+	Warning       string  `json:"warning"` // "clipping" or "dc_offset"
+	Message       string  `json:"message"`
+	ClippingRatio float64 `json:"clipping_ratio,omitempty"`
+	DCOffset      float64 `json:"dc_offset,omitempty"`
+	AudioStartMs  int64   `json:"audio_start_ms"`
+	AudioEndMs    int64   `json:"audio_end_ms"`
+}
+
+// SessionLimitWarningEvent represents session.limit_warning event, sent once per limit
+// when usage of the API key tier approaches it
+type SessionLimitWarningEvent struct {
+	BaseEvent
+	Limit LimitDetails `json:"limit"`
+}
+
+// SessionLimitExceededEvent represents session.limit_exceeded event, the last event
+// before the server closes a session that reached a usage limit
+type SessionLimitExceededEvent struct {
+	BaseEvent
+	Limit LimitDetails `json:"limit"`
+}
+
+// TranscriptionWindowEvent represents transcription.window event, the running transcript
+// of a sliding_window session after the latest window was merged. Text replaces the
+// previously received text; ReplacedWords trailing words of it were revised.
+type TranscriptionWindowEvent struct {
+	BaseEvent
+	Revision      int    `json:"revision"`
+	Text          string `json:"text"`
+	Delta         string `json:"delta"`
+	ReplacedWords int    `json:"replaced_words"`
+	WindowStartMs int64  `json:"window_start_ms"`
+	WindowEndMs   int64  `json:"window_end_ms"`
+}
+
+// ConversationItemInputAudioTranscriptionDeltaEvent represents an interim hypothesis of the
+// utterance in progress, sent when interim results are enabled. Hypotheses may revise
+// earlier words: Transcript is the full current hypothesis, Delta the appended text and
+// ReplacedWords the number of trailing words of the previous hypothesis it replaces.
+// The committed item and its completed event use the same ItemID.
+//
+// Compact deltas (Config.CompactDeltas) carry StablePrefix, the characters of the
+// previous hypothesis kept, and in Delta the text following them. The dispatcher
+// rebuilds Transcript before handlers see the event.
+type ConversationItemInputAudioTranscriptionDeltaEvent struct {
+	BaseEvent
+	ItemID        string `json:"item_id"`
+	ContentIndex  int    `json:"content_index"`
+	Delta         string `json:"delta"`
+	Transcript    string `json:"transcript"`
+	ReplacedWords int    `json:"replaced_words"`
+	StablePrefix  *int   `json:"stable_prefix,omitempty"`
+}
+
+// BandwidthStats counts the bytes a session moved over its WebSocket. Audio and
+// transcript bytes are payload, the rest of the traffic is protocol overhead.
+type BandwidthStats struct {
+	DurationMs              int64   `json:"duration_ms"`
+	AudioMs                 int64   `json:"audio_ms"`
+	InboundBytes            int64   `json:"inbound_bytes"`
+	InboundAudioBytes       int64   `json:"inbound_audio_bytes"`
+	InboundOverheadBytes    int64   `json:"inbound_overhead_bytes"`
+	InboundMessages         int64   `json:"inbound_messages"`
+	OutboundBytes           int64   `json:"outbound_bytes"`
+	OutboundTranscriptBytes int64   `json:"outbound_transcript_bytes"`
+	OutboundOverheadBytes   int64   `json:"outbound_overhead_bytes"`
+	OutboundMessages        int64   `json:"outbound_messages"`
+	InboundKbps             float64 `json:"inbound_kbps"`
+	OutboundKbps            float64 `json:"outbound_kbps"`
+	BytesPerAudioSecond     float64 `json:"bytes_per_audio_second"`
+}
+
+// SessionBandwidthEvent represents session.bandwidth event, sent by the server at the
+// session's bandwidth report interval
+type SessionBandwidthEvent struct {
+	BaseEvent
+	Bandwidth BandwidthStats `json:"bandwidth"`
+}
+
+// SessionStats reports the audio a session received and what recognition made of it
+type SessionStats struct {
+	DurationMs       int64   `json:"duration_ms"`
+	AudioSeconds     float64 `json:"audio_seconds"`  // input audio received
+	SpeechSeconds    float64 `json:"speech_seconds"` // speech the VAD detected in it
+	ASRCalls         int64   `json:"asr_calls"`      // requests for utterances or their chunks, interim and sliding window recognition
+	ASRErrors        int64   `json:"asr_errors"`
+	AvgRecognitionMs float64 `json:"avg_recognition_ms"` // mean duration of the ASR calls
+	// Input samples the server discarded before recognition: over the audio rate limit
+	// or in chunks failing their checksum
+	DroppedSamples int64 `json:"dropped_samples"`
+}
+
+// SessionStatsEvent represents session.stats event, sent by the server at the session's
+// stats report interval and in answer to session.stats.request
+type SessionStatsEvent struct {
+	BaseEvent
+	RequestEventID string       `json:"request_event_id,omitempty"` // event ID of the request answered, empty for periodic reports
+	Stats          SessionStats `json:"stats"`
+}
+
+// SessionStatsRequestEvent represents session.stats.request event, asking for a
+// session.stats event with the counters so far
+type SessionStatsRequestEvent struct {
+	BaseEvent
+}
+
+// SessionResumeEvent represents session.resume event, sent as the first event of a new
+// connection to take over the session whose connection dropped
+type SessionResumeEvent struct {
+	BaseEvent
+	ResumeToken string `json:"resume_token"`
+	LastEventID string `json:"last_event_id,omitempty"`
+}
+
+// SessionResumedEvent represents session.resumed event, sent by the server before the
+// events the client missed while disconnected
+type SessionResumedEvent struct {
+	BaseEvent
+	Replayed   int  `json:"replayed"`
+	EventsLost bool `json:"events_lost,omitempty"` // some events were no longer buffered
+}
+
 // Event represents any OpenAI event type
 type Event interface {
 	GetType() string
@@ -283,4 +680,60 @@ func (e *HeartbeatPongEvent) GetSessionID() string          { return e.SessionID
 
 func (e *ErrorEvent) GetType() string                          { return e.Type }
 func (e *ErrorEvent) GetEventID() string                       { return e.EventID }
-func (e *ErrorEvent) GetSessionID() string                     { return e.SessionID }
\ No newline at end of file
+func (e *ErrorEvent) GetSessionID() string                     { return e.SessionID }
+
+func (e *ConversationTruncatedEvent) GetType() string      { return e.Type }
+func (e *ConversationTruncatedEvent) GetEventID() string   { return e.EventID }
+func (e *ConversationTruncatedEvent) GetSessionID() string { return e.SessionID }
+
+func (e *VADSegmentEvent) GetType() string      { return e.Type }
+func (e *VADSegmentEvent) GetEventID() string   { return e.EventID }
+func (e *VADSegmentEvent) GetSessionID() string { return e.SessionID }
+
+func (e *SessionAnalyticsEvent) GetType() string      { return e.Type }
+func (e *SessionAnalyticsEvent) GetEventID() string   { return e.EventID }
+func (e *SessionAnalyticsEvent) GetSessionID() string { return e.SessionID }
+
+func (e *CaptionCueEvent) GetType() string      { return e.Type }
+func (e *CaptionCueEvent) GetEventID() string   { return e.EventID }
+func (e *CaptionCueEvent) GetSessionID() string { return e.SessionID }
+
+func (e *InputAudioBufferQualityWarningEvent) GetType() string      { return e.Type }
+func (e *InputAudioBufferQualityWarningEvent) GetEventID() string   { return e.EventID }
+func (e *InputAudioBufferQualityWarningEvent) GetSessionID() string { return e.SessionID }
+
+func (e *SessionLimitWarningEvent) GetType() string      { return e.Type }
+func (e *SessionLimitWarningEvent) GetEventID() string   { return e.EventID }
+func (e *SessionLimitWarningEvent) GetSessionID() string { return e.SessionID }
+
+func (e *SessionLimitExceededEvent) GetType() string      { return e.Type }
+func (e *SessionLimitExceededEvent) GetEventID() string   { return e.EventID }
+func (e *SessionLimitExceededEvent) GetSessionID() string { return e.SessionID }
+
+func (e *TranscriptionWindowEvent) GetType() string      { return e.Type }
+func (e *TranscriptionWindowEvent) GetEventID() string   { return e.EventID }
+func (e *TranscriptionWindowEvent) GetSessionID() string { return e.SessionID }
+
+func (e *ConversationItemInputAudioTranscriptionDeltaEvent) GetType() string      { return e.Type }
+func (e *ConversationItemInputAudioTranscriptionDeltaEvent) GetEventID() string   { return e.EventID }
+func (e *ConversationItemInputAudioTranscriptionDeltaEvent) GetSessionID() string { return e.SessionID }
+
+func (e *SessionBandwidthEvent) GetType() string      { return e.Type }
+func (e *SessionBandwidthEvent) GetEventID() string   { return e.EventID }
+func (e *SessionBandwidthEvent) GetSessionID() string { return e.SessionID }
+
+func (e *SessionResumeEvent) GetType() string      { return e.Type }
+func (e *SessionResumeEvent) GetEventID() string   { return e.EventID }
+func (e *SessionResumeEvent) GetSessionID() string { return e.SessionID }
+
+func (e *SessionResumedEvent) GetType() string      { return e.Type }
+func (e *SessionResumedEvent) GetEventID() string   { return e.EventID }
+func (e *SessionResumedEvent) GetSessionID() string { return e.SessionID }
+
+func (e *SessionStatsEvent) GetType() string      { return e.Type }
+func (e *SessionStatsEvent) GetEventID() string   { return e.EventID }
+func (e *SessionStatsEvent) GetSessionID() string { return e.SessionID }
+
+func (e *SessionStatsRequestEvent) GetType() string      { return e.Type }
+func (e *SessionStatsRequestEvent) GetEventID() string   { return e.EventID }
+func (e *SessionStatsRequestEvent) GetSessionID() string { return e.SessionID }
@@ -0,0 +1,299 @@
+package asr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gosdk/pkg/wav"
+)
+
+// ErrFFmpegNotFound is returned by TranscribeFile for formats other than 16-bit PCM WAV
+// when ffmpeg is not installed
+var ErrFFmpegNotFound = errors.New("ffmpeg not found, it is needed to decode this format")
+
+// Defaults of TranscribeFileOptions
+const (
+	defaultFileChunkDuration = 100 * time.Millisecond
+	defaultFileSpeed         = 4.0
+)
+
+// TranscribeFileOptions configures TranscribeFile
+type TranscribeFileOptions struct {
+	// Configuration of the session opened for the file, nil uses DefaultConfig. The
+	// input format is set to the 16kHz mono PCM the file is decoded to.
+	Config *Config
+
+	// ffmpeg binary decoding the file, looked up in PATH when empty. Without ffmpeg
+	// only 16-bit PCM WAV files can be transcribed.
+	FFmpegPath string
+
+	// Audio sent per write, 100ms when zero
+	ChunkDuration time.Duration
+
+	// Pacing of the audio as a multiple of real time, 4 when zero; a negative value
+	// sends the audio as fast as the connection takes it
+	Speed float64
+
+	// Observe, when set, is called with the recognizer of the file once its session
+	// started and before any audio is sent, to subscribe to its events, as load tests
+	// do to time them
+	Observe func(*Recognizer)
+}
+
+// FileSegment is a transcribed utterance of a file
+type FileSegment struct {
+	ItemID   string
+	Start    time.Duration // offset of the utterance in the file
+	End      time.Duration
+	Text     string
+	Language string // detected language when the session's language is unset or "auto"
+}
+
+// FileTranscript is the transcript of a file, one segment per utterance in file order
+type FileTranscript struct {
+	Segments []FileSegment
+	Duration time.Duration // of the decoded audio
+}
+
+// Text returns the transcripts of the segments, one per line
+func (t *FileTranscript) Text() string {
+	lines := make([]string, 0, len(t.Segments))
+	for _, segment := range t.Segments {
+		lines = append(lines, segment.Text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TranscribeFile transcribes an audio file, see TranscribeFileContext
+func TranscribeFile(path string, opts TranscribeFileOptions) (*FileTranscript, error) {
+	return TranscribeFileContext(context.Background(), path, opts)
+}
+
+// TranscribeFileContext transcribes an audio file in a session of its own. The file is
+// decoded to 16kHz mono PCM with ffmpeg, so any format it reads works, such as mp3,
+// m4a, flac and ogg; 16-bit PCM WAV files are decoded without it. The audio is sent
+// in paced chunks and committed at the end, and the transcript is returned once every
+// utterance is transcribed. When some utterances fail the others are returned along
+// with an error joining the failures.
+func TranscribeFileContext(ctx context.Context, path string, opts TranscribeFileOptions) (*FileTranscript, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	audio, wait, err := decodeAudioFile(ctx, path, opts.FFmpegPath)
+	if err != nil {
+		return nil, err
+	}
+	defer audio.Close()
+
+	config := DefaultConfig()
+	if opts.Config != nil {
+		copied := *opts.Config
+		config = &copied
+	}
+	config.InputSampleRate = 16000
+	config.InputChannels = 1
+
+	recognizer, err := NewRecognizer(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := recognizer.StartContext(ctx); err != nil {
+		return nil, err
+	}
+	defer recognizer.Stop()
+
+	collector := &fileCollector{}
+	recognizer.On(EventTypeConversationItemInputAudioTranscriptionCompleted, collector.onCompleted)
+	recognizer.On(EventTypeConversationItemInputAudioTranscriptionFailed, collector.onFailed)
+	if opts.Observe != nil {
+		opts.Observe(recognizer)
+	}
+
+	sent, err := sendAudioFile(ctx, recognizer, audio, opts)
+	if err != nil {
+		// Stop the decoder before reaping it
+		cancel()
+		wait()
+		return nil, err
+	}
+	if err := wait(); err != nil {
+		return nil, err
+	}
+
+	// Drain commits the tail of the file and stops once every utterance has a result,
+	// after the handlers above ran for the last one
+	if err := recognizer.Drain(ctx); err != nil {
+		return nil, fmt.Errorf("failed to finish transcription: %w", err)
+	}
+	return collector.transcript(sent)
+}
+
+// sendAudioFile writes decoded 16kHz mono PCM to the recognizer in paced chunks and
+// returns the duration sent
+func sendAudioFile(ctx context.Context, recognizer *Recognizer, audio io.Reader, opts TranscribeFileOptions) (time.Duration, error) {
+	chunkDuration := opts.ChunkDuration
+	if chunkDuration <= 0 {
+		chunkDuration = defaultFileChunkDuration
+	}
+	speed := opts.Speed
+	if speed == 0 {
+		speed = defaultFileSpeed
+	}
+
+	chunk := make([]byte, int(chunkDuration.Seconds()*16000)*2)
+	start := time.Now()
+	var sent time.Duration
+	for {
+		n, err := io.ReadFull(audio, chunk)
+		n -= n % 2
+		if n > 0 {
+			if err := recognizer.WriteContext(ctx, chunk[:n]); err != nil {
+				return sent, fmt.Errorf("failed to send audio: %w", err)
+			}
+			sent += time.Duration(n/2) * time.Second / 16000
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sent, nil
+		}
+		if err != nil {
+			return sent, fmt.Errorf("failed to decode audio: %w", err)
+		}
+
+		if speed > 0 {
+			pause := time.Until(start.Add(time.Duration(float64(sent) / speed)))
+			select {
+			case <-time.After(pause):
+			case <-ctx.Done():
+				return sent, ctx.Err()
+			}
+		}
+	}
+}
+
+// decodeAudioFile returns a reader of the file decoded to 16kHz mono PCM16, and a
+// function returning the decoder's error once the reader is at EOF
+func decodeAudioFile(ctx context.Context, path string, ffmpegPath string) (io.ReadCloser, func() error, error) {
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+	ffmpeg, lookErr := exec.LookPath(ffmpegPath)
+	if lookErr != nil {
+		if strings.EqualFold(filepath.Ext(path), ".wav") {
+			audio, err := decodeWAVFile(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			return audio, func() error { return nil }, nil
+		}
+		return nil, nil, ErrFFmpegNotFound
+	}
+
+	cmd := exec.CommandContext(ctx, ffmpeg, "-nostdin", "-v", "error", "-i", path,
+		"-f", "s16le", "-acodec", "pcm_s16le", "-ac", "1", "-ar", "16000", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	wait := func() error {
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("ffmpeg failed to decode %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+	return stdout, wait, nil
+}
+
+// decodeWAVFile decodes a 16-bit PCM WAV file to 16kHz mono PCM16 without ffmpeg
+func decodeWAVFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := wav.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	format := reader.GetFormat()
+	if format.AudioFormat != 1 || format.BitsPerSample != 16 {
+		return nil, fmt.Errorf("%s is not 16-bit PCM, install ffmpeg to decode it: %w", path, ErrFFmpegNotFound)
+	}
+
+	samples, err := reader.ReadSamplesPCM()
+	if err != nil {
+		return nil, err
+	}
+	utils := NewAudioUtils(int(format.SampleRate), int(format.NumChannels))
+	samples = utils.ConvertToMono(samples, int(format.NumChannels))
+	samples, err = utils.ResampleAudio(samples, int(format.SampleRate), 16000)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(PCM16ToBytes(samples))), nil
+}
+
+// fileCollector gathers the results of the utterances of a file
+type fileCollector struct {
+	mutex    sync.Mutex
+	segments []FileSegment
+	errs     []error
+}
+
+func (c *fileCollector) onCompleted(event Event) {
+	e, ok := event.(*ConversationItemInputAudioTranscriptionCompletedEvent)
+	if !ok {
+		return
+	}
+	result := completedResult(e)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.segments = append(c.segments, FileSegment{
+		ItemID:   result.ItemID,
+		Start:    time.Duration(result.AudioStartMs) * time.Millisecond,
+		End:      time.Duration(result.AudioEndMs) * time.Millisecond,
+		Text:     result.Text,
+		Language: result.Language,
+	})
+}
+
+func (c *fileCollector) onFailed(event Event) {
+	e, ok := event.(*ConversationItemInputAudioTranscriptionFailedEvent)
+	if !ok {
+		return
+	}
+	_, err := failedResult(e)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.errs = append(c.errs, fmt.Errorf("item %s: %w", e.ItemID, err))
+}
+
+// transcript returns the segments in file order, with the failures of the others
+func (c *fileCollector) transcript(duration time.Duration) (*FileTranscript, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	transcript := &FileTranscript{Segments: c.segments, Duration: duration}
+	sort.SliceStable(transcript.Segments, func(i, j int) bool {
+		return transcript.Segments[i].Start < transcript.Segments[j].Start
+	})
+	return transcript, errors.Join(c.errs...)
+}
@@ -0,0 +1,36 @@
+package asr
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// Encodings of the audio sent with input_audio_buffer.append, see Config.BinaryAudio
+const (
+	InputEncodingBase64 = "base64"
+	InputEncodingBinary = "binary"
+)
+
+// Layout of a binary audio frame: an 8 byte header, the version, the frame type, the
+// flags, a reserved byte and the little-endian IEEE CRC32 of the audio, followed by the
+// audio bytes
+const (
+	audioFrameVersion    = 1
+	audioFrameHeaderSize = 8
+	audioFrameAppend     = 1
+	audioFrameChecksum   = 1 << 0
+)
+
+// encodeAudioFrame returns the binary frame appending audio, with its CRC32 when
+// checksum is set
+func encodeAudioFrame(audio []byte, checksum bool) []byte {
+	data := make([]byte, audioFrameHeaderSize+len(audio))
+	data[0] = audioFrameVersion
+	data[1] = audioFrameAppend
+	if checksum {
+		data[2] = audioFrameChecksum
+		binary.LittleEndian.PutUint32(data[4:], crc32.ChecksumIEEE(audio))
+	}
+	copy(data[audioFrameHeaderSize:], audio)
+	return data
+}
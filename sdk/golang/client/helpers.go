@@ -1,6 +1,8 @@
 package asr
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -49,21 +51,21 @@ func (sc *SimpleConfig) ToConfig() *Config {
 func CreateRecognizer(url, language string) (*Recognizer, error) {
 	simpleConfig := NewSimpleConfig(url, language)
 	config := simpleConfig.ToConfig()
-	return NewRecognizer(config), nil
+	return NewRecognizer(config)
 }
 
 // CreateRecognizerWithCallbacks creates a recognizer with simple configuration and callbacks
 func CreateRecognizerWithCallbacks(url, language string, callback RecognitionCallback) (*Recognizer, error) {
 	simpleConfig := NewSimpleConfig(url, language)
 	config := simpleConfig.ToConfig()
-	return NewRecognizerWithLegacyCallbacks(config, callback), nil
+	return NewRecognizerWithLegacyCallbacks(config, callback)
 }
 
 // CreateRecognizerWithEventHandler creates a recognizer with simple configuration and event handler
 func CreateRecognizerWithEventHandler(url, language string, handler EventHandler) (*Recognizer, error) {
 	simpleConfig := NewSimpleConfig(url, language)
 	config := simpleConfig.ToConfig()
-	return NewRecognizerWithCallbacks(config, handler), nil
+	return NewRecognizerWithCallbacks(config, handler)
 }
 
 // QuickStart provides a quick way to start recognition with minimal setup
@@ -94,44 +96,28 @@ func QuickStartWithEvents(url, language string, handler EventHandler) (*Recogniz
 	return recognizer, nil
 }
 
-// ProcessAudioFile processes a single audio file and returns transcription
+// ProcessAudioFile processes a single audio file and returns transcription.
+// Results are correlated by conversation item ID, so it is safe to call concurrently.
 func (h *Helper) ProcessAudioFile(audioData []byte, timeout time.Duration) (string, error) {
 	if !h.recognizer.IsRunning() {
 		return "", ErrRecognizerNotRunning
 	}
 
-	// Channel to receive result
-	resultChan := make(chan string, 1)
-	errorChan := make(chan error, 1)
-
-	// Create callback to capture result
-	simpleCallback := &SimpleRecognitionCallback{
-		ResultChan: resultChan,
-		ErrorChan:  errorChan,
-	}
-
-	// Replace the legacy handler
-	h.recognizer.eventDispatcher.RegisterLegacyHandler(simpleCallback)
-
-	// Send audio data
-	if err := h.recognizer.Write(audioData); err != nil {
+	tracker := h.recognizer.tracker()
+	pending, err := tracker.submit(audioData)
+	if err != nil {
 		return "", fmt.Errorf("failed to send audio: %w", err)
 	}
 
-	// Commit the audio
-	if err := h.recognizer.CommitAudio(); err != nil {
-		return "", fmt.Errorf("failed to commit audio: %w", err)
-	}
-
 	// Wait for result or timeout
-	select {
-	case result := <-resultChan:
-		return result, nil
-	case err := <-errorChan:
-		return "", err
-	case <-time.After(timeout):
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result, err := tracker.wait(ctx, pending)
+	if errors.Is(err, context.DeadlineExceeded) {
 		return "", fmt.Errorf("transcription timeout after %v", timeout)
 	}
+	return result.Text, err
 }
 
 // StreamAudio continuously processes audio data from a channel
@@ -149,24 +135,6 @@ func (h *Helper) StreamAudio(audioChan <-chan []byte, errorChan chan<- error) {
 	}
 }
 
-// BatchProcess processes multiple audio files in sequence
-func (h *Helper) BatchProcess(audioFiles [][]byte, timeout time.Duration) ([]string, error) {
-	if !h.recognizer.IsRunning() {
-		return nil, ErrRecognizerNotRunning
-	}
-
-	results := make([]string, 0, len(audioFiles))
-	for i, audioData := range audioFiles {
-		result, err := h.ProcessAudioFile(audioData, timeout)
-		if err != nil {
-			return nil, fmt.Errorf("failed to process audio file %d: %w", i+1, err)
-		}
-		results[i] = result
-	}
-
-	return results, nil
-}
-
 // SessionInfo provides detailed session information
 type SessionInfo struct {
 	SessionID     string    `json:"session_id"`
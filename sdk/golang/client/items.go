@@ -0,0 +1,205 @@
+package asr
+
+import (
+	"context"
+	"sync"
+)
+
+// Number of settled items whose results WaitForItem still returns, and of results
+// Results buffers for a reader falling behind
+const (
+	itemResultsRetained = 256
+	itemResultsBuffered = 256
+)
+
+// ItemResult is the outcome of the transcription of a conversation item, delivered by
+// Results
+type ItemResult struct {
+	TranscriptionResult
+	// *ASRError when the transcription failed
+	Err error
+}
+
+// itemFuture is the result of an item, available once done is closed
+type itemFuture struct {
+	done    chan struct{}
+	result  ItemResult
+	settled bool
+	created bool // the server announced the item
+	waiters int
+}
+
+// itemTracker maps the conversation items of the session to their results, whether
+// they come from the caller's commits or from server-side turn detection, so that any
+// number of in-flight items can be waited for independently
+type itemTracker struct {
+	recognizer *Recognizer
+
+	mutex   sync.Mutex
+	futures map[string]*itemFuture
+	settled []string // settled items, oldest first, forgotten beyond itemResultsRetained
+
+	// Items the server created that have no result yet
+	outstanding int
+	idle        chan struct{} // closed when outstanding drops to zero
+}
+
+func newItemTracker(r *Recognizer) *itemTracker {
+	t := &itemTracker{
+		recognizer: r,
+		futures:    make(map[string]*itemFuture),
+	}
+
+	r.On(EventTypeConversationItemCreated, t.onItemCreated)
+	r.On(EventTypeConversationItemInputAudioTranscriptionCompleted, t.onCompleted)
+	r.On(EventTypeConversationItemInputAudioTranscriptionFailed, t.onFailed)
+
+	return t
+}
+
+// future returns the future of an item, creating it. The caller holds the mutex.
+func (t *itemTracker) future(itemID string) *itemFuture {
+	f, ok := t.futures[itemID]
+	if !ok {
+		f = &itemFuture{done: make(chan struct{})}
+		t.futures[itemID] = f
+	}
+	return f
+}
+
+func (t *itemTracker) onItemCreated(event Event) {
+	e, ok := event.(*ConversationItemCreatedEvent)
+	if !ok {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	f := t.future(e.Item.ID)
+	if f.created || f.settled {
+		f.created = true
+		return
+	}
+	f.created = true
+	if t.outstanding == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.outstanding++
+}
+
+func (t *itemTracker) onCompleted(event Event) {
+	if e, ok := event.(*ConversationItemInputAudioTranscriptionCompletedEvent); ok {
+		t.settle(ItemResult{TranscriptionResult: completedResult(e)})
+	}
+}
+
+func (t *itemTracker) onFailed(event Event) {
+	if e, ok := event.(*ConversationItemInputAudioTranscriptionFailedEvent); ok {
+		result, err := failedResult(e)
+		t.settle(ItemResult{TranscriptionResult: result, Err: err})
+	}
+}
+
+// settle records the result of an item, wakes its waiters and queues it for Results
+func (t *itemTracker) settle(result ItemResult) {
+	t.mutex.Lock()
+	f := t.future(result.ItemID)
+	if f.settled {
+		t.mutex.Unlock()
+		return
+	}
+	f.result, f.settled = result, true
+	close(f.done)
+	if f.created {
+		if t.outstanding--; t.outstanding == 0 {
+			close(t.idle)
+		}
+	}
+
+	t.settled = append(t.settled, result.ItemID)
+	if len(t.settled) > itemResultsRetained {
+		delete(t.futures, t.settled[0])
+		t.settled = t.settled[1:]
+	}
+	t.mutex.Unlock()
+
+	select {
+	case t.recognizer.results <- result:
+	default:
+		t.recognizer.logger.Warn("Results channel full, dropping item result", Fields{"component": "recognizer", "itemID": result.ItemID})
+		t.recognizer.eventStats.RecordEvent("result_dropped", true, "results channel full")
+	}
+}
+
+// wait blocks until the item has a result or ctx ends
+func (t *itemTracker) wait(ctx context.Context, itemID string) (ItemResult, error) {
+	t.mutex.Lock()
+	f := t.future(itemID)
+	f.waiters++
+	t.mutex.Unlock()
+
+	select {
+	case <-f.done:
+		return f.result, nil
+	case <-ctx.Done():
+		t.mutex.Lock()
+		// Forget items only the waiter asked for, so unknown IDs do not pile up
+		if f.waiters--; f.waiters == 0 && !f.created && !f.settled {
+			delete(t.futures, itemID)
+		}
+		t.mutex.Unlock()
+		return ItemResult{}, ctx.Err()
+	}
+}
+
+// waitIdle blocks until every created item has a result or ctx ends
+func (t *itemTracker) waitIdle(ctx context.Context) error {
+	t.mutex.Lock()
+	if t.outstanding == 0 {
+		t.mutex.Unlock()
+		return nil
+	}
+	idle := t.idle
+	t.mutex.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Recognizer) itemTracker() *itemTracker {
+	r.itemsOnce.Do(func() {
+		r.items = newItemTracker(r)
+	})
+	return r.items
+}
+
+// Results returns the channel delivering the result of every conversation item of the
+// session as its transcription completes or fails, in that order, including items of
+// server-side turn detection. The channel is shared by all callers and is not closed;
+// when it is not read, results beyond its buffer are dropped.
+func (r *Recognizer) Results() <-chan ItemResult {
+	return r.results
+}
+
+// WaitForItem waits for the transcript of a conversation item, such as the ItemID of an
+// input_audio_buffer.committed event. It returns an *ASRError when the transcription
+// failed and the error of ctx when it ends first. Results of the last 256 settled
+// items remain available.
+func (r *Recognizer) WaitForItem(ctx context.Context, itemID string) (TranscriptionResult, error) {
+	r.runningMutex.RLock()
+	running := r.isRunning
+	r.runningMutex.RUnlock()
+	if !running {
+		return TranscriptionResult{}, ErrRecognizerNotRunning
+	}
+
+	result, err := r.itemTracker().wait(ctx, itemID)
+	if err != nil {
+		return TranscriptionResult{}, err
+	}
+	return result.TranscriptionResult, result.Err
+}
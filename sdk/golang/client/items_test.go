@@ -0,0 +1,232 @@
+package asr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWaitForItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    map[string]any
+		early    bool // the result arrives before WaitForItem is called
+		wantText string
+		wantCode string
+	}{
+		{name: "completed", event: completedEvent("item_1", "hello"), wantText: "hello"},
+		{name: "failed", event: failedEvent("item_1", "asr_timeout"), wantCode: "asr_timeout"},
+		{name: "completed before waiting", event: completedEvent("item_1", "hello"), early: true, wantText: "hello"},
+		{name: "failed before waiting", event: failedEvent("item_1", "asr_timeout"), early: true, wantCode: "asr_timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t)
+			r := startRecognizer(t, server, nil)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			type outcome struct {
+				result TranscriptionResult
+				err    error
+			}
+			done := make(chan outcome, 1)
+			wait := func() {
+				result, err := r.WaitForItem(ctx, "item_1")
+				done <- outcome{result, err}
+			}
+			if tt.early {
+				server.send(t, tt.event)
+				<-r.Results()
+				go wait()
+			} else {
+				go wait()
+				// Let the waiter register before the result arrives
+				waitFor(t, func() bool {
+					r.items.mutex.Lock()
+					defer r.items.mutex.Unlock()
+					return r.items.futures["item_1"] != nil
+				}, "waiter")
+				server.send(t, tt.event)
+			}
+
+			got := <-done
+			if tt.wantCode == "" {
+				if got.err != nil {
+					t.Fatalf("WaitForItem: %v", got.err)
+				}
+				if got.result.ItemID != "item_1" || got.result.Text != tt.wantText || got.result.Language != "en" {
+					t.Errorf("result %+v, want item_1 %q in en", got.result, tt.wantText)
+				}
+				return
+			}
+			var asrErr *ASRError
+			if !errors.As(got.err, &asrErr) || asrErr.Code != tt.wantCode {
+				t.Fatalf("WaitForItem error %v, want code %s", got.err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWaitForItemForgetsUnknownItems(t *testing.T) {
+	server := newFakeServer(t)
+	r := startRecognizer(t, server, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.WaitForItem(ctx, "unknown"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitForItem error %v, want the deadline", err)
+	}
+	r.items.mutex.Lock()
+	defer r.items.mutex.Unlock()
+	if _, ok := r.items.futures["unknown"]; ok {
+		t.Error("future of an item nobody waits for is kept")
+	}
+}
+
+func TestResultsDeliverItemsInOrder(t *testing.T) {
+	server := newFakeServer(t)
+	r := startRecognizer(t, server, nil)
+
+	server.send(t, completedEvent("item_1", "one"))
+	server.send(t, failedEvent("item_2", "asr_timeout"))
+	server.send(t, completedEvent("item_3", "three"))
+	// A repeated result settles nothing
+	server.send(t, completedEvent("item_1", "again"))
+
+	for _, want := range []struct {
+		itemID string
+		text   string
+		failed bool
+	}{
+		{"item_1", "one", false},
+		{"item_2", "", true},
+		{"item_3", "three", false},
+	} {
+		select {
+		case result := <-r.Results():
+			if result.ItemID != want.itemID || result.Text != want.text || (result.Err != nil) != want.failed {
+				t.Fatalf("result %+v, want %s %q failed=%v", result, want.itemID, want.text, want.failed)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no result for %s", want.itemID)
+		}
+	}
+	select {
+	case result := <-r.Results():
+		t.Fatalf("unexpected result %+v", result)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestItemTrackerRetainsRecentResults(t *testing.T) {
+	server := newFakeServer(t)
+	r := startRecognizer(t, server, nil)
+
+	settled := itemResultsRetained + 1
+	for i := 0; i < settled; i++ {
+		r.items.settle(ItemResult{TranscriptionResult: TranscriptionResult{ItemID: fmt.Sprintf("item_%d", i)}})
+		<-r.Results()
+	}
+
+	r.items.mutex.Lock()
+	_, oldest := r.items.futures["item_0"]
+	_, newest := r.items.futures[fmt.Sprintf("item_%d", settled-1)]
+	r.items.mutex.Unlock()
+	if oldest || !newest {
+		t.Errorf("oldest retained %v, newest retained %v; want only the last %d items", oldest, newest, itemResultsRetained)
+	}
+}
+
+// committedEvent answers a commit, naming the item it created
+func committedEvent(commitID, itemID string) map[string]any {
+	return map[string]any{
+		"type":            EventTypeInputAudioBufferCommitted,
+		"event_id":        "event_committed_" + commitID,
+		"commit_event_id": commitID,
+		"item_id":         itemID,
+	}
+}
+
+func TestCommitAndWaitMatchesCommitsToItems(t *testing.T) {
+	server := newFakeServer(t)
+	r := startRecognizer(t, server, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	type outcome struct {
+		result TranscriptionResult
+		err    error
+	}
+	first, second := make(chan outcome, 1), make(chan outcome, 1)
+	commitAndWait := func(done chan<- outcome) {
+		result, err := r.CommitAndWait(ctx)
+		done <- outcome{result, err}
+	}
+	go commitAndWait(first)
+	firstCommit := server.next(t, EventTypeInputAudioBufferCommit)
+	go commitAndWait(second)
+	secondCommit := server.next(t, EventTypeInputAudioBufferCommit)
+
+	// Answered out of order, the second item settling before its commit is answered
+	server.send(t, completedEvent("item_2", "second"))
+	server.send(t, committedEvent(secondCommit.EventID, "item_2"))
+	server.send(t, committedEvent(firstCommit.EventID, "item_1"))
+	server.send(t, completedEvent("item_1", "first"))
+
+	for _, want := range []struct {
+		done chan outcome
+		text string
+	}{{first, "first"}, {second, "second"}} {
+		got := <-want.done
+		if got.err != nil || got.result.Text != want.text {
+			t.Errorf("CommitAndWait returned %q, %v; want %q", got.result.Text, got.err, want.text)
+		}
+	}
+
+	go commitAndWait(first)
+	empty := server.next(t, EventTypeInputAudioBufferCommit)
+	server.send(t, committedEvent(empty.EventID, ""))
+	if got := <-first; !errors.Is(got.err, ErrNoAudioCommitted) {
+		t.Errorf("CommitAndWait of an empty buffer returned %v, want ErrNoAudioCommitted", got.err)
+	}
+}
+
+func TestDrainWaitsForCreatedItems(t *testing.T) {
+	server := newFakeServer(t)
+	r := startRecognizer(t, server, nil)
+
+	// An item of server-side turn detection is still being transcribed
+	server.send(t, map[string]any{
+		"type":     EventTypeConversationItemCreated,
+		"event_id": "event_created",
+		"item":     map[string]any{"id": "item_vad", "type": "message"},
+	})
+	waitFor(t, func() bool {
+		r.items.mutex.Lock()
+		defer r.items.mutex.Unlock()
+		return r.items.outstanding == 1
+	}, "created item")
+
+	drained := make(chan error, 1)
+	go func() { drained <- r.Drain(context.Background()) }()
+	commit := server.next(t, EventTypeInputAudioBufferCommit)
+	server.send(t, committedEvent(commit.EventID, ""))
+	select {
+	case err := <-drained:
+		t.Fatalf("drained before the item settled: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	server.send(t, completedEvent("item_vad", "late"))
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Fatalf("Drain: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain did not return after the item settled")
+	}
+}
@@ -0,0 +1,136 @@
+package asr
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Fields are structured key/value pairs attached to a log entry
+type Fields map[string]interface{}
+
+// Logger receives the log output of the SDK. Set Config.Logger to route it into the
+// logging of the application; by default the SDK logs nothing.
+type Logger interface {
+	Debug(msg string, fields Fields)
+	Info(msg string, fields Fields)
+	Warn(msg string, fields Fields)
+	Error(msg string, fields Fields)
+}
+
+// NopLogger discards everything, it is the default logger
+type NopLogger struct{}
+
+func (NopLogger) Debug(string, Fields) {}
+func (NopLogger) Info(string, Fields)  {}
+func (NopLogger) Warn(string, Fields)  {}
+func (NopLogger) Error(string, Fields) {}
+
+// loggerOrNop returns logger, or NopLogger when it is nil
+func loggerOrNop(logger Logger) Logger {
+	if logger == nil {
+		return NopLogger{}
+	}
+	return logger
+}
+
+// StdLogger writes to a standard library logger, one line per entry with the fields
+// sorted by key. Debug entries are written only when Verbose is set.
+type StdLogger struct {
+	Logger  *log.Logger // nil uses the standard logger
+	Verbose bool
+}
+
+// NewStdLogger returns a StdLogger writing info, warnings and errors to the standard logger
+func NewStdLogger() *StdLogger {
+	return &StdLogger{}
+}
+
+func (l *StdLogger) Debug(msg string, fields Fields) {
+	if l.Verbose {
+		l.print("DEBUG", msg, fields)
+	}
+}
+
+func (l *StdLogger) Info(msg string, fields Fields)  { l.print("INFO", msg, fields) }
+func (l *StdLogger) Warn(msg string, fields Fields)  { l.print("WARN", msg, fields) }
+func (l *StdLogger) Error(msg string, fields Fields) { l.print("ERROR", msg, fields) }
+
+func (l *StdLogger) print(level, msg string, fields Fields) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var line strings.Builder
+	fmt.Fprintf(&line, "[%s] %s", level, msg)
+	for _, key := range keys {
+		fmt.Fprintf(&line, " %s=%v", key, fields[key])
+	}
+
+	if l.Logger != nil {
+		l.Logger.Print(line.String())
+	} else {
+		log.Print(line.String())
+	}
+}
+
+// SlogLogger adapts a log/slog logger
+type SlogLogger struct {
+	Logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to logger, nil uses slog.Default()
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{Logger: logger}
+}
+
+func (l *SlogLogger) Debug(msg string, fields Fields) { l.Logger.Debug(msg, slogArgs(fields)...) }
+func (l *SlogLogger) Info(msg string, fields Fields)  { l.Logger.Info(msg, slogArgs(fields)...) }
+func (l *SlogLogger) Warn(msg string, fields Fields)  { l.Logger.Warn(msg, slogArgs(fields)...) }
+func (l *SlogLogger) Error(msg string, fields Fields) { l.Logger.Error(msg, slogArgs(fields)...) }
+
+func slogArgs(fields Fields) []any {
+	args := make([]any, 0, len(fields))
+	for key, value := range fields {
+		args = append(args, slog.Any(key, value))
+	}
+	return args
+}
+
+// LogrusLogger adapts a logrus logger or entry
+type LogrusLogger struct {
+	Logger logrus.FieldLogger
+}
+
+// NewLogrusLogger returns a Logger writing to logger, nil uses the standard logrus logger
+func NewLogrusLogger(logger logrus.FieldLogger) *LogrusLogger {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return &LogrusLogger{Logger: logger}
+}
+
+func (l *LogrusLogger) Debug(msg string, fields Fields) {
+	l.Logger.WithFields(logrus.Fields(fields)).Debug(msg)
+}
+
+func (l *LogrusLogger) Info(msg string, fields Fields) {
+	l.Logger.WithFields(logrus.Fields(fields)).Info(msg)
+}
+
+func (l *LogrusLogger) Warn(msg string, fields Fields) {
+	l.Logger.WithFields(logrus.Fields(fields)).Warn(msg)
+}
+
+func (l *LogrusLogger) Error(msg string, fields Fields) {
+	l.Logger.WithFields(logrus.Fields(fields)).Error(msg)
+}
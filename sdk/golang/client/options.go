@@ -0,0 +1,152 @@
+package asr
+
+import "time"
+
+// Option configures a recognizer created with NewClient
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	config   *Config
+	handlers []EventHandler
+}
+
+// NewClient creates a recognizer for the server at url, starting from DefaultConfig
+// and applying opts in order:
+//
+//	recognizer, err := asr.NewClient("ws://localhost:8088/ws",
+//		asr.WithLanguage("zh"),
+//		asr.WithSampleRate(48000),
+//		asr.WithReconnect(3, 2*time.Second),
+//		asr.WithHandler(handler))
+//
+// It returns the validation error of an invalid resulting configuration.
+func NewClient(url string, opts ...Option) (*Recognizer, error) {
+	o := &clientOptions{config: DefaultConfig()}
+	o.config.URL = url
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	recognizer, err := NewRecognizer(o.config)
+	if err != nil {
+		return nil, err
+	}
+	for i, handler := range o.handlers {
+		// The first handler also receives the session lifecycle, like the one given
+		// to NewRecognizerWithCallbacks
+		if i == 0 {
+			recognizer.setHandler(handler)
+			continue
+		}
+		recognizer.eventDispatcher.RegisterEventHandler(handler)
+	}
+	return recognizer, nil
+}
+
+// WithConfig applies fn to the configuration, for settings without an option of their own
+func WithConfig(fn func(*Config)) Option {
+	return func(o *clientOptions) { fn(o.config) }
+}
+
+// WithHandler adds an event handler; embed DefaultEventHandler to implement only the
+// events of interest
+func WithHandler(handler EventHandler) Option {
+	return func(o *clientOptions) {
+		if handler != nil {
+			o.handlers = append(o.handlers, handler)
+		}
+	}
+}
+
+// WithHeader sets a header sent with the WebSocket handshake, e.g. Authorization
+func WithHeader(key, value string) Option {
+	return func(o *clientOptions) {
+		if o.config.Headers == nil {
+			o.config.Headers = make(map[string]string)
+		}
+		o.config.Headers[key] = value
+	}
+}
+
+// WithTimeout sets the connection timeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) { o.config.Timeout = timeout }
+}
+
+// WithLanguage sets the transcription language, "auto" has the server detect it
+func WithLanguage(language string) Option {
+	return func(o *clientOptions) { o.config.TranscriptionLanguage = language }
+}
+
+// WithHints sets the custom vocabulary, such as product names and call signs, the
+// engine is hinted to recognize
+func WithHints(hints ...string) Option {
+	return func(o *clientOptions) { o.config.TranscriptionHints = hints }
+}
+
+// WithBinaryAudio sends audio as binary WebSocket frames instead of Base64 in JSON,
+// for servers with the binary_audio feature
+func WithBinaryAudio() Option {
+	return func(o *clientOptions) { o.config.BinaryAudio = true }
+}
+
+// WithModel sets the transcription model
+func WithModel(model string) Option {
+	return func(o *clientOptions) { o.config.TranscriptionModel = model }
+}
+
+// WithSampleRate sets the sample rate of the audio written
+func WithSampleRate(sampleRate int) Option {
+	return func(o *clientOptions) { o.config.InputSampleRate = sampleRate }
+}
+
+// WithChannels sets the channel count of the audio written
+func WithChannels(channels int) Option {
+	return func(o *clientOptions) { o.config.InputChannels = channels }
+}
+
+// WithInterimResults asks for interim hypotheses, delivered to OnTranscriptionDelta
+func WithInterimResults() Option {
+	return func(o *clientOptions) { o.config.InterimResults = true }
+}
+
+// WithTurnDetection sets the server-side turn detection type, e.g. "server_vad", or
+// "none" to commit the audio with CommitAudio only
+func WithTurnDetection(detectionType string) Option {
+	return func(o *clientOptions) { o.config.TurnDetectionType = detectionType }
+}
+
+// WithNoiseReduction enables the server denoiser at strength, from 0 to 1
+func WithNoiseReduction(strength float64) Option {
+	return func(o *clientOptions) { o.config.NoiseReduction = &NoiseReductionConfig{Strength: &strength} }
+}
+
+// WithPipeline selects a named server pipeline
+func WithPipeline(pipeline string) Option {
+	return func(o *clientOptions) { o.config.Pipeline = pipeline }
+}
+
+// WithReconnect reconnects a dropped connection up to attempts times, waiting delay
+// longer before each attempt
+func WithReconnect(attempts int, delay time.Duration) Option {
+	return func(o *clientOptions) {
+		o.config.EnableReconnect = true
+		o.config.MaxReconnectAttempts = attempts
+		o.config.ReconnectDelay = delay
+	}
+}
+
+// WithoutReconnect reports a dropped connection as an error instead of reconnecting
+func WithoutReconnect() Option {
+	return func(o *clientOptions) { o.config.EnableReconnect = false }
+}
+
+// WithHeartbeat sets the interval of heartbeat pings
+func WithHeartbeat(interval time.Duration) Option {
+	return func(o *clientOptions) { o.config.HeartbeatInterval = interval }
+}
+
+// WithLogger routes the log output of the SDK to logger
+func WithLogger(logger Logger) Option {
+	return func(o *clientOptions) { o.config.Logger = logger }
+}
@@ -157,6 +157,90 @@ func (p *EventParser) ParseEvent(data []byte) (Event, error) {
 		}
 		return &event, nil
 
+	case EventTypeConversationTruncated:
+		var event ConversationTruncatedEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse conversation.truncated event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeVADSegment:
+		var event VADSegmentEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse vad.segment event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionAnalytics:
+		var event SessionAnalyticsEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.analytics event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeCaptionCue:
+		var event CaptionCueEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse caption.cue event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeInputAudioBufferQualityWarning:
+		var event InputAudioBufferQualityWarningEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse input_audio_buffer.quality_warning event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionLimitWarning:
+		var event SessionLimitWarningEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.limit_warning event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionLimitExceeded:
+		var event SessionLimitExceededEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.limit_exceeded event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeTranscriptionWindow:
+		var event TranscriptionWindowEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse transcription.window event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeConversationItemInputAudioTranscriptionDelta:
+		var event ConversationItemInputAudioTranscriptionDeltaEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse conversation.item.input_audio_transcription.delta event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionBandwidth:
+		var event SessionBandwidthEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.bandwidth event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionStats:
+		var event SessionStatsEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.stats event: %v", err)
+		}
+		return &event, nil
+
+	case EventTypeSessionResumed:
+		var event SessionResumedEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse session.resumed event: %v", err)
+		}
+		return &event, nil
+
 	default:
 		return nil, fmt.Errorf("unknown event type: %s", baseEvent.Type)
 	}
@@ -201,6 +285,34 @@ func (p *EventParser) ValidateEvent(event Event) error {
 		return p.validateInputAudioBufferClearedEvent(e)
 	case *ErrorEvent:
 		return p.validateErrorEvent(e)
+	case *ConversationTruncatedEvent:
+		return p.validateConversationTruncatedEvent(e)
+	case *VADSegmentEvent:
+		return p.validateVADSegmentEvent(e)
+	case *SessionAnalyticsEvent:
+		return p.validateSessionAnalyticsEvent(e)
+	case *CaptionCueEvent:
+		return p.validateCaptionCueEvent(e)
+	case *InputAudioBufferQualityWarningEvent:
+		return p.validateInputAudioBufferQualityWarningEvent(e)
+	case *SessionLimitWarningEvent:
+		return p.validateSessionLimitWarningEvent(e)
+	case *SessionLimitExceededEvent:
+		return p.validateSessionLimitExceededEvent(e)
+	case *TranscriptionWindowEvent:
+		return p.validateTranscriptionWindowEvent(e)
+	case *ConversationItemInputAudioTranscriptionDeltaEvent:
+		return p.validateConversationItemInputAudioTranscriptionDeltaEvent(e)
+	case *SessionBandwidthEvent:
+		return p.validateSessionBandwidthEvent(e)
+	case *SessionResumeEvent:
+		return p.validateSessionResumeEvent(e)
+	case *SessionStatsEvent:
+		return p.validateSessionStatsEvent(e)
+	case *SessionStatsRequestEvent:
+		return nil
+	case *SessionResumedEvent:
+		return p.validateSessionResumedEvent(e)
 	default:
 		return fmt.Errorf("unknown event type for validation")
 	}
@@ -379,6 +491,94 @@ func (p *EventParser) validateHeartbeatPongEvent(_ *HeartbeatPongEvent) error {
 	return nil
 }
 
+func (p *EventParser) validateConversationTruncatedEvent(event *ConversationTruncatedEvent) error {
+	if len(event.ItemIDs) == 0 {
+		return fmt.Errorf("truncated item IDs are required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateVADSegmentEvent(event *VADSegmentEvent) error {
+	if event.StartMs < 0 || event.EndMs < event.StartMs {
+		return fmt.Errorf("invalid segment bounds: start_ms=%d end_ms=%d", event.StartMs, event.EndMs)
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionAnalyticsEvent(_ *SessionAnalyticsEvent) error {
+	return nil
+}
+
+func (p *EventParser) validateCaptionCueEvent(event *CaptionCueEvent) error {
+	if event.ItemID == "" {
+		return fmt.Errorf("item_id is required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateInputAudioBufferQualityWarningEvent(event *InputAudioBufferQualityWarningEvent) error {
+	if event.Warning == "" {
+		return fmt.Errorf("warning is required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionLimitWarningEvent(event *SessionLimitWarningEvent) error {
+	if event.Limit.Name == "" {
+		return fmt.Errorf("limit.name is required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionLimitExceededEvent(event *SessionLimitExceededEvent) error {
+	if event.Limit.Name == "" {
+		return fmt.Errorf("limit.name is required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateTranscriptionWindowEvent(_ *TranscriptionWindowEvent) error {
+	return nil
+}
+
+func (p *EventParser) validateConversationItemInputAudioTranscriptionDeltaEvent(event *ConversationItemInputAudioTranscriptionDeltaEvent) error {
+	if event.ItemID == "" {
+		return fmt.Errorf("item ID is required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionBandwidthEvent(event *SessionBandwidthEvent) error {
+	if event.Bandwidth.DurationMs < 0 {
+		return fmt.Errorf("duration_ms must not be negative")
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionResumeEvent(event *SessionResumeEvent) error {
+	if event.SessionID == "" {
+		return fmt.Errorf("session ID is required")
+	}
+	if event.ResumeToken == "" {
+		return fmt.Errorf("resume token is required")
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionStatsEvent(event *SessionStatsEvent) error {
+	if event.Stats.DurationMs < 0 {
+		return fmt.Errorf("duration_ms must not be negative")
+	}
+	return nil
+}
+
+func (p *EventParser) validateSessionResumedEvent(event *SessionResumedEvent) error {
+	if event.Replayed < 0 {
+		return fmt.Errorf("replayed must not be negative")
+	}
+	return nil
+}
+
 // IsValidEventType checks if an event type is valid
 func IsValidEventType(eventType string) bool {
 	validTypes := []string{
@@ -400,6 +600,20 @@ func IsValidEventType(eventType string) bool {
 		EventTypeError,
 		EventTypeHeartbeatPing,
 		EventTypeHeartbeatPong,
+		EventTypeConversationTruncated,
+		EventTypeVADSegment,
+		EventTypeSessionAnalytics,
+		EventTypeCaptionCue,
+		EventTypeInputAudioBufferQualityWarning,
+		EventTypeSessionLimitWarning,
+		EventTypeSessionLimitExceeded,
+		EventTypeTranscriptionWindow,
+		EventTypeConversationItemInputAudioTranscriptionDelta,
+		EventTypeSessionBandwidth,
+		EventTypeSessionResume,
+		EventTypeSessionResumed,
+		EventTypeSessionStats,
+		EventTypeSessionStatsRequest,
 	}
 
 	for _, validType := range validTypes {
@@ -3,8 +3,9 @@ package asr
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"hash/crc32"
 	"sync"
 	"time"
 
@@ -36,17 +37,43 @@ type Recognizer struct {
 	errorChan      chan error
 	closeChan      chan struct{}
 	wg             sync.WaitGroup
+
+	logger Logger
+
+	// Session and last event to resume after the connection dropped, see resume.go
+	resume resumeState
+
+	// Audio written while reconnecting and the callback reporting a reconnect, see resend.go
+	resend           resendQueue
+	onReconnected    func(ReconnectStats)
+	reconnectedMutex sync.Mutex
+
+	// Sequence numbers of the session's events and the callback reporting gaps, see sequence.go
+	sequence sequenceTracker
+
+	// Callback of session.stats events, see stats.go
+	sessionStats sessionStatsCallback
+
+	// Correlates commits with their transcripts, created on first use
+	transcriptions     *transcriptionTracker
+	transcriptionsOnce sync.Once
+
+	// Results of the session's items, see items.go
+	items     *itemTracker
+	itemsOnce sync.Once
+	results   chan ItemResult
 }
 
-// NewRecognizer creates a new recognizer instance
-func NewRecognizer(config *Config) *Recognizer {
+// NewRecognizer creates a new recognizer instance, nil config uses DefaultConfig. It
+// returns the validation error of an invalid configuration.
+func NewRecognizer(config *Config) (*Recognizer, error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
 	// Validate configuration
 	if err := config.Validate(); err != nil {
-		log.Fatalf("[❌ Config] Invalid configuration: %v", err)
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
 	// Create context
@@ -56,16 +83,23 @@ func NewRecognizer(config *Config) *Recognizer {
 	connManager := NewConnectionManager(config.URL)
 	sessionManager := NewSessionManager(nil) // Will be set later
 	eventDispatcher := NewEventDispatcher(NewEventParser())
+	eventDispatcher.SetReplayBufferSize(config.ReplayBufferSize)
 	audioUtils := NewAudioUtils(config.InputSampleRate, config.InputChannels)
 	audioBuffer := NewAudioBuffer(1024*1000, config.InputSampleRate, config.InputChannels) // 1MB buffer
 	eventStats := NewEventStats()
+	logger := loggerOrNop(config.Logger)
+	connManager.SetLogger(logger)
+	sessionManager.SetLogger(logger)
+	eventDispatcher.SetLogger(logger)
 
 	// Apply connection settings
 	for key, value := range config.Headers {
 		connManager.SetHeader(key, value)
 	}
 	connManager.SetPingInterval(config.HeartbeatInterval)
-	connManager.SetReconnectOptions(config.EnableReconnect, config.MaxReconnectAttempts, config.ReconnectDelay)
+	// The recognizer reconnects itself, so it can resume its session on the new connection
+	connManager.SetReconnectOptions(false, config.MaxReconnectAttempts, config.ReconnectDelay)
+	connManager.ownerReconnects = config.EnableReconnect
 
 	return &Recognizer{
 		config:         config,
@@ -81,34 +115,47 @@ func NewRecognizer(config *Config) *Recognizer {
 		eventChan:      make(chan []byte, 1000),
 		errorChan:      make(chan error, 100),
 		closeChan:      make(chan struct{}),
-	}
+		results:        make(chan ItemResult, itemResultsBuffered),
+		logger:         logger,
+	}, nil
 }
 
 // NewRecognizerWithCallbacks creates a recognizer with event handlers
-func NewRecognizerWithCallbacks(config *Config, handler EventHandler) *Recognizer {
-	recognizer := NewRecognizer(config)
-	recognizer.sessionManager = NewSessionManager(handler)
-	recognizer.eventDispatcher.RegisterEventHandler(handler)
-	return recognizer
+func NewRecognizerWithCallbacks(config *Config, handler EventHandler) (*Recognizer, error) {
+	recognizer, err := NewRecognizer(config)
+	if err != nil {
+		return nil, err
+	}
+	recognizer.setHandler(handler)
+	return recognizer, nil
 }
 
 // NewRecognizerWithEventHandler creates a recognizer with event handler (alias for NewRecognizerWithCallbacks)
 func NewRecognizerWithEventHandler(config *Config, handler EventHandler) (*Recognizer, error) {
-	recognizer := NewRecognizerWithCallbacks(config, handler)
-	return recognizer, nil
+	return NewRecognizerWithCallbacks(config, handler)
 }
 
 // NewRecognizerWithLegacyCallbacks creates a recognizer with legacy recognition callbacks
-func NewRecognizerWithLegacyCallbacks(config *Config, callback RecognitionCallback) *Recognizer {
-	recognizer := NewRecognizer(config)
-	adapter := &RecognitionCallbackAdapter{Callback: callback}
-	recognizer.sessionManager = NewSessionManager(adapter)
-	recognizer.eventDispatcher.RegisterEventHandler(adapter)
-	return recognizer
+func NewRecognizerWithLegacyCallbacks(config *Config, callback RecognitionCallback) (*Recognizer, error) {
+	return NewRecognizerWithCallbacks(config, &RecognitionCallbackAdapter{Callback: callback})
+}
+
+// setHandler makes handler the handler of the session and of every event
+func (r *Recognizer) setHandler(handler EventHandler) {
+	r.sessionManager = NewSessionManager(handler)
+	r.sessionManager.SetLogger(r.logger)
+	r.eventDispatcher.RegisterEventHandler(handler)
 }
 
 // Start establishes connection and begins recognition session
 func (r *Recognizer) Start() error {
+	return r.StartContext(context.Background())
+}
+
+// StartContext is Start abandoning the connection handshake and the session
+// configuration when ctx is canceled or its deadline passes. ctx does not bound the
+// session started; it runs until Stop.
+func (r *Recognizer) StartContext(ctx context.Context) error {
 	r.runningMutex.Lock()
 	defer r.runningMutex.Unlock()
 
@@ -116,14 +163,27 @@ func (r *Recognizer) Start() error {
 		return ErrRecognizerRunning
 	}
 
-	log.Printf("[🚀 Recognizer] Starting recognition session")
+	r.logger.Debug("Starting recognition session", Fields{"component": "recognizer"})
+
+	// A stopped recognizer canceled its context, the new session needs a fresh one
+	if r.ctx.Err() != nil {
+		r.ctx, r.cancel = context.WithCancel(context.Background())
+	}
 
 	// Connect to WebSocket
-	if err := r.connManager.Connect(); err != nil {
+	connectCtx, cancel := r.operationContext(ctx)
+	defer cancel()
+	if err := r.connManager.Connect(connectCtx); err != nil {
 		r.sendError(fmt.Errorf("connection failed: %w", err))
 		return err
 	}
 
+	// Drop events buffered from a previous session
+	r.eventDispatcher.ClearReplayBuffer()
+	r.resume.reset()
+	r.sequence.reset()
+	r.resend.reset(r.config.ResendBufferDuration)
+
 	// Create session
 	session := r.sessionManager.CreateSession()
 
@@ -131,20 +191,30 @@ func (r *Recognizer) Start() error {
 	if r.config.Modality != "" || r.config.InputSampleRate > 0 {
 		sessionConfig := r.config.ToSessionConfig()
 		if err := r.sessionManager.UpdateSession(sessionConfig); err != nil {
-			log.Printf("[⚠️ Recognizer] Failed to configure session: %v", err)
+			r.logger.Warn("Failed to configure session", Fields{"component": "recognizer", "error": err})
 			// Continue anyway, session will use defaults
 		}
 	}
 
 	// Send session.update event to configure server
-	if err := r.sendSessionUpdate(session); err != nil {
+	if err := r.sendSessionUpdate(ctx, session); err != nil {
 		r.sendError(fmt.Errorf("session configuration failed: %w", err))
+		// Not running, so a later Start connects again
+		r.connManager.Disconnect()
 		return err
 	}
 
 	// Mark as running
 	r.isRunning = true
-	log.Printf("[✅ Recognizer] Recognition session started (Session ID: %s)", session.ID)
+	r.logger.Info("Recognition session started", Fields{"component": "recognizer", "sessionID": session.ID})
+
+	// Track items from the start so Drain can wait for every outstanding transcript
+	r.tracker()
+	r.itemTracker()
+
+	// Server-side turn detection commits without CommitAudio, the local buffer would
+	// fill up on a long stream
+	r.On(EventTypeInputAudioBufferCommitted, func(Event) { r.audioBuffer.Clear() })
 
 	// Start background goroutines
 	r.wg.Add(3)
@@ -159,8 +229,22 @@ func (r *Recognizer) Start() error {
 	return nil
 }
 
-// Stop stops the recognition session and cleans up resources
+// Stop stops the recognition session and cleans up resources, waiting up to 10 seconds
+// for the background goroutines to finish
 func (r *Recognizer) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := r.StopContext(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return nil
+}
+
+// StopContext is Stop waiting for the background goroutines until ctx is canceled or
+// its deadline passes. The session is stopped and its resources released either way;
+// the context error is returned when the goroutines did not finish in time.
+func (r *Recognizer) StopContext(ctx context.Context) error {
 	r.runningMutex.Lock()
 	defer r.runningMutex.Unlock()
 
@@ -168,7 +252,7 @@ func (r *Recognizer) Stop() error {
 		return ErrRecognizerNotRunning
 	}
 
-	log.Printf("[🛑 Recognizer] Stopping recognition session")
+	r.logger.Debug("Stopping recognition session", Fields{"component": "recognizer"})
 
 	// Cancel context to stop all goroutines
 	r.cancel()
@@ -178,7 +262,7 @@ func (r *Recognizer) Stop() error {
 
 	// Disconnect connection
 	if err := r.connManager.Disconnect(); err != nil {
-		log.Printf("[⚠️ Recognizer] Error during disconnection: %v", err)
+		r.logger.Warn("Failed to disconnect", Fields{"component": "recognizer", "error": err})
 	}
 
 	// Wait for goroutines to finish
@@ -188,11 +272,13 @@ func (r *Recognizer) Stop() error {
 		close(done)
 	}()
 
+	var waitErr error
 	select {
 	case <-done:
-		log.Printf("[✅ Recognizer] All goroutines stopped cleanly")
-	case <-time.After(10 * time.Second):
-		log.Printf("[⚠️ Recognizer] Timeout waiting for goroutines to stop")
+		r.logger.Debug("All goroutines stopped", Fields{"component": "recognizer"})
+	case <-ctx.Done():
+		r.logger.Warn("Stopped waiting for goroutines", Fields{"component": "recognizer", "error": ctx.Err()})
+		waitErr = ctx.Err()
 	}
 
 	// Cleanup resources
@@ -200,12 +286,72 @@ func (r *Recognizer) Stop() error {
 	r.audioBuffer.Clear()
 	r.eventDispatcher.ClearHandlers()
 
-	log.Printf("[✅ Recognizer] Recognition session stopped")
-	return nil
+	// The tracker's handlers are gone, a restarted session registers a new one
+	r.transcriptions = nil
+	r.transcriptionsOnce = sync.Once{}
+	r.items = nil
+	r.itemsOnce = sync.Once{}
+
+	r.logger.Info("Recognition session stopped", Fields{"component": "recognizer"})
+	return waitErr
+}
+
+// Drain commits the audio still buffered on the server, waits until every outstanding
+// utterance has been transcribed and then stops the recognizer. Unlike Stop it does not
+// lose the last utterance of a stream. When ctx expires first the recognizer is stopped
+// anyway and the context error is returned.
+func (r *Recognizer) Drain(ctx context.Context) error {
+	if !r.IsRunning() {
+		return ErrRecognizerNotRunning
+	}
+
+	r.logger.Debug("Draining recognition session", Fields{"component": "recognizer"})
+
+	drainErr := r.flush(ctx)
+	if drainErr != nil {
+		r.logger.Warn("Drain incomplete, stopping anyway", Fields{"component": "recognizer", "error": drainErr})
+	}
+
+	if err := r.Stop(); err != nil && drainErr == nil {
+		drainErr = err
+	}
+	return drainErr
+}
+
+// StopAndFlush is Drain with a timeout instead of a context
+func (r *Recognizer) StopAndFlush(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return r.Drain(ctx)
+}
+
+// flush commits the remaining audio and waits for all transcripts
+func (r *Recognizer) flush(ctx context.Context) error {
+	t := r.tracker()
+
+	pending, err := t.commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit remaining audio: %w", err)
+	}
+
+	// An empty buffer yields ErrNoAudioCommitted, failed transcripts are reported to
+	// the handlers already; either way the commit is settled
+	if _, err := t.wait(ctx, pending); err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return r.itemTracker().waitIdle(ctx)
 }
 
 // Write sends audio data to the server
 func (r *Recognizer) Write(audioData []byte) error {
+	return r.WriteContext(context.Background(), audioData)
+}
+
+// WriteContext is Write giving up when ctx is canceled or its deadline passes before
+// the audio is sent. A send abandoned halfway closes the connection, which the
+// recognizer reconnects when EnableReconnect is set.
+func (r *Recognizer) WriteContext(ctx context.Context, audioData []byte) error {
 	r.runningMutex.RLock()
 	defer r.runningMutex.RUnlock()
 
@@ -229,20 +375,42 @@ func (r *Recognizer) Write(audioData []byte) error {
 		return fmt.Errorf("audio conversion failed: %w", err)
 	}
 
-	// Create and send input_audio_buffer.append event
+	// Create and send input_audio_buffer.append event, as a binary frame when enabled
 	event := &InputAudioBufferAppendEvent{
 		BaseEvent: BaseEvent{
 			Type:    EventTypeInputAudioBufferAppend,
 			EventID: generateEventID(),
 		},
-		Audio: PCM16ToBase64(pcmSamples),
+	}
+	if r.config.BinaryAudio {
+		event.raw = PCM16ToBytes(pcmSamples)
+	} else {
+		event.Audio = PCM16ToBase64(pcmSamples)
+	}
+
+	// Attach checksum of the exact bytes being encoded
+	if r.config.EnableChecksum {
+		checksum := crc32.ChecksumIEEE(audioData)
+		event.CRC32 = &checksum
 	}
 
-	return r.sendEvent(event)
+	return r.sendAudioEvent(ctx, event, r.audioDuration(len(audioData)))
 }
 
 // CommitAudio commits the current audio buffer for processing
 func (r *Recognizer) CommitAudio() error {
+	return r.CommitAudioContext(context.Background())
+}
+
+// CommitAudioContext is CommitAudio giving up when ctx is canceled or its deadline
+// passes before the commit is sent
+func (r *Recognizer) CommitAudioContext(ctx context.Context) error {
+	return r.commitAudio(ctx, generateEventID())
+}
+
+// commitAudio sends input_audio_buffer.commit with the given event ID, which the server
+// echoes in the committed events answering it
+func (r *Recognizer) commitAudio(ctx context.Context, eventID string) error {
 	r.runningMutex.RLock()
 	defer r.runningMutex.RUnlock()
 
@@ -250,17 +418,23 @@ func (r *Recognizer) CommitAudio() error {
 		return ErrRecognizerNotRunning
 	}
 
-	log.Printf("[📤 Recognizer] Committing audio buffer")
+	r.logger.Debug("Committing audio buffer", Fields{"component": "recognizer"})
 
 	// Send input_audio_buffer.commit event
 	event := &InputAudioBufferCommitEvent{
 		BaseEvent: BaseEvent{
 			Type:    EventTypeInputAudioBufferCommit,
-			EventID: generateEventID(),
+			EventID: eventID,
 		},
 	}
 
-	return r.sendEvent(event)
+	if err := r.sendAudioEvent(ctx, event, 0); err != nil {
+		return err
+	}
+
+	// Committed audio is no longer pending, free the local buffer for the next utterance
+	r.audioBuffer.Clear()
+	return nil
 }
 
 // ClearAudioBuffer clears the audio buffer
@@ -272,7 +446,7 @@ func (r *Recognizer) ClearAudioBuffer() error {
 		return ErrRecognizerNotRunning
 	}
 
-	log.Printf("[🧹 Recognizer] Clearing audio buffer")
+	r.logger.Debug("Clearing audio buffer", Fields{"component": "recognizer"})
 
 	// Clear local buffer
 	r.audioBuffer.Clear()
@@ -285,7 +459,53 @@ func (r *Recognizer) ClearAudioBuffer() error {
 		},
 	}
 
-	return r.sendEvent(event)
+	return r.sendAudioEvent(context.Background(), event, 0)
+}
+
+// OnReconnected sets the callback run after the recognizer replaced a dropped
+// connection and re-sent the audio written meanwhile; nil removes it. It runs on the
+// goroutine receiving events and should return quickly.
+func (r *Recognizer) OnReconnected(fn func(ReconnectStats)) {
+	r.reconnectedMutex.Lock()
+	defer r.reconnectedMutex.Unlock()
+	r.onReconnected = fn
+}
+
+// AddEventHandler attaches an additional event handler and returns an ID that can be
+// passed to RemoveEventHandler. It is safe to call at any time, including while the
+// recognizer is running: the buffered recent events (session.created,
+// conversation.created, ...) are replayed to the handler first, followed by every
+// live event dispatched after the call returns.
+func (r *Recognizer) AddEventHandler(handler EventHandler) HandlerID {
+	if handler == nil {
+		return 0
+	}
+	return r.eventDispatcher.RegisterEventHandlerWithReplay(handler)
+}
+
+// RemoveEventHandler detaches a handler added with AddEventHandler or On. Once it
+// returns, the handler receives no further events apart from a delivery already in flight.
+func (r *Recognizer) RemoveEventHandler(id HandlerID) bool {
+	return r.eventDispatcher.RemoveEventHandler(id)
+}
+
+// On subscribes fn to a single event type (or EventTypeAny) while the recognizer is
+// running. Unlike AddEventHandler, buffered events are not replayed.
+func (r *Recognizer) On(eventType string, fn func(Event)) HandlerID {
+	if fn == nil {
+		return 0
+	}
+	return r.eventDispatcher.On(eventType, fn)
+}
+
+// Off removes a subscription created with On
+func (r *Recognizer) Off(id HandlerID) bool {
+	return r.eventDispatcher.Off(id)
+}
+
+// RecentEvents returns the buffered recent events ordered from oldest to newest
+func (r *Recognizer) RecentEvents() []Event {
+	return r.eventDispatcher.RecentEvents()
 }
 
 // IsRunning returns the current running status
@@ -304,11 +524,42 @@ func (r *Recognizer) GetSessionID() string {
 	return session.ID
 }
 
+// Capabilities returns the server feature set reported in session.created, or nil
+// if the server did not report one
+func (r *Recognizer) Capabilities() *Capabilities {
+	session := r.sessionManager.GetSession()
+	if session == nil {
+		return nil
+	}
+	return session.Capabilities
+}
+
+// tracker returns the commit/transcript correlation tracker of this recognizer
+func (r *Recognizer) tracker() *transcriptionTracker {
+	r.transcriptionsOnce.Do(func() {
+		r.transcriptions = newTranscriptionTracker(r)
+	})
+	return r.transcriptions
+}
+
 // GetConnectionStatus returns the current connection status
 func (r *Recognizer) GetConnectionStatus() ConnectionStatus {
 	return r.connManager.GetStatus()
 }
 
+// ConnectionState returns the current state of the connection
+func (r *Recognizer) ConnectionState() ConnectionState {
+	return r.connManager.State()
+}
+
+// StateChanges returns a channel receiving every later change of the connection state
+// across Start and Stop, with its time, reason and error: connecting, connected, the
+// connection dropping, each reconnect attempt and closing. Each call returns a new
+// channel; a receiver that falls more than 32 transitions behind loses the oldest ones.
+func (r *Recognizer) StateChanges() <-chan StateTransition {
+	return r.connManager.StateChanges()
+}
+
 // GetStats returns current statistics
 func (r *Recognizer) GetStats() map[string]interface{} {
 	session := r.sessionManager.GetSession()
@@ -340,7 +591,7 @@ func (r *Recognizer) GetStats() map[string]interface{} {
 }
 
 // sendSessionUpdate sends a session.update event to configure the server
-func (r *Recognizer) sendSessionUpdate(session *Session) error {
+func (r *Recognizer) sendSessionUpdate(ctx context.Context, session *Session) error {
 	// Create session.update event
 	event := &SessionUpdateEvent{
 		BaseEvent: BaseEvent{
@@ -358,14 +609,18 @@ func (r *Recognizer) sendSessionUpdate(session *Session) error {
 				SampleRate int    `json:"sample_rate"`
 				Channels   int    `json:"channels"`
 			} `json:"input_audio_format,omitempty"`
+			InputEncoding *string `json:"input_encoding,omitempty"`
 			OutputAudioFormat struct {
 				Type       string `json:"type"`
 				SampleRate int    `json:"sample_rate"`
 				Voice      string `json:"voice,omitempty"`
 			} `json:"output_audio_format,omitempty"`
 			InputAudioTranscription *struct {
-				Model    string `json:"model"`
-				Language string `json:"language"`
+				Model          string `json:"model"`
+				Language       string `json:"language"`
+				InterimResults bool   `json:"interim_results,omitempty"`
+				DeltaMode      string `json:"delta_mode,omitempty"`
+				Hints          []string `json:"hints,omitempty"`
 			} `json:"input_audio_transcription,omitempty"`
 			TurnDetection *struct {
 				Type              string  `json:"type"`
@@ -375,6 +630,15 @@ func (r *Recognizer) sendSessionUpdate(session *Session) error {
 			} `json:"turn_detection,omitempty"`
 			Tools []interface{} `json:"tools,omitempty"`
 			ToolChoice string `json:"tool_choice,omitempty"`
+			DeterministicSeed *int64 `json:"deterministic_seed,omitempty"`
+			TranscriptFormat *TranscriptFormat `json:"transcript_format,omitempty"`
+			Captions *CaptionConfig `json:"captions,omitempty"`
+			Recognition *RecognitionConfig `json:"recognition,omitempty"`
+			Pipeline *string `json:"pipeline,omitempty"`
+			BandwidthReportSeconds *int `json:"bandwidth_report_seconds,omitempty"`
+			StatsReportSeconds *int `json:"stats_report_seconds,omitempty"`
+			InputAudioNoiseReduction *NoiseReductionConfig `json:"input_audio_noise_reduction,omitempty"`
+			InputAudioGainControl *GainControlConfig `json:"input_audio_gain_control,omitempty"`
 		}{
 			ID:       session.ID,
 			Modality: session.Modality,
@@ -391,16 +655,53 @@ func (r *Recognizer) sendSessionUpdate(session *Session) error {
 	}
 
 	// Add optional fields if they exist
+	if r.config.DeterministicSeed != nil {
+		event.Session.DeterministicSeed = r.config.DeterministicSeed
+	}
+	if r.config.TranscriptFormat != nil {
+		event.Session.TranscriptFormat = r.config.TranscriptFormat
+	}
+	if r.config.Captions != nil {
+		event.Session.Captions = r.config.Captions
+	}
+	if r.config.Recognition != nil {
+		event.Session.Recognition = r.config.Recognition
+	}
+	if r.config.Pipeline != "" {
+		event.Session.Pipeline = &r.config.Pipeline
+	}
+	if r.config.BandwidthReportSeconds != nil {
+		event.Session.BandwidthReportSeconds = r.config.BandwidthReportSeconds
+	}
+	if r.config.StatsReportSeconds != nil {
+		event.Session.StatsReportSeconds = r.config.StatsReportSeconds
+	}
+	if r.config.NoiseReduction != nil {
+		event.Session.InputAudioNoiseReduction = r.config.NoiseReduction
+	}
+	if r.config.GainControl != nil {
+		event.Session.InputAudioGainControl = r.config.GainControl
+	}
+	if r.config.BinaryAudio {
+		encoding := InputEncodingBinary
+		event.Session.InputEncoding = &encoding
+	}
 	if session.Instructions != "" {
 		event.Session.Instructions = session.Instructions
 	}
 	if session.InputAudioTranscription != nil {
 		event.Session.InputAudioTranscription = &struct {
-			Model    string `json:"model"`
-			Language string `json:"language"`
+			Model          string `json:"model"`
+			Language       string `json:"language"`
+			InterimResults bool   `json:"interim_results,omitempty"`
+			DeltaMode      string `json:"delta_mode,omitempty"`
+			Hints          []string `json:"hints,omitempty"`
 		}{
-			Model:    session.InputAudioTranscription.Model,
-			Language: session.InputAudioTranscription.Language,
+			Model:          session.InputAudioTranscription.Model,
+			Language:       session.InputAudioTranscription.Language,
+			InterimResults: session.InputAudioTranscription.InterimResults,
+			DeltaMode:      session.InputAudioTranscription.DeltaMode,
+			Hints:          session.InputAudioTranscription.Hints,
 		}
 	}
 	if session.TurnDetection != nil {
@@ -423,11 +724,16 @@ func (r *Recognizer) sendSessionUpdate(session *Session) error {
 		event.Session.ToolChoice = session.ToolChoice
 	}
 
-	return r.sendEvent(event)
+	return r.sendEventContext(ctx, event)
 }
 
 // sendEvent serializes and sends an event to the server
 func (r *Recognizer) sendEvent(event Event) error {
+	return r.sendEventContext(context.Background(), event)
+}
+
+// sendEventContext is sendEvent giving up when ctx or the recognizer ends
+func (r *Recognizer) sendEventContext(ctx context.Context, event Event) error {
 	// Set session ID if available
 	session := r.sessionManager.GetSession()
 	if session != nil {
@@ -443,6 +749,14 @@ func (r *Recognizer) sendEvent(event Event) error {
 		}
 	}
 
+	sendCtx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	// Audio of a binary session goes out as a frame, without Base64
+	if e, ok := event.(*InputAudioBufferAppendEvent); ok && e.raw != nil {
+		return r.connManager.SendBinary(sendCtx, encodeAudioFrame(e.raw, e.CRC32 != nil))
+	}
+
 	// Serialize event
 	data, err := json.Marshal(event)
 	if err != nil {
@@ -450,7 +764,40 @@ func (r *Recognizer) sendEvent(event Event) error {
 	}
 
 	// Send via connection manager
-	return r.connManager.SendMessage(data)
+	return r.connManager.SendMessage(sendCtx, data)
+}
+
+// operationContext returns a context canceled with ctx or when the recognizer stops
+func (r *Recognizer) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(r.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// sendAudioEvent sends an audio buffer event. While the connection is down, or when
+// the send fails and the recognizer reconnects, the event is queued and sent after the
+// reconnect instead; a send the caller canceled is not.
+func (r *Recognizer) sendAudioEvent(ctx context.Context, event Event, audio time.Duration) error {
+	if r.resend.add(event, audio, false) {
+		return nil
+	}
+	err := r.sendEventContext(ctx, event)
+	if err != nil && ctx.Err() == nil && r.config.EnableReconnect && r.resend.add(event, audio, true) {
+		return nil
+	}
+	return err
+}
+
+// audioDuration returns the duration of size bytes of input PCM16 audio
+func (r *Recognizer) audioDuration(size int) time.Duration {
+	bytesPerSecond := r.config.InputSampleRate * max(r.config.InputChannels, 1) * 2
+	if bytesPerSecond <= 0 {
+		return 0
+	}
+	return time.Duration(size) * time.Second / time.Duration(bytesPerSecond)
 }
 
 // convertToPCM16 converts audio data to 16-bit PCM samples
@@ -474,27 +821,54 @@ func (r *Recognizer) convertToPCM16(audioData []byte) ([]int16, error) {
 func (r *Recognizer) messageReceiver() {
 	defer r.wg.Done()
 
-	log.Printf("[📡 Receiver] Starting message receiver")
+	r.logger.Debug("Starting message receiver", Fields{"component": "receiver"})
 
 	for {
 		select {
 		case <-r.ctx.Done():
-			log.Printf("[📡 Receiver] Message receiver stopped")
+			r.logger.Debug("Message receiver stopped", Fields{"component": "receiver"})
 			return
 		default:
-			messageType, message, err := r.connManager.ReadMessage()
+			messageType, message, err := r.connManager.ReadMessage(r.ctx)
 			if err != nil {
+				// Stop cancels the context and closes the connection under the read
+				if r.ctx.Err() != nil {
+					r.logger.Debug("Message receiver stopped", Fields{"component": "receiver"})
+					return
+				}
+				if r.config.EnableReconnect {
+					r.logger.Warn("Connection lost", Fields{"component": "receiver", "error": err})
+					r.resend.hold()
+					reconnectErr := r.reconnect()
+					if reconnectErr == nil {
+						continue
+					}
+					if dropped := r.resend.disable(); dropped > 0 {
+						r.logger.Warn("Dropping audio buffer events not sent before the connection was lost", Fields{"component": "receiver", "events": dropped})
+					}
+					err = fmt.Errorf("%w, reconnect failed: %v", err, reconnectErr)
+				}
 				r.sendError(fmt.Errorf("receive error: %w", err))
 				return
 			}
 
 			if messageType == websocket.TextMessage {
-				select {
-				case r.eventChan <- message:
-					r.eventStats.RecordEvent("message_received", false, "")
-				default:
-					log.Printf("[⚠️ Receiver] Event channel full, dropping message")
-					r.eventStats.RecordEvent("message_dropped", true, "event channel full")
+				deliver, outcome, replayed := r.resume.receive(message)
+				for _, message := range deliver {
+					select {
+					case r.eventChan <- message:
+						r.eventStats.RecordEvent("message_received", false, "")
+					default:
+						r.logger.Warn("Event channel full, dropping message", Fields{"component": "receiver"})
+						r.eventStats.RecordEvent("message_dropped", true, "event channel full")
+					}
+				}
+				switch outcome {
+				case resumeAccepted:
+					r.finishReconnect(true, replayed)
+				case resumeRefused:
+					r.continueInNewSession()
+					r.finishReconnect(false, 0)
 				}
 			}
 		}
@@ -505,14 +879,15 @@ func (r *Recognizer) messageReceiver() {
 func (r *Recognizer) eventProcessor() {
 	defer r.wg.Done()
 
-	log.Printf("[⚙️ Processor] Starting event processor")
+	r.logger.Debug("Starting event processor", Fields{"component": "processor"})
 
 	for {
 		select {
 		case <-r.ctx.Done():
-			log.Printf("[⚙️ Processor] Event processor stopped")
+			r.logger.Debug("Event processor stopped", Fields{"component": "processor"})
 			return
 		case message := <-r.eventChan:
+			r.checkSequence(message)
 			if err := r.eventDispatcher.Dispatch(message); err != nil {
 				r.sendError(fmt.Errorf("event processing error: %w", err))
 				r.eventStats.RecordEvent("event_processing_error", true, err.Error())
@@ -527,7 +902,7 @@ func (r *Recognizer) eventProcessor() {
 func (r *Recognizer) connectionMonitor() {
 	defer r.wg.Done()
 
-	log.Printf("[📊 Monitor] Starting connection monitor")
+	r.logger.Debug("Starting connection monitor", Fields{"component": "monitor"})
 
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
@@ -535,9 +910,13 @@ func (r *Recognizer) connectionMonitor() {
 	for {
 		select {
 		case <-r.ctx.Done():
-			log.Printf("[📊 Monitor] Connection monitor stopped")
+			r.logger.Debug("Connection monitor stopped", Fields{"component": "monitor"})
 			return
 		case <-ticker.C:
+			// The receiver reconnects a dropped connection
+			if r.resume.pending() {
+				continue
+			}
 			status := r.connManager.GetStatus()
 			if status == ConnectionStatusDisconnected || status == ConnectionStatusFailed {
 				r.sendError(fmt.Errorf("connection lost"))
@@ -547,11 +926,79 @@ func (r *Recognizer) connectionMonitor() {
 	}
 }
 
+// reconnect replaces the dropped connection and resumes the session on it with
+// session.resume, the server replaying the events sent while the recognizer was away.
+// Without a resume token the recognizer continues in the new session the server
+// created for the connection.
+func (r *Recognizer) reconnect() error {
+	sessionID, token, lastEventID := r.resume.begin()
+	if err := r.connManager.Reconnect(r.ctx); err != nil {
+		r.resume.abort()
+		return err
+	}
+
+	if token == "" {
+		r.resume.abort()
+		r.continueInNewSession()
+		r.finishReconnect(false, 0)
+		return nil
+	}
+
+	r.logger.Info("Resuming session", Fields{"component": "recognizer", "sessionID": sessionID, "lastEventID": lastEventID})
+	event := &SessionResumeEvent{
+		BaseEvent: BaseEvent{
+			Type:      EventTypeSessionResume,
+			EventID:   generateEventID(),
+			SessionID: sessionID,
+		},
+		ResumeToken: token,
+		LastEventID: lastEventID,
+	}
+	if err := r.sendEvent(event); err != nil {
+		r.resume.abort()
+		return err
+	}
+	return nil
+}
+
+// continueInNewSession configures the session the server created for a new connection
+// like the one that could not be resumed
+func (r *Recognizer) continueInNewSession() {
+	r.logger.Warn("Session not resumed, continuing in a new session", Fields{"component": "recognizer"})
+	r.sendError(ErrSessionNotResumed)
+	if err := r.sendSessionUpdate(r.ctx, r.sessionManager.GetSession()); err != nil {
+		r.sendError(fmt.Errorf("session configuration failed: %w", err))
+	}
+}
+
+// finishReconnect sends the audio written while the connection was down and reports
+// the reconnect to the OnReconnected callback. When the connection drops again the
+// remaining audio stays queued for the next reconnect.
+func (r *Recognizer) finishReconnect(resumed bool, replayedEvents int) {
+	stats, err := r.resend.flush(r.sendEvent)
+	if err != nil {
+		r.logger.Warn("Re-sending buffered audio failed", Fields{"component": "recognizer", "error": err})
+		return
+	}
+	stats.Resumed = resumed
+	stats.ReplayedEvents = replayedEvents
+
+	r.logger.Info("Reconnected", Fields{"component": "recognizer", "resumed": resumed, "eventsReplayed": replayedEvents,
+		"audioResent": stats.ReplayedAudio, "audioDropped": stats.DroppedAudio})
+
+	r.reconnectedMutex.Lock()
+	fn := r.onReconnected
+	r.reconnectedMutex.Unlock()
+	if fn != nil {
+		fn(stats)
+	}
+}
+
 // heartbeatLoop sends periodic heartbeat pings
 func (r *Recognizer) heartbeatLoop() {
 	defer r.wg.Done()
 
-	log.Printf("[💓 Heartbeat] Starting heartbeat loop")
+	r.logger.Debug("Starting heartbeat loop", Fields{"component": "heartbeat"})
 
 	ticker := time.NewTicker(r.config.HeartbeatInterval)
 	defer ticker.Stop()
@@ -559,7 +1006,7 @@ func (r *Recognizer) heartbeatLoop() {
 	for {
 		select {
 		case <-r.ctx.Done():
-			log.Printf("[💓 Heartbeat] Heartbeat loop stopped")
+			r.logger.Debug("Heartbeat loop stopped", Fields{"component": "heartbeat"})
 			return
 		case <-ticker.C:
 			if r.connManager.IsConnected() {
@@ -572,7 +1019,7 @@ func (r *Recognizer) heartbeatLoop() {
 				}
 
 				if err := r.sendEvent(event); err != nil {
-					log.Printf("[⚠️ Heartbeat] Failed to send ping: %v", err)
+					r.logger.Warn("Failed to send ping", Fields{"component": "heartbeat", "error": err})
 					r.eventStats.RecordEvent("heartbeat_error", true, err.Error())
 				} else {
 					r.eventStats.RecordEvent("heartbeat_sent", false, "")
@@ -587,7 +1034,7 @@ func (r *Recognizer) sendError(err error) {
 	select {
 	case r.errorChan <- err:
 	default:
-		log.Printf("[⚠️ Recognizer] Error channel full, dropping error: %v", err)
+		r.logger.Warn("Error channel full, dropping error", Fields{"component": "recognizer", "error": err})
 	}
 }
 
@@ -0,0 +1,96 @@
+package asr
+
+import "sync"
+
+// DefaultReplayBufferSize is the number of recent events kept for late-attached handlers
+const DefaultReplayBufferSize = 32
+
+// EventReplayBuffer keeps the last N dispatched events in a ring buffer so that
+// handlers attached after Start can still observe session lifecycle events
+type EventReplayBuffer struct {
+	events   []Event
+	capacity int
+	next     int
+	count    int
+	mutex    sync.RWMutex
+}
+
+// NewEventReplayBuffer creates a replay buffer holding up to capacity events.
+// A capacity of zero or less disables buffering.
+func NewEventReplayBuffer(capacity int) *EventReplayBuffer {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &EventReplayBuffer{
+		events:   make([]Event, capacity),
+		capacity: capacity,
+	}
+}
+
+// Add records an event, evicting the oldest one when the buffer is full
+func (rb *EventReplayBuffer) Add(event Event) {
+	if rb == nil || rb.capacity == 0 || event == nil {
+		return
+	}
+
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	rb.events[rb.next] = event
+	rb.next = (rb.next + 1) % rb.capacity
+	if rb.count < rb.capacity {
+		rb.count++
+	}
+}
+
+// Snapshot returns the buffered events ordered from oldest to newest
+func (rb *EventReplayBuffer) Snapshot() []Event {
+	if rb == nil || rb.capacity == 0 {
+		return nil
+	}
+
+	rb.mutex.RLock()
+	defer rb.mutex.RUnlock()
+
+	events := make([]Event, 0, rb.count)
+	start := (rb.next - rb.count + rb.capacity) % rb.capacity
+	for i := 0; i < rb.count; i++ {
+		events = append(events, rb.events[(start+i)%rb.capacity])
+	}
+	return events
+}
+
+// Len returns the number of buffered events
+func (rb *EventReplayBuffer) Len() int {
+	if rb == nil {
+		return 0
+	}
+
+	rb.mutex.RLock()
+	defer rb.mutex.RUnlock()
+	return rb.count
+}
+
+// Capacity returns the maximum number of buffered events
+func (rb *EventReplayBuffer) Capacity() int {
+	if rb == nil {
+		return 0
+	}
+	return rb.capacity
+}
+
+// Clear drops all buffered events
+func (rb *EventReplayBuffer) Clear() {
+	if rb == nil {
+		return
+	}
+
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	for i := range rb.events {
+		rb.events[i] = nil
+	}
+	rb.next = 0
+	rb.count = 0
+}
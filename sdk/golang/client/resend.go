@@ -0,0 +1,149 @@
+package asr
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultResendBufferDuration is the audio kept for re-sending while the recognizer
+// reconnects
+const DefaultResendBufferDuration = 10 * time.Second
+
+// ReconnectStats describes a reconnect, reported to the OnReconnected callback
+type ReconnectStats struct {
+	// The server resumed the session; false when recognition continues in a new one
+	Resumed bool
+	// Server events the recognizer missed while disconnected and received on resume
+	ReplayedEvents int
+	// Audio buffer events written while disconnected and sent after the reconnect
+	ReplayedChunks int
+	ReplayedAudio  time.Duration
+	// Audio dropped because the resend buffer was full
+	DroppedChunks int
+	DroppedAudio  time.Duration
+}
+
+// resendEntry is an audio buffer event waiting to be sent, audio is zero for commits
+// and clears
+type resendEntry struct {
+	event Event
+	audio time.Duration
+}
+
+// resendQueue holds the audio buffer events written while the connection is down and
+// sends them in order once it is back. It is bounded by the duration of the queued
+// audio; beyond it the oldest audio is dropped. New events queue behind the held ones
+// until the queue is flushed, so audio is never reordered.
+type resendQueue struct {
+	mutex    sync.Mutex
+	limit    time.Duration
+	entries  []resendEntry
+	queued   time.Duration
+	holding  bool  // the connection is down or the queue is being flushed
+	inFlight Event // the event flush is sending, never dropped
+	disabled bool  // reconnecting gave up, writes fail instead of queueing
+	stats    ReconnectStats
+}
+
+// reset empties the queue for a new run of the recognizer
+func (q *resendQueue) reset(limit time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.limit = limit
+	q.entries = nil
+	q.queued = 0
+	q.holding = false
+	q.disabled = false
+	q.stats = ReconnectStats{}
+}
+
+// hold starts queueing, the connection dropped
+func (q *resendQueue) hold() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.limit > 0 && !q.disabled {
+		q.holding = true
+	}
+}
+
+// add queues an event while holding, or when its send failed, and reports whether it
+// did. An event it does not queue is to be sent, or its send error returned.
+func (q *resendQueue) add(event Event, audio time.Duration, sendFailed bool) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.limit <= 0 || q.disabled || !(q.holding || sendFailed) {
+		return false
+	}
+	q.holding = true
+	q.entries = append(q.entries, resendEntry{event: event, audio: audio})
+	q.queued += audio
+
+	// Drop the oldest audio beyond the limit, commits and clears are kept
+	for i := 0; q.queued > q.limit && i < len(q.entries); {
+		if q.entries[i].audio == 0 || q.entries[i].event == q.inFlight {
+			i++
+			continue
+		}
+		q.queued -= q.entries[i].audio
+		q.stats.DroppedChunks++
+		q.stats.DroppedAudio += q.entries[i].audio
+		q.entries = append(q.entries[:i], q.entries[i+1:]...)
+	}
+	return true
+}
+
+// flush sends the queued events in order with send and stops holding once the queue
+// is empty. It stops at the first failed send, keeping that event queued for the next
+// reconnect, and returns the statistics since the connection dropped.
+func (q *resendQueue) flush(send func(Event) error) (ReconnectStats, error) {
+	for {
+		q.mutex.Lock()
+		if len(q.entries) == 0 {
+			q.holding = false
+			stats := q.stats
+			q.stats = ReconnectStats{}
+			q.mutex.Unlock()
+			return stats, nil
+		}
+		entry := q.entries[0]
+		q.inFlight = entry.event
+		q.mutex.Unlock()
+
+		err := send(entry.event)
+
+		q.mutex.Lock()
+		q.inFlight = nil
+		if err != nil {
+			stats := q.stats
+			q.mutex.Unlock()
+			return stats, err
+		}
+		// disable may have emptied the queue meanwhile
+		if len(q.entries) > 0 && q.entries[0].event == entry.event {
+			q.entries = q.entries[1:]
+			q.queued -= entry.audio
+			if entry.audio > 0 {
+				q.stats.ReplayedChunks++
+				q.stats.ReplayedAudio += entry.audio
+			}
+		}
+		q.mutex.Unlock()
+	}
+}
+
+// disable drops the queue once reconnecting gave up and returns the number of events
+// dropped
+func (q *resendQueue) disable() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	dropped := len(q.entries)
+	q.entries = nil
+	q.queued = 0
+	q.holding = false
+	q.disabled = true
+	return dropped
+}
@@ -0,0 +1,224 @@
+package asr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// resendStep queues an event, audio is zero for commits
+type resendStep struct {
+	id         string
+	audio      time.Duration
+	sendFailed bool
+	queued     bool // add reports the event queued
+}
+
+func TestResendQueue(t *testing.T) {
+	const chunk = 100 * time.Millisecond
+	tests := []struct {
+		name      string
+		limit     time.Duration
+		hold      bool
+		disable   bool
+		steps     []resendStep
+		wantSent  []string
+		wantStats ReconnectStats
+	}{
+		{
+			name:  "connected sends directly",
+			limit: time.Second,
+			steps: []resendStep{{id: "a1", audio: chunk}},
+		},
+		{
+			name:  "held events keep their order",
+			limit: time.Second,
+			hold:  true,
+			steps: []resendStep{
+				{id: "a1", audio: chunk, queued: true},
+				{id: "commit", queued: true},
+				{id: "a2", audio: chunk, queued: true},
+			},
+			wantSent:  []string{"a1", "commit", "a2"},
+			wantStats: ReconnectStats{ReplayedChunks: 2, ReplayedAudio: 2 * chunk},
+		},
+		{
+			name:  "failed send starts holding",
+			limit: time.Second,
+			steps: []resendStep{
+				{id: "a1", audio: chunk, sendFailed: true, queued: true},
+				{id: "a2", audio: chunk, queued: true},
+			},
+			wantSent:  []string{"a1", "a2"},
+			wantStats: ReconnectStats{ReplayedChunks: 2, ReplayedAudio: 2 * chunk},
+		},
+		{
+			name:  "oldest audio dropped beyond the limit, commits kept",
+			limit: 250 * time.Millisecond,
+			hold:  true,
+			steps: []resendStep{
+				{id: "a1", audio: chunk, queued: true},
+				{id: "commit", queued: true},
+				{id: "a2", audio: chunk, queued: true},
+				{id: "a3", audio: chunk, queued: true},
+				{id: "a4", audio: chunk, queued: true},
+			},
+			wantSent: []string{"commit", "a3", "a4"},
+			wantStats: ReconnectStats{
+				ReplayedChunks: 2, ReplayedAudio: 2 * chunk,
+				DroppedChunks: 2, DroppedAudio: 2 * chunk,
+			},
+		},
+		{
+			name:  "no buffer never queues",
+			hold:  true,
+			steps: []resendStep{{id: "a1", audio: chunk, sendFailed: true}},
+		},
+		{
+			name:    "disabled after reconnecting gave up",
+			limit:   time.Second,
+			hold:    true,
+			disable: true,
+			steps:   []resendStep{{id: "a1", audio: chunk, sendFailed: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q resendQueue
+			q.reset(tt.limit)
+			if tt.hold {
+				q.hold()
+			}
+			if tt.disable {
+				q.disable()
+			}
+			for _, step := range tt.steps {
+				event := &BaseEvent{Type: EventTypeInputAudioBufferAppend, EventID: step.id}
+				if queued := q.add(event, step.audio, step.sendFailed); queued != step.queued {
+					t.Fatalf("add(%s) = %v, want %v", step.id, queued, step.queued)
+				}
+			}
+
+			var sent []string
+			stats, err := q.flush(func(event Event) error {
+				sent = append(sent, event.GetEventID())
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(sent, tt.wantSent) {
+				t.Errorf("sent %v, want %v", sent, tt.wantSent)
+			}
+			if stats != tt.wantStats {
+				t.Errorf("stats %+v, want %+v", stats, tt.wantStats)
+			}
+			if q.add(&BaseEvent{EventID: "later"}, chunk, false) {
+				t.Error("flushed queue still holding")
+			}
+		})
+	}
+}
+
+func TestResendQueueFlushKeepsFailedEvent(t *testing.T) {
+	var q resendQueue
+	q.reset(time.Second)
+	q.hold()
+	for _, id := range []string{"a1", "a2", "a3"} {
+		q.add(&BaseEvent{EventID: id}, 100*time.Millisecond, false)
+	}
+
+	var sent []string
+	failing := func(event Event) error {
+		if event.GetEventID() == "a2" {
+			return errors.New("connection lost")
+		}
+		sent = append(sent, event.GetEventID())
+		return nil
+	}
+	if _, err := q.flush(failing); err == nil {
+		t.Fatal("flush did not report the failed send")
+	}
+	// Events written after the failure queue behind the unsent ones
+	if !q.add(&BaseEvent{EventID: "a4"}, 100*time.Millisecond, false) {
+		t.Fatal("queue stopped holding after a failed flush")
+	}
+
+	stats, err := q.flush(func(event Event) error {
+		sent = append(sent, event.GetEventID())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a1", "a2", "a3", "a4"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %v, want %v", sent, want)
+	}
+	if stats.ReplayedChunks != 4 {
+		t.Errorf("replayed %d chunks, want 4", stats.ReplayedChunks)
+	}
+}
+
+func TestRecognizerResendsAudioAfterReconnect(t *testing.T) {
+	server := newFakeServer(t)
+	r := startRecognizer(t, server, func(config *Config) {
+		config.MaxReconnectAttempts = 100
+		config.ResendBufferDuration = 250 * time.Millisecond
+	})
+	reconnected := make(chan ReconnectStats, 1)
+	r.OnReconnected(func(stats ReconnectStats) { reconnected <- stats })
+
+	server.reject(true)
+	server.drop()
+	waitFor(t, func() bool { return r.ConnectionState() == StateReconnecting }, "reconnecting")
+
+	// 100ms chunks of 16 kHz mono PCM16, the buffer keeps 250ms of them
+	chunks := make([][]byte, 4)
+	for i := range chunks {
+		chunks[i] = bytes.Repeat([]byte{byte(i + 1)}, 3200)
+	}
+	for i, chunk := range chunks {
+		if err := r.Write(chunk); err != nil {
+			t.Fatalf("write %d while reconnecting: %v", i, err)
+		}
+		if i == 1 {
+			if err := r.CommitAudio(); err != nil {
+				t.Fatalf("commit while reconnecting: %v", err)
+			}
+		}
+	}
+	server.reject(false)
+
+	var stats ReconnectStats
+	select {
+	case stats = <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recognizer did not reconnect")
+	}
+	want := ReconnectStats{
+		ReplayedChunks: 2, ReplayedAudio: 200 * time.Millisecond,
+		DroppedChunks: 2, DroppedAudio: 200 * time.Millisecond,
+	}
+	if stats != want {
+		t.Errorf("reconnect stats %+v, want %+v", stats, want)
+	}
+
+	// The new session is configured before the queued events arrive in order
+	server.next(t, EventTypeSessionUpdate)
+	if event := server.next(t, EventTypeInputAudioBufferAppend, EventTypeInputAudioBufferCommit); event.Type != EventTypeInputAudioBufferCommit {
+		t.Fatalf("first re-sent event %s, want the commit", event.Type)
+	}
+	for _, chunk := range chunks[2:] {
+		event := server.next(t, EventTypeInputAudioBufferAppend)
+		audio, err := base64.StdEncoding.DecodeString(event.Audio)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(audio, chunk) {
+			t.Fatalf("re-sent chunk %d, want chunk %d", audio[0], chunk[0])
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package asr
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// messageEnvelope holds the fields of a server event needed to resume its session
+type messageEnvelope struct {
+	Type        string `json:"type"`
+	EventID     string `json:"event_id"`
+	SessionID   string `json:"session_id"`
+	ResumeToken string `json:"resume_token"`
+	Replayed    int    `json:"replayed"`
+	Error       struct {
+		Code string `json:"code"`
+	} `json:"error"`
+}
+
+type heldMessage struct {
+	data     []byte
+	envelope messageEnvelope
+}
+
+// resumeOutcome is the answer of the server to session.resume
+type resumeOutcome int
+
+const (
+	resumeNone     resumeOutcome = iota // the message did not answer session.resume
+	resumeAccepted                      // session.resumed arrived
+	resumeRefused                       // the server keeps the session it created for the connection
+)
+
+// resumeState tracks what the recognizer needs to resume its server session after the
+// connection dropped: the session ID and resume token from session.created and the ID
+// of the last event received.
+//
+// While a new connection resumes the session, the server has already created another
+// session for it; the events of that session are held back until session.resumed
+// arrives and dropped, or delivered when the server refuses to resume.
+type resumeState struct {
+	mutex       sync.Mutex
+	sessionID   string
+	token       string
+	lastEventID string
+	resuming    bool
+	held        []heldMessage
+}
+
+// reset forgets the session of a previous run
+func (rs *resumeState) reset() {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	rs.sessionID, rs.token, rs.lastEventID = "", "", ""
+	rs.resuming = false
+	rs.held = nil
+}
+
+// begin starts resuming and returns the session to resume, the token is empty when
+// the server did not offer one
+func (rs *resumeState) begin() (sessionID, token, lastEventID string) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	rs.resuming = true
+	rs.held = nil
+	return rs.sessionID, rs.token, rs.lastEventID
+}
+
+// abort ends resuming without a session.resumed
+func (rs *resumeState) abort() {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	rs.resuming = false
+	rs.held = nil
+}
+
+// pending reports whether the recognizer is reconnecting or waiting for session.resumed
+func (rs *resumeState) pending() bool {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	return rs.resuming
+}
+
+// receive records a message from the server and returns the messages to dispatch,
+// none while they belong to the session created for a resuming connection, and the
+// outcome of a resume the message answers with the number of events replayed. When
+// the server refused to resume, the new session replaces the old one.
+func (rs *resumeState) receive(message []byte) (deliver [][]byte, outcome resumeOutcome, replayed int) {
+	var envelope messageEnvelope
+	if err := json.Unmarshal(message, &envelope); err != nil {
+		return [][]byte{message}, resumeNone, 0
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if rs.resuming && envelope.SessionID != rs.sessionID {
+		if envelope.Type != EventTypeError || envelope.Error.Code != "session_not_resumable" {
+			rs.held = append(rs.held, heldMessage{data: message, envelope: envelope})
+			return nil, resumeNone, 0
+		}
+
+		for _, held := range rs.held {
+			rs.record(held.envelope)
+			deliver = append(deliver, held.data)
+		}
+		rs.resuming = false
+		rs.held = nil
+		return append(deliver, message), resumeRefused, 0
+	}
+
+	outcome = resumeNone
+	if rs.resuming && envelope.Type == EventTypeSessionResumed {
+		rs.resuming = false
+		rs.held = nil
+		outcome, replayed = resumeAccepted, envelope.Replayed
+	}
+	rs.record(envelope)
+	return [][]byte{message}, outcome, replayed
+}
+
+// record notes the session and last event of a delivered message, the caller holds
+// the mutex
+func (rs *resumeState) record(envelope messageEnvelope) {
+	if envelope.Type == EventTypeSessionCreated {
+		rs.sessionID, rs.token = envelope.SessionID, envelope.ResumeToken
+	}
+	if envelope.EventID != "" {
+		rs.lastEventID = envelope.EventID
+	}
+}
@@ -0,0 +1,93 @@
+package asr
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// EventGap describes server events of a session the recognizer never processed,
+// reported to the OnEventGap callback. The server numbers the events of a session
+// with the seq field; a gap means events were lost while disconnected beyond what a
+// resume replays, or dropped because the event channel was full.
+type EventGap struct {
+	SessionID string
+	// Sequence numbers of the first and last missing event
+	FirstSeq uint64
+	LastSeq  uint64
+}
+
+// Missed returns the number of missing events
+func (g EventGap) Missed() uint64 {
+	return g.LastSeq - g.FirstSeq + 1
+}
+
+// sequenceTracker follows the sequence numbers of the events of the recognizer's
+// session and reports the gaps between them
+type sequenceTracker struct {
+	mutex     sync.Mutex
+	sessionID string
+	last      uint64
+	onGap     func(EventGap)
+}
+
+// reset forgets the session of a previous run
+func (t *sequenceTracker) reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.sessionID, t.last = "", 0
+}
+
+// observe records the sequence number of a message about to be dispatched and returns
+// the gap before it. Unnumbered messages and events replayed again are ignored, the
+// events of a new session start over.
+func (t *sequenceTracker) observe(message []byte) (EventGap, bool) {
+	var envelope struct {
+		SessionID string `json:"session_id"`
+		Seq       uint64 `json:"seq"`
+	}
+	if err := json.Unmarshal(message, &envelope); err != nil || envelope.Seq == 0 {
+		return EventGap{}, false
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if envelope.SessionID != "" && envelope.SessionID != t.sessionID {
+		t.sessionID, t.last = envelope.SessionID, 0
+	}
+	if envelope.Seq <= t.last {
+		return EventGap{}, false
+	}
+
+	gap := EventGap{SessionID: t.sessionID, FirstSeq: t.last + 1, LastSeq: envelope.Seq - 1}
+	t.last = envelope.Seq
+	return gap, gap.FirstSeq <= gap.LastSeq
+}
+
+// OnEventGap sets the callback run when events of the session are missing, before the
+// event following them is dispatched; nil removes it. It runs on the goroutine
+// processing events and should return quickly.
+func (r *Recognizer) OnEventGap(fn func(EventGap)) {
+	r.sequence.mutex.Lock()
+	defer r.sequence.mutex.Unlock()
+	r.sequence.onGap = fn
+}
+
+// checkSequence reports the gap before a message to the OnEventGap callback
+func (r *Recognizer) checkSequence(message []byte) {
+	gap, ok := r.sequence.observe(message)
+	if !ok {
+		return
+	}
+	r.logger.Warn("Missed server events", Fields{"component": "processor", "sessionID": gap.SessionID,
+		"firstSeq": gap.FirstSeq, "lastSeq": gap.LastSeq})
+	r.eventStats.RecordEvent("event_gap", true, "missed server events")
+
+	r.sequence.mutex.Lock()
+	fn := r.sequence.onGap
+	r.sequence.mutex.Unlock()
+	if fn != nil {
+		fn(gap)
+	}
+}
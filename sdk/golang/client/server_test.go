@@ -0,0 +1,172 @@
+package asr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeServer is a realtime endpoint on a local httptest server. It records the client
+// events of every connection in order and sends the events a test gives it to the
+// current connection.
+type fakeServer struct {
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+	received chan clientEvent
+
+	mutex     sync.Mutex
+	conn      *websocket.Conn
+	rejecting bool
+}
+
+// clientEvent is an event the fake server received
+type clientEvent struct {
+	Type    string `json:"type"`
+	EventID string `json:"event_id"`
+	Audio   string `json:"audio"`
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	s := &fakeServer{received: make(chan clientEvent, 1000)}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// url returns the WebSocket URL of the server
+func (s *fakeServer) url() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http")
+}
+
+func (s *fakeServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	rejecting := s.rejecting
+	s.mutex.Unlock()
+	if rejecting {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.mutex.Lock()
+	s.conn = conn
+	s.mutex.Unlock()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var event clientEvent
+		if json.Unmarshal(data, &event) == nil {
+			s.received <- event
+		}
+	}
+}
+
+// send writes an event to the current connection
+func (s *fakeServer) send(t *testing.T, event map[string]any) {
+	t.Helper()
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn == nil {
+		t.Fatal("no client connected")
+	}
+	if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		t.Fatalf("send %v: %v", event["type"], err)
+	}
+}
+
+// drop closes the current connection without a close frame, as a network failure does
+func (s *fakeServer) drop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn != nil {
+		s.conn.UnderlyingConn().Close()
+		s.conn = nil
+	}
+}
+
+// closeNormally closes the current connection with a normal closure frame
+func (s *fakeServer) closeNormally() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn != nil {
+		message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye")
+		s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+		s.conn = nil
+	}
+}
+
+// reject makes the server refuse new connections until reject(false)
+func (s *fakeServer) reject(rejecting bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.rejecting = rejecting
+}
+
+// next returns the next received event of one of the types, skipping other events
+func (s *fakeServer) next(t *testing.T, types ...string) clientEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-s.received:
+			for _, eventType := range types {
+				if event.Type == eventType {
+					return event
+				}
+			}
+		case <-timeout:
+			t.Fatalf("no %v event received", types)
+		}
+	}
+}
+
+// startRecognizer starts a recognizer connected to the server, stopped at the end of
+// the test
+func startRecognizer(t *testing.T, server *fakeServer, configure func(*Config)) *Recognizer {
+	t.Helper()
+	config := DefaultConfig()
+	config.URL = server.url()
+	config.ReconnectDelay = 20 * time.Millisecond
+	if configure != nil {
+		configure(config)
+	}
+	r, err := NewRecognizer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Stop() })
+	server.next(t, EventTypeSessionUpdate)
+	return r
+}
+
+// waitFor polls condition until it holds, failing the test after 5 seconds
+func waitFor(t *testing.T, condition func() bool, what string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -2,7 +2,6 @@ package asr
 
 import (
 	"fmt"
-	"log"
 	"sync"
 	"time"
 )
@@ -23,6 +22,7 @@ type Session struct {
 	Tools                         []interface{}
 	ToolChoice                    string
 	IsInitialized                 bool
+	Capabilities                  *Capabilities
 }
 
 // AudioFormat represents audio format configuration
@@ -34,8 +34,11 @@ type AudioFormat struct {
 
 // TranscriptionConfig represents audio transcription configuration
 type TranscriptionConfig struct {
-	Model    string `json:"model"`
-	Language string `json:"language"`
+	Model          string `json:"model"`
+	Language       string `json:"language"`
+	InterimResults bool   `json:"interim_results,omitempty"`
+	DeltaMode      string `json:"delta_mode,omitempty"` // "full" (default) or "compact"
+	Hints          []string `json:"hints,omitempty"`     // custom vocabulary
 }
 
 // TurnDetectionConfig represents turn detection configuration
@@ -62,6 +65,7 @@ type SessionManager struct {
 	session      *Session
 	sessionMutex sync.RWMutex
 	eventHandler  EventHandler
+	logger        Logger
 }
 
 // NewSessionManager creates a new session manager
@@ -69,9 +73,15 @@ func NewSessionManager(handler EventHandler) *SessionManager {
 	return &SessionManager{
 		session:     nil,
 		eventHandler: handler,
+		logger:       NopLogger{},
 	}
 }
 
+// SetLogger sets the logger of the session manager, nil discards the log output
+func (sm *SessionManager) SetLogger(logger Logger) {
+	sm.logger = loggerOrNop(logger)
+}
+
 // CreateSession initializes a new session with default configuration
 func (sm *SessionManager) CreateSession() *Session {
 	sm.sessionMutex.Lock()
@@ -99,7 +109,7 @@ func (sm *SessionManager) CreateSession() *Session {
 
 	sm.session = session
 
-	log.Printf("[🆔 Session] Created new session: %s", session.ID)
+	sm.logger.Debug("Created new session", Fields{"component": "session", "sessionID": session.ID})
 	return session
 }
 
@@ -147,6 +157,24 @@ func (sm *SessionManager) UpdateSession(config SessionConfig) error {
 		}
 		sm.session.InputAudioTranscription.Language = config.TranscriptionLanguage
 	}
+	if config.InterimResults {
+		if sm.session.InputAudioTranscription == nil {
+			sm.session.InputAudioTranscription = &TranscriptionConfig{}
+		}
+		sm.session.InputAudioTranscription.InterimResults = true
+	}
+	if config.CompactDeltas {
+		if sm.session.InputAudioTranscription == nil {
+			sm.session.InputAudioTranscription = &TranscriptionConfig{}
+		}
+		sm.session.InputAudioTranscription.DeltaMode = DeltaModeCompact
+	}
+	if len(config.TranscriptionHints) > 0 {
+		if sm.session.InputAudioTranscription == nil {
+			sm.session.InputAudioTranscription = &TranscriptionConfig{}
+		}
+		sm.session.InputAudioTranscription.Hints = config.TranscriptionHints
+	}
 
 	if config.TurnDetectionType != "" {
 		if sm.session.TurnDetection == nil {
@@ -183,7 +211,7 @@ func (sm *SessionManager) UpdateSession(config SessionConfig) error {
 	sm.session.UpdatedAt = time.Now()
 	sm.session.Status = string(SessionStatusUpdated)
 
-	log.Printf("[⚙️ Session] Updated session %s with new configuration", sm.session.ID)
+	sm.logger.Debug("Updated session configuration", Fields{"component": "session", "sessionID": sm.session.ID})
 	return nil
 }
 
@@ -206,7 +234,7 @@ func (sm *SessionManager) SetSessionStatus(status SessionStatus) {
 	sm.session.Status = string(status)
 	sm.session.UpdatedAt = time.Now()
 
-	log.Printf("[📊 Session] Session %s status changed to: %s", sm.session.ID, status)
+	sm.logger.Debug("Session status changed", Fields{"component": "session", "sessionID": sm.session.ID, "status": status})
 }
 
 // MarkSessionInitialized marks the session as initialized
@@ -221,7 +249,7 @@ func (sm *SessionManager) MarkSessionInitialized() {
 	sm.session.IsInitialized = true
 	sm.session.UpdatedAt = time.Now()
 
-	log.Printf("[✅ Session] Session %s marked as initialized", sm.session.ID)
+	sm.logger.Debug("Session initialized", Fields{"component": "session", "sessionID": sm.session.ID})
 }
 
 // IsSessionInitialized returns true if session is initialized
@@ -242,15 +270,16 @@ func (sm *SessionManager) HandleSessionCreated(event *SessionCreatedEvent) {
 	defer sm.sessionMutex.Unlock()
 
 	if sm.session == nil {
-		log.Printf("[⚠️ Session] Received session.created but no local session exists")
+		sm.logger.Warn("Received session.created but no local session exists", Fields{"component": "session"})
 		return
 	}
 
 	// Update session info from server event
 	sm.session.Status = string(SessionStatusActive)
 	sm.session.UpdatedAt = time.Now()
+	sm.session.Capabilities = event.Capabilities
 
-	log.Printf("[✅ Session] Session %s created and activated", event.Session.ID)
+	sm.logger.Info("Session created", Fields{"component": "session", "sessionID": event.Session.ID})
 
 	// Notify event handler
 	if sm.eventHandler != nil {
@@ -264,14 +293,14 @@ func (sm *SessionManager) HandleSessionUpdated(event *SessionUpdatedEvent) {
 	defer sm.sessionMutex.Unlock()
 
 	if sm.session == nil {
-		log.Printf("[⚠️ Session] Received session.updated but no local session exists")
+		sm.logger.Warn("Received session.updated but no local session exists", Fields{"component": "session"})
 		return
 	}
 
 	sm.session.Status = string(SessionStatusActive)
 	sm.session.UpdatedAt = time.Now()
 
-	log.Printf("[🔄 Session] Session %s updated", event.Session.ID)
+	sm.logger.Debug("Session updated", Fields{"component": "session", "sessionID": event.Session.ID})
 
 	// Notify event handler
 	if sm.eventHandler != nil {
@@ -319,12 +348,12 @@ func (sm *SessionManager) Cleanup() {
 	defer sm.sessionMutex.Unlock()
 
 	if sm.session != nil {
-		log.Printf("[🧹 Session] Cleaning up session: %s", sm.session.ID)
+		sm.logger.Debug("Cleaning up session", Fields{"component": "session", "sessionID": sm.session.ID})
 		sm.session.Status = string(SessionStatusInactive)
 	}
 
 	sm.session = nil
-	log.Printf("[✅ Session] Session manager cleanup completed")
+	sm.logger.Debug("Session manager cleanup completed", Fields{"component": "session"})
 }
 
 // generateSessionID generates a unique session ID
@@ -346,6 +375,9 @@ type SessionConfig struct {
 	// Transcription configuration
 	TranscriptionModel     string
 	TranscriptionLanguage  string
+	InterimResults         bool
+	CompactDeltas          bool
+	TranscriptionHints     []string
 
 	// Turn detection configuration
 	TurnDetectionType               string
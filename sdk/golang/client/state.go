@@ -0,0 +1,168 @@
+package asr
+
+import (
+	"sync"
+	"time"
+)
+
+// ConnectionState is a state of the connection of a ConnectionManager:
+//
+//	Idle → Connecting → Connected → Reconnecting → Connected ...
+//	                        ↓            ↓
+//	                      Closed ←───────┘
+//
+// Closed is reached by Disconnect, by a failed connect, when the server closes the
+// connection and nothing reconnects it, or when every reconnect attempt failed; a
+// later Connect starts over from Closed.
+type ConnectionState int
+
+const (
+	StateIdle ConnectionState = iota
+	StateConnecting
+	StateConnected
+	StateReconnecting
+	StateClosed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// Reasons of state transitions
+const (
+	StateReasonConnect          = "connect"           // Connect was called
+	StateReasonConnected        = "connected"         // the handshake succeeded
+	StateReasonConnectFailed    = "connect_failed"    // the handshake failed, Err tells why
+	StateReasonConnectionLost   = "connection_lost"   // the connection dropped, Err tells why
+	StateReasonServerClosed     = "server_closed"     // the server closed the connection normally
+	StateReasonReconnectAttempt = "reconnect_attempt" // a reconnect attempt starts, see Attempt
+	StateReasonReconnected      = "reconnected"       // a reconnect attempt succeeded
+	StateReasonReconnectFailed  = "reconnect_failed"  // every attempt failed or the reconnect was canceled
+	StateReasonDisconnected     = "disconnected"      // Disconnect or Cleanup was called
+)
+
+// StateTransition is a change of the connection state. Each reconnect attempt is
+// reported as a transition from Reconnecting to Reconnecting with its Attempt number.
+type StateTransition struct {
+	From    ConnectionState
+	To      ConnectionState
+	At      time.Time
+	Reason  string // one of the StateReason* reasons
+	Err     error  // the error behind connect_failed, connection_lost and reconnect_failed
+	Attempt int    // number of the reconnect attempt, from 1, 0 outside reconnects
+}
+
+// stateChangesBuffered is the capacity of the channels of StateChanges
+const stateChangesBuffered = 32
+
+// connectionState holds the state of a ConnectionManager and its subscribers, safe
+// for concurrent use
+type connectionState struct {
+	mutex       sync.Mutex
+	current     ConnectionState
+	subscribers []chan StateTransition
+	closed      bool
+}
+
+func (s *connectionState) get() ConnectionState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.current
+}
+
+// set moves to state to and notifies the subscribers. Transitions to the current
+// state are only reported for reconnect attempts.
+func (s *connectionState) set(to ConnectionState, reason string, err error, attempt int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.transition(to, reason, err, attempt)
+}
+
+// transition is set with the mutex held
+func (s *connectionState) transition(to ConnectionState, reason string, err error, attempt int) {
+	if s.current == to && attempt == 0 {
+		return
+	}
+	transition := StateTransition{From: s.current, To: to, At: time.Now(), Reason: reason, Err: err, Attempt: attempt}
+	s.current = to
+	for _, ch := range s.subscribers {
+		// A subscriber that fell behind loses its oldest transition rather than the newest
+		select {
+		case ch <- transition:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- transition
+		}
+	}
+}
+
+// lost moves a connected manager to Reconnecting when the connection will be replaced
+// and to Closed otherwise
+func (s *connectionState) lost(reconnecting bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.current != StateConnected {
+		return
+	}
+	if reconnecting {
+		s.transition(StateReconnecting, StateReasonConnectionLost, err, 0)
+	} else {
+		s.transition(StateClosed, StateReasonConnectionLost, err, 0)
+	}
+}
+
+func (s *connectionState) subscribe() <-chan StateTransition {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ch := make(chan StateTransition, stateChangesBuffered)
+	if s.closed {
+		close(ch)
+		return ch
+	}
+	s.subscribers = append(s.subscribers, ch)
+	return ch
+}
+
+// close closes the channels of the subscribers
+func (s *connectionState) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for _, ch := range s.subscribers {
+		close(ch)
+	}
+	s.subscribers = nil
+}
+
+// State returns the current state of the connection
+func (cm *ConnectionManager) State() ConnectionState {
+	return cm.state.get()
+}
+
+// StateChanges returns a channel receiving every later change of the connection
+// state, closed by Cleanup. Each call returns a new channel; a receiver that falls
+// more than 32 transitions behind loses the oldest ones, State gives the current state.
+func (cm *ConnectionManager) StateChanges() <-chan StateTransition {
+	return cm.state.subscribe()
+}
@@ -0,0 +1,177 @@
+package asr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// transition is the part of a StateTransition a test compares
+type transition struct {
+	from, to ConnectionState
+	reason   string
+	attempt  int
+}
+
+func TestConnectionStateTransitions(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// run drives the manager; ownerReconnects is set, as the Recognizer sets it
+		run  func(t *testing.T, cm *ConnectionManager, server *fakeServer)
+		want []transition
+	}{
+		{
+			name: "connect and disconnect",
+			run: func(t *testing.T, cm *ConnectionManager, server *fakeServer) {
+				mustConnect(t, cm)
+				cm.Disconnect()
+			},
+			want: []transition{
+				{StateIdle, StateConnecting, StateReasonConnect, 0},
+				{StateConnecting, StateConnected, StateReasonConnected, 0},
+				{StateConnected, StateClosed, StateReasonDisconnected, 0},
+			},
+		},
+		{
+			name: "connect failed",
+			run: func(t *testing.T, cm *ConnectionManager, server *fakeServer) {
+				server.reject(true)
+				if err := cm.Connect(ctx); err == nil {
+					t.Fatal("connected to a rejecting server")
+				}
+			},
+			want: []transition{
+				{StateIdle, StateConnecting, StateReasonConnect, 0},
+				{StateConnecting, StateClosed, StateReasonConnectFailed, 0},
+			},
+		},
+		{
+			name: "server closed",
+			run: func(t *testing.T, cm *ConnectionManager, server *fakeServer) {
+				mustConnect(t, cm)
+				server.closeNormally()
+				if _, _, err := cm.ReadMessage(ctx); err == nil {
+					t.Fatal("read from a closed connection")
+				}
+			},
+			want: []transition{
+				{StateIdle, StateConnecting, StateReasonConnect, 0},
+				{StateConnecting, StateConnected, StateReasonConnected, 0},
+				{StateConnected, StateClosed, StateReasonServerClosed, 0},
+			},
+		},
+		{
+			name: "connection lost and reconnected",
+			run: func(t *testing.T, cm *ConnectionManager, server *fakeServer) {
+				mustConnect(t, cm)
+				server.drop()
+				if _, _, err := cm.ReadMessage(ctx); err == nil {
+					t.Fatal("read from a dropped connection")
+				}
+				if err := cm.Reconnect(ctx); err != nil {
+					t.Fatalf("reconnect: %v", err)
+				}
+			},
+			want: []transition{
+				{StateIdle, StateConnecting, StateReasonConnect, 0},
+				{StateConnecting, StateConnected, StateReasonConnected, 0},
+				{StateConnected, StateReconnecting, StateReasonConnectionLost, 0},
+				{StateReconnecting, StateReconnecting, StateReasonReconnectAttempt, 1},
+				{StateReconnecting, StateConnected, StateReasonReconnected, 0},
+				{StateConnected, StateClosed, StateReasonDisconnected, 0},
+			},
+		},
+		{
+			name: "every reconnect attempt failed",
+			run: func(t *testing.T, cm *ConnectionManager, server *fakeServer) {
+				mustConnect(t, cm)
+				server.reject(true)
+				server.drop()
+				if _, _, err := cm.ReadMessage(ctx); err == nil {
+					t.Fatal("read from a dropped connection")
+				}
+				if err := cm.Reconnect(ctx); err == nil {
+					t.Fatal("reconnected to a rejecting server")
+				}
+			},
+			want: []transition{
+				{StateIdle, StateConnecting, StateReasonConnect, 0},
+				{StateConnecting, StateConnected, StateReasonConnected, 0},
+				{StateConnected, StateReconnecting, StateReasonConnectionLost, 0},
+				{StateReconnecting, StateReconnecting, StateReasonReconnectAttempt, 1},
+				{StateReconnecting, StateReconnecting, StateReasonReconnectAttempt, 2},
+				{StateReconnecting, StateClosed, StateReasonReconnectFailed, 0},
+			},
+		},
+		{
+			name: "connect again after closing",
+			run: func(t *testing.T, cm *ConnectionManager, server *fakeServer) {
+				mustConnect(t, cm)
+				cm.Disconnect()
+				mustConnect(t, cm)
+			},
+			want: []transition{
+				{StateIdle, StateConnecting, StateReasonConnect, 0},
+				{StateConnecting, StateConnected, StateReasonConnected, 0},
+				{StateConnected, StateClosed, StateReasonDisconnected, 0},
+				{StateClosed, StateConnecting, StateReasonConnect, 0},
+				{StateConnecting, StateConnected, StateReasonConnected, 0},
+				{StateConnected, StateClosed, StateReasonDisconnected, 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t)
+			cm := NewConnectionManager(server.url())
+			cm.SetReconnectOptions(false, 2, 10*time.Millisecond)
+			cm.ownerReconnects = true
+			changes := cm.StateChanges()
+
+			tt.run(t, cm, server)
+			// Cleanup closes the channel of StateChanges
+			cm.Cleanup()
+
+			var got []transition
+			for change := range changes {
+				got = append(got, transition{change.From, change.To, change.Reason, change.Attempt})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("transitions %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("transition %d is %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+			if state := cm.State(); state != StateClosed {
+				t.Errorf("state after cleanup %s, want closed", state)
+			}
+		})
+	}
+}
+
+func TestStateChangesDropOldestForSlowReceiver(t *testing.T) {
+	var state connectionState
+	changes := state.subscribe()
+	for attempt := 1; attempt <= stateChangesBuffered+3; attempt++ {
+		state.set(StateReconnecting, StateReasonReconnectAttempt, nil, attempt)
+	}
+	state.close()
+
+	var attempts []int
+	for change := range changes {
+		attempts = append(attempts, change.Attempt)
+	}
+	if len(attempts) != stateChangesBuffered || attempts[0] != 4 || attempts[len(attempts)-1] != stateChangesBuffered+3 {
+		t.Errorf("received attempts %v, want the last %d", attempts, stateChangesBuffered)
+	}
+}
+
+func mustConnect(t *testing.T, cm *ConnectionManager) {
+	t.Helper()
+	if err := cm.Connect(context.Background()); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+}
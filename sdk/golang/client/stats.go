@@ -0,0 +1,60 @@
+package asr
+
+import "sync"
+
+// sessionStatsCallback holds the OnSessionStats callback, subscribed to session.stats
+// on first use
+type sessionStatsCallback struct {
+	mutex     sync.Mutex
+	fn        func(*SessionStatsEvent)
+	subscribe sync.Once
+}
+
+// OnSessionStats sets the callback run with every session.stats event, sent at the
+// interval of Config.StatsReportSeconds and in answer to RequestSessionStats; nil
+// removes it. It runs on the goroutine processing events and should return quickly.
+func (r *Recognizer) OnSessionStats(fn func(*SessionStatsEvent)) {
+	r.sessionStats.mutex.Lock()
+	r.sessionStats.fn = fn
+	r.sessionStats.mutex.Unlock()
+
+	r.sessionStats.subscribe.Do(func() {
+		r.On(EventTypeSessionStats, r.dispatchSessionStats)
+	})
+}
+
+func (r *Recognizer) dispatchSessionStats(event Event) {
+	e, ok := event.(*SessionStatsEvent)
+	if !ok {
+		return
+	}
+	r.sessionStats.mutex.Lock()
+	fn := r.sessionStats.fn
+	r.sessionStats.mutex.Unlock()
+	if fn != nil {
+		fn(e)
+	}
+}
+
+// RequestSessionStats asks the server for the counters of the session so far. The
+// session.stats answer carries the event ID of the request in RequestEventID and is
+// delivered to OnSessionStats like the periodic reports.
+func (r *Recognizer) RequestSessionStats() (string, error) {
+	r.runningMutex.RLock()
+	defer r.runningMutex.RUnlock()
+
+	if !r.isRunning {
+		return "", ErrRecognizerNotRunning
+	}
+
+	event := &SessionStatsRequestEvent{
+		BaseEvent: BaseEvent{
+			Type:    EventTypeSessionStatsRequest,
+			EventID: generateEventID(),
+		},
+	}
+	if err := r.sendEvent(event); err != nil {
+		return "", err
+	}
+	return event.EventID, nil
+}
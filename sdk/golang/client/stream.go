@@ -0,0 +1,169 @@
+package asr
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+)
+
+// AudioWriter returns an io.Writer sending the audio written to it through the
+// recognizer, so PCM16 audio can be copied in from any reader, e.g. the stdout of
+// ffmpeg. Writes may split samples and frames anywhere; an incomplete frame is held
+// back until the rest of it arrives. The writer is not safe for concurrent use.
+func (r *Recognizer) AudioWriter() io.Writer {
+	return &audioWriter{recognizer: r}
+}
+
+type audioWriter struct {
+	recognizer *Recognizer
+	partial    []byte // the start of a frame split by the last write
+}
+
+func (w *audioWriter) Write(p []byte) (int, error) {
+	frameSize := 2 * max(w.recognizer.config.InputChannels, 1)
+
+	data := p
+	if len(w.partial) > 0 {
+		data = append(w.partial, p...)
+	}
+	whole := len(data) - len(data)%frameSize
+
+	if whole > 0 {
+		if err := w.recognizer.Write(data[:whole]); err != nil {
+			return 0, err
+		}
+	}
+	w.partial = append(w.partial[:0], data[whole:]...)
+	return len(p), nil
+}
+
+// TranscriptReader yields the final transcripts of a running recognizer, one line per
+// utterance, so they can be consumed as a plain io.Reader. Next returns them one at a
+// time instead. Transcripts are queued until read; once the recognizer stops the
+// reader returns io.EOF after the queued ones.
+type TranscriptReader struct {
+	recognizer *Recognizer
+	done       <-chan struct{} // the run of the recognizer ended
+	id         HandlerID
+
+	mutex   sync.Mutex
+	queue   []string
+	notify  chan struct{} // closed when the queue grows or the reader is closed
+	closed  bool
+	pending string // the unread part of the line Read is returning
+}
+
+// Transcripts returns a reader of the final transcripts produced from now on. It is
+// at EOF right away when the recognizer is not running. Close it to stop queueing.
+func (r *Recognizer) Transcripts() *TranscriptReader {
+	r.runningMutex.RLock()
+	done := r.ctx.Done()
+	if !r.isRunning {
+		stopped := make(chan struct{})
+		close(stopped)
+		done = stopped
+	}
+	r.runningMutex.RUnlock()
+
+	tr := &TranscriptReader{
+		recognizer: r,
+		done:       done,
+		notify:     make(chan struct{}),
+	}
+	tr.id = r.On(EventTypeConversationItemInputAudioTranscriptionCompleted, tr.onCompleted)
+	return tr
+}
+
+func (tr *TranscriptReader) onCompleted(event Event) {
+	e, ok := event.(*ConversationItemInputAudioTranscriptionCompletedEvent)
+	if !ok {
+		return
+	}
+
+	var text strings.Builder
+	for _, content := range e.Item.Content {
+		if content.Type == "transcript" {
+			text.WriteString(content.Transcript)
+		}
+	}
+
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if tr.closed {
+		return
+	}
+	tr.queue = append(tr.queue, text.String())
+	close(tr.notify)
+	tr.notify = make(chan struct{})
+}
+
+// Next waits for the next transcript. It returns io.EOF once the recognizer stopped
+// and every queued transcript was returned, io.ErrClosedPipe after Close, or the
+// error of ctx.
+func (tr *TranscriptReader) Next(ctx context.Context) (string, error) {
+	for {
+		tr.mutex.Lock()
+		if tr.closed {
+			tr.mutex.Unlock()
+			return "", io.ErrClosedPipe
+		}
+		if len(tr.queue) > 0 {
+			text := tr.queue[0]
+			tr.queue = tr.queue[1:]
+			tr.mutex.Unlock()
+			return text, nil
+		}
+		notify := tr.notify
+		tr.mutex.Unlock()
+
+		select {
+		case <-notify:
+		case <-tr.done:
+			// A transcript dispatched just before the stop is still returned
+			tr.mutex.Lock()
+			empty := len(tr.queue) == 0
+			tr.mutex.Unlock()
+			if empty {
+				return "", io.EOF
+			}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// Read reads the transcripts as text, each followed by a newline
+func (tr *TranscriptReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if tr.pending == "" {
+		text, err := tr.Next(context.Background())
+		if err != nil {
+			return 0, err
+		}
+		tr.pending = text + "\n"
+	}
+
+	n := copy(p, tr.pending)
+	tr.pending = tr.pending[n:]
+	return n, nil
+}
+
+// Close stops queueing transcripts and drops the queued ones, a Next or Read waiting
+// returns io.ErrClosedPipe
+func (tr *TranscriptReader) Close() error {
+	tr.recognizer.Off(tr.id)
+
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if !tr.closed {
+		tr.closed = true
+		tr.queue = nil
+		close(tr.notify)
+	}
+	return nil
+}
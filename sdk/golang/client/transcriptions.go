@@ -0,0 +1,231 @@
+package asr
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNoAudioCommitted is returned when a commit produced no conversation item,
+// e.g. because the server found no speech in the committed audio
+var ErrNoAudioCommitted = errors.New("no audio committed")
+
+// TranscriptionResult is the transcript of the item a commit created, returned by
+// CommitAndWait
+type TranscriptionResult struct {
+	ItemID string
+	Text   string
+	// Language the server detected when the session's language is unset or "auto"
+	Language string
+	// Input channel of the item in multi-channel sessions
+	Channel *int
+	// Span of the item's speech in the session audio, zero when the server does not
+	// report it
+	AudioStartMs int64
+	AudioEndMs   int64
+}
+
+// pendingTranscription is a caller waiting for the item its own commit created
+type pendingTranscription struct {
+	commitID string // event_id of the commit
+	// Receives the ID of the item, empty when the commit created none
+	item chan string
+}
+
+func (p *pendingTranscription) resolve(itemID string) {
+	select {
+	case p.item <- itemID:
+	default:
+	}
+}
+
+// transcriptionTracker correlates commits with their conversation items so that
+// concurrent callers each receive the transcript of the audio they committed.
+//
+// The server answers every input_audio_buffer.commit with input_audio_buffer.committed
+// events naming the commit and the items it created, one per channel with speech, or a
+// single one without an item when the buffer held no speech. Servers predating
+// commit_event_id answer each commit with one committed event in order, followed by
+// conversation.item.created when the buffer contained speech, so the item created after
+// the Nth committed event belongs to the Nth commit. The transcript of the item is then
+// waited for on the item tracker, see items.go.
+type transcriptionTracker struct {
+	recognizer *Recognizer
+
+	// submitMutex serializes Write+Commit sequences so audio of concurrent calls is not interleaved
+	submitMutex sync.Mutex
+
+	mutex     sync.Mutex
+	committed []*pendingTranscription // commit sent, waiting for committed
+	current   *pendingTranscription   // committed received, waiting for item created
+}
+
+func newTranscriptionTracker(r *Recognizer) *transcriptionTracker {
+	t := &transcriptionTracker{
+		recognizer: r,
+	}
+
+	r.On(EventTypeInputAudioBufferCommitted, t.onCommitted)
+	r.On(EventTypeConversationItemCreated, t.onItemCreated)
+
+	return t
+}
+
+// submit writes and commits audio, returning a pending transcription for that commit
+func (t *transcriptionTracker) submit(audioData []byte) (*pendingTranscription, error) {
+	t.submitMutex.Lock()
+	defer t.submitMutex.Unlock()
+
+	if err := t.recognizer.Write(audioData); err != nil {
+		return nil, err
+	}
+
+	return t.commit(context.Background())
+}
+
+// commit commits the server-side audio buffer, returning a pending transcription for it
+func (t *transcriptionTracker) commit(ctx context.Context) (*pendingTranscription, error) {
+	pending := &pendingTranscription{commitID: generateEventID(), item: make(chan string, 1)}
+
+	// Queue before committing, the committed event may arrive before CommitAudio returns
+	t.mutex.Lock()
+	t.committed = append(t.committed, pending)
+	t.mutex.Unlock()
+
+	if err := t.recognizer.commitAudio(ctx, pending.commitID); err != nil {
+		t.cancel(pending)
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+// cancel forgets a pending transcription, e.g. after the caller timed out
+func (t *transcriptionTracker) cancel(pending *pendingTranscription) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for i, p := range t.committed {
+		if p == pending {
+			t.committed = append(t.committed[:i], t.committed[i+1:]...)
+			break
+		}
+	}
+	if t.current == pending {
+		t.current = nil
+	}
+}
+
+// wait blocks until the item of a pending commit has its transcript or ctx ends
+func (t *transcriptionTracker) wait(ctx context.Context, pending *pendingTranscription) (TranscriptionResult, error) {
+	var itemID string
+	select {
+	case itemID = <-pending.item:
+	case <-ctx.Done():
+		t.cancel(pending)
+		return TranscriptionResult{}, ctx.Err()
+	}
+	if itemID == "" {
+		return TranscriptionResult{}, ErrNoAudioCommitted
+	}
+
+	result, err := t.recognizer.itemTracker().wait(ctx, itemID)
+	if err != nil {
+		return TranscriptionResult{}, err
+	}
+	return result.TranscriptionResult, result.Err
+}
+
+func (t *transcriptionTracker) onCommitted(event Event) {
+	e, ok := event.(*InputAudioBufferCommittedEvent)
+	if !ok {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if e.CommitEventID != "" {
+		t.onCommitAnswered(e)
+		return
+	}
+
+	// The previous commit never produced an item
+	if t.current != nil {
+		t.current.resolve("")
+		t.current = nil
+	}
+
+	if len(t.committed) > 0 {
+		t.current = t.committed[0]
+		t.committed = t.committed[1:]
+	}
+}
+
+// onCommitAnswered binds a commit to the first item named by the committed events
+// answering it; the caller waits for that item. The caller holds the mutex.
+func (t *transcriptionTracker) onCommitAnswered(e *InputAudioBufferCommittedEvent) {
+	for i, pending := range t.committed {
+		if pending.commitID != e.CommitEventID {
+			continue
+		}
+		t.committed = append(t.committed[:i], t.committed[i+1:]...)
+		pending.resolve(e.ItemID)
+		return
+	}
+}
+
+func (t *transcriptionTracker) onItemCreated(event Event) {
+	e, ok := event.(*ConversationItemCreatedEvent)
+	if !ok {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Items not preceded by one of our commits come from server-side turn detection
+	if t.current == nil {
+		return
+	}
+
+	t.current.resolve(e.Item.ID)
+	t.current = nil
+}
+
+// completedResult returns the result of a completed transcription
+func completedResult(e *ConversationItemInputAudioTranscriptionCompletedEvent) TranscriptionResult {
+	result := TranscriptionResult{
+		ItemID:       e.Item.ID,
+		Language:     e.Language,
+		Channel:      e.Channel,
+		AudioStartMs: e.AudioStartMs,
+		AudioEndMs:   e.AudioEndMs,
+	}
+	for _, content := range e.Item.Content {
+		if content.Type == "transcript" {
+			result.Text += content.Transcript
+		}
+	}
+	return result
+}
+
+// failedResult returns the result and error of a failed transcription
+func failedResult(e *ConversationItemInputAudioTranscriptionFailedEvent) (TranscriptionResult, error) {
+	return TranscriptionResult{ItemID: e.ItemID, Channel: e.Channel}, e.Err()
+}
+
+// CommitAndWait commits the audio written since the last commit and waits for the
+// transcript of the item it created. Results are matched by item, so concurrent callers
+// and server-side turn detection do not interfere. It returns ErrNoAudioCommitted when
+// the buffer held no speech, an *ASRError when transcription failed, and the error of
+// ctx when it ends first; in a multi-channel session the first channel with speech is
+// waited for.
+func (r *Recognizer) CommitAndWait(ctx context.Context) (TranscriptionResult, error) {
+	t := r.tracker()
+	pending, err := t.commit(ctx)
+	if err != nil {
+		return TranscriptionResult{}, err
+	}
+	return t.wait(ctx, pending)
+}
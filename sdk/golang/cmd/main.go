@@ -197,18 +197,12 @@ func processAudioFile(wrapper *asr.CompatibilityWrapper, filePath, fileName stri
 			for i, v := range resampled.Data {
 				reSamples[i] = int16(v)
 			}
-			// add silence to the end
-			silence := make([]int16, 48000) 
-			reSamples = append(reSamples, silence...)
 			byteData,err = samplesToBytes(reSamples)
 			if err != nil {
 				return fmt.Errorf("error converting samples to bytes: %v", err)
 			}
 
 		}  else {
-			// add silence to the end
-			silence := make([]int16, 48000) 
-			pcmData = append(pcmData, silence...)
 			byteData,err = samplesToBytes(pcmData)
 			if err != nil {
 				return fmt.Errorf("error converting samples to bytes: %v", err)
@@ -227,6 +221,15 @@ func processAudioFile(wrapper *asr.CompatibilityWrapper, filePath, fileName stri
 			}
 
 			chunk := byteData[i:end]
+
+			// Commit with the last chunk so the file's final utterance is recognized
+			// without padding the file with silence until the VAD ends it
+			if end == len(byteData) {
+				if err := wrapper.WriteAndCommit(chunk); err != nil {
+					return fmt.Errorf("error sending audio chunk at position %d: %v", i, err)
+				}
+				break
+			}
 			if err := wrapper.Write(chunk); err != nil {
 				return fmt.Errorf("error sending audio chunk at position %d: %v", i, err)
 			}
@@ -0,0 +1,370 @@
+// Command simstream replays WAV recordings against the server through the SDK under
+// simulated network conditions: faster or slower than real time, with send jitter and
+// packet loss, and reports transcription latency so operators can characterize the
+// pipeline before going live.
+//
+//	simstream -speed 1.5 -jitter-ms 80 -loss 0.05 -loss-burst 3 recording.wav
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	asr "gosdk/client"
+	"gosdk/pkg/wav"
+)
+
+type options struct {
+	url       string
+	language  string
+	speed     float64
+	chunkMs   int
+	jitterMs  int
+	loss      float64
+	lossBurst float64
+	lossMode  string
+	commitMs  int
+	seed      int64
+	timeout   time.Duration
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.url, "url", "ws://localhost:8088/v1/realtime", "server WebSocket URL")
+	flag.StringVar(&opts.language, "lang", "zh", "transcription language")
+	flag.Float64Var(&opts.speed, "speed", 1.0, "replay speed relative to real time, e.g. 0.5 or 2")
+	flag.IntVar(&opts.chunkMs, "chunk-ms", 100, "audio per packet in milliseconds")
+	flag.IntVar(&opts.jitterMs, "jitter-ms", 0, "maximum random send delay added to each packet")
+	flag.Float64Var(&opts.loss, "loss", 0, "fraction of packets lost, 0 to 1")
+	flag.Float64Var(&opts.lossBurst, "loss-burst", 1, "mean number of consecutive packets lost per loss event")
+	flag.StringVar(&opts.lossMode, "loss-mode", "drop", "lost packets are dropped (drop) or replaced with silence (silence)")
+	flag.IntVar(&opts.commitMs, "commit-ms", 0, "commit the audio buffer every N ms of audio, 0 relies on server VAD")
+	flag.Int64Var(&opts.seed, "seed", 0, "random seed for jitter and loss, 0 uses the clock")
+	flag.DurationVar(&opts.timeout, "timeout", 60*time.Second, "time to wait for the last transcripts")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: simstream [flags] <recording.wav>...\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatalf("[❌ simstream] %v", err)
+	}
+	if opts.seed == 0 {
+		opts.seed = time.Now().UnixNano()
+	}
+
+	for _, path := range flag.Args() {
+		report, err := replay(path, opts)
+		if err != nil {
+			log.Fatalf("[❌ simstream] %s: %v", path, err)
+		}
+		report.print(path)
+	}
+}
+
+func (o options) validate() error {
+	if o.speed <= 0 {
+		return fmt.Errorf("speed must be positive")
+	}
+	if o.chunkMs <= 0 || o.jitterMs < 0 || o.commitMs < 0 {
+		return fmt.Errorf("chunk-ms must be positive, jitter-ms and commit-ms non-negative")
+	}
+	if o.loss < 0 || o.loss >= 1 {
+		return fmt.Errorf("loss must be in [0, 1)")
+	}
+	if o.lossBurst < 1 {
+		return fmt.Errorf("loss-burst must be at least 1")
+	}
+	if o.lossMode != "drop" && o.lossMode != "silence" {
+		return fmt.Errorf("loss-mode must be drop or silence")
+	}
+	return nil
+}
+
+// lossModel is a two-state Gilbert model: packets are lost in the bad state, whose
+// stationary probability is the loss rate and whose mean duration is the burst length
+type lossModel struct {
+	rng       *rand.Rand
+	goodToBad float64
+	badToGood float64
+	bad       bool
+}
+
+func newLossModel(rng *rand.Rand, loss, burst float64) *lossModel {
+	m := &lossModel{rng: rng, badToGood: 1 / burst}
+	if loss > 0 {
+		m.goodToBad = loss * m.badToGood / (1 - loss)
+	}
+	return m
+}
+
+func (m *lossModel) lost() bool {
+	if m.bad {
+		m.bad = m.rng.Float64() >= m.badToGood
+	} else {
+		m.bad = m.rng.Float64() < m.goodToBad
+	}
+	return m.bad
+}
+
+// replayReport summarizes a replay
+type replayReport struct {
+	audio       time.Duration
+	wall        time.Duration
+	packets     int
+	lost        int
+	transcripts []string
+	failures    int
+	latencies   []time.Duration
+	opts        options
+}
+
+func (r *replayReport) print(path string) {
+	fmt.Printf("\n📊 %s\n", path)
+	fmt.Printf("   speed=%.2fx chunk=%dms jitter=%dms loss=%.1f%% burst=%.1f mode=%s seed=%d\n",
+		r.opts.speed, r.opts.chunkMs, r.opts.jitterMs, r.opts.loss*100, r.opts.lossBurst, r.opts.lossMode, r.opts.seed)
+	fmt.Printf("   audio %s sent in %s (effective %.2fx)\n",
+		r.audio.Round(time.Millisecond), r.wall.Round(time.Millisecond), r.audio.Seconds()/r.wall.Seconds())
+	fmt.Printf("   packets %d, lost %d (%.1f%%)\n", r.packets, r.lost, float64(r.lost)*100/float64(max(r.packets, 1)))
+	fmt.Printf("   transcripts %d, failed %d\n", len(r.transcripts), r.failures)
+
+	if len(r.latencies) > 0 {
+		sorted := append([]time.Duration(nil), r.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		var sum time.Duration
+		for _, l := range sorted {
+			sum += l
+		}
+		fmt.Printf("   latency min %s, avg %s, p50 %s, p95 %s, max %s\n",
+			sorted[0].Round(time.Millisecond),
+			(sum / time.Duration(len(sorted))).Round(time.Millisecond),
+			percentile(sorted, 0.5).Round(time.Millisecond),
+			percentile(sorted, 0.95).Round(time.Millisecond),
+			sorted[len(sorted)-1].Round(time.Millisecond))
+	}
+	for i, text := range r.transcripts {
+		fmt.Printf("   %3d. %s\n", i+1, text)
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[int(p*float64(len(sorted)-1)+0.5)]
+}
+
+// replayHandler measures latency from the moment the end of an utterance was sent to
+// its transcript. Utterance ends come from speech_stopped (server VAD) or from our
+// own commits, and are matched to transcripts in order.
+type replayHandler struct {
+	asr.DefaultEventHandler
+
+	mutex   sync.Mutex
+	sentAt  []time.Time // wall time each sent chunk left, indexed by chunk of server-side audio
+	chunkMs int
+	commits bool // utterances end at our commits rather than at speech_stopped
+	pending []time.Time
+	report  *replayReport
+}
+
+func (h *replayHandler) chunkSent(at time.Time) {
+	h.mutex.Lock()
+	h.sentAt = append(h.sentAt, at)
+	h.mutex.Unlock()
+}
+
+func (h *replayHandler) utteranceEnded(at time.Time) {
+	h.mutex.Lock()
+	h.pending = append(h.pending, at)
+	h.mutex.Unlock()
+}
+
+func (h *replayHandler) OnSpeechStopped(event *asr.InputAudioBufferSpeechStoppedEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.commits {
+		return
+	}
+
+	// audio_end_ms counts the audio the server received, dropped packets never arrive
+	index := event.AudioEndMs / h.chunkMs
+	if index >= len(h.sentAt) {
+		index = len(h.sentAt) - 1
+	}
+	if index >= 0 {
+		h.pending = append(h.pending, h.sentAt[index])
+	}
+}
+
+func (h *replayHandler) OnTranscriptionCompleted(event *asr.ConversationItemInputAudioTranscriptionCompletedEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	text := ""
+	if len(event.Item.Content) > 0 {
+		text = event.Item.Content[0].Transcript
+	}
+	h.report.transcripts = append(h.report.transcripts, text)
+	if latency, ok := h.popPending(); ok {
+		h.report.latencies = append(h.report.latencies, latency)
+	}
+	fmt.Printf("✅ %s\n", text)
+}
+
+func (h *replayHandler) OnTranscriptionFailed(event *asr.ConversationItemInputAudioTranscriptionFailedEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.report.failures++
+	h.popPending()
+	fmt.Printf("❌ transcription failed: %s\n", event.Error.Message)
+}
+
+func (h *replayHandler) OnError(event *asr.ErrorEvent) {
+	fmt.Printf("💥 server error: %s\n", event.Error.Message)
+}
+
+func (h *replayHandler) popPending() (time.Duration, bool) {
+	if len(h.pending) == 0 {
+		return 0, false
+	}
+	sent := h.pending[0]
+	h.pending = h.pending[1:]
+	return time.Since(sent), true
+}
+
+// replay streams a recording with the configured pacing, jitter and loss
+func replay(path string, opts options) (*replayReport, error) {
+	samples, sampleRate, err := loadWAV(path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &replayReport{
+		audio: time.Duration(len(samples)) * time.Second / time.Duration(sampleRate),
+		opts:  opts,
+	}
+	handler := &replayHandler{chunkMs: opts.chunkMs, commits: opts.commitMs > 0, report: report}
+
+	config := asr.DefaultConfig()
+	config.URL = opts.url
+	config.TranscriptionLanguage = opts.language
+	config.InputSampleRate = sampleRate
+	config.InputChannels = 1
+
+	recognizer, err := asr.NewRecognizerWithEventHandler(config, handler)
+	if err != nil {
+		return nil, err
+	}
+	if err := recognizer.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start recognizer: %w", err)
+	}
+
+	rng := rand.New(rand.NewSource(opts.seed))
+	loss := newLossModel(rng, opts.loss, opts.lossBurst)
+	chunkSamples := sampleRate * opts.chunkMs / 1000
+	chunkInterval := time.Duration(float64(opts.chunkMs) * float64(time.Millisecond) / opts.speed)
+	jitter := time.Duration(opts.jitterMs) * time.Millisecond
+
+	start := time.Now()
+	last := start
+	sinceCommit := 0
+	for offset := 0; offset < len(samples); offset += chunkSamples {
+		chunk := samples[offset:min(offset+chunkSamples, len(samples))]
+		report.packets++
+
+		// A delayed packet holds back the ones behind it, the stream stays in order
+		due := start.Add(time.Duration(report.packets-1) * chunkInterval)
+		if jitter > 0 {
+			due = due.Add(time.Duration(rng.Int63n(int64(jitter) + 1)))
+		}
+		if due.Before(last) {
+			due = last
+		}
+		time.Sleep(time.Until(due))
+		last = due
+
+		if loss.lost() {
+			report.lost++
+			if opts.lossMode == "drop" {
+				continue
+			}
+			chunk = make([]int16, len(chunk))
+		}
+
+		if err := recognizer.Write(samplesToBytes(chunk)); err != nil {
+			recognizer.Stop()
+			return nil, fmt.Errorf("failed to send packet %d: %w", report.packets, err)
+		}
+		handler.chunkSent(time.Now())
+
+		sinceCommit += opts.chunkMs
+		if opts.commitMs > 0 && sinceCommit >= opts.commitMs {
+			handler.utteranceEnded(time.Now())
+			if err := recognizer.CommitAudio(); err != nil {
+				recognizer.Stop()
+				return nil, fmt.Errorf("failed to commit audio: %w", err)
+			}
+			sinceCommit = 0
+		}
+	}
+	report.wall = time.Since(start)
+
+	if opts.commitMs > 0 && sinceCommit > 0 {
+		handler.utteranceEnded(time.Now())
+	}
+	if err := recognizer.StopAndFlush(opts.timeout); err != nil {
+		log.Printf("[⚠️ simstream] Failed to flush recognition results: %v", err)
+	}
+	return report, nil
+}
+
+// loadWAV reads a 16-bit PCM recording as mono samples
+func loadWAV(path string) ([]int16, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := wav.NewReader(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read WAV header: %w", err)
+	}
+	format := reader.GetFormat()
+	if format.AudioFormat != 1 || format.BitsPerSample != 16 {
+		return nil, 0, fmt.Errorf("unsupported WAV format, requires 16-bit PCM")
+	}
+	if format.SampleRate != 16000 && format.SampleRate != 48000 {
+		return nil, 0, fmt.Errorf("unsupported sample rate %d, requires 16000 or 48000", format.SampleRate)
+	}
+
+	samples, err := reader.ReadSamplesPCM()
+	if err != nil {
+		return nil, 0, err
+	}
+	channels := int(format.NumChannels)
+	if channels > 1 {
+		samples = asr.NewAudioUtils(int(format.SampleRate), channels).ConvertToMono(samples, channels)
+	}
+	return samples, int(format.SampleRate), nil
+}
+
+func samplesToBytes(samples []int16) []byte {
+	data := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(sample))
+	}
+	return data
+}
@@ -125,4 +125,5 @@ func (h *BasicEventHandler) OnAudioBufferAppended(event *asr.InputAudioBufferApp
 func (h *BasicEventHandler) OnAudioBufferCommitted(event *asr.InputAudioBufferCommittedEvent) {}
 func (h *BasicEventHandler) OnAudioBufferCleared(event *asr.InputAudioBufferClearedEvent) {}
 func (h *BasicEventHandler) OnSpeechStarted(event *asr.InputAudioBufferSpeechStartedEvent) {}
-func (h *BasicEventHandler) OnSpeechStopped(event *asr.InputAudioBufferSpeechStoppedEvent) {}
\ No newline at end of file
+func (h *BasicEventHandler) OnSpeechStopped(event *asr.InputAudioBufferSpeechStoppedEvent) {}
+func (h *BasicEventHandler) OnTranscriptionDelta(event *asr.ConversationItemInputAudioTranscriptionDeltaEvent) {}
@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"math"
-	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	asr "gosdk/client"
+	"gosdk/pkg/mic"
 )
 
 func main() {
@@ -38,38 +39,18 @@ func main() {
 	// Start result display
 	go handler.displayResults()
 
-	fmt.Println("✅ Recognizer started, listening for audio input...")
-	fmt.Println("Please input audio data (press Enter to send, type 'quit' to exit):")
+	fmt.Println("✅ Recognizer started, listening to the microphone...")
 
-	// Simple command line audio input
-	go func() {
-		for {
-			var input string
-			fmt.Print("Audio> ")
-			fmt.Scanln(&input)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-			if input == "quit" || input == "exit" {
-				fmt.Println("👋 Exiting...")
-				recognizer.Stop()
-				return
-			}
-
-			if input == "" {
-				continue
-			}
-
-			// Simulate audio data (in real applications, this would be actual audio data)
-			audioData := generateTestAudio(input)
-			if err := recognizer.Write(audioData); err != nil {
-				log.Printf("Failed to send audio: %v", err)
-			}
+	// Capture the default input device until Ctrl+C, resampled to 16kHz mono
+	if err := mic.CaptureToRecognizer(ctx, recognizer, mic.DefaultDevice); err != nil {
+		if errors.Is(err, mic.ErrUnsupported) {
+			log.Fatalf("Microphone capture needs PortAudio, run with: go run -tags portaudio ./examples/streaming")
 		}
-	}()
-
-	// Wait for signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+		log.Fatalf("Microphone capture failed: %v", err)
+	}
 
 	fmt.Println("\n👋 Program exiting")
 }
@@ -146,6 +127,10 @@ func (h *StreamingHandler) OnAudioBufferCleared(event *asr.InputAudioBufferClear
 func (h *StreamingHandler) OnSpeechStarted(event *asr.InputAudioBufferSpeechStartedEvent) {}
 func (h *StreamingHandler) OnSpeechStopped(event *asr.InputAudioBufferSpeechStoppedEvent) {}
 
+func (h *StreamingHandler) OnTranscriptionDelta(event *asr.ConversationItemInputAudioTranscriptionDeltaEvent) {
+	fmt.Printf("⏳ Partial: %s\n", event.Transcript)
+}
+
 // displayResults displays streaming recognition results
 func (h *StreamingHandler) displayResults() {
 	for {
@@ -170,24 +155,3 @@ func (h *StreamingHandler) displayResults() {
 		}
 	}
 }
-
-// generateTestAudio generates test audio data
-func generateTestAudio(text string) []byte {
-	// Generate audio data with duration based on text length
-	duration := float64(len(text)) // 1 second per character
-	samples := int(duration * 16000)     // 16kHz
-	audioData := make([]byte, samples*2)
-
-	// Generate simple sine wave
-	for i := 0; i < samples; i++ {
-		t := float64(i) / float64(samples)
-		frequency := 440.0 + (float64(i%10)*100.0) // Varies from 440Hz-1340Hz
-		value := int16(16383 * math.Sin(2*math.Pi*frequency*t))
-
-		// Convert to little-endian bytes
-		audioData[i*2] = byte(value & 0xFF)
-		audioData[i*2+1] = byte((value >> 8) & 0xFF)
-	}
-
-	return audioData
-}
\ No newline at end of file
@@ -6,4 +6,7 @@ require (
 	github.com/go-audio/audio v1.0.0
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
+	github.com/sirupsen/logrus v1.9.3
 )
+
+require golang.org/x/sys v0.35.0 // indirect
@@ -0,0 +1,116 @@
+//go:build portaudio
+
+package mic
+
+/*
+#cgo pkg-config: portaudio-2.0
+#include <portaudio.h>
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"unsafe"
+)
+
+// Supported reports whether this build can capture audio
+const Supported = true
+
+// paError converts a PortAudio error code
+func paError(code C.PaError) error {
+	return fmt.Errorf("portaudio: %s", C.GoString(C.Pa_GetErrorText(code)))
+}
+
+// Devices lists the audio input devices
+func Devices() ([]Device, error) {
+	if code := C.Pa_Initialize(); code != C.paNoError {
+		return nil, paError(code)
+	}
+	defer C.Pa_Terminate()
+
+	count := C.Pa_GetDeviceCount()
+	if count < 0 {
+		return nil, paError(C.PaError(count))
+	}
+	defaultID := C.Pa_GetDefaultInputDevice()
+
+	var devices []Device
+	for id := C.PaDeviceIndex(0); id < count; id++ {
+		info := C.Pa_GetDeviceInfo(id)
+		if info == nil || info.maxInputChannels <= 0 {
+			continue
+		}
+		devices = append(devices, Device{
+			ID:         int(id),
+			Name:       C.GoString(info.name),
+			Channels:   int(info.maxInputChannels),
+			SampleRate: int(info.defaultSampleRate),
+			Default:    id == defaultID,
+		})
+	}
+	return devices, nil
+}
+
+// Capture reads audio from the input device deviceID, or DefaultDevice, and passes it
+// to fn as 16kHz mono PCM16 in chunks of about 50ms, until ctx ends or fn fails. The
+// device is opened at its native rate with at most two channels, which are mixed down.
+// fn must not keep samples after it returns.
+func Capture(ctx context.Context, deviceID int, fn func(samples []int16) error) error {
+	if code := C.Pa_Initialize(); code != C.paNoError {
+		return paError(code)
+	}
+	defer C.Pa_Terminate()
+
+	device := C.PaDeviceIndex(deviceID)
+	if deviceID == DefaultDevice {
+		device = C.Pa_GetDefaultInputDevice()
+		if device == C.paNoDevice {
+			return fmt.Errorf("portaudio: no default input device")
+		}
+	}
+	info := C.Pa_GetDeviceInfo(device)
+	if info == nil || info.maxInputChannels <= 0 {
+		return fmt.Errorf("portaudio: device %d is not an input device", deviceID)
+	}
+
+	channels := int(info.maxInputChannels)
+	if channels > 2 {
+		channels = 2
+	}
+	sampleRate := int(info.defaultSampleRate)
+	frames := sampleRate * chunkMs / 1000
+
+	params := C.PaStreamParameters{
+		device:           device,
+		channelCount:     C.int(channels),
+		sampleFormat:     C.paInt16,
+		suggestedLatency: info.defaultLowInputLatency,
+	}
+	var stream unsafe.Pointer
+	if code := C.Pa_OpenStream(&stream, &params, nil, C.double(sampleRate), C.ulong(frames), C.paClipOff, nil, nil); code != C.paNoError {
+		return paError(code)
+	}
+	defer C.Pa_CloseStream(stream)
+
+	if code := C.Pa_StartStream(stream); code != C.paNoError {
+		return paError(code)
+	}
+	defer C.Pa_StopStream(stream)
+
+	resampler := newResampler(sampleRate, SampleRate)
+	buffer := make([]int16, frames*channels)
+	for ctx.Err() == nil {
+		// An overflow lost input the device could not hand over in time, the rest is fine
+		code := C.Pa_ReadStream(stream, unsafe.Pointer(&buffer[0]), C.ulong(frames))
+		if code != C.paNoError && code != C.paInputOverflowed {
+			return paError(code)
+		}
+		if samples := resampler.process(downmix(buffer, channels)); len(samples) > 0 {
+			if err := fn(samples); err != nil {
+				return err
+			}
+		}
+	}
+	return ctx.Err()
+}
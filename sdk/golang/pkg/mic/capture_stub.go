@@ -0,0 +1,18 @@
+//go:build !portaudio
+
+package mic
+
+import "context"
+
+// Supported reports whether this build can capture audio
+const Supported = false
+
+// Devices returns ErrUnsupported, build with -tags portaudio to capture audio
+func Devices() ([]Device, error) {
+	return nil, ErrUnsupported
+}
+
+// Capture returns ErrUnsupported, build with -tags portaudio to capture audio
+func Capture(ctx context.Context, deviceID int, fn func(samples []int16) error) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,64 @@
+// Package mic captures microphone audio for a recognizer. Capture needs cgo and the
+// PortAudio headers and is only built with -tags portaudio; other builds report
+// ErrUnsupported so programs using the package still build without the library.
+//
+// Audio is captured at the device's native rate and channel count and converted to
+// the 16kHz mono PCM16 a recognizer expects by default.
+package mic
+
+import (
+	"context"
+	"errors"
+
+	asr "gosdk/client"
+)
+
+// ErrUnsupported is returned by Capture and Devices in builds without the portaudio tag
+var ErrUnsupported = errors.New("microphone capture not available, build with -tags portaudio")
+
+// SampleRate is the rate of the audio Capture delivers, mono 16-bit PCM
+const SampleRate = 16000
+
+// DefaultDevice selects the system's default input device
+const DefaultDevice = -1
+
+// chunkMs is the duration of the audio read from the device at a time
+const chunkMs = 50
+
+// Device is an audio input device
+type Device struct {
+	ID         int
+	Name       string
+	Channels   int
+	SampleRate int // native rate, resampled to SampleRate on capture
+	Default    bool
+}
+
+// CaptureToRecognizer writes audio from the input device deviceID, or DefaultDevice,
+// to rec until ctx ends or a write fails. rec must be started and configured for
+// 16kHz mono input, the default. It returns nil when ctx ends.
+func CaptureToRecognizer(ctx context.Context, rec *asr.Recognizer, deviceID int) error {
+	err := Capture(ctx, deviceID, func(samples []int16) error {
+		return rec.WriteContext(ctx, asr.PCM16ToBytes(samples))
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// downmix averages interleaved samples of channels to mono
+func downmix(samples []int16, channels int) []int16 {
+	if channels <= 1 {
+		return samples
+	}
+	mono := make([]int16, len(samples)/channels)
+	for i := range mono {
+		sum := 0
+		for c := 0; c < channels; c++ {
+			sum += int(samples[i*channels+c])
+		}
+		mono[i] = int16(sum / channels)
+	}
+	return mono
+}
@@ -0,0 +1,82 @@
+package mic
+
+import "math"
+
+// resamplerHalfTaps is the number of zero crossings of the sinc kernel on each side
+const resamplerHalfTaps = 16
+
+// resampler converts a stream of mono PCM16 between sample rates with a windowed sinc
+// interpolator, low-pass filtering below the output's Nyquist frequency when
+// downsampling so device rates such as 44.1kHz and 48kHz do not alias. It keeps the
+// input it still needs between calls.
+type resampler struct {
+	step    float64 // input samples per output sample
+	cutoff  float64 // of the low-pass, relative to the input's Nyquist frequency
+	width   int     // half width of the kernel in input samples
+	history []float64
+	pos     float64 // position of the next output sample in history
+}
+
+func newResampler(inRate, outRate int) *resampler {
+	r := &resampler{step: float64(inRate) / float64(outRate), cutoff: 0.95}
+	if outRate < inRate {
+		r.cutoff *= float64(outRate) / float64(inRate)
+	}
+	r.width = int(math.Ceil(resamplerHalfTaps / r.cutoff))
+	// Silence before the first sample centres the kernel on it
+	r.history = make([]float64, r.width)
+	r.pos = float64(r.width)
+	return r
+}
+
+// process returns the output for the next input samples
+func (r *resampler) process(samples []int16) []int16 {
+	if r.step == 1 {
+		return samples
+	}
+	for _, s := range samples {
+		r.history = append(r.history, float64(s))
+	}
+
+	var out []int16
+	for int(r.pos)+r.width < len(r.history) {
+		center := int(r.pos)
+		frac := r.pos - float64(center)
+		sum := 0.0
+		for k := -r.width + 1; k <= r.width; k++ {
+			sum += r.history[center+k] * r.kernel(float64(k)-frac)
+		}
+		out = append(out, clampPCM16(sum))
+		r.pos += r.step
+	}
+
+	// Drop the input no later output reaches back to
+	if drop := int(r.pos) - r.width; drop > 0 {
+		r.history = append(r.history[:0], r.history[drop:]...)
+		r.pos -= float64(drop)
+	}
+	return out
+}
+
+// kernel is the Hann windowed low-pass sinc at x input samples from the output
+func (r *resampler) kernel(x float64) float64 {
+	if math.Abs(x) >= float64(r.width) {
+		return 0
+	}
+	window := 0.5 + 0.5*math.Cos(math.Pi*x/float64(r.width))
+	arg := math.Pi * r.cutoff * x
+	if arg == 0 {
+		return r.cutoff * window
+	}
+	return r.cutoff * math.Sin(arg) / arg * window
+}
+
+func clampPCM16(v float64) int16 {
+	switch {
+	case v > math.MaxInt16:
+		return math.MaxInt16
+	case v < math.MinInt16:
+		return math.MinInt16
+	}
+	return int16(math.Round(v))
+}
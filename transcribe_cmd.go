@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-restream/stt/pkg/captions"
+	"github.com/go-restream/stt/pkg/textformat"
+
+	asr "gosdk/client"
+)
+
+const transcribeUsage = `Usage: stt transcribe [options] <file>...
+
+Transcribes audio files with a running server, one session per file. Files are
+decoded with ffmpeg, so any format it reads works; 16-bit PCM WAV files are
+transcribed without it.
+
+Options:
+`
+
+// defaultRealtimeURL is the realtime endpoint of a server running with the example
+// configuration
+const defaultRealtimeURL = "ws://localhost:8088/v1/realtime"
+
+// transcriptSegment is a segment of the JSON output of stt transcribe
+type transcriptSegment struct {
+	ItemID   string `json:"item_id"`
+	StartMs  int64  `json:"start_ms"`
+	EndMs    int64  `json:"end_ms"`
+	Text     string `json:"text"`
+	Language string `json:"language,omitempty"`
+}
+
+// runTranscribeCommand implements "stt transcribe" and returns the exit code
+func runTranscribeCommand(args []string) int {
+	fs := flag.NewFlagSet("transcribe", flag.ContinueOnError)
+	url := fs.String("url", defaultRealtimeURL, "Realtime WebSocket URL of the server")
+	apiKey := fs.String("key", "", "API key sent as the bearer token")
+	language := fs.String("lang", asr.DefaultConfig().TranscriptionLanguage, `Transcription language, "auto" to detect it`)
+	model := fs.String("model", asr.DefaultConfig().TranscriptionModel, "Transcription model")
+	speed := fs.Float64("speed", 4, "Pacing of the audio as a multiple of real time, negative to send it unpaced")
+	ffmpeg := fs.String("ffmpeg", "", "Path of the ffmpeg binary, looked up in PATH when empty")
+	format := fs.String("format", "text", "Output format: text, json, or the caption formats srt, vtt and jsonl")
+	outDir := fs.String("o", "", "Directory the output of each file is written to, named after the file; stdout when empty")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, transcribeUsage)
+		fs.PrintDefaults()
+	}
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return 2
+	}
+	ext, err := outputExt(*format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✘ %v\n", err)
+		return 2
+	}
+
+	config := asr.DefaultConfig()
+	config.URL = *url
+	config.TranscriptionLanguage = *language
+	config.TranscriptionModel = *model
+	if *apiKey != "" {
+		config.Headers = map[string]string{"Authorization": "Bearer " + *apiKey}
+	}
+	opts := asr.TranscribeFileOptions{Config: config, FFmpegPath: *ffmpeg, Speed: *speed}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	code := 0
+	for _, file := range files {
+		transcript, err := asr.TranscribeFileContext(ctx, file, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✘ %s: %v\n", file, err)
+			code = 1
+		}
+		if transcript == nil {
+			continue
+		}
+		if err := saveTranscript(file, transcript, *format, ext, *outDir, len(files) > 1); err != nil {
+			fmt.Fprintf(os.Stderr, "✘ %v\n", err)
+			return 1
+		}
+	}
+	return code
+}
+
+// outputExt returns the file extension of an output format of stt transcribe
+func outputExt(format string) (string, error) {
+	switch format {
+	case "text":
+		return ".txt", nil
+	case "json":
+		return ".json", nil
+	}
+	captionFormat, err := captions.ParseFormat(format)
+	if err != nil {
+		return "", fmt.Errorf("unknown output format %q, expected text, json, srt, vtt or jsonl", format)
+	}
+	return captionFormat.Ext(), nil
+}
+
+// saveTranscript writes the transcript of a file to a file of its name in outDir, or
+// to stdout when outDir is empty
+func saveTranscript(file string, transcript *asr.FileTranscript, format, ext, outDir string, named bool) error {
+	if outDir == "" {
+		return writeTranscript(os.Stdout, file, transcript, format, named)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)) + ext
+	out, err := os.Create(filepath.Join(outDir, name))
+	if err != nil {
+		return err
+	}
+	if err := writeTranscript(out, file, transcript, format, false); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writeTranscript writes the transcript of a file in format, headed by the file name
+// when several text transcripts go to stdout
+func writeTranscript(w io.Writer, file string, transcript *asr.FileTranscript, format string, named bool) error {
+	switch format {
+	case "text":
+	case "json":
+		output := struct {
+			File       string              `json:"file"`
+			DurationMs int64               `json:"duration_ms"`
+			Segments   []transcriptSegment `json:"segments"`
+		}{File: file, DurationMs: transcript.Duration.Milliseconds(), Segments: []transcriptSegment{}}
+		for _, segment := range transcript.Segments {
+			output.Segments = append(output.Segments, transcriptSegment{
+				ItemID:   segment.ItemID,
+				StartMs:  segment.Start.Milliseconds(),
+				EndMs:    segment.End.Milliseconds(),
+				Text:     segment.Text,
+				Language: segment.Language,
+			})
+		}
+		return json.NewEncoder(w).Encode(output)
+	default:
+		captionFormat, _ := captions.ParseFormat(format)
+		writer := captions.NewWriter(w, captionFormat, textformat.CueOptions{})
+		for _, segment := range transcript.Segments {
+			err := writer.WriteSegment(captions.Segment{
+				ItemID:   segment.ItemID,
+				StartMs:  segment.Start.Milliseconds(),
+				EndMs:    segment.End.Milliseconds(),
+				Text:     segment.Text,
+				Language: segment.Language,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return writer.Flush()
+	}
+
+	if named {
+		fmt.Fprintf(w, "== %s\n", file)
+	}
+	for _, segment := range transcript.Segments {
+		fmt.Fprintf(w, "[%s - %s] %s\n", formatOffset(segment.Start), formatOffset(segment.End), segment.Text)
+	}
+	return nil
+}
+
+// formatOffset formats an offset into a file as hh:mm:ss.mmm
+func formatOffset(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// parseInterspersed parses flags given before, between and after the positional
+// arguments, as in "stt transcribe file.wav -lang zh", and returns the latter
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
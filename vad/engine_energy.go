@@ -0,0 +1,33 @@
+package vad
+
+import (
+	yaml "github.com/go-restream/stt/config"
+	"github.com/go-restream/stt/pkg/energyvad"
+)
+
+// energyEngine is the pure-Go energy and zero-crossing detector. It needs no model and
+// reads the thresholds and durations of the vad section, ignoring the model settings.
+type energyEngine struct {
+	*energyvad.Detector
+}
+
+func newEnergyEngine(cfg *yaml.Config) VADEngine {
+	return energyEngine{energyvad.New(energyvad.Options{
+		SampleRate:         cfg.Vad.SampleRate,
+		FrameSize:          cfg.Vad.WindowSize,
+		Threshold:          cfg.Vad.Threshold,
+		MinSilenceDuration: cfg.Vad.MinSilenceDuration,
+		MinSpeechDuration:  cfg.Vad.MinSpeechDuration,
+		MaxSpeechDuration:  cfg.Vad.MaxSpeechDuration,
+	})}
+}
+
+func (e energyEngine) Front() *SpeechSegment {
+	segment := e.Detector.Front()
+	if segment == nil {
+		return nil
+	}
+	return &SpeechSegment{Start: segment.Start, Samples: segment.Samples}
+}
+
+func (e energyEngine) Close() {}
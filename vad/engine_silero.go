@@ -0,0 +1,58 @@
+//go:build !novad
+
+package vad
+
+import (
+	yaml "github.com/go-restream/stt/config"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// SileroAvailable reports whether the Silero model run by sherpa-onnx is compiled in,
+// false in builds with the novad tag
+const SileroAvailable = true
+
+// sileroEngine runs the Silero model of vad.model
+type sileroEngine struct {
+	*sherpa.VoiceActivityDetector
+}
+
+// newSileroEngine loads the model, nil when it cannot be loaded
+func newSileroEngine(cfg *yaml.Config) VADEngine {
+	bufferSize := float32(20)
+	detector := sherpa.NewVoiceActivityDetector(initVADConfig(cfg), bufferSize)
+	if detector == nil {
+		return nil
+	}
+	return sileroEngine{detector}
+}
+
+func (e sileroEngine) Front() *SpeechSegment {
+	segment := e.VoiceActivityDetector.Front()
+	if segment == nil {
+		return nil
+	}
+	return &SpeechSegment{Start: segment.Start, Samples: segment.Samples}
+}
+
+func (e sileroEngine) Close() {
+	sherpa.DeleteVoiceActivityDetector(e.VoiceActivityDetector)
+}
+
+func initVADConfig(cfg *yaml.Config) *sherpa.VadModelConfig {
+	config := sherpa.VadModelConfig{}
+
+	config.SileroVad.Model = cfg.Vad.Model
+	config.SileroVad.Threshold = cfg.Vad.Threshold
+	config.SileroVad.MinSilenceDuration = cfg.Vad.MinSilenceDuration
+	config.SileroVad.MinSpeechDuration = cfg.Vad.MinSpeechDuration
+	config.SileroVad.WindowSize = cfg.Vad.WindowSize
+	config.SileroVad.MaxSpeechDuration = cfg.Vad.MaxSpeechDuration
+
+	config.SampleRate = cfg.Vad.SampleRate
+	config.NumThreads = cfg.Vad.NumThreads
+	config.Provider = cfg.Vad.Provider
+	config.Debug = cfg.Vad.Debug
+
+	return &config
+}
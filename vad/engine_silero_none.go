@@ -0,0 +1,13 @@
+//go:build novad
+
+package vad
+
+import (
+	yaml "github.com/go-restream/stt/config"
+)
+
+// SileroAvailable reports whether the Silero model run by sherpa-onnx is compiled in,
+// false in builds with the novad tag. Without it every detector uses the energy engine.
+const SileroAvailable = false
+
+func newSileroEngine(cfg *yaml.Config) VADEngine { return nil }
@@ -7,7 +7,6 @@ import (
 
 	yaml "github.com/go-restream/stt/config"
 
-	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,39 +14,130 @@ var (
 	default_sample_rate = 16000
 )
 
+// Engines selectable with vad.engine
+const (
+	EngineSilero = "silero" // the Silero model run by sherpa-onnx, the default
+	EngineEnergy = "energy" // a pure-Go energy and zero-crossing detector needing no model
+)
+
+// SpeechSegment is a detected speech segment, Start is its first sample counted from
+// the creation or last Reset of the detector
+type SpeechSegment struct {
+	Start   int
+	Samples []float32
+}
+
+// VADEngine finds speech segments in a stream of samples. Engines are not safe for
+// concurrent use, the VADDetector wrapping them serializes the calls.
+type VADEngine interface {
+	// AcceptWaveform analyzes the samples, queueing every speech segment ending in them
+	AcceptWaveform(samples []float32)
+	// IsSpeech reports whether speech is in progress
+	IsSpeech() bool
+	// IsEmpty reports whether no segment is queued
+	IsEmpty() bool
+	// Front returns the oldest queued segment
+	Front() *SpeechSegment
+	// Pop removes the oldest queued segment
+	Pop()
+	// Flush ends the speech in progress, queueing it as a segment
+	Flush()
+	// Reset drops all state, including queued segments
+	Reset()
+	// Close releases the engine
+	Close()
+}
+
 type VADDetector struct {
-	vad         *sherpa.VoiceActivityDetector
+	vad         VADEngine
+	engine      string
 	sampleRate  int
 	sampleBuffer []float32
-	speechSegments []sherpa.SpeechSegment
+	speechSegments []SpeechSegment
 	printed     bool
 	config      *yaml.Config
 	mutex       sync.RWMutex
+
+	// Prefix padding: the latest samples are kept so the audio preceding a segment
+	// can be prepended to it. Positions count from the last engine reset like
+	// SpeechSegment.Start, so history ends at historyEnd.
+	prefixPadding int // samples, from vad.prefix_padding_ms
+	history       []float32
+	historyEnd    int
+	preroll       []float32 // history when the speech in progress started
+	prerollEnd    int
 }
 
+// prefixPaddingLag is the history kept beyond the prefix padding, in seconds: engines
+// report speech some time after the segment they eventually return starts
+const prefixPaddingLag = 1
+
 func NewVADDetector(cfg *yaml.Config) *VADDetector {
-	vadCfg := initVADConfig(cfg)
-	bufferSize := float32(20)
-	vad := sherpa.NewVoiceActivityDetector(vadCfg, bufferSize)
-	if vad == nil {
+	vad, engine := newEngine(cfg)
+	return &VADDetector{
+		vad:           vad,
+		engine:        engine,
+		sampleRate:    default_sample_rate,
+		config:        cfg,
+		prefixPadding: cfg.Vad.PrefixPaddingMs * default_sample_rate / 1000,
+	}
+}
+
+// newEngine creates the engine of vad.engine. Without Silero compiled in, or when its
+// model cannot be loaded, the energy engine takes over so the service still runs.
+func newEngine(cfg *yaml.Config) (VADEngine, string) {
+	switch cfg.Vad.Engine {
+	case EngineEnergy:
+		return newEnergyEngine(cfg), EngineEnergy
+	case "", EngineSilero:
+	default:
 		logger.WithFields(logrus.Fields{
 			"component": "eng_vad_audio_sys",
-			"action":    "initialization_failed",
-		}).Fatal("Failed to initialize VAD detector")
+			"action":    "unknown_engine",
+			"engine":    cfg.Vad.Engine,
+		}).Warn("Unknown VAD engine, using silero")
 	}
-	return &VADDetector{
-		vad:        vad,
-		sampleRate: default_sample_rate,
-		config:     cfg,
+
+	if !SileroAvailable {
+		logger.WithFields(logrus.Fields{
+			"component": "eng_vad_audio_sys",
+			"action":    "engine_fallback",
+			"engine":    EngineEnergy,
+		}).Warn("Silero VAD not compiled in (novad build), using the energy engine")
+		return newEnergyEngine(cfg), EngineEnergy
 	}
+	if engine := newSileroEngine(cfg); engine != nil {
+		return engine, EngineSilero
+	}
+	logger.WithFields(logrus.Fields{
+		"component": "eng_vad_audio_sys",
+		"action":    "initialization_failed",
+		"engine":    EngineSilero,
+		"model":     cfg.Vad.Model,
+	}).Error("Failed to load the Silero VAD model, using the energy engine")
+	return newEnergyEngine(cfg), EngineEnergy
+}
+
+// EngineName returns the engine detectors created with cfg run, unless the Silero
+// model fails to load
+func EngineName(cfg *yaml.Config) string {
+	if cfg.Vad.Engine == EngineEnergy || !SileroAvailable {
+		return EngineEnergy
+	}
+	return EngineSilero
+}
+
+// Engine returns the name of the engine the detector runs
+func (v *VADDetector) Engine() string {
+	return v.engine
 }
 
 func (v *VADDetector) Close() {
-	sherpa.DeleteVoiceActivityDetector(v.vad)
+	v.vad.Close()
 }
 
 // ProcessSamples processes audio samples and returns speech segments
-func (v *VADDetector) ProcessSamples(samples []float32) *sherpa.SpeechSegment {
+func (v *VADDetector) ProcessSamples(samples []float32) *SpeechSegment {
 	v.mutex.Lock()
 	defer v.mutex.Unlock()
 
@@ -95,7 +185,7 @@ func (v *VADDetector) ProcessSamples(samples []float32) *sherpa.SpeechSegment {
 
 	if v.config.Vad.BypassForTesting {
 		if len(samples) > 0 {
-			segment := sherpa.SpeechSegment{
+			segment := SpeechSegment{
 				Samples: samples,
 			}
 			logger.WithFields(logrus.Fields{
@@ -109,10 +199,16 @@ func (v *VADDetector) ProcessSamples(samples []float32) *sherpa.SpeechSegment {
 		}
 	}
 
+	wasSpeech := v.vad.IsSpeech()
 	v.vad.AcceptWaveform(samples)
+	v.remember(samples)
 
 	isSpeech := v.vad.IsSpeech()
 	isEmpty := v.vad.IsEmpty()
+	if isSpeech && !wasSpeech && v.prefixPadding > 0 {
+		v.preroll = append(v.preroll[:0], v.history...)
+		v.prerollEnd = v.historyEnd
+	}
 
 	logger.WithFields(logrus.Fields{
 		"component": "eng_vad_audio_sys",
@@ -140,6 +236,7 @@ func (v *VADDetector) ProcessSamples(samples []float32) *sherpa.SpeechSegment {
 	for !isEmpty {
 		segment := v.vad.Front()
 		v.vad.Pop()
+		v.pad(segment)
 		v.speechSegments = append(v.speechSegments, *segment)
 		segmentsCollected++
 		isEmpty = v.vad.IsEmpty()
@@ -176,6 +273,8 @@ func (v *VADDetector) ProcessSamples(samples []float32) *sherpa.SpeechSegment {
 				"sampleRate":    v.sampleRate,
 			}).Info("Processed speech segment")
 			v.vad.Reset()
+			v.historyEnd = 0
+			v.preroll = v.preroll[:0]
 			v.printed = false
 			return &segment
 		}
@@ -189,7 +288,7 @@ func (v *VADDetector) ProcessSamples(samples []float32) *sherpa.SpeechSegment {
 	return nil
 }
 
-func (v *VADDetector) ProcessSample(sample float32) *sherpa.SpeechSegment {
+func (v *VADDetector) ProcessSample(sample float32) *SpeechSegment {
 	v.sampleBuffer = append(v.sampleBuffer, sample)
 
 	if len(v.sampleBuffer) >= 160 {
@@ -199,6 +298,28 @@ func (v *VADDetector) ProcessSample(sample float32) *sherpa.SpeechSegment {
 	return nil
 }
 
+// Flush ends any speech in progress and returns every speech segment not yet
+// returned by ProcessSamples, e.g. when the audio stream ends mid-utterance
+func (v *VADDetector) Flush() []SpeechSegment {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	segments := v.speechSegments
+	v.speechSegments = nil
+	if v.vad == nil {
+		return segments
+	}
+
+	v.vad.Flush()
+	for !v.vad.IsEmpty() {
+		segment := v.vad.Front()
+		v.vad.Pop()
+		v.pad(segment)
+		segments = append(segments, *segment)
+	}
+	return segments
+}
+
 // Reset resets the VAD detector state
 func (v *VADDetector) Reset() {
 	v.mutex.Lock()
@@ -206,30 +327,60 @@ func (v *VADDetector) Reset() {
 
 	v.vad.Reset()
 	v.speechSegments = nil
+	v.sampleBuffer = v.sampleBuffer[:0]
+	v.printed = false
+	v.history = v.history[:0]
+	v.historyEnd = 0
+	v.preroll = v.preroll[:0]
 }
 
-// IsSpeech checks if speech activity is currently detected
-func (v *VADDetector) IsSpeech() bool {
-	v.mutex.RLock()
-	defer v.mutex.RUnlock()
+// PrefixPaddingMs returns the audio prepended to each segment, in milliseconds
+func (v *VADDetector) PrefixPaddingMs() int {
+	return v.prefixPadding * 1000 / v.sampleRate
+}
 
-	return v.vad.IsSpeech()
+// remember appends samples accepted by the engine to the history, keeping the
+// prefix padding and the detection lag
+func (v *VADDetector) remember(samples []float32) {
+	v.historyEnd += len(samples)
+	if v.prefixPadding <= 0 {
+		return
+	}
+	v.history = append(v.history, samples...)
+	if keep := v.prefixPadding + prefixPaddingLag*v.sampleRate; len(v.history) > 2*keep {
+		v.history = append(v.history[:0], v.history[len(v.history)-keep:]...)
+	}
 }
 
-func initVADConfig(cfg *yaml.Config) *sherpa.VadModelConfig{
-	config := sherpa.VadModelConfig{}
+// pad prepends the prefix padding to a segment returned by the engine, taken from
+// the history saved when its speech started. Segments whose preceding audio is no
+// longer kept are returned unchanged.
+func (v *VADDetector) pad(segment *SpeechSegment) {
+	if v.prefixPadding <= 0 {
+		return
+	}
+	source, end := v.preroll, v.prerollEnd
+	if len(source) == 0 {
+		source, end = v.history, v.historyEnd
+	}
+	begin := end - len(source)
+	from := max(segment.Start-v.prefixPadding, begin)
+	if segment.Start > end || from >= segment.Start {
+		return
+	}
 
-	config.SileroVad.Model = cfg.Vad.Model
-	config.SileroVad.Threshold = cfg.Vad.Threshold
-	config.SileroVad.MinSilenceDuration = cfg.Vad.MinSilenceDuration
-	config.SileroVad.MinSpeechDuration = cfg.Vad.MinSpeechDuration
-	config.SileroVad.WindowSize = cfg.Vad.WindowSize
-	config.SileroVad.MaxSpeechDuration = cfg.Vad.MaxSpeechDuration
+	prefix := source[from-begin : segment.Start-begin]
+	samples := make([]float32, 0, len(prefix)+len(segment.Samples))
+	samples = append(samples, prefix...)
+	segment.Samples = append(samples, segment.Samples...)
+	segment.Start = from
+	v.preroll = v.preroll[:0]
+}
 
-	config.SampleRate = cfg.Vad.SampleRate
-	config.NumThreads = cfg.Vad.NumThreads
-	config.Provider = cfg.Vad.Provider
-	config.Debug = cfg.Vad.Debug
+// IsSpeech checks if speech activity is currently detected
+func (v *VADDetector) IsSpeech() bool {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
 
-	return &config
+	return v.vad.IsSpeech()
 }